@@ -0,0 +1,58 @@
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEntryRejected is returned when the admission hook of a tree rejects an entry
+var ErrEntryRejected = errors.New("merkletree: entry rejected")
+
+// AdmissionHook validates the entry d about to be appended at index, rejecting it
+// with a non-nil error
+type AdmissionHook func(index uint64, d []byte) error
+
+// RejectedError reports the entry an admission hook rejected and the hook's error.
+// errors.Is reports true for both ErrEntryRejected and the hook's error.
+type RejectedError struct {
+	Index uint64
+	Err   error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%v: index %d: %v", ErrEntryRejected, e.Index, e.Err)
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrEntryRejected
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// WithAdmissionHook calls hook for every entry appended to the tree, passed to New
+// or set with SetLeaf, with the index it is about to be stored at, before the tree
+// changes. An entry rejected by the hook rejects its whole batch, which TryAppend
+// and the other appending methods return as a *RejectedError. A batch the hook
+// admitted may still be rejected afterwards, e.g. with ErrLogFull, so hooks should
+// not record entries as appended. The hook is called with the tree locked and must
+// not use the tree.
+func WithAdmissionHook(hook AdmissionHook) Option {
+	return func(m *MerkleHashTree) {
+		m.admissionHook = hook
+	}
+}
+
+// admit runs the admission hook on the entries d appended from index first on
+func (m *MerkleHashTree) admit(first uint64, d [][]byte) error {
+	if m.admissionHook == nil {
+		return nil
+	}
+	for i, e := range d {
+		if err := m.admissionHook(first+uint64(i), e); err != nil {
+			return &RejectedError{Index: first + uint64(i), Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errPolicy = errors.New("policy violation")
+
+// rejectingHook rejects entries equal to bad and records the indexes it was called with
+func rejectingHook(bad []byte, seen *[]uint64) AdmissionHook {
+	return func(index uint64, d []byte) error {
+		*seen = append(*seen, index)
+		if bytes.Equal(d, bad) {
+			return errPolicy
+		}
+		return nil
+	}
+}
+
+func TestAdmissionHookRejectsBatchAtomically(t *testing.T) {
+	D := makeEntries(8)
+	bad := []byte("bad")
+	for _, opts := range [][]Option{nil, {WithIndexBoundLeaves()}, {WithDeferredHashing()}, {WithNodeDeltas(), WithRootHistory()}} {
+		var seen []uint64
+		tree := New(nil, append(opts, WithAdmissionHook(rejectingHook(bad, &seen)))...)
+		tree.Append(D[:3]...)
+		head, generation := tree.GenerationHead()
+
+		_, err := tree.TryAppend(D[3], D[4], bad, D[5], D[6])
+		assert.ErrorIs(t, err, ErrEntryRejected)
+		assert.ErrorIs(t, err, errPolicy)
+		var rejected *RejectedError
+		if assert.ErrorAs(t, err, &rejected) {
+			assert.Equal(t, uint64(5), rejected.Index)
+		}
+
+		afterHead, afterGeneration := tree.GenerationHead()
+		assert.Equal(t, head, afterHead)
+		assert.Equal(t, generation, afterGeneration)
+		assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, seen)
+
+		tree.Append(D[3:]...)
+		assert.Equal(t, New(D, opts...).MerkleRoot(), tree.MerkleRoot())
+		assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 3, 4, 5, 6, 7}, seen)
+	}
+}
+
+func TestAdmissionHookOnEveryAppendPath(t *testing.T) {
+	bad := []byte("bad")
+	var seen []uint64
+	tree := New(makeEntries(2), WithAdmissionHook(rejectingHook(bad, &seen)))
+	root := tree.MerkleRoot()
+
+	_, err := tree.AppendIf(root, bad)
+	assert.ErrorIs(t, err, errPolicy)
+	_, err = tree.AppendWithExtra(bad, []byte("extra"))
+	assert.ErrorIs(t, err, errPolicy)
+	_, _, err = tree.AppendWithProof([]byte("good"), bad)
+	assert.ErrorIs(t, err, errPolicy)
+	assert.Equal(t, root, tree.MerkleRoot())
+	assert.Equal(t, []uint64{0, 1, 2, 2, 2, 3}, seen)
+
+	s := NewSequencer(tree, WithMaxBatch(2))
+	good, err := s.Submit([]byte("good"))
+	assert.NoError(t, err)
+	rejected, err := s.Submit(bad)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, (<-good).Err, errPolicy)
+	assert.ErrorIs(t, (<-rejected).Err, errPolicy)
+	assert.Equal(t, root, tree.MerkleRoot())
+}
+
+func TestAdmissionHookOnNewAndSetLeaf(t *testing.T) {
+	D := makeEntries(4)
+	bad := []byte("bad")
+	var seen []uint64
+	hook := WithAdmissionHook(rejectingHook(bad, &seen))
+
+	_, err := TryNew([][]byte{D[0], bad, D[1]}, hook)
+	assert.ErrorIs(t, err, errPolicy)
+	var rejected *RejectedError
+	if assert.ErrorAs(t, err, &rejected) {
+		assert.Equal(t, uint64(1), rejected.Index)
+	}
+	assert.Equal(t, []uint64{0, 1}, seen)
+	assert.Panics(t, func() { New([][]byte{bad}, hook) })
+
+	seen = nil
+	tree := New(D, hook)
+	root := tree.MerkleRoot()
+	_, err = tree.SetLeaf(2, bad)
+	assert.ErrorIs(t, err, errPolicy)
+	assert.Equal(t, root, tree.MerkleRoot())
+	_, err = tree.SetLeaf(2, []byte("good"))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 2, 2}, seen)
+}
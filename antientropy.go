@@ -0,0 +1,152 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// RemoteNodeFetcher returns the hash of the node at level and index of a remote tree,
+// in the canonical layout of the tree at the size a sync is planned for.
+// *MerkleHashTree implements it for its current size.
+type RemoteNodeFetcher interface {
+	Node(level, index uint64) ([sha256.Size]byte, error)
+}
+
+// Range is the range of leaves [Start, End)
+type Range struct {
+	Start uint64
+	End   uint64
+}
+
+// Node returns the hash of the node at level and index in the canonical layout of
+// the tree, which covers the leaves [index·2^level, min((index+1)·2^level, size))
+func (m *MerkleHashTree) Node(level, index uint64) ([sha256.Size]byte, error) {
+	defer m.readLock()()
+	size := uint64(len(m.tree[0]))
+	start, end, ok := NodeID{Level: level, Index: index}.leafRange(size)
+	if !ok {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: no node at level %d, index %d in a tree of size %d", ErrIndexOutOfRange, level, index, size)
+	}
+	return m.mthOfRange(int(start), int(end-1)), nil
+}
+
+// PlanSync returns the ranges of leaves local must fetch from remote to match the
+// remote tree of remoteSize leaves. It descends from the root, only fetching the
+// nodes of subtrees whose local hash differs, so the plan covers the leaves that
+// differ and those local is missing. Leaves local has beyond remoteSize are
+// dropped by ApplySync and not part of the plan.
+func PlanSync(local *MerkleHashTree, remote RemoteNodeFetcher, remoteSize uint64) ([]Range, error) {
+	defer local.readLock()()
+	localSize := uint64(len(local.tree[0]))
+
+	var plan []Range
+	add := func(start, end uint64) {
+		if n := len(plan); n > 0 && plan[n-1].End == start {
+			plan[n-1].End = end
+			return
+		}
+		plan = append(plan, Range{Start: start, End: end})
+	}
+
+	var visit func(start, end uint64) error
+	visit = func(start, end uint64) error {
+		if start >= localSize {
+			add(start, end)
+			return nil
+		}
+		if end <= localSize {
+			id := rangeNode(start, end)
+			hash, err := remote.Node(id.Level, id.Index)
+			if err != nil {
+				return err
+			}
+			if hash == local.mthOfRange(int(start), int(end-1)) {
+				return nil
+			}
+			if end-start == 1 {
+				add(start, end)
+				return nil
+			}
+		}
+
+		k := start + SplitPoint(end-start)
+		if err := visit(start, k); err != nil {
+			return err
+		}
+		return visit(k, end)
+	}
+
+	if remoteSize == 0 {
+		return nil, nil
+	}
+	if err := visit(0, remoteSize); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ApplySync fetches the leaf hashes of the ranges of plan from remote, the nodes
+// at level 0, and patches local to the remote tree head. Leaves of local beyond
+// the head are dropped. The patched tree must have the root of head, or local is
+// left unchanged and ErrRootMismatch is returned. Like SetLeaf, patching rewrites
+// the history of local, and entries stored from the first patched leaf on are
+// dropped.
+func ApplySync(local *MerkleHashTree, remote RemoteNodeFetcher, head TreeHead, plan []Range) error {
+	fetched := make(map[uint64][sha256.Size]byte)
+	for _, r := range plan {
+		if r.Start >= r.End || r.End > head.TreeSize {
+			return fmt.Errorf("%w: range [%d, %d) for size %d", ErrInvalidRange, r.Start, r.End, head.TreeSize)
+		}
+		for i := r.Start; i < r.End; i++ {
+			hash, err := remote.Node(0, i)
+			if err != nil {
+				return err
+			}
+			fetched[i] = hash
+		}
+	}
+
+	defer local.writeLock()()
+	if local.sealed {
+		return ErrSealed
+	}
+
+	leaves := make([][sha256.Size]byte, head.TreeSize)
+	copy(leaves, local.tree[0])
+	first := uint64(len(local.tree[0]))
+	if head.TreeSize < first {
+		first = head.TreeSize
+	}
+	for i := range leaves {
+		hash, ok := fetched[uint64(i)]
+		if ok {
+			if leaves[i] != hash && uint64(i) < first {
+				first = uint64(i)
+			}
+			leaves[i] = hash
+		} else if uint64(i) >= uint64(len(local.tree[0])) {
+			return fmt.Errorf("%w: plan does not cover leaf %d", ErrInvalidRange, i)
+		}
+	}
+	if local.sorted {
+		if i := unsortedAt(nil, leaves); i >= 0 {
+			return fmt.Errorf("%w: at index %d", ErrUnsortedLeaves, i)
+		}
+	}
+	if root, _ := MTHRangeFromLeafHashes(leaves, 0, head.TreeSize); root != head.RootHash {
+		return fmt.Errorf("%w: synced tree of size %d", ErrRootMismatch, head.TreeSize)
+	}
+
+	if err := local.persist(first, leaves[first:], head.TreeSize); err != nil {
+		return err
+	}
+
+	if uint64(len(local.entries)) > first {
+		local.entries = local.entries[:first]
+	}
+	local.tree = make([][][sha256.Size]byte, levels(len(leaves)))
+	local.tree[0] = leaves
+	local.rewriteHistory(first + 1)
+	local.rewrite()
+	return nil
+}
@@ -0,0 +1,110 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingFetcher counts the nodes fetched from a remote tree
+type countingFetcher struct {
+	tree    *MerkleHashTree
+	fetched int
+}
+
+func (f *countingFetcher) Node(level, index uint64) ([32]byte, error) {
+	f.fetched++
+	return f.tree.Node(level, index)
+}
+
+func corrupted(D [][]byte, indexes ...int) [][]byte {
+	local := append([][]byte(nil), D...)
+	for _, i := range indexes {
+		local[i] = []byte("corrupt")
+	}
+	return local
+}
+
+func TestPlanSync(t *testing.T) {
+	D := makeEntries(37)
+	remote := &countingFetcher{tree: New(D)}
+	head := remote.tree.TreeHead()
+
+	for _, c := range []struct {
+		name  string
+		local [][]byte
+		plan  []Range
+	}{
+		{"in sync", D, nil},
+		{"one leaf", corrupted(D, 9), []Range{{9, 10}}},
+		{"adjacent leaves", corrupted(D, 15, 16, 17), []Range{{15, 18}}},
+		{"scattered leaves", corrupted(D, 0, 20, 36), []Range{{0, 1}, {20, 21}, {36, 37}}},
+		{"missing leaves", D[:30], []Range{{30, 37}}},
+		{"missing and corrupt", corrupted(D[:33], 2, 32), []Range{{2, 3}, {32, 37}}},
+		{"extra leaves", append(append([][]byte(nil), D...), []byte("extra")), nil},
+		{"empty", nil, []Range{{0, 37}}},
+	} {
+		local := New(c.local)
+		remote.fetched = 0
+		plan, err := PlanSync(local, remote, head.TreeSize)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.plan, plan, c.name)
+
+		assert.NoError(t, ApplySync(local, remote, head, plan), c.name)
+		assert.Equal(t, head, local.TreeHead(), c.name)
+		for i := range D {
+			leaf, err := local.LeafHash(uint64(i))
+			assert.NoError(t, err)
+			assert.Equal(t, leafHash(D[i]), leaf, c.name)
+		}
+	}
+
+	// A single damaged leaf only fetches the nodes along its path and their siblings
+	remote.fetched = 0
+	_, err := PlanSync(New(corrupted(D, 9)), remote, head.TreeSize)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, remote.fetched, 2*7)
+}
+
+func TestApplySyncVerifiesRoot(t *testing.T) {
+	D := makeEntries(12)
+	remote := New(D)
+	local := New(corrupted(D, 3, 7))
+	before := local.TreeHead()
+
+	plan, err := PlanSync(local, remote, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, []Range{{3, 4}, {7, 8}}, plan)
+
+	// An incomplete plan or a wrong head leaves the local tree untouched
+	assert.ErrorIs(t, ApplySync(local, remote, remote.TreeHead(), plan[:1]), ErrRootMismatch)
+	assert.ErrorIs(t, ApplySync(local, remote, TreeHead{TreeSize: 12}, plan), ErrRootMismatch)
+	assert.ErrorIs(t, ApplySync(local, remote, TreeHead{TreeSize: 13}, []Range{{0, 13}}), ErrIndexOutOfRange)
+	assert.ErrorIs(t, ApplySync(local, remote, remote.TreeHead(), []Range{{5, 13}}), ErrInvalidRange)
+	assert.ErrorIs(t, ApplySync(New(D[:5]), remote, remote.TreeHead(), nil), ErrInvalidRange)
+	assert.Equal(t, before, local.TreeHead())
+
+	assert.NoError(t, ApplySync(local, remote, remote.TreeHead(), plan))
+	assert.Equal(t, remote.TreeHead(), local.TreeHead())
+
+	// The remote has no tree of the planned size
+	_, err = PlanSync(New(makeEntries(13)), remote, 13)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestMerkleHashTreeNode(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	l, err := tree.Node(2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[4:]), l)
+	j, err := tree.Node(0, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, leafHash(D[6]), j)
+
+	// [6, 7) is leaf 6, not a node at level 1
+	_, err = tree.Node(1, 3)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = tree.Node(3, 1)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
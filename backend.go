@@ -0,0 +1,148 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"runtime"
+	"sync"
+)
+
+// HashBackend computes the SHA-256 digests of many independent inputs. It is used to
+// hash leaves in batches, which lets multi-buffer or SIMD SHA-256 implementations
+// hash several leaves at once. Implementations must be safe for concurrent use.
+// Building with the sha256simd tag adds SIMDBackend and MultiBufferBackend, over
+// github.com/minio/sha256-simd.
+type HashBackend interface {
+	HashMany(inputs [][]byte) [][sha256.Size]byte
+}
+
+// DefaultBackend hashes inputs one after another with crypto/sha256
+var DefaultBackend HashBackend = serialBackend{}
+
+type serialBackend struct{}
+
+func (serialBackend) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, len(inputs))
+	for i, in := range inputs {
+		hashes[i] = sha256.Sum256(in)
+	}
+	return hashes
+}
+
+// minParallelBatch is the smallest batch a ParallelBackend splits across workers
+const minParallelBatch = 1024
+
+// parallelBackend splits batches across workers, hashing every range with inner
+type parallelBackend struct {
+	workers int
+	inner   HashBackend
+}
+
+// ParallelBackend returns a backend hashing large batches on the given number of
+// goroutines, or on GOMAXPROCS goroutines when workers is not positive. Batches
+// smaller than minParallelBatch are hashed serially. It is the backend WithParallelism
+// hashes leaves with, see there for using both.
+func ParallelBackend(workers int) HashBackend {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return parallelBackend{workers: workers, inner: DefaultBackend}
+}
+
+func (b parallelBackend) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	if len(inputs) < minParallelBatch || b.workers <= 1 {
+		return b.inner.HashMany(inputs)
+	}
+
+	hashes := make([][sha256.Size]byte, len(inputs))
+	parallelFor(len(inputs), b.workers, func(start, end int) {
+		copy(hashes[start:end], b.inner.HashMany(inputs[start:end]))
+	})
+	return hashes
+}
+
+// parallelFor calls fn over consecutive ranges splitting [0, n) across workers
+// goroutines, and returns once every call returned. Fewer than minParallelBatch
+// items are passed to a single call on the calling goroutine.
+func parallelFor(n, workers int, fn func(start, end int)) {
+	if n < minParallelBatch || workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// hashLeaves returns the leaf hashes of d appended at index first, computed with the backend of the tree
+func (m *MerkleHashTree) hashLeaves(first uint64, d [][]byte) [][sha256.Size]byte {
+	backend := m.backend
+	if m.hasher != nil {
+		backend = *m.hasher
+	} else if backend == nil {
+		backend = DefaultBackend
+	}
+	if _, parallel := backend.(parallelBackend); !parallel && m.parallelism > 1 {
+		backend = parallelBackend{workers: m.parallelism, inner: backend}
+	}
+
+	leafPrefix := []byte{LeafPrefix}
+	if m.hasher != nil {
+		leafPrefix = m.hasher.prefix(LeafPrefix)
+	}
+	prefix := len(leafPrefix)
+	if m.indexBound {
+		prefix += 8
+	}
+	size := 0
+	for _, e := range d {
+		size += len(e) + prefix
+	}
+	buf := make([]byte, 0, size)
+	inputs := make([][]byte, len(d))
+	for i, e := range d {
+		start := len(buf)
+		buf = append(buf, leafPrefix...)
+		if m.indexBound {
+			buf = binary.BigEndian.AppendUint64(buf, first+uint64(i))
+		}
+		buf = append(buf, e...)
+		inputs[i] = buf[start:len(buf):len(buf)]
+	}
+	return backend.HashMany(inputs)
+}
+
+// leafHasher returns a function giving the leaf hashes of d appended at index first,
+// or the error of the admission hook rejecting them. Unless leaves are index bound
+// their hashes do not depend on first, and are computed right away so that appends
+// hash outside of the tree lock.
+func (m *MerkleHashTree) leafHasher(d [][]byte) func(first uint64) ([][sha256.Size]byte, error) {
+	if m.indexBound {
+		return func(first uint64) ([][sha256.Size]byte, error) {
+			if err := m.admit(first, d); err != nil {
+				return nil, err
+			}
+			return m.hashLeaves(first, d), nil
+		}
+	}
+
+	leaves := m.hashLeaves(0, d)
+	return func(first uint64) ([][sha256.Size]byte, error) {
+		if err := m.admit(first, d); err != nil {
+			return nil, err
+		}
+		return leaves, nil
+	}
+}
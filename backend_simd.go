@@ -0,0 +1,27 @@
+//go:build sha256simd
+
+package merkletree
+
+import (
+	"crypto/sha256"
+
+	simd "github.com/minio/sha256-simd"
+)
+
+// SIMDBackend returns a backend hashing inputs one after another with
+// github.com/minio/sha256-simd, which uses the SHA instructions of x86 and ARM CPUs
+// that have them. It is built with the sha256simd tag only, so that the package
+// has no such dependency by default.
+func SIMDBackend() HashBackend {
+	return simdBackend{}
+}
+
+type simdBackend struct{}
+
+func (simdBackend) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, len(inputs))
+	for i, in := range inputs {
+		hashes[i] = simd.Sum256(in)
+	}
+	return hashes
+}
@@ -0,0 +1,74 @@
+//go:build sha256simd && amd64
+
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"github.com/klauspost/cpuid/v2"
+	simd "github.com/minio/sha256-simd"
+)
+
+// multiBufferLanes is the number of inputs an Avx512Server hashes at once
+const multiBufferLanes = 16
+
+var (
+	multiBufferMu     sync.Mutex
+	multiBufferOnce   sync.Once
+	multiBufferServer *simd.Avx512Server
+)
+
+// MultiBufferBackend returns a backend hashing sixteen inputs at once with the
+// AVX-512 multi-buffer SHA-256 of github.com/minio/sha256-simd, or SIMDBackend on
+// CPUs without AVX-512. It is built with the sha256simd tag only.
+//
+// Every input round-trips through the goroutine of the Avx512Server, which costs
+// more than hashing a small leaf: measure with the HashLeaves64K benchmarks before
+// choosing it over SIMDBackend.
+func MultiBufferBackend() HashBackend {
+	if !cpuid.CPU.Supports(cpuid.AVX512F, cpuid.AVX512DQ, cpuid.AVX512BW, cpuid.AVX512VL) {
+		return SIMDBackend()
+	}
+	multiBufferOnce.Do(func() {
+		multiBufferServer = simd.NewAvx512Server()
+	})
+	return multiBufferBackend{}
+}
+
+// multiBufferBackend feeds every lane of the Avx512Server from a goroutine of its
+// own. The server runs for the life of the process, so it is shared by every
+// backend, and its lanes by one batch at a time.
+type multiBufferBackend struct{}
+
+func (multiBufferBackend) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	multiBufferMu.Lock()
+	defer multiBufferMu.Unlock()
+
+	hashes := make([][sha256.Size]byte, len(inputs))
+	var wg sync.WaitGroup
+	for lane := 0; lane < multiBufferLanes && lane < len(inputs); lane++ {
+		wg.Add(1)
+		go func(lane int) {
+			defer wg.Done()
+			// The server picks the lane from the low bits of the uid, and keeps a
+			// state for uid 0 across batches, so lane 0 uses uid 16
+			uid := uint64(multiBufferLanes + lane)
+			for i := lane; i < len(inputs); i += multiBufferLanes {
+				hashes[i] = multiBufferServer.Sum(uid, sha256Padded(inputs[i]))
+			}
+		}(lane)
+	}
+	wg.Wait()
+	return hashes
+}
+
+// sha256Padded returns in followed by the SHA-256 padding, a whole number of blocks
+func sha256Padded(in []byte) []byte {
+	padded := make([]byte, (len(in)+1+8+63)/64*64)
+	copy(padded, in)
+	padded[len(in)] = 0x80
+	binary.BigEndian.PutUint64(padded[len(padded)-8:], uint64(len(in))<<3)
+	return padded
+}
@@ -0,0 +1,9 @@
+//go:build sha256simd && !amd64
+
+package merkletree
+
+// MultiBufferBackend returns SIMDBackend, the multi-buffer SHA-256 of
+// github.com/minio/sha256-simd being available on amd64 only
+func MultiBufferBackend() HashBackend {
+	return SIMDBackend()
+}
@@ -0,0 +1,69 @@
+//go:build sha256simd
+
+package merkletree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSIMDBackendsMatchDefault(t *testing.T) {
+	// Inputs of every length around the block and padding boundaries
+	inputs := make([][]byte, 300)
+	for i := range inputs {
+		inputs[i] = bytes.Repeat([]byte{byte(i)}, i)
+	}
+
+	for _, backend := range []HashBackend{SIMDBackend(), MultiBufferBackend()} {
+		assert.Equal(t, DefaultBackend.HashMany(inputs), backend.HashMany(inputs))
+		assert.Equal(t, DefaultBackend.HashMany(inputs[:5]), backend.HashMany(inputs[:5]))
+		assert.Empty(t, backend.HashMany(nil))
+
+		for _, n := range []int{0, 1, 15, 16, 17, 1000} {
+			D := makeEntries(n)
+			tree := New(D, WithHashBackend(backend))
+			assert.Equal(t, New(D).tree, tree.tree, "n=%d", n)
+			tree.Append(D...)
+			assert.Equal(t, MTH(append(D, D...)), tree.MerkleRoot(), "n=%d", n)
+		}
+	}
+}
+
+func benchmarkHashLeavesOf(b *testing.B, backend HashBackend, size int) {
+	D := make([][]byte, 1<<16)
+	for i := range D {
+		D[i] = bytes.Repeat([]byte{byte(i)}, size)
+	}
+	tree := New(nil, WithHashBackend(backend))
+	b.SetBytes(int64(len(D) * size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.hashLeaves(0, D)
+	}
+}
+
+func BenchmarkHashLeaves64KDefault(b *testing.B) {
+	benchmarkHashLeavesOf(b, DefaultBackend, 32)
+}
+
+func BenchmarkHashLeaves64KSIMD(b *testing.B) {
+	benchmarkHashLeavesOf(b, SIMDBackend(), 32)
+}
+
+func BenchmarkHashLeaves64KMultiBuffer(b *testing.B) {
+	benchmarkHashLeavesOf(b, MultiBufferBackend(), 32)
+}
+
+func BenchmarkHashLeaves64KOf4KiBDefault(b *testing.B) {
+	benchmarkHashLeavesOf(b, DefaultBackend, 4096)
+}
+
+func BenchmarkHashLeaves64KOf4KiBSIMD(b *testing.B) {
+	benchmarkHashLeavesOf(b, SIMDBackend(), 4096)
+}
+
+func BenchmarkHashLeaves64KOf4KiBMultiBuffer(b *testing.B) {
+	benchmarkHashLeavesOf(b, MultiBufferBackend(), 4096)
+}
@@ -0,0 +1,114 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelBackendMatchesDefault(t *testing.T) {
+	for _, n := range []int{0, 1, 7, minParallelBatch, 3*minParallelBatch + 5} {
+		D := makeEntries(n)
+		serial := New(D)
+		parallel := New(D, WithHashBackend(ParallelBackend(4)))
+		assert.Equal(t, serial.tree, parallel.tree, "n=%d", n)
+
+		serial.Append(D...)
+		parallel.Append(D...)
+		assert.Equal(t, serial.MerkleRoot(), parallel.MerkleRoot(), "n=%d", n)
+	}
+}
+
+func TestWithParallelismMatchesSerial(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	h := newSHA512_256Hasher(t)
+	for _, n := range []int{0, 1, 7, minParallelBatch - 1, 2 * minParallelBatch, 5*minParallelBatch + 3} {
+		D := makeEntries(n)
+		for _, opts := range [][]Option{nil, {WithHasher(h)}, {WithIndexBoundLeaves()}} {
+			serial := New(D, opts...)
+			parallel := New(D, append(opts, WithParallelism(4))...)
+			assert.Equal(t, serial.tree, parallel.tree, "n=%d", n)
+
+			// Appends extend the right edge in parallel once it is large enough
+			serial.Append(D...)
+			parallel.Append(D...)
+			assert.Equal(t, serial.tree, parallel.tree, "n=%d", n)
+			for _, d := range D[:3*len(D)/4] {
+				parallel.Append(d)
+			}
+			serial.Append(D[:3*len(D)/4]...)
+			assert.Equal(t, serial.tree, parallel.tree, "n=%d", n)
+		}
+	}
+	assert.Equal(t, New(nil, WithParallelism(0)).parallelism, runtime.GOMAXPROCS(0))
+
+	// Every worker is done when the tree is returned, give them time to exit
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
+
+func benchmarkHashLeaves(b *testing.B, backend HashBackend) {
+	D := makeEntries(1 << 16)
+	tree := New(nil, WithHashBackend(backend))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.hashLeaves(0, D)
+	}
+}
+
+func BenchmarkHashLeavesDefault(b *testing.B) {
+	benchmarkHashLeaves(b, DefaultBackend)
+}
+
+func BenchmarkHashLeavesParallel(b *testing.B) {
+	benchmarkHashLeaves(b, ParallelBackend(0))
+}
+
+func benchmarkNew(b *testing.B, opts ...Option) {
+	D := makeEntries(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(D, opts...)
+	}
+}
+
+func BenchmarkNew1MSerial(b *testing.B) {
+	benchmarkNew(b)
+}
+
+func BenchmarkNew1MParallel(b *testing.B) {
+	benchmarkNew(b, WithParallelism(0))
+}
+
+// countingBackend counts the HashMany calls of a backend
+type countingBackend struct {
+	calls *int32
+}
+
+func (b countingBackend) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	atomic.AddInt32(b.calls, 1)
+	return DefaultBackend.HashMany(inputs)
+}
+
+func TestWithParallelismAndParallelBackend(t *testing.T) {
+	D := makeEntries(4 * minParallelBatch)
+
+	// WithParallelism splits the batch across its workers over the tree backend
+	calls := int32(0)
+	New(D, WithHashBackend(countingBackend{&calls}), WithParallelism(4))
+	assert.Equal(t, int32(4), calls)
+
+	// A ParallelBackend is not split again
+	backend := ParallelBackend(2).(parallelBackend)
+	calls = 0
+	backend.inner = countingBackend{&calls}
+	tree := New(D, WithHashBackend(backend), WithParallelism(8))
+	assert.Equal(t, int32(2), calls)
+	assert.Equal(t, New(D).tree, tree.tree)
+}
@@ -0,0 +1,320 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrInvalidPartialTree is returned when a Bitcoin partial merkle tree is malformed
+var ErrInvalidPartialTree = errors.New("merkletree: invalid bitcoin partial merkle tree")
+
+// maxBitcoinTransactions bounds the number of transactions of a partial merkle tree
+// to the most a block can hold, as checked by Bitcoin Core.
+const maxBitcoinTransactions = 4000000 / 240
+
+// BitcoinPartialTree is the partial merkle tree of a Bitcoin merkleblock message.
+// It commits to the transactions of a block, of which some are matched, with the
+// minimal set of hashes needed to recompute the merkle root. Hashes are in internal
+// byte order, the reverse of the hex txids shown by block explorers.
+type BitcoinPartialTree struct {
+	TotalTransactions uint32
+	Hashes            [][sha256.Size]byte
+	// Flags holds the depth-first traversal bits, least significant bit first
+	Flags []byte
+}
+
+// MultihashDoubleSHA256 is the multicodec code of dbl-sha2-256
+const MultihashDoubleSHA256 = 0x56
+
+// BitcoinHasher hashes with the double SHA-256 of Bitcoin and without the prefixes
+// of RFC 6962, so that the leaf hash of a serialized transaction is its txid and
+// nodes are hashed as in the merkle tree of a block. Trees created with it from
+// the txids of a block, with NewFromLeafHashes, encode the partial merkle trees of
+// merkleblock messages with BitcoinPartialTree.
+//
+// Leaves and nodes of trees over BitcoinHasher are not domain separated, so their
+// proofs should only be trusted for leaves known to be transactions.
+var BitcoinHasher = Hasher{name: "dbl-sha2-256", code: MultihashDoubleSHA256, new: newDoubleSHA256, unprefixed: true}
+
+// doubleSHA256 is a hash.Hash computing SHA-256 of the SHA-256 of its input
+type doubleSHA256 struct {
+	hash.Hash
+}
+
+func newDoubleSHA256() hash.Hash {
+	return doubleSHA256{sha256.New()}
+}
+
+func (d doubleSHA256) Sum(b []byte) []byte {
+	h := sha256.Sum256(d.Hash.Sum(nil))
+	return append(b, h[:]...)
+}
+
+// bitcoinHash returns the double SHA-256 of the concatenation of left and right
+func bitcoinHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	return BitcoinHasher.NodeHash(left, right)
+}
+
+// bitcoinWidth returns the number of nodes at height h of a tree of n transactions
+func bitcoinWidth(n uint64, h uint) uint64 {
+	return (n + (1 << h) - 1) >> h
+}
+
+func bitcoinHeight(n uint64) uint {
+	h := uint(0)
+	for bitcoinWidth(n, h) > 1 {
+		h++
+	}
+	return h
+}
+
+// bitcoinNode returns the hash of the node at height h and position pos. Unlike
+// RFC 6962, a node without a right sibling is hashed with itself.
+func bitcoinNode(txids [][sha256.Size]byte, h uint, pos uint64) [sha256.Size]byte {
+	if h == 0 {
+		return txids[pos]
+	}
+	left := bitcoinNode(txids, h-1, pos*2)
+	right := left
+	if pos*2+1 < bitcoinWidth(uint64(len(txids)), h-1) {
+		right = bitcoinNode(txids, h-1, pos*2+1)
+	}
+	return bitcoinHash(left, right)
+}
+
+// BitcoinMerkleRoot returns the merkle root of a block with the given transaction ids
+func BitcoinMerkleRoot(txids [][sha256.Size]byte) [sha256.Size]byte {
+	if len(txids) == 0 {
+		return [sha256.Size]byte{}
+	}
+	return bitcoinNode(txids, bitcoinHeight(uint64(len(txids))), 0)
+}
+
+// NewBitcoinPartialTree returns the partial merkle tree of the block with the given
+// transaction ids in which the transactions at the matched indexes are matched.
+func NewBitcoinPartialTree(txids [][sha256.Size]byte, matched []uint64) (BitcoinPartialTree, error) {
+	n := uint64(len(txids))
+	if n == 0 || n > maxBitcoinTransactions {
+		return BitcoinPartialTree{}, fmt.Errorf("%w: %d transactions", ErrInvalidPartialTree, n)
+	}
+
+	match := make([]bool, n)
+	for _, i := range matched {
+		if i >= n {
+			return BitcoinPartialTree{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, n)
+		}
+		match[i] = true
+	}
+
+	p := BitcoinPartialTree{TotalTransactions: uint32(n)}
+	var bits []bool
+	var build func(h uint, pos uint64)
+	build = func(h uint, pos uint64) {
+		parentOfMatch := false
+		for i := pos << h; i < (pos+1)<<h && i < n; i++ {
+			parentOfMatch = parentOfMatch || match[i]
+		}
+		bits = append(bits, parentOfMatch)
+
+		if h == 0 || !parentOfMatch {
+			p.Hashes = append(p.Hashes, bitcoinNode(txids, h, pos))
+			return
+		}
+		build(h-1, pos*2)
+		if pos*2+1 < bitcoinWidth(n, h-1) {
+			build(h-1, pos*2+1)
+		}
+	}
+	build(bitcoinHeight(n), 0)
+
+	p.Flags = make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			p.Flags[i/8] |= 1 << (i % 8)
+		}
+	}
+	return p, nil
+}
+
+// BitcoinPartialTree returns the partial merkle tree of the block whose txids are
+// the leaves of the tree, in which the transactions at the matched indexes are
+// matched. A tree not created WithHasher(BitcoinHasher) fails with
+// ErrUnsupportedHash. Bitcoin hashes the last node of a level of odd width with
+// itself where RFC 6962 carries it up, so the root the partial tree commits to,
+// the BitcoinMerkleRoot of the leaves, is the MerkleRoot of the tree only when its
+// size is a power of two.
+func (m *MerkleHashTree) BitcoinPartialTree(matched []uint64) (BitcoinPartialTree, error) {
+	defer m.readLock()()
+
+	if name := m.Hasher().Name(); name != BitcoinHasher.name {
+		return BitcoinPartialTree{}, fmt.Errorf("%w: partial merkle tree of a tree over %s", ErrUnsupportedHash, name)
+	}
+	return NewBitcoinPartialTree(m.tree[0], matched)
+}
+
+// Extract recomputes the merkle root of the partial tree and returns it with the
+// indexes of the matched transactions. Trees with unused hashes or flags, and trees
+// in which a node has two identical children, are rejected: both would let different
+// encodings, or different transaction lists, commit to the same root.
+func (p BitcoinPartialTree) Extract() ([sha256.Size]byte, []uint64, error) {
+	var root [sha256.Size]byte
+	n := uint64(p.TotalTransactions)
+	if n == 0 || n > maxBitcoinTransactions {
+		return root, nil, fmt.Errorf("%w: %d transactions", ErrInvalidPartialTree, n)
+	}
+	if uint64(len(p.Hashes)) > n {
+		return root, nil, fmt.Errorf("%w: more hashes than transactions", ErrInvalidPartialTree)
+	}
+	if len(p.Flags)*8 < len(p.Hashes) {
+		return root, nil, fmt.Errorf("%w: fewer flags than hashes", ErrInvalidPartialTree)
+	}
+
+	var bitsUsed, hashesUsed int
+	var matched []uint64
+	var extract func(h uint, pos uint64) ([sha256.Size]byte, error)
+	extract = func(h uint, pos uint64) ([sha256.Size]byte, error) {
+		if bitsUsed >= len(p.Flags)*8 {
+			return [sha256.Size]byte{}, fmt.Errorf("%w: ran out of flags", ErrInvalidPartialTree)
+		}
+		parentOfMatch := p.Flags[bitsUsed/8]&(1<<(bitsUsed%8)) != 0
+		bitsUsed++
+
+		if h == 0 || !parentOfMatch {
+			if hashesUsed >= len(p.Hashes) {
+				return [sha256.Size]byte{}, fmt.Errorf("%w: ran out of hashes", ErrInvalidPartialTree)
+			}
+			hash := p.Hashes[hashesUsed]
+			hashesUsed++
+			if h == 0 && parentOfMatch {
+				matched = append(matched, pos)
+			}
+			return hash, nil
+		}
+
+		left, err := extract(h-1, pos*2)
+		if err != nil {
+			return left, err
+		}
+		right := left
+		if pos*2+1 < bitcoinWidth(n, h-1) {
+			if right, err = extract(h-1, pos*2+1); err != nil {
+				return right, err
+			}
+			// CVE-2012-2459: identical siblings make the tree ambiguous
+			if right == left {
+				return [sha256.Size]byte{}, fmt.Errorf("%w: identical siblings", ErrInvalidPartialTree)
+			}
+		}
+		return bitcoinHash(left, right), nil
+	}
+
+	root, err := extract(bitcoinHeight(n), 0)
+	if err != nil {
+		return [sha256.Size]byte{}, nil, err
+	}
+	if (bitsUsed+7)/8 != len(p.Flags) {
+		return [sha256.Size]byte{}, nil, fmt.Errorf("%w: unused flags", ErrInvalidPartialTree)
+	}
+	if hashesUsed != len(p.Hashes) {
+		return [sha256.Size]byte{}, nil, fmt.Errorf("%w: unused hashes", ErrInvalidPartialTree)
+	}
+	return root, matched, nil
+}
+
+// VerifyBitcoinPartialTree checks that p commits to the block merkle root and
+// returns the indexes of the matched transactions.
+func VerifyBitcoinPartialTree(root [sha256.Size]byte, p BitcoinPartialTree) ([]uint64, error) {
+	extracted, matched, err := p.Extract()
+	if err != nil {
+		return nil, err
+	}
+	if extracted != root {
+		return nil, ErrRootMismatch
+	}
+	return matched, nil
+}
+
+// MarshalBinary encodes p as in a merkleblock message: the little endian
+// transaction count, then the hashes and the flag bytes, each preceded by their
+// count as a Bitcoin CompactSize.
+func (p BitcoinPartialTree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, p.TotalTransactions)
+	writeCompactSize(&buf, uint64(len(p.Hashes)))
+	for _, h := range p.Hashes {
+		buf.Write(h[:])
+	}
+	writeCompactSize(&buf, uint64(len(p.Flags)))
+	buf.Write(p.Flags)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a partial merkle tree encoded by MarshalBinary
+func (p *BitcoinPartialTree) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var decoded BitcoinPartialTree
+	if err := binary.Read(r, binary.LittleEndian, &decoded.TotalTransactions); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPartialTree, err)
+	}
+
+	count, err := readCompactSize(r)
+	if err != nil || count > uint64(r.Len())/sha256.Size {
+		return fmt.Errorf("%w: bad hash count", ErrInvalidPartialTree)
+	}
+	decoded.Hashes = make([][sha256.Size]byte, count)
+	for i := range decoded.Hashes {
+		r.Read(decoded.Hashes[i][:])
+	}
+
+	count, err = readCompactSize(r)
+	if err != nil || count != uint64(r.Len()) {
+		return fmt.Errorf("%w: bad flag count", ErrInvalidPartialTree)
+	}
+	decoded.Flags = make([]byte, count)
+	r.Read(decoded.Flags)
+
+	*p = decoded
+	return nil
+}
+
+func writeCompactSize(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xfd)
+		binary.Write(buf, binary.LittleEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xfe)
+		binary.Write(buf, binary.LittleEndian, uint32(n))
+	default:
+		buf.WriteByte(0xff)
+		binary.Write(buf, binary.LittleEndian, n)
+	}
+}
+
+func readCompactSize(r *bytes.Reader) (uint64, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch prefix {
+	case 0xfd:
+		var n uint16
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return uint64(n), err
+	case 0xfe:
+		var n uint32
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return uint64(n), err
+	case 0xff:
+		var n uint64
+		err = binary.Read(r, binary.LittleEndian, &n)
+		return n, err
+	}
+	return uint64(prefix), nil
+}
@@ -0,0 +1,151 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// txid decodes a transaction id displayed in RPC byte order into internal byte order
+func txid(t *testing.T, s string) [sha256.Size]byte {
+	var h [sha256.Size]byte
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+	for i := range b {
+		h[i] = b[len(b)-1-i]
+	}
+	return h
+}
+
+// Transactions of mainnet block 100000
+func block100000(t *testing.T) ([][sha256.Size]byte, [sha256.Size]byte) {
+	txids := [][sha256.Size]byte{
+		txid(t, "8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		txid(t, "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		txid(t, "6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		txid(t, "e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	}
+	return txids, txid(t, "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766")
+}
+
+func TestBitcoinPartialTreeMainnetBlock(t *testing.T) {
+	txids, root := block100000(t)
+	assert.Equal(t, root, BitcoinMerkleRoot(txids))
+
+	p, err := NewBitcoinPartialTree(txids, []uint64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4), p.TotalTransactions)
+	assert.Equal(t, [][sha256.Size]byte{txids[0], txids[1], bitcoinHash(txids[2], txids[3])}, p.Hashes)
+	assert.Equal(t, []byte{0x0b}, p.Flags)
+
+	matched, err := VerifyBitcoinPartialTree(root, p)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1}, matched)
+
+	encoded, err := p.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, encoded, 4+1+3*32+1+1)
+	var decoded BitcoinPartialTree
+	assert.NoError(t, decoded.UnmarshalBinary(encoded))
+	assert.Equal(t, p, decoded)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(encoded[:len(encoded)-1]), ErrInvalidPartialTree)
+}
+
+func TestBitcoinPartialTreeRoundTrip(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		txids := make([][sha256.Size]byte, n)
+		for i := range txids {
+			txids[i] = sha256.Sum256([]byte{byte(i)})
+		}
+		root := BitcoinMerkleRoot(txids)
+
+		for _, matched := range [][]uint64{nil, {0}, {uint64(n - 1)}, {0, uint64(n / 2), uint64(n - 1)}} {
+			p, err := NewBitcoinPartialTree(txids, matched)
+			assert.NoError(t, err)
+			extracted, err := VerifyBitcoinPartialTree(root, p)
+			assert.NoError(t, err, "n=%d matched=%v", n, matched)
+
+			expected := make([]uint64, 0)
+			for i := 0; i < n; i++ {
+				for _, m := range matched {
+					if m == uint64(i) {
+						expected = append(expected, m)
+						break
+					}
+				}
+			}
+			assert.ElementsMatch(t, expected, extracted, "n=%d", n)
+		}
+	}
+}
+
+func TestBitcoinPartialTreeRejectsMalleated(t *testing.T) {
+	txids, root := block100000(t)
+	p, err := NewBitcoinPartialTree(txids, []uint64{1})
+	assert.NoError(t, err)
+
+	extraHash := p
+	extraHash.Hashes = append(append([][sha256.Size]byte{}, p.Hashes...), txids[3])
+	_, err = VerifyBitcoinPartialTree(root, extraHash)
+	assert.ErrorIs(t, err, ErrInvalidPartialTree)
+
+	extraFlags := p
+	extraFlags.Flags = []byte{0x0b, 0x00}
+	_, err = VerifyBitcoinPartialTree(root, extraFlags)
+	assert.ErrorIs(t, err, ErrInvalidPartialTree)
+
+	missingHash := p
+	missingHash.Hashes = p.Hashes[:2]
+	_, err = VerifyBitcoinPartialTree(root, missingHash)
+	assert.ErrorIs(t, err, ErrInvalidPartialTree)
+
+	// Duplicating the last transaction of an odd block keeps the merkle root,
+	// which a partial tree must not be able to exploit.
+	odd := txids[:3]
+	duplicated := append(append([][sha256.Size]byte{}, odd...), odd[2])
+	assert.Equal(t, BitcoinMerkleRoot(odd), BitcoinMerkleRoot(duplicated))
+	p, err = NewBitcoinPartialTree(duplicated, []uint64{3})
+	assert.NoError(t, err)
+	_, err = VerifyBitcoinPartialTree(BitcoinMerkleRoot(odd), p)
+	assert.ErrorIs(t, err, ErrInvalidPartialTree)
+
+	_, err = VerifyBitcoinPartialTree(txids[0], extraFlags)
+	assert.Error(t, err)
+	p, _ = NewBitcoinPartialTree(txids, []uint64{1})
+	_, err = VerifyBitcoinPartialTree(txids[0], p)
+	assert.ErrorIs(t, err, ErrRootMismatch)
+}
+
+func TestBitcoinHasherPartialTree(t *testing.T) {
+	txids, root := block100000(t)
+	tree := NewFromLeafHashes(txids, WithHasher(BitcoinHasher))
+	assert.Equal(t, root, tree.MerkleRoot())
+	assert.Equal(t, bitcoinHash(txids[0], txids[1]), BitcoinHasher.NodeHash(txids[0], txids[1]))
+
+	p, err := tree.BitcoinPartialTree([]uint64{1})
+	assert.NoError(t, err)
+	want, err := NewBitcoinPartialTree(txids, []uint64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, want, p)
+	matched, err := VerifyBitcoinPartialTree(root, p)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1}, matched)
+
+	// The leaf hash of a transaction is its double SHA-256, without a prefix
+	tx := []byte("transaction")
+	once := sha256.Sum256(tx)
+	assert.Equal(t, sha256.Sum256(once[:]), BitcoinHasher.LeafHash(tx))
+	assert.Equal(t, BitcoinHasher.LeafHash(tx), New([][]byte{tx}, WithHasher(BitcoinHasher)).MerkleRoot())
+
+	// Odd levels are hashed as in Bitcoin, whatever the root of the tree
+	odd := NewFromLeafHashes(txids[:3], WithHasher(BitcoinHasher))
+	p, err = odd.BitcoinPartialTree([]uint64{2})
+	assert.NoError(t, err)
+	_, err = VerifyBitcoinPartialTree(BitcoinMerkleRoot(txids[:3]), p)
+	assert.NoError(t, err)
+
+	_, err = NewFromLeafHashes(txids).BitcoinPartialTree([]uint64{1})
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
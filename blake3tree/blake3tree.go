@@ -0,0 +1,308 @@
+// Package blake3tree builds merkle trees over 1 KiB chunks of a file with the tree
+// mode of BLAKE3, so that the root of a tree is the official BLAKE3 digest of the
+// file, and reads and writes the bao outboard encoding of such trees for verified
+// streaming of chunks and slices.
+//
+// The tree has the shape of an RFC 6962 tree over the chunks: the left subtree of a
+// node covers the largest power of two of chunks smaller than the node covers. The
+// nodes are BLAKE3 chaining values instead of SHA-256 hashes. A chunk is
+// compressed with its index as the counter, a parent from the chaining values of
+// its children, and the root with the root flag. The BLAKE3 compression function
+// comes from lukechampine.com/blake3, which only this package depends on.
+//
+// The bao outboard encoding of a tree is the little endian uint64 length of the
+// file, followed by the children of every parent in pre-order, each as the 32-byte
+// chaining values of its left and right child.
+package blake3tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/viveksyngh/merkletree"
+	"lukechampine.com/blake3/guts"
+)
+
+// ChunkSize is the size of the chunks of a file, of which only the last may be
+// shorter
+const ChunkSize = guts.ChunkSize
+
+// Errors returned when reading outboard encodings and verifying chunks
+var (
+	ErrInvalidOutboard = errors.New("blake3tree: invalid bao outboard encoding")
+	ErrChunkMismatch   = errors.New("blake3tree: chunk does not match the tree")
+)
+
+// Tree is the BLAKE3 tree of a file: the chaining values of its chunks and its root
+type Tree struct {
+	length uint64
+	chunks [][8]uint32
+	root   [32]byte
+}
+
+// New returns the tree of data
+func New(data []byte) *Tree {
+	t, _ := Build(bytes.NewReader(data))
+	return t
+}
+
+// Build returns the tree of the file read from r, reading it a chunk at a time
+func Build(r io.Reader) (*Tree, error) {
+	t := &Tree{}
+	buf := make([]byte, ChunkSize)
+	var last guts.Node
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && len(t.chunks) > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		// The empty file is a single empty chunk
+		last = guts.CompressChunk(buf[:n], &guts.IV, uint64(len(t.chunks)), 0)
+		t.chunks = append(t.chunks, guts.ChainingValue(last))
+		t.length += uint64(n)
+		if n < ChunkSize {
+			break
+		}
+	}
+
+	if len(t.chunks) == 1 {
+		last.Flags |= guts.FlagRoot
+		t.root = cvBytes(guts.ChainingValue(last))
+	} else {
+		t.root = cvBytes(subtreeCV(t.chunks, guts.FlagRoot))
+	}
+	return t, nil
+}
+
+// Root returns the root of the tree, the BLAKE3 digest of the file
+func (t *Tree) Root() [32]byte {
+	return t.root
+}
+
+// Len returns the length of the file in bytes
+func (t *Tree) Len() uint64 {
+	return t.length
+}
+
+// Chunks returns the number of chunks, at least 1
+func (t *Tree) Chunks() uint64 {
+	return uint64(len(t.chunks))
+}
+
+// WriteOutboard writes the bao outboard encoding of the tree to w
+func (t *Tree) WriteOutboard(w io.Writer) error {
+	buf := make([]byte, 8+(2*len(t.chunks)-2)*32)
+	binary.LittleEndian.PutUint64(buf, t.length)
+
+	// Children are written after their parent, which needs their chaining values
+	var encode func(chunks [][8]uint32, off int) ([8]uint32, int)
+	encode = func(chunks [][8]uint32, off int) ([8]uint32, int) {
+		if len(chunks) == 1 {
+			return chunks[0], 0
+		}
+		mid := split(len(chunks))
+		left, leftLen := encode(chunks[:mid], off+64)
+		right, rightLen := encode(chunks[mid:], off+64+leftLen)
+		putCV(buf[off:], left)
+		putCV(buf[off+32:], right)
+		return guts.ChainingValue(guts.ParentNode(left, right, &guts.IV, 0)), 64 + leftLen + rightLen
+	}
+	encode(t.chunks, 8)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadOutboard reads the bao outboard encoding of the tree with the given root
+// from r, checking every parent against the root. The chunks of the file can then
+// be verified with VerifyChunks without reading the rest of the file.
+func ReadOutboard(r io.Reader, root [32]byte) (*Tree, error) {
+	br := bufio.NewReader(r)
+	var header [8]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOutboard, err)
+	}
+	t := &Tree{length: binary.LittleEndian.Uint64(header[:]), root: root}
+	chunks := (t.length + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	var parent [64]byte
+	var decode func(cv [8]uint32, start, end uint64, flags uint32) error
+	decode = func(cv [8]uint32, start, end uint64, flags uint32) error {
+		if end-start == 1 {
+			t.chunks = append(t.chunks, cv)
+			return nil
+		}
+		if _, err := io.ReadFull(br, parent[:]); err != nil {
+			return fmt.Errorf("%w: parent of chunks [%d, %d): %v", ErrInvalidOutboard, start, end, err)
+		}
+		left, right := readCV(parent[:32]), readCV(parent[32:])
+		if guts.ChainingValue(guts.ParentNode(left, right, &guts.IV, flags)) != cv {
+			return fmt.Errorf("%w: parent of chunks [%d, %d) does not match", ErrInvalidOutboard, start, end)
+		}
+		mid := start + uint64(split(int(end-start)))
+		if err := decode(left, start, mid, 0); err != nil {
+			return err
+		}
+		return decode(right, mid, end, 0)
+	}
+	if err := decode(readCV(root[:]), 0, chunks, guts.FlagRoot); err != nil {
+		return nil, err
+	}
+	if n, _ := br.Read(parent[:1]); n != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidOutboard)
+	}
+	return t, nil
+}
+
+// VerifyChunks checks that data is the file from the chunk at index first on. data
+// must end at a chunk boundary or at the end of the file.
+func (t *Tree) VerifyChunks(first uint64, data []byte) error {
+	for i := first; ; i++ {
+		if i >= t.Chunks() {
+			return fmt.Errorf("%w: chunk %d of %d", merkletree.ErrIndexOutOfRange, i, t.Chunks())
+		}
+		size := uint64(ChunkSize)
+		if i == t.Chunks()-1 {
+			size = t.length - i*ChunkSize
+		}
+		if uint64(len(data)) < size {
+			return fmt.Errorf("%w: %d bytes of chunk %d of %d bytes", ErrChunkMismatch, len(data), i, size)
+		}
+		if !t.chunkMatches(i, data[:size]) {
+			return fmt.Errorf("%w: chunk %d", ErrChunkMismatch, i)
+		}
+		data = data[size:]
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+func (t *Tree) chunkMatches(i uint64, chunk []byte) bool {
+	n := guts.CompressChunk(chunk, &guts.IV, i, 0)
+	if len(t.chunks) == 1 {
+		n.Flags |= guts.FlagRoot
+		return cvBytes(guts.ChainingValue(n)) == t.root
+	}
+	return guts.ChainingValue(n) == t.chunks[i]
+}
+
+// InclusionProof returns the proof of the chunk at index i. Its hashes are the
+// chaining values of the siblings on the path from the chunk to the root, in the
+// order of an RFC 6962 audit path.
+func (t *Tree) InclusionProof(i uint64) (merkletree.InclusionProof, error) {
+	n := t.Chunks()
+	if i >= n {
+		return merkletree.InclusionProof{}, fmt.Errorf("%w: chunk %d of %d", merkletree.ErrIndexOutOfRange, i, n)
+	}
+	var hashes [][32]byte
+	chunks := t.chunks
+	for index := i; len(chunks) > 1; {
+		mid := uint64(split(len(chunks)))
+		if index < mid {
+			hashes = append(hashes, cvBytes(subtreeCV(chunks[mid:], 0)))
+			chunks = chunks[:mid]
+		} else {
+			hashes = append(hashes, cvBytes(subtreeCV(chunks[:mid], 0)))
+			chunks, index = chunks[mid:], index-mid
+		}
+	}
+	for l, r := 0, len(hashes)-1; l < r; l, r = l+1, r-1 {
+		hashes[l], hashes[r] = hashes[r], hashes[l]
+	}
+	return merkletree.InclusionProof{LeafIndex: i, TreeSize: n, Hashes: hashes}, nil
+}
+
+// VerifyInclusion checks that chunk is the chunk at p.LeafIndex of the file with
+// the given BLAKE3 digest, of p.TreeSize chunks
+func VerifyInclusion(chunk []byte, root [32]byte, p merkletree.InclusionProof) error {
+	if p.LeafIndex >= p.TreeSize {
+		return fmt.Errorf("%w: chunk %d of %d", merkletree.ErrIndexOutOfRange, p.LeafIndex, p.TreeSize)
+	}
+	if len(chunk) > ChunkSize {
+		return fmt.Errorf("%w: chunk of %d bytes", ErrChunkMismatch, len(chunk))
+	}
+
+	node := guts.CompressChunk(chunk, &guts.IV, p.LeafIndex, 0)
+	if p.TreeSize == 1 && len(p.Hashes) == 0 {
+		node.Flags |= guts.FlagRoot
+	}
+	cv := guts.ChainingValue(node)
+	fn, sn := p.LeafIndex, p.TreeSize-1
+	for j, h := range p.Hashes {
+		if sn == 0 {
+			return fmt.Errorf("%w: %d hashes for chunk %d of %d", merkletree.ErrInvalidProof, len(p.Hashes), p.LeafIndex, p.TreeSize)
+		}
+		var flags uint32
+		if j == len(p.Hashes)-1 {
+			flags = guts.FlagRoot
+		}
+		sibling := readCV(h[:])
+		if fn&1 == 1 || fn == sn {
+			cv = guts.ChainingValue(guts.ParentNode(sibling, cv, &guts.IV, flags))
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			cv = guts.ChainingValue(guts.ParentNode(cv, sibling, &guts.IV, flags))
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return fmt.Errorf("%w: %d hashes for chunk %d of %d", merkletree.ErrInvalidProof, len(p.Hashes), p.LeafIndex, p.TreeSize)
+	}
+	if cvBytes(cv) != root {
+		return fmt.Errorf("%w: chunk %d", merkletree.ErrRootMismatch, p.LeafIndex)
+	}
+	return nil
+}
+
+// subtreeCV returns the chaining value of the subtree over chunks, compressing its
+// top parent with flags
+func subtreeCV(chunks [][8]uint32, flags uint32) [8]uint32 {
+	if len(chunks) == 1 {
+		return chunks[0]
+	}
+	mid := split(len(chunks))
+	left, right := subtreeCV(chunks[:mid], 0), subtreeCV(chunks[mid:], 0)
+	return guts.ChainingValue(guts.ParentNode(left, right, &guts.IV, flags))
+}
+
+// split returns the number of chunks covered by the left subtree of n > 1 chunks,
+// the largest power of two smaller than n
+func split(n int) int {
+	return 1 << (bits.Len64(uint64(n-1)) - 1)
+}
+
+func cvBytes(cv [8]uint32) [32]byte {
+	var b [32]byte
+	putCV(b[:], cv)
+	return b
+}
+
+func putCV(b []byte, cv [8]uint32) {
+	for i, w := range cv {
+		binary.LittleEndian.PutUint32(b[4*i:], w)
+	}
+}
+
+func readCV(b []byte) [8]uint32 {
+	var cv [8]uint32
+	for i := range cv {
+		cv[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return cv
+}
@@ -0,0 +1,141 @@
+package blake3tree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+	"lukechampine.com/blake3"
+	"lukechampine.com/blake3/bao"
+)
+
+// testInput returns the input of the BLAKE3 test vectors: the byte i at index i,
+// modulo 251
+func testInput(n int) []byte {
+	input := make([]byte, n)
+	for i := range input {
+		input[i] = byte(i % 251)
+	}
+	return input
+}
+
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	assert.NoError(t, err)
+	var vectors struct {
+		Cases []struct {
+			InputLen int    `json:"input_len"`
+			Hash     string `json:"hash"`
+		}
+	}
+	assert.NoError(t, json.Unmarshal(data, &vectors))
+	assert.NotEmpty(t, vectors.Cases)
+
+	for _, c := range vectors.Cases {
+		tree := New(testInput(c.InputLen))
+		root := tree.Root()
+		assert.Equal(t, c.Hash[:64], hex.EncodeToString(root[:]), "input of %d bytes", c.InputLen)
+		assert.Equal(t, uint64(c.InputLen), tree.Len())
+	}
+}
+
+func TestOutboardGolden(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	assert.NoError(t, err)
+	golden, err := os.ReadFile("testdata/bao-golden.obao")
+	assert.NoError(t, err)
+
+	tree := New(data)
+	root := tree.Root()
+	assert.Equal(t, "6654fbd1836b531b25e2782c9cc9b792c80abb36b024f59db5d5f6bd3187ddfe", hex.EncodeToString(root[:]))
+	var outboard bytes.Buffer
+	assert.NoError(t, tree.WriteOutboard(&outboard))
+	assert.Equal(t, golden, outboard.Bytes())
+
+	read, err := ReadOutboard(bytes.NewReader(golden), root)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, read)
+}
+
+func TestOutboard(t *testing.T) {
+	for _, n := range []int{0, 1, 1023, 1024, 1025, 2048, 3000, 5 * 1024, 8 * 1024, 31*1024 + 7} {
+		data := testInput(n)
+		tree := New(data)
+		root := tree.Root()
+		assert.Equal(t, blake3.Sum256(data), root, "%d bytes", n)
+
+		var outboard bytes.Buffer
+		assert.NoError(t, tree.WriteOutboard(&outboard))
+		assert.True(t, bao.VerifyBuf(data, outboard.Bytes(), 0, root), "%d bytes", n)
+
+		read, err := ReadOutboard(bytes.NewReader(outboard.Bytes()), root)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.Chunks(), read.Chunks())
+		assert.NoError(t, read.VerifyChunks(0, data))
+		for i := uint64(0); i < read.Chunks(); i++ {
+			end := (i + 1) * ChunkSize
+			if end > uint64(n) {
+				end = uint64(n)
+			}
+			assert.NoError(t, read.VerifyChunks(i, data[i*ChunkSize:end]), "chunk %d of %d bytes", i, n)
+		}
+		if n > 0 {
+			tampered := append([]byte(nil), data...)
+			tampered[n-1] ^= 1
+			assert.ErrorIs(t, read.VerifyChunks(0, tampered), ErrChunkMismatch)
+		}
+
+		// The outboard of a single chunk has no parents, so the root is only checked
+		// against the chunk
+		other, err := ReadOutboard(bytes.NewReader(outboard.Bytes()), blake3.Sum256([]byte("other")))
+		if tree.Chunks() > 1 {
+			assert.ErrorIs(t, err, ErrInvalidOutboard)
+		} else {
+			assert.ErrorIs(t, other.VerifyChunks(0, data), ErrChunkMismatch)
+		}
+		if outboard.Len() > 8 {
+			corrupt := append([]byte(nil), outboard.Bytes()...)
+			corrupt[len(corrupt)-1] ^= 1
+			_, err = ReadOutboard(bytes.NewReader(corrupt), root)
+			assert.ErrorIs(t, err, ErrInvalidOutboard)
+			_, err = ReadOutboard(bytes.NewReader(corrupt[:len(corrupt)-1]), root)
+			assert.ErrorIs(t, err, ErrInvalidOutboard)
+		}
+		_, err = ReadOutboard(bytes.NewReader(append(outboard.Bytes(), 0)), root)
+		assert.ErrorIs(t, err, ErrInvalidOutboard)
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	for _, n := range []int{0, 1000, 1024, 1025, 7 * 1024, 13*1024 + 100} {
+		data := testInput(n)
+		tree := New(data)
+		for i := uint64(0); i < tree.Chunks(); i++ {
+			end := (i + 1) * ChunkSize
+			if end > uint64(n) {
+				end = uint64(n)
+			}
+			chunk := data[i*ChunkSize : end]
+			proof, err := tree.InclusionProof(i)
+			assert.NoError(t, err)
+			assert.NoError(t, VerifyInclusion(chunk, tree.Root(), proof), "chunk %d of %d bytes", i, n)
+
+			if len(chunk) > 0 {
+				tampered := append([]byte(nil), chunk...)
+				tampered[0] ^= 1
+				assert.ErrorIs(t, VerifyInclusion(tampered, tree.Root(), proof), merkletree.ErrRootMismatch)
+			}
+			if len(proof.Hashes) > 0 {
+				short := proof
+				short.Hashes = proof.Hashes[1:]
+				assert.Error(t, VerifyInclusion(chunk, tree.Root(), short))
+			}
+		}
+		_, err := tree.InclusionProof(tree.Chunks())
+		assert.ErrorIs(t, err, merkletree.ErrIndexOutOfRange)
+	}
+}
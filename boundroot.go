@@ -0,0 +1,86 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// BoundRoot returns the size-bound root of a tree of the given size and RFC 6962
+// root: SHA-256(uint64(size) || root), with size big endian. Unlike the RFC 6962
+// root alone, it commits to the number of leaves explicitly.
+func BoundRoot(size uint64, root [sha256.Size]byte) [sha256.Size]byte {
+	e := make([]byte, 0, 8+sha256.Size)
+	e = binary.BigEndian.AppendUint64(e, size)
+	e = append(e, root[:]...)
+	return sha256.Sum256(e)
+}
+
+// BoundRoot returns the size-bound root of the tree head. Tree heads that are
+// signed for consumers of size-bound roots should sign it in place of RootHash.
+func (h TreeHead) BoundRoot() [sha256.Size]byte {
+	return BoundRoot(h.TreeSize, h.RootHash)
+}
+
+// BoundRoot returns the size-bound root of the tree. MerkleRoot still returns the
+// RFC 6962 root it is derived from.
+func (m *MerkleHashTree) BoundRoot() [sha256.Size]byte {
+	defer m.readLock()()
+	return BoundRoot(uint64(len(m.tree[0])), m.root())
+}
+
+// VerifyInclusionBound checks that leafHash is included in the tree with the given
+// size-bound root. The root is only reproduced when p carries the actual size of
+// the tree, even if its audit path would also fit other sizes.
+func VerifyInclusionBound(leafHash, boundRoot [sha256.Size]byte, p InclusionProof) error {
+	root, err := verify.RootFromInclusionProof(leafHash, p)
+	if err != nil {
+		return err
+	}
+
+	calculated := BoundRoot(p.TreeSize, root)
+	if !bytes.Equal(calculated[:], boundRoot[:]) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// VerifyConsistencyBound checks that the tree with size-bound root newBoundRoot is an
+// append-only extension of the tree with size-bound root oldBoundRoot. oldRoot is the
+// RFC 6962 root of the old tree, which the verifier starts from when the old size is
+// a power of two.
+func VerifyConsistencyBound(oldRoot, oldBoundRoot, newBoundRoot [sha256.Size]byte, p ConsistencyProof) error {
+	m, n := p.OldSize, p.NewSize
+	if m > n {
+		return fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	if BoundRoot(m, oldRoot) != oldBoundRoot {
+		return fmt.Errorf("%w: old root does not match the old size-bound root", ErrRootMismatch)
+	}
+
+	// Nothing is known about the new tree when the old one is empty.
+	if m == 0 || m == n {
+		if len(p.Hashes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if m == n && oldBoundRoot != newBoundRoot {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	fr, sr, err := verify.RootsFromConsistencyProof(oldRoot, p)
+	if err != nil {
+		return err
+	}
+	if fr != oldRoot {
+		return fmt.Errorf("%w: reconstructed old root does not match", ErrRootMismatch)
+	}
+	if BoundRoot(n, sr) != newBoundRoot {
+		return fmt.Errorf("%w: reconstructed new root does not match", ErrRootMismatch)
+	}
+	return nil
+}
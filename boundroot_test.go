@@ -0,0 +1,57 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundRoot(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	assert.Equal(t, BoundRoot(7, MTH(D)), tree.BoundRoot())
+	assert.Equal(t, tree.TreeHead().BoundRoot(), tree.BoundRoot())
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+	assert.NotEqual(t, BoundRoot(7, MTH(D)), BoundRoot(8, MTH(D)))
+}
+
+func TestVerifyInclusionBound(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	for i := range D {
+		p, err := tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusionBound(leafHash(D[i]), tree.BoundRoot(), p))
+	}
+
+	// The audit path of the first leaf of a 3 leaf tree also fits a 4 leaf tree, so
+	// a proof lying about the size still reproduces the inner root.
+	small := New(D[:3])
+	p, err := small.InclusionProofByIndex(0)
+	assert.NoError(t, err)
+	p.TreeSize = 4
+	assert.NoError(t, VerifyInclusion(leafHash(D[0]), small.MerkleRoot(), p))
+	assert.ErrorIs(t, VerifyInclusionBound(leafHash(D[0]), small.BoundRoot(), p), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyInclusionBound(leafHash(D[0]), BoundRoot(3, small.MerkleRoot()), p), ErrRootMismatch)
+}
+
+func TestVerifyConsistencyBound(t *testing.T) {
+	D := makeEntries(20)
+	tree := New(D)
+
+	for n := 1; n <= len(D); n++ {
+		for m := 0; m <= n; m++ {
+			p, err := tree.ConsistencyProof(uint64(m), uint64(n))
+			assert.NoError(t, err)
+			oldRoot := MTH(D[:m])
+			err = VerifyConsistencyBound(oldRoot, BoundRoot(uint64(m), oldRoot), BoundRoot(uint64(n), MTH(D[:n])), p)
+			assert.NoError(t, err, "m=%d n=%d", m, n)
+		}
+	}
+
+	p, err := tree.ConsistencyProof(3, 7)
+	assert.NoError(t, err)
+	oldRoot := MTH(D[:3])
+	assert.ErrorIs(t, VerifyConsistencyBound(oldRoot, BoundRoot(4, oldRoot), BoundRoot(7, MTH(D[:7])), p), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyConsistencyBound(oldRoot, BoundRoot(3, oldRoot), BoundRoot(8, MTH(D[:7])), p), ErrRootMismatch)
+}
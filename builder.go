@@ -0,0 +1,126 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrTreeNotKept is returned by TreeBuilder.Tree unless the builder was created
+// with KeepTree
+var ErrTreeNotKept = errors.New("merkletree: builder does not keep the tree")
+
+// TreeBuilder computes the merkle root of a stream of entries added one at a time.
+// It only keeps the frontier of the tree, one root of a perfect subtree per level,
+// so that the root of n entries takes O(log n) memory, whatever the size of the
+// stream. The root of the entries added equals MTH of them.
+type TreeBuilder struct {
+	hasher   *Hasher
+	count    uint64
+	frontier [][sha256.Size]byte
+
+	keep   bool
+	leaves [][sha256.Size]byte
+}
+
+// TreeBuilderOption configures a TreeBuilder
+type TreeBuilderOption func(*TreeBuilder)
+
+// KeepTree keeps the leaf hashes of the entries added, for Tree to return the tree
+// with all its levels. The builder then takes O(n) memory.
+func KeepTree() TreeBuilderOption {
+	return func(b *TreeBuilder) {
+		b.keep = true
+	}
+}
+
+// WithBuilderHasher hashes the leaves and nodes with h, see WithHasher
+func WithBuilderHasher(h Hasher) TreeBuilderOption {
+	return func(b *TreeBuilder) {
+		if h.new == nil {
+			b.hasher = nil
+			return
+		}
+		b.hasher = &h
+	}
+}
+
+// NewTreeBuilder returns a builder of the tree of no entries
+func NewTreeBuilder(opts ...TreeBuilderOption) *TreeBuilder {
+	b := &TreeBuilder{frontier: make([][sha256.Size]byte, 0, 64)}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Add adds the leaf for entry d. A builder holding 2^64-1 leaves is full, and
+// further leaves fail with ErrLogFull. A builder with a Hasher without 32-byte
+// digests fails with ErrUnsupportedHash.
+func (b *TreeBuilder) Add(d []byte) error {
+	if b.count == math.MaxUint64 {
+		return fmt.Errorf("%w: %d leaves", ErrLogFull, b.count)
+	}
+
+	var h [sha256.Size]byte
+	if b.hasher == nil {
+		h = leafHash(d)
+	} else {
+		if err := b.hasher.fitsTree(); err != nil {
+			return err
+		}
+		h = b.hasher.LeafHash(d)
+	}
+	if b.keep {
+		b.leaves = append(b.leaves, h)
+	}
+
+	b.frontier = frontierAppendWith(b.nodeHash, b.frontier, b.count, h)
+	b.count++
+	return nil
+}
+
+// Count returns the number of leaves added
+func (b *TreeBuilder) Count() uint64 {
+	return b.count
+}
+
+// Root returns the merkle root of the leaves added
+func (b *TreeBuilder) Root() [sha256.Size]byte {
+	if b.hasher == nil {
+		return frontierRoot(b.frontier)
+	}
+	return frontierRootWith(b.hasher.NodeHash, b.hasher.EmptyRoot(), b.frontier)
+}
+
+// TreeHead returns the number and merkle root of the leaves added
+func (b *TreeBuilder) TreeHead() TreeHead {
+	return TreeHead{TreeSize: b.count, RootHash: b.Root()}
+}
+
+// Tree returns the tree of the leaves added, created with opts, when the builder
+// was created with KeepTree, and ErrTreeNotKept otherwise. The tree hashes with the
+// hasher of the builder.
+func (b *TreeBuilder) Tree(opts ...Option) (*MerkleHashTree, error) {
+	if !b.keep {
+		return nil, ErrTreeNotKept
+	}
+	if b.hasher != nil {
+		opts = append(opts, WithHasher(*b.hasher))
+	}
+
+	tree := New(nil, opts...)
+	defer tree.writeLock()()
+	if _, err := tree.admitLeafHashes(b.leaves); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (b *TreeBuilder) nodeHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	if b.hasher == nil {
+		return sha256NodeHash(left, right)
+	}
+	return b.hasher.NodeHash(left, right)
+}
@@ -0,0 +1,71 @@
+package merkletree
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	b := NewTreeBuilder()
+	assert.Equal(t, MTH(nil), b.Root())
+	D := makeEntries(130)
+	for n := 1; n <= len(D); n++ {
+		assert.NoError(t, b.Add(D[n-1]))
+		assert.Equal(t, uint64(n), b.Count())
+		assert.Equal(t, MTH(D[:n]), b.Root(), "size %d", n)
+		assert.Len(t, b.frontier, bits.OnesCount64(uint64(n)), "size %d", n)
+	}
+	assert.Equal(t, TreeHead{TreeSize: 130, RootHash: MTH(D)}, b.TreeHead())
+	_, err := b.Tree()
+	assert.ErrorIs(t, err, ErrTreeNotKept)
+}
+
+func TestTreeBuilderRandomSizes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		n := r.Intn(4000)
+		D := make([][]byte, n)
+		b := NewTreeBuilder()
+		for j := range D {
+			D[j] = make([]byte, r.Intn(64))
+			r.Read(D[j])
+			assert.NoError(t, b.Add(D[j]))
+		}
+		assert.Equal(t, MTH(D), b.Root(), "size %d", n)
+		assert.Equal(t, New(D).MerkleRoot(), b.Root(), "size %d", n)
+	}
+}
+
+func TestTreeBuilderKeepTree(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	D := makeEntries(77)
+	for _, opts := range [][]TreeBuilderOption{{KeepTree()}, {KeepTree(), WithBuilderHasher(h)}} {
+		b := NewTreeBuilder(opts...)
+		for _, d := range D {
+			assert.NoError(t, b.Add(d))
+		}
+		tree, err := b.Tree(WithLocking())
+		assert.NoError(t, err)
+		assert.Equal(t, b.Root(), tree.MerkleRoot())
+		assert.Equal(t, b.hasher != nil, tree.hasher != nil)
+		if b.hasher == nil {
+			assert.Equal(t, New(D).tree, tree.tree)
+		} else {
+			assert.Equal(t, referenceMTH(h, D), b.Root())
+			assert.Equal(t, New(D, WithHasher(h)).tree, tree.tree)
+		}
+	}
+	assert.Equal(t, h.EmptyRoot(), NewTreeBuilder(WithBuilderHasher(h)).Root())
+}
+
+func BenchmarkTreeBuilderAdd(b *testing.B) {
+	builder := NewTreeBuilder()
+	d := []byte("entry")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.Add(d)
+	}
+}
@@ -0,0 +1,244 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned by bundled logs
+var (
+	ErrInvalidBundleSize = errors.New("merkletree: bundle size must be positive")
+	ErrRecordNotSealed   = errors.New("merkletree: record is in the open bundle")
+)
+
+// Bundle describes a leaf of a BundledLog: the records [First, First+Count) and the
+// root of the inner tree over their leaf hashes
+type Bundle struct {
+	First uint64
+	Count uint64
+	Root  [sha256.Size]byte
+}
+
+// BundleLeaf returns the entry of the outer tree for b: EncodeLeafFields of the big
+// endian uint64 first record index and record count, and the inner root.
+func BundleLeaf(b Bundle) []byte {
+	first := binary.BigEndian.AppendUint64(nil, b.First)
+	count := binary.BigEndian.AppendUint64(nil, b.Count)
+	return EncodeLeafFields(first, count, b.Root[:])
+}
+
+// BundledLog is a log of records in two tiers: consecutive records are bundled
+// into an inner tree, and every sealed bundle is a BundleLeaf of the outer tree.
+// A bundle is sealed once it holds the bundle size of records, or on Flush, so the
+// last bundle before a flush may be shorter. Records of the open bundle are not
+// committed to by the outer tree yet. It is safe for concurrent use.
+type BundledLog struct {
+	mu         sync.Mutex
+	bundleSize uint64
+	tree       *MerkleHashTree
+	bundles    []Bundle
+	records    [][sha256.Size]byte
+}
+
+// NewBundledLog returns an empty log bundling up to bundleSize records per leaf.
+// The outer tree is created with opts. Records and bundles are hashed, and proofs
+// verified by VerifyRecord, with SHA-256, so a tree over another Hasher fails with
+// ErrUnsupportedHash.
+func NewBundledLog(bundleSize int, opts ...Option) (*BundledLog, error) {
+	if bundleSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBundleSize, bundleSize)
+	}
+	tree, err := TryNew(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if name := tree.algorithm(); name != "" {
+		return nil, fmt.Errorf("%w: bundled log over %s", ErrUnsupportedHash, name)
+	}
+	return &BundledLog{bundleSize: uint64(bundleSize), tree: tree}, nil
+}
+
+// Tree returns the outer tree, whose leaves are the BundleLeaf of every sealed bundle
+func (l *BundledLog) Tree() *MerkleHashTree {
+	return l.tree
+}
+
+// Bundles returns the sealed bundles
+func (l *BundledLog) Bundles() []Bundle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Bundle(nil), l.bundles...)
+}
+
+// Size returns the number of records, including those of the open bundle
+func (l *BundledLog) Size() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.records))
+}
+
+// Append adds records, sealing every bundle they fill. When a bundle cannot be
+// appended to the outer tree, the records before the one filling it stay added.
+func (l *BundledLog) Append(records ...[]byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, r := range records {
+		l.records = append(l.records, leafHash(r))
+		if l.open() == l.bundleSize {
+			if err := l.seal(); err != nil {
+				l.records = l.records[:len(l.records)-1]
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush seals the open bundle, if it holds any record, as a short bundle
+func (l *BundledLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open() == 0 {
+		return nil
+	}
+	return l.seal()
+}
+
+// open returns the number of records of the open bundle
+func (l *BundledLog) open() uint64 {
+	return uint64(len(l.records)) - l.sealed()
+}
+
+// sealed returns the number of records of sealed bundles
+func (l *BundledLog) sealed() uint64 {
+	if len(l.bundles) == 0 {
+		return 0
+	}
+	last := l.bundles[len(l.bundles)-1]
+	return last.First + last.Count
+}
+
+func (l *BundledLog) seal() error {
+	first := l.sealed()
+	root, _ := MTHRangeFromLeafHashes(l.records, first, uint64(len(l.records)))
+	b := Bundle{First: first, Count: uint64(len(l.records)) - first, Root: root}
+	if _, err := l.tree.TryAppend(BundleLeaf(b)); err != nil {
+		return err
+	}
+	l.bundles = append(l.bundles, b)
+	return nil
+}
+
+// RecordProof proves that a record is included in a bundle, and that the bundle is a
+// leaf of the outer tree
+type RecordProof struct {
+	Bundle Bundle
+	Record InclusionProof
+	Leaf   InclusionProof
+}
+
+// ProveRecord returns the proof of the record at recordIndex against the current
+// root of the outer tree
+func (l *BundledLog) ProveRecord(recordIndex uint64) (RecordProof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if recordIndex >= uint64(len(l.records)) {
+		return RecordProof{}, fmt.Errorf("%w: record %d of %d", ErrIndexOutOfRange, recordIndex, len(l.records))
+	}
+	if recordIndex >= l.sealed() {
+		return RecordProof{}, fmt.Errorf("%w: record %d", ErrRecordNotSealed, recordIndex)
+	}
+
+	i := sort.Search(len(l.bundles), func(i int) bool {
+		return l.bundles[i].First+l.bundles[i].Count > recordIndex
+	})
+	b := l.bundles[i]
+	leaf, err := l.tree.InclusionProofByIndex(uint64(i))
+	if err != nil {
+		return RecordProof{}, err
+	}
+
+	records := l.records[b.First : b.First+b.Count]
+	index := recordIndex - b.First
+	steps := inclusionSteps(index, 0, b.Count)
+	hashes := make([][sha256.Size]byte, len(steps))
+	for j, step := range steps {
+		start, end, _ := step.sibling.leafRange(b.Count)
+		hashes[j], _ = MTHRangeFromLeafHashes(records, start, end)
+	}
+	record := InclusionProof{LeafIndex: index, TreeSize: b.Count, Hashes: hashes}
+	return RecordProof{Bundle: b, Record: record, Leaf: leaf}, nil
+}
+
+// VerifyRecord checks that the record with the given leaf hash is the record at
+// recordIndex of the log whose outer tree has the given root. Only the proof itself
+// is consulted.
+func VerifyRecord(root, leafHash [sha256.Size]byte, recordIndex uint64, p RecordProof) error {
+	b := p.Bundle
+	if recordIndex < b.First || recordIndex-b.First >= b.Count {
+		return fmt.Errorf("%w: record %d is not in the bundle of records [%d, %d)", ErrInvalidProof, recordIndex, b.First, b.First+b.Count)
+	}
+	if p.Record.LeafIndex != recordIndex-b.First || p.Record.TreeSize != b.Count {
+		return fmt.Errorf("%w: record proof for index %d of %d", ErrInvalidProof, p.Record.LeafIndex, p.Record.TreeSize)
+	}
+	if err := VerifyInclusion(leafHash, b.Root, p.Record); err != nil {
+		return fmt.Errorf("%w in the bundle", err)
+	}
+	if err := VerifyInclusion(bundleLeafHash(b), root, p.Leaf); err != nil {
+		return fmt.Errorf("%w for the bundle in the outer tree", err)
+	}
+	return nil
+}
+
+func bundleLeafHash(b Bundle) [sha256.Size]byte {
+	return leafHash(BundleLeaf(b))
+}
+
+// MarshalBinary encodes p as the big endian uint64 first record and count and the
+// root of its bundle, followed by the record proof and the leaf proof encoded as
+// for ComposedProof
+func (p RecordProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, p.Bundle.First)
+	binary.Write(&buf, binary.BigEndian, p.Bundle.Count)
+	buf.Write(p.Bundle.Root[:])
+	for _, proof := range []InclusionProof{p.Record, p.Leaf} {
+		if err := writeInclusionProof(&buf, proof); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a record proof encoded by MarshalBinary
+func (p *RecordProof) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var decoded RecordProof
+	if err := binary.Read(r, binary.BigEndian, &decoded.Bundle.First); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &decoded.Bundle.Count); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if n, _ := r.Read(decoded.Bundle.Root[:]); n != sha256.Size {
+		return fmt.Errorf("%w: bundle root", ErrInvalidProof)
+	}
+	for _, proof := range []*InclusionProof{&decoded.Record, &decoded.Leaf} {
+		if err := readInclusionProof(r, proof); err != nil {
+			return err
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidProof, r.Len())
+	}
+
+	*p = decoded
+	return nil
+}
@@ -0,0 +1,96 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundledLogProveRecord(t *testing.T) {
+	D := makeEntries(23)
+	l, err := NewBundledLog(4)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Append(D[:10]...))
+	assert.NoError(t, l.Append(D[10:]...))
+
+	// 5 full bundles are sealed, the last 3 records are in the open bundle
+	assert.Equal(t, uint64(23), l.Size())
+	assert.Equal(t, uint64(5), l.Tree().Size())
+	root := l.Tree().MerkleRoot()
+	for i := uint64(0); i < 20; i++ {
+		p, err := l.ProveRecord(i)
+		assert.NoError(t, err)
+		assert.Equal(t, Bundle{First: i / 4 * 4, Count: 4, Root: MTH(D[i/4*4 : i/4*4+4])}, p.Bundle)
+		assert.NoError(t, VerifyRecord(root, leafHash(D[i]), i, p))
+		assert.ErrorIs(t, VerifyRecord(root, leafHash(D[i]), i^1, p), ErrInvalidProof)
+		assert.ErrorIs(t, VerifyRecord(root, leafHash(D[i^1]), i, p), ErrRootMismatch)
+	}
+	_, err = l.ProveRecord(20)
+	assert.ErrorIs(t, err, ErrRecordNotSealed)
+	_, err = l.ProveRecord(23)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	// Flushing seals the final partial bundle
+	assert.NoError(t, l.Flush())
+	assert.NoError(t, l.Flush())
+	assert.Equal(t, uint64(6), l.Tree().Size())
+	root = l.Tree().MerkleRoot()
+	for i := uint64(20); i < 23; i++ {
+		p, err := l.ProveRecord(i)
+		assert.NoError(t, err)
+		assert.Equal(t, Bundle{First: 20, Count: 3, Root: MTH(D[20:])}, p.Bundle)
+		assert.NoError(t, VerifyRecord(root, leafHash(D[i]), i, p))
+	}
+
+	// Records after a flush start a new bundle
+	assert.NoError(t, l.Append(D[:5]...))
+	p, err := l.ProveRecord(26)
+	assert.NoError(t, err)
+	assert.Equal(t, Bundle{First: 23, Count: 4, Root: MTH(D[:4])}, p.Bundle)
+	assert.NoError(t, VerifyRecord(l.Tree().MerkleRoot(), leafHash(D[3]), 26, p))
+	assert.Len(t, l.Bundles(), 7)
+}
+
+func TestBundledLogSingleRecordBundles(t *testing.T) {
+	D := makeEntries(5)
+	l, err := NewBundledLog(1)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Append(D...))
+	for i := range D {
+		p, err := l.ProveRecord(uint64(i))
+		assert.NoError(t, err)
+		assert.Empty(t, p.Record.Hashes)
+		assert.NoError(t, VerifyRecord(l.Tree().MerkleRoot(), leafHash(D[i]), uint64(i), p))
+	}
+
+	_, err = NewBundledLog(0)
+	assert.ErrorIs(t, err, ErrInvalidBundleSize)
+}
+
+func TestBundledLogCapacity(t *testing.T) {
+	l, err := NewBundledLog(2, WithMaxLeaves(1))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, l.Append(makeEntries(5)...), ErrLogFull)
+	assert.Equal(t, uint64(3), l.Size())
+	assert.Len(t, l.Bundles(), 1)
+}
+
+func TestRecordProofMarshalBinary(t *testing.T) {
+	D := makeEntries(13)
+	l, err := NewBundledLog(5)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Append(D...))
+	assert.NoError(t, l.Flush())
+
+	p, err := l.ProveRecord(11)
+	assert.NoError(t, err)
+	data, err := p.MarshalBinary()
+	assert.NoError(t, err)
+	var decoded RecordProof
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, p, decoded)
+	assert.NoError(t, VerifyRecord(l.Tree().MerkleRoot(), leafHash(D[11]), 11, decoded))
+
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), ErrInvalidProof)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), ErrInvalidProof)
+}
@@ -0,0 +1,90 @@
+package merkletree
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// proofCacheKey identifies an inclusion proof. A proof for a given leaf index and
+// tree size never changes once the tree has reached that size, so cached entries
+// stay valid across appends and are only ever evicted to bound memory, or purged
+// when the history of the tree is rewritten by Truncate or SetLeaf.
+type proofCacheKey struct {
+	index uint64
+	size  uint64
+}
+
+type proofCacheEntry struct {
+	key    proofCacheKey
+	hashes [][sha256.Size]byte
+}
+
+// proofCache is a bounded LRU cache of audit paths safe for concurrent use
+type proofCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[proofCacheKey]*list.Element
+	order    *list.List
+}
+
+func newProofCache(capacity int) *proofCache {
+	return &proofCache{
+		capacity: capacity,
+		entries:  make(map[proofCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns a copy of the cached audit path for key
+func (c *proofCache) get(key proofCacheKey) ([][sha256.Size]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return copyHashes(e.Value.(*proofCacheEntry).hashes), true
+}
+
+// purge removes all entries
+func (c *proofCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[proofCacheKey]*list.Element)
+	c.order.Init()
+}
+
+// add stores a copy of the audit path for key, evicting the least recently used entry when full
+func (c *proofCache) add(key proofCacheKey, hashes [][sha256.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&proofCacheEntry{key: key, hashes: copyHashes(hashes)})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*proofCacheEntry).key)
+	}
+}
+
+// len returns the number of cached proofs
+func (c *proofCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func copyHashes(hashes [][sha256.Size]byte) [][sha256.Size]byte {
+	c := make([][sha256.Size]byte, len(hashes))
+	copy(c, hashes)
+	return c
+}
@@ -0,0 +1,80 @@
+package merkletree
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingMetrics struct {
+	hits   int64
+	misses int64
+}
+
+func (c *countingMetrics) ProofCacheHit()  { atomic.AddInt64(&c.hits, 1) }
+func (c *countingMetrics) ProofCacheMiss() { atomic.AddInt64(&c.misses, 1) }
+
+func TestProofCache(t *testing.T) {
+	D := makeEntries(7)
+	metrics := &countingMetrics{}
+	tree := New(D, WithProofCache(2), WithMetrics(metrics))
+
+	first, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+	second, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int64(1), metrics.hits)
+	assert.Equal(t, int64(1), metrics.misses)
+
+	// mutating a returned proof must not affect the cached copy
+	second.Hashes[0][0] ^= 0xff
+	third, _ := tree.InclusionProofByIndex(3)
+	assert.Equal(t, first, third)
+
+	tree.InclusionProofByIndex(0)
+	tree.InclusionProofByIndex(1)
+	assert.Equal(t, 2, tree.proofCache.len())
+	// index 3 was evicted as the least recently used entry
+	tree.InclusionProofByIndex(3)
+	assert.Equal(t, int64(4), metrics.misses)
+}
+
+func TestProofCacheKeyedBySize(t *testing.T) {
+	D := makeEntries(16)
+	tree := New(D[:7], WithProofCache(16))
+
+	before, _ := tree.InclusionProofByIndex(3)
+	tree.Append(D[7:]...)
+	after, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, before.Hashes, after.Hashes)
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), MTH(D[:7]), before))
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), MTH(D), after))
+}
+
+func TestProofCacheConcurrentReaders(t *testing.T) {
+	D := makeEntries(64)
+	metrics := &countingMetrics{}
+	tree := New(D, WithProofCache(8), WithMetrics(metrics))
+	root := tree.MerkleRoot()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				index := uint64((g + i) % 16)
+				proof, err := tree.InclusionProofByIndex(index)
+				assert.NoError(t, err)
+				assert.NoError(t, VerifyInclusion(leafHash(D[index]), root, proof))
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(8*200), atomic.LoadInt64(&metrics.hits)+atomic.LoadInt64(&metrics.misses))
+}
@@ -0,0 +1,94 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors returned when a tree does not accept more leaves
+var (
+	ErrLogFull = errors.New("merkletree: append would exceed the maximum number of leaves")
+	ErrSealed  = errors.New("merkletree: tree is sealed")
+)
+
+// Seal stops the tree from accepting more leaves. Roots and proofs remain available.
+func (m *MerkleHashTree) Seal() {
+	defer m.writeLock()()
+	m.sealed = true
+}
+
+// Sealed reports whether the tree has been sealed, explicitly or by filling up
+// with WithSealWhenFull.
+func (m *MerkleHashTree) Sealed() bool {
+	defer m.readLock()()
+	return m.sealed
+}
+
+// Remaining returns the number of leaves that can still be appended to the tree
+func (m *MerkleHashTree) Remaining() uint64 {
+	defer m.readLock()()
+	return m.remaining()
+}
+
+func (m *MerkleHashTree) remaining() uint64 {
+	size := uint64(len(m.tree[0]) + len(m.pending))
+	switch {
+	case m.sealed:
+		return 0
+	case m.maxLeaves == 0:
+		return math.MaxUint64 - size
+	case size >= m.maxLeaves:
+		return 0
+	default:
+		return m.maxLeaves - size
+	}
+}
+
+// TryAppend adds new leaf nodes to the tree and returns the new merkle root. A
+// batch that would exceed the capacity of the tree set WithMaxLeaves is rejected
+// as a whole with ErrLogFull, and any batch appended to a sealed tree with ErrSealed.
+// WithDeferredHashing the entries are only buffered and the zero hash is returned
+// in place of the root.
+func (m *MerkleHashTree) TryAppend(d ...[]byte) ([sha256.Size]byte, error) {
+	if m.deferred && !m.sorted {
+		defer m.lock()()
+		return [sha256.Size]byte{}, m.deferEntries(d)
+	}
+	hash := m.leafHasher(d)
+
+	defer m.writeLock()()
+	leaves, err := hash(uint64(len(m.tree[0])))
+	if err != nil {
+		return m.root(), err
+	}
+	return m.admitLeafHashes(leaves)
+}
+
+// admitLeafHashes appends leaves when the tree has room for all of them, sealing
+// the tree once it is full when WithSealWhenFull is set.
+func (m *MerkleHashTree) admitLeafHashes(leaves [][sha256.Size]byte) ([sha256.Size]byte, error) {
+	if len(leaves) == 0 {
+		return m.root(), nil
+	}
+	if m.sealed {
+		return m.root(), ErrSealed
+	}
+	if remaining := m.remaining(); uint64(len(leaves)) > remaining {
+		return m.root(), fmt.Errorf("%w: %d leaves, %d remaining", ErrLogFull, len(leaves), remaining)
+	}
+	if err := m.checkSorted(uint64(len(m.tree[0])), leaves); err != nil {
+		return m.root(), err
+	}
+	size := uint64(len(m.tree[0]))
+	if err := m.persist(size, leaves, size+uint64(len(leaves))); err != nil {
+		return m.root(), err
+	}
+
+	root := m.appendLeafHashes(leaves)
+	if m.sealWhenFull && m.remaining() == 0 {
+		m.sealed = true
+	}
+	return root, nil
+}
@@ -0,0 +1,106 @@
+package merkletree
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxLeaves(t *testing.T) {
+	D := makeEntries(10)
+	tree := New(D[:2], WithMaxLeaves(6))
+	assert.Equal(t, uint64(4), tree.Remaining())
+
+	// A batch straddling the cap is rejected without partial application.
+	root := tree.MerkleRoot()
+	_, err := tree.TryAppend(D[2:7]...)
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Equal(t, uint64(2), tree.Size())
+	assert.Equal(t, root, tree.MerkleRoot())
+	assert.Equal(t, root, tree.Append(D[2:7]...))
+
+	// A batch exactly filling the tree succeeds.
+	newRoot, err := tree.TryAppend(D[2:6]...)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:6]), newRoot)
+	assert.Equal(t, uint64(0), tree.Remaining())
+	assert.False(t, tree.Sealed())
+
+	_, err = tree.TryAppend(D[6])
+	assert.ErrorIs(t, err, ErrLogFull)
+	_, err = tree.AppendIf(newRoot, D[6])
+	assert.ErrorIs(t, err, ErrLogFull)
+	_, _, err = tree.AppendWithProof(D[6])
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Equal(t, MTH(D[:6]), tree.MerkleRoot())
+}
+
+func TestSealWhenFull(t *testing.T) {
+	D := makeEntries(4)
+	tree := New(nil, WithMaxLeaves(3), WithSealWhenFull())
+	assert.Equal(t, uint64(3), tree.Remaining())
+
+	_, err := tree.TryAppend(D[:2]...)
+	assert.NoError(t, err)
+	assert.False(t, tree.Sealed())
+
+	_, err = tree.TryAppend(D[2])
+	assert.NoError(t, err)
+	assert.True(t, tree.Sealed())
+
+	_, err = tree.TryAppend(D[3])
+	assert.ErrorIs(t, err, ErrSealed)
+	assert.Equal(t, MTH(D[:3]), tree.MerkleRoot())
+}
+
+func TestNewMaxLeaves(t *testing.T) {
+	D := makeEntries(4)
+	_, err := TryNew(D, WithMaxLeaves(3))
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Panics(t, func() { New(D, WithMaxLeaves(3)) })
+
+	tree, err := TryNew(D[:3], WithMaxLeaves(3), WithSealWhenFull())
+	assert.NoError(t, err)
+	assert.True(t, tree.Sealed())
+	assert.Equal(t, MTH(D[:3]), tree.MerkleRoot())
+	_, err = tree.TryAppend(D[3])
+	assert.ErrorIs(t, err, ErrSealed)
+
+	tree = New(D[:3], WithMaxLeaves(3))
+	assert.False(t, tree.Sealed())
+	assert.Equal(t, uint64(0), tree.Remaining())
+	assert.False(t, New(D[:2], WithMaxLeaves(3), WithSealWhenFull()).Sealed())
+}
+
+func TestSeal(t *testing.T) {
+	D := makeEntries(3)
+	tree := New(D[:2])
+	assert.Equal(t, uint64(math.MaxUint64-2), tree.Remaining())
+
+	tree.Seal()
+	assert.True(t, tree.Sealed())
+	assert.Equal(t, uint64(0), tree.Remaining())
+	_, err := tree.TryAppend(D[2])
+	assert.ErrorIs(t, err, ErrSealed)
+
+	proof, err := tree.InclusionProofByIndex(1)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[1]), MTH(D[:2]), proof))
+}
+
+func TestSequencerRejectsBatchBeyondCapacity(t *testing.T) {
+	D := makeEntries(3)
+	tree := New(D[:2], WithLocking(), WithMaxLeaves(3))
+	s := NewSequencer(tree, WithMaxBatch(2))
+	defer s.Close()
+
+	first, err := s.Submit(D[2])
+	assert.NoError(t, err)
+	second, err := s.Submit([]byte("overflow"))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, receive(t, first).Err, ErrLogFull)
+	assert.ErrorIs(t, receive(t, second).Err, ErrLogFull)
+	assert.Equal(t, uint64(2), tree.Size())
+}
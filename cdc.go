@@ -0,0 +1,231 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// ErrInvalidChunkLayout is returned when decoding a chunk layout not written by
+// ChunkLayout.MarshalBinary
+var ErrInvalidChunkLayout = errors.New("merkletree: invalid chunk layout")
+
+// CDCParams are the parameters of content-defined chunking. Chunks are at least
+// Min and at most Max bytes long, and Avg bytes on average. The chunks of a stream,
+// and so the root of its tree, depend on them.
+type CDCParams struct {
+	Min, Avg, Max int
+}
+
+func (p CDCParams) validate() error {
+	if p.Min < 1 || p.Min > p.Avg || p.Avg > p.Max {
+		return fmt.Errorf("%w: chunk sizes min %d, avg %d, max %d", ErrInvalidRange, p.Min, p.Avg, p.Max)
+	}
+	return nil
+}
+
+// WithContentDefinedChunking makes NewFromChunks cut chunks where the content of
+// the stream says so, instead of every chunkSize bytes, so that an edit only
+// changes the chunks around it. chunkSize is the average size, and chunks are
+// between min and max bytes. The sizes of the chunks are kept in the ChunkLayout
+// of the tree.
+func WithContentDefinedChunking(min, max int) Option {
+	return func(m *MerkleHashTree) {
+		m.cdc = &CDCParams{Min: min, Max: max}
+	}
+}
+
+// ChunkLayout is the content-defined chunking of a stream: the parameters it was
+// cut with and the size of every chunk, leaf i covering the Size[i] bytes of the
+// stream from Offset(i)
+type ChunkLayout struct {
+	Params CDCParams
+	Sizes  []uint64
+}
+
+// ChunkLayout returns the layout of the chunks of a tree built by NewFromChunks
+// WithContentDefinedChunking, or false. It covers the leaves read from the stream,
+// not leaves appended later.
+func (m *MerkleHashTree) ChunkLayout() (ChunkLayout, bool) {
+	defer m.readLock()()
+	if m.chunkLayout == nil {
+		return ChunkLayout{}, false
+	}
+	return ChunkLayout{Params: m.chunkLayout.Params, Sizes: append([]uint64(nil), m.chunkLayout.Sizes...)}, true
+}
+
+// Offset returns the offset in the stream of chunk i
+func (l ChunkLayout) Offset(i uint64) uint64 {
+	var offset uint64
+	for _, size := range l.Sizes[:i] {
+		offset += size
+	}
+	return offset
+}
+
+// Length returns the length of the stream
+func (l ChunkLayout) Length() uint64 {
+	return l.Offset(uint64(len(l.Sizes)))
+}
+
+// MarshalBinary encodes the layout as unsigned varints: the minimum, average and
+// maximum chunk size, the number of chunks and their sizes
+func (l ChunkLayout) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 4*binary.MaxVarintLen64+len(l.Sizes)*3)
+	for _, v := range []int{l.Params.Min, l.Params.Avg, l.Params.Max, len(l.Sizes)} {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	for _, size := range l.Sizes {
+		b = binary.AppendUvarint(b, size)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a layout encoded by MarshalBinary
+func (l *ChunkLayout) UnmarshalBinary(b []byte) error {
+	var values [4]uint64
+	for i := range values {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > 1<<31 {
+			return fmt.Errorf("%w: bad header", ErrInvalidChunkLayout)
+		}
+		values[i], b = v, b[n:]
+	}
+	params := CDCParams{Min: int(values[0]), Avg: int(values[1]), Max: int(values[2])}
+	if err := params.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChunkLayout, err)
+	}
+	if values[3] > uint64(len(b)) {
+		return fmt.Errorf("%w: %d chunks in %d bytes", ErrInvalidChunkLayout, values[3], len(b))
+	}
+	sizes := make([]uint64, values[3])
+	for i := range sizes {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v < 1 || v > uint64(params.Max) {
+			return fmt.Errorf("%w: bad size of chunk %d", ErrInvalidChunkLayout, i)
+		}
+		sizes[i], b = v, b[n:]
+	}
+	if len(b) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidChunkLayout, len(b))
+	}
+	*l = ChunkLayout{Params: params, Sizes: sizes}
+	return nil
+}
+
+// gearTable holds the random values of bytes for the gear hash of FastCDC. They
+// are the first 8 bytes, big endian, of SHA-256("merkletree gear" || b), fixed
+// since chunk boundaries depend on them.
+var gearTable = func() (table [256]uint64) {
+	for i := range table {
+		h := sha256.Sum256(append([]byte("merkletree gear"), byte(i)))
+		table[i] = binary.BigEndian.Uint64(h[:])
+	}
+	return table
+}()
+
+// chunker cuts a stream into chunks with FastCDC: the gear hash of the bytes of
+// the chunk is computed from its minimum size on, and the chunk is cut after the
+// first byte where the top bits of the hash are zero. Normalized chunking uses
+// two more bits than the average size calls for before the average size, and two
+// fewer after it, to keep sizes close to the average.
+type chunker struct {
+	r       io.Reader
+	params  CDCParams
+	small   uint64
+	large   uint64
+	buf     []byte
+	n, next int
+	eof     bool
+}
+
+func newChunker(r io.Reader, params CDCParams) *chunker {
+	b := bits.Len(uint(params.Avg)) - 1
+	mask := func(n int) uint64 {
+		if n < 1 {
+			n = 1
+		}
+		return ^uint64(0) << (64 - n)
+	}
+	return &chunker{r: r, params: params, small: mask(b + 2), large: mask(b - 2), buf: make([]byte, 2*params.Max)}
+}
+
+// chunk returns the next chunk, valid until the next call, or io.EOF
+func (c *chunker) chunk() ([]byte, error) {
+	if c.n-c.next < c.params.Max && !c.eof {
+		c.n = copy(c.buf, c.buf[c.next:c.n])
+		c.next = 0
+		m, err := io.ReadFull(c.r, c.buf[c.n:])
+		c.n += m
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	data := c.buf[c.next:c.n]
+	if len(data) == 0 {
+		return nil, io.EOF
+	}
+	size := cutPoint(data, c.params, c.small, c.large)
+	c.next += size
+	return data[:size], nil
+}
+
+// cutPoint returns the size of the chunk at the start of data
+func cutPoint(data []byte, p CDCParams, small, large uint64) int {
+	if len(data) <= p.Min {
+		return len(data)
+	}
+	end, avg := len(data), p.Avg
+	if end > p.Max {
+		end = p.Max
+	}
+	if avg > end {
+		avg = end
+	}
+	var hash uint64
+	i := p.Min
+	for ; i < avg; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&small == 0 {
+			return i + 1
+		}
+	}
+	for ; i < end; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&large == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
+
+// appendContentChunks appends a leaf per content-defined chunk of r to the tree
+func (m *MerkleHashTree) appendContentChunks(r io.Reader, params CDCParams) error {
+	if err := params.validate(); err != nil {
+		return err
+	}
+	layout := &ChunkLayout{Params: params, Sizes: make([]uint64, 0)}
+	c := newChunker(r, params)
+	for {
+		chunk, err := c.chunk()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := m.TryAppend(chunk); err != nil {
+			return err
+		}
+		layout.Sizes = append(layout.Sizes, uint64(len(chunk)))
+	}
+	unlock := m.writeLock()
+	m.chunkLayout = layout
+	unlock()
+	return nil
+}
@@ -0,0 +1,148 @@
+package merkletree
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomFile(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// leafSet returns the leaf hashes of tree, counted
+func leafSet(tree *MerkleHashTree) map[[32]byte]int {
+	set := make(map[[32]byte]int)
+	for _, h := range tree.tree[0] {
+		set[h]++
+	}
+	return set
+}
+
+// changedLeaves returns the number of leaves of b that are not leaves of a
+func changedLeaves(a, b *MerkleHashTree) int {
+	set := leafSet(a)
+	changed := 0
+	for _, h := range b.tree[0] {
+		if set[h] > 0 {
+			set[h]--
+			continue
+		}
+		changed++
+	}
+	return changed
+}
+
+func TestContentDefinedChunking(t *testing.T) {
+	file := randomFile(1<<20, 1)
+	opt := WithContentDefinedChunking(2048, 65536)
+	tree, err := NewFromChunks(bytes.NewReader(file), 8192, opt)
+	assert.NoError(t, err)
+
+	layout, ok := tree.ChunkLayout()
+	assert.True(t, ok)
+	assert.Equal(t, CDCParams{Min: 2048, Avg: 8192, Max: 65536}, layout.Params)
+	assert.Equal(t, tree.Size(), uint64(len(layout.Sizes)))
+	assert.Equal(t, uint64(len(file)), layout.Length())
+	for i, size := range layout.Sizes {
+		assert.LessOrEqual(t, size, uint64(65536))
+		if i < len(layout.Sizes)-1 {
+			assert.GreaterOrEqual(t, size, uint64(2048))
+		}
+		start := layout.Offset(uint64(i))
+		assert.Equal(t, leafHash(file[start:start+size]), tree.tree[0][i])
+	}
+	// Sizes stay around the average
+	assert.InDelta(t, len(file)/8192, len(layout.Sizes), float64(len(file)/8192)/2)
+
+	// The layout does not depend on how the stream is read
+	again, err := NewFromChunks(io.MultiReader(bytes.NewReader(file[:12345]), bytes.NewReader(file[12345:])), 8192, opt)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.MerkleRoot(), again.MerkleRoot())
+
+	_, ok = New(nil).ChunkLayout()
+	assert.False(t, ok)
+	_, err = NewFromChunks(bytes.NewReader(file), 1024, opt)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestContentDefinedChunkingLocalEdits(t *testing.T) {
+	file := randomFile(1<<20, 2)
+	edited := append(append(append([]byte(nil), file[:1000]...), "inserted bytes"...), file[1000:]...)
+	edited = append(edited[:500000], edited[500100:]...)
+
+	opt := WithContentDefinedChunking(1024, 16384)
+	before, err := NewFromChunks(bytes.NewReader(file), 4096, opt)
+	assert.NoError(t, err)
+	after, err := NewFromChunks(bytes.NewReader(edited), 4096, opt)
+	assert.NoError(t, err)
+
+	// An insertion and a deletion each change the chunks around them only
+	changed := changedLeaves(before, after)
+	assert.Greater(t, changed, 0)
+	assert.LessOrEqual(t, changed, 6, "%d of %d leaves changed", changed, after.Size())
+
+	// Fixed size chunks all change after the insertion
+	fixedBefore, err := NewFromChunks(bytes.NewReader(file), 4096)
+	assert.NoError(t, err)
+	fixedAfter, err := NewFromChunks(bytes.NewReader(edited), 4096)
+	assert.NoError(t, err)
+	assert.Greater(t, changedLeaves(fixedBefore, fixedAfter), int(fixedAfter.Size())*9/10)
+}
+
+func TestChunkLayoutVerifyingReader(t *testing.T) {
+	file := randomFile(100000, 3)
+	tree, err := NewFromChunks(bytes.NewReader(file), 1024, WithContentDefinedChunking(256, 4096))
+	assert.NoError(t, err)
+	layout, _ := tree.ChunkLayout()
+
+	proofs := make([]InclusionProof, tree.Size())
+	for i := range proofs {
+		proofs[i], err = tree.InclusionProofAtSize(uint64(i), tree.Size())
+		assert.NoError(t, err)
+	}
+	v, err := NewLayoutVerifyingReader(bytes.NewReader(file), tree.MerkleRoot(), layout, StaticChunkProofs(proofs))
+	assert.NoError(t, err)
+	got, err := io.ReadAll(v)
+	assert.NoError(t, err)
+	assert.Equal(t, file, got)
+
+	// Shifting the boundary of a chunk fails its verification
+	layout.Sizes[3]++
+	layout.Sizes[4]--
+	v, err = NewLayoutVerifyingReader(bytes.NewReader(file), tree.MerkleRoot(), layout, StaticChunkProofs(proofs))
+	assert.NoError(t, err)
+	_, err = io.ReadAll(v)
+	var chunkErr *ChunkError
+	assert.ErrorAs(t, err, &chunkErr)
+	assert.Equal(t, uint64(3), chunkErr.Index)
+}
+
+func TestChunkLayoutSerialization(t *testing.T) {
+	tree, err := NewFromChunks(bytes.NewReader(randomFile(50000, 4)), 1024, WithContentDefinedChunking(256, 4096))
+	assert.NoError(t, err)
+	layout, _ := tree.ChunkLayout()
+
+	b, err := layout.MarshalBinary()
+	assert.NoError(t, err)
+	var decoded ChunkLayout
+	assert.NoError(t, decoded.UnmarshalBinary(b))
+	assert.Equal(t, layout, decoded)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(b[:len(b)-1]), ErrInvalidChunkLayout)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(b, 0)), ErrInvalidChunkLayout)
+
+	key := bytes.Repeat([]byte{7}, 32)
+	var buf bytes.Buffer
+	assert.NoError(t, tree.SaveEncrypted(&buf, key))
+	loaded, err := LoadEncrypted(&buf, key)
+	assert.NoError(t, err)
+	loadedLayout, ok := loaded.ChunkLayout()
+	assert.True(t, ok)
+	assert.Equal(t, layout, loadedLayout)
+	assert.Equal(t, tree.MerkleRoot(), loaded.MerkleRoot())
+}
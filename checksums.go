@@ -0,0 +1,144 @@
+package merkletree
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidChecksumFile is returned when parsing a line that is not in the
+// format of sha256sum
+var ErrInvalidChecksumFile = errors.New("merkletree: invalid checksum file")
+
+// Checksum is a line of a checksum file: the SHA-256 digest of the content of the
+// named file
+type Checksum struct {
+	Name   string
+	Digest [sha256.Size]byte
+}
+
+// LeafData returns the data of the leaf for c in a tree built by
+// NewFromChecksumFile: the name and the digest as fields encoded by
+// EncodeLeafFields. The digest stands in for the content, which is not read.
+func (c Checksum) LeafData() []byte {
+	return EncodeLeafFields([]byte(c.Name), c.Digest[:])
+}
+
+// ParseChecksums reads a checksum file in the format of sha256sum: a line per
+// file of the hex digest, a space, a space or the '*' marker of binary mode, and
+// the name. A line starting with a backslash has a name with the backslashes and
+// newlines escaped as \\ and \n. Blank lines are skipped, and any other line fails
+// with ErrInvalidChecksumFile naming its line number.
+func ParseChecksums(r io.Reader) ([]Checksum, error) {
+	var checksums []Checksum
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		c, err := parseChecksumLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidChecksumFile, line, err)
+		}
+		checksums = append(checksums, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+func parseChecksumLine(text string) (Checksum, error) {
+	var c Checksum
+	escaped := strings.HasPrefix(text, `\`)
+	if escaped {
+		text = text[1:]
+	}
+
+	const digestLen = 2 * sha256.Size
+	if len(text) < digestLen+3 {
+		return c, errors.New("line too short")
+	}
+	if _, err := hex.Decode(c.Digest[:], []byte(text[:digestLen])); err != nil {
+		return c, fmt.Errorf("bad digest: %v", err)
+	}
+	if text[digestLen] != ' ' || (text[digestLen+1] != ' ' && text[digestLen+1] != '*') {
+		return c, errors.New("digest not followed by two spaces or a space and '*'")
+	}
+
+	c.Name = text[digestLen+2:]
+	if escaped {
+		name, err := unescapeChecksumName(c.Name)
+		if err != nil {
+			return c, err
+		}
+		c.Name = name
+	}
+	return c, nil
+}
+
+func unescapeChecksumName(name string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '\\' {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i++; i == len(name) {
+			return "", errors.New("name ends in a backslash")
+		}
+		switch name[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			return "", fmt.Errorf("bad escape \\%c in name", name[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// NewFromChecksumFile returns the tree, created with opts, with a leaf for every
+// line of the checksum file read from r, in order, whose data is the LeafData of
+// the line. Lines are parsed by ParseChecksums.
+func NewFromChecksumFile(r io.Reader, opts ...Option) (*MerkleHashTree, error) {
+	checksums, err := ParseChecksums(r)
+	if err != nil {
+		return nil, err
+	}
+	D := make([][]byte, len(checksums))
+	for i, c := range checksums {
+		D[i] = c.LeafData()
+	}
+	return New(D, opts...), nil
+}
+
+// ExportChecksums writes the leaf hashes of the tree to w as a checksum file in
+// the format of sha256sum, the leaf at index i named names[i], escaping names with
+// backslashes or newlines as sha256sum does. There must be a name for every leaf.
+func (m *MerkleHashTree) ExportChecksums(w io.Writer, names []string) error {
+	unlock := m.readLock()
+	leaves := copyHashes(m.tree[0])
+	unlock()
+	if len(names) != len(leaves) {
+		return fmt.Errorf("%w: %d names for %d leaves", ErrInvalidRange, len(names), len(leaves))
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, leaf := range leaves {
+		name := names[i]
+		if strings.ContainsAny(name, "\\\n") {
+			bw.WriteByte('\\')
+			name = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(name)
+		}
+		fmt.Fprintf(bw, "%x  %s\n", leaf, name)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,92 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksumsCoreutils(t *testing.T) {
+	// SHA256SUMS was written by GNU coreutils sha256sum over the files beside it,
+	// license-head in binary mode
+	dir := filepath.Join("testdata", "checksums")
+	f, err := os.Open(filepath.Join(dir, "SHA256SUMS"))
+	assert.NoError(t, err)
+	defer f.Close()
+	checksums, err := ParseChecksums(f)
+	assert.NoError(t, err)
+
+	names := []string{"hello.txt", "empty", "license-head", `back\slash.txt`, "sub/nested.txt"}
+	assert.Len(t, checksums, len(names))
+	D := make([][]byte, len(checksums))
+	for i, c := range checksums {
+		assert.Equal(t, names[i], c.Name)
+		content, err := os.ReadFile(filepath.Join(dir, c.Name))
+		assert.NoError(t, err)
+		assert.Equal(t, sha256.Sum256(content), c.Digest, c.Name)
+		D[i] = EncodeLeafFields([]byte(c.Name), c.Digest[:])
+	}
+
+	_, err = f.Seek(0, 0)
+	assert.NoError(t, err)
+	tree, err := NewFromChecksumFile(f)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+}
+
+func TestChecksumsRoundTrip(t *testing.T) {
+	D := makeEntries(5)
+	tree := New(D)
+	names := []string{"a", "dir/b c", `back\slash`, "new\nline", "*star"}
+
+	var b bytes.Buffer
+	assert.NoError(t, tree.ExportChecksums(&b, names))
+	assert.True(t, strings.HasPrefix(strings.Split(b.String(), "\n")[2], `\`))
+
+	checksums, err := ParseChecksums(&b)
+	assert.NoError(t, err)
+	assert.Len(t, checksums, len(D))
+	for i, c := range checksums {
+		assert.Equal(t, names[i], c.Name)
+		assert.Equal(t, leafHash(D[i]), c.Digest)
+	}
+
+	assert.ErrorIs(t, tree.ExportChecksums(&b, names[:4]), ErrInvalidRange)
+}
+
+func TestParseChecksumsTolerant(t *testing.T) {
+	digest := sha256.Sum256([]byte("x"))
+	hexDigest := strings.ToUpper(hex.EncodeToString(digest[:]))
+	file := "\n" + hexDigest + " *one\n\n   \n" + hex.EncodeToString(digest[:]) + "  two words\n"
+
+	checksums, err := ParseChecksums(strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, []Checksum{{Name: "one", Digest: digest}, {Name: "two words", Digest: digest}}, checksums)
+}
+
+func TestParseChecksumsErrors(t *testing.T) {
+	digest := sha256.Sum256([]byte("x"))
+	good := hex.EncodeToString(digest[:]) + "  name\n"
+	for _, tc := range []struct {
+		name, line, want string
+	}{
+		{"short", "abcd  name", "line 3: line too short"},
+		{"bad hex", strings.Repeat("zz", 32) + "  name", "line 3: bad digest"},
+		{"one space", hex.EncodeToString(digest[:]) + " name", "line 3: digest not followed"},
+		{"no space", hex.EncodeToString(digest[:]) + "x name", "line 3: digest not followed"},
+		{"bad escape", `\` + hex.EncodeToString(digest[:]) + `  a\tb`, `line 3: bad escape \t`},
+		{"trailing backslash", `\` + hex.EncodeToString(digest[:]) + `  a\`, "line 3: name ends in a backslash"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseChecksums(strings.NewReader(good + "\n" + tc.line + "\n" + good))
+			assert.ErrorIs(t, err, ErrInvalidChecksumFile)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnverifiedResponse is returned when a log server responds with a proof that does not verify
+var ErrUnverifiedResponse = errors.New("merkletree: log server response does not verify")
+
+// LogClient fetches roots and proofs from a server created with NewHandler
+type LogClient struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+// ClientOption configures a LogClient
+type ClientOption func(*LogClient)
+
+// WithHTTPClient sets the http.Client used for requests
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(l *LogClient) {
+		l.httpClient = c
+	}
+}
+
+// WithRetries retries failed requests up to n times, waiting backoff before the
+// first retry and doubling the wait after each attempt. Only network errors,
+// 429 and 5xx responses are retried.
+func WithRetries(n int, backoff time.Duration) ClientOption {
+	return func(l *LogClient) {
+		l.maxRetries = n
+		l.backoff = backoff
+	}
+}
+
+// NewLogClient returns a client for the log served at baseURL
+func NewLogClient(baseURL string, opts ...ClientOption) *LogClient {
+	c := &LogClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetRoot fetches the current tree head of the log
+func (c *LogClient) GetRoot(ctx context.Context) (TreeHead, error) {
+	var resp rootResponse
+	if err := c.get(ctx, RootPath, nil, &resp); err != nil {
+		return TreeHead{}, err
+	}
+
+	head := TreeHead{TreeSize: resp.TreeSize}
+	if err := decodeHexHash(resp.RootHash, &head.RootHash); err != nil {
+		return TreeHead{}, err
+	}
+	return head, nil
+}
+
+// GetInclusionProof fetches the inclusion proof of the leaf at index in the tree of size leaves
+func (c *LogClient) GetInclusionProof(ctx context.Context, index, size uint64) (InclusionProof, error) {
+	params := url.Values{}
+	params.Set("index", strconv.FormatUint(index, 10))
+	params.Set("tree_size", strconv.FormatUint(size, 10))
+	return c.getInclusionProof(ctx, params)
+}
+
+// GetInclusionProofByHash fetches the inclusion proof of the leaf with the given
+// leaf hash in the tree of size leaves
+func (c *LogClient) GetInclusionProofByHash(ctx context.Context, leafHash [sha256.Size]byte, size uint64) (InclusionProof, error) {
+	params := url.Values{}
+	params.Set("leaf_hash", hex.EncodeToString(leafHash[:]))
+	params.Set("tree_size", strconv.FormatUint(size, 10))
+	return c.getInclusionProof(ctx, params)
+}
+
+func (c *LogClient) getInclusionProof(ctx context.Context, params url.Values) (InclusionProof, error) {
+	var resp inclusionProofResponse
+	if err := c.get(ctx, InclusionProofPath, params, &resp); err != nil {
+		return InclusionProof{}, err
+	}
+
+	hashes, err := decodeHexHashes(resp.AuditPath)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return InclusionProof{Algorithm: resp.Algorithm, LeafIndex: resp.LeafIndex, TreeSize: resp.TreeSize, Hashes: hashes}, nil
+}
+
+// GetConsistency fetches the consistency proof between the trees of the first
+// oldSize and newSize leaves
+func (c *LogClient) GetConsistency(ctx context.Context, oldSize, newSize uint64) (ConsistencyProof, error) {
+	params := url.Values{}
+	params.Set("first", strconv.FormatUint(oldSize, 10))
+	params.Set("second", strconv.FormatUint(newSize, 10))
+
+	var resp consistencyProofResponse
+	if err := c.get(ctx, ConsistencyProofPath, params, &resp); err != nil {
+		return ConsistencyProof{}, err
+	}
+
+	hashes, err := decodeHexHashes(resp.Consistency)
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+	return ConsistencyProof{Algorithm: resp.Algorithm, OldSize: resp.First, NewSize: resp.Second, Hashes: hashes}, nil
+}
+
+// GetEntries fetches the leaf inputs of the entries start to end of the log. Like
+// get-entries of Certificate Transparency, end is inclusive and the server may
+// return fewer entries than requested.
+func (c *LogClient) GetEntries(ctx context.Context, start, end uint64) ([][]byte, error) {
+	params := url.Values{}
+	params.Set("start", strconv.FormatUint(start, 10))
+	params.Set("end", strconv.FormatUint(end, 10))
+
+	var resp entriesResponse
+	if err := c.get(ctx, EntriesPath, params, &resp); err != nil {
+		return nil, err
+	}
+	if uint64(len(resp.Entries)) > end-start+1 {
+		return nil, fmt.Errorf("%w: %d entries, requested %d", ErrUnverifiedResponse, len(resp.Entries), end-start+1)
+	}
+	leaves := make([][]byte, len(resp.Entries))
+	for i, e := range resp.Entries {
+		leaves[i] = e.LeafInput
+	}
+	return leaves, nil
+}
+
+// VerifyLeaf fetches the inclusion proof for leafHash at the tree head trusted by
+// w and verifies it against the trusted root.
+func (c *LogClient) VerifyLeaf(ctx context.Context, leafHash [sha256.Size]byte, w *Witness) (InclusionProof, error) {
+	head := w.TreeHead()
+	proof, err := c.GetInclusionProofByHash(ctx, leafHash, head.TreeSize)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	if proof.TreeSize != head.TreeSize {
+		return InclusionProof{}, fmt.Errorf("%w: proof for tree size %d, requested %d", ErrUnverifiedResponse, proof.TreeSize, head.TreeSize)
+	}
+	if err := VerifyInclusion(leafHash, head.RootHash, proof); err != nil {
+		return InclusionProof{}, fmt.Errorf("%w: %v", ErrUnverifiedResponse, err)
+	}
+	return proof, nil
+}
+
+// UpdateWitness fetches the current tree head of the log together with a
+// consistency proof from the tree head trusted by w, and advances w when the
+// proof verifies. It returns the tree head trusted by w afterwards.
+func (c *LogClient) UpdateWitness(ctx context.Context, w *Witness) (TreeHead, error) {
+	trusted := w.TreeHead()
+	head, err := c.GetRoot(ctx)
+	if err != nil {
+		return trusted, err
+	}
+	if head.TreeSize < trusted.TreeSize {
+		return trusted, fmt.Errorf("%w: tree size %d is smaller than trusted size %d", ErrUnverifiedResponse, head.TreeSize, trusted.TreeSize)
+	}
+
+	proof, err := c.GetConsistency(ctx, trusted.TreeSize, head.TreeSize)
+	if err != nil {
+		return trusted, err
+	}
+	if err := w.Update(head, proof); err != nil {
+		return trusted, fmt.Errorf("%w: %v", ErrUnverifiedResponse, err)
+	}
+	return head, nil
+}
+
+// get performs a GET request for path and decodes the JSON response into v,
+// retrying retryable failures.
+func (c *LogClient) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	u := c.baseURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	backoff := c.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.do(ctx, u, v); err == nil || !retryable(err) || attempt >= c.maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+	}
+}
+
+func (c *LogClient) do(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBodyLength))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,110 @@
+package merkletree
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogClient(t *testing.T) {
+	D := makeEntries(16)
+	tree := New(D[:7])
+	server := httptest.NewServer(NewHandler(tree))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewLogClient(server.URL)
+
+	head, err := client.GetRoot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeHead{TreeSize: 7, RootHash: MTH(D[:7])}, head)
+	witness := NewWitness(head)
+
+	proof, err := client.GetInclusionProof(ctx, 3, 7)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), head.RootHash, proof))
+
+	_, err = client.VerifyLeaf(ctx, leafHash(D[5]), witness)
+	assert.NoError(t, err)
+
+	tree.Append(D[7:]...)
+	newHead, err := client.UpdateWitness(ctx, witness)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeHead{TreeSize: 16, RootHash: MTH(D)}, newHead)
+	assert.Equal(t, newHead, witness.TreeHead())
+
+	// proofs at a historical size still verify against the historical root
+	proof, err = client.GetInclusionProofByHash(ctx, leafHash(D[3]), 7)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), MTH(D[:7]), proof))
+
+	consistency, err := client.GetConsistency(ctx, 7, 16)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyConsistency(MTH(D[:7]), MTH(D), consistency))
+
+	_, err = client.GetInclusionProof(ctx, 16, 16)
+	var httpErr *HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+
+	_, err = client.GetInclusionProofByHash(ctx, leafHash([]byte("missing")), 16)
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
+
+func TestLogClientRejectsBogusProofs(t *testing.T) {
+	D := makeEntries(16)
+	honest := New(D[:7])
+	forged := append(makeEntries(6), []byte("forged"))
+	forked := New(append(forged, D[7:]...))
+
+	// the malicious server serves honest roots but proofs from a forked tree
+	mux := http.NewServeMux()
+	mux.Handle(RootPath, NewHandler(honest))
+	mux.Handle("/proof/", NewHandler(forked))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewLogClient(server.URL)
+	witness := NewWitness(TreeHead{TreeSize: 7, RootHash: MTH(D[:7])})
+
+	_, err := client.VerifyLeaf(ctx, leafHash(D[1]), witness)
+	assert.ErrorIs(t, err, ErrUnverifiedResponse)
+
+	honest.Append(D[7:]...)
+	_, err = client.UpdateWitness(ctx, witness)
+	assert.ErrorIs(t, err, ErrUnverifiedResponse)
+	assert.Equal(t, uint64(7), witness.TreeHead().TreeSize)
+}
+
+func TestLogClientRetries(t *testing.T) {
+	tree := New(makeEntries(7))
+	handler := NewHandler(tree)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	_, err := NewLogClient(server.URL).GetRoot(context.Background())
+	var httpErr *HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+
+	atomic.StoreInt32(&calls, 0)
+	head, err := NewLogClient(server.URL, WithRetries(3, time.Millisecond)).GetRoot(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), head.TreeSize)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,127 @@
+package merkletree
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Errors returned when decoding entries stored WithEntryCodec
+var (
+	ErrCorruptEntry = errors.New("merkletree: stored entry fails to decode")
+	ErrUnknownCodec = errors.New("merkletree: unknown entry codec")
+)
+
+// Codec compresses the entries kept by a tree, see WithEntryCodec. Implementations
+// must be safe for concurrent use.
+type Codec interface {
+	// Name identifies the codec in every encoded entry, so it must not change once
+	// entries are encoded with it
+	Name() string
+	Encode(b []byte) ([]byte, error)
+	Decode(b []byte) ([]byte, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(GzipCodec(gzip.DefaultCompression))
+}
+
+// RegisterCodec makes entries encoded with c decodable by name, replacing a codec
+// registered with the same name. The gzip codec is registered by default.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+type gzipCodec struct {
+	level int
+}
+
+// GzipCodec returns the codec named "gzip", compressing with compress/gzip at the
+// given level
+func GzipCodec(level int) Codec {
+	return gzipCodec{level: level}
+}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c gzipCodec) Encode(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// WithEntryCodec keeps the entries appended with AppendWithExtra encoded with c,
+// typically to compress verbose entries. Leaves are always hashed over the entries
+// as appended, so roots and proofs do not depend on the codec, and GetEntry returns
+// them decoded. Every encoded entry starts with the name of its codec, which must
+// be registered with RegisterCodec to decode it.
+func WithEntryCodec(c Codec) Option {
+	return func(m *MerkleHashTree) {
+		m.codec = c
+	}
+}
+
+// encodeEntry encodes b with c, prefixed with the length of the codec name and the
+// name
+func encodeEntry(c Codec, b []byte) ([]byte, error) {
+	name := c.Name()
+	if len(name) == 0 || len(name) > 255 {
+		return nil, fmt.Errorf("%w: name %q", ErrUnknownCodec, name)
+	}
+	payload, err := c.Encode(b)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, 0, 1+len(name)+len(payload))
+	encoded = append(encoded, byte(len(name)))
+	encoded = append(encoded, name...)
+	return append(encoded, payload...), nil
+}
+
+// decodeEntry decodes an entry encoded by encodeEntry with the codec it names
+func decodeEntry(b []byte) ([]byte, error) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return nil, fmt.Errorf("%w: truncated codec name", ErrCorruptEntry)
+	}
+	name := string(b[1 : 1+b[0]])
+	codecsMu.RLock()
+	c, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	d, err := c.Decode(b[1+len(name):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorruptEntry, name, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,67 @@
+package merkletree
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryCodec(t *testing.T) {
+	plain := New(nil)
+	compressed := New(nil, WithEntryCodec(GzipCodec(gzip.BestCompression)))
+	entries := make([][]byte, 20)
+	for i := range entries {
+		entries[i] = []byte(fmt.Sprintf(`{"index": %d, "payload": %q}`, i, strings.Repeat("verbose ", 100)))
+		extra := bytes.Repeat([]byte{byte(i)}, 500)
+		_, err := plain.AppendWithExtra(entries[i], extra)
+		assert.NoError(t, err)
+		_, err = compressed.AppendWithExtra(entries[i], extra)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, plain.TreeHead(), compressed.TreeHead())
+	assert.Equal(t, New(entries).MerkleRoot(), compressed.MerkleRoot())
+
+	for i, d := range entries {
+		leaf, extra, err := compressed.GetEntry(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, d, leaf)
+		assert.Equal(t, bytes.Repeat([]byte{byte(i)}, 500), extra)
+		assert.Less(t, len(compressed.entries[i].leaf), len(d)/4)
+
+		p, err := compressed.InclusionProofAtSize(uint64(i), compressed.Size())
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(leafHash(leaf), compressed.MerkleRoot(), p))
+	}
+
+	// Tombstones are stored encoded too
+	tomb, err := compressed.Redact(3, nil)
+	assert.NoError(t, err)
+	leaf, _, err := compressed.GetEntry(compressed.Size() - 1)
+	assert.NoError(t, err)
+	assert.Equal(t, TombstoneLeaf(tomb), leaf)
+}
+
+func TestEntryCodecCorruption(t *testing.T) {
+	tree := New(nil, WithEntryCodec(GzipCodec(gzip.DefaultCompression)))
+	for i := 0; i < 3; i++ {
+		_, err := tree.AppendWithExtra([]byte(strings.Repeat("entry ", 50)), []byte("extra"))
+		assert.NoError(t, err)
+	}
+
+	blob := tree.entries[0].leaf
+	blob[len(blob)-12] ^= 1
+	_, _, err := tree.GetEntry(0)
+	assert.ErrorIs(t, err, ErrCorruptEntry)
+
+	tree.entries[1].extra = tree.entries[1].extra[:3]
+	_, _, err = tree.GetEntry(1)
+	assert.ErrorIs(t, err, ErrCorruptEntry)
+
+	tree.entries[2].leaf[1] = 'x'
+	_, _, err = tree.GetEntry(2)
+	assert.ErrorIs(t, err, ErrUnknownCodec)
+}
@@ -0,0 +1,150 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// ShardLeaf returns the entry committing to the tree head of a shard in a
+// super-tree over shards: EncodeLeafFields of the big endian uint64 tree size and
+// the root hash.
+func ShardLeaf(head TreeHead) []byte {
+	size := binary.BigEndian.AppendUint64(nil, head.TreeSize)
+	return EncodeLeafFields(size, head.RootHash[:])
+}
+
+// ComposedProof proves that an entry is included in a shard, and that the shard's
+// tree head is a leaf of a super-tree over shards, whose leaves are ShardLeaf of
+// the shard heads. It pins the shard head the super-tree committed to, so it stays
+// valid against that super-tree root after the shard has grown.
+type ComposedProof struct {
+	ShardHead TreeHead
+	Entry     InclusionProof
+	Shard     InclusionProof
+}
+
+// NewComposedProof returns the composed proof of the entry at index of shard, for
+// the shard head at leaf shardIndex of super. head is the shard head recorded by
+// the super-tree, which may be older than the current state of shard.
+func NewComposedProof(super *MerkleHashTree, shardIndex uint64, shard *MerkleHashTree, head TreeHead, index uint64) (ComposedProof, error) {
+	superLeaf, err := super.LeafHash(shardIndex)
+	if err != nil {
+		return ComposedProof{}, err
+	}
+	if superLeaf != shardLeafHash(head) {
+		return ComposedProof{}, fmt.Errorf("%w: shard head is not leaf %d of the super-tree", ErrRootMismatch, shardIndex)
+	}
+	shardProof, err := super.InclusionProofByIndex(shardIndex)
+	if err != nil {
+		return ComposedProof{}, err
+	}
+
+	defer shard.readLock()()
+	if size := uint64(len(shard.tree[0])); head.TreeSize > size {
+		return ComposedProof{}, fmt.Errorf("%w: shard head of size %d, shard size %d", ErrInvalidRange, head.TreeSize, size)
+	}
+	if shard.rootAtSize(head.TreeSize) != head.RootHash {
+		return ComposedProof{}, fmt.Errorf("%w: shard has another root at size %d", ErrRootMismatch, head.TreeSize)
+	}
+	entry, err := shard.inclusionProofAtSize(index, head.TreeSize)
+	if err != nil {
+		return ComposedProof{}, err
+	}
+	return ComposedProof{ShardHead: head, Entry: entry, Shard: shardProof}, nil
+}
+
+// Verify checks that leafHash is included in the pinned shard head, and that the
+// shard head is included in the super-tree with root superRoot. Only the proof
+// itself is consulted.
+func (p ComposedProof) Verify(leafHash, superRoot [sha256.Size]byte) error {
+	if p.Entry.TreeSize != p.ShardHead.TreeSize {
+		return fmt.Errorf("%w: entry proof for size %d, shard head of size %d", ErrInvalidProof, p.Entry.TreeSize, p.ShardHead.TreeSize)
+	}
+	if err := VerifyInclusion(leafHash, p.ShardHead.RootHash, p.Entry); err != nil {
+		return fmt.Errorf("%w in the shard", err)
+	}
+	if err := VerifyInclusion(shardLeafHash(p.ShardHead), superRoot, p.Shard); err != nil {
+		return fmt.Errorf("%w for the shard in the super-tree", err)
+	}
+	return nil
+}
+
+func shardLeafHash(head TreeHead) [sha256.Size]byte {
+	return leafHash(ShardLeaf(head))
+}
+
+// MarshalBinary encodes p as the big endian uint64 size and the root hash of the
+// shard head, followed by the entry proof and the shard proof. Each proof is its
+// big endian uint64 leaf index and tree size, a byte counting its hashes and the
+// hashes.
+func (p ComposedProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, p.ShardHead.TreeSize)
+	buf.Write(p.ShardHead.RootHash[:])
+	for _, proof := range []InclusionProof{p.Entry, p.Shard} {
+		if err := writeInclusionProof(&buf, proof); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeInclusionProof writes the big endian uint64 leaf index and tree size of p, a
+// byte counting its hashes and the hashes
+func writeInclusionProof(buf *bytes.Buffer, p InclusionProof) error {
+	if len(p.Hashes) > 0xff {
+		return fmt.Errorf("%w: %d hashes", ErrInvalidProofSize, len(p.Hashes))
+	}
+	binary.Write(buf, binary.BigEndian, p.LeafIndex)
+	binary.Write(buf, binary.BigEndian, p.TreeSize)
+	buf.WriteByte(byte(len(p.Hashes)))
+	for _, h := range p.Hashes {
+		buf.Write(h[:])
+	}
+	return nil
+}
+
+// readInclusionProof reads an inclusion proof written by writeInclusionProof into p
+func readInclusionProof(r *bytes.Reader, p *InclusionProof) error {
+	var count byte
+	if err := binary.Read(r, binary.BigEndian, &p.LeafIndex); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &p.TreeSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil || int(count)*sha256.Size > r.Len() {
+		return fmt.Errorf("%w: bad hash count", ErrInvalidProof)
+	}
+	p.Hashes = make([][sha256.Size]byte, count)
+	for i := range p.Hashes {
+		r.Read(p.Hashes[i][:])
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a composed proof encoded by MarshalBinary
+func (p *ComposedProof) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var decoded ComposedProof
+	if err := binary.Read(r, binary.BigEndian, &decoded.ShardHead.TreeSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if n, _ := r.Read(decoded.ShardHead.RootHash[:]); n != sha256.Size {
+		return fmt.Errorf("%w: shard root", ErrInvalidProof)
+	}
+
+	for _, proof := range []*InclusionProof{&decoded.Entry, &decoded.Shard} {
+		if err := readInclusionProof(r, proof); err != nil {
+			return err
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidProof, r.Len())
+	}
+
+	*p = decoded
+	return nil
+}
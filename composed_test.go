@@ -0,0 +1,106 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// shardedLog returns three shards and a super-tree over their current heads
+func shardedLog() ([]*MerkleHashTree, []TreeHead, *MerkleHashTree) {
+	shards := []*MerkleHashTree{New(makeEntries(5)), New(makeEntries(12)), New(makeEntries(1))}
+	heads := make([]TreeHead, len(shards))
+	super := New(nil)
+	for i, shard := range shards {
+		heads[i] = shard.TreeHead()
+		super.Append(ShardLeaf(heads[i]))
+	}
+	return shards, heads, super
+}
+
+func TestComposedProof(t *testing.T) {
+	shards, heads, super := shardedLog()
+	superRoot := super.MerkleRoot()
+
+	for s, shard := range shards {
+		for i := uint64(0); i < heads[s].TreeSize; i++ {
+			p, err := NewComposedProof(super, uint64(s), shard, heads[s], i)
+			assert.NoError(t, err)
+			leaf, _ := shard.LeafHash(i)
+			assert.NoError(t, p.Verify(leaf, superRoot))
+
+			data, err := p.MarshalBinary()
+			assert.NoError(t, err)
+			var decoded ComposedProof
+			assert.NoError(t, decoded.UnmarshalBinary(data))
+			assert.Equal(t, p, decoded)
+			assert.NoError(t, decoded.Verify(leaf, superRoot))
+
+			assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), ErrInvalidProof)
+			assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), ErrInvalidProof)
+		}
+	}
+}
+
+func TestComposedProofAfterShardGrew(t *testing.T) {
+	shards, heads, super := shardedLog()
+	pinned := super.MerkleRoot()
+	before, err := NewComposedProof(super, 1, shards[1], heads[1], 7)
+	assert.NoError(t, err)
+
+	// The shard rotates its root, and the super-tree records the new head
+	shards[1].Append(makeEntries(4)...)
+	newHead := shards[1].TreeHead()
+	super.Append(ShardLeaf(newHead))
+
+	leaf, _ := shards[1].LeafHash(7)
+	assert.NoError(t, before.Verify(leaf, pinned))
+
+	// Proofs are still issued for the pinned head from the grown shard
+	after, err := NewComposedProof(super, 1, shards[1], heads[1], 7)
+	assert.NoError(t, err)
+	assert.Equal(t, before.Entry, after.Entry)
+	assert.NoError(t, after.Verify(leaf, super.MerkleRoot()))
+
+	current, err := NewComposedProof(super, 3, shards[1], newHead, 7)
+	assert.NoError(t, err)
+	assert.NoError(t, current.Verify(leaf, super.MerkleRoot()))
+	assert.ErrorIs(t, current.Verify(leaf, pinned), ErrRootMismatch)
+
+	_, err = NewComposedProof(super, 1, shards[1], newHead, 7)
+	assert.ErrorIs(t, err, ErrRootMismatch)
+	_, err = NewComposedProof(super, 1, New(makeEntries(3)), heads[1], 2)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestComposedProofTampered(t *testing.T) {
+	shards, heads, super := shardedLog()
+	superRoot := super.MerkleRoot()
+	p, err := NewComposedProof(super, 1, shards[1], heads[1], 9)
+	assert.NoError(t, err)
+	leaf, _ := shards[1].LeafHash(9)
+
+	other, _ := shards[1].LeafHash(8)
+	assert.ErrorIs(t, p.Verify(other, superRoot), ErrRootMismatch)
+
+	tampered := p
+	tampered.Entry.Hashes = append([][32]byte(nil), p.Entry.Hashes...)
+	tampered.Entry.Hashes[0][0] ^= 1
+	assert.ErrorIs(t, tampered.Verify(leaf, superRoot), ErrRootMismatch)
+
+	tampered = p
+	tampered.Shard.Hashes = append([][32]byte(nil), p.Shard.Hashes...)
+	tampered.Shard.Hashes[1][0] ^= 1
+	assert.ErrorIs(t, tampered.Verify(leaf, superRoot), ErrRootMismatch)
+
+	// A shard head of another size does not match the super-tree leaf
+	tampered = p
+	tampered.ShardHead.TreeSize++
+	assert.ErrorIs(t, tampered.Verify(leaf, superRoot), ErrInvalidProof)
+	tampered.Entry.TreeSize++
+	assert.Error(t, tampered.Verify(leaf, superRoot))
+
+	tampered = p
+	tampered.ShardHead.RootHash = heads[0].RootHash
+	assert.ErrorIs(t, tampered.Verify(leaf, superRoot), ErrRootMismatch)
+}
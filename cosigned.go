@@ -0,0 +1,200 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned when signing and verifying cosigned checkpoints
+var (
+	ErrQuorumNotMet   = errors.New("merkletree: too few valid checkpoint signatures")
+	ErrUnsupportedKey = errors.New("merkletree: unsupported key, want an ECDSA or Ed25519 key")
+)
+
+// CheckpointSignature is a signature line of a signed note: the name of the key,
+// the first four bytes of the SHA-256 of its PKIX encoding, and the signature of
+// the note text.
+type CheckpointSignature struct {
+	Name      string
+	KeyID     [4]byte
+	Signature []byte
+}
+
+// CosignedCheckpoint is a checkpoint in the signed note format signed by the log
+// and countersigned by any number of witnesses. Signatures are made and checked as
+// for VerifyRekorCheckpoint, and all cover the same note text committing to the
+// origin, size and root of the checkpoint. Timestamps are optional attestations
+// by external authorities that the note text existed at some time.
+type CosignedCheckpoint struct {
+	RekorCheckpoint
+	Signatures []CheckpointSignature
+	Timestamps []TimestampToken
+}
+
+// NewCosignedCheckpoint returns an unsigned checkpoint of head for the log origin,
+// with extension lines other
+func NewCosignedCheckpoint(origin string, head TreeHead, other ...string) *CosignedCheckpoint {
+	return &CosignedCheckpoint{
+		RekorCheckpoint: RekorCheckpoint{Origin: origin, TreeHead: head, Other: other},
+		Signatures:      make([]CheckpointSignature, 0),
+	}
+}
+
+// ParseCosignedCheckpoint parses a checkpoint in the signed note format with all
+// of its signatures. Signatures are not verified.
+func ParseCosignedCheckpoint(note string) (*CosignedCheckpoint, error) {
+	body, _, sigs, err := ParseRekorCheckpoint(note)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &CosignedCheckpoint{RekorCheckpoint: body, Signatures: make([]CheckpointSignature, 0, len(sigs))}
+	for _, line := range sigs {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: malformed signature line", ErrInvalidCheckpoint)
+		}
+		b, err := base64.StdEncoding.DecodeString(fields[1])
+		if authority := strings.TrimPrefix(fields[0], timestampNamePrefix); authority != fields[0] && err == nil && authority != "" && len(b) > 0 {
+			c.Timestamps = append(c.Timestamps, TimestampToken{Authority: authority, Token: b})
+			continue
+		}
+		if err != nil || len(b) < 5 {
+			return nil, fmt.Errorf("%w: malformed signature of %s", ErrInvalidCheckpoint, fields[0])
+		}
+		sig := CheckpointSignature{Name: fields[0], Signature: b[4:]}
+		copy(sig.KeyID[:], b)
+		c.Signatures = append(c.Signatures, sig)
+	}
+	return c, nil
+}
+
+// Text returns the note text covered by the signatures
+func (c *CosignedCheckpoint) Text() string {
+	var b strings.Builder
+	b.WriteString(c.Origin + "\n")
+	b.WriteString(strconv.FormatUint(c.TreeSize, 10) + "\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(c.RootHash[:]) + "\n")
+	for _, line := range c.Other {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// String returns the checkpoint in the signed note format
+func (c *CosignedCheckpoint) String() string {
+	var b strings.Builder
+	b.WriteString(c.Text() + "\n")
+	for _, sig := range c.Signatures {
+		line := base64.StdEncoding.EncodeToString(append(sig.KeyID[:], sig.Signature...))
+		b.WriteString("— " + sig.Name + " " + line + "\n")
+	}
+	for _, ts := range c.Timestamps {
+		b.WriteString("— " + timestampNamePrefix + ts.Authority + " " + base64.StdEncoding.EncodeToString(ts.Token) + "\n")
+	}
+	return b.String()
+}
+
+// MarshalText returns the checkpoint in the signed note format
+func (c *CosignedCheckpoint) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses a checkpoint in the signed note format into c
+func (c *CosignedCheckpoint) UnmarshalText(text []byte) error {
+	parsed, err := ParseCosignedCheckpoint(string(text))
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+// AddSignature signs the note text with signer, an ECDSA or Ed25519 key, and adds
+// the signature under name
+func (c *CosignedCheckpoint) AddSignature(name string, signer crypto.Signer) error {
+	if name == "" || strings.ContainsAny(name, " \n+") {
+		return fmt.Errorf("%w: invalid key name %q", ErrInvalidCheckpoint, name)
+	}
+	keyID, err := noteKeyID(signer.Public())
+	if err != nil {
+		return err
+	}
+
+	sig, err := signNote(signer, []byte(c.Text()))
+	if err != nil {
+		return err
+	}
+
+	c.Signatures = append(c.Signatures, CheckpointSignature{Name: name, KeyID: keyID, Signature: sig})
+	return nil
+}
+
+// VerifyThreshold checks that at least quorum distinct keys of pubkeys, by key
+// name, have valid signatures of the checkpoint. Signatures of unknown names or by
+// keys not matching their name are ignored, and every key counts once however many
+// names or signatures it has.
+func (c *CosignedCheckpoint) VerifyThreshold(pubkeys map[string]crypto.PublicKey, quorum int) error {
+	if quorum < 1 {
+		return fmt.Errorf("%w: quorum %d", ErrQuorumNotMet, quorum)
+	}
+
+	text := []byte(c.Text())
+	signed := make(map[string]bool)
+	for _, sig := range c.Signatures {
+		pub, ok := pubkeys[sig.Name]
+		if !ok {
+			continue
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil || signed[string(der)] {
+			continue
+		}
+		if keyHash := sha256.Sum256(der); !bytes.Equal(keyHash[:4], sig.KeyID[:]) {
+			continue
+		}
+		if verifyNoteSignature(pub, text, sig.Signature) {
+			signed[string(der)] = true
+		}
+	}
+
+	if len(signed) < quorum {
+		return fmt.Errorf("%w: %d of %d", ErrQuorumNotMet, len(signed), quorum)
+	}
+	return nil
+}
+
+// signNote signs text as verifyNoteSignature expects, with ECDSA over its SHA-256
+// or with Ed25519 over text itself
+func signNote(signer crypto.Signer, text []byte) ([]byte, error) {
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, text, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(text)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, signer.Public())
+}
+
+// noteKeyID returns the first four bytes of the SHA-256 of the PKIX encoding of pub
+func noteKeyID(pub crypto.PublicKey) ([4]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return [4]byte{}, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+	}
+	keyHash := sha256.Sum256(der)
+	var id [4]byte
+	copy(id[:], keyHash[:])
+	return id, nil
+}
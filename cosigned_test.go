@@ -0,0 +1,113 @@
+package merkletree
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cosigners(t *testing.T) ([]crypto.Signer, map[string]crypto.PublicKey) {
+	signers := make([]crypto.Signer, 0, 4)
+	for i := 0; i < 2; i++ {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		signers = append(signers, ecKey, edKey)
+	}
+	pubkeys := map[string]crypto.PublicKey{
+		"log":       signers[0].Public(),
+		"witness-a": signers[1].Public(),
+		"witness-b": signers[2].Public(),
+		"witness-c": signers[3].Public(),
+	}
+	return signers, pubkeys
+}
+
+func TestCosignedCheckpointVerifyThreshold(t *testing.T) {
+	signers, pubkeys := cosigners(t)
+	head := New(makeEntries(9)).TreeHead()
+	c := NewCosignedCheckpoint("example.com/log", head, "Timestamp: 1689748607742585419")
+	assert.NoError(t, c.AddSignature("log", signers[0]))
+	assert.NoError(t, c.AddSignature("witness-a", signers[1]))
+	assert.NoError(t, c.AddSignature("witness-b", signers[2]))
+
+	assert.NoError(t, c.VerifyThreshold(pubkeys, 3))
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 4), ErrQuorumNotMet)
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 0), ErrQuorumNotMet)
+
+	// The log signature still verifies as a Rekor checkpoint
+	_, err := VerifyRekorCheckpoint(c.String(), signers[0].Public())
+	assert.NoError(t, err)
+
+	// Unknown signers and signatures by keys not matching their name do not count
+	_, unknown, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	assert.NoError(t, c.AddSignature("stranger", unknown))
+	assert.NoError(t, c.AddSignature("witness-c", signers[1]))
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 4), ErrQuorumNotMet)
+}
+
+func TestCosignedCheckpointDuplicateKeys(t *testing.T) {
+	signers, pubkeys := cosigners(t)
+	c := NewCosignedCheckpoint("example.com/log", New(makeEntries(3)).TreeHead())
+	assert.NoError(t, c.AddSignature("witness-a", signers[1]))
+	assert.NoError(t, c.AddSignature("witness-a", signers[1]))
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 2), ErrQuorumNotMet)
+
+	// One key under two names counts once
+	pubkeys["alias"] = signers[1].Public()
+	assert.NoError(t, c.AddSignature("alias", signers[1]))
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 2), ErrQuorumNotMet)
+	assert.NoError(t, c.VerifyThreshold(pubkeys, 1))
+}
+
+func TestCosignedCheckpointOtherRoot(t *testing.T) {
+	signers, pubkeys := cosigners(t)
+	head := New(makeEntries(9)).TreeHead()
+	c := NewCosignedCheckpoint("example.com/log", head)
+	assert.NoError(t, c.AddSignature("log", signers[0]))
+
+	other := NewCosignedCheckpoint("example.com/log", TreeHead{TreeSize: 9, RootHash: MTH(makeEntries(10)[1:])})
+	assert.NoError(t, other.AddSignature("witness-a", signers[1]))
+	assert.NoError(t, other.AddSignature("witness-b", signers[2]))
+
+	// Signatures over another root do not count towards the quorum
+	c.Signatures = append(c.Signatures, other.Signatures...)
+	assert.NoError(t, c.VerifyThreshold(pubkeys, 1))
+	assert.ErrorIs(t, c.VerifyThreshold(pubkeys, 2), ErrQuorumNotMet)
+	assert.NoError(t, other.VerifyThreshold(pubkeys, 2))
+}
+
+func TestCosignedCheckpointRoundTrip(t *testing.T) {
+	signers, pubkeys := cosigners(t)
+	c := NewCosignedCheckpoint("example.com/log", New(makeEntries(5)).TreeHead(), "Timestamp: 1", "extra")
+	for i, name := range []string{"log", "witness-a", "witness-b", "witness-c"} {
+		assert.NoError(t, c.AddSignature(name, signers[i]))
+	}
+
+	text, err := c.MarshalText()
+	assert.NoError(t, err)
+	var decoded CosignedCheckpoint
+	assert.NoError(t, decoded.UnmarshalText(text))
+	assert.Equal(t, *c, decoded)
+	assert.NoError(t, decoded.VerifyThreshold(pubkeys, 4))
+
+	parsed, err := ParseCosignedCheckpoint(string(text))
+	assert.NoError(t, err)
+	assert.Equal(t, c.String(), parsed.String())
+
+	_, err = ParseCosignedCheckpoint(c.Text() + "\n— log AAAA\n")
+	assert.ErrorIs(t, err, ErrInvalidCheckpoint)
+	assert.ErrorIs(t, c.AddSignature("two words", signers[0]), ErrInvalidCheckpoint)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, c.AddSignature("rsa", rsaKey), ErrUnsupportedKey)
+}
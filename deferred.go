@@ -0,0 +1,44 @@
+package merkletree
+
+import "fmt"
+
+// deferEntries buffers copies of the entries d when the tree has room for them,
+// sealing the tree once it is full when WithSealWhenFull is set.
+func (m *MerkleHashTree) deferEntries(d [][]byte) error {
+	if len(d) == 0 {
+		return nil
+	}
+	if m.sealed {
+		return ErrSealed
+	}
+	if remaining := m.remaining(); uint64(len(d)) > remaining {
+		return fmt.Errorf("%w: %d leaves, %d remaining", ErrLogFull, len(d), remaining)
+	}
+	if err := m.admit(uint64(len(m.tree[0])+len(m.pending)), d); err != nil {
+		return err
+	}
+
+	m.generation++
+	for _, e := range d {
+		m.pending = append(m.pending, append([]byte(nil), e...))
+	}
+	if m.sealWhenFull && m.remaining() == 0 {
+		m.sealed = true
+	}
+	return nil
+}
+
+// hashPending hashes the buffered entries and appends them to the tree
+func (m *MerkleHashTree) hashPending() {
+	if len(m.pending) == 0 {
+		return
+	}
+
+	leaves := m.hashLeaves(uint64(len(m.tree[0])), m.pending)
+	m.pending = nil
+
+	// The appends were counted when their entries were buffered.
+	generation := m.generation
+	m.appendLeafHashes(leaves)
+	m.generation = generation
+}
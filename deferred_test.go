@@ -0,0 +1,127 @@
+package merkletree
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferredHashingMatchesEager(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithIndexBoundLeaves()}, {WithHashBackend(ParallelBackend(4))}} {
+		rng := rand.New(rand.NewSource(1))
+		D := makeEntries(300)
+		eager := New(D[:5], opts...)
+		deferred := New(D[:5], append([]Option{WithDeferredHashing()}, opts...)...)
+
+		for next := 5; next < len(D); {
+			n := rng.Intn(10)
+			if next+n > len(D) {
+				n = len(D) - next
+			}
+			eager.Append(D[next : next+n]...)
+			root, err := deferred.TryAppend(D[next : next+n]...)
+			assert.NoError(t, err)
+			assert.Equal(t, [32]byte{}, root)
+			next += n
+
+			if rng.Intn(4) == 0 {
+				assert.Equal(t, eager.TreeHead(), deferred.TreeHead())
+				i := uint64(rng.Intn(next))
+				expected, err := eager.InclusionProofByIndex(i)
+				assert.NoError(t, err)
+				p, err := deferred.InclusionProofByIndex(i)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, p)
+			}
+		}
+		assert.Equal(t, eager.MerkleRoot(), deferred.MerkleRoot())
+	}
+}
+
+func TestDeferredHashingCopiesEntries(t *testing.T) {
+	tree := New(nil, WithDeferredHashing())
+	d := []byte("entry")
+	tree.Append(d)
+	d[0] = 'E'
+	assert.Equal(t, MTH([][]byte{[]byte("entry")}), tree.MerkleRoot())
+}
+
+func TestDeferredHashingCapacity(t *testing.T) {
+	D := makeEntries(5)
+	tree := New(nil, WithDeferredHashing(), WithMaxLeaves(4), WithSealWhenFull())
+
+	_, err := tree.TryAppend(D[:3]...)
+	assert.NoError(t, err)
+	_, err = tree.TryAppend(D[3:5]...)
+	assert.ErrorIs(t, err, ErrLogFull)
+	_, err = tree.TryAppend(D[3])
+	assert.NoError(t, err)
+	_, err = tree.TryAppend(D[4])
+	assert.ErrorIs(t, err, ErrSealed)
+
+	assert.Equal(t, uint64(0), tree.Remaining())
+	assert.Equal(t, MTH(D[:4]), tree.MerkleRoot())
+}
+
+func TestDeferredHashingConcurrent(t *testing.T) {
+	D := makeEntries(400)
+	tree := New(nil, WithDeferredHashing(), WithLocking())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(D); i += 4 {
+				tree.Append(D[i])
+				if i%7 == 0 {
+					tree.MerkleRoot()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(len(D)), tree.Size())
+	for i := range D {
+		p, err := tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+		leaf, err := tree.LeafHash(uint64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(leaf, tree.MerkleRoot(), p))
+	}
+}
+
+// benchmarkAppendLatency appends single entries to a tree of 64k leaves, reading
+// the root every 1000 appends, and reports the 99th percentile of the latency of
+// an append.
+func benchmarkAppendLatency(b *testing.B, opts ...Option) {
+	tree := New(makeEntries(1<<16), opts...)
+	entry := []byte("entry")
+	latencies := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		tree.Append(entry)
+		latencies[i] = time.Since(start)
+		if i%1000 == 999 {
+			tree.MerkleRoot()
+		}
+	}
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
+
+func BenchmarkAppendLatencyEager(b *testing.B) {
+	benchmarkAppendLatency(b)
+}
+
+func BenchmarkAppendLatencyDeferred(b *testing.B) {
+	benchmarkAppendLatency(b, WithDeferredHashing())
+}
@@ -0,0 +1,123 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDelta is returned when node deltas do not extend the levels of a tree
+var ErrInvalidDelta = errors.New("merkletree: node delta does not extend the tree")
+
+// NodeDelta is a node of the tree created or overwritten by an append. Level 0 holds
+// the leaves, and the node at Index of a level covers the leaves
+// [Index*2^Level, min((Index+1)*2^Level, size)).
+type NodeDelta struct {
+	Level uint64
+	Index uint64
+	Hash  [sha256.Size]byte
+}
+
+// LastAppendDelta returns the nodes created or overwritten by the last append, level
+// by level from the leaves up: the new leaves, the nodes of the right edge of the tree
+// that now cover new leaves, and the nodes of any new levels. It returns nil unless the
+// tree was created WithNodeDeltas, and after Truncate or SetLeaf, which rewrite the tree.
+func (m *MerkleHashTree) LastAppendDelta() []NodeDelta {
+	defer m.readLock()()
+	return append([]NodeDelta(nil), m.lastDelta...)
+}
+
+// recordDelta records the nodes covering leaves from index oldSize on. Nodes covering
+// only older leaves commit to the same range as before the append and are unchanged.
+func (m *MerkleHashTree) recordDelta(oldSize uint64) {
+	m.lastDelta = m.lastDelta[:0]
+	for level := range m.tree {
+		for i := oldSize >> uint(level); i < uint64(len(m.tree[level])); i++ {
+			m.lastDelta = append(m.lastDelta, NodeDelta{Level: uint64(level), Index: i, Hash: m.tree[level][i]})
+		}
+	}
+}
+
+// ApplyNodeDeltas applies the deltas of an append to the tree, such as a copy of the
+// tree restored from storage before the append, and returns the new merkle root. The
+// leaves of the deltas are appended as AppendLeafHashes does, and the nodes above them
+// are recomputed: every delta must be a leaf following the previous one or one of the
+// recomputed nodes, and head the tree head after the append. Deltas that do not match
+// fail with ErrInvalidDelta, and a head of another root with ErrRootMismatch, before
+// the tree is changed.
+func (m *MerkleHashTree) ApplyNodeDeltas(head TreeHead, deltas []NodeDelta) ([sha256.Size]byte, error) {
+	defer m.writeLock()()
+
+	size := uint64(len(m.tree[0]))
+	var leaves [][sha256.Size]byte
+	for _, d := range deltas {
+		if d.Level != 0 {
+			continue
+		}
+		if next := size + uint64(len(leaves)); d.Index != next {
+			return m.root(), fmt.Errorf("%w: leaf %d, expected leaf %d", ErrInvalidDelta, d.Index, next)
+		}
+		leaves = append(leaves, d.Hash)
+	}
+	newSize := size + uint64(len(leaves))
+	if head.TreeSize != newSize {
+		return m.root(), fmt.Errorf("%w: tree head of size %d, deltas to size %d", ErrInvalidDelta, head.TreeSize, newSize)
+	}
+
+	expected := make(map[NodeID][sha256.Size]byte)
+	for _, d := range m.appendDelta(size, leaves) {
+		expected[NodeID{Level: d.Level, Index: d.Index}] = d.Hash
+	}
+	for _, d := range deltas {
+		id := NodeID{Level: d.Level, Index: d.Index}
+		if hash, ok := expected[id]; !ok || hash != d.Hash {
+			return m.root(), fmt.Errorf("%w: node %d at level %d", ErrInvalidDelta, d.Index, d.Level)
+		}
+		delete(expected, id)
+	}
+	if len(expected) > 0 {
+		return m.root(), fmt.Errorf("%w: %d nodes missing", ErrInvalidDelta, len(expected))
+	}
+
+	frontier := m.frontier(size)
+	for i, leaf := range leaves {
+		frontier = frontierAppendWith(m.nodeHash, frontier, size+uint64(i), leaf)
+	}
+	if root := frontierRootWith(m.nodeHash, m.emptyRoot(), frontier); root != head.RootHash {
+		return m.root(), fmt.Errorf("%w: deltas give root %x at size %d", ErrRootMismatch, root, newSize)
+	}
+	return m.admitLeafHashes(leaves)
+}
+
+// appendDelta returns the deltas recordDelta would record after appending leaves to
+// the tree of oldSize leaves, without changing the tree. As in extendTree, a node
+// with no right child is carried up unchanged and not stored.
+func (m *MerkleHashTree) appendDelta(oldSize uint64, leaves [][sha256.Size]byte) []NodeDelta {
+	var delta []NodeDelta
+	for i, leaf := range leaves {
+		delta = append(delta, NodeDelta{Index: oldSize + uint64(i), Hash: leaf})
+	}
+
+	below, belowFirst := leaves, oldSize
+	for level, count := uint64(1), oldSize+uint64(len(leaves)); count > 1; level++ {
+		child := func(i uint64) [sha256.Size]byte {
+			if i >= belowFirst {
+				return below[i-belowFirst]
+			}
+			return m.tree[level-1][i]
+		}
+		start := oldSize >> level
+		nodes := make([][sha256.Size]byte, 0, (count+1)/2-start)
+		for i := start; i < (count+1)/2; i++ {
+			if 2*i+1 == count {
+				nodes = append(nodes, child(2*i))
+				continue
+			}
+			hash := m.nodeHash(child(2*i), child(2*i+1))
+			nodes = append(nodes, hash)
+			delta = append(delta, NodeDelta{Level: level, Index: i, Hash: hash})
+		}
+		below, belowFirst, count = nodes, start, (count+1)/2
+	}
+	return delta
+}
@@ -0,0 +1,103 @@
+package merkletree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeDeltasReplay(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	D := makeEntries(200)
+	tree := New(nil, WithNodeDeltas())
+	replica := New(nil)
+
+	for next := 0; next < len(D); {
+		n := 1 + rng.Intn(9)
+		if next+n > len(D) {
+			n = len(D) - next
+		}
+		tree.Append(D[next : next+n]...)
+		next += n
+
+		root, err := replica.ApplyNodeDeltas(tree.TreeHead(), tree.LastAppendDelta())
+		assert.NoError(t, err)
+		assert.Equal(t, tree.MerkleRoot(), root)
+		assert.Equal(t, tree.tree, replica.tree, "size %d", next)
+	}
+}
+
+func TestLastAppendDelta(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D[:5], WithNodeDeltas())
+	assert.Nil(t, tree.LastAppendDelta())
+
+	// Appending leaf 5 to 5 leaves creates the leaf and its parent (1, 2), which
+	// replaces leaf 4 as the right child of the root (3, 0).
+	tree.Append(D[5])
+	delta := tree.LastAppendDelta()
+	assert.Equal(t, []NodeDelta{
+		{Level: 0, Index: 5, Hash: tree.tree[0][5]},
+		{Level: 1, Index: 2, Hash: tree.tree[1][2]},
+		{Level: 3, Index: 0, Hash: tree.tree[3][0]},
+	}, delta)
+	assert.Equal(t, MTH(D[:6]), delta[len(delta)-1].Hash)
+
+	tree.Truncate(4)
+	assert.Nil(t, tree.LastAppendDelta())
+	assert.Nil(t, New(D).LastAppendDelta())
+}
+
+func TestApplyNodeDeltasRejectsGaps(t *testing.T) {
+	D := makeEntries(4)
+	tree := New(D[:2])
+	before := tree.MerkleRoot()
+	head := TreeHead{TreeSize: 3, RootHash: MTH(D[:3])}
+
+	_, err := tree.ApplyNodeDeltas(head, []NodeDelta{{Level: 0, Index: 2}, {Level: 0, Index: 4}})
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	_, err = tree.ApplyNodeDeltas(head, []NodeDelta{{Level: 3, Index: 0}})
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	assert.Equal(t, before, tree.MerkleRoot())
+	assert.Equal(t, uint64(2), tree.Size())
+}
+
+func TestApplyNodeDeltasRejectsTampering(t *testing.T) {
+	D := makeEntries(6)
+	primary := New(D[:3], WithNodeDeltas())
+	primary.Append(D[3:]...)
+	head, delta := primary.TreeHead(), primary.LastAppendDelta()
+
+	replica := New(D[:3], WithNodeDeltas())
+	proof, err := replica.InclusionProofByIndex(1)
+	assert.NoError(t, err)
+	before := replica.TreeHead()
+
+	// A forged leaf comes with the nodes and head it hashes to, so only the head
+	// of the primary shows it
+	forged := New(D[:3], WithNodeDeltas())
+	forged.Append(D[3], []byte("forged"), D[5])
+	_, err = replica.ApplyNodeDeltas(head, forged.LastAppendDelta())
+	assert.ErrorIs(t, err, ErrRootMismatch)
+
+	tampered := append([]NodeDelta(nil), delta...)
+	tampered[len(tampered)-1].Hash[0] ^= 1
+	_, err = replica.ApplyNodeDeltas(head, tampered)
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	_, err = replica.ApplyNodeDeltas(head, delta[:len(delta)-1])
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	_, err = replica.ApplyNodeDeltas(TreeHead{TreeSize: 5, RootHash: head.RootHash}, delta)
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+
+	assert.Equal(t, before, replica.TreeHead())
+	assert.Nil(t, replica.LastAppendDelta())
+	cached, err := replica.InclusionProofByIndex(1)
+	assert.NoError(t, err)
+	assert.Equal(t, proof, cached)
+
+	root, err := replica.ApplyNodeDeltas(head, delta)
+	assert.NoError(t, err)
+	assert.Equal(t, head.RootHash, root)
+	assert.Equal(t, delta, replica.LastAppendDelta())
+}
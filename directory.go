@@ -0,0 +1,232 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestVersion is the version of the manifest format written by WriteManifest.
+// Version 2 encodes the leaves of files with EncodeLeafFields.
+const ManifestVersion = 2
+
+// ErrFileMismatch is returned when a file does not match its manifest entry
+var ErrFileMismatch = errors.New("merkletree: file does not match manifest entry")
+
+// DirectoryFile describes a regular file included in a directory tree
+type DirectoryFile struct {
+	// Path is the slash separated path of the file relative to the directory root
+	Path   string
+	Size   uint64
+	SHA256 [sha256.Size]byte
+}
+
+// DirectoryTree is a merkle hash tree over the regular files of a directory.
+// Files are ordered by their slash separated relative path, compared bytewise,
+// and leaf i commits to Files[i] as encoded by directoryLeaf.
+type DirectoryTree struct {
+	Tree  *MerkleHashTree
+	Files []DirectoryFile
+}
+
+// directoryLeaf returns the leaf data for a file: EncodeLeafFields of the path, the
+// big endian uint64 size and the SHA-256 of the content.
+func directoryLeaf(f DirectoryFile) []byte {
+	size := binary.BigEndian.AppendUint64(nil, f.Size)
+	return EncodeLeafFields([]byte(f.Path), size, f.SHA256[:])
+}
+
+// NewFromDirectory builds a merkle hash tree over the regular files below root.
+// Directories, symlinks and other non regular files are skipped. Manifest entries
+// are verified with the leaf and node hashes of RFC 6962 over SHA-256, so options
+// changing them, WithHasher and WithIndexBoundLeaves, fail with ErrUnsupportedHash.
+func NewFromDirectory(root string, opts ...Option) (*DirectoryTree, error) {
+	files := make([]DirectoryFile, 0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		f.Path = filepath.ToSlash(rel)
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// WalkDir orders entries per directory, which differs from ordering full paths
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	leaves := make([][]byte, 0, len(files))
+	for _, f := range files {
+		leaves = append(leaves, directoryLeaf(f))
+	}
+	tree, err := TryNew(leaves, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if name := tree.algorithm(); name != "" {
+		return nil, fmt.Errorf("%w: directory tree over %s", ErrUnsupportedHash, name)
+	}
+	if tree.indexBound {
+		return nil, fmt.Errorf("%w: directory tree of index bound leaves", ErrUnsupportedHash)
+	}
+	return &DirectoryTree{Tree: tree, Files: files}, nil
+}
+
+func hashFile(path string) (DirectoryFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return DirectoryFile{}, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return DirectoryFile{}, err
+	}
+
+	f := DirectoryFile{Size: uint64(n)}
+	copy(f.SHA256[:], h.Sum(nil))
+	return f, nil
+}
+
+// Manifest is the JSON document written by WriteManifest:
+//
+//	{
+//	  "version": 2,
+//	  "root": "<hex merkle root>",
+//	  "tree_size": <number of files>,
+//	  "files": [
+//	    {
+//	      "path": "<slash separated relative path>",
+//	      "size": <file size in bytes>,
+//	      "sha256": "<hex SHA-256 of the content>",
+//	      "leaf_index": <index of the file's leaf>,
+//	      "tree_size": <number of files>,
+//	      "inclusion_proof": ["<hex hash>", ...]
+//	    }
+//	  ]
+//	}
+//
+// All hashes are lowercase hex. Files are listed in leaf order.
+type Manifest struct {
+	Version  int             `json:"version"`
+	Root     string          `json:"root"`
+	TreeSize uint64          `json:"tree_size"`
+	Files    []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes a single file of a manifest together with its inclusion proof
+type ManifestEntry struct {
+	Path           string   `json:"path"`
+	Size           uint64   `json:"size"`
+	SHA256         string   `json:"sha256"`
+	LeafIndex      uint64   `json:"leaf_index"`
+	TreeSize       uint64   `json:"tree_size"`
+	InclusionProof []string `json:"inclusion_proof"`
+}
+
+// WriteManifest writes the JSON manifest of the directory tree to w
+func (d *DirectoryTree) WriteManifest(w io.Writer) error {
+	root := d.Tree.MerkleRoot()
+	manifest := Manifest{
+		Version:  ManifestVersion,
+		Root:     hex.EncodeToString(root[:]),
+		TreeSize: uint64(len(d.Files)),
+		Files:    make([]ManifestEntry, 0, len(d.Files)),
+	}
+
+	for i, f := range d.Files {
+		proof, err := d.Tree.InclusionProofByIndex(uint64(i))
+		if err != nil {
+			return err
+		}
+
+		entry := ManifestEntry{
+			Path:           f.Path,
+			Size:           f.Size,
+			SHA256:         hex.EncodeToString(f.SHA256[:]),
+			LeafIndex:      proof.LeafIndex,
+			TreeSize:       proof.TreeSize,
+			InclusionProof: make([]string, 0, len(proof.Hashes)),
+		}
+		for _, h := range proof.Hashes {
+			entry.InclusionProof = append(entry.InclusionProof, hex.EncodeToString(h[:]))
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+// VerifyManifestEntry checks that entry is included in the directory tree with the given root
+func VerifyManifestEntry(root [sha256.Size]byte, entry ManifestEntry) error {
+	f := DirectoryFile{Path: entry.Path, Size: entry.Size}
+	if err := decodeHexHash(entry.SHA256, &f.SHA256); err != nil {
+		return err
+	}
+
+	proof := InclusionProof{
+		LeafIndex: entry.LeafIndex,
+		TreeSize:  entry.TreeSize,
+		Hashes:    make([][sha256.Size]byte, len(entry.InclusionProof)),
+	}
+	for i, h := range entry.InclusionProof {
+		if err := decodeHexHash(h, &proof.Hashes[i]); err != nil {
+			return err
+		}
+	}
+
+	return VerifyInclusion(leafHash(directoryLeaf(f)), root, proof)
+}
+
+// VerifyManifestFile checks that the content read from r matches entry and that
+// entry is included in the directory tree with the given root.
+func VerifyManifestFile(root [sha256.Size]byte, entry ManifestEntry, r io.Reader) error {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+
+	if uint64(n) != entry.Size || hex.EncodeToString(h.Sum(nil)) != entry.SHA256 {
+		return fmt.Errorf("%w: %s", ErrFileMismatch, entry.Path)
+	}
+	return VerifyManifestEntry(root, entry)
+}
+
+func decodeHexHash(s string, h *[sha256.Size]byte) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != sha256.Size {
+		return fmt.Errorf("merkletree: invalid hash length %d", len(b))
+	}
+	copy(h[:], b)
+	return nil
+}
@@ -0,0 +1,73 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectoryManifest(t *testing.T) {
+	dir := filepath.Join("testdata", "release")
+	tree, err := NewFromDirectory(dir)
+	assert.NoError(t, err)
+
+	// "docs.txt" sorts before "docs/usage.txt" when full paths are compared
+	paths := make([]string, 0)
+	for _, f := range tree.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"README", "bin/tool", "docs.txt", "docs/usage.txt"}, paths)
+
+	var buf bytes.Buffer
+	assert.NoError(t, tree.WriteManifest(&buf))
+
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &manifest))
+	assert.Equal(t, ManifestVersion, manifest.Version)
+	assert.Len(t, manifest.Files, 4)
+
+	root := tree.Tree.MerkleRoot()
+	assert.Equal(t, hex.EncodeToString(root[:]), manifest.Root)
+	for _, entry := range manifest.Files {
+		assert.NoError(t, VerifyManifestEntry(root, entry))
+
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyManifestFile(root, entry, bytes.NewReader(content)))
+	}
+
+	entry := manifest.Files[2]
+	assert.ErrorIs(t, VerifyManifestFile(root, entry, strings.NewReader("changelog\nextra\n")), ErrFileMismatch)
+
+	// a manifest entry rewritten to match a modified file no longer verifies
+	modified := entry
+	modified.Size = uint64(len("tampered\n"))
+	sum := leafHash([]byte("unused"))
+	modified.SHA256 = hex.EncodeToString(sum[:])
+	assert.ErrorIs(t, VerifyManifestEntry(root, modified), ErrRootMismatch)
+}
+
+func TestDirectoryRejectsHashingOptions(t *testing.T) {
+	dir := filepath.Join("testdata", "release")
+	_, err := NewFromDirectory(dir, WithHasher(newSHA512_256Hasher(t)))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	_, err = NewFromDirectory(dir, WithIndexBoundLeaves())
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	// Other options keep the manifest verifiable
+	tree, err := NewFromDirectory(dir, WithProofCache(4), WithLocking())
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, tree.WriteManifest(&buf))
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &manifest))
+	for _, entry := range manifest.Files {
+		assert.NoError(t, VerifyManifestEntry(tree.Tree.MerkleRoot(), entry))
+	}
+}
@@ -0,0 +1,203 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned when saving or loading encrypted trees
+var (
+	ErrInvalidKey     = errors.New("merkletree: encryption key must be 32 bytes")
+	ErrAuthentication = errors.New("merkletree: encrypted tree failed authentication")
+)
+
+const (
+	encryptedMagic = "MTEN"
+	// Version 2 saves the indexes of redacted leaves, version 3 the keys of leaves,
+	// version 4 the chunk layout and version 5 the output of MarshalBinary
+	encryptedVersion = 5
+	// header: magic, version byte and the AES-GCM nonce
+	encryptedHeaderSize = len(encryptedMagic) + 1 + 12
+)
+
+// SaveEncrypted writes the tree as returned by MarshalBinary to w, encrypted with
+// AES-256-GCM under key, a raw 32-byte key derived by the caller. The output is a
+// header holding the format version and a random nonce, followed by the
+// ciphertext, which also authenticates the header.
+func (m *MerkleHashTree) SaveEncrypted(w io.Writer, key []byte) error {
+	aead, err := newTreeAEAD(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, encryptedHeaderSize)
+	copy(header, encryptedMagic)
+	header[len(encryptedMagic)] = encryptedVersion
+	nonce := header[len(encryptedMagic)+1:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	_, err = w.Write(aead.Seal(header, nonce, plaintext, header))
+	return err
+}
+
+// LoadEncrypted returns the tree written by SaveEncrypted under key, created with
+// opts and loaded with UnmarshalBinary. Anything but the output of SaveEncrypted
+// under the same key, such as a wrong key or a single flipped bit, fails with
+// ErrAuthentication before any leaf is loaded. Trees saved by earlier versions,
+// holding the leaf hashes, redactions, keys and chunk layout, are still loaded.
+func LoadEncrypted(r io.Reader, key []byte, opts ...Option) (*MerkleHashTree, error) {
+	aead, err := newTreeAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < encryptedHeaderSize || string(data[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, fmt.Errorf("%w: invalid header", ErrAuthentication)
+	}
+	version := data[len(encryptedMagic)]
+	if version < 1 || version > encryptedVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrAuthentication, version)
+	}
+
+	header := data[:encryptedHeaderSize]
+	plaintext, err := aead.Open(nil, header[len(encryptedMagic)+1:], data[encryptedHeaderSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	if version == encryptedVersion {
+		tree := New(nil, opts...)
+		if err := tree.UnmarshalBinary(plaintext); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	}
+	saved, err := parseSavedTree(plaintext, version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+
+	tree := New(nil, opts...)
+	unlock := tree.writeLock()
+	defer unlock()
+	root, err := tree.admitLeafHashes(saved.leaves)
+	if err != nil {
+		return nil, err
+	}
+	if root != saved.root {
+		return nil, fmt.Errorf("%w: loaded root %x, saved root %x", ErrRootMismatch, root, saved.root)
+	}
+	for _, i := range saved.redacted {
+		if err := tree.markRedacted(i); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range saved.keyed {
+		if err := tree.setKey(k.key, k.index); err != nil {
+			return nil, err
+		}
+	}
+	tree.chunkLayout = saved.layout
+	return tree, nil
+}
+
+// savedTree is the plaintext written by SaveEncrypted before version 5
+type savedTree struct {
+	leaves   [][sha256.Size]byte
+	redacted []uint64
+	keyed    []keyedLeaf
+	layout   *ChunkLayout
+	root     [sha256.Size]byte
+}
+
+// parseSavedTree decodes the plaintext of the given format version. Version 1 has
+// no redacted leaves, versions before 3 no keys and versions before 4 no layout.
+func parseSavedTree(plaintext []byte, version byte) (savedTree, error) {
+	var saved savedTree
+	if len(plaintext) < 8+sha256.Size {
+		return saved, errors.New("short tree")
+	}
+	copy(saved.root[:], plaintext[len(plaintext)-sha256.Size:])
+	r := bytes.NewReader(plaintext[:len(plaintext)-sha256.Size])
+
+	var size uint64
+	binary.Read(r, binary.BigEndian, &size)
+	if size > uint64(r.Len())/sha256.Size {
+		return saved, fmt.Errorf("%d bytes of leaf hashes for %d leaves", r.Len(), size)
+	}
+	saved.leaves = make([][sha256.Size]byte, size)
+	for i := range saved.leaves {
+		r.Read(saved.leaves[i][:])
+	}
+
+	if version >= 2 {
+		var count uint64
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil || count > uint64(r.Len())/8 {
+			return saved, errors.New("bad redacted indexes")
+		}
+		saved.redacted = make([]uint64, count)
+		binary.Read(r, binary.BigEndian, saved.redacted)
+	}
+	if version >= 3 {
+		var count uint64
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil || count > uint64(r.Len())/12 {
+			return saved, errors.New("bad keys")
+		}
+		for i := uint64(0); i < count; i++ {
+			var k keyedLeaf
+			var length uint32
+			binary.Read(r, binary.BigEndian, &k.index)
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil || uint64(length) > uint64(r.Len()) {
+				return saved, errors.New("bad key")
+			}
+			key := make([]byte, length)
+			r.Read(key)
+			k.key = string(key)
+			saved.keyed = append(saved.keyed, k)
+		}
+	}
+	if version >= 4 {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || uint64(length) > uint64(r.Len()) {
+			return saved, errors.New("bad chunk layout")
+		}
+		if length > 0 {
+			b := make([]byte, length)
+			r.Read(b)
+			saved.layout = &ChunkLayout{}
+			if err := saved.layout.UnmarshalBinary(b); err != nil {
+				return saved, err
+			}
+		}
+	}
+	if r.Len() != 0 {
+		return saved, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+	return saved, nil
+}
+
+func newTreeAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w: got %d bytes", ErrInvalidKey, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
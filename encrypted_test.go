@@ -0,0 +1,80 @@
+package merkletree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	for _, n := range []int{0, 1, 7, 64} {
+		D := makeEntries(n)
+		tree := New(D)
+
+		var buf bytes.Buffer
+		assert.NoError(t, tree.SaveEncrypted(&buf, key))
+		if n > 0 {
+			assert.False(t, bytes.Contains(buf.Bytes(), tree.tree[0][0][:]))
+		}
+
+		loaded, err := LoadEncrypted(bytes.NewReader(buf.Bytes()), key)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.TreeHead(), loaded.TreeHead())
+		if n > 0 {
+			proof, err := loaded.InclusionProofByIndex(uint64(n - 1))
+			assert.NoError(t, err)
+			assert.NoError(t, VerifyInclusion(leafHash(D[n-1]), MTH(D), proof))
+		}
+	}
+
+	// Entries are saved along with the hashes
+	tree := New(makeEntries(3))
+	_, err := tree.AppendWithExtra([]byte("entry"), []byte("extra"))
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, tree.SaveEncrypted(&buf, key))
+	loaded, err := LoadEncrypted(&buf, key)
+	assert.NoError(t, err)
+	leaf, extra, err := loaded.GetEntry(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("entry"), leaf)
+	assert.Equal(t, []byte("extra"), extra)
+
+	// Every save uses a fresh nonce
+	var a, b bytes.Buffer
+	assert.NoError(t, tree.SaveEncrypted(&a, key))
+	assert.NoError(t, tree.SaveEncrypted(&b, key))
+	assert.NotEqual(t, a.Bytes(), b.Bytes())
+}
+
+func TestEncryptedTamper(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	var buf bytes.Buffer
+	assert.NoError(t, New(makeEntries(5)).SaveEncrypted(&buf, key))
+	saved := buf.Bytes()
+
+	for i := range saved {
+		for _, bit := range []byte{0x01, 0x80} {
+			tampered := append([]byte(nil), saved...)
+			tampered[i] ^= bit
+			tree, err := LoadEncrypted(bytes.NewReader(tampered), key)
+			assert.ErrorIs(t, err, ErrAuthentication, "byte %d", i)
+			assert.Nil(t, tree)
+		}
+	}
+
+	_, err := LoadEncrypted(bytes.NewReader(saved[:len(saved)-1]), key)
+	assert.ErrorIs(t, err, ErrAuthentication)
+	_, err = LoadEncrypted(bytes.NewReader(saved[:3]), key)
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	wrong := bytes.Repeat([]byte{8}, 32)
+	_, err = LoadEncrypted(bytes.NewReader(saved), wrong)
+	assert.ErrorIs(t, err, ErrAuthentication)
+
+	_, err = LoadEncrypted(bytes.NewReader(saved), key[:16])
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	assert.ErrorIs(t, New(nil).SaveEncrypted(&buf, nil), ErrInvalidKey)
+}
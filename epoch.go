@@ -0,0 +1,130 @@
+package merkletree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBrokenLineage is returned when epoch links do not chain an epoch to a later one
+var ErrBrokenLineage = errors.New("merkletree: epoch lineage is broken")
+
+// EpochLeaf returns leaf 0 of epoch, committing to the final tree head prev of the
+// epoch before: EncodeLeafFields of the big endian uint64 epoch number, the big
+// endian uint64 size of prev and the root hash of prev.
+func EpochLeaf(epoch uint64, prev TreeHead) []byte {
+	e := binary.BigEndian.AppendUint64(nil, epoch)
+	size := binary.BigEndian.AppendUint64(nil, prev.TreeSize)
+	return EncodeLeafFields(e, size, prev.RootHash[:])
+}
+
+// EpochLink proves that the tree head Head of Epoch starts with the EpochLeaf
+// committing to Previous, the final tree head of the epoch before.
+type EpochLink struct {
+	Epoch    uint64
+	Previous TreeHead
+	Head     TreeHead
+	Proof    InclusionProof
+}
+
+// EpochChain is a log rotated into epochs: every epoch is a tree that is sealed when
+// the next one starts, and the first leaf of every epoch but the first commits to
+// the final tree head of the epoch before, so that any epoch is chained to the
+// current one. Epochs are numbered from 0.
+type EpochChain struct {
+	opts []Option
+
+	mu     sync.Mutex
+	epochs []*MerkleHashTree
+}
+
+// NewEpochChain returns a chain whose epoch 0 is an empty tree. Every epoch is
+// created with opts.
+func NewEpochChain(opts ...Option) *EpochChain {
+	return &EpochChain{opts: opts, epochs: []*MerkleHashTree{New(nil, opts...)}}
+}
+
+// Current returns the current epoch and its tree
+func (c *EpochChain) Current() (uint64, *MerkleHashTree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint64(len(c.epochs) - 1), c.epochs[len(c.epochs)-1]
+}
+
+// Epoch returns the tree of epoch
+func (c *EpochChain) Epoch(epoch uint64) (*MerkleHashTree, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch >= uint64(len(c.epochs)) {
+		return nil, fmt.Errorf("%w: epoch %d of %d", ErrIndexOutOfRange, epoch, len(c.epochs))
+	}
+	return c.epochs[epoch], nil
+}
+
+// Rotate seals the current epoch and starts the next one, whose leaf 0 is the
+// EpochLeaf of the final tree head of the sealed epoch. It returns that tree head.
+func (c *EpochChain) Rotate() TreeHead {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := c.epochs[len(c.epochs)-1]
+	last.Seal()
+	head := last.TreeHead()
+
+	next := New([][]byte{EpochLeaf(uint64(len(c.epochs)), head)}, c.opts...)
+	c.epochs = append(c.epochs, next)
+	return head
+}
+
+// Lineage returns the links chaining the final tree head of epoch to the current
+// tree head of the current epoch, for VerifyLineage.
+func (c *EpochChain) Lineage(epoch uint64) (TreeHead, []EpochLink, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch >= uint64(len(c.epochs)) {
+		return TreeHead{}, nil, fmt.Errorf("%w: epoch %d of %d", ErrIndexOutOfRange, epoch, len(c.epochs))
+	}
+
+	old := c.epochs[epoch].TreeHead()
+	prev := old
+	links := make([]EpochLink, 0, len(c.epochs)-int(epoch)-1)
+	for e := epoch + 1; e < uint64(len(c.epochs)); e++ {
+		head := c.epochs[e].TreeHead()
+		p, err := c.epochs[e].InclusionProofAtSize(0, head.TreeSize)
+		if err != nil {
+			return TreeHead{}, nil, err
+		}
+		links = append(links, EpochLink{Epoch: e, Previous: prev, Head: head, Proof: p})
+		prev = head
+	}
+	return old, links, nil
+}
+
+// VerifyLineage checks that links chain the tree head old, the final tree head of
+// some epoch, to the tree head current of a later epoch. Every link must prove
+// that the head of its epoch starts with the EpochLeaf of the head of the epoch
+// before. Without links, old must be current.
+func VerifyLineage(old, current TreeHead, links ...EpochLink) error {
+	prev := old
+	for i, link := range links {
+		if link.Previous != prev {
+			return fmt.Errorf("%w: link %d does not follow the tree head before", ErrBrokenLineage, i)
+		}
+		if i > 0 && link.Epoch != links[i-1].Epoch+1 {
+			return fmt.Errorf("%w: epoch %d follows epoch %d", ErrBrokenLineage, link.Epoch, links[i-1].Epoch)
+		}
+		if link.Proof.LeafIndex != 0 || link.Proof.TreeSize != link.Head.TreeSize {
+			return fmt.Errorf("%w: link %d does not prove leaf 0 of its tree head", ErrBrokenLineage, i)
+		}
+		if err := VerifyInclusion(leafHash(EpochLeaf(link.Epoch, link.Previous)), link.Head.RootHash, link.Proof); err != nil {
+			return fmt.Errorf("%w: epoch %d: %v", ErrBrokenLineage, link.Epoch, err)
+		}
+		prev = link.Head
+	}
+
+	if prev != current {
+		return fmt.Errorf("%w: lineage does not end at the current tree head", ErrBrokenLineage)
+	}
+	return nil
+}
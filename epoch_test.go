@@ -0,0 +1,84 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rotatedChain(t *testing.T) (*EpochChain, []TreeHead) {
+	chain := NewEpochChain()
+	finals := make([]TreeHead, 0)
+	for e, n := range []int{5, 3, 8, 1} {
+		epoch, tree := chain.Current()
+		assert.Equal(t, uint64(e), epoch)
+		tree.Append(makeRangeEntries(10*e, 10*e+n)...)
+		finals = append(finals, chain.Rotate())
+	}
+	_, tree := chain.Current()
+	tree.Append(makeEntries(6)...)
+	return chain, finals
+}
+
+func TestEpochChainRotate(t *testing.T) {
+	chain, finals := rotatedChain(t)
+
+	for e, final := range finals {
+		tree, err := chain.Epoch(uint64(e))
+		assert.NoError(t, err)
+		assert.True(t, tree.Sealed())
+		assert.Equal(t, final, tree.TreeHead())
+		_, err = tree.TryAppend([]byte("late"))
+		assert.ErrorIs(t, err, ErrSealed)
+
+		next, err := chain.Epoch(uint64(e) + 1)
+		assert.NoError(t, err)
+		leaf, err := next.LeafHash(0)
+		assert.NoError(t, err)
+		assert.Equal(t, leafHash(EpochLeaf(uint64(e)+1, final)), leaf)
+	}
+
+	_, err := chain.Epoch(5)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestVerifyLineage(t *testing.T) {
+	chain, _ := rotatedChain(t)
+	_, tree := chain.Current()
+	current := tree.TreeHead()
+
+	for e := uint64(0); e <= 4; e++ {
+		old, links, err := chain.Lineage(e)
+		assert.NoError(t, err)
+		assert.Len(t, links, int(4-e))
+		assert.NoError(t, VerifyLineage(old, current, links...), "from epoch %d", e)
+	}
+
+	old, links, err := chain.Lineage(0)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, VerifyLineage(old, current, links[:3]...), ErrBrokenLineage)
+	assert.ErrorIs(t, VerifyLineage(old, current, append(links[:1:1], links[2:]...)...), ErrBrokenLineage)
+
+	// A middle epoch with another root breaks the chain on both of its sides
+	tampered := append([]EpochLink(nil), links...)
+	tampered[1].Head.RootHash[0] ^= 1
+	assert.ErrorIs(t, VerifyLineage(old, current, tampered...), ErrBrokenLineage)
+	tampered[2].Previous = tampered[1].Head
+	assert.ErrorIs(t, VerifyLineage(old, current, tampered...), ErrBrokenLineage)
+
+	// So does a middle epoch starting from another tree head
+	tampered = append([]EpochLink(nil), links...)
+	tampered[1].Previous.TreeSize++
+	assert.ErrorIs(t, VerifyLineage(old, current, tampered...), ErrBrokenLineage)
+
+	// Or a middle epoch replaced with a valid looking epoch of its own
+	forged := New([][]byte{EpochLeaf(2, links[0].Head), []byte("forged")})
+	p, _ := forged.InclusionProofByIndex(0)
+	tampered = append([]EpochLink(nil), links...)
+	tampered[1] = EpochLink{Epoch: 2, Previous: links[0].Head, Head: forged.TreeHead(), Proof: p}
+	assert.ErrorIs(t, VerifyLineage(old, current, tampered...), ErrBrokenLineage)
+
+	stale := old
+	stale.RootHash[0] ^= 1
+	assert.ErrorIs(t, VerifyLineage(stale, current, links...), ErrBrokenLineage)
+}
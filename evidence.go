@@ -0,0 +1,231 @@
+package merkletree
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrInvalidArchive is returned when an evidence archive does not verify
+var ErrInvalidArchive = errors.New("merkletree: invalid evidence archive")
+
+// EvidenceManifestVersion is the version of the manifest written by WriteEvidenceArchive
+const EvidenceManifestVersion = 1
+
+// Files of an evidence archive
+const (
+	evidenceCheckpoint = "checkpoint"
+	evidenceManifest   = "manifest.json"
+	evidenceEntries    = "entries/"
+)
+
+// EvidenceManifest is the manifest.json of an evidence archive:
+//
+//	{
+//	  "version": 1,
+//	  "tree_size": <size of the checkpoint>,
+//	  "root": "<hex root of the checkpoint>",
+//	  "entries": [
+//	    {
+//	      "path": "entries/<leaf index>",
+//	      "size": <entry size in bytes>,
+//	      "sha256": "<hex SHA-256 of the entry>",
+//	      "leaf_index": <leaf index>,
+//	      "inclusion_proof": ["<hex hash>", ...]
+//	    }
+//	  ]
+//	}
+//
+// Entries are listed by leaf index, and their proofs are against the checkpoint.
+type EvidenceManifest struct {
+	Version  int             `json:"version"`
+	TreeSize uint64          `json:"tree_size"`
+	Root     string          `json:"root"`
+	Entries  []EvidenceEntry `json:"entries"`
+}
+
+// EvidenceEntry describes an entry file of an evidence archive and its inclusion proof
+type EvidenceEntry struct {
+	Path           string   `json:"path"`
+	Size           uint64   `json:"size"`
+	SHA256         string   `json:"sha256"`
+	LeafIndex      uint64   `json:"leaf_index"`
+	InclusionProof []string `json:"inclusion_proof"`
+}
+
+// WriteEvidenceArchive writes a self-contained archive proving the entries at
+// indices to w, for a third party to check with VerifyEvidenceArchive. checkpoint
+// is a signed checkpoint of the tree in the signed note format, for instance a
+// CosignedCheckpoint, and the entries are proven against its size and root. The
+// entries must be kept by the tree, appended with AppendWithExtra or served by its
+// LeafSource. The archive is a tar file holding, in order:
+//
+//	checkpoint       the signed checkpoint, as given
+//	manifest.json    the EvidenceManifest
+//	entries/<index>  the data of every entry, by leaf index in decimal
+func (m *MerkleHashTree) WriteEvidenceArchive(w io.Writer, indices []uint64, checkpoint string) error {
+	c, _, _, err := ParseRekorCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+	manifest := EvidenceManifest{
+		Version:  EvidenceManifestVersion,
+		TreeSize: c.TreeSize,
+		Root:     hex.EncodeToString(c.RootHash[:]),
+		Entries:  make([]EvidenceEntry, 0, len(indices)),
+	}
+
+	indices = sortedIndices(indices)
+	entries := make([][]byte, len(indices))
+	for j, i := range indices {
+		if i >= c.TreeSize {
+			return fmt.Errorf("%w: index %d, checkpoint size %d", ErrIndexOutOfRange, i, c.TreeSize)
+		}
+		if entries[j], _, err = m.GetEntry(i); err != nil {
+			return err
+		}
+	}
+
+	unlock := m.readLock()
+	if c.TreeSize > uint64(len(m.tree[0])) || m.rootAtSize(c.TreeSize) != c.RootHash {
+		unlock()
+		return fmt.Errorf("%w: checkpoint of size %d is not a state of the tree", ErrRootMismatch, c.TreeSize)
+	}
+	for j, i := range indices {
+		proof, err := m.inclusionProofAtSize(i, c.TreeSize)
+		if err != nil {
+			unlock()
+			return err
+		}
+		digest := sha256.Sum256(entries[j])
+		entry := EvidenceEntry{
+			Path:           evidenceEntries + strconv.FormatUint(i, 10),
+			Size:           uint64(len(entries[j])),
+			SHA256:         hex.EncodeToString(digest[:]),
+			LeafIndex:      i,
+			InclusionProof: make([]string, 0, len(proof.Hashes)),
+		}
+		for _, h := range proof.Hashes {
+			entry.InclusionProof = append(entry.InclusionProof, hex.EncodeToString(h[:]))
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	unlock()
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	write := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(evidenceCheckpoint, []byte(checkpoint)); err != nil {
+		return err
+	}
+	if err := write(evidenceManifest, append(manifestJSON, '\n')); err != nil {
+		return err
+	}
+	for j, e := range manifest.Entries {
+		if err := write(e.Path, entries[j]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// VerifyEvidenceArchive checks the evidence archive of size bytes read from r: that
+// its checkpoint is signed by pub, an *ecdsa.PublicKey or an ed25519.PublicKey,
+// that its manifest is for the size and root of the checkpoint, that the archive
+// holds exactly the entry files of the manifest with their listed size and
+// SHA-256, and that every entry is included in the checkpoint. It returns the
+// manifest of the verified archive.
+func VerifyEvidenceArchive(r io.ReaderAt, size int64, pub crypto.PublicKey) (EvidenceManifest, error) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(io.NewSectionReader(r, 0, size))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return EvidenceManifest{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			return EvidenceManifest{}, fmt.Errorf("%w: %s is not a regular file", ErrInvalidArchive, h.Name)
+		}
+		if _, ok := files[h.Name]; ok {
+			return EvidenceManifest{}, fmt.Errorf("%w: duplicate file %s", ErrInvalidArchive, h.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return EvidenceManifest{}, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, h.Name, err)
+		}
+		files[h.Name] = data
+	}
+
+	checkpoint, ok := files[evidenceCheckpoint]
+	if !ok {
+		return EvidenceManifest{}, fmt.Errorf("%w: no %s", ErrInvalidArchive, evidenceCheckpoint)
+	}
+	c, err := VerifyRekorCheckpoint(string(checkpoint), pub)
+	if err != nil {
+		return EvidenceManifest{}, err
+	}
+
+	var manifest EvidenceManifest
+	data, ok := files[evidenceManifest]
+	if !ok {
+		return EvidenceManifest{}, fmt.Errorf("%w: no %s", ErrInvalidArchive, evidenceManifest)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifest); err != nil {
+		return EvidenceManifest{}, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, evidenceManifest, err)
+	}
+	if manifest.Version != EvidenceManifestVersion {
+		return EvidenceManifest{}, fmt.Errorf("%w: manifest version %d", ErrInvalidArchive, manifest.Version)
+	}
+	if manifest.TreeSize != c.TreeSize || manifest.Root != hex.EncodeToString(c.RootHash[:]) {
+		return EvidenceManifest{}, fmt.Errorf("%w: manifest is for size %d and root %s, checkpoint for size %d", ErrInvalidArchive, manifest.TreeSize, manifest.Root, c.TreeSize)
+	}
+
+	if len(files) != len(manifest.Entries)+2 {
+		return EvidenceManifest{}, fmt.Errorf("%w: %d files for %d entries", ErrInvalidArchive, len(files), len(manifest.Entries))
+	}
+	for _, e := range manifest.Entries {
+		if e.Path != evidenceEntries+strconv.FormatUint(e.LeafIndex, 10) {
+			return EvidenceManifest{}, fmt.Errorf("%w: entry %d at %s", ErrInvalidArchive, e.LeafIndex, e.Path)
+		}
+		entry, ok := files[e.Path]
+		if !ok {
+			return EvidenceManifest{}, fmt.Errorf("%w: no %s", ErrInvalidArchive, e.Path)
+		}
+		digest := sha256.Sum256(entry)
+		if uint64(len(entry)) != e.Size || hex.EncodeToString(digest[:]) != e.SHA256 {
+			return EvidenceManifest{}, fmt.Errorf("%w: %s does not match the manifest", ErrInvalidArchive, e.Path)
+		}
+
+		proof := InclusionProof{LeafIndex: e.LeafIndex, TreeSize: c.TreeSize, Hashes: make([][sha256.Size]byte, len(e.InclusionProof))}
+		for i, h := range e.InclusionProof {
+			if err := decodeHexHash(h, &proof.Hashes[i]); err != nil {
+				return EvidenceManifest{}, fmt.Errorf("%w: proof of %s: %v", ErrInvalidArchive, e.Path, err)
+			}
+		}
+		if err := VerifyInclusion(leafHash(entry), c.RootHash, proof); err != nil {
+			return EvidenceManifest{}, fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+	return manifest, nil
+}
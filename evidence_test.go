@@ -0,0 +1,119 @@
+package merkletree
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rewriteArchive returns the archive with the data of every file passed through edit
+func rewriteArchive(t *testing.T, archive []byte, edit func(name string, data []byte) (string, []byte)) []byte {
+	var out bytes.Buffer
+	tr, tw := tar.NewReader(bytes.NewReader(archive)), tar.NewWriter(&out)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		name, data := edit(h.Name, data)
+		if name == "" {
+			continue
+		}
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write(data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return out.Bytes()
+}
+
+func TestEvidenceArchive(t *testing.T) {
+	pub, signer, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	D := makeEntries(20)
+	tree := New(nil)
+	for _, d := range D[:15] {
+		tree.AppendWithExtra(d, nil)
+	}
+	checkpoint := signRekorCheckpoint(t, "example.com/log", TreeHead{TreeSize: 15, RootHash: tree.MerkleRoot()}, signer)
+	// Entries appended after the checkpoint are proven against it
+	for _, d := range D[15:] {
+		tree.AppendWithExtra(d, nil)
+	}
+
+	var archive bytes.Buffer
+	assert.NoError(t, tree.WriteEvidenceArchive(&archive, []uint64{7, 2, 14, 7}, checkpoint))
+	data := archive.Bytes()
+
+	manifest, err := VerifyEvidenceArchive(bytes.NewReader(data), int64(len(data)), pub)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15), manifest.TreeSize)
+	assert.Len(t, manifest.Entries, 3)
+	for j, i := range []uint64{2, 7, 14} {
+		assert.Equal(t, i, manifest.Entries[j].LeafIndex)
+		assert.Equal(t, uint64(len(D[i])), manifest.Entries[j].Size)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, err = VerifyEvidenceArchive(bytes.NewReader(data), int64(len(data)), otherPub)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	swapped := rewriteArchive(t, data, func(name string, d []byte) (string, []byte) {
+		switch name {
+		case "entries/2":
+			return name, D[7]
+		case "entries/7":
+			return name, D[2]
+		}
+		return name, d
+	})
+	_, err = VerifyEvidenceArchive(bytes.NewReader(swapped), int64(len(swapped)), pub)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	renamed := rewriteArchive(t, data, func(name string, d []byte) (string, []byte) {
+		if name == "entries/14" {
+			return "entries/15", d
+		}
+		return name, d
+	})
+	_, err = VerifyEvidenceArchive(bytes.NewReader(renamed), int64(len(renamed)), pub)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	extra := rewriteArchive(t, data, func(name string, d []byte) (string, []byte) {
+		if name == "entries/14" {
+			return "entries/140", d
+		}
+		return name, d
+	})
+	_, err = VerifyEvidenceArchive(bytes.NewReader(extra), int64(len(extra)), pub)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	_, err = VerifyEvidenceArchive(bytes.NewReader(data[:len(data)/2]), int64(len(data)/2), pub)
+	assert.Error(t, err)
+}
+
+func TestWriteEvidenceArchiveErrors(t *testing.T) {
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	var calls int
+	tree := New(nil, WithLeafSource(sliceSource(makeEntries(10), &calls)))
+	tree.Append(makeEntries(10)...)
+
+	checkpoint := signRekorCheckpoint(t, "example.com/log", TreeHead{TreeSize: 10, RootHash: tree.MerkleRoot()}, signer)
+	assert.ErrorIs(t, tree.WriteEvidenceArchive(io.Discard, []uint64{10}, checkpoint), ErrIndexOutOfRange)
+
+	forked := signRekorCheckpoint(t, "example.com/log", TreeHead{TreeSize: 10, RootHash: New(makeEntries(11)).MerkleRoot()}, signer)
+	assert.ErrorIs(t, tree.WriteEvidenceArchive(io.Discard, []uint64{1}, forked), ErrRootMismatch)
+
+	// Entries of a tree that only keeps leaf hashes can't be archived
+	hashesOnly := New(makeEntries(10))
+	assert.ErrorIs(t, hashesOnly.WriteEvidenceArchive(io.Discard, []uint64{0}, checkpoint), ErrEntryNotStored)
+}
@@ -0,0 +1,157 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// ProofNodeRole is the part of the RFC 9162 verification a proof hash is used in
+type ProofNodeRole string
+
+// Roles of proof hashes. Inner hashes are siblings below the point where the path
+// meets the right edge of the tree, border hashes are left siblings along the right
+// edge, and the seed of a consistency proof is the node of the old tree the
+// verification starts from.
+const (
+	RoleInner  ProofNodeRole = "inner"
+	RoleBorder ProofNodeRole = "border"
+	RoleSeed   ProofNodeRole = "seed"
+)
+
+// ExplainedNode is a hash of a proof together with its node, the leaves [Start,
+// End) it commits to and its role
+type ExplainedNode struct {
+	ProofNode
+	Start uint64
+	End   uint64
+	Role  ProofNodeRole
+}
+
+// ExplainedProof is an inclusion or consistency proof annotated hash by hash
+type ExplainedProof struct {
+	// Consistency is true for consistency proofs from OldSize to TreeSize, and
+	// false for the audit path of LeafIndex in a tree of TreeSize leaves
+	Consistency bool
+	LeafIndex   uint64
+	OldSize     uint64
+	TreeSize    uint64
+	Nodes       []ExplainedNode
+}
+
+// ExplainInclusionProof annotates the hashes of p, which must have the length of
+// an audit path of its leaf index and tree size
+func ExplainInclusionProof(p InclusionProof) (ExplainedProof, error) {
+	if p.LeafIndex >= p.TreeSize {
+		return ExplainedProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, p.LeafIndex, p.TreeSize)
+	}
+	steps := inclusionSteps(p.LeafIndex, 0, p.TreeSize)
+	if len(steps) != len(p.Hashes) {
+		return ExplainedProof{}, fmt.Errorf("%w: %d hashes, want %d", ErrInvalidProofSize, len(p.Hashes), len(steps))
+	}
+
+	inner := InnerProofSize(p.LeafIndex, p.TreeSize)
+	e := ExplainedProof{LeafIndex: p.LeafIndex, TreeSize: p.TreeSize, Nodes: make([]ExplainedNode, len(steps))}
+	for i, step := range steps {
+		start, end, _ := step.sibling.leafRange(p.TreeSize)
+		role := RoleBorder
+		if i < inner {
+			role = RoleInner
+		}
+		e.Nodes[i] = explainedNode(p.Hashes[i], start, end, role)
+	}
+	return e, nil
+}
+
+// ExplainConsistencyProof annotates the hashes of p, which must have the length of
+// a consistency proof between its sizes
+func ExplainConsistencyProof(p ConsistencyProof) (ExplainedProof, error) {
+	m, n := p.OldSize, p.NewSize
+	if m > n {
+		return ExplainedProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	var ranges [][2]uint64
+	if m > 0 && m < n {
+		ranges = consistencyRanges(m, 0, n, true)
+	}
+	if len(ranges) != len(p.Hashes) {
+		return ExplainedProof{}, fmt.Errorf("%w: %d hashes, want %d", ErrInvalidProofSize, len(p.Hashes), len(ranges))
+	}
+
+	// As in RFC 9162, the proof is the seed, unless the old size is a power of two
+	// and the seed is the old root, followed by the path of the last old leaf
+	// above the seed.
+	e := ExplainedProof{Consistency: true, OldSize: m, TreeSize: n, Nodes: make([]ExplainedNode, len(ranges))}
+	inner := 0
+	if len(ranges) > 0 {
+		inner = InnerProofSize(m-1, n) - bits.TrailingZeros64(m)
+	}
+	seed := 0
+	if m&(m-1) != 0 {
+		seed = 1
+	}
+	for i, r := range ranges {
+		switch {
+		case i < seed:
+			e.Nodes[i] = explainedNode(p.Hashes[i], r[0], r[1], RoleSeed)
+		case i < seed+inner:
+			e.Nodes[i] = explainedNode(p.Hashes[i], r[0], r[1], RoleInner)
+		default:
+			e.Nodes[i] = explainedNode(p.Hashes[i], r[0], r[1], RoleBorder)
+		}
+	}
+	return e, nil
+}
+
+func explainedNode(hash [sha256.Size]byte, start, end uint64, role ProofNodeRole) ExplainedNode {
+	node := ProofNode{NodeID: rangeNode(start, end), Hash: hash}
+	return ExplainedNode{ProofNode: node, Start: start, End: end, Role: role}
+}
+
+// consistencyRanges returns the leaf ranges of SUBPROOF of RFC 6962 for the first m
+// leaves of [start, end)
+func consistencyRanges(m, start, end uint64, known bool) [][2]uint64 {
+	if m == end-start {
+		if known {
+			return nil
+		}
+		return [][2]uint64{{start, end}}
+	}
+	k := SplitPoint(end - start)
+	if m <= k {
+		return append(consistencyRanges(m, start, start+k, known), [2]uint64{start + k, end})
+	}
+	return append(consistencyRanges(m-k, start+k, end, false), [2]uint64{start, start + k})
+}
+
+// Summary describes the proof in the words of RFC 6962, naming every hash with
+// name, e.g. "The audit path for d3 is [c, g, l]" with leaves named d0, d1, ...
+// A nil name names hashes by their leaf ranges.
+func (e ExplainedProof) Summary(name func(ExplainedNode) string) string {
+	if name == nil {
+		name = func(n ExplainedNode) string {
+			return fmt.Sprintf("[%d, %d)", n.Start, n.End)
+		}
+	}
+	names := make([]string, len(e.Nodes))
+	for i, n := range e.Nodes {
+		names[i] = name(n)
+	}
+
+	if e.Consistency {
+		return fmt.Sprintf("The consistency proof between sizes %d and %d is [%s]", e.OldSize, e.TreeSize, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("The audit path for d%d is [%s]", e.LeafIndex, strings.Join(names, ", "))
+}
+
+// String returns the summary of the proof followed by a line per hash
+func (e ExplainedProof) String() string {
+	var b strings.Builder
+	b.WriteString(e.Summary(nil))
+	b.WriteString("\n")
+	for i, n := range e.Nodes {
+		fmt.Fprintf(&b, "%3d  %-6s  leaves [%d, %d)  level %d index %d  %x\n", i, n.Role, n.Start, n.End, n.Level, n.Index, n.Hash)
+	}
+	return b.String()
+}
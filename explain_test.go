@@ -0,0 +1,127 @@
+package merkletree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcLetters names the nodes of the 7 leaf example tree of RFC 6962 section 2.1.3
+//
+//	        hash
+//	       /    \
+//	      /      \
+//	     /        \
+//	    k          l
+//	   / \        / \
+//	  /   \      /   \
+//	 g     h    i    j
+//	/ \   / \  / \   |
+//	a b   c d  e f   d6
+var rfcLetters = map[[2]uint64]string{
+	{0, 1}: "a", {1, 2}: "b", {2, 3}: "c", {3, 4}: "d", {4, 5}: "e", {5, 6}: "f", {6, 7}: "j",
+	{0, 2}: "g", {2, 4}: "h", {4, 6}: "i", {0, 4}: "k", {4, 7}: "l",
+}
+
+func rfcLetter(n ExplainedNode) string {
+	return rfcLetters[[2]uint64{n.Start, n.End}]
+}
+
+func TestExplainInclusionProof(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	for i, want := range []string{
+		"The audit path for d0 is [b, h, l]",
+		"The audit path for d3 is [c, g, l]",
+		"The audit path for d4 is [f, j, k]",
+		"The audit path for d6 is [i, k]",
+	} {
+		index := []uint64{0, 3, 4, 6}[i]
+		p, err := tree.InclusionProofAtSize(index, 7)
+		assert.NoError(t, err)
+		e, err := ExplainInclusionProof(p)
+		assert.NoError(t, err)
+		assert.Equal(t, want, e.Summary(rfcLetter))
+
+		for j, n := range e.Nodes {
+			// Explaining does not change the hashes, which commit to their ranges
+			assert.Equal(t, p.Hashes[j], n.Hash)
+			root, err := MTHRange(D, n.Start, n.End)
+			assert.NoError(t, err)
+			assert.Equal(t, root, n.Hash)
+			assert.Equal(t, rangeNode(n.Start, n.End), n.NodeID)
+			assert.NoError(t, tree.CheckProofNodes(7, []ProofNode{n.ProofNode}))
+		}
+	}
+
+	p, err := tree.InclusionProofAtSize(4, 7)
+	assert.NoError(t, err)
+	e, err := ExplainInclusionProof(p)
+	assert.NoError(t, err)
+	assert.Equal(t, []ProofNodeRole{RoleInner, RoleInner, RoleBorder}, roles(e))
+	assert.Equal(t, NodeID{Level: 2, Index: 0}, e.Nodes[2].NodeID)
+	assert.Equal(t, fmt.Sprintf(`The audit path for d4 is [[5, 6), [6, 7), [0, 4)]
+  0  inner   leaves [5, 6)  level 0 index 5  %x
+  1  inner   leaves [6, 7)  level 0 index 6  %x
+  2  border  leaves [0, 4)  level 2 index 0  %x
+`, p.Hashes[0], p.Hashes[1], p.Hashes[2]), e.String())
+
+	p.Hashes = p.Hashes[1:]
+	_, err = ExplainInclusionProof(p)
+	assert.ErrorIs(t, err, ErrInvalidProofSize)
+	_, err = ExplainInclusionProof(InclusionProof{LeafIndex: 7, TreeSize: 7})
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func roles(e ExplainedProof) []ProofNodeRole {
+	r := make([]ProofNodeRole, len(e.Nodes))
+	for i, n := range e.Nodes {
+		r[i] = n.Role
+	}
+	return r
+}
+
+func TestExplainConsistencyProof(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	for _, c := range []struct {
+		m     uint64
+		want  string
+		roles []ProofNodeRole
+	}{
+		{3, "The consistency proof between sizes 3 and 7 is [c, d, g, l]", []ProofNodeRole{RoleSeed, RoleInner, RoleInner, RoleInner}},
+		{4, "The consistency proof between sizes 4 and 7 is [l]", []ProofNodeRole{RoleInner}},
+		{6, "The consistency proof between sizes 6 and 7 is [i, j, k]", []ProofNodeRole{RoleSeed, RoleInner, RoleBorder}},
+	} {
+		p, err := tree.ConsistencyProof(c.m, 7)
+		assert.NoError(t, err)
+		e, err := ExplainConsistencyProof(p)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, e.Summary(rfcLetter))
+		assert.Equal(t, c.roles, roles(e))
+		for j, n := range e.Nodes {
+			assert.Equal(t, p.Hashes[j], n.Hash)
+		}
+	}
+
+	// Every hash commits to its range in larger trees too
+	D = makeEntries(21)
+	tree = New(D)
+	for m := uint64(0); m <= 21; m++ {
+		p, err := tree.ConsistencyProof(m, 21)
+		assert.NoError(t, err)
+		e, err := ExplainConsistencyProof(p)
+		assert.NoError(t, err)
+		for _, n := range e.Nodes {
+			root, err := MTHRange(D, n.Start, n.End)
+			assert.NoError(t, err)
+			assert.Equal(t, root, n.Hash)
+		}
+	}
+
+	_, err := ExplainConsistencyProof(ConsistencyProof{OldSize: 3, NewSize: 7})
+	assert.ErrorIs(t, err, ErrInvalidProofSize)
+	_, err = ExplainConsistencyProof(ConsistencyProof{OldSize: 8, NewSize: 7})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
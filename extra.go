@@ -0,0 +1,119 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrEntryNotStored is returned for the entries of leaves that were not appended with AppendWithExtra
+var ErrEntryNotStored = errors.New("merkletree: entry is not stored")
+
+// storedEntry is an entry appended with AppendWithExtra, encoded when the tree has
+// an entry codec
+type storedEntry struct {
+	leaf    []byte
+	extra   []byte
+	encoded bool
+}
+
+// EntrySource is implemented by trees that keep their entries, which NewHandler
+// then serves along with the proofs.
+type EntrySource interface {
+	GetEntry(i uint64) (leaf, extra []byte, err error)
+}
+
+// AppendWithExtra appends a leaf for leaf and stores the leaf together with extra, an
+// attachment that is returned along with the leaf but never hashed, like the
+// extra_data of Certificate Transparency entries. It returns the new merkle root.
+func (m *MerkleHashTree) AppendWithExtra(leaf, extra []byte) ([sha256.Size]byte, error) {
+	hash := m.leafHasher([][]byte{leaf})
+	e, err := m.newStoredEntry(leaf, extra)
+	if err != nil {
+		return m.MerkleRoot(), err
+	}
+
+	defer m.writeLock()()
+	size := uint64(len(m.tree[0]))
+	leaves, err := hash(size)
+	if err != nil {
+		return m.root(), err
+	}
+	root, err := m.admitLeafHashes(leaves)
+	if err != nil {
+		return root, err
+	}
+	m.storeEntry(size, e)
+	return root, nil
+}
+
+// newStoredEntry returns copies of leaf and extra to store, encoded with the codec
+// of the tree
+func (m *MerkleHashTree) newStoredEntry(leaf, extra []byte) (storedEntry, error) {
+	if m.codec == nil {
+		return storedEntry{
+			leaf:  append(make([]byte, 0, len(leaf)), leaf...),
+			extra: append(make([]byte, 0, len(extra)), extra...),
+		}, nil
+	}
+	encodedLeaf, err := encodeEntry(m.codec, leaf)
+	if err != nil {
+		return storedEntry{}, err
+	}
+	encodedExtra, err := encodeEntry(m.codec, extra)
+	if err != nil {
+		return storedEntry{}, err
+	}
+	return storedEntry{leaf: encodedLeaf, extra: encodedExtra, encoded: true}, nil
+}
+
+// storeEntry stores e as the entry at index i, the last leaf
+func (m *MerkleHashTree) storeEntry(i uint64, e storedEntry) {
+	for uint64(len(m.entries)) < i {
+		m.entries = append(m.entries, storedEntry{})
+	}
+	m.entries = append(m.entries, e)
+}
+
+// GetExtra returns the extra data stored with the leaf at index i
+func (m *MerkleHashTree) GetExtra(i uint64) ([]byte, error) {
+	_, extra, err := m.GetEntry(i)
+	return extra, err
+}
+
+// GetEntry returns the leaf at index i and its extra data, when the leaf was appended
+// with AppendWithExtra. Other leaves are only known by their leaf hash, unless the
+// tree has a LeafSource to fetch them from, without extra data. Redacted leaves fail
+// with ErrRedacted.
+func (m *MerkleHashTree) GetEntry(i uint64) (leaf, extra []byte, err error) {
+	unlock := m.readLock()
+	if i >= uint64(len(m.tree[0])) {
+		defer unlock()
+		return nil, nil, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, len(m.tree[0]))
+	}
+	if m.redacted[i] {
+		defer unlock()
+		return nil, nil, fmt.Errorf("%w: index %d", ErrRedacted, i)
+	}
+	if i < uint64(len(m.entries)) && m.entries[i].leaf != nil {
+		e := m.entries[i]
+		unlock()
+		if !e.encoded {
+			return append([]byte(nil), e.leaf...), append([]byte(nil), e.extra...), nil
+		}
+		if leaf, err = decodeEntry(e.leaf); err != nil {
+			return nil, nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		if extra, err = decodeEntry(e.extra); err != nil {
+			return nil, nil, fmt.Errorf("index %d: extra data: %w", i, err)
+		}
+		return leaf, extra, nil
+	}
+	unlock()
+
+	if m.leafSource == nil {
+		return nil, nil, fmt.Errorf("%w: index %d", ErrEntryNotStored, i)
+	}
+	leaf, err = m.fetchEntry(i)
+	return leaf, nil, err
+}
@@ -0,0 +1,96 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtraDataNeverHashed(t *testing.T) {
+	D := makeEntries(9)
+	plain := New(D)
+	for _, opts := range [][]Option{nil, {WithIndexBoundLeaves()}} {
+		a := New(nil, opts...)
+		b := New(nil, opts...)
+		for i, d := range D {
+			_, err := a.AppendWithExtra(d, []byte(fmt.Sprintf("chain-%d", i)))
+			assert.NoError(t, err)
+			_, err = b.AppendWithExtra(d, make([]byte, 1000*i))
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, a.tree, b.tree)
+		if opts == nil {
+			assert.Equal(t, plain.MerkleRoot(), a.MerkleRoot())
+		}
+		for i := range D {
+			pa, err := a.InclusionProofByIndex(uint64(i))
+			assert.NoError(t, err)
+			pb, err := b.InclusionProofByIndex(uint64(i))
+			assert.NoError(t, err)
+			assert.Equal(t, pa, pb)
+		}
+	}
+}
+
+func TestGetEntry(t *testing.T) {
+	D := makeEntries(4)
+	tree := New(D[:2])
+	_, err := tree.AppendWithExtra(D[2], []byte("extra-2"))
+	assert.NoError(t, err)
+	tree.Append(D[3])
+
+	leaf, extra, err := tree.GetEntry(2)
+	assert.NoError(t, err)
+	assert.Equal(t, D[2], leaf)
+	assert.Equal(t, []byte("extra-2"), extra)
+
+	extra, err = tree.GetExtra(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("extra-2"), extra)
+
+	_, err = tree.GetExtra(1)
+	assert.ErrorIs(t, err, ErrEntryNotStored)
+	_, err = tree.GetExtra(3)
+	assert.ErrorIs(t, err, ErrEntryNotStored)
+	_, err = tree.GetExtra(4)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	_, err = tree.SetLeaf(2, D[2])
+	assert.NoError(t, err)
+	_, err = tree.GetExtra(2)
+	assert.ErrorIs(t, err, ErrEntryNotStored)
+}
+
+func TestHandlerServesEntries(t *testing.T) {
+	tree := New(nil)
+	for i, d := range makeEntries(5) {
+		tree.AppendWithExtra(d, []byte(fmt.Sprintf("extra-%d", i)))
+	}
+	server := httptest.NewServer(NewHandler(tree))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EntriesPath + "?start=1&end=9")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var entries entriesResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	assert.Len(t, entries.Entries, 4)
+	for i, e := range entries.Entries {
+		assert.Equal(t, makeEntries(5)[i+1], e.LeafInput)
+		assert.Equal(t, []byte(fmt.Sprintf("extra-%d", i+1)), e.ExtraData)
+	}
+
+	for _, query := range []string{"?start=5&end=6", "?start=2&end=1", "?start=x&end=1"} {
+		resp, err := http.Get(server.URL + EntriesPath + query)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
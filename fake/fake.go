@@ -0,0 +1,190 @@
+// Package fake provides a configurable fake of merkletree.Tree for unit tests of
+// code that depends on a merkle hash tree.
+package fake
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/viveksyngh/merkletree"
+)
+
+// Names of the methods of Tree for which an error can be injected with SetError
+const (
+	LeafIndex            = "LeafIndex"
+	InclusionProofAtSize = "InclusionProofAtSize"
+	ConsistencyProof     = "ConsistencyProof"
+	TryAppend            = "TryAppend"
+)
+
+// ErrNotScripted is returned for proofs that were not scripted with
+// SetInclusionProof or SetConsistencyProof.
+var ErrNotScripted = errors.New("fake: proof not scripted")
+
+type sizes struct {
+	first, second uint64
+}
+
+// Tree is a fake merkletree.Tree with a fixed tree head and scripted proofs. It
+// follows the error contracts of merkletree.Tree for out of range arguments, and
+// returns injected errors in place of any result. It is safe for concurrent use.
+type Tree struct {
+	mu          sync.Mutex
+	head        merkletree.TreeHead
+	leaves      map[[sha256.Size]byte]uint64
+	inclusion   map[sizes]merkletree.InclusionProof
+	consistency map[sizes]merkletree.ConsistencyProof
+	errs        map[string]error
+	appended    [][]byte
+}
+
+var _ merkletree.Tree = (*Tree)(nil)
+
+// New returns a fake tree with the given tree head
+func New(head merkletree.TreeHead) *Tree {
+	return &Tree{
+		head:        head,
+		leaves:      make(map[[sha256.Size]byte]uint64),
+		inclusion:   make(map[sizes]merkletree.InclusionProof),
+		consistency: make(map[sizes]merkletree.ConsistencyProof),
+		errs:        make(map[string]error),
+	}
+}
+
+// SetHead replaces the tree head of the fake
+func (f *Tree) SetHead(head merkletree.TreeHead) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.head = head
+}
+
+// SetLeafIndex makes LeafIndex return index for leafHash
+func (f *Tree) SetLeafIndex(leafHash [sha256.Size]byte, index uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leaves[leafHash] = index
+}
+
+// SetInclusionProof makes InclusionProofAtSize return p for its leaf index and tree size
+func (f *Tree) SetInclusionProof(p merkletree.InclusionProof) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inclusion[sizes{p.LeafIndex, p.TreeSize}] = p
+}
+
+// SetConsistencyProof makes ConsistencyProof return p for its tree sizes
+func (f *Tree) SetConsistencyProof(p merkletree.ConsistencyProof) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.consistency[sizes{p.OldSize, p.NewSize}] = p
+}
+
+// SetError makes the named method return err until it is reset with a nil error
+func (f *Tree) SetError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// Appended returns the entries appended to the fake, in order
+func (f *Tree) Appended() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte(nil), f.appended...)
+}
+
+// Size returns the size of the tree head
+func (f *Tree) Size() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head.TreeSize
+}
+
+// MerkleRoot returns the root hash of the tree head
+func (f *Tree) MerkleRoot() [sha256.Size]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head.RootHash
+}
+
+// TreeHead returns the tree head
+func (f *Tree) TreeHead() merkletree.TreeHead {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head
+}
+
+// LeafIndex returns the index set with SetLeafIndex
+func (f *Tree) LeafIndex(leafHash [sha256.Size]byte) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errs[LeafIndex]; err != nil {
+		return 0, err
+	}
+	index, ok := f.leaves[leafHash]
+	if !ok {
+		return 0, merkletree.ErrLeafNotFound
+	}
+	return index, nil
+}
+
+// InclusionProofAtSize returns the proof set with SetInclusionProof
+func (f *Tree) InclusionProofAtSize(i, n uint64) (merkletree.InclusionProof, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errs[InclusionProofAtSize]; err != nil {
+		return merkletree.InclusionProof{}, err
+	}
+	if i >= n || n > f.head.TreeSize {
+		return merkletree.InclusionProof{}, fmt.Errorf("%w: index %d, size %d", merkletree.ErrIndexOutOfRange, i, n)
+	}
+	p, ok := f.inclusion[sizes{i, n}]
+	if !ok {
+		return merkletree.InclusionProof{}, fmt.Errorf("%w: inclusion of %d at size %d", ErrNotScripted, i, n)
+	}
+	return p, nil
+}
+
+// ConsistencyProof returns the proof set with SetConsistencyProof. Proofs from
+// the empty tree are empty and need not be scripted.
+func (f *Tree) ConsistencyProof(m, n uint64) (merkletree.ConsistencyProof, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errs[ConsistencyProof]; err != nil {
+		return merkletree.ConsistencyProof{}, err
+	}
+	if m > n || n > f.head.TreeSize {
+		return merkletree.ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", merkletree.ErrInvalidRange, m, n)
+	}
+	if m == 0 {
+		return merkletree.ConsistencyProof{OldSize: m, NewSize: n, Hashes: make([][sha256.Size]byte, 0)}, nil
+	}
+	p, ok := f.consistency[sizes{m, n}]
+	if !ok {
+		return merkletree.ConsistencyProof{}, fmt.Errorf("%w: consistency between %d and %d", ErrNotScripted, m, n)
+	}
+	return p, nil
+}
+
+// TryAppend records the entries and grows the size of the tree head accordingly.
+// The root hash is left unchanged until it is replaced with SetHead.
+func (f *Tree) TryAppend(d ...[]byte) ([sha256.Size]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.errs[TryAppend]; err != nil {
+		return f.head.RootHash, err
+	}
+	f.appended = append(f.appended, d...)
+	f.head.TreeSize += uint64(len(d))
+	return f.head.RootHash, nil
+}
@@ -0,0 +1,137 @@
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+	"github.com/viveksyngh/merkletree/merkletest"
+)
+
+func leafHash(d []byte) [sha256.Size]byte {
+	return sha256.Sum256(append([]byte{merkletree.LeafPrefix}, d...))
+}
+
+// scriptedFrom returns a fake serving the same proofs as tree
+func scriptedFrom(t *testing.T, tree *merkletree.MerkleHashTree, D [][]byte) *Tree {
+	f := New(tree.TreeHead())
+	n := tree.Size()
+	for i, d := range D {
+		f.SetLeafIndex(leafHash(d), uint64(i))
+		for size := uint64(i + 1); size <= n; size++ {
+			p, err := tree.InclusionProofAtSize(uint64(i), size)
+			assert.NoError(t, err)
+			f.SetInclusionProof(p)
+		}
+	}
+	for m := uint64(1); m <= n; m++ {
+		for size := m; size <= n; size++ {
+			p, err := tree.ConsistencyProof(m, size)
+			assert.NoError(t, err)
+			f.SetConsistencyProof(p)
+		}
+	}
+	return f
+}
+
+// testTreeContract checks the method contracts of merkletree.Tree on a tree of the entries D
+func testTreeContract(t *testing.T, tree merkletree.Tree, D [][]byte) {
+	n := uint64(len(D))
+	head := tree.TreeHead()
+	assert.Equal(t, n, head.TreeSize)
+	assert.Equal(t, tree.Size(), head.TreeSize)
+	assert.Equal(t, tree.MerkleRoot(), head.RootHash)
+	assert.Equal(t, merkletree.MTH(D), head.RootHash)
+
+	for i, d := range D {
+		index, err := tree.LeafIndex(leafHash(d))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), index)
+
+		p, err := tree.InclusionProofAtSize(uint64(i), n)
+		assert.NoError(t, err)
+		assert.NoError(t, merkletree.VerifyInclusion(leafHash(d), head.RootHash, p))
+	}
+	_, err := tree.LeafIndex(leafHash([]byte("missing")))
+	assert.ErrorIs(t, err, merkletree.ErrLeafNotFound)
+	_, err = tree.InclusionProofAtSize(n, n)
+	assert.ErrorIs(t, err, merkletree.ErrIndexOutOfRange)
+	_, err = tree.InclusionProofAtSize(0, n+1)
+	assert.ErrorIs(t, err, merkletree.ErrIndexOutOfRange)
+
+	for m := uint64(0); m <= n; m++ {
+		p, err := tree.ConsistencyProof(m, n)
+		assert.NoError(t, err)
+		assert.NoError(t, merkletree.VerifyConsistency(merkletree.MTH(D[:m]), head.RootHash, p), "m=%d", m)
+	}
+	_, err = tree.ConsistencyProof(2, 1)
+	assert.ErrorIs(t, err, merkletree.ErrInvalidRange)
+	_, err = tree.ConsistencyProof(1, n+1)
+	assert.ErrorIs(t, err, merkletree.ErrInvalidRange)
+
+	root, err := tree.TryAppend([]byte("x"), []byte("y"))
+	assert.NoError(t, err)
+	assert.Equal(t, n+2, tree.Size())
+	assert.Equal(t, root, tree.MerkleRoot())
+}
+
+func TestTreeContract(t *testing.T) {
+	D := merkletest.MakeEntries(9)
+	t.Run("MerkleHashTree", func(t *testing.T) {
+		testTreeContract(t, merkletree.New(D), D)
+	})
+	t.Run("Fake", func(t *testing.T) {
+		f := scriptedFrom(t, merkletree.New(D), D)
+		testTreeContract(t, f, D)
+		assert.Equal(t, [][]byte{[]byte("x"), []byte("y")}, f.Appended())
+	})
+}
+
+func TestInjectedErrors(t *testing.T) {
+	f := New(merkletree.TreeHead{TreeSize: 4})
+	injected := errors.New("injected")
+
+	_, err := f.InclusionProofAtSize(1, 4)
+	assert.ErrorIs(t, err, ErrNotScripted)
+
+	for _, method := range []string{LeafIndex, InclusionProofAtSize, ConsistencyProof, TryAppend} {
+		f.SetError(method, injected)
+	}
+	_, err = f.LeafIndex([sha256.Size]byte{})
+	assert.ErrorIs(t, err, injected)
+	_, err = f.InclusionProofAtSize(1, 4)
+	assert.ErrorIs(t, err, injected)
+	_, err = f.ConsistencyProof(0, 4)
+	assert.ErrorIs(t, err, injected)
+	_, err = f.TryAppend([]byte("x"))
+	assert.ErrorIs(t, err, injected)
+	assert.Equal(t, uint64(4), f.Size())
+	assert.Empty(t, f.Appended())
+
+	f.SetError(TryAppend, nil)
+	_, err = f.TryAppend([]byte("x"))
+	assert.NoError(t, err)
+}
+
+func TestHandlerServesFake(t *testing.T) {
+	D := merkletest.MakeEntries(6)
+	f := scriptedFrom(t, merkletree.New(D), D)
+	server := httptest.NewServer(merkletree.NewHandler(f))
+	defer server.Close()
+
+	client := merkletree.NewLogClient(server.URL)
+	witness := merkletree.NewWitness(merkletree.TreeHead{})
+	head, err := client.UpdateWitness(context.Background(), witness)
+	assert.NoError(t, err)
+	assert.Equal(t, f.TreeHead(), head)
+	_, err = client.VerifyLeaf(context.Background(), leafHash(D[3]), witness)
+	assert.NoError(t, err)
+
+	f.SetError(InclusionProofAtSize, errors.New("injected"))
+	_, err = client.VerifyLeaf(context.Background(), leafHash(D[3]), witness)
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Paths served by CheckpointFeed.Handler
+const (
+	FeedPath     = "/feed"
+	FeedAtomPath = "/feed.atom"
+)
+
+// ErrFeedGap is returned when walking a feed whose entries do not link up with
+// each other or with the tree head trusted by the witness
+var ErrFeedGap = errors.New("merkletree: feed entry does not follow its predecessor")
+
+// FeedEntry is a published tree head of a CheckpointFeed along with the tree head
+// published before it and the consistency proof between the two, so that every
+// entry can be verified on its own with VerifyFeedEntry
+type FeedEntry struct {
+	TreeHead
+	Time     time.Time
+	Previous TreeHead
+	Proof    [][sha256.Size]byte
+}
+
+// VerifyFeedEntry checks that e extends the tree head published before it
+func VerifyFeedEntry(e FeedEntry) error {
+	if e.TreeSize < e.Previous.TreeSize {
+		return fmt.Errorf("%w: size %d after size %d", ErrRollback, e.TreeSize, e.Previous.TreeSize)
+	}
+	p := ConsistencyProof{OldSize: e.Previous.TreeSize, NewSize: e.TreeSize, Hashes: e.Proof}
+	if e.Previous.TreeSize == 0 {
+		// Every tree extends the empty tree, with an empty proof
+		if len(e.Proof) != 0 || e.Previous.RootHash != sha256.Sum256(nil) {
+			return fmt.Errorf("%w: size %d", ErrInvalidProof, e.TreeSize)
+		}
+		return nil
+	}
+	return VerifyConsistency(e.Previous.RootHash, e.RootHash, p)
+}
+
+// CheckpointFeed publishes the tree heads of a tree to monitors as a bounded list
+// of its most recent entries, each linked to the entry before it by a consistency
+// proof. It is safe for concurrent use.
+type CheckpointFeed struct {
+	mu       sync.RWMutex
+	tree     Tree
+	capacity int
+	entries  []FeedEntry
+	last     TreeHead
+}
+
+// NewCheckpointFeed returns an empty feed of the tree heads of tree, keeping the
+// capacity most recent entries
+func NewCheckpointFeed(tree Tree, capacity int) *CheckpointFeed {
+	return &CheckpointFeed{tree: tree, capacity: capacity, last: TreeHead{RootHash: sha256.Sum256(nil)}}
+}
+
+// Publish adds the current tree head of the tree to the feed and returns its
+// entry, or the latest entry when the tree did not grow since it
+func (f *CheckpointFeed) Publish() (FeedEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	head := f.tree.TreeHead()
+	if len(f.entries) > 0 {
+		switch {
+		case head == f.last:
+			return f.entries[len(f.entries)-1], nil
+		case head.TreeSize < f.last.TreeSize:
+			return FeedEntry{}, fmt.Errorf("%w: size %d after size %d", ErrRollback, head.TreeSize, f.last.TreeSize)
+		case head.TreeSize == f.last.TreeSize:
+			return FeedEntry{}, fmt.Errorf("%w: size %d", ErrFork, head.TreeSize)
+		}
+	}
+
+	proof := make([][sha256.Size]byte, 0)
+	if f.last.TreeSize > 0 {
+		p, err := f.tree.ConsistencyProof(f.last.TreeSize, head.TreeSize)
+		if err != nil {
+			return FeedEntry{}, err
+		}
+		proof = p.Hashes
+	}
+	e := FeedEntry{TreeHead: head, Time: time.Now().UTC(), Previous: f.last, Proof: proof}
+	f.entries = append(f.entries, e)
+	if len(f.entries) > f.capacity {
+		f.entries = append(f.entries[:0:0], f.entries[len(f.entries)-f.capacity:]...)
+	}
+	f.last = head
+	return e, nil
+}
+
+// Entries returns the entries of the feed, oldest first
+func (f *CheckpointFeed) Entries() []FeedEntry {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]FeedEntry(nil), f.entries...)
+}
+
+type feedResponse struct {
+	Entries []feedEntryResponse `json:"entries"`
+}
+
+type feedEntryResponse struct {
+	TreeSize         uint64    `json:"tree_size"`
+	RootHash         string    `json:"root_hash"`
+	Timestamp        time.Time `json:"timestamp"`
+	PreviousTreeSize uint64    `json:"previous_tree_size"`
+	PreviousRootHash string    `json:"previous_root_hash"`
+	Consistency      []string  `json:"consistency"`
+}
+
+func newFeedEntryResponse(e FeedEntry) feedEntryResponse {
+	return feedEntryResponse{
+		TreeSize:         e.TreeSize,
+		RootHash:         hex.EncodeToString(e.RootHash[:]),
+		Timestamp:        e.Time,
+		PreviousTreeSize: e.Previous.TreeSize,
+		PreviousRootHash: hex.EncodeToString(e.Previous.RootHash[:]),
+		Consistency:      encodeHexHashes(e.Proof),
+	}
+}
+
+func (r feedEntryResponse) feedEntry() (FeedEntry, error) {
+	e := FeedEntry{Time: r.Timestamp}
+	e.TreeSize, e.Previous.TreeSize = r.TreeSize, r.PreviousTreeSize
+	if err := decodeHexHash(r.RootHash, &e.RootHash); err != nil {
+		return e, err
+	}
+	if err := decodeHexHash(r.PreviousRootHash, &e.Previous.RootHash); err != nil {
+		return e, err
+	}
+	proof, err := decodeHexHashes(r.Consistency)
+	e.Proof = proof
+	return e, err
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Handler returns an http.Handler serving the entries of the feed, newest first:
+//
+//	GET /feed        {"entries": [{"tree_size", "root_hash", "timestamp",
+//	                   "previous_tree_size", "previous_root_hash", "consistency"}]}
+//	GET /feed.atom   an Atom feed with an entry per tree head, whose content is
+//	                 the JSON of the entry
+//
+// Hashes are lowercase hex and timestamps RFC 3339.
+func (f *CheckpointFeed) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(FeedPath, func(w http.ResponseWriter, r *http.Request) {
+		entries := f.Entries()
+		resp := feedResponse{Entries: make([]feedEntryResponse, 0, len(entries))}
+		for i := len(entries) - 1; i >= 0; i-- {
+			resp.Entries = append(resp.Entries, newFeedEntryResponse(entries[i]))
+		}
+		writeJSON(w, resp)
+	})
+	mux.HandleFunc(FeedAtomPath, func(w http.ResponseWriter, r *http.Request) {
+		entries := f.Entries()
+		feed := atomFeed{ID: "urn:merkletree:feed", Title: "Tree heads", Updated: time.Unix(0, 0).UTC().Format(time.RFC3339)}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if i == len(entries)-1 {
+				feed.Updated = e.Time.Format(time.RFC3339)
+			}
+			content, _ := json.Marshal(newFeedEntryResponse(e))
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:      fmt.Sprintf("urn:merkletree:tree-head:%d:%x", e.TreeSize, e.RootHash),
+				Title:   fmt.Sprintf("Tree size %d", e.TreeSize),
+				Updated: e.Time.Format(time.RFC3339),
+				Content: atomContent{Type: "application/json", Body: string(content)},
+			})
+		}
+		w.Header().Set("Content-Type", "application/atom+xml")
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(feed)
+	})
+	return mux
+}
+
+// GetFeed fetches the entries of the feed served by CheckpointFeed.Handler at the
+// base URL of the client, oldest first, as WalkFeed takes them
+func (c *LogClient) GetFeed(ctx context.Context) ([]FeedEntry, error) {
+	var resp feedResponse
+	if err := c.get(ctx, FeedPath, nil, &resp); err != nil {
+		return nil, err
+	}
+	entries := make([]FeedEntry, len(resp.Entries))
+	for i, r := range resp.Entries {
+		e, err := r.feedEntry()
+		if err != nil {
+			return nil, fmt.Errorf("%w: feed entry %d: %v", ErrUnverifiedResponse, i, err)
+		}
+		entries[len(entries)-1-i] = e
+	}
+	return entries, nil
+}
+
+// WalkFeed advances w through entries, oldest first, verifying every entry with
+// VerifyFeedEntry and that it follows the entry before it. Entries up to the tree
+// head trusted by w must agree with it, and the first entry beyond it must follow
+// it, or the walk fails with ErrFeedGap. It returns the number of entries w was
+// advanced by, and on error w trusts the last entry that verified.
+func WalkFeed(w *Witness, entries []FeedEntry) (int, error) {
+	advanced := 0
+	for i, e := range entries {
+		if err := VerifyFeedEntry(e); err != nil {
+			return advanced, fmt.Errorf("feed entry %d: %w", i, err)
+		}
+		if i > 0 && e.Previous != entries[i-1].TreeHead {
+			return advanced, fmt.Errorf("%w: entry %d of size %d", ErrFeedGap, i, e.TreeSize)
+		}
+
+		trusted := w.TreeHead()
+		switch {
+		case e.TreeSize < trusted.TreeSize:
+			continue
+		case e.TreeSize == trusted.TreeSize:
+			if e.RootHash != trusted.RootHash {
+				return advanced, fmt.Errorf("%w: feed entry %d, size %d", ErrFork, i, e.TreeSize)
+			}
+			continue
+		case e.Previous.TreeSize != trusted.TreeSize:
+			return advanced, fmt.Errorf("%w: entry %d follows size %d, trusted size %d", ErrFeedGap, i, e.Previous.TreeSize, trusted.TreeSize)
+		case trusted.TreeSize > 0 && e.Previous.RootHash != trusted.RootHash:
+			return advanced, fmt.Errorf("%w: feed entry %d follows size %d", ErrFork, i, e.Previous.TreeSize)
+		}
+		p := ConsistencyProof{OldSize: e.Previous.TreeSize, NewSize: e.TreeSize, Hashes: e.Proof}
+		if err := w.Update(e.TreeHead, p); err != nil {
+			return advanced, fmt.Errorf("feed entry %d: %w", i, err)
+		}
+		advanced++
+	}
+	return advanced, nil
+}
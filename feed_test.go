@@ -0,0 +1,139 @@
+package merkletree
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointFeed(t *testing.T) {
+	D := makeEntries(60)
+	tree := New(nil, WithLocking())
+	feed := NewCheckpointFeed(tree, 5)
+	for _, n := range []int{3, 4, 9, 16, 17, 30, 31, 60} {
+		tree.Append(D[tree.Size():n]...)
+		e, err := feed.Publish()
+		assert.NoError(t, err)
+		assert.Equal(t, tree.TreeHead(), e.TreeHead)
+		assert.NoError(t, VerifyFeedEntry(e))
+	}
+	again, err := feed.Publish()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(60), again.TreeSize)
+
+	entries := feed.Entries()
+	assert.Len(t, entries, 5)
+	assert.Equal(t, uint64(16), entries[0].TreeSize)
+	assert.Equal(t, uint64(9), entries[0].Previous.TreeSize)
+
+	server := httptest.NewServer(feed.Handler())
+	defer server.Close()
+	fetched, err := NewLogClient(server.URL).GetFeed(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), len(fetched))
+	for i := range entries {
+		assert.Equal(t, entries[i].TreeHead, fetched[i].TreeHead)
+		assert.Equal(t, entries[i].Previous, fetched[i].Previous)
+		assert.Equal(t, entries[i].Proof, fetched[i].Proof)
+		assert.True(t, entries[i].Time.Equal(fetched[i].Time))
+	}
+
+	// A witness trusting the tree head before the oldest entry walks the whole feed
+	w := NewWitness(TreeHead{TreeSize: 9, RootHash: MTH(D[:9])})
+	advanced, err := WalkFeed(w, fetched)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, advanced)
+	assert.Equal(t, tree.TreeHead(), w.TreeHead())
+
+	// Walking again, or from within the feed, is a no-op up to the trusted head
+	advanced, err = WalkFeed(w, fetched)
+	assert.NoError(t, err)
+	assert.Zero(t, advanced)
+	w = NewWitness(TreeHead{TreeSize: 30, RootHash: MTH(D[:30])})
+	advanced, err = WalkFeed(w, fetched)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, advanced)
+
+	// A witness trusting a head the feed does not link to
+	w = NewWitness(TreeHead{TreeSize: 4, RootHash: MTH(D[:4])})
+	_, err = WalkFeed(w, fetched)
+	assert.ErrorIs(t, err, ErrFeedGap)
+	w = NewWitness(TreeHead{TreeSize: 16, RootHash: MTH(D[:15])})
+	_, err = WalkFeed(w, fetched)
+	assert.ErrorIs(t, err, ErrFork)
+}
+
+func TestCheckpointFeedForgedEntry(t *testing.T) {
+	D := makeEntries(40)
+	tree := New(nil)
+	feed := NewCheckpointFeed(tree, 10)
+	for _, n := range []int{5, 12, 20, 33, 40} {
+		tree.Append(D[tree.Size():n]...)
+		_, err := feed.Publish()
+		assert.NoError(t, err)
+	}
+	entries := feed.Entries()
+	assert.Equal(t, uint64(0), entries[0].Previous.TreeSize)
+
+	// A forged middle entry that is valid on its own, for another tree
+	forged := append([]FeedEntry(nil), entries...)
+	other := New(append(append([][]byte(nil), D[:12]...), makeEntries(30)[20:28]...))
+	proof, err := other.ConsistencyProof(12, 20)
+	assert.NoError(t, err)
+	forged[2] = FeedEntry{TreeHead: other.TreeHead(), Time: entries[2].Time, Previous: entries[1].TreeHead, Proof: proof.Hashes}
+	assert.NoError(t, VerifyFeedEntry(forged[2]))
+
+	w := NewWitness(TreeHead{})
+	advanced, err := WalkFeed(w, forged)
+	assert.ErrorIs(t, err, ErrFeedGap)
+	assert.Equal(t, 3, advanced)
+	assert.Equal(t, other.TreeHead(), w.TreeHead())
+
+	// A middle entry whose proof was tampered with does not verify on its own
+	tampered := append([]FeedEntry(nil), entries...)
+	tampered[3].Proof = copyHashes(tampered[3].Proof)
+	tampered[3].Proof[0][0] ^= 1
+	w = NewWitness(TreeHead{})
+	advanced, err = WalkFeed(w, tampered)
+	assert.Error(t, err)
+	assert.Equal(t, 3, advanced)
+	assert.Equal(t, TreeHead{TreeSize: 20, RootHash: MTH(D[:20])}, w.TreeHead())
+
+	// The genuine feed walks end to end
+	w = NewWitness(TreeHead{})
+	advanced, err = WalkFeed(w, entries)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, advanced)
+}
+
+func TestCheckpointFeedAtom(t *testing.T) {
+	tree := New(makeEntries(3))
+	feed := NewCheckpointFeed(tree, 10)
+	_, err := feed.Publish()
+	assert.NoError(t, err)
+	tree.Append(makeEntries(7)[3:]...)
+	_, err = feed.Publish()
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	feed.Handler().ServeHTTP(rec, httptest.NewRequest("GET", FeedAtomPath, nil))
+	assert.Equal(t, "application/atom+xml", rec.Header().Get("Content-Type"))
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+
+	var parsed atomFeed
+	assert.NoError(t, xml.Unmarshal(body, &parsed))
+	assert.Len(t, parsed.Entries, 2)
+	var newest feedEntryResponse
+	assert.NoError(t, json.Unmarshal([]byte(parsed.Entries[0].Content.Body), &newest))
+	e, err := newest.feedEntry()
+	assert.NoError(t, err)
+	assert.Equal(t, tree.TreeHead(), e.TreeHead)
+	assert.NoError(t, VerifyFeedEntry(e))
+	assert.Equal(t, parsed.Entries[0].Updated, parsed.Updated)
+}
@@ -0,0 +1,508 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Files of a FileLog directory
+const (
+	fileLogRecordsName    = "records"
+	fileLogCheckpointName = "checkpoint"
+	fileLogLockName       = "lock"
+)
+
+const (
+	checkpointMagic   = "MTCK"
+	checkpointVersion = 1
+	// record header: uint32 data length followed by the 32-byte leaf hash
+	recordHeaderSize = 4 + sha256.Size
+	// record trailer: CRC-32 (Castagnoli) of the header and the data
+	recordTrailerSize = 4
+)
+
+// ErrCorruptLog is returned when the files of a FileLog are inconsistent in a way
+// that cannot be explained by a crash during an append.
+var ErrCorruptLog = errors.New("merkletree: corrupt file log")
+
+// Errors returned when opening a FileLog and writing to one opened read-only
+var (
+	ErrLocked   = errors.New("merkletree: file log is locked by another open")
+	ErrReadOnly = errors.New("merkletree: file log is opened read-only")
+)
+
+// CheckpointError is returned by Append when the entries were appended, but writing
+// the checkpoint that follows failed. The entries are in the log, and are recovered
+// from the records on reopen, so they must not be appended again.
+type CheckpointError struct {
+	Err error
+}
+
+func (e *CheckpointError) Error() string {
+	return fmt.Sprintf("merkletree: entries appended, writing the checkpoint: %v", e.Err)
+}
+
+func (e *CheckpointError) Unwrap() error {
+	return e.Err
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileLog is a durable append-only log of entries backed by a directory.
+//
+// Each entry is stored in the records file together with its leaf hash and a
+// checksum. Every CheckpointEvery appends, and on Close, the log writes a
+// checkpoint holding the number of entries, their byte length and the frontier
+// of the tree, so reopening the log only needs to read the records appended after
+// the last checkpoint. A record torn by a crash during an append is discarded.
+//
+// The merkle hash tree needed for proofs is built from the stored leaf hashes the
+// first time a proof is requested. A FileLog is not safe for concurrent use.
+//
+// An open log holds an exclusive lock on the lock file of its directory, or a
+// shared lock when opened WithReadOnly, so that a log is never written by two
+// opens at once, in the same process or not. Locks are advisory flock locks, not
+// taken on platforms without flock.
+type FileLog struct {
+	dir      string
+	records  *os.File
+	lock     *os.File
+	readOnly bool
+
+	size     uint64
+	offset   int64
+	frontier [][sha256.Size]byte
+	tree     *MerkleHashTree
+
+	syncEvery       int
+	checkpointEvery int
+	sinceSync       int
+	sinceCheckpoint int
+}
+
+// FileLogOption configures a FileLog
+type FileLogOption func(*FileLog)
+
+// WithSyncEvery syncs the records file to disk after every n appended entries.
+// With n <= 1 every append is synced before it returns.
+func WithSyncEvery(n int) FileLogOption {
+	return func(l *FileLog) {
+		l.syncEvery = n
+	}
+}
+
+// WithCheckpointEvery writes a checkpoint after every n appended entries
+func WithCheckpointEvery(n int) FileLogOption {
+	return func(l *FileLog) {
+		l.checkpointEvery = n
+	}
+}
+
+// WithReadOnly opens an existing log for proofs only, taking a shared lock, so
+// that any number of read-only opens can serve proofs while no writer has the log
+// open. The log is read as it was at open, and Append and Checkpoint fail with
+// ErrReadOnly.
+func WithReadOnly() FileLogOption {
+	return func(l *FileLog) {
+		l.readOnly = true
+	}
+}
+
+// OpenFileLog opens the log stored in dir, creating it if it does not exist, and
+// recovers from a crash during a previous append. It fails with ErrLocked while
+// another open holds a conflicting lock on dir, and with ErrCorruptLog when the
+// checkpoint fails validation. Close releases the lock.
+func OpenFileLog(dir string, opts ...FileLogOption) (l *FileLog, err error) {
+	l = &FileLog{
+		dir:             dir,
+		frontier:        make([][sha256.Size]byte, 0),
+		syncEvery:       1,
+		checkpointEvery: 1024,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	recordsFlag := os.O_RDWR | os.O_CREATE
+	if l.readOnly {
+		recordsFlag = os.O_RDONLY
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, fileLogLockName), os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			lock.Close()
+		}
+	}()
+	l.lock = lock
+	if err := lockFile(lock, l.readOnly); errors.Is(err, ErrLocked) {
+		return nil, fmt.Errorf("%w: %s", ErrLocked, dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := l.readCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	l.records, err = os.OpenFile(filepath.Join(dir, fileLogRecordsName), recordsFlag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.recover(); err != nil {
+		l.records.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover reads the records appended after the checkpoint and truncates a torn
+// record at the end of the file: one cut short by the end of the file, or the last
+// record of the file failing its checksum. A record failing its checksum or its
+// leaf hash with more records after it fails with ErrCorruptLog.
+func (l *FileLog) recover() error {
+	info, err := l.records.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < l.offset {
+		return fmt.Errorf("%w: checkpoint at offset %d beyond end of records at %d", ErrCorruptLog, l.offset, info.Size())
+	}
+
+	r := io.NewSectionReader(l.records, l.offset, info.Size()-l.offset)
+	for {
+		leaf, entry, n, err := readRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err == nil && leaf != leafHash(entry) {
+			err = fmt.Errorf("%w: leaf hash mismatch", ErrCorruptLog)
+		}
+		if err != nil {
+			end, seekErr := r.Seek(0, io.SeekCurrent)
+			if seekErr != nil {
+				return seekErr
+			}
+			if l.offset+end < info.Size() {
+				return fmt.Errorf("%w: record %d at offset %d: %v", ErrCorruptLog, l.size, l.offset, err)
+			}
+			break
+		}
+		l.frontier = frontierAppend(l.frontier, l.size, leaf)
+		l.size++
+		l.offset += n
+	}
+
+	// A read-only log leaves the torn tail for the next writer
+	if l.readOnly {
+		return nil
+	}
+	if l.offset < info.Size() {
+		if err := l.records.Truncate(l.offset); err != nil {
+			return err
+		}
+	}
+	_, err = l.records.Seek(l.offset, io.SeekStart)
+	return err
+}
+
+// readRecord reads a record from r, returning its leaf hash, entry and encoded
+// length. A record running past the end of r fails with io.ErrUnexpectedEOF before
+// its entry is read.
+func readRecord(r *io.SectionReader) ([sha256.Size]byte, []byte, int64, error) {
+	var leaf [sha256.Size]byte
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return leaf, nil, 0, err
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return leaf, nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if int64(length)+recordTrailerSize > r.Size()-pos {
+		return leaf, nil, 0, io.ErrUnexpectedEOF
+	}
+	rest := make([]byte, int(length)+recordTrailerSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return leaf, nil, 0, err
+	}
+
+	entry := rest[:length]
+	checksum := crc32.Update(crc32.Checksum(header, crcTable), crcTable, entry)
+	if checksum != binary.BigEndian.Uint32(rest[length:]) {
+		return leaf, nil, 0, ErrCorruptLog
+	}
+
+	copy(leaf[:], header[4:])
+	return leaf, entry, int64(len(header) + len(rest)), nil
+}
+
+func appendRecord(b []byte, entry []byte, leaf [sha256.Size]byte) []byte {
+	start := len(b)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(entry)))
+	b = append(b, leaf[:]...)
+	b = append(b, entry...)
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b[start:], crcTable))
+}
+
+// Append durably adds entries to the log and returns the new merkle root. When
+// the entries are appended but the checkpoint due after them cannot be written,
+// Append returns the new root with a *CheckpointError, and the checkpoint is
+// written again by the next append or Checkpoint. Any other error leaves the log
+// unchanged.
+func (l *FileLog) Append(entries ...[]byte) ([sha256.Size]byte, error) {
+	if l.readOnly {
+		return [sha256.Size]byte{}, ErrReadOnly
+	}
+	buf := make([]byte, 0)
+	leaves := make([][sha256.Size]byte, 0, len(entries))
+	for _, e := range entries {
+		leaf := leafHash(e)
+		leaves = append(leaves, leaf)
+		buf = appendRecord(buf, e, leaf)
+	}
+
+	if _, err := l.records.Write(buf); err != nil {
+		return [sha256.Size]byte{}, l.rollback(err)
+	}
+
+	l.sinceSync += len(entries)
+	if l.sinceSync >= l.syncEvery {
+		if err := l.records.Sync(); err != nil {
+			l.sinceSync -= len(entries)
+			return [sha256.Size]byte{}, l.rollback(err)
+		}
+		l.sinceSync = 0
+	}
+
+	for _, leaf := range leaves {
+		l.frontier = frontierAppend(l.frontier, l.size, leaf)
+		l.size++
+	}
+	l.offset += int64(len(buf))
+	if l.tree != nil {
+		l.tree.appendLeafHashes(leaves)
+	}
+
+	l.sinceCheckpoint += len(entries)
+	if l.sinceCheckpoint >= l.checkpointEvery {
+		if err := l.Checkpoint(); err != nil {
+			return l.Root(), &CheckpointError{Err: err}
+		}
+	}
+	return l.Root(), nil
+}
+
+// rollback drops whatever part of a failed append made it to the records file,
+// returning err, or err along with the error of dropping it
+func (l *FileLog) rollback(err error) error {
+	if terr := l.records.Truncate(l.offset); terr != nil {
+		return fmt.Errorf("%w (truncating records: %v)", err, terr)
+	}
+	if _, serr := l.records.Seek(l.offset, io.SeekStart); serr != nil {
+		return fmt.Errorf("%w (seeking records: %v)", err, serr)
+	}
+	return err
+}
+
+// Size returns the number of entries in the log
+func (l *FileLog) Size() uint64 {
+	return l.size
+}
+
+// Root returns the merkle root of the log
+func (l *FileLog) Root() [sha256.Size]byte {
+	return frontierRoot(l.frontier)
+}
+
+// InclusionProof returns the audit path for the entry at index in the tree of the first size entries
+func (l *FileLog) InclusionProof(index, size uint64) (InclusionProof, error) {
+	tree, err := l.loadTree()
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return tree.InclusionProofAtSize(index, size)
+}
+
+// ConsistencyProof returns the consistency proof between the trees of the first m and n entries
+func (l *FileLog) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	if m > n || n > l.size {
+		return ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	tree, err := l.loadTree()
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+
+	return tree.ConsistencyProof(m, n)
+}
+
+// loadTree builds the merkle hash tree from the leaf hashes stored in the records file
+func (l *FileLog) loadTree() (*MerkleHashTree, error) {
+	if l.tree != nil {
+		return l.tree, nil
+	}
+
+	leaves := make([][sha256.Size]byte, 0, l.size)
+	r := io.NewSectionReader(l.records, 0, l.offset)
+	for uint64(len(leaves)) < l.size {
+		leaf, _, _, err := readRecord(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: record %d: %v", ErrCorruptLog, len(leaves), err)
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	tree := New(nil)
+	tree.appendLeafHashes(leaves)
+	if tree.MerkleRoot() != l.Root() {
+		return nil, fmt.Errorf("%w: records do not match the checkpoint", ErrCorruptLog)
+	}
+	l.tree = tree
+	return tree, nil
+}
+
+// Checkpoint syncs the records file and atomically replaces the checkpoint
+func (l *FileLog) Checkpoint() error {
+	if l.readOnly {
+		return ErrReadOnly
+	}
+	if err := l.records.Sync(); err != nil {
+		return err
+	}
+	l.sinceSync = 0
+
+	b := marshalCheckpoint(l.size, uint64(l.offset), l.frontier)
+	if err := writeFileAtomic(filepath.Join(l.dir, fileLogCheckpointName), b); err != nil {
+		return err
+	}
+	l.sinceCheckpoint = 0
+	return nil
+}
+
+func (l *FileLog) readCheckpoint() error {
+	b, err := os.ReadFile(filepath.Join(l.dir, fileLogCheckpointName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	size, offset, frontier, err := parseCheckpoint(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptLog, err)
+	}
+	l.size, l.offset, l.frontier = size, int64(offset), frontier
+	return nil
+}
+
+// marshalCheckpoint encodes the size, an offset in the input and the frontier of
+// a tree: the magic, the version, the size and offset as big endian uint64s, the
+// frontier, the root and the CRC-32 (Castagnoli) of all of it
+func marshalCheckpoint(size, offset uint64, frontier [][sha256.Size]byte) []byte {
+	b := []byte(checkpointMagic)
+	b = append(b, checkpointVersion)
+	b = binary.BigEndian.AppendUint64(b, size)
+	b = binary.BigEndian.AppendUint64(b, offset)
+	for _, h := range frontier {
+		b = append(b, h[:]...)
+	}
+	root := frontierRoot(frontier)
+	b = append(b, root[:]...)
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, crcTable))
+}
+
+// parseCheckpoint decodes a checkpoint encoded by marshalCheckpoint, checking its
+// frontier against its root
+func parseCheckpoint(b []byte) (size, offset uint64, frontier [][sha256.Size]byte, err error) {
+	const fixed = len(checkpointMagic) + 1 + 8 + 8
+	if len(b) < fixed+sha256.Size+4 || !bytes.Equal(b[:len(checkpointMagic)], []byte(checkpointMagic)) {
+		return 0, 0, nil, errors.New("invalid checkpoint")
+	}
+	body, checksum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.Checksum(body, crcTable) != checksum {
+		return 0, 0, nil, errors.New("checkpoint checksum mismatch")
+	}
+	if body[len(checkpointMagic)] != checkpointVersion {
+		return 0, 0, nil, fmt.Errorf("unsupported checkpoint version %d", body[len(checkpointMagic)])
+	}
+
+	size = binary.BigEndian.Uint64(body[len(checkpointMagic)+1:])
+	offset = binary.BigEndian.Uint64(body[len(checkpointMagic)+9:])
+	hashes := body[fixed:]
+	if len(hashes)%sha256.Size != 0 {
+		return 0, 0, nil, errors.New("invalid checkpoint")
+	}
+
+	frontier = make([][sha256.Size]byte, len(hashes)/sha256.Size-1)
+	for i := range frontier {
+		copy(frontier[i][:], hashes[i*sha256.Size:])
+	}
+	var root [sha256.Size]byte
+	copy(root[:], hashes[len(hashes)-sha256.Size:])
+	if !validFrontier(frontier, size) || frontierRoot(frontier) != root {
+		return 0, 0, nil, errors.New("checkpoint frontier does not match its root")
+	}
+	return size, offset, frontier, nil
+}
+
+// Close writes a final checkpoint, unless the log is read-only, closes the log and
+// releases its lock
+func (l *FileLog) Close() error {
+	defer l.lock.Close()
+	if !l.readOnly {
+		if err := l.Checkpoint(); err != nil {
+			l.records.Close()
+			return err
+		}
+	}
+	return l.records.Close()
+}
+
+// writeFileAtomic replaces the file at path with data by writing a temporary file
+// in the same directory, syncing it and renaming it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// sync the directory so the rename itself is durable
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package merkletree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// crash abandons the log without writing a checkpoint
+func crash(l *FileLog) {
+	l.records.Close()
+	l.lock.Close()
+}
+
+func copyDir(t *testing.T, src, dst string) {
+	for _, name := range []string{fileLogRecordsName, fileLogCheckpointName} {
+		b, err := os.ReadFile(filepath.Join(src, name))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dst, name), b, 0o644))
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	dir := t.TempDir()
+	D := makeEntries(20)
+
+	l, err := OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(nil), l.Root())
+
+	root, err := l.Append(D[:7]...)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:7]), root)
+	assert.NoError(t, l.Close())
+
+	l, err = OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), l.Size())
+	assert.Equal(t, MTH(D[:7]), l.Root())
+
+	proof, err := l.InclusionProof(3, 7)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), MTH(D[:7]), proof))
+
+	// appends after the tree was loaded keep it up to date
+	root, err = l.Append(D[7:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), root)
+	proof, err = l.InclusionProof(19, 20)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[19]), root, proof))
+
+	consistency, err := l.ConsistencyProof(7, 20)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyConsistency(MTH(D[:7]), root, consistency))
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogRecoversRecordsAfterCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	D := makeEntries(13)
+
+	l, err := OpenFileLog(dir, WithCheckpointEvery(10))
+	assert.NoError(t, err)
+	for _, d := range D {
+		_, err := l.Append(d)
+		assert.NoError(t, err)
+	}
+	crash(l)
+
+	// the checkpoint says 10 entries, the records file holds 13
+	l, err = OpenFileLog(dir, WithCheckpointEvery(10))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), l.Size())
+	assert.Equal(t, MTH(D), l.Root())
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogTornWrites(t *testing.T) {
+	base := t.TempDir()
+	D := makeEntries(16)
+
+	l, err := OpenFileLog(base, WithCheckpointEvery(10))
+	assert.NoError(t, err)
+	_, err = l.Append(D[:10]...)
+	assert.NoError(t, err)
+	checkpointOffset := l.offset
+
+	offsets := []int64{checkpointOffset}
+	for _, d := range D[10:] {
+		_, err = l.Append(d)
+		assert.NoError(t, err)
+		offsets = append(offsets, l.offset)
+	}
+	crash(l)
+
+	for cut := checkpointOffset; cut <= offsets[len(offsets)-1]; cut++ {
+		dir := t.TempDir()
+		copyDir(t, base, dir)
+		assert.NoError(t, os.Truncate(filepath.Join(dir, fileLogRecordsName), cut))
+
+		complete := 0
+		for complete+1 < len(offsets) && offsets[complete+1] <= cut {
+			complete++
+		}
+		size := 10 + complete
+
+		l, err := OpenFileLog(dir)
+		assert.NoError(t, err, "cut at %d", cut)
+		assert.Equal(t, uint64(size), l.Size(), "cut at %d", cut)
+		assert.Equal(t, MTH(D[:size]), l.Root(), "cut at %d", cut)
+
+		// the torn tail is gone, so appending again produces a valid log
+		_, err = l.Append(D[size:]...)
+		assert.NoError(t, err)
+		crash(l)
+
+		l, err = OpenFileLog(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, MTH(D), l.Root())
+		proof, err := l.InclusionProof(uint64(size-1), 16)
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(leafHash(D[size-1]), MTH(D), proof))
+		assert.NoError(t, l.Close())
+	}
+
+	// losing records covered by the checkpoint is not a torn write
+	dir := t.TempDir()
+	copyDir(t, base, dir)
+	assert.NoError(t, os.Truncate(filepath.Join(dir, fileLogRecordsName), checkpointOffset-1))
+	_, err = OpenFileLog(dir)
+	assert.ErrorIs(t, err, ErrCorruptLog)
+}
+
+func TestFileLogCorruptRecords(t *testing.T) {
+	base := t.TempDir()
+	D := makeEntries(6)
+
+	l, err := OpenFileLog(base, WithCheckpointEvery(2))
+	assert.NoError(t, err)
+	_, err = l.Append(D[:2]...)
+	assert.NoError(t, err)
+	l.checkpointEvery = len(D)
+	offsets := []int64{l.offset}
+	for _, d := range D[2:] {
+		_, err = l.Append(d)
+		assert.NoError(t, err)
+		offsets = append(offsets, l.offset)
+	}
+	crash(l)
+
+	corrupt := func(at int64, b ...byte) string {
+		dir := t.TempDir()
+		copyDir(t, base, dir)
+		f, err := os.OpenFile(filepath.Join(dir, fileLogRecordsName), os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		_, err = f.WriteAt(b, at)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+		return dir
+	}
+
+	// a bad record followed by valid ones is corruption, not a torn write, and the
+	// records after it are kept
+	for _, at := range []int64{offsets[1] + recordHeaderSize, offsets[1] + 4} {
+		dir := corrupt(at, 0xff)
+		_, err = OpenFileLog(dir)
+		assert.ErrorIs(t, err, ErrCorruptLog, "byte %d", at)
+		info, err := os.Stat(filepath.Join(dir, fileLogRecordsName))
+		assert.NoError(t, err)
+		assert.Equal(t, offsets[len(offsets)-1], info.Size())
+	}
+
+	// the last record failing its checksum is a torn write
+	l, err = OpenFileLog(corrupt(offsets[len(offsets)-1]-1, 0xff))
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:5]), l.Root())
+	assert.NoError(t, l.Close())
+
+	// as is a length running past the end of the file, which is not allocated
+	l, err = OpenFileLog(corrupt(offsets[len(offsets)-2], 0xff, 0xff, 0xff, 0xff))
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:5]), l.Root())
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogCorruptCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenFileLog(dir)
+	assert.NoError(t, err)
+	_, err = l.Append(makeEntries(5)...)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Close())
+
+	path := filepath.Join(dir, fileLogCheckpointName)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	b[len(b)-10] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, b, 0o644))
+
+	_, err = OpenFileLog(dir)
+	assert.ErrorIs(t, err, ErrCorruptLog)
+}
+
+func TestFileLogCreateThenReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "new", "log")
+	D := makeEntries(9)
+
+	l, err := OpenFileLog(dir)
+	assert.NoError(t, err)
+	_, err = l.Append(D...)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Close())
+
+	l, err = OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(9), l.Size())
+	assert.Equal(t, MTH(D), l.Root())
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogLocking(t *testing.T) {
+	dir := t.TempDir()
+	D := makeEntries(6)
+
+	l, err := OpenFileLog(dir)
+	assert.NoError(t, err)
+	_, err = l.Append(D...)
+	assert.NoError(t, err)
+
+	// A second writer and readers fail fast while the writer has the log open
+	_, err = OpenFileLog(dir)
+	assert.ErrorIs(t, err, ErrLocked)
+	_, err = OpenFileLog(dir, WithReadOnly())
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.NoError(t, l.Close())
+
+	r1, err := OpenFileLog(dir, WithReadOnly())
+	assert.NoError(t, err)
+	r2, err := OpenFileLog(dir, WithReadOnly())
+	assert.NoError(t, err)
+	_, err = OpenFileLog(dir)
+	assert.ErrorIs(t, err, ErrLocked)
+
+	proof, err := r2.InclusionProof(4, 6)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[4]), MTH(D), proof))
+	_, err = r1.Append(D[0])
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, r1.Checkpoint(), ErrReadOnly)
+	assert.NoError(t, r1.Close())
+	assert.NoError(t, r2.Close())
+
+	l, err = OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), l.Root())
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogConcurrentOpen(t *testing.T) {
+	dir := t.TempDir()
+	opened := make(chan *FileLog, 2)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			l, err := OpenFileLog(dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			opened <- l
+		}()
+	}
+
+	// Exactly one open wins the lock
+	l := <-opened
+	assert.ErrorIs(t, <-errs, ErrLocked)
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogReadOnlyMissing(t *testing.T) {
+	_, err := OpenFileLog(filepath.Join(t.TempDir(), "missing"), WithReadOnly())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileLogCorruptCheckpointReleasesLock(t *testing.T) {
+	dir := t.TempDir()
+	l, err := OpenFileLog(dir)
+	assert.NoError(t, err)
+	_, err = l.Append(makeEntries(3)...)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Close())
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, fileLogCheckpointName), []byte("MTCK garbage"), 0o644))
+
+	for _, opts := range [][]FileLogOption{nil, {WithReadOnly()}} {
+		_, err = OpenFileLog(dir, opts...)
+		assert.ErrorIs(t, err, ErrCorruptLog)
+	}
+	// The failed opens did not keep the lock
+	assert.NoError(t, os.Remove(filepath.Join(dir, fileLogCheckpointName)))
+	l, err = OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Close())
+}
+
+func TestFileLogCheckpointFailure(t *testing.T) {
+	dir := t.TempDir()
+	D := makeEntries(6)
+	l, err := OpenFileLog(dir, WithCheckpointEvery(2))
+	assert.NoError(t, err)
+	_, err = l.Append(D[:2]...)
+	assert.NoError(t, err)
+
+	// A directory in place of the checkpoint makes writing the checkpoint fail
+	checkpoint := filepath.Join(dir, fileLogCheckpointName)
+	assert.NoError(t, os.Remove(checkpoint))
+	assert.NoError(t, os.MkdirAll(filepath.Join(checkpoint, "blocked"), 0o755))
+
+	root, err := l.Append(D[2:4]...)
+	var cerr *CheckpointError
+	assert.ErrorAs(t, err, &cerr)
+	assert.Equal(t, MTH(D[:4]), root)
+	assert.Equal(t, uint64(4), l.Size())
+
+	// The entries are not appended again, and a later checkpoint records them
+	assert.NoError(t, os.RemoveAll(checkpoint))
+	root, err = l.Append(D[4:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), root)
+	assert.NoError(t, l.Close())
+
+	l, err = OpenFileLog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), l.Size())
+	assert.Equal(t, MTH(D), l.Root())
+	assert.NoError(t, l.Close())
+}
@@ -0,0 +1,188 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ErrFrontierMismatch is returned when a consistency proof holds a root of the old
+// frontier that differs from the frontier of the verifier
+var ErrFrontierMismatch = errors.New("merkletree: consistency proof does not match the frontier")
+
+// A frontier holds the roots of the perfect subtrees a tree of a given size
+// decomposes into, ordered from the leftmost (largest) to the rightmost (smallest).
+// There is one root per set bit of the tree size, which is enough to compute the
+// merkle root and to keep appending leaves without any other part of the tree.
+
+// frontierAppend adds leaf to the frontier of a tree of size leaves and returns the
+// frontier of the tree of size+1 leaves.
+func frontierAppend(frontier [][sha256.Size]byte, size uint64, leaf [sha256.Size]byte) [][sha256.Size]byte {
+	return frontierAppendWith(sha256NodeHash, frontier, size, leaf)
+}
+
+// frontierAppendWith is frontierAppend hashing nodes with node
+func frontierAppendWith(node func(left, right [sha256.Size]byte) [sha256.Size]byte, frontier [][sha256.Size]byte, size uint64, leaf [sha256.Size]byte) [][sha256.Size]byte {
+	h := leaf
+	for size&1 == 1 {
+		h = node(frontier[len(frontier)-1], h)
+		frontier = frontier[:len(frontier)-1]
+		size = size >> 1
+	}
+	return append(frontier, h)
+}
+
+// frontierRoot returns the merkle root of the tree described by frontier
+func frontierRoot(frontier [][sha256.Size]byte) [sha256.Size]byte {
+	return frontierRootWith(sha256NodeHash, sha256.Sum256(nil), frontier)
+}
+
+// frontierRootWith is frontierRoot hashing nodes with node, and returning empty for
+// the empty tree
+func frontierRootWith(node func(left, right [sha256.Size]byte) [sha256.Size]byte, empty [sha256.Size]byte, frontier [][sha256.Size]byte) [sha256.Size]byte {
+	if len(frontier) == 0 {
+		return empty
+	}
+
+	root := frontier[len(frontier)-1]
+	for i := len(frontier) - 2; i >= 0; i-- {
+		root = node(frontier[i], root)
+	}
+	return root
+}
+
+// sha256NodeHash returns the SHA-256 hash of the node with the given children
+func sha256NodeHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	return nodeHash(append(left[:], right[:]...))
+}
+
+// validFrontier reports whether frontier has the shape of a frontier of a tree of size leaves
+func validFrontier(frontier [][sha256.Size]byte, size uint64) bool {
+	return len(frontier) == bits.OnesCount64(size)
+}
+
+// frontier returns the frontier of the tree of the first size leaves. The roots
+// of perfect subtrees never change once complete, so they are read from the
+// stored levels.
+func (m *MerkleHashTree) frontier(size uint64) [][sha256.Size]byte {
+	sizes := PerfectSubtreeDecomposition(size)
+	frontier := make([][sha256.Size]byte, 0, len(sizes))
+	start := uint64(0)
+	for _, s := range sizes {
+		level := bits.TrailingZeros64(s)
+		frontier = append(frontier, m.tree[level][start>>uint(level)])
+		start += s
+	}
+	return frontier
+}
+
+// Frontier returns the frontier of the tree of the first size leaves: the roots of
+// the perfect subtrees it decomposes into, from the leftmost to the rightmost. It is
+// enough to compute the root of that tree with FrontierRoot and to prove the
+// consistency of later trees with ConsistencyFromFrontier. Those hash with SHA-256,
+// so the frontier of a tree over another Hasher fails with ErrUnsupportedHash.
+func (m *MerkleHashTree) Frontier(size uint64) ([][sha256.Size]byte, error) {
+	defer m.readLock()()
+
+	if name := m.algorithm(); name != "" {
+		return nil, fmt.Errorf("%w: frontier of a tree over %s", ErrUnsupportedHash, name)
+	}
+	if size > uint64(len(m.tree[0])) {
+		return nil, fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, size, len(m.tree[0]))
+	}
+	return m.frontier(size), nil
+}
+
+// FrontierRoot returns the merkle root of the tree with the given frontier
+func FrontierRoot(frontier [][sha256.Size]byte) [sha256.Size]byte {
+	return frontierRoot(frontier)
+}
+
+// ConsistencyFromFrontier returns the consistency proof between the tree of oldSize
+// leaves with frontier oldFrontier and the tree extending it with the leaves whose
+// leaf hashes are newLeaves. Every node of the proof either is a root of the old
+// frontier or only covers new leaves, so no other part of the old tree is needed.
+func ConsistencyFromFrontier(oldSize uint64, oldFrontier [][sha256.Size]byte, newLeaves [][sha256.Size]byte) ([][sha256.Size]byte, error) {
+	if !validFrontier(oldFrontier, oldSize) {
+		return nil, fmt.Errorf("%w: %d frontier hashes for size %d", ErrInvalidRange, len(oldFrontier), oldSize)
+	}
+
+	proof := make([][sha256.Size]byte, 0)
+	if oldSize == 0 || len(newLeaves) == 0 {
+		return proof, nil
+	}
+
+	b := frontierProof{size: oldSize, frontier: oldFrontier, leaves: newLeaves}
+	return b.subProof(oldSize, 0, oldSize+uint64(len(newLeaves)), true, proof), nil
+}
+
+// VerifyConsistencyWithFrontier checks that proof is the consistency proof between
+// the tree of oldSize leaves with frontier oldFrontier and the tree of newSize
+// leaves with root newRoot, for light clients that keep the frontier of the tree
+// they trust rather than the tree. Every node of a consistency proof left of the
+// old size is a root of the old frontier, see ConsistencyFromFrontier, and one
+// that is not the root held by the client fails with ErrFrontierMismatch naming
+// it, before the proof is verified against the root of the frontier.
+func VerifyConsistencyWithFrontier(oldSize uint64, oldFrontier [][sha256.Size]byte, newSize uint64, newRoot [sha256.Size]byte, proof [][sha256.Size]byte) error {
+	if !validFrontier(oldFrontier, oldSize) {
+		return fmt.Errorf("%w: %d frontier hashes for size %d", ErrInvalidRange, len(oldFrontier), oldSize)
+	}
+
+	if oldSize > 0 && oldSize < newSize {
+		ranges := consistencyRanges(oldSize, 0, newSize, true)
+		if len(ranges) == len(proof) {
+			roots := make(map[[2]uint64]int, len(oldFrontier))
+			start := uint64(0)
+			for k, size := range PerfectSubtreeDecomposition(oldSize) {
+				roots[[2]uint64{start, start + size}] = k
+				start += size
+			}
+			for j, r := range ranges {
+				k, ok := roots[r]
+				if ok && proof[j] != oldFrontier[k] {
+					return fmt.Errorf("%w: root %d of the frontier, covering leaves [%d, %d)", ErrFrontierMismatch, k, r[0], r[1])
+				}
+			}
+		}
+	}
+
+	p := ConsistencyProof{OldSize: oldSize, NewSize: newSize, Hashes: proof}
+	return VerifyConsistency(frontierRoot(oldFrontier), newRoot, p)
+}
+
+// frontierProof builds the SUBPROOF of RFC 6962 section 2.1.2 from the frontier of the
+// old tree and the leaf hashes appended to it. Going down the tree, the complete left
+// subtrees left of the old size are the roots of the frontier, in order.
+type frontierProof struct {
+	size     uint64
+	frontier [][sha256.Size]byte
+	next     int
+	leaves   [][sha256.Size]byte
+}
+
+// subProof appends SUBPROOF(m, D[start:end], known) to proof
+func (b *frontierProof) subProof(m, start, end uint64, known bool, proof [][sha256.Size]byte) [][sha256.Size]byte {
+	n := end - start
+	if m == n {
+		if known {
+			return proof
+		}
+		return append(proof, frontierRoot(b.frontier[b.next:]))
+	}
+
+	k := SplitPoint(n)
+	if m <= k {
+		proof = b.subProof(m, start, start+k, known, proof)
+		return append(proof, treeHash(start+k-b.size, end-b.size, b.leaf))
+	}
+
+	left := b.frontier[b.next]
+	b.next++
+	proof = b.subProof(m-k, start+k, end, false, proof)
+	return append(proof, left)
+}
+
+func (b *frontierProof) leaf(i uint64) [sha256.Size]byte {
+	return b.leaves[i]
+}
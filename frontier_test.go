@@ -0,0 +1,81 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistencyFromFrontier(t *testing.T) {
+	D := makeEntries(70)
+	tree := New(D)
+	for m := uint64(0); m <= uint64(len(D)); m++ {
+		oldFrontier, err := tree.Frontier(m)
+		assert.NoError(t, err)
+		oldRoot := FrontierRoot(oldFrontier)
+		assert.Equal(t, MTH(D[:m]), oldRoot)
+
+		for n := m; n <= uint64(len(D)); n++ {
+			proof, err := ConsistencyFromFrontier(m, oldFrontier, tree.tree[0][m:n])
+			assert.NoError(t, err)
+			expected, err := tree.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			assert.Equal(t, expected.Hashes, proof, "m=%d n=%d", m, n)
+
+			newFrontier, err := tree.Frontier(n)
+			assert.NoError(t, err)
+			p := ConsistencyProof{OldSize: m, NewSize: n, Hashes: proof}
+			assert.NoError(t, VerifyConsistency(oldRoot, FrontierRoot(newFrontier), p), "m=%d n=%d", m, n)
+		}
+	}
+}
+
+func TestConsistencyFromFrontierRejectsInvalidFrontier(t *testing.T) {
+	tree := New(makeEntries(8))
+	frontier, err := tree.Frontier(6)
+	assert.NoError(t, err)
+
+	_, err = ConsistencyFromFrontier(7, frontier, tree.tree[0][7:])
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = tree.Frontier(9)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestVerifyConsistencyWithFrontier(t *testing.T) {
+	D := makeEntries(40)
+	tree := New(D)
+	for m := uint64(0); m <= 40; m++ {
+		frontier, err := tree.Frontier(m)
+		assert.NoError(t, err)
+		for _, n := range []uint64{m, m + 1, 27, 40} {
+			if n < m || n > 40 {
+				continue
+			}
+			proof, err := tree.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			newRoot := tree.rootAtSize(n)
+			assert.NoError(t, VerifyConsistencyWithFrontier(m, frontier, n, newRoot, proof.Hashes), "%d to %d", m, n)
+
+			if m == 0 || m == n {
+				continue
+			}
+			// The proof is valid for the true old root, but not for a frontier with
+			// any of its roots replaced
+			assert.NoError(t, VerifyConsistency(tree.rootAtSize(m), newRoot, proof))
+			for k := range frontier {
+				forged := copyHashes(frontier)
+				forged[k][0] ^= 1
+				err := VerifyConsistencyWithFrontier(m, forged, n, newRoot, proof.Hashes)
+				assert.Error(t, err, "%d to %d, root %d", m, n, k)
+				if len(frontier) > 1 || m&(m-1) != 0 {
+					assert.ErrorIs(t, err, ErrFrontierMismatch, "%d to %d, root %d", m, n, k)
+				}
+			}
+			assert.Error(t, VerifyConsistencyWithFrontier(m, frontier, n, tree.rootAtSize(n-1), proof.Hashes))
+		}
+	}
+
+	frontier, err := tree.Frontier(5)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, VerifyConsistencyWithFrontier(7, frontier, 10, tree.rootAtSize(10), nil), ErrInvalidRange)
+}
@@ -0,0 +1,326 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// maxFuzzLeaves bounds the trees built from a fuzz input
+const maxFuzzLeaves = 300
+
+// fuzzImpl is one way of computing the root and proofs of the tree of the entries
+// D. Proofs are for the tree of all of D. A nil inclusion or consistency means the
+// implementation cannot produce that proof.
+type fuzzImpl struct {
+	name        string
+	root        func(D [][]byte) [sha256.Size]byte
+	inclusion   func(D [][]byte, i uint64) ([][sha256.Size]byte, error)
+	consistency func(D [][]byte, m uint64) ([][sha256.Size]byte, error)
+}
+
+// fuzzScheme is a tree construction along with every implementation of it and
+// every verifier of its proofs. The fuzz targets check all implementations of a
+// scheme against each other and all verifiers against every implementation, so a
+// new mode is cross-checked by adding it here.
+type fuzzScheme struct {
+	name                 string
+	leafHash             func(i uint64, d []byte) [sha256.Size]byte
+	impls                []fuzzImpl
+	inclusionVerifiers   map[string]func(leafHash, root [sha256.Size]byte, p InclusionProof) error
+	consistencyVerifiers map[string]func(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error
+}
+
+// treeImpl checks a MerkleHashTree built by build from D
+func treeImpl(name string, build func(D [][]byte) *MerkleHashTree) fuzzImpl {
+	return fuzzImpl{
+		name: name,
+		root: func(D [][]byte) [sha256.Size]byte {
+			return build(D).MerkleRoot()
+		},
+		inclusion: func(D [][]byte, i uint64) ([][sha256.Size]byte, error) {
+			p, err := build(D).InclusionProofByIndex(i)
+			return p.Hashes, err
+		},
+		consistency: func(D [][]byte, m uint64) ([][sha256.Size]byte, error) {
+			p, err := build(D).ConsistencyProof(m, uint64(len(D)))
+			return p.Hashes, err
+		},
+	}
+}
+
+// appendedOneByOne builds a tree by appending the entries of D one at a time
+func appendedOneByOne(opts ...Option) func(D [][]byte) *MerkleHashTree {
+	return func(D [][]byte) *MerkleHashTree {
+		tree := New(nil, opts...)
+		for _, d := range D {
+			tree.Append(d)
+		}
+		return tree
+	}
+}
+
+// grownTree builds a tree with more leaves than D, so that its proofs of D are
+// proofs at a past size of the tree
+func grownTree(opts ...Option) func(D [][]byte) *MerkleHashTree {
+	return func(D [][]byte) *MerkleHashTree {
+		return New(append(append([][]byte(nil), D...), []byte("later"), []byte("leaves")), opts...)
+	}
+}
+
+func pastSizeImpl(name string, build func(D [][]byte) *MerkleHashTree) fuzzImpl {
+	return fuzzImpl{
+		name: name,
+		root: func(D [][]byte) [sha256.Size]byte {
+			tree := build(D)
+			defer tree.readLock()()
+			if len(D) == 0 {
+				return sha256.Sum256(nil)
+			}
+			return tree.mthOfRange(0, len(D)-1)
+		},
+		inclusion: func(D [][]byte, i uint64) ([][sha256.Size]byte, error) {
+			p, err := build(D).InclusionProofAtSize(i, uint64(len(D)))
+			return p.Hashes, err
+		},
+		consistency: func(D [][]byte, m uint64) ([][sha256.Size]byte, error) {
+			p, err := build(D).ConsistencyProof(m, uint64(len(D)))
+			return p.Hashes, err
+		},
+	}
+}
+
+var fuzzSchemes = []fuzzScheme{
+	{
+		name: "rfc6962",
+		leafHash: func(_ uint64, d []byte) [sha256.Size]byte {
+			return leafHash(d)
+		},
+		impls: []fuzzImpl{
+			{
+				name: "stateless",
+				root: MTH,
+				inclusion: func(D [][]byte, i uint64) ([][sha256.Size]byte, error) {
+					return Path(i, D), nil
+				},
+				consistency: func(D [][]byte, m uint64) ([][sha256.Size]byte, error) {
+					return Proof(m, D), nil
+				},
+			},
+			treeImpl("tree", func(D [][]byte) *MerkleHashTree { return New(D) }),
+			treeImpl("appended", appendedOneByOne()),
+			treeImpl("deferred", appendedOneByOne(WithDeferredHashing())),
+			pastSizeImpl("past-size", grownTree()),
+			{
+				name: "frontier",
+				root: func(D [][]byte) [sha256.Size]byte {
+					return FrontierRoot(New(D).frontier(uint64(len(D))))
+				},
+				consistency: func(D [][]byte, m uint64) ([][sha256.Size]byte, error) {
+					tree := New(D)
+					frontier, err := tree.Frontier(m)
+					if err != nil {
+						return nil, err
+					}
+					return ConsistencyFromFrontier(m, frontier, tree.tree[0][m:])
+				},
+			},
+		},
+		inclusionVerifiers: map[string]func(leafHash, root [sha256.Size]byte, p InclusionProof) error{
+			"verify": verify.VerifyInclusion,
+			"verify-into": func(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+				var scratch [sha256.Size]byte
+				return verify.VerifyInclusionInto(&scratch, &leafHash, &root, p.LeafIndex, p.TreeSize, p.Hashes)
+			},
+			"partial": func(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+				return NewPartialTree(TreeHead{TreeSize: p.TreeSize, RootHash: root}).AddProof(leafHash, p.LeafIndex, p.Hashes)
+			},
+			"bound": func(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+				return VerifyInclusionBound(leafHash, BoundRoot(p.TreeSize, root), p)
+			},
+		},
+		consistencyVerifiers: map[string]func(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error{
+			"verify": verify.VerifyConsistency,
+			"verify-into": func(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error {
+				var oldScratch, newScratch [sha256.Size]byte
+				return verify.VerifyConsistencyInto(&oldScratch, &newScratch, &oldRoot, &newRoot, p.OldSize, p.NewSize, p.Hashes)
+			},
+			"bound": func(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error {
+				return VerifyConsistencyBound(oldRoot, BoundRoot(p.OldSize, oldRoot), BoundRoot(p.NewSize, newRoot), p)
+			},
+		},
+	},
+	{
+		name:     "index-bound",
+		leafHash: IndexBoundLeafHash,
+		impls: []fuzzImpl{
+			treeImpl("tree", func(D [][]byte) *MerkleHashTree { return New(D, WithIndexBoundLeaves()) }),
+			treeImpl("appended", appendedOneByOne(WithIndexBoundLeaves())),
+			treeImpl("deferred", appendedOneByOne(WithIndexBoundLeaves(), WithDeferredHashing())),
+			pastSizeImpl("past-size", grownTree(WithIndexBoundLeaves())),
+		},
+		inclusionVerifiers: map[string]func(leafHash, root [sha256.Size]byte, p InclusionProof) error{
+			"verify": verify.VerifyInclusion,
+		},
+		consistencyVerifiers: map[string]func(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error{
+			"verify": verify.VerifyConsistency,
+		},
+	},
+}
+
+// fuzzLeaves splits data into entries, each prefixed by its length in one byte
+func fuzzLeaves(data []byte) [][]byte {
+	var D [][]byte
+	for len(data) > 0 && len(D) < maxFuzzLeaves {
+		n := int(data[0])
+		data = data[1:]
+		if n > len(data) {
+			n = len(data)
+		}
+		D = append(D, data[:n])
+		data = data[n:]
+	}
+	return D
+}
+
+// encodeFuzzLeaves is the inverse of fuzzLeaves
+func encodeFuzzLeaves(D [][]byte) []byte {
+	var data []byte
+	for _, d := range D {
+		data = append(data, byte(len(d)))
+		data = append(data, d...)
+	}
+	return data
+}
+
+// addFuzzSeeds seeds f with the leaves of the RFC 6962 reference test vectors and
+// every tree size up to the number of leaves, passing the size to args
+func addFuzzSeeds(f *testing.F, args func(size uint16) []interface{}) {
+	var D [][]byte
+	for _, l := range []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"} {
+		d, err := hex.DecodeString(l)
+		if err != nil {
+			f.Fatal(err)
+		}
+		D = append(D, d)
+	}
+	for n := 0; n <= len(D); n++ {
+		f.Add(append([]interface{}{encodeFuzzLeaves(D[:n])}, args(uint16(n))...)...)
+	}
+	f.Add(append([]interface{}{encodeFuzzLeaves(makeEntries(33))}, args(33)...)...)
+}
+
+func FuzzRootsAgree(f *testing.F) {
+	addFuzzSeeds(f, func(uint16) []interface{} { return nil })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		D := fuzzLeaves(data)
+		for _, s := range fuzzSchemes {
+			want := s.impls[0].root(D)
+			for _, impl := range s.impls[1:] {
+				if got := impl.root(D); got != want {
+					t.Errorf("%s: %s root %x, %s root %x", s.name, impl.name, got, s.impls[0].name, want)
+				}
+			}
+		}
+	})
+}
+
+func FuzzInclusionRoundTrip(f *testing.F) {
+	addFuzzSeeds(f, func(size uint16) []interface{} { return []interface{}{size / 2} })
+	f.Fuzz(func(t *testing.T, data []byte, index uint16) {
+		D := fuzzLeaves(data)
+		if len(D) == 0 {
+			return
+		}
+		n := uint64(len(D))
+		i := uint64(index) % n
+
+		for _, s := range fuzzSchemes {
+			root := s.impls[0].root(D)
+			leaf := s.leafHash(i, D[i])
+			var want [][sha256.Size]byte
+			for _, impl := range s.impls {
+				if impl.inclusion == nil {
+					continue
+				}
+				hashes, err := impl.inclusion(D, i)
+				if err != nil {
+					t.Fatalf("%s: %s: inclusion proof of %d in %d: %v", s.name, impl.name, i, n, err)
+				}
+				if want == nil {
+					want = hashes
+				} else if !equalHashes(hashes, want) {
+					t.Errorf("%s: %s: inclusion proof of %d in %d differs", s.name, impl.name, i, n)
+				}
+
+				p := InclusionProof{LeafIndex: i, TreeSize: n, Hashes: hashes}
+				for name, verifier := range s.inclusionVerifiers {
+					if err := verifier(leaf, root, p); err != nil {
+						t.Errorf("%s: %s proof of %d in %d rejected by %s: %v", s.name, impl.name, i, n, name, err)
+					}
+					if err := verifier(s.leafHash(i, append(append([]byte(nil), D[i]...), 0)), root, p); err == nil {
+						t.Errorf("%s: %s proof of %d in %d accepted another leaf by %s", s.name, impl.name, i, n, name)
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzConsistencyRoundTrip(f *testing.F) {
+	addFuzzSeeds(f, func(size uint16) []interface{} { return []interface{}{size / 2} })
+	f.Fuzz(func(t *testing.T, data []byte, old uint16) {
+		D := fuzzLeaves(data)
+		if len(D) == 0 {
+			return
+		}
+		n := uint64(len(D))
+		m := uint64(old)%n + 1
+
+		for _, s := range fuzzSchemes {
+			oldRoot, newRoot := s.impls[0].root(D[:m]), s.impls[0].root(D)
+			var want [][sha256.Size]byte
+			for _, impl := range s.impls {
+				if impl.consistency == nil {
+					continue
+				}
+				hashes, err := impl.consistency(D, m)
+				if err != nil {
+					t.Fatalf("%s: %s: consistency proof of %d to %d: %v", s.name, impl.name, m, n, err)
+				}
+				if want == nil {
+					want = hashes
+				} else if !equalHashes(hashes, want) {
+					t.Errorf("%s: %s: consistency proof of %d to %d differs", s.name, impl.name, m, n)
+				}
+
+				p := ConsistencyProof{OldSize: m, NewSize: n, Hashes: hashes}
+				for name, verifier := range s.consistencyVerifiers {
+					if err := verifier(oldRoot, newRoot, p); err != nil {
+						t.Errorf("%s: %s proof of %d to %d rejected by %s: %v", s.name, impl.name, m, n, name, err)
+					}
+					if m < n {
+						if err := verifier(oldRoot, s.impls[0].root(D[:n-1]), p); err == nil {
+							t.Errorf("%s: %s proof of %d to %d accepted another root by %s", s.name, impl.name, m, n, name)
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+func equalHashes(a, b [][sha256.Size]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i][:], b[i][:]) {
+			return false
+		}
+	}
+	return true
+}
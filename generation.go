@@ -0,0 +1,18 @@
+package merkletree
+
+// Generation returns a counter incremented by every successful mutation of the tree:
+// each accepted Append, TryAppend, AppendIf or AppendWithExtra batch, Truncate,
+// SetLeaf and ApplyNodeDeltas. Things derived from the tree are still current while
+// the generation has not changed. It starts at zero for a new tree, and
+// UnmarshalBinary restores the generation of the tree it loads.
+func (m *MerkleHashTree) Generation() uint64 {
+	defer m.readLock()()
+	return m.generation
+}
+
+// GenerationHead returns the tree head along with the generation it belongs to,
+// read together so that they are consistent under WithLocking.
+func (m *MerkleHashTree) GenerationHead() (TreeHead, uint64) {
+	defer m.readLock()()
+	return TreeHead{TreeSize: uint64(len(m.tree[0])), RootHash: m.root()}, m.generation
+}
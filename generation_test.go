@@ -0,0 +1,83 @@
+package merkletree
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerationCountsMutations(t *testing.T) {
+	D := makeEntries(8)
+	for _, opts := range [][]Option{nil, {WithDeferredHashing()}, {WithMaxLeaves(6)}} {
+		tree := New(nil, opts...)
+		assert.Equal(t, uint64(0), tree.Generation())
+
+		tree.Append(D[:3]...)
+		tree.Append(D[3])
+		assert.Equal(t, uint64(2), tree.Generation())
+
+		// Empty and rejected batches do not change the tree
+		tree.Append()
+		_, err := tree.AppendIf([32]byte{}, D[4])
+		assert.Error(t, err)
+		assert.Equal(t, uint64(2), tree.Generation())
+
+		_, err = tree.AppendWithExtra(D[4], []byte("extra"))
+		assert.NoError(t, err)
+		_, err = tree.SetLeaf(1, D[7])
+		assert.NoError(t, err)
+		_, err = tree.Truncate(3)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), tree.Generation())
+
+		_, err = tree.Truncate(4)
+		assert.ErrorIs(t, err, ErrInvalidRange)
+		_, err = tree.SetLeaf(3, D[0])
+		assert.ErrorIs(t, err, ErrIndexOutOfRange)
+		assert.Equal(t, uint64(5), tree.Generation())
+
+		head, generation := tree.GenerationHead()
+		assert.Equal(t, tree.TreeHead(), head)
+		assert.Equal(t, uint64(5), generation)
+	}
+
+	full := New(D[:2], WithMaxLeaves(2))
+	_, err := full.TryAppend(D[2])
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Equal(t, uint64(0), full.Generation())
+}
+
+func TestGenerationFollowsNodeDeltas(t *testing.T) {
+	D := makeEntries(7)
+	primary := New(nil, WithNodeDeltas())
+	replica := New(nil)
+	for _, d := range D {
+		primary.Append(d)
+		_, err := replica.ApplyNodeDeltas(primary.TreeHead(), primary.LastAppendDelta())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, primary.Generation(), replica.Generation())
+}
+
+func TestGenerationHeadConsistentUnderLocking(t *testing.T) {
+	D := makeEntries(64)
+	tree := New(nil, WithLocking())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, d := range D {
+			tree.Append(d)
+		}
+	}()
+
+	// Every append adds a single leaf, so the generation is the tree size
+	for i := 0; i < 200; i++ {
+		head, generation := tree.GenerationHead()
+		assert.Equal(t, head.TreeSize, generation)
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(len(D)), tree.Generation())
+}
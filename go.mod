@@ -2,10 +2,26 @@ module github.com/viveksyngh/merkletree
 
 go 1.19
 
-require github.com/stretchr/testify v1.8.1
+require (
+	github.com/cosmos/ics23/go v0.10.0
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/klauspost/cpuid/v2 v2.0.9
+	github.com/minio/sha256-simd v1.0.0
+	github.com/stretchr/testify v1.8.1
+	google.golang.org/grpc v1.58.3
+	google.golang.org/protobuf v1.31.0
+	lukechampine.com/blake3 v1.3.0
+)
 
 require (
+	github.com/cosmos/gogoproto v1.4.3 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
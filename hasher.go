@@ -0,0 +1,468 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// Errors returned for hash functions other than SHA-256
+var (
+	ErrUnsupportedHash = errors.New("merkletree: unsupported hash function")
+	ErrHashMismatch    = verify.ErrHashMismatch
+)
+
+// Hasher hashes the leaves and nodes of RFC 6962 trees with a hash function other
+// than SHA-256, for trees matching formats defined over another hash. The zero
+// Hasher hashes with SHA-256.
+//
+// A tree created WithHasher builds, appends and proves with it, and tags its proofs
+// with the name of the Hasher, so that the package level verification functions
+// reject them with ErrHashMismatch. Frontiers, bundled logs and node bundles are
+// verified with SHA-256 by the package level functions, so Frontier, NewBundledLog
+// and ExtractBundle fail with ErrUnsupportedHash for trees over another hash, and
+// sparse trees only hash with SHA-256. Use the methods of the Hasher to compute and
+// verify roots and proofs instead.
+//
+// Trees and the methods over [32]byte hashes take hash functions with 32-byte
+// digests, like SHA-512/256 or BLAKE2b-256, and panic with ErrUnsupportedHash for
+// others. The Digest methods compute and verify roots and proofs over digests of
+// any size, like those of SHA-512 or BLAKE2b-512.
+type Hasher struct {
+	name string
+	code uint64
+	new  func() hash.Hash
+	// unprefixed hashes leaves and nodes without the prefixes of RFC 6962, as
+	// BitcoinHasher does
+	unprefixed bool
+}
+
+// SHA256Hasher is the default hasher
+var SHA256Hasher = Hasher{name: verify.SHA256Algorithm, code: MultihashSHA256, new: sha256.New}
+
+// NewHasher returns a hasher named name hashing with the hash function returned by
+// h, whose multicodec code is code, e.g. the code of sha2-512-256 with
+// sha512.New512_256. The code identifies the hash function in the multihash of roots,
+// so that roots of trees over different hash functions are told apart, and the name
+// tags the proofs of the hasher. A hash function without digests fails with
+// ErrUnsupportedHash.
+func NewHasher(name string, code uint64, h func() hash.Hash) (Hasher, error) {
+	if size := h().Size(); size <= 0 {
+		return Hasher{}, fmt.Errorf("%w: %s has %d-byte digests", ErrUnsupportedHash, name, size)
+	}
+	return Hasher{name: name, code: code, new: h}, nil
+}
+
+// WithHasher hashes the leaves and nodes of the tree with h, see Hasher. It takes
+// the place of the backend set WithHashBackend. A hasher without 32-byte digests
+// fails TryNew with ErrUnsupportedHash.
+func WithHasher(h Hasher) Option {
+	return func(m *MerkleHashTree) {
+		if h.new == nil {
+			m.hasher = nil
+			return
+		}
+		m.hasher = &h
+	}
+}
+
+// Hasher returns the hasher of the tree
+func (m *MerkleHashTree) Hasher() Hasher {
+	if m.hasher == nil {
+		return SHA256Hasher
+	}
+	return *m.hasher
+}
+
+// Name returns the name of the hash function
+func (h Hasher) Name() string {
+	if h.new == nil {
+		return SHA256Hasher.name
+	}
+	return h.name
+}
+
+// Size returns the size of the digests of the hash function in bytes
+func (h Hasher) Size() int {
+	if h.new == nil {
+		return sha256.Size
+	}
+	return h.new().Size()
+}
+
+// fitsTree fails with ErrUnsupportedHash unless the digests of h fit the hashes of
+// a tree
+func (h Hasher) fitsTree() error {
+	if size := h.Size(); size != sha256.Size {
+		return fmt.Errorf("%w: %s has %d-byte digests", ErrUnsupportedHash, h.Name(), size)
+	}
+	return nil
+}
+
+// algorithm returns the tag of the proofs of trees over h, empty for SHA-256
+func (h Hasher) algorithm() string {
+	if name := h.Name(); name != verify.SHA256Algorithm {
+		return name
+	}
+	return ""
+}
+
+// checkAlgorithm fails with ErrHashMismatch unless algorithm, the tag of a proof,
+// names h
+func (h Hasher) checkAlgorithm(algorithm string) error {
+	if algorithm == "" {
+		algorithm = verify.SHA256Algorithm
+	}
+	if algorithm != h.Name() {
+		return fmt.Errorf("%w: proof of %s, not %s", ErrHashMismatch, algorithm, h.Name())
+	}
+	return nil
+}
+
+// prefix returns the domain separation prefix p, or nothing for an unprefixed hasher
+func (h Hasher) prefix(p byte) []byte {
+	if h.unprefixed {
+		return nil
+	}
+	return []byte{p}
+}
+
+// digest returns the digest of the concatenation of parts
+func (h Hasher) digest(parts ...[]byte) []byte {
+	if h.new == nil {
+		h = SHA256Hasher
+	}
+	d := h.new()
+	for _, p := range parts {
+		d.Write(p)
+	}
+	return d.Sum(nil)
+}
+
+// sum returns the digest of the concatenation of parts as a hash of a tree,
+// panicking when it does not fit
+func (h Hasher) sum(parts ...[]byte) [sha256.Size]byte {
+	if err := h.fitsTree(); err != nil {
+		panic(err)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.digest(parts...))
+	return sum
+}
+
+// LeafHash returns the hash of the leaf with data d: H(0x00 || d), or H(d) for
+// BitcoinHasher
+func (h Hasher) LeafHash(d []byte) [sha256.Size]byte {
+	return h.sum(h.prefix(LeafPrefix), d)
+}
+
+// NodeHash returns the hash of the node with the given children: H(0x01 || left ||
+// right), or H(left || right) for BitcoinHasher
+func (h Hasher) NodeHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	return h.sum(h.prefix(NodePrefix), left[:], right[:])
+}
+
+// EmptyRoot returns the root of the empty tree, the hash of the empty string
+func (h Hasher) EmptyRoot() [sha256.Size]byte {
+	return h.sum()
+}
+
+// HashMany hashes inputs one after another, making h the HashBackend of trees
+// created WithHasher
+func (h Hasher) HashMany(inputs [][]byte) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, len(inputs))
+	for i, in := range inputs {
+		hashes[i] = h.sum(in)
+	}
+	return hashes
+}
+
+// MTH returns the Merkle Tree Hash of D, like the package level MTH
+func (h Hasher) MTH(D [][]byte) [sha256.Size]byte {
+	return New(D, WithHasher(h)).MerkleRoot()
+}
+
+// Path returns the audit path of the leaf at index m of D, like the package level
+// Path
+func (h Hasher) Path(m uint64, D [][]byte) [][sha256.Size]byte {
+	p, err := New(D, WithHasher(h)).InclusionProofByIndex(m)
+	if err != nil {
+		return nil
+	}
+	return p.Hashes
+}
+
+// Proof returns the consistency proof between the trees of D[0:m] and D, like the
+// package level Proof
+func (h Hasher) Proof(m uint64, D [][]byte) [][sha256.Size]byte {
+	if m == 0 || m > uint64(len(D)) {
+		return nil
+	}
+	return New(D, WithHasher(h)).ConsitencyProof(m, uint64(len(D)))
+}
+
+// VerifyInclusion checks that leafHash is included in the tree with the given root
+// at the position and tree size described by p, like the package level
+// VerifyInclusion. A proof tagged with another algorithm fails with ErrHashMismatch.
+func (h Hasher) VerifyInclusion(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+	if err := h.checkAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	calculated, err := verify.RootFromInclusionProofWith(h.NodeHash, leafHash, p)
+	if err != nil {
+		return err
+	}
+	if calculated != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// VerifyConsistency checks that the tree with root newRoot is an append-only
+// extension of the tree with root oldRoot, like the package level VerifyConsistency.
+// A proof tagged with another algorithm fails with ErrHashMismatch.
+func (h Hasher) VerifyConsistency(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error {
+	if err := h.checkAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	m, n := p.OldSize, p.NewSize
+	if m > n {
+		return fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	if m == 0 || m == n {
+		if len(p.Hashes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if m == n && oldRoot != newRoot {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	fr, sr, err := verify.RootsFromConsistencyProofWith(h.NodeHash, oldRoot, p)
+	if err != nil {
+		return err
+	}
+	if fr != oldRoot {
+		return fmt.Errorf("%w: reconstructed old root does not match", ErrRootMismatch)
+	}
+	if sr != newRoot {
+		return fmt.Errorf("%w: reconstructed new root does not match", ErrRootMismatch)
+	}
+	return nil
+}
+
+// LeafDigest returns the digest of the leaf with data d, like LeafHash, for a hash
+// function of any digest size
+func (h Hasher) LeafDigest(d []byte) []byte {
+	return h.digest(h.prefix(LeafPrefix), d)
+}
+
+// NodeDigest returns the digest of the node with the given children, like NodeHash,
+// for a hash function of any digest size
+func (h Hasher) NodeDigest(left, right []byte) []byte {
+	return h.digest(h.prefix(NodePrefix), left, right)
+}
+
+// DigestMTH returns the Merkle Tree Hash of D, like MTH, for a hash function of any
+// digest size
+func (h Hasher) DigestMTH(D [][]byte) []byte {
+	return h.digestMTH(h.leafDigests(D))
+}
+
+// DigestPath returns the inclusion proof of the leaf at index m of D, like Path,
+// for a hash function of any digest size. An index outside of D fails with
+// ErrIndexOutOfRange.
+func (h Hasher) DigestPath(m uint64, D [][]byte) (DigestInclusionProof, error) {
+	n := uint64(len(D))
+	if m >= n {
+		return DigestInclusionProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, m, n)
+	}
+	hashes := h.digestPath(m, h.leafDigests(D))
+	return DigestInclusionProof{Algorithm: h.Name(), LeafIndex: m, TreeSize: n, Hashes: hashes}, nil
+}
+
+// DigestProof returns the consistency proof between the trees of D[0:m] and D, like
+// Proof, for a hash function of any digest size. An m larger than D fails with
+// ErrInvalidRange.
+func (h Hasher) DigestProof(m uint64, D [][]byte) (DigestConsistencyProof, error) {
+	n := uint64(len(D))
+	if m > n {
+		return DigestConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	p := DigestConsistencyProof{Algorithm: h.Name(), OldSize: m, NewSize: n, Hashes: make([][]byte, 0)}
+	if m > 0 {
+		p.Hashes = h.digestSubProof(m, h.leafDigests(D), true)
+	}
+	return p, nil
+}
+
+// VerifyDigestInclusion checks that leafDigest is included in the tree with the
+// given root, like VerifyInclusion, for a hash function of any digest size. A proof
+// tagged with another algorithm, or holding a digest of another size, fails with
+// ErrHashMismatch.
+func (h Hasher) VerifyDigestInclusion(leafDigest, root []byte, p DigestInclusionProof) error {
+	if err := h.checkDigests(p.Algorithm, append([][]byte{leafDigest, root}, p.Hashes...)); err != nil {
+		return err
+	}
+	calculated, err := verify.RootFromDigestInclusionProof(h.NodeDigest, leafDigest, p)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(calculated, root) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// VerifyDigestConsistency checks that the tree with root newRoot is an append-only
+// extension of the tree with root oldRoot, like VerifyConsistency, for a hash
+// function of any digest size. A proof tagged with another algorithm, or holding a
+// digest of another size, fails with ErrHashMismatch.
+func (h Hasher) VerifyDigestConsistency(oldRoot, newRoot []byte, p DigestConsistencyProof) error {
+	if err := h.checkDigests(p.Algorithm, append([][]byte{oldRoot, newRoot}, p.Hashes...)); err != nil {
+		return err
+	}
+	m, n := p.OldSize, p.NewSize
+	if m > n {
+		return fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	if m == 0 || m == n {
+		if len(p.Hashes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if m == n && !bytes.Equal(oldRoot, newRoot) {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	fr, sr, err := verify.RootsFromDigestConsistencyProof(h.NodeDigest, oldRoot, p)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(fr, oldRoot) {
+		return fmt.Errorf("%w: reconstructed old root does not match", ErrRootMismatch)
+	}
+	if !bytes.Equal(sr, newRoot) {
+		return fmt.Errorf("%w: reconstructed new root does not match", ErrRootMismatch)
+	}
+	return nil
+}
+
+// checkDigests fails with ErrHashMismatch unless algorithm names h and the digests
+// have its size
+func (h Hasher) checkDigests(algorithm string, digests [][]byte) error {
+	if err := h.checkAlgorithm(algorithm); err != nil {
+		return err
+	}
+	size := h.Size()
+	for _, d := range digests {
+		if len(d) != size {
+			return fmt.Errorf("%w: digest of %d bytes, %s has %d", ErrHashMismatch, len(d), h.Name(), size)
+		}
+	}
+	return nil
+}
+
+// leafDigests returns the leaf digests of D
+func (h Hasher) leafDigests(D [][]byte) [][]byte {
+	leaves := make([][]byte, len(D))
+	for i, d := range D {
+		leaves[i] = h.LeafDigest(d)
+	}
+	return leaves
+}
+
+// digestMTH returns MTH of the tree of the given leaf digests
+func (h Hasher) digestMTH(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return h.digest()
+	case 1:
+		return leaves[0]
+	}
+	k := SplitPoint(uint64(len(leaves)))
+	return h.NodeDigest(h.digestMTH(leaves[:k]), h.digestMTH(leaves[k:]))
+}
+
+// digestPath returns PATH(m, D) of RFC 6962 over the given leaf digests
+func (h Hasher) digestPath(m uint64, leaves [][]byte) [][]byte {
+	n := uint64(len(leaves))
+	if n <= 1 {
+		return make([][]byte, 0)
+	}
+	k := SplitPoint(n)
+	if m < k {
+		return append(h.digestPath(m, leaves[:k]), h.digestMTH(leaves[k:]))
+	}
+	return append(h.digestPath(m-k, leaves[k:]), h.digestMTH(leaves[:k]))
+}
+
+// digestSubProof returns SUBPROOF(m, D, known) of RFC 6962 over the given leaf
+// digests
+func (h Hasher) digestSubProof(m uint64, leaves [][]byte, known bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if known {
+			return make([][]byte, 0)
+		}
+		return [][]byte{h.digestMTH(leaves)}
+	}
+	k := SplitPoint(n)
+	if m <= k {
+		return append(h.digestSubProof(m, leaves[:k], known), h.digestMTH(leaves[k:]))
+	}
+	return append(h.digestSubProof(m-k, leaves[k:], false), h.digestMTH(leaves[:k]))
+}
+
+// RootFromMultihash returns the root encoded in mh, as returned by RootMultihash
+// of a tree, failing with ErrHashMismatch when it is the root of a tree over
+// another hash function
+func (h Hasher) RootFromMultihash(mh []byte) ([sha256.Size]byte, error) {
+	if h.new == nil {
+		h = SHA256Hasher
+	}
+	code, digest, err := decodeMultihash(mh)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	if code != h.code {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: code 0x%x, %s is 0x%x", ErrHashMismatch, code, h.name, h.code)
+	}
+	if len(digest) != sha256.Size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: digest of %d bytes", ErrHashMismatch, len(digest))
+	}
+	var root [sha256.Size]byte
+	copy(root[:], digest)
+	return root, nil
+}
+
+// nodeHash returns the hash of the node with the given children, with the hasher
+// of the tree
+func (m *MerkleHashTree) nodeHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	if m.hasher == nil {
+		return nodeHash(append(left[:], right[:]...))
+	}
+	return m.hasher.NodeHash(left, right)
+}
+
+// algorithm returns the tag of the proofs of the tree, empty for SHA-256
+func (m *MerkleHashTree) algorithm() string {
+	if m.hasher == nil {
+		return ""
+	}
+	return m.hasher.algorithm()
+}
+
+// emptyRoot returns the root of the empty tree, with the hasher of the tree
+func (m *MerkleHashTree) emptyRoot() [sha256.Size]byte {
+	if m.hasher == nil {
+		return sha256.Sum256(nil)
+	}
+	return m.hasher.EmptyRoot()
+}
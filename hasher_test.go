@@ -0,0 +1,201 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testHashCode identifies the SHA-512/256 hasher of the tests in multihashes
+const testHashCode = 0x1015
+
+func newSHA512_256Hasher(t *testing.T) Hasher {
+	h, err := NewHasher("sha2-512-256", testHashCode, sha512.New512_256)
+	assert.NoError(t, err)
+	return h
+}
+
+// referenceMTH is MTH of RFC 6962 over the leaf and node hashes of h
+func referenceMTH(h Hasher, D [][]byte) [sha256.Size]byte {
+	switch len(D) {
+	case 0:
+		return sha512.Sum512_256(nil)
+	case 1:
+		return h.LeafHash(D[0])
+	}
+	k := SplitPoint(uint64(len(D)))
+	return h.NodeHash(referenceMTH(h, D[:k]), referenceMTH(h, D[k:]))
+}
+
+func TestHasher(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	assert.Equal(t, "sha2-512-256", h.Name())
+	assert.Equal(t, sha512.Sum512_256(append([]byte{LeafPrefix}, "d0"...)), h.LeafHash([]byte("d0")))
+
+	D := makeEntries(20)
+	tree := New(nil, WithHasher(h))
+	assert.Equal(t, sha512.Sum512_256(nil), tree.MerkleRoot())
+	for n := 1; n <= len(D); n++ {
+		tree.Append(D[n-1])
+		root := referenceMTH(h, D[:n])
+		assert.Equal(t, root, tree.MerkleRoot(), "size %d", n)
+		assert.Equal(t, root, New(D[:n], WithHasher(h)).MerkleRoot(), "size %d", n)
+		assert.Equal(t, root, h.MTH(D[:n]), "size %d", n)
+		assert.NotEqual(t, MTH(D[:n]), root)
+	}
+	assert.Equal(t, h.Name(), tree.Hasher().Name())
+
+	root := tree.MerkleRoot()
+	for i := range D {
+		p, err := tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, h.Path(uint64(i), D), p.Hashes)
+		assert.Equal(t, p.Hashes, tree.InclusionProof(D[i]))
+		assert.NoError(t, h.VerifyInclusion(h.LeafHash(D[i]), root, p))
+		assert.Equal(t, "sha2-512-256", p.Algorithm)
+		assert.ErrorIs(t, VerifyInclusion(leafHash(D[i]), root, p), ErrHashMismatch)
+		assert.ErrorIs(t, SHA256Hasher.VerifyInclusion(h.LeafHash(D[i]), root, p), ErrHashMismatch)
+	}
+	for n := 1; n <= len(D); n++ {
+		for m := 1; m <= n; m++ {
+			p, err := tree.ConsistencyProof(uint64(m), uint64(n))
+			assert.NoError(t, err)
+			assert.Equal(t, h.Proof(uint64(m), D[:n]), p.Hashes, "sizes %d to %d", m, n)
+			assert.NoError(t, h.VerifyConsistency(referenceMTH(h, D[:m]), referenceMTH(h, D[:n]), p), "sizes %d to %d", m, n)
+			assert.ErrorIs(t, SHA256Hasher.VerifyConsistency(referenceMTH(h, D[:m]), referenceMTH(h, D[:n]), p), ErrHashMismatch)
+			if m < n {
+				assert.ErrorIs(t, VerifyConsistency(referenceMTH(h, D[:m]), referenceMTH(h, D[:n]), p), ErrHashMismatch, "sizes %d to %d", m, n)
+			}
+		}
+	}
+}
+
+func TestDefaultHasher(t *testing.T) {
+	D := makeEntries(9)
+	for _, h := range []Hasher{{}, SHA256Hasher} {
+		assert.Equal(t, "sha2-256", h.Name())
+		assert.Equal(t, MTH(D), h.MTH(D))
+		assert.Equal(t, MTH(D), New(D, WithHasher(h)).MerkleRoot())
+		assert.Equal(t, Path(4, D), h.Path(4, D))
+		assert.Equal(t, Proof(3, D), h.Proof(3, D))
+	}
+	assert.Equal(t, SHA256Hasher.Name(), New(D).Hasher().Name())
+}
+
+func TestHasherMismatch(t *testing.T) {
+	sha512Hasher, err := NewHasher("sha2-512", 0x13, sha512.New)
+	assert.NoError(t, err)
+	assert.Equal(t, sha512.Size, sha512Hasher.Size())
+	_, err = TryNew(makeEntries(3), WithHasher(sha512Hasher))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	assert.ErrorIs(t, NewTreeBuilder(WithBuilderHasher(sha512Hasher)).Add([]byte("d0")), ErrUnsupportedHash)
+	assert.Panics(t, func() { sha512Hasher.LeafHash([]byte("d0")) })
+
+	h := newSHA512_256Hasher(t)
+	D := makeEntries(5)
+	tree := New(D, WithHasher(h))
+	mh := tree.RootMultihash()
+	root, err := h.RootFromMultihash(mh)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.MerkleRoot(), root)
+
+	// Roots over different hash functions are told apart by their multihash
+	_, err = SHA256Hasher.RootFromMultihash(mh)
+	assert.ErrorIs(t, err, ErrHashMismatch)
+	_, err = MultihashToRoot(mh)
+	assert.ErrorIs(t, err, ErrInvalidMultihash)
+	_, err = h.RootFromMultihash(New(D).RootMultihash())
+	assert.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestHasherUnsupportedFeatures(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	tree := New(makeEntries(5), WithHasher(h))
+	_, err := tree.Frontier(3)
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	_, err = tree.ExtractBundle([]uint64{1})
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	_, err = NewBundledLog(4, WithHasher(h))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+
+	// The SHA-256 hasher is the default one
+	sha := New(makeEntries(5), WithHasher(SHA256Hasher))
+	_, err = sha.Frontier(3)
+	assert.NoError(t, err)
+	_, err = sha.ExtractBundle([]uint64{1})
+	assert.NoError(t, err)
+	_, err = NewBundledLog(4, WithHasher(SHA256Hasher))
+	assert.NoError(t, err)
+}
+
+// referenceDigestMTH is MTH of RFC 6962 over the leaf and node digests of h
+func referenceDigestMTH(h Hasher, D [][]byte) []byte {
+	switch len(D) {
+	case 0:
+		return h.digest()
+	case 1:
+		return h.LeafDigest(D[0])
+	}
+	k := SplitPoint(uint64(len(D)))
+	return h.NodeDigest(referenceDigestMTH(h, D[:k]), referenceDigestMTH(h, D[k:]))
+}
+
+func TestDigestHasher(t *testing.T) {
+	h, err := NewHasher("sha2-512", 0x13, sha512.New)
+	assert.NoError(t, err)
+	leaf := sha512.Sum512(append([]byte{LeafPrefix}, "d0"...))
+	assert.Equal(t, leaf[:], h.LeafDigest([]byte("d0")))
+
+	D := makeEntries(13)
+	empty := sha512.Sum512(nil)
+	assert.Equal(t, empty[:], h.DigestMTH(nil))
+	for n := 1; n <= len(D); n++ {
+		root := h.DigestMTH(D[:n])
+		assert.Len(t, root, sha512.Size)
+		assert.Equal(t, referenceDigestMTH(h, D[:n]), root, "size %d", n)
+
+		for i := 0; i < n; i++ {
+			p, err := h.DigestPath(uint64(i), D[:n])
+			assert.NoError(t, err)
+			assert.Equal(t, "sha2-512", p.Algorithm)
+			assert.NoError(t, h.VerifyDigestInclusion(h.LeafDigest(D[i]), root, p), "leaf %d of %d", i, n)
+			if n > 1 {
+				assert.ErrorIs(t, h.VerifyDigestInclusion(h.LeafDigest(D[(i+1)%n]), root, p), ErrRootMismatch)
+			}
+		}
+		for m := 0; m <= n; m++ {
+			p, err := h.DigestProof(uint64(m), D[:n])
+			assert.NoError(t, err)
+			assert.NoError(t, h.VerifyDigestConsistency(h.DigestMTH(D[:m]), root, p), "sizes %d to %d", m, n)
+		}
+	}
+
+	root := h.DigestMTH(D)
+	p, err := h.DigestPath(4, D)
+	assert.NoError(t, err)
+	_, err = h.DigestPath(13, D)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = h.DigestProof(14, D)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	// Proofs of another algorithm, or with digests of another size, are rejected
+	other := p
+	other.Algorithm = "blake2b-512"
+	assert.ErrorIs(t, h.VerifyDigestInclusion(h.LeafDigest(D[4]), root, other), ErrHashMismatch)
+	short := p
+	short.Hashes = append([][]byte{p.Hashes[0][:32]}, p.Hashes[1:]...)
+	assert.ErrorIs(t, h.VerifyDigestInclusion(h.LeafDigest(D[4]), root, short), ErrHashMismatch)
+	assert.ErrorIs(t, h.VerifyDigestInclusion(h.LeafDigest(D[4]), root[:32], p), ErrHashMismatch)
+	c, err := h.DigestProof(5, D)
+	assert.NoError(t, err)
+	c.Algorithm = ""
+	assert.ErrorIs(t, h.VerifyDigestConsistency(h.DigestMTH(D[:5]), root, c), ErrHashMismatch)
+
+	// The digest methods of a hasher of 32-byte digests match the tree
+	h256 := newSHA512_256Hasher(t)
+	tree := New(D, WithHasher(h256))
+	treeRoot := tree.MerkleRoot()
+	assert.Equal(t, treeRoot[:], h256.DigestMTH(D))
+}
@@ -0,0 +1,165 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// ErrRootUnavailable is returned by RootAt for sizes whose root the tree never had
+// in its current history, e.g. sizes skipped over by SetLeaf
+var ErrRootUnavailable = errors.New("merkletree: root is not available")
+
+// RootRecord is a root recorded by WithRootHistory, along with the time it was recorded
+type RootRecord struct {
+	TreeHead
+	Time time.Time
+}
+
+// RetentionPolicy returns the records of a root history to keep, given the records
+// in ascending order of tree size and the current time. The last record is the
+// current root of the tree.
+type RetentionPolicy func(records []RootRecord, now time.Time) []RootRecord
+
+// KeepLastRoots keeps the n most recent roots
+func KeepLastRoots(n int) RetentionPolicy {
+	return func(records []RootRecord, _ time.Time) []RootRecord {
+		if len(records) <= n {
+			return records
+		}
+		return records[len(records)-n:]
+	}
+}
+
+// KeepPowerOfTwoRoots keeps the first recorded root of every range of sizes
+// [2^k, 2^(k+1)), i.e. the root at each power of two size when leaves are appended
+// one at a time, and the current root.
+func KeepPowerOfTwoRoots() RetentionPolicy {
+	return func(records []RootRecord, _ time.Time) []RootRecord {
+		kept := records[:0:0]
+		for i, r := range records {
+			if i == 0 || i == len(records)-1 || bits.Len64(r.TreeSize) != bits.Len64(records[i-1].TreeSize) {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	}
+}
+
+// KeepRootsNewerThan keeps the roots recorded within d of the current time, and the
+// current root.
+func KeepRootsNewerThan(d time.Duration) RetentionPolicy {
+	return func(records []RootRecord, now time.Time) []RootRecord {
+		i := sort.Search(len(records), func(i int) bool {
+			return now.Sub(records[i].Time) < d
+		})
+		if i == len(records) && i > 0 {
+			i--
+		}
+		return records[i:]
+	}
+}
+
+// WithRootHistory records the root of the tree after every mutation, which RootAt
+// then returns without recomputing it.
+func WithRootHistory() Option {
+	return func(m *MerkleHashTree) {
+		m.history = true
+	}
+}
+
+// WithRootRetention records the root history like WithRootHistory, pruning it with
+// policy after every mutation.
+func WithRootRetention(policy RetentionPolicy) Option {
+	return func(m *MerkleHashTree) {
+		m.history = true
+		m.retention = policy
+	}
+}
+
+// recordRoot adds the current root to the root history and prunes it
+func (m *MerkleHashTree) recordRoot() {
+	if !m.history {
+		return
+	}
+	r := RootRecord{TreeHead: TreeHead{TreeSize: uint64(len(m.tree[0])), RootHash: m.root()}, Time: m.now()}
+	if n := len(m.roots); n > 0 && m.roots[n-1].TreeSize == r.TreeSize {
+		m.roots = m.roots[:n-1]
+	}
+	m.roots = append(m.roots, r)
+	m.pruneHistory()
+}
+
+func (m *MerkleHashTree) pruneHistory() int {
+	if m.retention == nil {
+		return 0
+	}
+	n := len(m.roots)
+	m.roots = append(m.roots[:0:0], m.retention(m.roots, m.now())...)
+	return n - len(m.roots)
+}
+
+func (m *MerkleHashTree) now() time.Time {
+	if m.clock != nil {
+		return m.clock()
+	}
+	return time.Now()
+}
+
+// rewriteHistory forgets the roots of the sizes from on, whose leaves were rewritten,
+// before the tree is rebuilt. Sizes from on up to the current size become unavailable.
+func (m *MerkleHashTree) rewriteHistory(from uint64) {
+	size := uint64(len(m.tree[0]))
+	i := sort.Search(len(m.roots), func(i int) bool { return m.roots[i].TreeSize >= from })
+	m.roots = m.roots[:i]
+
+	unpublished := m.unpublished[:0]
+	for _, r := range append(m.unpublished, [2]uint64{from, size}) {
+		if r[1] > size {
+			r[1] = size
+		}
+		if r[0] < r[1] {
+			unpublished = append(unpublished, r)
+		}
+	}
+	m.unpublished = unpublished
+}
+
+// PruneHistory applies the retention policy of the tree to its root history and
+// returns the number of roots pruned. This is only needed for policies depending
+// on time, as the history is also pruned after every mutation.
+func (m *MerkleHashTree) PruneHistory() int {
+	defer m.writeLock()()
+	return m.pruneHistory()
+}
+
+// RootHistory returns the recorded roots in ascending order of tree size
+func (m *MerkleHashTree) RootHistory() []RootRecord {
+	defer m.readLock()()
+	return append([]RootRecord(nil), m.roots...)
+}
+
+// RootAt returns the root the tree had at the given size. Roots that are not in the
+// root history are recomputed from the leaf hashes, except for sizes the tree never
+// had since its leaves were rewritten by SetLeaf, for which ErrRootUnavailable is
+// returned.
+func (m *MerkleHashTree) RootAt(size uint64) ([sha256.Size]byte, error) {
+	defer m.readLock()()
+
+	if size > uint64(len(m.tree[0])) {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, size, len(m.tree[0]))
+	}
+	i := sort.Search(len(m.roots), func(i int) bool { return m.roots[i].TreeSize >= size })
+	if i < len(m.roots) && m.roots[i].TreeSize == size {
+		return m.roots[i].RootHash, nil
+	}
+	for _, r := range m.unpublished {
+		if r[0] <= size && size < r[1] {
+			return [sha256.Size]byte{}, fmt.Errorf("%w: size %d was rewritten", ErrRootUnavailable, size)
+		}
+	}
+	return m.rootAtSize(size), nil
+}
@@ -0,0 +1,109 @@
+package merkletree
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func historySizes(tree *MerkleHashTree) []uint64 {
+	var sizes []uint64
+	for _, r := range tree.RootHistory() {
+		sizes = append(sizes, r.TreeSize)
+	}
+	return sizes
+}
+
+func TestRootHistoryRetention(t *testing.T) {
+	D := makeEntries(20)
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		sizes  []uint64
+	}{
+		{"last", KeepLastRoots(3), []uint64{18, 19, 20}},
+		{"power of two", KeepPowerOfTwoRoots(), []uint64{0, 1, 2, 4, 8, 16, 20}},
+	}
+	for _, tt := range tests {
+		tree := New(nil, WithRootRetention(tt.policy))
+		for _, d := range D {
+			tree.Append(d)
+		}
+		assert.Equal(t, tt.sizes, historySizes(tree), tt.name)
+
+		for n := uint64(0); n <= uint64(len(D)); n++ {
+			root, err := tree.RootAt(n)
+			assert.NoError(t, err)
+			assert.Equal(t, MTH(D[:n]), root, "%s: size %d", tt.name, n)
+		}
+	}
+
+	unbounded := New(nil, WithRootHistory())
+	for _, d := range D {
+		unbounded.Append(d)
+	}
+	assert.Len(t, unbounded.RootHistory(), len(D)+1)
+	assert.Equal(t, 0, unbounded.PruneHistory())
+}
+
+func TestRootHistoryNewerThan(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tree := New(nil, WithRootRetention(KeepRootsNewerThan(time.Minute)))
+	tree.clock = func() time.Time { return now }
+
+	D := makeEntries(10)
+	for _, d := range D {
+		tree.Append(d)
+		now = now.Add(15 * time.Second)
+	}
+	// Roots are recorded 15s apart; the clock has moved on after the last one
+	assert.Equal(t, []uint64{7, 8, 9, 10}, historySizes(tree))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, 3, tree.PruneHistory())
+	assert.Equal(t, []uint64{10}, historySizes(tree))
+
+	root, err := tree.RootAt(3)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:3]), root)
+}
+
+func TestRootAtAfterRewrite(t *testing.T) {
+	D := makeEntries(12)
+	for _, opts := range [][]Option{nil, {WithRootHistory()}, {WithRootRetention(KeepLastRoots(2))}} {
+		tree := New(nil, opts...)
+		for _, d := range D[:10] {
+			tree.Append(d)
+		}
+
+		_, err := tree.SetLeaf(4, []byte("rewritten"))
+		assert.NoError(t, err)
+		for n := uint64(0); n <= 4; n++ {
+			root, err := tree.RootAt(n)
+			assert.NoError(t, err)
+			assert.Equal(t, MTH(D[:n]), root)
+		}
+		for n := uint64(5); n < 10; n++ {
+			_, err := tree.RootAt(n)
+			assert.ErrorIs(t, err, ErrRootUnavailable, "size %d", n)
+		}
+		root, err := tree.RootAt(10)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.MerkleRoot(), root)
+
+		// Sizes above the truncated size are gone, the ones appended later are new
+		_, err = tree.Truncate(7)
+		assert.NoError(t, err)
+		tree.Append(D[10:]...)
+		_, err = tree.RootAt(6)
+		assert.ErrorIs(t, err, ErrRootUnavailable)
+		for _, n := range []uint64{7, 9} {
+			root, err := tree.RootAt(n)
+			assert.NoError(t, err)
+			assert.Equal(t, tree.rootAtSize(n), root)
+		}
+		_, err = tree.RootAt(10)
+		assert.ErrorIs(t, err, ErrInvalidRange)
+	}
+}
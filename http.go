@@ -0,0 +1,251 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Paths served by NewHandler
+const (
+	RootPath               = "/root"
+	InclusionProofPath     = "/proof/inclusion"
+	ConsistencyProofPath   = "/proof/consistency"
+	EntriesPath            = "/entries"
+	maxHTTPErrorBodyLength = 512
+	// maxEntriesPerResponse caps the number of entries served by a single request
+	maxEntriesPerResponse = 256
+)
+
+type rootResponse struct {
+	TreeSize uint64 `json:"tree_size"`
+	RootHash string `json:"root_hash"`
+}
+
+type inclusionProofResponse struct {
+	Algorithm string   `json:"algorithm,omitempty"`
+	LeafIndex uint64   `json:"leaf_index"`
+	TreeSize  uint64   `json:"tree_size"`
+	AuditPath []string `json:"audit_path"`
+}
+
+type consistencyProofResponse struct {
+	Algorithm   string   `json:"algorithm,omitempty"`
+	First       uint64   `json:"first"`
+	Second      uint64   `json:"second"`
+	Consistency []string `json:"consistency"`
+}
+
+type entriesResponse struct {
+	Entries []entryResponse `json:"entries"`
+}
+
+type entryResponse struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// NewHandler returns an http.Handler serving the root and proofs of tree:
+//
+//	GET /root                                          {"tree_size", "root_hash"}
+//	GET /proof/inclusion?index=i&tree_size=n           {"leaf_index", "tree_size", "audit_path"}
+//	GET /proof/inclusion?leaf_hash=hex&tree_size=n     {"leaf_index", "tree_size", "audit_path"}
+//	GET /proof/consistency?first=m&second=n            {"first", "second", "consistency"}
+//	GET /entries?start=i&end=j                         {"entries": [{"leaf_input", "extra_data"}]}
+//
+// Hashes are lowercase hex. tree_size defaults to the current size of the tree.
+// Entries are only served by trees implementing EntrySource. Like the get-entries
+// method of Certificate Transparency, end is inclusive, leaf_input and extra_data
+// are base64 and fewer entries than requested may be returned. A range holding a
+// redacted entry fails with 410 Gone.
+// A *MerkleHashTree must be created WithLocking if it is appended to while the
+// handler is serving requests.
+func NewHandler(tree Tree) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(RootPath, func(w http.ResponseWriter, r *http.Request) {
+		head := tree.TreeHead()
+		writeJSON(w, rootResponse{TreeSize: head.TreeSize, RootHash: hex.EncodeToString(head.RootHash[:])})
+	})
+	mux.HandleFunc(InclusionProofPath, func(w http.ResponseWriter, r *http.Request) {
+		serveInclusionProof(tree, w, r)
+	})
+	mux.HandleFunc(ConsistencyProofPath, func(w http.ResponseWriter, r *http.Request) {
+		serveConsistencyProof(tree, w, r)
+	})
+	if source, ok := tree.(EntrySource); ok {
+		mux.HandleFunc(EntriesPath, func(w http.ResponseWriter, r *http.Request) {
+			serveEntries(tree, source, w, r)
+		})
+	}
+	return mux
+}
+
+func serveInclusionProof(tree Tree, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	size := tree.Size()
+	if q.Has("tree_size") {
+		var err error
+		if size, err = strconv.ParseUint(q.Get("tree_size"), 10, 64); err != nil {
+			http.Error(w, "invalid tree_size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var index uint64
+	switch {
+	case q.Has("index"):
+		var err error
+		if index, err = strconv.ParseUint(q.Get("index"), 10, 64); err != nil {
+			http.Error(w, "invalid index", http.StatusBadRequest)
+			return
+		}
+	case q.Has("leaf_hash"):
+		var hash [sha256.Size]byte
+		if err := decodeHexHash(q.Get("leaf_hash"), &hash); err != nil {
+			http.Error(w, "invalid leaf_hash", http.StatusBadRequest)
+			return
+		}
+		i, err := tree.LeafIndex(hash)
+		if err != nil || i >= size {
+			http.Error(w, ErrLeafNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		index = i
+	default:
+		http.Error(w, "index or leaf_hash is required", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := tree.InclusionProofAtSize(index, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, inclusionProofResponse{
+		Algorithm: proof.Algorithm,
+		LeafIndex: proof.LeafIndex,
+		TreeSize:  proof.TreeSize,
+		AuditPath: encodeHexHashes(proof.Hashes),
+	})
+}
+
+func serveConsistencyProof(tree Tree, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	first, err := strconv.ParseUint(q.Get("first"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid first", http.StatusBadRequest)
+		return
+	}
+	second, err := strconv.ParseUint(q.Get("second"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid second", http.StatusBadRequest)
+		return
+	}
+	proof, err := tree.ConsistencyProof(first, second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, consistencyProofResponse{Algorithm: proof.Algorithm, First: first, Second: second, Consistency: encodeHexHashes(proof.Hashes)})
+}
+
+func serveEntries(tree Tree, source EntrySource, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, err := strconv.ParseUint(q.Get("start"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid start", http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.ParseUint(q.Get("end"), 10, 64)
+	if err != nil || end < start {
+		http.Error(w, "invalid end", http.StatusBadRequest)
+		return
+	}
+	if size := tree.Size(); start >= size {
+		http.Error(w, fmt.Sprintf("start %d is beyond the tree size %d", start, size), http.StatusBadRequest)
+		return
+	} else if end >= size {
+		end = size - 1
+	}
+	if end-start >= maxEntriesPerResponse {
+		end = start + maxEntriesPerResponse - 1
+	}
+
+	resp := entriesResponse{Entries: make([]entryResponse, 0, end-start+1)}
+	for i := start; i <= end; i++ {
+		leaf, extra, err := source.GetEntry(i)
+		if errors.Is(err, ErrEntryNotStored) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrRedacted) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		var sourceErr *LeafSourceError
+		if errors.As(err, &sourceErr) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Entries = append(resp.Entries, entryResponse{LeafInput: leaf, ExtraData: extra})
+	}
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func encodeHexHashes(hashes [][sha256.Size]byte) []string {
+	encoded := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		encoded = append(encoded, hex.EncodeToString(h[:]))
+	}
+	return encoded
+}
+
+func decodeHexHashes(encoded []string) ([][sha256.Size]byte, error) {
+	hashes := make([][sha256.Size]byte, len(encoded))
+	for i, s := range encoded {
+		if err := decodeHexHash(s, &hashes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// HTTPError is returned by LogClient when the server responds with a non-200 status
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("merkletree: unexpected HTTP status %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable reports whether a request failing with err may succeed when retried
+func retryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// transport failures are reported as *url.Error, anything else is a bad response
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,99 @@
+// Package ics23 renders inclusion proofs of RFC 6962 merkle hash trees as ICS23
+// existence proofs, the proof format of IBC and the Cosmos ecosystem.
+//
+// ICS23 leaves are key-value pairs hashed as SHA-256(0x00 || key || value), so the
+// entry of a tree leaf must be the concatenation of a non empty key and value.
+package ics23
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	ics "github.com/cosmos/ics23/go"
+	"github.com/viveksyngh/merkletree"
+)
+
+// ErrEmptyKeyValue is returned when converting a proof for an empty key or value,
+// which ICS23 verifiers reject.
+var ErrEmptyKeyValue = errors.New("ics23: key and value must not be empty")
+
+// ProofSpec is the ICS23 proof spec of RFC 6962 merkle hash trees. Inner nodes
+// are SHA-256(0x01 || left || right), so the prefix of an inner op is 0x01
+// followed by the left sibling when the child is on the right.
+var ProofSpec = &ics.ProofSpec{
+	LeafSpec: &ics.LeafOp{
+		Hash:         ics.HashOp_SHA256,
+		PrehashKey:   ics.HashOp_NO_HASH,
+		PrehashValue: ics.HashOp_NO_HASH,
+		Length:       ics.LengthOp_NO_PREFIX,
+		Prefix:       []byte{merkletree.LeafPrefix},
+	},
+	InnerSpec: &ics.InnerSpec{
+		ChildOrder:      []int32{0, 1},
+		ChildSize:       sha256.Size,
+		MinPrefixLength: 1,
+		MaxPrefixLength: 1,
+		Hash:            ics.HashOp_SHA256,
+	},
+}
+
+// ExistenceProof returns the ICS23 existence proof of the leaf key || value from
+// its inclusion proof p.
+func ExistenceProof(key, value []byte, p merkletree.InclusionProof) (*ics.ExistenceProof, error) {
+	if len(key) == 0 || len(value) == 0 {
+		return nil, ErrEmptyKeyValue
+	}
+
+	lefts, err := siblingSides(p)
+	if err != nil {
+		return nil, err
+	}
+
+	path := make([]*ics.InnerOp, 0, len(p.Hashes))
+	for i, h := range p.Hashes {
+		op := &ics.InnerOp{Hash: ics.HashOp_SHA256, Prefix: []byte{merkletree.NodePrefix}}
+		if lefts[i] {
+			op.Prefix = append(op.Prefix, h[:]...)
+		} else {
+			op.Suffix = append([]byte(nil), h[:]...)
+		}
+		path = append(path, op)
+	}
+
+	return &ics.ExistenceProof{
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte(nil), value...),
+		Leaf:  ProofSpec.LeafSpec,
+		Path:  path,
+	}, nil
+}
+
+// CommitmentProof wraps the existence proof of key || value in a CommitmentProof
+func CommitmentProof(key, value []byte, p merkletree.InclusionProof) (*ics.CommitmentProof, error) {
+	exist, err := ExistenceProof(key, value, p)
+	if err != nil {
+		return nil, err
+	}
+	return &ics.CommitmentProof{Proof: &ics.CommitmentProof_Exist{Exist: exist}}, nil
+}
+
+// siblingSides reports for every hash of the audit path of p whether it is the left
+// sibling. Siblings of the inner part of the path are on the left where the index has
+// a set bit, and siblings of the border are always on the left.
+func siblingSides(p merkletree.InclusionProof) ([]bool, error) {
+	if p.LeafIndex >= p.TreeSize {
+		return nil, fmt.Errorf("%w: index %d, size %d", merkletree.ErrIndexOutOfRange, p.LeafIndex, p.TreeSize)
+	}
+
+	inner := merkletree.InnerProofSize(p.LeafIndex, p.TreeSize)
+	if len(p.Hashes) != inner+merkletree.BorderSize(p.LeafIndex, p.TreeSize) {
+		return nil, merkletree.ErrInvalidProofSize
+	}
+
+	lefts := make([]bool, len(p.Hashes))
+	for i := range lefts {
+		lefts[i] = i >= inner || (p.LeafIndex>>uint(i))&1 == 1
+	}
+	return lefts, nil
+}
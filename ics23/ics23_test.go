@@ -0,0 +1,48 @@
+package ics23
+
+import (
+	"fmt"
+	"testing"
+
+	ics "github.com/cosmos/ics23/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+)
+
+func TestExistenceProofVerifiesWithICS23(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 7, 8, 13, 32} {
+		keys := make([][]byte, size)
+		values := make([][]byte, size)
+		entries := make([][]byte, size)
+		for i := range entries {
+			keys[i] = []byte(fmt.Sprintf("key%d", i))
+			values[i] = []byte(fmt.Sprintf("value%d", i))
+			entries[i] = append(append([]byte(nil), keys[i]...), values[i]...)
+		}
+		tree := merkletree.New(entries)
+		root := tree.MerkleRoot()
+
+		for i := range entries {
+			p, err := tree.InclusionProofByIndex(uint64(i))
+			assert.NoError(t, err)
+
+			proof, err := CommitmentProof(keys[i], values[i], p)
+			assert.NoError(t, err)
+			assert.True(t, ics.VerifyMembership(ProofSpec, root[:], proof, keys[i], values[i]), "index %d, size %d", i, size)
+			assert.False(t, ics.VerifyMembership(ProofSpec, root[:], proof, keys[i], []byte("other")), "index %d, size %d", i, size)
+		}
+	}
+}
+
+func TestExistenceProofRejectsInvalid(t *testing.T) {
+	tree := merkletree.New([][]byte{[]byte("ab"), []byte("cd"), []byte("ef")})
+	p, err := tree.InclusionProofByIndex(1)
+	assert.NoError(t, err)
+
+	_, err = ExistenceProof(nil, []byte("cd"), p)
+	assert.ErrorIs(t, err, ErrEmptyKeyValue)
+
+	p.TreeSize = 5
+	_, err = ExistenceProof([]byte("c"), []byte("d"), p)
+	assert.ErrorIs(t, err, merkletree.ErrInvalidProofSize)
+}
@@ -0,0 +1,187 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidDepth is returned when creating an IncrementalTree of an unsupported depth
+var ErrInvalidDepth = errors.New("merkletree: invalid incremental tree depth")
+
+// PairHash hashes two sibling nodes into their parent
+type PairHash func(left, right [32]byte) [32]byte
+
+// SHA256Pair returns SHA-256(left || right), the node hash of the Ethereum deposit contract
+func SHA256Pair(left, right [32]byte) [32]byte {
+	return sha256.Sum256(append(left[:], right[:]...))
+}
+
+// Keccak256Pair returns Keccak-256(left || right), the node hash of keccak based incremental trees
+func Keccak256Pair(left, right [32]byte) [32]byte {
+	return keccak256(append(left[:], right[:]...))
+}
+
+// IncrementalOption configures an IncrementalTree
+type IncrementalOption func(*IncrementalTree)
+
+// WithPairHash hashes the nodes of an IncrementalTree with hash instead of SHA256Pair
+func WithPairHash(hash PairHash) IncrementalOption {
+	return func(t *IncrementalTree) {
+		t.hash = hash
+	}
+}
+
+// IncrementalTree is a fixed depth merkle tree in the style of the Ethereum deposit
+// contract. Leaves fill the tree from the left, and empty positions hold zero hashes:
+// zero[0] is 32 zero bytes and zero[h+1] is the hash of two zero[h]. Unlike the RFC
+// 6962 trees of this package, leaves are not prefixed, and appends only update the
+// branch of left siblings along the path of the next leaf. Leaf hashes are kept to
+// serve proofs.
+type IncrementalTree struct {
+	depth  int
+	hash   PairHash
+	zero   [][32]byte
+	branch [][32]byte
+	leaves [][32]byte
+}
+
+// NewIncrementalTree returns an empty incremental tree of the given depth, which
+// must be between 1 and 63. The deposit contract uses a depth of 32.
+func NewIncrementalTree(depth int, opts ...IncrementalOption) (*IncrementalTree, error) {
+	if depth < 1 || depth > 63 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDepth, depth)
+	}
+
+	t := &IncrementalTree{depth: depth, hash: SHA256Pair, branch: make([][32]byte, depth)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.zero = make([][32]byte, depth+1)
+	for h := 0; h < depth; h++ {
+		t.zero[h+1] = t.hash(t.zero[h], t.zero[h])
+	}
+	return t, nil
+}
+
+// Size returns the number of leaves appended to the tree
+func (t *IncrementalTree) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// ZeroHash returns the root of an empty subtree of the given height
+func (t *IncrementalTree) ZeroHash(height int) [32]byte {
+	return t.zero[height]
+}
+
+// Append adds a leaf to the tree. As in the deposit contract, a tree of depth d
+// holds at most 2^d - 1 leaves, after which ErrLogFull is returned.
+func (t *IncrementalTree) Append(leaf [32]byte) error {
+	if t.Size() >= 1<<t.depth-1 {
+		return ErrLogFull
+	}
+
+	t.leaves = append(t.leaves, leaf)
+	node := leaf
+	size := t.Size()
+	for h := 0; h < t.depth; h++ {
+		if size&1 == 1 {
+			t.branch[h] = node
+			return nil
+		}
+		node = t.hash(t.branch[h], node)
+		size >>= 1
+	}
+	return nil
+}
+
+// Root returns the root of the tree
+func (t *IncrementalTree) Root() [32]byte {
+	var node [32]byte
+	size := t.Size()
+	for h := 0; h < t.depth; h++ {
+		if size&1 == 1 {
+			node = t.hash(t.branch[h], node)
+		} else {
+			node = t.hash(node, t.zero[h])
+		}
+		size >>= 1
+	}
+	return node
+}
+
+// lengthNode returns the little endian leaf count padded to 32 bytes, mixed into the deposit root
+func (t *IncrementalTree) lengthNode() [32]byte {
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:], t.Size())
+	return length
+}
+
+// DepositRoot returns the root mixed in with the number of leaves, as returned by
+// get_deposit_root of the deposit contract: hash(Root() || uint64le(size) || 24 zero bytes).
+func (t *IncrementalTree) DepositRoot() [32]byte {
+	return t.hash(t.Root(), t.lengthNode())
+}
+
+// Proof returns the sibling nodes on the path from the leaf at index to the root,
+// ordered from the leaf upwards.
+func (t *IncrementalTree) Proof(index uint64) ([][32]byte, error) {
+	if index >= t.Size() {
+		return nil, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, t.Size())
+	}
+
+	proof := make([][32]byte, 0, t.depth)
+	nodes := t.leaves
+	for h := 0; h < t.depth; h++ {
+		sibling := t.zero[h]
+		if index^1 < uint64(len(nodes)) {
+			sibling = nodes[index^1]
+		}
+		proof = append(proof, sibling)
+
+		parents := make([][32]byte, 0, (len(nodes)+1)/2)
+		for i := 0; i < len(nodes); i += 2 {
+			right := t.zero[h]
+			if i+1 < len(nodes) {
+				right = nodes[i+1]
+			}
+			parents = append(parents, t.hash(nodes[i], right))
+		}
+		nodes = parents
+		index >>= 1
+	}
+	return proof, nil
+}
+
+// DepositProof returns the proof of the leaf at index against DepositRoot: the
+// proof against Root followed by the mixed in leaf count.
+func (t *IncrementalTree) DepositProof(index uint64) ([][32]byte, error) {
+	proof, err := t.Proof(index)
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, t.lengthNode()), nil
+}
+
+// VerifyIncrementalProof checks that leaf is at index of the incremental tree with
+// the given root, hashing nodes with hash. The depth of the tree is the length of proof.
+func VerifyIncrementalProof(hash PairHash, leaf [32]byte, index uint64, proof [][32]byte, root [32]byte) error {
+	if len(proof) < 64 && index>>len(proof) != 0 {
+		return fmt.Errorf("%w: index %d, depth %d", ErrIndexOutOfRange, index, len(proof))
+	}
+
+	node := leaf
+	for _, sibling := range proof {
+		if index&1 == 1 {
+			node = hash(sibling, node)
+		} else {
+			node = hash(node, sibling)
+		}
+		index >>= 1
+	}
+	if node != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
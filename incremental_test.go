@@ -0,0 +1,160 @@
+package merkletree
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHash(t *testing.T, s string) [32]byte {
+	var h [32]byte
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+	copy(h[:], b)
+	return h
+}
+
+// naiveIncrementalRoot computes the root of a tree of the given depth holding
+// leaves, padded with zero hashes.
+func naiveIncrementalRoot(hash PairHash, depth int, leaves [][32]byte) [32]byte {
+	nodes := make([][32]byte, 1<<depth)
+	copy(nodes, leaves)
+	for len(nodes) > 1 {
+		parents := make([][32]byte, len(nodes)/2)
+		for i := range parents {
+			parents[i] = hash(nodes[2*i], nodes[2*i+1])
+		}
+		nodes = parents
+	}
+	return nodes[0]
+}
+
+func TestIncrementalTreeZeroHashes(t *testing.T) {
+	tree, err := NewIncrementalTree(32)
+	assert.NoError(t, err)
+	// Zero hashes of the deposit contract
+	assert.Equal(t, decodeHash(t, "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"), tree.ZeroHash(1))
+	assert.Equal(t, decodeHash(t, "db56114e00fdd4c1f85c892bf35ac9a89289aaecb1ebd0a96cde606a748b5d71"), tree.ZeroHash(2))
+	assert.Equal(t, decodeHash(t, "c78009fdf07fc56a11f122370658a353aaa542ed63e44c4bc15ff4cd105ab33c"), tree.ZeroHash(3))
+	assert.Equal(t, decodeHash(t, "536d98837f2dd165a55d5eeae91485954472d56f246df256bf3cae19352a123c"), tree.ZeroHash(4))
+	// get_deposit_root of the mainnet deposit contract before the first deposit
+	assert.Equal(t, decodeHash(t, "d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e"), tree.DepositRoot())
+
+	keccak, err := NewIncrementalTree(32, WithPairHash(Keccak256Pair))
+	assert.NoError(t, err)
+	assert.Equal(t, decodeHash(t, "ad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5"), keccak.ZeroHash(1))
+	assert.Equal(t, decodeHash(t, "b4c11951957c6f8f642c4af61cd6b24640fec6dc7fc607ee8206a99e92410d30"), keccak.ZeroHash(2))
+}
+
+// depositDataRoot returns hash_tree_root of a DepositData, as the deposit contract
+// computes it
+func depositDataRoot(pubkey, withdrawalCredentials []byte, amount uint64, signature []byte) [32]byte {
+	chunk := func(b []byte) [32]byte {
+		var c [32]byte
+		copy(c[:], b)
+		return c
+	}
+	var amountChunk [32]byte
+	binary.LittleEndian.PutUint64(amountChunk[:], amount)
+	pubkeyRoot := SHA256Pair(chunk(pubkey[:32]), chunk(pubkey[32:]))
+	signatureRoot := SHA256Pair(SHA256Pair(chunk(signature[:32]), chunk(signature[32:64])), SHA256Pair(chunk(signature[64:]), [32]byte{}))
+	return SHA256Pair(SHA256Pair(pubkeyRoot, chunk(withdrawalCredentials)), SHA256Pair(amountChunk, signatureRoot))
+}
+
+func TestIncrementalTreeDepositContract(t *testing.T) {
+	f, err := os.Open("testdata/deposit/deposits.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	tree, err := NewIncrementalTree(32)
+	assert.NoError(t, err)
+	var leaves [][32]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		var index, amount uint64
+		var pubkey, credentials, signature, dataRoot, depositRoot, depositCount string
+		_, err := fmt.Sscan(line, &index, &pubkey, &credentials, &amount, &signature, &dataRoot, &depositRoot, &depositCount)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.Size(), index)
+
+		pub, err := hex.DecodeString(pubkey)
+		assert.NoError(t, err)
+		wc, err := hex.DecodeString(credentials)
+		assert.NoError(t, err)
+		sig, err := hex.DecodeString(signature)
+		assert.NoError(t, err)
+		leaf := depositDataRoot(pub, wc, amount, sig)
+		assert.Equal(t, decodeHash(t, dataRoot), leaf, "deposit %d", index)
+
+		assert.NoError(t, tree.Append(leaf))
+		leaves = append(leaves, leaf)
+		assert.Equal(t, decodeHash(t, depositRoot), tree.DepositRoot(), "deposit %d", index)
+		count := make([]byte, 8)
+		binary.LittleEndian.PutUint64(count, tree.Size())
+		assert.Equal(t, depositCount, hex.EncodeToString(count))
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, uint64(5), tree.Size())
+
+	// Every deposit is proven against the recorded root
+	for i, leaf := range leaves {
+		proof, err := tree.DepositProof(uint64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyIncrementalProof(SHA256Pair, leaf, uint64(i), proof, tree.DepositRoot()))
+	}
+}
+
+func TestIncrementalTreeMatchesFullTree(t *testing.T) {
+	for _, hash := range []PairHash{SHA256Pair, Keccak256Pair} {
+		tree, err := NewIncrementalTree(6, WithPairHash(hash))
+		assert.NoError(t, err)
+
+		leaves := make([][32]byte, 0)
+		for i := 0; i < 40; i++ {
+			assert.Equal(t, naiveIncrementalRoot(hash, 6, leaves), tree.Root(), "size %d", i)
+
+			leaf := sha256.Sum256([]byte{byte(i)})
+			assert.NoError(t, tree.Append(leaf))
+			leaves = append(leaves, leaf)
+		}
+
+		for i, leaf := range leaves {
+			proof, err := tree.Proof(uint64(i))
+			assert.NoError(t, err)
+			assert.Len(t, proof, 6)
+			assert.NoError(t, VerifyIncrementalProof(hash, leaf, uint64(i), proof, tree.Root()))
+			assert.ErrorIs(t, VerifyIncrementalProof(hash, leaf, uint64(i)^1, proof, tree.Root()), ErrRootMismatch)
+
+			proof, err = tree.DepositProof(uint64(i))
+			assert.NoError(t, err)
+			assert.NoError(t, VerifyIncrementalProof(hash, leaf, uint64(i), proof, tree.DepositRoot()))
+		}
+
+		_, err = tree.Proof(40)
+		assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	}
+}
+
+func TestIncrementalTreeCapacity(t *testing.T) {
+	_, err := NewIncrementalTree(0)
+	assert.ErrorIs(t, err, ErrInvalidDepth)
+
+	tree, err := NewIncrementalTree(2)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, tree.Append([32]byte{byte(i)}))
+	}
+	assert.ErrorIs(t, tree.Append([32]byte{3}), ErrLogFull)
+	assert.Equal(t, uint64(3), tree.Size())
+}
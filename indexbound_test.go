@@ -0,0 +1,113 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func indexBoundLeafHashes(D [][]byte) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, 0, len(D))
+	for i, d := range D {
+		hashes = append(hashes, IndexBoundLeafHash(uint64(i), d))
+	}
+	return hashes
+}
+
+func TestIndexBoundLeaves(t *testing.T) {
+	D := makeEntries(11)
+	tree := New(D[:4], WithIndexBoundLeaves())
+	tree.Append(D[4:7]...)
+	_, err := tree.TryAppend(D[7:9]...)
+	assert.NoError(t, err)
+	_, _, err = tree.AppendWithProof(D[9:]...)
+	assert.NoError(t, err)
+
+	expected, err := MTHRangeFromLeafHashes(indexBoundLeafHashes(D), 0, uint64(len(D)))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, tree.MerkleRoot())
+	assert.NotEqual(t, MTH(D), tree.MerkleRoot())
+
+	for i, d := range D {
+		proof, err := tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(IndexBoundLeafHash(uint64(i), d), expected, proof))
+		assert.Equal(t, proof.Hashes, tree.InclusionProof(d))
+	}
+
+	D[3] = []byte("x")
+	root, err := tree.SetLeaf(3, D[3])
+	assert.NoError(t, err)
+	expected, _ = MTHRangeFromLeafHashes(indexBoundLeafHashes(D), 0, uint64(len(D)))
+	assert.Equal(t, expected, root)
+}
+
+func TestIndexBoundLeavesHasher(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	D := makeEntries(7)
+	D[5] = D[2]
+	tree := New(D, WithHasher(h), WithIndexBoundLeaves())
+
+	for i, d := range D {
+		proof, err := tree.InclusionProofOfEntry(d)
+		assert.NoError(t, err)
+		if i == 5 {
+			i = 2
+		}
+		assert.Equal(t, uint64(i), proof.LeafIndex)
+		leaf := sha512.Sum512_256(append(binary.BigEndian.AppendUint64([]byte{LeafPrefix}, uint64(i)), d...))
+		assert.NoError(t, h.VerifyInclusion(leaf, tree.MerkleRoot(), proof))
+	}
+	_, err := tree.InclusionProofOfEntry([]byte("missing"))
+	assert.Error(t, err)
+}
+
+func TestIndexBoundLeavesCannotBeRelocated(t *testing.T) {
+	D := makeEntries(8)
+	for _, bound := range []bool{false, true} {
+		var opts []Option
+		if bound {
+			opts = append(opts, WithIndexBoundLeaves())
+		}
+		tree := New(D, opts...)
+		root := tree.MerkleRoot()
+		oldProof, err := tree.InclusionProofByIndex(2)
+		assert.NoError(t, err)
+
+		// Whoever controls the stored leaf hashes swaps the leaves at 2 and 3.
+		tree.tree[0][2], tree.tree[0][3] = tree.tree[0][3], tree.tree[0][2]
+		tree.buildTree(tree.tree[0])
+		assert.NotEqual(t, root, tree.MerkleRoot())
+
+		proof, err := tree.InclusionProofByIndex(3)
+		assert.NoError(t, err)
+		if bound {
+			assert.ErrorIs(t, VerifyInclusion(IndexBoundLeafHash(3, D[2]), tree.MerkleRoot(), proof), ErrRootMismatch)
+			assert.ErrorIs(t, VerifyInclusion(IndexBoundLeafHash(2, D[2]), tree.MerkleRoot(), oldProof), ErrRootMismatch)
+		} else {
+			assert.NoError(t, VerifyInclusion(leafHash(D[2]), tree.MerkleRoot(), proof))
+		}
+	}
+}
+
+func TestSequencerIndexBoundLeaves(t *testing.T) {
+	D := makeEntries(5)
+	tree := New(D[:2], WithLocking(), WithIndexBoundLeaves())
+	s := NewSequencer(tree, WithMaxBatch(3))
+
+	results := make([]<-chan Sequenced, 0)
+	for _, d := range D[2:] {
+		c, err := s.Submit(d)
+		assert.NoError(t, err)
+		results = append(results, c)
+	}
+	assert.NoError(t, s.Close())
+
+	expected, _ := MTHRangeFromLeafHashes(indexBoundLeafHashes(D), 0, uint64(len(D)))
+	for _, c := range results {
+		assert.Equal(t, expected, receive(t, c).Root)
+	}
+}
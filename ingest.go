@@ -0,0 +1,189 @@
+package merkletree
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ingestChunkSize is the number of records read before they are appended to the tree
+const ingestChunkSize = 1024
+
+// ErrMissingField is returned when a record has no value for the field or column leaves are extracted from
+var ErrMissingField = errors.New("merkletree: record is missing the leaf field")
+
+// ParseError reports the line of the input a record could not be ingested from
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("merkletree: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// NewFromNDJSON creates a merkle hash tree with a leaf for every record of the
+// newline delimited JSON read from r. See AppendNDJSON.
+func NewFromNDJSON(r io.Reader, field string, opts ...Option) (*MerkleHashTree, error) {
+	tree := New(nil, opts...)
+	if _, err := tree.AppendNDJSON(r, field); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// AppendNDJSON appends a leaf for every record of the newline delimited JSON read
+// from r and returns the new merkle root. The leaf is the value of the top level
+// field of the record, the string itself for JSON strings and the JSON text for
+// other values, or the whole line when field is empty. Blank lines are skipped.
+// The input is read and appended in chunks; on error, the records preceding the
+// failing one have been appended.
+func (m *MerkleHashTree) AppendNDJSON(r io.Reader, field string) ([sha256.Size]byte, error) {
+	br := bufio.NewReader(r)
+	line := 0
+	return m.appendRecords(func() ([]byte, error) {
+		for {
+			text, err := br.ReadBytes('\n')
+			if err == io.EOF && len(text) == 0 {
+				return nil, io.EOF
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			line++
+
+			text = bytes.TrimRight(text, "\r\n")
+			if len(bytes.TrimSpace(text)) == 0 {
+				continue
+			}
+			if field == "" {
+				return text, nil
+			}
+
+			leaf, err := ndjsonField(text, field)
+			if err != nil {
+				return nil, &ParseError{Line: line, Err: err}
+			}
+			return leaf, nil
+		}
+	})
+}
+
+// ndjsonField returns the leaf bytes of field in the JSON object text
+func ndjsonField(text []byte, field string) ([]byte, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(text, &record); err != nil {
+		return nil, err
+	}
+	value, ok := record[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrMissingField, field)
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return []byte(s), nil
+	}
+	return value, nil
+}
+
+// NewFromCSV creates a merkle hash tree with a leaf for every record of the CSV
+// read from r. See AppendCSV.
+func NewFromCSV(r io.Reader, column int, opts ...Option) (*MerkleHashTree, error) {
+	tree := New(nil, opts...)
+	if _, err := tree.AppendCSV(r, column); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// AppendCSV appends a leaf for every record of the CSV read from r and returns the
+// new merkle root. The leaf is the field at the zero based column of the record,
+// or the record as it appears in the input, without its line ending, when column
+// is negative. A header row is ingested like any other record. The input is read
+// and appended in chunks; on error, the records preceding the failing one have
+// been appended.
+func (m *MerkleHashTree) AppendCSV(r io.Reader, column int) ([sha256.Size]byte, error) {
+	raw := &recordingReader{r: r}
+	cr := csv.NewReader(raw)
+	cr.FieldsPerRecord = -1
+
+	return m.appendRecords(func() ([]byte, error) {
+		record, err := cr.Read()
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, &ParseError{Line: perr.Line, Err: perr.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if column < 0 {
+			return bytes.TrimRight(raw.take(cr.InputOffset()), "\r\n"), nil
+		}
+		raw.take(cr.InputOffset())
+		if column >= len(record) {
+			line, _ := cr.FieldPos(0)
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("%w: column %d", ErrMissingField, column)}
+		}
+		return []byte(record[column]), nil
+	})
+}
+
+// recordingReader keeps the bytes read from r until they are taken, so the raw
+// text of a record can be recovered after the CSV reader has parsed it.
+type recordingReader struct {
+	r      io.Reader
+	buf    []byte
+	offset int64
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// take returns the recorded bytes up to the input offset end and forgets them
+func (rr *recordingReader) take(end int64) []byte {
+	n := end - rr.offset
+	taken := append([]byte(nil), rr.buf[:n]...)
+	rr.buf = append(rr.buf[:0], rr.buf[n:]...)
+	rr.offset = end
+	return taken
+}
+
+// appendRecords appends the leaves returned by next in chunks until it returns io.EOF
+func (m *MerkleHashTree) appendRecords(next func() ([]byte, error)) ([sha256.Size]byte, error) {
+	chunk := make([][]byte, 0, ingestChunkSize)
+	for {
+		leaf, err := next()
+		if err != nil {
+			root, appendErr := m.TryAppend(chunk...)
+			if appendErr != nil {
+				return root, appendErr
+			}
+			if err == io.EOF {
+				return root, nil
+			}
+			return root, err
+		}
+
+		chunk = append(chunk, leaf)
+		if len(chunk) == ingestChunkSize {
+			if root, err := m.TryAppend(chunk...); err != nil {
+				return root, err
+			}
+			chunk = chunk[:0]
+		}
+	}
+}
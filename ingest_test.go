@@ -0,0 +1,111 @@
+package merkletree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openFixture(t *testing.T, name string) *os.File {
+	f, err := os.Open(filepath.Join("testdata", "ingest", name))
+	assert.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestNewFromNDJSON(t *testing.T) {
+	tree, err := NewFromNDJSON(openFixture(t, "entries.ndjson"), "digest")
+	assert.NoError(t, err)
+	expected := [][]byte{
+		[]byte("sha256:9f86d081884c7d65"),
+		[]byte("sha256:60303ae22b998861"),
+		[]byte("sha256:fd61a03af4f77d87"),
+		[]byte("sha256:a4e624d686e03ed2"),
+		[]byte("sha256:ca978112ca1bbdca"),
+	}
+	assert.Equal(t, MTH(expected), tree.MerkleRoot())
+
+	tree, err = NewFromNDJSON(openFixture(t, "entries.ndjson"), "size")
+	assert.NoError(t, err)
+	assert.Equal(t, MTH([][]byte{[]byte("1024"), []byte("2048"), []byte("512"), []byte("4096"), []byte("128")}), tree.MerkleRoot())
+
+	content, err := os.ReadFile(filepath.Join("testdata", "ingest", "entries.ndjson"))
+	assert.NoError(t, err)
+	lines := make([][]byte, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line != "" {
+			lines = append(lines, []byte(line))
+		}
+	}
+	tree, err = NewFromNDJSON(openFixture(t, "entries.ndjson"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(lines), tree.MerkleRoot())
+}
+
+func TestAppendNDJSONMalformed(t *testing.T) {
+	tree := New(nil)
+	_, err := tree.AppendNDJSON(openFixture(t, "malformed.ndjson"), "digest")
+	var perr *ParseError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, 3, perr.Line)
+	assert.Equal(t, uint64(2), tree.Size())
+
+	_, err = NewFromNDJSON(openFixture(t, "missing.ndjson"), "digest")
+	assert.ErrorIs(t, err, ErrMissingField)
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, 2, perr.Line)
+}
+
+func TestNewFromCSV(t *testing.T) {
+	tree, err := NewFromCSV(openFixture(t, "entries.csv"), 1)
+	assert.NoError(t, err)
+	expected := [][]byte{[]byte("README"), []byte("docs/a, b.txt"), []byte(`notes/"quoted".md`), []byte("bin/tool")}
+	assert.Equal(t, MTH(expected), tree.MerkleRoot())
+
+	content, err := os.ReadFile(filepath.Join("testdata", "ingest", "entries.csv"))
+	assert.NoError(t, err)
+	lines := make([][]byte, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		lines = append(lines, []byte(line))
+	}
+	tree, err = NewFromCSV(openFixture(t, "entries.csv"), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(lines), tree.MerkleRoot())
+}
+
+func TestAppendCSVMalformed(t *testing.T) {
+	tree := New(nil)
+	_, err := tree.AppendCSV(openFixture(t, "malformed.csv"), 2)
+	var perr *ParseError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, 2, perr.Line)
+	assert.Equal(t, uint64(1), tree.Size())
+
+	_, err = NewFromCSV(openFixture(t, "short.csv"), 2)
+	assert.ErrorIs(t, err, ErrMissingField)
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, 2, perr.Line)
+}
+
+func TestAppendNDJSONChunks(t *testing.T) {
+	var input strings.Builder
+	D := make([][]byte, 3*ingestChunkSize+5)
+	for i := range D {
+		D[i] = []byte(fmt.Sprintf("entry-%d", i))
+		fmt.Fprintf(&input, "{\"entry\": %q}\n", D[i])
+	}
+
+	tree := New(D[:3])
+	root, err := tree.AppendNDJSON(strings.NewReader(input.String()), "entry")
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(append(append([][]byte{}, D[:3]...), D...)), root)
+
+	tree = New(nil, WithMaxLeaves(ingestChunkSize+1))
+	_, err = tree.AppendNDJSON(strings.NewReader(input.String()), "entry")
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Equal(t, uint64(ingestChunkSize), tree.Size())
+}
@@ -0,0 +1,124 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCorruptNode is returned when a stored node does not match the hash of its children
+var ErrCorruptNode = errors.New("merkletree: stored node does not match its children")
+
+// backgroundVerifySlice is the number of nodes StartBackgroundVerify checks per
+// interval, holding the read lock
+const backgroundVerifySlice = 4096
+
+// IntegrityError reports a stored interior node that does not match the hash of
+// its children, e.g. after memory corruption
+type IntegrityError struct {
+	Node     NodeID
+	Stored   [sha256.Size]byte
+	Computed [sha256.Size]byte
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%v: level %d, index %d: stored %x, computed %x", ErrCorruptNode, e.Node.Level, e.Node.Index, e.Stored, e.Computed)
+}
+
+// Unwrap makes errors.Is(err, ErrCorruptNode) report true
+func (e *IntegrityError) Unwrap() error {
+	return ErrCorruptNode
+}
+
+// VerifyIntegrity recomputes every stored interior node from its children and
+// returns an *IntegrityError for the first, lowest, one that does not match. Leaves
+// are only known by their hashes and cannot be checked.
+func (m *MerkleHashTree) VerifyIntegrity() error {
+	defer m.readLock()()
+
+	var first *IntegrityError
+	m.verifyNodes(NodeID{Level: 1}, -1, func(e *IntegrityError) {
+		if first == nil {
+			first = e
+		}
+	})
+	if first != nil {
+		return first
+	}
+	return nil
+}
+
+// StartBackgroundVerify checks the integrity of the tree in the background until
+// ctx is done. Every interval it checks the next slice of stored nodes, as
+// VerifyIntegrity does, holding the read lock only for that slice, and passes an
+// *IntegrityError for every corrupt node to onError. Passes cover the whole tree
+// over several intervals before starting over. The tree must be created
+// WithLocking when it is appended to meanwhile.
+func (m *MerkleHashTree) StartBackgroundVerify(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		next := NodeID{Level: 1}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var corrupt []*IntegrityError
+			unlock := m.readLock()
+			next = m.verifyNodes(next, backgroundVerifySlice, func(e *IntegrityError) {
+				corrupt = append(corrupt, e)
+			})
+			unlock()
+			for _, e := range corrupt {
+				onError(e)
+			}
+		}
+	}()
+}
+
+// verifyNodes checks up to budget stored interior nodes, or all of them with a
+// negative budget, in order of level and index starting at from, reporting the
+// corrupt ones. It stops after the last node, and returns the node to continue
+// from, back at the first interior node once it checked the last one.
+func (m *MerkleHashTree) verifyNodes(from NodeID, budget int, report func(*IntegrityError)) NodeID {
+	id := from
+	wrapped := false
+	for checked := 0; budget < 0 || checked < budget; {
+		for id.Level < uint64(len(m.tree)) && id.Index >= uint64(len(m.tree[id.Level])) {
+			id = NodeID{Level: id.Level + 1}
+		}
+		if id.Level >= uint64(len(m.tree)) {
+			// A slice ends with the pass, and only starts over when it checked nothing
+			if budget < 0 || wrapped || checked > 0 {
+				return NodeID{Level: 1}
+			}
+			id, wrapped = NodeID{Level: 1}, true
+			continue
+		}
+
+		left := m.storedOrCarried(id.Level-1, 2*id.Index)
+		right := m.storedOrCarried(id.Level-1, 2*id.Index+1)
+		computed := m.nodeHash(left, right)
+		if stored := m.tree[id.Level][id.Index]; stored != computed {
+			report(&IntegrityError{Node: id, Stored: stored, Computed: computed})
+		}
+		id.Index++
+		checked++
+	}
+	return id
+}
+
+// storedOrCarried returns the node at index of the level as paired by buildTree:
+// a stored node, or beyond them the node carried up unchanged from below
+func (m *MerkleHashTree) storedOrCarried(level, index uint64) [sha256.Size]byte {
+	for index >= uint64(len(m.tree[level])) {
+		level, index = level-1, 2*index
+	}
+	return m.tree[level][index]
+}
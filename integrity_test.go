@@ -0,0 +1,77 @@
+package merkletree
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyIntegrity(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 13, 64} {
+		tree := New(makeEntries(n))
+		assert.NoError(t, tree.VerifyIntegrity(), "size %d", n)
+	}
+
+	// A corrupt leaf shows in its parent, a corrupt interior node in itself
+	tree := New(makeEntries(13))
+	tree.tree[0][9][0] ^= 1
+	var integrityErr *IntegrityError
+	err := tree.VerifyIntegrity()
+	assert.True(t, errors.As(err, &integrityErr))
+	assert.ErrorIs(t, err, ErrCorruptNode)
+	assert.Equal(t, NodeID{Level: 1, Index: 4}, integrityErr.Node)
+
+	tree = New(makeEntries(13))
+	// Node (3, 1) covers the leaves [8, 13), with leaf 12 carried up from level 0
+	tree.tree[3][1][5] ^= 1
+	err = tree.VerifyIntegrity()
+	assert.True(t, errors.As(err, &integrityErr))
+	assert.Equal(t, NodeID{Level: 3, Index: 1}, integrityErr.Node)
+	assert.Equal(t, tree.tree[3][1], integrityErr.Stored)
+	tree.tree[3][1][5] ^= 1
+	assert.NoError(t, tree.VerifyIntegrity())
+}
+
+func TestStartBackgroundVerify(t *testing.T) {
+	tree := New(makeEntries(2000), WithLocking())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 16)
+	tree.StartBackgroundVerify(ctx, time.Millisecond, func(err error) { errs <- err })
+
+	// Appends go on while the tree is checked
+	for i := 0; i < 100; i++ {
+		tree.Append([]byte{byte(i)})
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock := tree.writeLock()
+	tree.tree[5][40][0] ^= 1
+	unlock()
+
+	select {
+	case err := <-errs:
+		var integrityErr *IntegrityError
+		assert.True(t, errors.As(err, &integrityErr))
+		assert.Equal(t, NodeID{Level: 5, Index: 40}, integrityErr.Node)
+	case <-time.After(5 * time.Second):
+		t.Fatal("corruption was not reported")
+	}
+
+	// Once ctx is done nothing is reported anymore
+	cancel()
+	time.Sleep(5 * time.Millisecond)
+	for len(errs) > 0 {
+		<-errs
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,27 @@
+package merkletree
+
+import "crypto/sha256"
+
+// Tree is the interface of a merkle hash tree that can be appended to and serves
+// proofs. It is satisfied by *MerkleHashTree, and lets code depending on a tree be
+// tested against a fake, such as the one in the fake subpackage.
+type Tree interface {
+	// Size returns the number of leaves in the tree
+	Size() uint64
+	// MerkleRoot returns the root hash of the tree
+	MerkleRoot() [sha256.Size]byte
+	// TreeHead returns the size and root hash of the tree, read atomically
+	TreeHead() TreeHead
+	// LeafIndex returns the index of the first leaf with the given leaf hash, or ErrLeafNotFound
+	LeafIndex(leafHash [sha256.Size]byte) (uint64, error)
+	// InclusionProofAtSize returns the audit path for the leaf at index i in the
+	// tree of the first n leaves, or ErrIndexOutOfRange
+	InclusionProofAtSize(i, n uint64) (InclusionProof, error)
+	// ConsistencyProof returns the consistency proof between the trees of the
+	// first m and n leaves, or ErrInvalidRange
+	ConsistencyProof(m, n uint64) (ConsistencyProof, error)
+	// TryAppend adds leaves to the tree and returns the new root hash
+	TryAppend(d ...[]byte) ([sha256.Size]byte, error)
+}
+
+var _ Tree = (*MerkleHashTree)(nil)
@@ -0,0 +1,90 @@
+package merkletree
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// keccak256Rate is the rate in bytes of the Keccak-256 sponge
+const keccak256Rate = 136
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations holds the rotation offset of lane x+5y
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state a, with lane (x, y) at a[x+5y]
+func keccakF1600(a *[25]uint64) {
+	for round := 0; round < 24; round++ {
+		// theta
+		var c [5]uint64
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+
+		// rho and pi
+		var b [25]uint64
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// keccak256 returns the legacy Keccak-256 digest of data, as used by Ethereum,
+// which differs from SHA3-256 in its padding.
+func keccak256(data []byte) [32]byte {
+	var a [25]uint64
+	absorb := func(block []byte) {
+		for i := 0; i < keccak256Rate/8; i++ {
+			a[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+		}
+		keccakF1600(&a)
+	}
+
+	for len(data) >= keccak256Rate {
+		absorb(data[:keccak256Rate])
+		data = data[keccak256Rate:]
+	}
+	var last [keccak256Rate]byte
+	copy(last[:], data)
+	last[len(data)] ^= 0x01
+	last[keccak256Rate-1] ^= 0x80
+	absorb(last[:])
+
+	var digest [32]byte
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(digest[i*8:], a[i])
+	}
+	return digest
+}
@@ -0,0 +1,115 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Errors returned by keyed appends and lookups
+var (
+	ErrDuplicateKey = errors.New("merkletree: key is already in the tree")
+	ErrKeyNotFound  = errors.New("merkletree: key not found")
+)
+
+// keyedLeaf is a leaf appended with AppendKeyed
+type keyedLeaf struct {
+	index uint64
+	key   string
+}
+
+// WithKeyOverwrite lets AppendKeyed append a leaf for a key already in the tree,
+// which then refers to the new leaf, instead of failing with ErrDuplicateKey
+func WithKeyOverwrite() Option {
+	return func(m *MerkleHashTree) {
+		m.keyOverwrite = true
+	}
+}
+
+// AppendKeyed appends a leaf for d, to be looked up by key with IndexOfKey and
+// InclusionProofByKey, and returns the new merkle root. A key already in the tree
+// fails with ErrDuplicateKey unless the tree is created WithKeyOverwrite. Keys
+// are saved by SaveEncrypted, and those of leaves dropped by Truncate forgotten.
+func (m *MerkleHashTree) AppendKeyed(key string, d []byte) ([sha256.Size]byte, error) {
+	hash := m.leafHasher([][]byte{d})
+
+	defer m.writeLock()()
+	if _, ok := m.keys[key]; ok && !m.keyOverwrite {
+		return m.root(), fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+	}
+	size := uint64(len(m.tree[0]))
+	leaves, err := hash(size)
+	if err != nil {
+		return m.root(), err
+	}
+	root, err := m.admitLeafHashes(leaves)
+	if err != nil {
+		return root, err
+	}
+	return root, m.setKey(key, size)
+}
+
+// setKey makes key refer to the leaf at index, keeping the leaves it referred to
+// before so Truncate can restore them
+func (m *MerkleHashTree) setKey(key string, index uint64) error {
+	if index >= uint64(len(m.tree[0])) {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, len(m.tree[0]))
+	}
+	if m.keys == nil {
+		m.keys = make(map[string][]uint64)
+	}
+	m.keys[key] = append(m.keys[key], index)
+	return nil
+}
+
+// IndexOfKey returns the index of the latest leaf appended for key
+func (m *MerkleHashTree) IndexOfKey(key string) (uint64, error) {
+	defer m.readLock()()
+	return m.indexOfKey(key)
+}
+
+func (m *MerkleHashTree) indexOfKey(key string) (uint64, error) {
+	indexes, ok := m.keys[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+	return indexes[len(indexes)-1], nil
+}
+
+// InclusionProofByKey returns the audit path of the latest leaf appended for key
+func (m *MerkleHashTree) InclusionProofByKey(key string) (InclusionProof, error) {
+	defer m.readLock()()
+	i, err := m.indexOfKey(key)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return m.inclusionProofAtSize(i, uint64(len(m.tree[0])))
+}
+
+// truncateKeys forgets the leaves from index n on
+func (m *MerkleHashTree) truncateKeys(n uint64) {
+	for key, indexes := range m.keys {
+		kept := len(indexes)
+		for kept > 0 && indexes[kept-1] >= n {
+			kept--
+		}
+		if kept == 0 {
+			delete(m.keys, key)
+		} else {
+			m.keys[key] = indexes[:kept]
+		}
+	}
+}
+
+// keyedLeaves returns every leaf appended with AppendKeyed, in order of index
+func (m *MerkleHashTree) keyedLeaves() []keyedLeaf {
+	var keyed []keyedLeaf
+	for key, indexes := range m.keys {
+		for _, i := range indexes {
+			keyed = append(keyed, keyedLeaf{index: i, key: key})
+		}
+	}
+	sort.Slice(keyed, func(a, b int) bool { return keyed[a].index < keyed[b].index })
+	return keyed
+}
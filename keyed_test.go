@@ -0,0 +1,110 @@
+package merkletree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendKeyed(t *testing.T) {
+	tree := New(makeEntries(3))
+	D := makeEntries(3)
+	for i := 0; i < 500; i++ {
+		d := []byte(fmt.Sprintf("order-%d data", i))
+		D = append(D, d)
+		_, err := tree.AppendKeyed(fmt.Sprintf("order-%d", i), d)
+		assert.NoError(t, err)
+		if i%7 == 0 {
+			tree.Append([]byte("unkeyed"))
+			D = append(D, []byte("unkeyed"))
+		}
+	}
+	root := tree.MerkleRoot()
+	assert.Equal(t, MTH(D), root)
+
+	for _, i := range []int{0, 1, 250, 499} {
+		key := fmt.Sprintf("order-%d", i)
+		index, err := tree.IndexOfKey(key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("order-%d data", i)), D[index])
+		proof, err := tree.InclusionProofByKey(key)
+		assert.NoError(t, err)
+		assert.Equal(t, index, proof.LeafIndex)
+		assert.NoError(t, VerifyInclusion(leafHash(D[index]), root, proof))
+	}
+
+	_, err := tree.AppendKeyed("order-7", []byte("again"))
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, root, tree.MerkleRoot())
+	_, err = tree.IndexOfKey("order-500")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = tree.InclusionProofByKey("order-500")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// A rejected append does not add the key
+	full := New(nil, WithMaxLeaves(1))
+	_, err = full.AppendKeyed("a", []byte("a"))
+	assert.NoError(t, err)
+	_, err = full.AppendKeyed("b", []byte("b"))
+	assert.ErrorIs(t, err, ErrLogFull)
+	_, err = full.IndexOfKey("b")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestAppendKeyedOverwrite(t *testing.T) {
+	tree := New(nil, WithKeyOverwrite())
+	for _, d := range []string{"v1", "v2", "v3"} {
+		_, err := tree.AppendKeyed("order", []byte(d))
+		assert.NoError(t, err)
+		tree.AppendKeyed("other-"+d, nil)
+	}
+	index, err := tree.IndexOfKey("order")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), index)
+
+	// Truncate restores the leaf a key referred to before
+	_, err = tree.Truncate(4)
+	assert.NoError(t, err)
+	index, err = tree.IndexOfKey("order")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), index)
+	_, err = tree.IndexOfKey("other-v3")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = tree.Truncate(0)
+	assert.NoError(t, err)
+	_, err = tree.IndexOfKey("order")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestAppendKeyedPersistence(t *testing.T) {
+	tree := New(nil, WithKeyOverwrite())
+	for i := 0; i < 20; i++ {
+		tree.AppendKeyed(fmt.Sprintf("k%d", i%8), []byte{byte(i)})
+	}
+	tree.AppendKeyed("", []byte("empty key"))
+
+	key := bytes.Repeat([]byte{3}, 32)
+	var buf bytes.Buffer
+	assert.NoError(t, tree.SaveEncrypted(&buf, key))
+	loaded, err := LoadEncrypted(&buf, key, WithKeyOverwrite())
+	assert.NoError(t, err)
+	assert.Equal(t, tree.keys, loaded.keys)
+	for i := 0; i < 8; i++ {
+		want, _ := tree.IndexOfKey(fmt.Sprintf("k%d", i))
+		got, err := loaded.IndexOfKey(fmt.Sprintf("k%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	index, err := loaded.IndexOfKey("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), index)
+
+	// History survives as well, as Truncate shows
+	_, err = loaded.Truncate(10)
+	assert.NoError(t, err)
+	index, err = loaded.IndexOfKey("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(9), index)
+}
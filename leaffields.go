@@ -0,0 +1,47 @@
+package merkletree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedLeafFields is returned when decoding leaf data not encoded by EncodeLeafFields
+var ErrMalformedLeafFields = errors.New("merkletree: malformed leaf fields")
+
+// EncodeLeafFields returns the leaf data for a leaf made of several fields: each
+// field preceded by its length in bytes as an unsigned varint, as written by
+// binary.AppendUvarint. Unlike concatenating the fields, the encoding is
+// unambiguous: different lists of fields never have the same encoding. Fixed size
+// values such as integers should be encoded to bytes with a fixed width first.
+func EncodeLeafFields(fields ...[]byte) []byte {
+	size := 0
+	for _, f := range fields {
+		size += binary.MaxVarintLen64 + len(f)
+	}
+
+	b := make([]byte, 0, size)
+	for _, f := range fields {
+		b = binary.AppendUvarint(b, uint64(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+// DecodeLeafFields returns the fields of leaf data encoded by EncodeLeafFields
+func DecodeLeafFields(b []byte) ([][]byte, error) {
+	fields := make([][]byte, 0)
+	for len(b) > 0 {
+		n, read := binary.Uvarint(b)
+		if read <= 0 {
+			return nil, fmt.Errorf("%w: bad length of field %d", ErrMalformedLeafFields, len(fields))
+		}
+		b = b[read:]
+		if n > uint64(len(b)) {
+			return nil, fmt.Errorf("%w: field %d of %d bytes exceeds the data", ErrMalformedLeafFields, len(fields), n)
+		}
+		fields = append(fields, b[:n:n])
+		b = b[n:]
+	}
+	return fields, nil
+}
@@ -0,0 +1,65 @@
+package merkletree
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeLeafFieldsUnambiguous(t *testing.T) {
+	// Naive concatenation gives the same leaf for both
+	assert.NotEqual(t, EncodeLeafFields([]byte("ab"), []byte("c")), EncodeLeafFields([]byte("a"), []byte("bc")))
+	assert.NotEqual(t, EncodeLeafFields([]byte("abc")), EncodeLeafFields([]byte("abc"), nil))
+	assert.NotEqual(t, EncodeLeafFields(), EncodeLeafFields(nil))
+	assert.Equal(t, []byte{2, 'a', 'b', 0, 1, 'c'}, EncodeLeafFields([]byte("ab"), nil, []byte("c")))
+}
+
+// randomFields returns up to 4 fields over a tiny alphabet, so that many distinct
+// field lists share their concatenation
+func randomFields(r *rand.Rand) [][]byte {
+	fields := make([][]byte, r.Intn(5))
+	for i := range fields {
+		fields[i] = make([]byte, r.Intn(4))
+		for j := range fields[i] {
+			fields[i][j] = "ab"[r.Intn(2)]
+		}
+	}
+	if r.Intn(8) == 0 && len(fields) > 0 {
+		fields[0] = bytes.Repeat([]byte("a"), 128+r.Intn(256))
+	}
+	return fields
+}
+
+func TestEncodeLeafFieldsInjective(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	seen := make(map[string][][]byte)
+	for i := 0; i < 20000; i++ {
+		fields := randomFields(r)
+		encoded := EncodeLeafFields(fields...)
+
+		decoded, err := DecodeLeafFields(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, len(fields), len(decoded))
+		for j := range fields {
+			assert.True(t, bytes.Equal(fields[j], decoded[j]))
+		}
+
+		if other, ok := seen[string(encoded)]; ok {
+			assert.Equal(t, len(other), len(fields), "%q and %q", other, fields)
+			for j := range other {
+				assert.True(t, bytes.Equal(other[j], fields[j]), "%q and %q", other, fields)
+			}
+		}
+		seen[string(encoded)] = fields
+	}
+}
+
+func TestDecodeLeafFieldsRejectsMalformed(t *testing.T) {
+	encoded := EncodeLeafFields([]byte("user"), []byte("42"))
+	for _, b := range [][]byte{encoded[:len(encoded)-1], {0x80}, {5, 'a'}} {
+		_, err := DecodeLeafFields(b)
+		assert.ErrorIs(t, err, ErrMalformedLeafFields)
+	}
+}
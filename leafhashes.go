@@ -0,0 +1,36 @@
+package merkletree
+
+import "crypto/sha256"
+
+// The functions below take leaf hashes computed elsewhere, such as upstream in a
+// pipeline, in place of entries, and use them as the leaves of the tree without
+// hashing them again. The caller is responsible for the domain separation of
+// RFC 6962: every leaf hash must be SHA-256(0x00 || entry), or the leaf hash of the
+// Hasher or IndexBoundLeafHash of a tree created with them. A hash of the entry
+// without the 0x00 prefix gives another root, and lets a leaf pass for an interior
+// node in proofs.
+
+// NewFromLeafHashes creates a merkle hash tree whose leaves are the given leaf
+// hashes. The tree over the leaf hashes of entries, as returned by verify.LeafHash,
+// has the root of New over the entries.
+func NewFromLeafHashes(hashes [][sha256.Size]byte, opts ...Option) *MerkleHashTree {
+	tree := MerkleHashTree{}
+	for _, opt := range opts {
+		opt(&tree)
+	}
+	tree.tree = make([][][sha256.Size]byte, levels(len(hashes)))
+	tree.tree[0] = append(make([][sha256.Size]byte, 0, len(hashes)), hashes...)
+	tree.buildTree(tree.tree[0])
+	tree.recordRoot()
+	return &tree
+}
+
+// AppendLeafHashes adds leaves with the given leaf hashes to the tree and returns
+// the new merkle root. Like Append, a batch rejected because the tree is full or
+// sealed, or out of order in a sorted tree, leaves the tree unchanged. The admission hook is not called, as
+// there are no entries to pass it.
+func (m *MerkleHashTree) AppendLeafHashes(hashes ...[sha256.Size]byte) [sha256.Size]byte {
+	defer m.writeLock()()
+	root, _ := m.admitLeafHashes(hashes)
+	return root
+}
@@ -0,0 +1,52 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+func TestNewFromLeafHashes(t *testing.T) {
+	D := makeEntries(21)
+	hashes := make([][sha256.Size]byte, len(D))
+	for i, d := range D {
+		hashes[i] = verify.LeafHash(d)
+	}
+
+	for n := 0; n <= len(D); n++ {
+		tree := NewFromLeafHashes(hashes[:n])
+		assert.Equal(t, New(D[:n]).MerkleRoot(), tree.MerkleRoot(), "size %d", n)
+		assert.Equal(t, MTH(D[:n]), tree.MerkleRoot(), "size %d", n)
+	}
+
+	// The tree keeps a copy of the hashes
+	tree := NewFromLeafHashes(hashes[:10], WithLocking())
+	hashes[0][0] ^= 1
+	assert.Equal(t, MTH(D[:10]), tree.MerkleRoot())
+	hashes[0][0] ^= 1
+
+	assert.Equal(t, MTH(D[:13]), tree.AppendLeafHashes(hashes[10:13]...))
+	assert.Equal(t, MTH(D[:14]), tree.AppendLeafHashes(hashes[13]))
+	assert.Equal(t, MTH(D[:15]), tree.Append(D[14]))
+	assert.Equal(t, MTH(D[:15]), tree.AppendLeafHashes())
+	p, err := tree.InclusionProofByIndex(12)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Verify(hashes[12], MTH(D[:15])))
+
+	// Without the leaf prefix the hashes are those of other leaves
+	unprefixed := make([][sha256.Size]byte, len(D))
+	for i, d := range D {
+		unprefixed[i] = sha256.Sum256(d)
+	}
+	assert.NotEqual(t, MTH(D), NewFromLeafHashes(unprefixed).MerkleRoot())
+}
+
+func TestAppendLeafHashesRejected(t *testing.T) {
+	D := makeEntries(4)
+	hashes := [][sha256.Size]byte{verify.LeafHash(D[0]), verify.LeafHash(D[1]), verify.LeafHash(D[2])}
+	tree := NewFromLeafHashes(hashes[:2], WithMaxLeaves(2))
+	assert.Equal(t, MTH(D[:2]), tree.AppendLeafHashes(hashes[2]))
+	assert.Equal(t, uint64(2), tree.Size())
+}
@@ -0,0 +1,71 @@
+package merkletree
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// LeafSource returns the entry of the leaf at index i from storage kept outside the tree
+type LeafSource func(i uint64) ([]byte, error)
+
+// LeafSourceError is returned when the LeafSource of a tree fails to return an entry
+type LeafSourceError struct {
+	Index uint64
+	Err   error
+}
+
+func (e *LeafSourceError) Error() string {
+	return fmt.Sprintf("merkletree: leaf source: index %d: %v", e.Index, e.Err)
+}
+
+func (e *LeafSourceError) Unwrap() error {
+	return e.Err
+}
+
+// WithLeafSource lets the tree fetch entries from source when it needs them, without
+// storing or caching them: GetEntry, and thus the entries served by NewHandler, falls
+// back to source for leaves not appended with AppendWithExtra, and proofs by value
+// only accept a leaf when source confirms it holds the entry.
+func WithLeafSource(source LeafSource) Option {
+	return func(m *MerkleHashTree) {
+		m.leafSource = source
+	}
+}
+
+// fetchEntry returns the entry at index i from the leaf source of the tree
+func (m *MerkleHashTree) fetchEntry(i uint64) ([]byte, error) {
+	e, err := m.leafSource(i)
+	if err != nil {
+		return nil, &LeafSourceError{Index: i, Err: err}
+	}
+	return e, nil
+}
+
+// InclusionProofOfEntry returns the inclusion proof of the first leaf of entry e.
+// Without a LeafSource, that is the first leaf with the leaf hash of e. With one,
+// leaves whose entry in the source is not e are skipped, and errors of the source
+// are returned as a *LeafSourceError. The source is not called with the tree locked.
+func (m *MerkleHashTree) InclusionProofOfEntry(e []byte) (InclusionProof, error) {
+	unlock := m.readLock()
+	candidates := m.indexesOfEntry(e)
+	source := m.leafSource
+	unlock()
+
+	for _, i := range candidates {
+		if source != nil {
+			stored, err := m.fetchEntry(i)
+			if err != nil {
+				return InclusionProof{}, err
+			}
+			if !bytes.Equal(stored, e) {
+				continue
+			}
+		}
+
+		unlock := m.readLock()
+		p, err := m.inclusionProofAtSize(i, uint64(len(m.tree[0])))
+		unlock()
+		return p, err
+	}
+	return InclusionProof{}, ErrLeafNotFound
+}
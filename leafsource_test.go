@@ -0,0 +1,136 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceSource is a LeafSource reading from an in-memory slice
+func sliceSource(D [][]byte, calls *int) LeafSource {
+	return func(i uint64) ([]byte, error) {
+		*calls++
+		if i >= uint64(len(D)) {
+			return nil, errors.New("no such entry")
+		}
+		return D[i], nil
+	}
+}
+
+func TestLeafSourceMatchesStoredEntries(t *testing.T) {
+	D := makeEntries(9)
+	D = append(D, D[3])
+
+	for _, opts := range [][]Option{nil, {WithIndexBoundLeaves()}} {
+		var calls int
+		sourced := New(D, append(opts, WithLeafSource(sliceSource(D, &calls)))...)
+		stored := New(nil, opts...)
+		for _, d := range D {
+			_, err := stored.AppendWithExtra(d, nil)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, stored.MerkleRoot(), sourced.MerkleRoot())
+
+		for i, d := range D {
+			want, _, err := stored.GetEntry(uint64(i))
+			assert.NoError(t, err)
+			got, extra, err := sourced.GetEntry(uint64(i))
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.Empty(t, extra)
+
+			wantProof, err := stored.InclusionProofOfEntry(d)
+			assert.NoError(t, err)
+			gotProof, err := sourced.InclusionProofOfEntry(d)
+			assert.NoError(t, err)
+			assert.Equal(t, wantProof, gotProof)
+			assert.Equal(t, stored.InclusionProof(d), sourced.InclusionProof(d))
+		}
+
+		_, _, err := sourced.GetEntry(uint64(len(D)))
+		assert.ErrorIs(t, err, ErrIndexOutOfRange)
+		_, err = sourced.InclusionProofOfEntry([]byte("missing"))
+		assert.ErrorIs(t, err, ErrLeafNotFound)
+		assert.Equal(t, 3*len(D), calls)
+	}
+}
+
+func TestLeafSourceSkipsUnconfirmedLeaves(t *testing.T) {
+	D := makeEntries(6)
+	tree := New(append(D, D[2]))
+
+	// The source holds another entry at the first leaf of D[2], e.g. after a
+	// faulty restore, so only the duplicate at index 6 is confirmed.
+	source := append(append([][]byte(nil), D...), D[2])
+	source[2] = []byte("overwritten")
+	var calls int
+	sourced := New(append(D, D[2]), WithLeafSource(sliceSource(source, &calls)))
+
+	p, err := sourced.InclusionProofOfEntry(D[2])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), p.LeafIndex)
+	assert.NoError(t, VerifyInclusion(leafHash(D[2]), sourced.MerkleRoot(), p))
+	assert.Equal(t, 2, calls)
+
+	p, err = tree.InclusionProofOfEntry(D[2])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), p.LeafIndex)
+
+	source[6] = []byte("overwritten")
+	_, err = sourced.InclusionProofOfEntry(D[2])
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+	assert.Empty(t, sourced.InclusionProof(D[2]))
+}
+
+func TestLeafSourceErrorsPropagate(t *testing.T) {
+	D := makeEntries(5)
+	errUnavailable := errors.New("store unavailable")
+	tree := New(D, WithLeafSource(func(i uint64) ([]byte, error) {
+		return nil, errUnavailable
+	}))
+
+	_, _, err := tree.GetEntry(3)
+	assert.ErrorIs(t, err, errUnavailable)
+	var sourceErr *LeafSourceError
+	if assert.ErrorAs(t, err, &sourceErr) {
+		assert.Equal(t, uint64(3), sourceErr.Index)
+	}
+
+	_, err = tree.InclusionProofOfEntry(D[1])
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Empty(t, tree.InclusionProof(D[1]))
+
+	server := httptest.NewServer(NewHandler(tree))
+	defer server.Close()
+	resp, err := http.Get(server.URL + EntriesPath + "?start=0&end=1")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestHandlerServesEntriesFromLeafSource(t *testing.T) {
+	D := makeEntries(5)
+	var calls int
+	tree := New(D, WithLeafSource(sliceSource(D, &calls)))
+	server := httptest.NewServer(NewHandler(tree))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + EntriesPath + "?start=1&end=3")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var entries entriesResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	if assert.Len(t, entries.Entries, 3) {
+		for i, e := range entries.Entries {
+			assert.Equal(t, D[i+1], e.LeafInput)
+			assert.Empty(t, e.ExtraData)
+		}
+	}
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,308 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Errors returned by UnmarshalBinary
+var (
+	// ErrCorruptTree is returned for data that is not a tree returned by MarshalBinary
+	ErrCorruptTree = errors.New("merkletree: corrupt tree encoding")
+	// ErrLeafModeMismatch is returned for a tree whose leaves are index bound or
+	// sorted when those of the receiving tree are not, or the other way round
+	ErrLeafModeMismatch = errors.New("merkletree: tree of another leaf mode")
+)
+
+const (
+	treeMagic   = "MTHT"
+	treeVersion = 1
+)
+
+// Leaf modes of an encoded tree
+const (
+	treeIndexBound = 1 << iota
+	treeSorted
+)
+
+// MarshalBinary returns the tree, with the state kept along with its hashes. A
+// header holds the format version, the hash size, the name of the Hasher, the leaf
+// mode, the Generation and the number of leaves, which determines the number of
+// hashes of every level. The hashes follow level by level from the leaves to the root, then the
+// sections below as EncodeLeafFields of:
+//
+//   - the entries stored with AppendWithExtra, as EncodeLeafFields of the index,
+//     a byte set when the entry is encoded with a Codec, the leaf and the extra
+//     data of every entry
+//   - the indexes of redacted leaves
+//   - EncodeLeafFields of the index and key of every leaf appended with AppendKeyed
+//   - the ChunkLayout, empty without one
+//   - the name of the entry Codec, empty without one
+//
+// Integers are big endian uint64s, and a CRC-32C checksum ends the output.
+func (m *MerkleHashTree) MarshalBinary() ([]byte, error) {
+	defer m.readLock()()
+
+	name := m.Hasher().Name()
+	nodes := 0
+	for _, level := range m.tree {
+		nodes += len(level)
+	}
+	sections := EncodeLeafFields(m.marshalSections()...)
+	b := make([]byte, 0, len(treeMagic)+4+len(name)+8+8+nodes*sha256.Size+len(sections)+4)
+	b = append(b, treeMagic...)
+	b = append(b, treeVersion, sha256.Size, byte(len(name)))
+	b = append(b, name...)
+	b = append(b, m.leafMode())
+	b = binary.BigEndian.AppendUint64(b, m.generation)
+	b = binary.BigEndian.AppendUint64(b, uint64(len(m.tree[0])))
+	for _, level := range m.tree {
+		for _, h := range level {
+			b = append(b, h[:]...)
+		}
+	}
+	b = append(b, sections...)
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, crcTable)), nil
+}
+
+// leafMode returns the leaf mode of the tree encoded by MarshalBinary
+func (m *MerkleHashTree) leafMode() byte {
+	var mode byte
+	if m.indexBound {
+		mode |= treeIndexBound
+	}
+	if m.sorted {
+		mode |= treeSorted
+	}
+	return mode
+}
+
+// marshalSections returns the sections of the tree encoded by MarshalBinary
+func (m *MerkleHashTree) marshalSections() [][]byte {
+	var entries [][]byte
+	for i, e := range m.entries {
+		if e.leaf == nil {
+			continue
+		}
+		encoded := []byte{0}
+		if e.encoded {
+			encoded[0] = 1
+		}
+		entries = append(entries, binary.BigEndian.AppendUint64(nil, uint64(i)), encoded, e.leaf, e.extra)
+	}
+
+	redacted := make([]byte, 0, 8*len(m.redacted))
+	for _, i := range m.redactedIndexes() {
+		redacted = binary.BigEndian.AppendUint64(redacted, i)
+	}
+
+	var keys [][]byte
+	for _, k := range m.keyedLeaves() {
+		keys = append(keys, binary.BigEndian.AppendUint64(nil, k.index), []byte(k.key))
+	}
+
+	var layout []byte
+	if m.chunkLayout != nil {
+		layout, _ = m.chunkLayout.MarshalBinary()
+	}
+	var codec []byte
+	if m.codec != nil {
+		codec = []byte(m.codec.Name())
+	}
+	return [][]byte{EncodeLeafFields(entries...), redacted, EncodeLeafFields(keys...), layout, codec}
+}
+
+// UnmarshalBinary replaces the tree with one returned by MarshalBinary, keeping
+// the options of the tree, and restoring the generation, entries, redactions, keys
+// and chunk layout of the encoded tree. A tree without an entry Codec takes the codec of the
+// encoded tree, which must be registered with RegisterCodec.
+//
+// Data of a tree over another Hasher fails with ErrHashMismatch, and of a tree
+// whose leaves are index bound or sorted when those of the tree are not, or the
+// other way round, with ErrLeafModeMismatch. More leaves than allowed WithMaxLeaves
+// fail with ErrLogFull. Truncated or corrupted data fails with ErrCorruptTree, or
+// with an *IntegrityError when a stored node does not match its children, before
+// the tree is changed.
+func (m *MerkleHashTree) UnmarshalBinary(data []byte) error {
+	header := len(treeMagic) + 3
+	if len(data) < header+1+8+8+4 || string(data[:len(treeMagic)]) != treeMagic {
+		return fmt.Errorf("%w: invalid header", ErrCorruptTree)
+	}
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptTree)
+	}
+	if version := body[len(treeMagic)]; version != treeVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrCorruptTree, version)
+	}
+	if size := body[len(treeMagic)+1]; size != sha256.Size {
+		return fmt.Errorf("%w: %d-byte hashes", ErrCorruptTree, size)
+	}
+	nameLen := int(body[len(treeMagic)+2])
+	if len(body) < header+nameLen+1+8+8 {
+		return fmt.Errorf("%w: invalid header", ErrCorruptTree)
+	}
+	name := string(body[header : header+nameLen])
+	if want := m.Hasher().Name(); name != want {
+		return fmt.Errorf("%w: tree of %s, not %s", ErrHashMismatch, name, want)
+	}
+	body = body[header+nameLen:]
+	if mode, want := body[0], m.leafMode(); mode != want {
+		return fmt.Errorf("%w: leaf mode %d, not %d", ErrLeafModeMismatch, mode, want)
+	}
+	generation := binary.BigEndian.Uint64(body[1:])
+	n := binary.BigEndian.Uint64(body[1+8:])
+	body = body[1+8+8:]
+
+	// Every level but the leaves holds the pairs of the level below, counting the
+	// node carried up from it
+	counts := []uint64{n}
+	for carried := n; carried > 1; carried = (carried + 1) / 2 {
+		counts = append(counts, carried/2)
+	}
+	total := uint64(0)
+	for _, c := range counts {
+		if c > uint64(len(body))/sha256.Size-total {
+			return fmt.Errorf("%w: %d bytes of hashes for %d leaves", ErrCorruptTree, len(body), n)
+		}
+		total += c
+	}
+
+	decoded := &MerkleHashTree{tree: make([][][sha256.Size]byte, len(counts)), hasher: m.hasher, indexBound: m.indexBound, codec: m.codec}
+	for l, c := range counts {
+		decoded.tree[l] = make([][sha256.Size]byte, c)
+		for i := range decoded.tree[l] {
+			copy(decoded.tree[l][i][:], body)
+			body = body[sha256.Size:]
+		}
+	}
+	var corrupt *IntegrityError
+	decoded.verifyNodes(NodeID{Level: 1}, -1, func(e *IntegrityError) {
+		if corrupt == nil {
+			corrupt = e
+		}
+	})
+	if corrupt != nil {
+		return corrupt
+	}
+	if m.sorted {
+		if i := unsortedAt(nil, decoded.tree[0]); i >= 0 {
+			return fmt.Errorf("%w: at index %d", ErrUnsortedLeaves, i)
+		}
+	}
+	if m.maxLeaves > 0 && n > m.maxLeaves {
+		return fmt.Errorf("%w: %d leaves, %d remaining", ErrLogFull, n, m.maxLeaves)
+	}
+	if err := decoded.unmarshalSections(body); err != nil {
+		return err
+	}
+
+	defer m.writeLock()()
+	if err := m.persist(0, decoded.tree[0], n); err != nil {
+		return err
+	}
+	m.tree = decoded.tree
+	m.entries, m.redacted, m.keys, m.chunkLayout, m.codec = decoded.entries, decoded.redacted, decoded.keys, decoded.chunkLayout, decoded.codec
+	m.generation = generation
+	m.lastDelta = nil
+	if m.proofCache != nil {
+		m.proofCache.purge()
+	}
+	if m.sealWhenFull && m.remaining() == 0 {
+		m.sealed = true
+	}
+	m.recordRoot()
+	return nil
+}
+
+// unmarshalSections restores the sections encoded by marshalSections into the
+// decoded tree m, checking them against its leaves
+func (m *MerkleHashTree) unmarshalSections(b []byte) error {
+	sections, err := DecodeLeafFields(b)
+	if err != nil || len(sections) != 5 {
+		return fmt.Errorf("%w: invalid sections", ErrCorruptTree)
+	}
+	entries, err := DecodeLeafFields(sections[0])
+	if err != nil || len(entries)%4 != 0 {
+		return fmt.Errorf("%w: invalid entries", ErrCorruptTree)
+	}
+	keys, err := DecodeLeafFields(sections[2])
+	if err != nil || len(keys)%2 != 0 || len(sections[1])%8 != 0 {
+		return fmt.Errorf("%w: invalid keys or redactions", ErrCorruptTree)
+	}
+	n := uint64(len(m.tree[0]))
+	index := func(b []byte) (uint64, error) {
+		if len(b) != 8 || binary.BigEndian.Uint64(b) >= n {
+			return 0, fmt.Errorf("%w: invalid index", ErrCorruptTree)
+		}
+		return binary.BigEndian.Uint64(b), nil
+	}
+
+	if name := string(sections[4]); name != "" && m.codec == nil {
+		codecsMu.RLock()
+		m.codec = codecs[name]
+		codecsMu.RUnlock()
+		if m.codec == nil {
+			return fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+		}
+	}
+
+	for len(entries) > 0 {
+		i, err := index(entries[0])
+		if err != nil {
+			return err
+		}
+		if i < uint64(len(m.entries)) {
+			return fmt.Errorf("%w: entry %d out of order", ErrCorruptTree, i)
+		}
+		if len(entries[1]) != 1 || entries[1][0] > 1 {
+			return fmt.Errorf("%w: invalid entry %d", ErrCorruptTree, i)
+		}
+		e := storedEntry{
+			leaf:    append(make([]byte, 0, len(entries[2])), entries[2]...),
+			extra:   append(make([]byte, 0, len(entries[3])), entries[3]...),
+			encoded: entries[1][0] == 1,
+		}
+		leaf := e.leaf
+		if e.encoded {
+			if leaf, err = decodeEntry(e.leaf); err != nil {
+				return fmt.Errorf("%w: entry %d: %v", ErrCorruptTree, i, err)
+			}
+		}
+		if m.hashLeaves(i, [][]byte{leaf})[0] != m.tree[0][i] {
+			return fmt.Errorf("%w: entry %d does not match its leaf", ErrCorruptTree, i)
+		}
+		m.storeEntry(i, e)
+		entries = entries[4:]
+	}
+
+	for b := sections[1]; len(b) > 0; b = b[8:] {
+		i, err := index(b[:8])
+		if err != nil {
+			return err
+		}
+		if err := m.markRedacted(i); err != nil {
+			return err
+		}
+	}
+	for ; len(keys) > 0; keys = keys[2:] {
+		i, err := index(keys[0])
+		if err != nil {
+			return err
+		}
+		if err := m.setKey(string(keys[1]), i); err != nil {
+			return err
+		}
+	}
+
+	if len(sections[3]) > 0 {
+		m.chunkLayout = &ChunkLayout{}
+		if err := m.chunkLayout.UnmarshalBinary(sections[3]); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptTree, err)
+		}
+	}
+	return nil
+}
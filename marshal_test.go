@@ -0,0 +1,219 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 8, 3000} {
+		D := makeEntries(n)
+		tree := New(D)
+		b, err := tree.MarshalBinary()
+		assert.NoError(t, err, "size %d", n)
+
+		var loaded MerkleHashTree
+		assert.NoError(t, loaded.UnmarshalBinary(b), "size %d", n)
+		assert.Equal(t, tree.tree, loaded.tree, "size %d", n)
+		assert.Equal(t, MTH(D), loaded.MerkleRoot(), "size %d", n)
+		assert.Equal(t, uint64(n), loaded.Size(), "size %d", n)
+		for _, i := range []int{0, n / 2, n - 1} {
+			if i < 0 || i >= n {
+				continue
+			}
+			p, err := loaded.InclusionProofByIndex(uint64(i))
+			assert.NoError(t, err, "size %d", n)
+			assert.Equal(t, Path(uint64(i), D), p.Hashes, "size %d", n)
+		}
+
+		// The loaded tree appends like the original
+		D = append(D, []byte("appended"))
+		assert.Equal(t, MTH(D), loaded.Append(D[n]), "size %d", n)
+	}
+}
+
+func TestMarshalBinaryHasher(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	D := makeEntries(9)
+	b, err := New(D, WithHasher(h)).MarshalBinary()
+	assert.NoError(t, err)
+
+	loaded := New(nil, WithHasher(h))
+	assert.NoError(t, loaded.UnmarshalBinary(b))
+	assert.Equal(t, referenceMTH(h, D), loaded.MerkleRoot())
+	assert.NoError(t, loaded.VerifyIntegrity())
+
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(b), ErrHashMismatch)
+}
+
+func TestUnmarshalBinaryCorrupt(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D[:6])
+	_, err := tree.AppendWithExtra(D[6], []byte("extra"))
+	assert.NoError(t, err)
+	b, err := tree.MarshalBinary()
+	assert.NoError(t, err)
+
+	for n := 0; n < len(b); n++ {
+		assert.ErrorIs(t, New(nil).UnmarshalBinary(b[:n]), ErrCorruptTree, "%d bytes", n)
+	}
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(append(b, 0)), ErrCorruptTree)
+	for i := range b {
+		flipped := append([]byte(nil), b...)
+		flipped[i] ^= 0x40
+		assert.Error(t, New(nil).UnmarshalBinary(flipped), "byte %d", i)
+	}
+
+	// resum returns data with its checksum fixed up
+	resum := func(data []byte) []byte {
+		body := data[:len(data)-4]
+		return binary.BigEndian.AppendUint32(body, crc32.Checksum(body, crcTable))
+	}
+
+	// A stored node not matching its children is caught behind a valid checksum
+	hashes := hashesOffset(b)
+	interior := append([]byte(nil), b...)
+	interior[hashes+len(D)*sha256.Size+sha256.Size] ^= 1
+	var integrityErr *IntegrityError
+	err = New(nil).UnmarshalBinary(resum(interior))
+	assert.ErrorAs(t, err, &integrityErr)
+	assert.Equal(t, NodeID{Level: 1, Index: 1}, integrityErr.Node)
+
+	leaf := append([]byte(nil), b...)
+	leaf[hashes] ^= 1
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(resum(leaf)), ErrCorruptNode)
+
+	// Hashes for another number of leaves
+	size := append([]byte(nil), b...)
+	binary.BigEndian.PutUint64(size[hashes-8:], 8)
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(resum(size)), ErrCorruptTree)
+	binary.BigEndian.PutUint64(size[hashes-8:], 1<<62)
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(resum(size)), ErrCorruptTree)
+
+	// A failed load leaves the tree unchanged
+	assert.Error(t, tree.UnmarshalBinary(resum(interior)))
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+	leafData, extra, err := tree.GetEntry(6)
+	assert.NoError(t, err)
+	assert.Equal(t, D[6], leafData)
+	assert.Equal(t, []byte("extra"), extra)
+
+	// A successful one replaces the entries along with the hashes
+	other := New(D[:2])
+	_, err = other.AppendWithExtra([]byte("other"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, other.UnmarshalBinary(b))
+	_, extra, err = other.GetEntry(6)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("extra"), extra)
+	_, _, err = other.GetEntry(2)
+	assert.ErrorIs(t, err, ErrEntryNotStored)
+}
+
+// hashesOffset returns the offset of the hashes in b, a tree encoded by MarshalBinary
+func hashesOffset(b []byte) int {
+	return len(treeMagic) + 3 + int(b[len(treeMagic)+2]) + 1 + 8 + 8
+}
+
+func TestMarshalBinaryGeneration(t *testing.T) {
+	D := makeEntries(6)
+	tree := New(D[:2])
+	tree.Append(D[2])
+	tree.Append(D[3:5]...)
+	_, err := tree.SetLeaf(1, D[5])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), tree.Generation())
+	b, err := tree.MarshalBinary()
+	assert.NoError(t, err)
+
+	loaded := New(D)
+	loaded.Append(D...)
+	assert.NoError(t, loaded.UnmarshalBinary(b))
+	head, generation := loaded.GenerationHead()
+	assert.Equal(t, tree.TreeHead(), head)
+	assert.Equal(t, uint64(3), generation)
+
+	// The loaded tree counts on from the generation of the encoded one
+	loaded.Append(D[5])
+	assert.Equal(t, uint64(4), loaded.Generation())
+}
+
+func TestMarshalBinaryState(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D[:3], WithEntryCodec(GzipCodec(1)))
+	_, err := tree.AppendWithExtra(D[3], []byte("extra 3"))
+	assert.NoError(t, err)
+	_, err = tree.AppendKeyed("k4", D[4])
+	assert.NoError(t, err)
+	_, err = tree.AppendWithExtra(D[5], []byte("extra 5"))
+	assert.NoError(t, err)
+	_, err = tree.AppendKeyed("k6", D[6])
+	assert.NoError(t, err)
+	_, err = tree.Redact(5, nil)
+	assert.NoError(t, err)
+	layout := ChunkLayout{Params: CDCParams{Min: 1, Avg: 2, Max: 4}, Sizes: []uint64{1, 2}}
+	tree.chunkLayout = &layout
+	b, err := tree.MarshalBinary()
+	assert.NoError(t, err)
+
+	loaded := New(nil)
+	assert.NoError(t, loaded.UnmarshalBinary(b))
+	assert.Equal(t, tree.MerkleRoot(), loaded.MerkleRoot())
+	leaf, extra, err := loaded.GetEntry(3)
+	assert.NoError(t, err)
+	assert.Equal(t, D[3], leaf)
+	assert.Equal(t, []byte("extra 3"), extra)
+	_, _, err = loaded.GetEntry(5)
+	assert.ErrorIs(t, err, ErrRedacted)
+	assert.Equal(t, []uint64{5}, loaded.Redacted())
+	i, err := loaded.IndexOfKey("k6")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), i)
+	loadedLayout, ok := loaded.ChunkLayout()
+	assert.True(t, ok)
+	assert.Equal(t, layout, loadedLayout)
+
+	// The loaded tree keeps encoding entries with the codec of the encoded tree
+	_, err = loaded.AppendWithExtra(D[7], []byte("extra 7"))
+	assert.NoError(t, err)
+	assert.True(t, loaded.entries[8].encoded)
+
+	// An entry not matching its leaf is caught behind a valid checksum
+	tree = New(D[:3])
+	_, err = tree.AppendWithExtra(D[3], []byte("extra 3"))
+	assert.NoError(t, err)
+	b, err = tree.MarshalBinary()
+	assert.NoError(t, err)
+	body := append([]byte(nil), b[:len(b)-4]...)
+	body[bytes.LastIndex(body, D[3])] ^= 1
+	body = binary.BigEndian.AppendUint32(body, crc32.Checksum(body, crcTable))
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(body), ErrCorruptTree)
+}
+
+func TestUnmarshalBinaryLeafMode(t *testing.T) {
+	D := makeEntries(5)
+	b, err := New(D).MarshalBinary()
+	assert.NoError(t, err)
+	indexBound, err := New(D, WithIndexBoundLeaves()).MarshalBinary()
+	assert.NoError(t, err)
+	sorted, err := NewFromSortedLeafHashes(nil)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, New(nil, WithIndexBoundLeaves()).UnmarshalBinary(b), ErrLeafModeMismatch)
+	assert.ErrorIs(t, New(nil).UnmarshalBinary(indexBound), ErrLeafModeMismatch)
+	assert.ErrorIs(t, sorted.UnmarshalBinary(b), ErrLeafModeMismatch)
+
+	loaded := New(nil, WithIndexBoundLeaves())
+	assert.NoError(t, loaded.UnmarshalBinary(indexBound))
+	assert.Equal(t, New(D, WithIndexBoundLeaves()).MerkleRoot(), loaded.MerkleRoot())
+	p, err := loaded.InclusionProofOfEntry(D[3])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), p.LeafIndex)
+
+	assert.ErrorIs(t, New(nil, WithMaxLeaves(4)).UnmarshalBinary(b), ErrLogFull)
+}
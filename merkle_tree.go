@@ -2,36 +2,51 @@ package merkletree
 
 import (
 	"crypto/sha256"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree/verify"
 )
 
 // Prefixes for leaves and nodes
 const (
-	LeafPrefix = byte(0)
-	NodePrefix = byte(1)
+	LeafPrefix = verify.LeafPrefix
+	NodePrefix = verify.NodePrefix
 )
 
-func largestPowerOf2SmallerThan(n uint64) uint64 {
-	if n < 2 {
-		return uint64(0)
+// MTH returns Merkle Hash Tree. The input to the Merkle Tree Hash is a list of data entries;
+// The output is a single 32-byte Merkle Tree Hash.
+func MTH(D [][]byte) [sha256.Size]byte {
+	return treeHash(0, uint64(len(D)), func(i uint64) [sha256.Size]byte {
+		return leafHash(D[i])
+	})
+}
+
+// MTHRange returns the Merkle Tree Hash of the entries D[start:end] without copying them.
+func MTHRange(D [][]byte, start, end uint64) ([sha256.Size]byte, error) {
+	if start > end || end > uint64(len(D)) {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: [%d, %d) of %d entries", ErrInvalidRange, start, end, len(D))
 	}
 
-	cur := uint64(1)
-	prev := uint64(1)
-	for i := 0; i < 64; i++ {
-		cur = cur * 2
-		if cur > n-1 {
-			return prev
-		}
-		prev = cur
+	return treeHash(start, end, func(i uint64) [sha256.Size]byte {
+		return leafHash(D[i])
+	}), nil
+}
+
+// MTHRangeFromLeafHashes returns the Merkle Tree Hash of the entries whose leaf hashes are hashes[start:end].
+func MTHRangeFromLeafHashes(hashes [][sha256.Size]byte, start, end uint64) ([sha256.Size]byte, error) {
+	if start > end || end > uint64(len(hashes)) {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: [%d, %d) of %d leaf hashes", ErrInvalidRange, start, end, len(hashes))
 	}
 
-	return uint64(0)
+	return treeHash(start, end, func(i uint64) [sha256.Size]byte {
+		return hashes[i]
+	}), nil
 }
 
-// MTH returns Merkle Hash Tree. The input to the Merkle Tree Hash is a list of data entries;
-// The output is a single 32-byte Merkle Tree Hash.
-func MTH(D [][]byte) [sha256.Size]byte {
-	n := uint64(len(D))
+// treeHash returns the Merkle Tree Hash of the entries with indices in [start, end),
+// where leaf returns the leaf hash of the entry at index i.
+func treeHash(start, end uint64, leaf func(i uint64) [sha256.Size]byte) [sha256.Size]byte {
+	n := end - start
 
 	// The hash of an empty list is the hash of an empty string: MTH({}) = SHA-256().
 	if n == 0 {
@@ -40,21 +55,19 @@ func MTH(D [][]byte) [sha256.Size]byte {
 
 	// The hash of a list with one entry (also known as a leaf hash) is:  MTH({d(0)}) = SHA-256(0x00 || d(0)).
 	if n == 1 {
-		e := []byte{LeafPrefix}
-		e = append(e, D[0]...)
-		return sha256.Sum256(e)
+		return leaf(start)
 	}
 
 	// For n > 1, let k be the largest power of two smaller than n (i.e.,k < n <= 2k).
 	// The Merkle Tree Hash of an n-element list D[n] is then
 	// defined recursively as MTH(D[n]) = SHA - 256(0x01 || MTH(D[0:k]) || MTH(D[k:n]))
 
-	k := largestPowerOf2SmallerThan(n)
+	k := SplitPoint(n)
 
 	e := []byte{NodePrefix}
-	x := MTH(D[0:k])
+	x := treeHash(start, start+k, leaf)
 	e = append(e, x[:]...)
-	x = MTH(D[k:n])
+	x = treeHash(start+k, end, leaf)
 	e = append(e, x[:]...)
 	return sha256.Sum256(e)
 }
@@ -62,28 +75,43 @@ func MTH(D [][]byte) [sha256.Size]byte {
 // Path returns a merkle auidt path. A Merkle audit path for a leaf in a Merkle Hash Tree is the shortest
 // list of additional nodes in the Merkle Tree required to compute the Merkle Tree Hash for that tree.
 // The audit path consists of the list of missing nodes required to compute the nodes leading from a leaf to the root of the tree.
+// It is empty for an index outside of D, use TryPath to tell it apart from the empty path of the only leaf.
 func Path(m uint64, D [][]byte) [][sha256.Size]byte {
-	n := uint64(len(D))
-	path := make([][sha256.Size]byte, 0)
+	path, err := TryPath(m, D)
+	if err != nil {
+		return make([][sha256.Size]byte, 0)
+	}
+	return path
+}
 
-	if m < 0 || m > n {
-		return path
+// TryPath returns the audit path of the leaf at index m of D, like Path. An index
+// outside of D fails with ErrIndexOutOfRange.
+func TryPath(m uint64, D [][]byte) ([][sha256.Size]byte, error) {
+	if n := uint64(len(D)); m >= n {
+		return nil, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, m, n)
 	}
+	return auditPath(m, D), nil
+}
+
+// auditPath returns the audit path of the leaf at index m < len(D) of D
+func auditPath(m uint64, D [][]byte) [][sha256.Size]byte {
+	n := uint64(len(D))
+	path := make([][sha256.Size]byte, 0)
 
 	// The path for the single leaf in a tree with a one-element input list D[1] = {d(0)} is empty: PATH(0, {d(0)}) = {}
 	if m == 0 && n == 1 {
 		return path
 	}
 
-	k := largestPowerOf2SmallerThan(n)
+	k := SplitPoint(n)
 
 	if m < k {
 		// for m < k; PATH(m, D[n]) = PATH(m, D[0:k]) : MTH(D[k:n])
-		path = append(path, Path(m, D[0:k])...)
+		path = append(path, auditPath(m, D[0:k])...)
 		path = append(path, MTH(D[k:n]))
 	} else {
 		// for m >= k, PATH(m, D[n]) = PATH(m - k, D[k:n]) : MTH(D[0:k])
-		path = append(path, Path(m-k, D[k:n])...)
+		path = append(path, auditPath(m-k, D[k:n])...)
 		path = append(path, MTH(D[0:k]))
 	}
 
@@ -108,7 +136,7 @@ func subProof(m uint64, D [][]byte, isKnown bool) (path [][sha256.Size]byte) {
 
 	// For m < n, let k be the largest power of two smaller than n.  The subproof is then defined recursively.
 	if m < n {
-		k := largestPowerOf2SmallerThan(n)
+		k := SplitPoint(n)
 
 		if m <= k {
 			// If m <= k, the right subtree entries D[k:n] only exist in the current
@@ -132,16 +160,26 @@ func subProof(m uint64, D [][]byte, isKnown bool) (path [][sha256.Size]byte) {
 // a previously advertised hash MTH(D[0:m]) of the first m leaves, m <= n.
 // It returns the list of nodes in the Merkle Tree required to verify that the first m inputs D[0:m] are equal in both trees.
 // Merkle consistency proofs prove the append-only property of the tree.
+// It returns nil for m outside of (0, n], use TryProof to tell it apart from the empty proof for m = n.
 func Proof(m uint64, D [][]byte) [][sha256.Size]byte {
-	n := uint64(len(D))
-
-	if m < 0 || m > n {
+	proof, err := TryProof(m, D)
+	if err != nil {
 		return nil
 	}
+	return proof
+}
+
+// TryProof returns the consistency proof between MTH(D[0:m]) and MTH(D), like Proof.
+// A size m of 0 or greater than len(D) fails with ErrInvalidRange.
+func TryProof(m uint64, D [][]byte) ([][sha256.Size]byte, error) {
+	n := uint64(len(D))
+	if m == 0 || m > n {
+		return nil, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
 
 	// Given an ordered list of n inputs to the tree, D[n] = {d(0), ...,d(n-1)},
 	// the Merkle consistency proof PROOF(m, D[n]) for a previous Merkle Tree Hash MTH(D[0:m]),
 	// 0 < m < n, is defined as: PROOF(m, D[n]) = SUBPROOF(m, D[n], true)
 
-	return subProof(m, D, true)
+	return subProof(m, D, true), nil
 }
@@ -1,6 +1,7 @@
 package merkletree
 
 import (
+	"crypto/sha256"
 	"strconv"
 	"testing"
 
@@ -51,7 +52,7 @@ func Test_LargestPowerOf2SmallerThan(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		got := largestPowerOf2SmallerThan(test.input)
+		got := SplitPoint(test.input)
 		if test.output != got {
 			t.Errorf("%s failed. expected: %d, got: %d", test.name, test.output, got)
 		}
@@ -157,6 +158,44 @@ func TestProof(t *testing.T) {
 	assert.Len(t, path, 3)
 }
 
+func TestPathAndProofErrors(t *testing.T) {
+	D := makeEntries(7)
+	for _, m := range []uint64{7, 8} {
+		_, err := TryPath(m, D)
+		assert.ErrorIs(t, err, ErrIndexOutOfRange, "index %d", m)
+		assert.Empty(t, Path(m, D), "index %d", m)
+	}
+	_, err := TryPath(0, nil)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = TryPath(1, D[:1])
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	assert.Empty(t, Path(1, D[:1]))
+
+	// The path of the only leaf is legitimately empty
+	path, err := TryPath(0, D[:1])
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+	path, err = TryPath(6, D)
+	assert.NoError(t, err)
+	assert.Equal(t, Path(6, D), path)
+
+	for _, m := range []uint64{0, 8} {
+		_, err := TryProof(m, D)
+		assert.ErrorIs(t, err, ErrInvalidRange, "size %d", m)
+		assert.Nil(t, Proof(m, D), "size %d", m)
+	}
+	_, err = TryProof(0, nil)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	// The proof of a tree consistent with itself is legitimately empty
+	proof, err := TryProof(7, D)
+	assert.NoError(t, err)
+	assert.Empty(t, proof)
+	proof, err = TryProof(3, D)
+	assert.NoError(t, err)
+	assert.Equal(t, Proof(3, D), proof)
+}
+
 func makeRangeEntries(start, end int) (D [][]byte) {
 	for i := start; i < end; i++ {
 		v := "d" + strconv.FormatInt(int64(i), 10)
@@ -164,3 +203,32 @@ func makeRangeEntries(start, end int) (D [][]byte) {
 	}
 	return
 }
+
+func TestMTHRange(t *testing.T) {
+	D := makeEntries(10)
+	hashes := make([][sha256.Size]byte, 0, len(D))
+	for _, d := range D {
+		hashes = append(hashes, leafHash(d))
+	}
+
+	for start := 0; start <= len(D); start++ {
+		for end := start; end <= len(D); end++ {
+			expected := MTH(append([][]byte{}, D[start:end]...))
+
+			got, err := MTHRange(D, uint64(start), uint64(end))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, got, "range [%d, %d)", start, end)
+
+			got, err = MTHRangeFromLeafHashes(hashes, uint64(start), uint64(end))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, got, "range [%d, %d)", start, end)
+		}
+	}
+
+	_, err := MTHRange(D, 3, 2)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = MTHRange(D, 0, 11)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = MTHRangeFromLeafHashes(hashes, 11, 11)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -0,0 +1,184 @@
+package merklegrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree"
+	"google.golang.org/grpc"
+)
+
+// Client calls the MerkleTree service and converts responses to the types of
+// the merkletree package. The Verify methods check every proof locally and
+// never return data that does not verify.
+type Client struct {
+	rpc MerkleTreeClient
+}
+
+// NewClient returns a client using cc
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{rpc: NewMerkleTreeClient(cc)}
+}
+
+// Append adds entries to the tree and returns the new tree head
+func (c *Client) Append(ctx context.Context, entries ...[]byte) (merkletree.TreeHead, error) {
+	resp, err := c.rpc.Append(ctx, &AppendRequest{Entries: entries})
+	if err != nil {
+		return merkletree.TreeHead{}, err
+	}
+	return toTreeHead(resp.GetTreeSize(), resp.GetRootHash())
+}
+
+// AppendStreamClient streams batches of entries to the server
+type AppendStreamClient struct {
+	stream MerkleTree_AppendStreamClient
+}
+
+// AppendStream opens a client stream for appending batches of entries
+func (c *Client) AppendStream(ctx context.Context) (*AppendStreamClient, error) {
+	stream, err := c.rpc.AppendStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &AppendStreamClient{stream: stream}, nil
+}
+
+// Send sends a batch of entries
+func (s *AppendStreamClient) Send(entries ...[]byte) error {
+	return s.stream.Send(&AppendRequest{Entries: entries})
+}
+
+// CloseAndRecv closes the stream and returns the tree head after all batches were appended
+func (s *AppendStreamClient) CloseAndRecv() (merkletree.TreeHead, error) {
+	resp, err := s.stream.CloseAndRecv()
+	if err != nil {
+		return merkletree.TreeHead{}, err
+	}
+	return toTreeHead(resp.GetTreeSize(), resp.GetRootHash())
+}
+
+// GetRoot returns the current tree head
+func (c *Client) GetRoot(ctx context.Context) (merkletree.TreeHead, error) {
+	resp, err := c.rpc.GetRoot(ctx, &GetRootRequest{})
+	if err != nil {
+		return merkletree.TreeHead{}, err
+	}
+	return toTreeHead(resp.GetTreeSize(), resp.GetRootHash())
+}
+
+// GetInclusionProof returns the audit path of the leaf at index in the tree of size leaves
+func (c *Client) GetInclusionProof(ctx context.Context, index, size uint64) (merkletree.InclusionProof, error) {
+	return c.getInclusionProof(ctx, &InclusionProofRequest{Leaf: &InclusionProofRequest_LeafIndex{LeafIndex: index}, TreeSize: size})
+}
+
+// GetInclusionProofByHash returns the audit path of the leaf with the given leaf hash in the tree of size leaves
+func (c *Client) GetInclusionProofByHash(ctx context.Context, leafHash [sha256.Size]byte, size uint64) (merkletree.InclusionProof, error) {
+	return c.getInclusionProof(ctx, &InclusionProofRequest{Leaf: &InclusionProofRequest_LeafHash{LeafHash: leafHash[:]}, TreeSize: size})
+}
+
+func (c *Client) getInclusionProof(ctx context.Context, req *InclusionProofRequest) (merkletree.InclusionProof, error) {
+	resp, err := c.rpc.GetInclusionProof(ctx, req)
+	if err != nil {
+		return merkletree.InclusionProof{}, err
+	}
+
+	hashes, err := toHashes(resp.GetAuditPath())
+	if err != nil {
+		return merkletree.InclusionProof{}, err
+	}
+	return merkletree.InclusionProof{LeafIndex: resp.GetLeafIndex(), TreeSize: resp.GetTreeSize(), Hashes: hashes}, nil
+}
+
+// GetConsistencyProof returns the consistency proof between the trees of the first and second leaves
+func (c *Client) GetConsistencyProof(ctx context.Context, first, second uint64) (merkletree.ConsistencyProof, error) {
+	resp, err := c.rpc.GetConsistencyProof(ctx, &ConsistencyRequest{First: first, Second: second})
+	if err != nil {
+		return merkletree.ConsistencyProof{}, err
+	}
+
+	hashes, err := toHashes(resp.GetHashes())
+	if err != nil {
+		return merkletree.ConsistencyProof{}, err
+	}
+	return merkletree.ConsistencyProof{OldSize: resp.GetFirst(), NewSize: resp.GetSecond(), Hashes: hashes}, nil
+}
+
+// VerifyLeaf fetches the inclusion proof for leafHash at the tree head trusted
+// by w and verifies it against the trusted root.
+func (c *Client) VerifyLeaf(ctx context.Context, leafHash [sha256.Size]byte, w *merkletree.Witness) (merkletree.InclusionProof, error) {
+	head := w.TreeHead()
+	proof, err := c.GetInclusionProofByHash(ctx, leafHash, head.TreeSize)
+	if err != nil {
+		return merkletree.InclusionProof{}, err
+	}
+
+	if proof.TreeSize != head.TreeSize {
+		return merkletree.InclusionProof{}, fmt.Errorf("%w: proof for tree size %d, requested %d", merkletree.ErrUnverifiedResponse, proof.TreeSize, head.TreeSize)
+	}
+	if err := merkletree.VerifyInclusion(leafHash, head.RootHash, proof); err != nil {
+		return merkletree.InclusionProof{}, fmt.Errorf("%w: %v", merkletree.ErrUnverifiedResponse, err)
+	}
+	return proof, nil
+}
+
+// UpdateWitness fetches the current tree head and a consistency proof from the
+// tree head trusted by w, and advances w when the proof verifies.
+func (c *Client) UpdateWitness(ctx context.Context, w *merkletree.Witness) (merkletree.TreeHead, error) {
+	trusted := w.TreeHead()
+	head, err := c.GetRoot(ctx)
+	if err != nil {
+		return trusted, err
+	}
+	if head.TreeSize < trusted.TreeSize {
+		return trusted, fmt.Errorf("%w: tree size %d is smaller than trusted size %d", merkletree.ErrUnverifiedResponse, head.TreeSize, trusted.TreeSize)
+	}
+
+	proof, err := c.GetConsistencyProof(ctx, trusted.TreeSize, head.TreeSize)
+	if err != nil {
+		return trusted, err
+	}
+	if err := w.Update(head, proof); err != nil {
+		return trusted, fmt.Errorf("%w: %v", merkletree.ErrUnverifiedResponse, err)
+	}
+	return head, nil
+}
+
+var errInvalidHash = errors.New("merklegrpc: invalid hash length")
+
+func toHash(b []byte) ([sha256.Size]byte, error) {
+	var h [sha256.Size]byte
+	if len(b) != sha256.Size {
+		return h, errInvalidHash
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+func toHashes(bs [][]byte) ([][sha256.Size]byte, error) {
+	hashes := make([][sha256.Size]byte, len(bs))
+	for i, b := range bs {
+		var err error
+		if hashes[i], err = toHash(b); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+func fromHashes(hashes [][sha256.Size]byte) [][]byte {
+	bs := make([][]byte, len(hashes))
+	for i := range hashes {
+		bs[i] = append([]byte{}, hashes[i][:]...)
+	}
+	return bs
+}
+
+func toTreeHead(size uint64, root []byte) (merkletree.TreeHead, error) {
+	hash, err := toHash(root)
+	if err != nil {
+		return merkletree.TreeHead{}, err
+	}
+	return merkletree.TreeHead{TreeSize: size, RootHash: hash}, nil
+}
@@ -0,0 +1,304 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: merkletree.proto
+
+package merklegrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MerkleTree_Append_FullMethodName              = "/merkletree.v1.MerkleTree/Append"
+	MerkleTree_AppendStream_FullMethodName        = "/merkletree.v1.MerkleTree/AppendStream"
+	MerkleTree_GetRoot_FullMethodName             = "/merkletree.v1.MerkleTree/GetRoot"
+	MerkleTree_GetInclusionProof_FullMethodName   = "/merkletree.v1.MerkleTree/GetInclusionProof"
+	MerkleTree_GetConsistencyProof_FullMethodName = "/merkletree.v1.MerkleTree/GetConsistencyProof"
+)
+
+// MerkleTreeClient is the client API for MerkleTree service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MerkleTreeClient interface {
+	// Append adds entries to the tree and returns the new tree head.
+	Append(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendResponse, error)
+	// AppendStream appends the entries of every request in order and returns the
+	// tree head after the last one.
+	AppendStream(ctx context.Context, opts ...grpc.CallOption) (MerkleTree_AppendStreamClient, error)
+	// GetRoot returns the current tree head.
+	GetRoot(ctx context.Context, in *GetRootRequest, opts ...grpc.CallOption) (*GetRootResponse, error)
+	// GetInclusionProof returns the audit path of a leaf.
+	GetInclusionProof(ctx context.Context, in *InclusionProofRequest, opts ...grpc.CallOption) (*InclusionProofResponse, error)
+	// GetConsistencyProof returns the consistency proof between two tree sizes.
+	GetConsistencyProof(ctx context.Context, in *ConsistencyRequest, opts ...grpc.CallOption) (*ConsistencyResponse, error)
+}
+
+type merkleTreeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMerkleTreeClient(cc grpc.ClientConnInterface) MerkleTreeClient {
+	return &merkleTreeClient{cc}
+}
+
+func (c *merkleTreeClient) Append(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*AppendResponse, error) {
+	out := new(AppendResponse)
+	err := c.cc.Invoke(ctx, MerkleTree_Append_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleTreeClient) AppendStream(ctx context.Context, opts ...grpc.CallOption) (MerkleTree_AppendStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MerkleTree_ServiceDesc.Streams[0], MerkleTree_AppendStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &merkleTreeAppendStreamClient{stream}
+	return x, nil
+}
+
+type MerkleTree_AppendStreamClient interface {
+	Send(*AppendRequest) error
+	CloseAndRecv() (*AppendResponse, error)
+	grpc.ClientStream
+}
+
+type merkleTreeAppendStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *merkleTreeAppendStreamClient) Send(m *AppendRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *merkleTreeAppendStreamClient) CloseAndRecv() (*AppendResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AppendResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *merkleTreeClient) GetRoot(ctx context.Context, in *GetRootRequest, opts ...grpc.CallOption) (*GetRootResponse, error) {
+	out := new(GetRootResponse)
+	err := c.cc.Invoke(ctx, MerkleTree_GetRoot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleTreeClient) GetInclusionProof(ctx context.Context, in *InclusionProofRequest, opts ...grpc.CallOption) (*InclusionProofResponse, error) {
+	out := new(InclusionProofResponse)
+	err := c.cc.Invoke(ctx, MerkleTree_GetInclusionProof_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merkleTreeClient) GetConsistencyProof(ctx context.Context, in *ConsistencyRequest, opts ...grpc.CallOption) (*ConsistencyResponse, error) {
+	out := new(ConsistencyResponse)
+	err := c.cc.Invoke(ctx, MerkleTree_GetConsistencyProof_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MerkleTreeServer is the server API for MerkleTree service.
+// All implementations must embed UnimplementedMerkleTreeServer
+// for forward compatibility
+type MerkleTreeServer interface {
+	// Append adds entries to the tree and returns the new tree head.
+	Append(context.Context, *AppendRequest) (*AppendResponse, error)
+	// AppendStream appends the entries of every request in order and returns the
+	// tree head after the last one.
+	AppendStream(MerkleTree_AppendStreamServer) error
+	// GetRoot returns the current tree head.
+	GetRoot(context.Context, *GetRootRequest) (*GetRootResponse, error)
+	// GetInclusionProof returns the audit path of a leaf.
+	GetInclusionProof(context.Context, *InclusionProofRequest) (*InclusionProofResponse, error)
+	// GetConsistencyProof returns the consistency proof between two tree sizes.
+	GetConsistencyProof(context.Context, *ConsistencyRequest) (*ConsistencyResponse, error)
+	mustEmbedUnimplementedMerkleTreeServer()
+}
+
+// UnimplementedMerkleTreeServer must be embedded to have forward compatible implementations.
+type UnimplementedMerkleTreeServer struct {
+}
+
+func (UnimplementedMerkleTreeServer) Append(context.Context, *AppendRequest) (*AppendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Append not implemented")
+}
+func (UnimplementedMerkleTreeServer) AppendStream(MerkleTree_AppendStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method AppendStream not implemented")
+}
+func (UnimplementedMerkleTreeServer) GetRoot(context.Context, *GetRootRequest) (*GetRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoot not implemented")
+}
+func (UnimplementedMerkleTreeServer) GetInclusionProof(context.Context, *InclusionProofRequest) (*InclusionProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInclusionProof not implemented")
+}
+func (UnimplementedMerkleTreeServer) GetConsistencyProof(context.Context, *ConsistencyRequest) (*ConsistencyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsistencyProof not implemented")
+}
+func (UnimplementedMerkleTreeServer) mustEmbedUnimplementedMerkleTreeServer() {}
+
+// UnsafeMerkleTreeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MerkleTreeServer will
+// result in compilation errors.
+type UnsafeMerkleTreeServer interface {
+	mustEmbedUnimplementedMerkleTreeServer()
+}
+
+func RegisterMerkleTreeServer(s grpc.ServiceRegistrar, srv MerkleTreeServer) {
+	s.RegisterService(&MerkleTree_ServiceDesc, srv)
+}
+
+func _MerkleTree_Append_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleTreeServer).Append(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleTree_Append_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleTreeServer).Append(ctx, req.(*AppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleTree_AppendStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MerkleTreeServer).AppendStream(&merkleTreeAppendStreamServer{stream})
+}
+
+type MerkleTree_AppendStreamServer interface {
+	SendAndClose(*AppendResponse) error
+	Recv() (*AppendRequest, error)
+	grpc.ServerStream
+}
+
+type merkleTreeAppendStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *merkleTreeAppendStreamServer) SendAndClose(m *AppendResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *merkleTreeAppendStreamServer) Recv() (*AppendRequest, error) {
+	m := new(AppendRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _MerkleTree_GetRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleTreeServer).GetRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleTree_GetRoot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleTreeServer).GetRoot(ctx, req.(*GetRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleTree_GetInclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InclusionProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleTreeServer).GetInclusionProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleTree_GetInclusionProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleTreeServer).GetInclusionProof(ctx, req.(*InclusionProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerkleTree_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConsistencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerkleTreeServer).GetConsistencyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerkleTree_GetConsistencyProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerkleTreeServer).GetConsistencyProof(ctx, req.(*ConsistencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MerkleTree_ServiceDesc is the grpc.ServiceDesc for MerkleTree service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MerkleTree_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "merkletree.v1.MerkleTree",
+	HandlerType: (*MerkleTreeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Append",
+			Handler:    _MerkleTree_Append_Handler,
+		},
+		{
+			MethodName: "GetRoot",
+			Handler:    _MerkleTree_GetRoot_Handler,
+		},
+		{
+			MethodName: "GetInclusionProof",
+			Handler:    _MerkleTree_GetInclusionProof_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProof",
+			Handler:    _MerkleTree_GetConsistencyProof_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AppendStream",
+			Handler:       _MerkleTree_AppendStream_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "merkletree.proto",
+}
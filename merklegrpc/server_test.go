@@ -0,0 +1,135 @@
+package merklegrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func makeEntries(start, end int) (D [][]byte) {
+	for i := start; i < end; i++ {
+		D = append(D, []byte("d"+strconv.Itoa(i)))
+	}
+	return
+}
+
+func leafHash(d []byte) [sha256.Size]byte {
+	return sha256.Sum256(append([]byte{merkletree.LeafPrefix}, d...))
+}
+
+func startServer(t *testing.T, tree *merkletree.MerkleHashTree) *Client {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(NewServer(tree))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	D := makeEntries(0, 16)
+	client := startServer(t, merkletree.New(D[:7]))
+	ctx := context.Background()
+
+	head, err := client.GetRoot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, merkletree.TreeHead{TreeSize: 7, RootHash: merkletree.MTH(D[:7])}, head)
+	witness := merkletree.NewWitness(head)
+
+	proof, err := client.GetInclusionProof(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), proof.TreeSize)
+	assert.NoError(t, merkletree.VerifyInclusion(leafHash(D[0]), head.RootHash, proof))
+
+	head, err = client.Append(ctx, D[7:9]...)
+	assert.NoError(t, err)
+	assert.Equal(t, merkletree.TreeHead{TreeSize: 9, RootHash: merkletree.MTH(D[:9])}, head)
+
+	stream, err := client.AppendStream(ctx)
+	assert.NoError(t, err)
+	for _, d := range D[9:] {
+		assert.NoError(t, stream.Send(d))
+	}
+	head, err = stream.CloseAndRecv()
+	assert.NoError(t, err)
+	assert.Equal(t, merkletree.TreeHead{TreeSize: 16, RootHash: merkletree.MTH(D)}, head)
+
+	_, err = client.VerifyLeaf(ctx, leafHash(D[4]), witness)
+	assert.NoError(t, err)
+	head, err = client.UpdateWitness(ctx, witness)
+	assert.NoError(t, err)
+	assert.Equal(t, head, witness.TreeHead())
+
+	_, err = client.GetInclusionProofByHash(ctx, leafHash([]byte("missing")), 0)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetInclusionProof(ctx, 16, 0)
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+	_, err = client.GetConsistencyProof(ctx, 8, 17)
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
+
+func TestServerConcurrentAppendsAndProofs(t *testing.T) {
+	client := startServer(t, merkletree.New(makeEntries(0, 1)))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				_, err := client.Append(ctx, []byte("w"+strconv.Itoa(w)+"-"+strconv.Itoa(i)))
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				head, err := client.GetRoot(ctx)
+				assert.NoError(t, err)
+				proof, err := client.GetInclusionProofByHash(ctx, leafHash([]byte("d0")), head.TreeSize)
+				assert.NoError(t, err)
+				assert.NoError(t, merkletree.VerifyInclusion(leafHash([]byte("d0")), head.RootHash, proof))
+			}
+		}()
+	}
+	wg.Wait()
+
+	head, err := client.GetRoot(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), head.TreeSize)
+}
+
+func TestMessageEncoding(t *testing.T) {
+	req := &InclusionProofRequest{Leaf: &InclusionProofRequest_LeafIndex{LeafIndex: 0}, TreeSize: 7}
+	b, err := proto.Marshal(req)
+	assert.NoError(t, err)
+	decoded := new(InclusionProofRequest)
+	assert.NoError(t, proto.Unmarshal(b, decoded))
+	assert.True(t, proto.Equal(req, decoded))
+
+	// field numbers and wire types follow merkletree.proto
+	assert.NoError(t, proto.Unmarshal([]byte{0x08, 0x00, 0x18, 0x07}, decoded))
+	assert.True(t, proto.Equal(req, decoded))
+	assert.Error(t, proto.Unmarshal([]byte{0x0a, 0x05}, decoded))
+}
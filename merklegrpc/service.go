@@ -0,0 +1,124 @@
+package merklegrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/viveksyngh/merkletree"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative merkletree.proto
+
+// NewGRPCServer returns a grpc.Server serving srv
+func NewGRPCServer(srv MerkleTreeServer, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	RegisterMerkleTreeServer(s, srv)
+	return s
+}
+
+// Server implements MerkleTreeServer over a merkle hash tree. Appends are
+// serialized with respect to each other and to concurrent proof requests.
+type Server struct {
+	UnimplementedMerkleTreeServer
+
+	mu   sync.RWMutex
+	tree merkletree.Tree
+}
+
+// NewServer returns a server for tree. The tree must only be modified through the server.
+func NewServer(tree merkletree.Tree) *Server {
+	return &Server{tree: tree}
+}
+
+// Append adds the entries of req to the tree
+func (s *Server) Append(ctx context.Context, req *AppendRequest) (*AppendResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, err := s.tree.TryAppend(req.GetEntries()...)
+	switch {
+	case errors.Is(err, merkletree.ErrLogFull):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &AppendResponse{TreeSize: s.tree.Size(), RootHash: root[:]}, nil
+}
+
+// AppendStream appends the entries of every received request in order and
+// responds with the tree head after the last one.
+func (s *Server) AppendStream(stream MerkleTree_AppendStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			root, _ := s.GetRoot(stream.Context(), &GetRootRequest{})
+			return stream.SendAndClose(&AppendResponse{TreeSize: root.TreeSize, RootHash: root.RootHash})
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.Append(stream.Context(), req); err != nil {
+			return err
+		}
+	}
+}
+
+// GetRoot returns the current tree head
+func (s *Server) GetRoot(ctx context.Context, req *GetRootRequest) (*GetRootResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	head := s.tree.TreeHead()
+	return &GetRootResponse{TreeSize: head.TreeSize, RootHash: head.RootHash[:]}, nil
+}
+
+// GetInclusionProof returns the audit path of the requested leaf
+func (s *Server) GetInclusionProof(ctx context.Context, req *InclusionProofRequest) (*InclusionProofResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size := req.GetTreeSize()
+	if size == 0 {
+		size = s.tree.Size()
+	}
+
+	var index uint64
+	switch leaf := req.GetLeaf().(type) {
+	case *InclusionProofRequest_LeafIndex:
+		index = leaf.LeafIndex
+	case *InclusionProofRequest_LeafHash:
+		hash, err := toHash(leaf.LeafHash)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if index, err = s.tree.LeafIndex(hash); err != nil || index >= size {
+			return nil, status.Error(codes.NotFound, merkletree.ErrLeafNotFound.Error())
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "leaf_index or leaf_hash is required")
+	}
+
+	proof, err := s.tree.InclusionProofAtSize(index, size)
+	if err != nil {
+		return nil, status.Error(codes.OutOfRange, err.Error())
+	}
+	return &InclusionProofResponse{LeafIndex: proof.LeafIndex, TreeSize: proof.TreeSize, AuditPath: fromHashes(proof.Hashes)}, nil
+}
+
+// GetConsistencyProof returns the consistency proof between the requested tree sizes
+func (s *Server) GetConsistencyProof(ctx context.Context, req *ConsistencyRequest) (*ConsistencyResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	proof, err := s.tree.ConsistencyProof(req.GetFirst(), req.GetSecond())
+	if err != nil {
+		return nil, status.Error(codes.OutOfRange, err.Error())
+	}
+	return &ConsistencyResponse{First: req.GetFirst(), Second: req.GetSecond(), Hashes: fromHashes(proof.Hashes)}, nil
+}
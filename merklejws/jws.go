@@ -0,0 +1,243 @@
+// Package merklejws embeds merkle inclusion proofs in the claims of JSON Web Tokens,
+// signed as compact JWS with ES256 or EdDSA, so that proofs can be passed around
+// by stacks that already exchange JWTs.
+//
+// The claims of a token are:
+//
+//	tree_size   the size of the tree the proof is for
+//	leaf_index  the index of the leaf
+//	leaf_hash   the leaf hash, base64url without padding
+//	root        the merkle root of the tree, base64url without padding
+//	proof       the audit path from the leaf towards the root, base64url without padding
+//
+// Other claims, such as iat or exp, are ignored.
+package merklejws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/viveksyngh/merkletree"
+)
+
+// Errors returned when signing and verifying tokens
+var (
+	ErrUnsupportedKey   = errors.New("merklejws: unsupported key, want an ECDSA P-256 or Ed25519 key")
+	ErrMalformedToken   = errors.New("merklejws: malformed token")
+	ErrInvalidSignature = errors.New("merklejws: invalid token signature")
+	ErrLeafMismatch     = errors.New("merklejws: token is for another leaf")
+)
+
+// Signature algorithms
+const (
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Claims are the claims of a token carrying an inclusion proof
+type Claims struct {
+	TreeSize  uint64   `json:"tree_size"`
+	LeafIndex uint64   `json:"leaf_index"`
+	LeafHash  string   `json:"leaf_hash"`
+	Root      string   `json:"root"`
+	Proof     []string `json:"proof"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// NewClaims returns the claims of the inclusion proof p of leafHash in the tree with the given root
+func NewClaims(leafHash, root [sha256.Size]byte, p merkletree.InclusionProof) Claims {
+	c := Claims{
+		TreeSize:  p.TreeSize,
+		LeafIndex: p.LeafIndex,
+		LeafHash:  encodeHash(leafHash),
+		Root:      encodeHash(root),
+		Proof:     make([]string, 0, len(p.Hashes)),
+	}
+	for _, h := range p.Hashes {
+		c.Proof = append(c.Proof, encodeHash(h))
+	}
+	return c
+}
+
+// InclusionProof decodes the leaf hash, root and inclusion proof of the claims
+func (c Claims) InclusionProof() (leafHash, root [sha256.Size]byte, p merkletree.InclusionProof, err error) {
+	if err = decodeHash(c.LeafHash, &leafHash); err != nil {
+		return
+	}
+	if err = decodeHash(c.Root, &root); err != nil {
+		return
+	}
+	p = merkletree.InclusionProof{LeafIndex: c.LeafIndex, TreeSize: c.TreeSize, Hashes: make([][sha256.Size]byte, len(c.Proof))}
+	for i, s := range c.Proof {
+		if err = decodeHash(s, &p.Hashes[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Sign returns the compact JWS of the claims signed by signer, using ES256 for ECDSA
+// P-256 keys and EdDSA for Ed25519 keys.
+func Sign(c Claims, signer crypto.Signer) (string, error) {
+	alg, err := algorithm(signer.Public())
+	if err != nil {
+		return "", err
+	}
+
+	h, err := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch alg {
+	case ES256:
+		digest := sha256.Sum256([]byte(input))
+		der, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+		if sig, err = rawECDSASignature(der); err != nil {
+			return "", err
+		}
+	case EdDSA:
+		if sig, err = signer.Sign(rand.Reader, []byte(input), crypto.Hash(0)); err != nil {
+			return "", err
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// ParseAndVerify checks the signature of token with the public key pub, and that the
+// inclusion proof it carries is a proof of leafHash for the root it claims. The root
+// is only as trustworthy as the signer: callers still have to compare it with a
+// trusted tree head of the log.
+func ParseAndVerify(token string, pub crypto.PublicKey, leafHash [sha256.Size]byte) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: want 3 parts, got %d", ErrMalformedToken, len(parts))
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, err
+	}
+	alg, err := algorithm(pub)
+	if err != nil {
+		return Claims{}, err
+	}
+	// The algorithm is fixed by the key, so a token cannot pick a weaker one.
+	if h.Alg != alg {
+		return Claims{}, fmt.Errorf("%w: algorithm %q, key requires %q", ErrInvalidSignature, h.Alg, alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: signature: %v", ErrMalformedToken, err)
+	}
+	if !verifySignature(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrInvalidSignature
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Claims{}, err
+	}
+	claimedLeaf, root, p, err := c.InclusionProof()
+	if err != nil {
+		return Claims{}, err
+	}
+	if claimedLeaf != leafHash {
+		return Claims{}, ErrLeafMismatch
+	}
+	if err := merkletree.VerifyInclusion(leafHash, root, p); err != nil {
+		return Claims{}, err
+	}
+	return c, nil
+}
+
+func algorithm(pub crypto.PublicKey) (string, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return ES256, nil
+		}
+	case ed25519.PublicKey:
+		return EdDSA, nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+func verifySignature(pub crypto.PublicKey, input, sig []byte) bool {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, input, sig)
+	}
+	return false
+}
+
+// rawECDSASignature converts an ASN.1 ECDSA P-256 signature to the fixed size r || s
+// encoding of JWS.
+func rawECDSASignature(der []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return nil
+}
+
+func encodeHash(h [sha256.Size]byte) string {
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+func decodeHash(s string, h *[sha256.Size]byte) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("%w: invalid hash %q", ErrMalformedToken, s)
+	}
+	copy(h[:], b)
+	return nil
+}
@@ -0,0 +1,127 @@
+package merklejws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+	"github.com/viveksyngh/merkletree/merkletest"
+)
+
+func signers(t *testing.T) []crypto.Signer {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return []crypto.Signer{ecKey, edKey}
+}
+
+func proofClaims(t *testing.T, index uint64) ([]byte, Claims) {
+	D := merkletest.MakeEntries(11)
+	tree := merkletree.New(D)
+	p, err := tree.InclusionProofByIndex(index)
+	assert.NoError(t, err)
+	leaf, err := tree.LeafHash(index)
+	assert.NoError(t, err)
+	return D[index], NewClaims(leaf, tree.MerkleRoot(), p)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, signer := range signers(t) {
+		for _, index := range []uint64{0, 4, 10} {
+			_, claims := proofClaims(t, index)
+			token, err := Sign(claims, signer)
+			assert.NoError(t, err)
+
+			leaf, _, _, err := claims.InclusionProof()
+			assert.NoError(t, err)
+			parsed, err := ParseAndVerify(token, signer.Public(), leaf)
+			assert.NoError(t, err)
+			assert.Equal(t, claims, parsed)
+
+			_, err = ParseAndVerify(token, signer.Public(), sha256.Sum256(nil))
+			assert.ErrorIs(t, err, ErrLeafMismatch)
+		}
+	}
+}
+
+func TestParseAndVerifyRejects(t *testing.T) {
+	keys := signers(t)
+	ecKey, edKey := keys[0], keys[1]
+	_, claims := proofClaims(t, 3)
+	leaf, _, _, err := claims.InclusionProof()
+	assert.NoError(t, err)
+	token, err := Sign(claims, ecKey)
+	assert.NoError(t, err)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, err = ParseAndVerify(token, other.Public(), leaf)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// The algorithm of the header must be the one of the key
+	_, err = ParseAndVerify(token, edKey.Public(), leaf)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	parts := strings.Split(token, ".")
+	_, err = ParseAndVerify(parts[0]+"."+parts[1]+".", ecKey.Public(), leaf)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = ParseAndVerify(parts[0]+"."+parts[1], ecKey.Public(), leaf)
+	assert.ErrorIs(t, err, ErrMalformedToken)
+
+	// A signed token with a proof that does not verify is rejected
+	claims.Proof[0] = claims.Proof[1]
+	token, err = Sign(claims, ecKey)
+	assert.NoError(t, err)
+	_, err = ParseAndVerify(token, ecKey.Public(), leaf)
+	assert.ErrorIs(t, err, merkletree.ErrRootMismatch)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	_, err = Sign(claims, p384)
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func TestInteropWithJWTLibrary(t *testing.T) {
+	keys := signers(t)
+	methods := []jwt.SigningMethod{jwt.SigningMethodES256, jwt.SigningMethodEdDSA}
+	for i, signer := range keys {
+		_, claims := proofClaims(t, 7)
+		leaf, _, _, err := claims.InclusionProof()
+		assert.NoError(t, err)
+
+		// A token minted by the library with the documented claim names
+		minted, err := jwt.NewWithClaims(methods[i], jwt.MapClaims{
+			"tree_size":  claims.TreeSize,
+			"leaf_index": claims.LeafIndex,
+			"leaf_hash":  claims.LeafHash,
+			"root":       claims.Root,
+			"proof":      claims.Proof,
+			"iat":        1700000000,
+		}).SignedString(signer)
+		assert.NoError(t, err)
+		parsed, err := ParseAndVerify(minted, signer.Public(), leaf)
+		assert.NoError(t, err, "minted by the library with %s", methods[i].Alg())
+		assert.Equal(t, claims, parsed)
+
+		// A token signed here parses with the library
+		token, err := Sign(claims, signer)
+		assert.NoError(t, err)
+		decoded, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+			return signer.Public(), nil
+		}, jwt.WithValidMethods([]string{methods[i].Alg()}))
+		assert.NoError(t, err)
+		mapClaims := decoded.Claims.(jwt.MapClaims)
+		assert.Equal(t, claims.Root, mapClaims["root"])
+		assert.Equal(t, fmt.Sprint(claims.TreeSize), fmt.Sprint(mapClaims["tree_size"]))
+	}
+}
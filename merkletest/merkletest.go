@@ -0,0 +1,71 @@
+// Package merkletest provides entry generators, proof assertions and a stateful
+// test harness for code using merkle hash trees.
+package merkletest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/viveksyngh/merkletree"
+)
+
+// MakeEntries returns the n entries d0, d1, ..., d(n-1)
+func MakeEntries(n int) [][]byte {
+	return MakeRangeEntries(0, n)
+}
+
+// MakeRangeEntries returns the entries d(start) through d(end-1)
+func MakeRangeEntries(start, end int) [][]byte {
+	entries := make([][]byte, 0, end-start)
+	for i := start; i < end; i++ {
+		entries = append(entries, []byte(fmt.Sprintf("d%d", i)))
+	}
+	return entries
+}
+
+// MakeRandomEntries returns n entries of up to 64 random bytes, deterministically derived from seed
+func MakeRandomEntries(seed int64, n int) [][]byte {
+	r := rand.New(rand.NewSource(seed))
+	entries := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		e := make([]byte, r.Intn(65))
+		r.Read(e)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// RequireInclusionVerifies fails the test unless the inclusion proof of the leaf
+// at index verifies against the current root of tree.
+func RequireInclusionVerifies(t testing.TB, tree *merkletree.MerkleHashTree, index uint64) {
+	t.Helper()
+
+	head := tree.TreeHead()
+	leaf, err := tree.LeafHash(index)
+	if err != nil {
+		t.Fatalf("leaf %d: %v", index, err)
+	}
+	proof, err := tree.InclusionProofAtSize(index, head.TreeSize)
+	if err != nil {
+		t.Fatalf("inclusion proof of leaf %d at size %d: %v", index, head.TreeSize, err)
+	}
+	if err := merkletree.VerifyInclusion(leaf, head.RootHash, proof); err != nil {
+		t.Fatalf("inclusion proof of leaf %d at size %d does not verify: %v", index, head.TreeSize, err)
+	}
+}
+
+// RequireConsistencyVerifies fails the test unless the consistency proof served
+// by newTree verifies that it is an append-only extension of oldTree.
+func RequireConsistencyVerifies(t testing.TB, oldTree, newTree merkletree.Tree) {
+	t.Helper()
+
+	oldHead, newHead := oldTree.TreeHead(), newTree.TreeHead()
+	proof, err := newTree.ConsistencyProof(oldHead.TreeSize, newHead.TreeSize)
+	if err != nil {
+		t.Fatalf("consistency proof between sizes %d and %d: %v", oldHead.TreeSize, newHead.TreeSize, err)
+	}
+	if err := merkletree.VerifyConsistency(oldHead.RootHash, newHead.RootHash, proof); err != nil {
+		t.Fatalf("consistency proof between sizes %d and %d does not verify: %v", oldHead.TreeSize, newHead.TreeSize, err)
+	}
+}
@@ -0,0 +1,33 @@
+package merkletest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/viveksyngh/merkletree"
+)
+
+func TestMakeEntries(t *testing.T) {
+	assert.Equal(t, [][]byte{[]byte("d0"), []byte("d1"), []byte("d2")}, MakeEntries(3))
+	assert.Equal(t, MakeRandomEntries(7, 10), MakeRandomEntries(7, 10))
+	assert.NotEqual(t, MakeRandomEntries(7, 10), MakeRandomEntries(8, 10))
+}
+
+func TestRunStatefulTestScripted(t *testing.T) {
+	D := MakeEntries(12)
+	RunStatefulTest(t, []Op{
+		{Kind: Append, Entries: D[:5]},
+		{Kind: SetLeaf, Index: 2, Entries: [][]byte{[]byte("x")}},
+		{Kind: Append, Entries: D[5:9]},
+		{Kind: Truncate, Size: 6},
+		{Kind: Append, Entries: D[9:]},
+		{Kind: Truncate, Size: 0},
+		{Kind: Append, Entries: D[:1]},
+	})
+}
+
+func TestRunStatefulTestSeeded(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		RunStatefulTest(t, RandomOps(seed, 40), merkletree.WithProofCache(64))
+	}
+}
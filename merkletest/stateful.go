@@ -0,0 +1,114 @@
+package merkletest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/viveksyngh/merkletree"
+)
+
+// OpKind is the kind of mutation performed by an Op
+type OpKind int
+
+// Kinds of Op
+const (
+	// Append appends Entries
+	Append OpKind = iota
+	// Truncate truncates the tree to Size leaves
+	Truncate
+	// SetLeaf replaces the leaf at Index with Entries[0]
+	SetLeaf
+)
+
+// Op is a single mutation of a tree performed by RunStatefulTest
+type Op struct {
+	Kind    OpKind
+	Entries [][]byte
+	Size    uint64
+	Index   uint64
+}
+
+func (op Op) String() string {
+	switch op.Kind {
+	case Append:
+		return fmt.Sprintf("Append(%d entries)", len(op.Entries))
+	case Truncate:
+		return fmt.Sprintf("Truncate(%d)", op.Size)
+	case SetLeaf:
+		return fmt.Sprintf("SetLeaf(%d)", op.Index)
+	}
+	return fmt.Sprintf("Op(%d)", op.Kind)
+}
+
+// RandomOps returns n valid operations deterministically derived from seed,
+// mostly appends with occasional truncations and leaf replacements.
+func RandomOps(seed int64, n int) []Op {
+	r := rand.New(rand.NewSource(seed))
+	ops := make([]Op, 0, n)
+	size := uint64(0)
+	for i := 0; i < n; i++ {
+		switch k := r.Intn(10); {
+		case k == 0 && size > 0:
+			op := Op{Kind: Truncate, Size: uint64(r.Int63n(int64(size) + 1))}
+			size = op.Size
+			ops = append(ops, op)
+		case k == 1 && size > 0:
+			ops = append(ops, Op{Kind: SetLeaf, Index: uint64(r.Int63n(int64(size))), Entries: MakeRandomEntries(r.Int63(), 1)})
+		default:
+			op := Op{Kind: Append, Entries: MakeRandomEntries(r.Int63(), r.Intn(20))}
+			size += uint64(len(op.Entries))
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// RunStatefulTest performs ops on a new tree created with opts, and after every
+// operation checks the tree against the root computed with merkletree.MTH from a
+// reference list of entries. Inclusion proofs of all leaves are verified, as well
+// as consistency with the previous tree head after appends.
+func RunStatefulTest(t testing.TB, ops []Op, opts ...merkletree.Option) {
+	t.Helper()
+
+	tree := merkletree.New(nil, opts...)
+	var entries [][]byte
+	for step, op := range ops {
+		old := merkletree.New(entries)
+
+		var err error
+		switch op.Kind {
+		case Append:
+			_, err = tree.TryAppend(op.Entries...)
+			entries = append(entries, op.Entries...)
+		case Truncate:
+			_, err = tree.Truncate(op.Size)
+			if op.Size <= uint64(len(entries)) {
+				entries = entries[:op.Size]
+			}
+		case SetLeaf:
+			_, err = tree.SetLeaf(op.Index, op.Entries[0])
+			if op.Index < uint64(len(entries)) {
+				entries[op.Index] = op.Entries[0]
+			}
+		default:
+			t.Fatalf("step %d: unknown %v", step, op)
+		}
+		if err != nil {
+			t.Fatalf("step %d: %v: %v", step, op, err)
+		}
+
+		if size := tree.Size(); size != uint64(len(entries)) {
+			t.Fatalf("step %d: %v: size %d, expected %d", step, op, size, len(entries))
+		}
+		if root, expected := tree.MerkleRoot(), merkletree.MTH(entries); root != expected {
+			t.Fatalf("step %d: %v: root %x, expected %x", step, op, root, expected)
+		}
+		for i := range entries {
+			RequireInclusionVerifies(t, tree, uint64(i))
+		}
+		if op.Kind == Append {
+			RequireConsistencyVerifies(t, old, tree)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package merkletree_test
+
+import (
+	"testing"
+
+	"github.com/viveksyngh/merkletree"
+	"github.com/viveksyngh/merkletree/merkletest"
+)
+
+func TestStatefulRandomOperations(t *testing.T) {
+	for seed := int64(100); seed < 110; seed++ {
+		merkletest.RunStatefulTest(t, merkletest.RandomOps(seed, 30), merkletree.WithLocking())
+	}
+}
+
+func TestAppendedTreesStayConsistent(t *testing.T) {
+	D := merkletest.MakeRandomEntries(1, 33)
+	tree := merkletree.New(nil)
+	for i := range D {
+		old := merkletree.New(D[:i])
+		tree.Append(D[i])
+		merkletest.RequireConsistencyVerifies(t, old, tree)
+		merkletest.RequireInclusionVerifies(t, tree, uint64(i))
+	}
+}
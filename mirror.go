@@ -0,0 +1,240 @@
+package merkletree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// defaultMirrorBatchSize is the number of entries a Mirror requests at a time
+const defaultMirrorBatchSize = 256
+
+// MirrorError reports a part of a mirrored log that does not verify: the entries
+// [Start, End) fetched from the log, or the tree heads of sizes Start and End
+type MirrorError struct {
+	Start uint64
+	End   uint64
+	Err   error
+}
+
+func (e *MirrorError) Error() string {
+	return fmt.Sprintf("merkletree: mirror [%d, %d): %v", e.Start, e.End, e.Err)
+}
+
+// Unwrap returns the verification error
+func (e *MirrorError) Unwrap() error {
+	return e.Err
+}
+
+// MirrorState is the progress of a Mirror: the number of entries it mirrored and
+// the latest tree head of the log it verified
+type MirrorState struct {
+	Next uint64
+	Head TreeHead
+}
+
+// MirrorOption configures a Mirror
+type MirrorOption func(*Mirror)
+
+// WithMirrorBatchSize requests n entries at a time
+func WithMirrorBatchSize(n uint64) MirrorOption {
+	return func(m *Mirror) {
+		m.batchSize = n
+	}
+}
+
+// WithMirrorRateLimit calls wait before every request to the log, which fails the
+// sync when wait returns an error, e.g. the Wait method of a rate limiter
+func WithMirrorRateLimit(wait func(ctx context.Context) error) MirrorOption {
+	return func(m *Mirror) {
+		m.wait = wait
+	}
+}
+
+// WithMirrorProgress calls save with the state of the mirror whenever it verified a
+// new tree head or new entries, so the mirror can be resumed WithMirrorResume.
+// The sync fails when save returns an error.
+func WithMirrorProgress(save func(MirrorState) error) MirrorOption {
+	return func(m *Mirror) {
+		m.save = save
+	}
+}
+
+// WithMirrorResume resumes mirroring from state, saved WithMirrorProgress, into a
+// tree holding the state.Next entries mirrored before
+func WithMirrorResume(state MirrorState) MirrorOption {
+	return func(m *Mirror) {
+		m.state = state
+	}
+}
+
+// Mirror keeps a verified local copy of a log served by NewHandler, or of a
+// Certificate Transparency log through such a server. Sync fetches the tree head
+// of the log, verifies it is consistent with the last one, and appends the new
+// entries to a local tree, a batch at a time. A batch is only kept once the local
+// root over it is proven consistent with the tree head, so the local tree always
+// is a verified prefix of the log. A Mirror is not safe for concurrent use.
+type Mirror struct {
+	client    *LogClient
+	tree      *MerkleHashTree
+	state     MirrorState
+	batchSize uint64
+	wait      func(ctx context.Context) error
+	save      func(MirrorState) error
+}
+
+// NewMirror returns a mirror of the log client talks to, appending to tree
+func NewMirror(client *LogClient, tree *MerkleHashTree, opts ...MirrorOption) (*Mirror, error) {
+	m := &Mirror{client: client, tree: tree, batchSize: defaultMirrorBatchSize}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.batchSize == 0 {
+		m.batchSize = 1
+	}
+
+	head := tree.TreeHead()
+	if head.TreeSize != m.state.Next {
+		return nil, fmt.Errorf("%w: tree of size %d, resuming from %d", ErrInvalidRange, head.TreeSize, m.state.Next)
+	}
+	if m.state.Next > m.state.Head.TreeSize {
+		return nil, fmt.Errorf("%w: resuming from %d beyond the tree head of size %d", ErrInvalidRange, m.state.Next, m.state.Head.TreeSize)
+	}
+	if m.state.Next > 0 && m.state.Next == m.state.Head.TreeSize && head.RootHash != m.state.Head.RootHash {
+		return nil, fmt.Errorf("%w: tree does not match the resumed tree head", ErrRootMismatch)
+	}
+	return m, nil
+}
+
+// State returns the progress of the mirror
+func (m *Mirror) State() MirrorState {
+	return m.state
+}
+
+// Sync mirrors the log up to its current tree head and returns it. A tree head or
+// batch of entries that does not verify fails with a *MirrorError, leaving the
+// local tree at the last verified entry.
+func (m *Mirror) Sync(ctx context.Context) (TreeHead, error) {
+	if err := m.limit(ctx); err != nil {
+		return m.state.Head, err
+	}
+	head, err := m.client.GetRoot(ctx)
+	if err != nil {
+		return m.state.Head, err
+	}
+	if err := m.advance(ctx, head); err != nil {
+		return m.state.Head, err
+	}
+
+	for m.state.Next < head.TreeSize {
+		if err := m.fetch(ctx, head); err != nil {
+			return head, err
+		}
+	}
+	return head, nil
+}
+
+// advance verifies that head is consistent with the trusted tree head and trusts it
+func (m *Mirror) advance(ctx context.Context, head TreeHead) error {
+	trusted := m.state.Head
+	switch {
+	case head.TreeSize < trusted.TreeSize:
+		return &MirrorError{Start: head.TreeSize, End: trusted.TreeSize, Err: fmt.Errorf("%w: size %d, trusted size %d", ErrRollback, head.TreeSize, trusted.TreeSize)}
+	case head.TreeSize == trusted.TreeSize:
+		if head.RootHash != trusted.RootHash {
+			return &MirrorError{Start: head.TreeSize, End: head.TreeSize, Err: fmt.Errorf("%w: size %d", ErrFork, head.TreeSize)}
+		}
+		return nil
+	case trusted.TreeSize > 0:
+		if err := m.limit(ctx); err != nil {
+			return err
+		}
+		proof, err := m.client.GetConsistency(ctx, trusted.TreeSize, head.TreeSize)
+		if err != nil {
+			return err
+		}
+		if err := VerifyConsistency(trusted.RootHash, head.RootHash, proof); err != nil {
+			return &MirrorError{Start: trusted.TreeSize, End: head.TreeSize, Err: fmt.Errorf("%w: %v", ErrUnverifiedResponse, err)}
+		}
+	}
+
+	m.state.Head = head
+	return m.progress()
+}
+
+// fetch appends the next batch of entries up to head and verifies the local root
+// over them against head
+func (m *Mirror) fetch(ctx context.Context, head TreeHead) error {
+	start := m.state.Next
+	end := start + m.batchSize
+	if end > head.TreeSize {
+		end = head.TreeSize
+	}
+	if err := m.limit(ctx); err != nil {
+		return err
+	}
+	entries, err := m.client.GetEntries(ctx, start, end-1)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return &MirrorError{Start: start, End: end, Err: fmt.Errorf("%w: no entries", ErrUnverifiedResponse)}
+	}
+	end = start + uint64(len(entries))
+
+	if _, err := m.tree.TryAppend(entries...); err != nil {
+		return err
+	}
+	if err := m.verifyPrefix(ctx, end, head); err != nil {
+		if _, terr := m.tree.Truncate(start); terr != nil {
+			return terr
+		}
+		var mirrorErr *MirrorError
+		if !errors.As(err, &mirrorErr) {
+			return err
+		}
+		mirrorErr.Start, mirrorErr.End = start, end
+		return mirrorErr
+	}
+
+	m.state.Next = end
+	return m.progress()
+}
+
+// verifyPrefix verifies that the first size leaves of the local tree are those of
+// the log at head
+func (m *Mirror) verifyPrefix(ctx context.Context, size uint64, head TreeHead) error {
+	root := m.tree.MerkleRoot()
+	if size == head.TreeSize {
+		if root != head.RootHash {
+			return &MirrorError{Err: fmt.Errorf("%w: local root %x, tree head root %x", ErrRootMismatch, root, head.RootHash)}
+		}
+		return nil
+	}
+
+	if err := m.limit(ctx); err != nil {
+		return err
+	}
+	proof, err := m.client.GetConsistency(ctx, size, head.TreeSize)
+	if err != nil {
+		return err
+	}
+	if err := VerifyConsistency(root, head.RootHash, proof); err != nil {
+		return &MirrorError{Err: fmt.Errorf("%w: %v", ErrRootMismatch, err)}
+	}
+	return nil
+}
+
+func (m *Mirror) limit(ctx context.Context) error {
+	if m.wait == nil {
+		return ctx.Err()
+	}
+	return m.wait(ctx)
+}
+
+func (m *Mirror) progress() error {
+	if m.save == nil {
+		return nil
+	}
+	return m.save(m.state)
+}
@@ -0,0 +1,123 @@
+package merkletree
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEntryLog returns a tree serving its entries through NewHandler
+func newEntryLog(D [][]byte) *MerkleHashTree {
+	tree := New(nil)
+	for _, d := range D {
+		tree.AppendWithExtra(d, nil)
+	}
+	return tree
+}
+
+func TestMirrorSync(t *testing.T) {
+	D := makeEntries(40)
+	log := newEntryLog(D[:23])
+	server := httptest.NewServer(NewHandler(log))
+	defer server.Close()
+
+	ctx := context.Background()
+	var saved []MirrorState
+	waits := 0
+	local := New(nil)
+	mirror, err := NewMirror(NewLogClient(server.URL), local,
+		WithMirrorBatchSize(5),
+		WithMirrorRateLimit(func(ctx context.Context) error { waits++; return nil }),
+		WithMirrorProgress(func(s MirrorState) error { saved = append(saved, s); return nil }))
+	assert.NoError(t, err)
+
+	head, err := mirror.Sync(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeHead{TreeSize: 23, RootHash: MTH(D[:23])}, head)
+	assert.Equal(t, head, local.TreeHead())
+	assert.Equal(t, MirrorState{Next: 23, Head: head}, mirror.State())
+	// The tree head, then five batches of entries
+	assert.Len(t, saved, 6)
+	assert.Equal(t, uint64(5), saved[1].Next)
+	// The root, then entries and a consistency proof per batch but the last
+	assert.Equal(t, 1+5+4, waits)
+
+	// The mirror resumes from the saved state into the same tree
+	for _, d := range D[23:] {
+		log.AppendWithExtra(d, nil)
+	}
+	mirror, err = NewMirror(NewLogClient(server.URL), local, WithMirrorBatchSize(8), WithMirrorResume(saved[len(saved)-1]))
+	assert.NoError(t, err)
+	head, err = mirror.Sync(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeHead{TreeSize: 40, RootHash: MTH(D)}, head)
+	assert.Equal(t, head, local.TreeHead())
+
+	_, err = NewMirror(NewLogClient(server.URL), local, WithMirrorResume(saved[2]))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = NewMirror(NewLogClient(server.URL), New(nil), WithMirrorResume(MirrorState{Head: head}))
+	assert.NoError(t, err)
+}
+
+func TestMirrorTamperedEntries(t *testing.T) {
+	D := makeEntries(20)
+	forged := append([][]byte(nil), D...)
+	forged[12] = []byte("forged")
+
+	// The log serves honest tree heads and proofs but a forged entry
+	mux := http.NewServeMux()
+	mux.Handle("/", NewHandler(newEntryLog(D)))
+	mux.Handle(EntriesPath, NewHandler(newEntryLog(forged)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	local := New(nil)
+	mirror, err := NewMirror(NewLogClient(server.URL), local, WithMirrorBatchSize(5))
+	assert.NoError(t, err)
+	_, err = mirror.Sync(context.Background())
+	var mirrorErr *MirrorError
+	assert.True(t, errors.As(err, &mirrorErr))
+	assert.Equal(t, uint64(10), mirrorErr.Start)
+	assert.Equal(t, uint64(15), mirrorErr.End)
+	assert.ErrorIs(t, err, ErrRootMismatch)
+	assert.Equal(t, TreeHead{TreeSize: 10, RootHash: MTH(D[:10])}, local.TreeHead())
+	assert.Equal(t, uint64(10), mirror.State().Next)
+}
+
+func TestMirrorInconsistentTreeHead(t *testing.T) {
+	D := makeEntries(12)
+	log := newEntryLog(D[:8])
+	server := httptest.NewServer(NewHandler(log))
+	defer server.Close()
+
+	local := New(nil)
+	mirror, err := NewMirror(NewLogClient(server.URL), local)
+	assert.NoError(t, err)
+	_, err = mirror.Sync(context.Background())
+	assert.NoError(t, err)
+
+	// The log rewrites an entry it served before
+	log.SetLeaf(3, []byte("rewritten"))
+	log.AppendWithExtra(D[8], nil)
+	_, err = mirror.Sync(context.Background())
+	var mirrorErr *MirrorError
+	assert.True(t, errors.As(err, &mirrorErr))
+	assert.Equal(t, uint64(8), mirrorErr.Start)
+	assert.Equal(t, uint64(9), mirrorErr.End)
+	assert.ErrorIs(t, err, ErrUnverifiedResponse)
+	assert.Equal(t, uint64(8), local.Size())
+
+	log.Truncate(5)
+	_, err = mirror.Sync(context.Background())
+	assert.ErrorIs(t, err, ErrRollback)
+
+	limited, err := NewMirror(NewLogClient(server.URL), New(nil),
+		WithMirrorRateLimit(func(ctx context.Context) error { return context.DeadlineExceeded }))
+	assert.NoError(t, err)
+	_, err = limited.Sync(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,67 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MultihashSHA256 is the multicodec code of sha2-256
+const MultihashSHA256 = 0x12
+
+// ErrInvalidMultihash is returned when parsing a malformed or unsupported multihash
+var ErrInvalidMultihash = errors.New("merkletree: invalid multihash")
+
+// RootMultihash returns the merkle root of the tree encoded as a multihash:
+// the varint hash function code, the varint digest length and the digest. The code
+// is the one of the Hasher of the tree.
+func (m *MerkleHashTree) RootMultihash() []byte {
+	root := m.MerkleRoot()
+	return encodeMultihash(m.Hasher().code, root[:])
+}
+
+func encodeMultihash(code uint64, digest []byte) []byte {
+	mh := make([]byte, 0, 2*binary.MaxVarintLen64+len(digest))
+	mh = binary.AppendUvarint(mh, code)
+	mh = binary.AppendUvarint(mh, uint64(len(digest)))
+	return append(mh, digest...)
+}
+
+// decodeMultihash returns the code and digest of the multihash mh
+func decodeMultihash(mh []byte) (uint64, []byte, error) {
+	code, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("%w: bad code", ErrInvalidMultihash)
+	}
+	mh = mh[n:]
+
+	length, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("%w: bad length", ErrInvalidMultihash)
+	}
+	mh = mh[n:]
+	if uint64(len(mh)) != length {
+		return 0, nil, fmt.Errorf("%w: digest of %d bytes with length %d", ErrInvalidMultihash, len(mh), length)
+	}
+	return code, mh, nil
+}
+
+// MultihashToRoot returns the merkle root encoded in the multihash mh
+func MultihashToRoot(mh []byte) ([sha256.Size]byte, error) {
+	var root [sha256.Size]byte
+
+	code, digest, err := decodeMultihash(mh)
+	if err != nil {
+		return root, err
+	}
+	if code != MultihashSHA256 {
+		return root, fmt.Errorf("%w: unsupported code 0x%x", ErrInvalidMultihash, code)
+	}
+	if len(digest) != sha256.Size {
+		return root, fmt.Errorf("%w: digest of %d bytes with length %d", ErrInvalidMultihash, len(digest), len(digest))
+	}
+
+	copy(root[:], digest)
+	return root, nil
+}
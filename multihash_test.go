@@ -0,0 +1,37 @@
+package merkletree
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootMultihash(t *testing.T) {
+	// sha2-256 multihash of the empty string from the multiformats test vectors
+	empty := New(nil).RootMultihash()
+	assert.Equal(t, "1220e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(empty))
+
+	tree := New(makeEntries(7))
+	mh := tree.RootMultihash()
+	assert.Equal(t, []byte{0x12, 0x20}, mh[:2])
+	root, err := MultihashToRoot(mh)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.MerkleRoot(), root)
+}
+
+func TestMultihashToRootRejectsInvalid(t *testing.T) {
+	mh := New(makeEntries(3)).RootMultihash()
+
+	for name, invalid := range map[string][]byte{
+		"empty":     {},
+		"sha1":      append([]byte{0x11, 0x14}, mh[2:22]...),
+		"truncated": mh[:len(mh)-1],
+		"trailing":  append(append([]byte{}, mh...), 0),
+		"length":    append([]byte{0x12, 0x1f}, mh[2:33]...),
+		"varint":    {0x92},
+	} {
+		_, err := MultihashToRoot(invalid)
+		assert.ErrorIs(t, err, ErrInvalidMultihash, name)
+	}
+}
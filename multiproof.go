@@ -0,0 +1,163 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// MultiProof proves the leaves at Indices of the tree of TreeSize leaves at once.
+// Where the audit paths of the leaves would share a node, or hold the hash of a
+// subtree over other proven leaves, the multiproof holds it once, or not at all, so
+// that the proof of clustered leaves is much smaller than their audit paths.
+//
+// Hashes are the roots of the subtrees holding none of the leaves, met walking the
+// tree depth first from the left, and splitting every subtree holding a leaf as MTH
+// does. Algorithm tags the proofs of trees over another Hasher, as it does
+// inclusion proofs.
+type MultiProof struct {
+	Algorithm string
+	TreeSize  uint64
+	Indices   []uint64
+	Hashes    [][sha256.Size]byte
+}
+
+// MultiProof returns the proof of the leaves at indices in the tree. The indices may
+// be in any order and repeated, the proof holds them sorted and unique. No indices
+// fail with ErrInvalidRange, and indices outside of the tree with ErrIndexOutOfRange.
+func (m *MerkleHashTree) MultiProof(indices []uint64) (*MultiProof, error) {
+	defer m.readLock()()
+
+	n := uint64(len(m.tree[0]))
+	sorted := append([]uint64(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for i, index := range sorted {
+		if i == 0 || index != sorted[i-1] {
+			unique = append(unique, index)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("%w: no leaf to prove", ErrInvalidRange)
+	}
+	if last := unique[len(unique)-1]; last >= n {
+		return nil, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, last, n)
+	}
+
+	p := &MultiProof{Algorithm: m.algorithm(), TreeSize: n, Indices: unique, Hashes: make([][sha256.Size]byte, 0)}
+	m.multiProof(p, unique, 0, n)
+	return p, nil
+}
+
+// multiProof appends to p the hashes proving the leaves at indices, the sorted
+// indices of the leaves requested in [start, end)
+func (m *MerkleHashTree) multiProof(p *MultiProof, indices []uint64, start, end uint64) {
+	if len(indices) == 0 {
+		p.Hashes = append(p.Hashes, m.mthOfRange(int(start), int(end)-1))
+		return
+	}
+	if end-start == 1 {
+		return
+	}
+	k := start + SplitPoint(end-start)
+	split := sort.Search(len(indices), func(i int) bool { return indices[i] >= k })
+	m.multiProof(p, indices[:split], start, k)
+	m.multiProof(p, indices[split:], k, end)
+}
+
+// VerifyMultiProof checks that leafHashes are the leaf hashes at indices, in
+// increasing order, of the tree of size leaves with the given root. It fails with
+// ErrInvalidProof when p is nil or proves other indices or another size, with
+// ErrInvalidProofSize when p has too few or too many hashes and with
+// ErrRootMismatch when the root recomputed differs, as it does for any wrong leaf
+// hash. A proof tagged with another algorithm than SHA-256 fails with
+// ErrHashMismatch.
+func VerifyMultiProof(leafHashes [][sha256.Size]byte, indices []uint64, size uint64, root [sha256.Size]byte, p *MultiProof) error {
+	return verifyMultiProof(SHA256Hasher, sha256NodeHash, leafHashes, indices, size, root, p)
+}
+
+// VerifyMultiProof checks the multiproof p of a tree over h, like the package level
+// VerifyMultiProof. A proof tagged with another algorithm fails with
+// ErrHashMismatch.
+func (h Hasher) VerifyMultiProof(leafHashes [][sha256.Size]byte, indices []uint64, size uint64, root [sha256.Size]byte, p *MultiProof) error {
+	return verifyMultiProof(h, h.NodeHash, leafHashes, indices, size, root, p)
+}
+
+// verifyMultiProof checks the multiproof p of a tree over h, whose nodes node hashes
+func verifyMultiProof(h Hasher, node func(left, right [sha256.Size]byte) [sha256.Size]byte, leafHashes [][sha256.Size]byte, indices []uint64, size uint64, root [sha256.Size]byte, p *MultiProof) error {
+	if p == nil {
+		return fmt.Errorf("%w: no proof", ErrInvalidProof)
+	}
+	if err := h.checkAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	if len(indices) == 0 || len(leafHashes) != len(indices) {
+		return fmt.Errorf("%w: %d leaf hashes for %d indices", ErrInvalidProof, len(leafHashes), len(indices))
+	}
+	for i, index := range indices {
+		if i > 0 && index <= indices[i-1] {
+			return fmt.Errorf("%w: indices are not increasing at %d", ErrInvalidProof, index)
+		}
+	}
+	if indices[len(indices)-1] >= size {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, indices[len(indices)-1], size)
+	}
+	if p.TreeSize != size || len(p.Indices) != len(indices) {
+		return fmt.Errorf("%w: proof of %d leaves of %d, not %d of %d", ErrInvalidProof, len(p.Indices), p.TreeSize, len(indices), size)
+	}
+	for i := range indices {
+		if p.Indices[i] != indices[i] {
+			return fmt.Errorf("%w: proof of index %d, not %d", ErrInvalidProof, p.Indices[i], indices[i])
+		}
+	}
+
+	v := multiProofVerifier{node: node, leaves: leafHashes, hashes: p.Hashes}
+	calculated, err := v.root(indices, 0, size)
+	if err != nil {
+		return err
+	}
+	if len(v.hashes) != 0 {
+		return fmt.Errorf("%w: %d hashes left over", ErrInvalidProofSize, len(v.hashes))
+	}
+	if calculated != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// multiProofVerifier consumes the leaf hashes and proof hashes of a multiproof in
+// the order multiProof visits them
+type multiProofVerifier struct {
+	node   func(left, right [sha256.Size]byte) [sha256.Size]byte
+	leaves [][sha256.Size]byte
+	hashes [][sha256.Size]byte
+}
+
+// root returns the hash of [start, end), holding the leaves at indices
+func (v *multiProofVerifier) root(indices []uint64, start, end uint64) ([sha256.Size]byte, error) {
+	if len(indices) == 0 {
+		if len(v.hashes) == 0 {
+			return [sha256.Size]byte{}, fmt.Errorf("%w: too few hashes", ErrInvalidProofSize)
+		}
+		h := v.hashes[0]
+		v.hashes = v.hashes[1:]
+		return h, nil
+	}
+	if end-start == 1 {
+		h := v.leaves[0]
+		v.leaves = v.leaves[1:]
+		return h, nil
+	}
+
+	k := start + SplitPoint(end-start)
+	split := sort.Search(len(indices), func(i int) bool { return indices[i] >= k })
+	left, err := v.root(indices[:split], start, k)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	right, err := v.root(indices[split:], k, end)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return v.node(left, right), nil
+}
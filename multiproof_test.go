@@ -0,0 +1,135 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func leafHashesAt(D [][]byte, indices []uint64) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, len(indices))
+	for i, index := range indices {
+		hashes[i] = leafHash(D[index])
+	}
+	return hashes
+}
+
+func TestMultiProof(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 7, 8, 33, 500} {
+		D := makeEntries(n)
+		tree := New(D)
+		root := MTH(D)
+		for trial := 0; trial < 20; trial++ {
+			indices := make([]uint64, 1+r.Intn(n))
+			for i := range indices {
+				indices[i] = uint64(r.Intn(n))
+			}
+			p, err := tree.MultiProof(indices)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(n), p.TreeSize)
+			assert.NoError(t, VerifyMultiProof(leafHashesAt(D, p.Indices), p.Indices, uint64(n), root, p), "size %d, indices %v", n, p.Indices)
+
+			// A single wrong leaf hash changes the root
+			leaves := leafHashesAt(D, p.Indices)
+			wrong := r.Intn(len(leaves))
+			leaves[wrong][0] ^= 1
+			assert.ErrorIs(t, VerifyMultiProof(leaves, p.Indices, uint64(n), root, p), ErrRootMismatch, "size %d, indices %v", n, p.Indices)
+		}
+	}
+}
+
+func TestMultiProofSingleLeafIsAuditPath(t *testing.T) {
+	D := makeEntries(21)
+	tree := New(D)
+	for i := range D {
+		p, err := tree.MultiProof([]uint64{uint64(i)})
+		assert.NoError(t, err)
+
+		// The hashes are those of the audit path, in the order of the walk
+		assert.ElementsMatch(t, Path(uint64(i), D), p.Hashes, "leaf %d", i)
+	}
+
+	// Proving every leaf takes no hash at all
+	p, err := tree.MultiProof([]uint64{20, 0, 5, 1, 2, 3, 4, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 5})
+	assert.NoError(t, err)
+	assert.Empty(t, p.Hashes)
+	assert.Len(t, p.Indices, 21)
+	assert.NoError(t, VerifyMultiProof(leafHashesAt(D, p.Indices), p.Indices, 21, MTH(D), p))
+}
+
+func TestMultiProofSize(t *testing.T) {
+	D := makeEntries(1 << 12)
+	tree := New(D)
+	indices := make([]uint64, 0, 50)
+	for i := uint64(1000); i < 1050; i++ {
+		indices = append(indices, i)
+	}
+	p, err := tree.MultiProof(indices)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyMultiProof(leafHashesAt(D, indices), indices, uint64(len(D)), MTH(D), p))
+
+	paths := 0
+	for _, i := range indices {
+		paths += len(Path(i, D))
+	}
+	assert.Equal(t, 12*len(indices), paths)
+	assert.Less(t, len(p.Hashes), 20)
+}
+
+func TestMultiProofHasher(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	D := makeEntries(13)
+	tree := New(D, WithHasher(h))
+	indices := []uint64{0, 4, 5, 12}
+	leaves := make([][sha256.Size]byte, len(indices))
+	for i, index := range indices {
+		leaves[i] = h.LeafHash(D[index])
+	}
+
+	p, err := tree.MultiProof(indices)
+	assert.NoError(t, err)
+	assert.Equal(t, h.Name(), p.Algorithm)
+	assert.NoError(t, h.VerifyMultiProof(leaves, indices, 13, tree.MerkleRoot(), p))
+
+	// The proof is not mistaken for one of a SHA-256 tree, nor the other way round
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 13, tree.MerkleRoot(), p), ErrHashMismatch)
+	sha, err := New(D).MultiProof(indices)
+	assert.NoError(t, err)
+	assert.Empty(t, sha.Algorithm)
+	assert.ErrorIs(t, h.VerifyMultiProof(leafHashesAt(D, indices), indices, 13, MTH(D), sha), ErrHashMismatch)
+	assert.NoError(t, SHA256Hasher.VerifyMultiProof(leafHashesAt(D, indices), indices, 13, MTH(D), sha))
+}
+
+func TestMultiProofErrors(t *testing.T) {
+	D := makeEntries(9)
+	tree := New(D)
+	root := MTH(D)
+	_, err := tree.MultiProof(nil)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = tree.MultiProof([]uint64{3, 9})
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	indices := []uint64{2, 3, 7}
+	leaves := leafHashesAt(D, indices)
+	p, err := tree.MultiProof(indices)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyMultiProof(leaves, indices, 9, root, p))
+
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 9, root, nil), ErrInvalidProof)
+	assert.ErrorIs(t, VerifyMultiProof(leaves[:2], indices, 9, root, p), ErrInvalidProof)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, []uint64{3, 2, 7}, 9, root, p), ErrInvalidProof)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, []uint64{2, 3, 6}, 9, root, p), ErrInvalidProof)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 10, root, p), ErrInvalidProof)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 7, root, p), ErrIndexOutOfRange)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 9, MTH(D[:8]), p), ErrRootMismatch)
+
+	short := *p
+	short.Hashes = p.Hashes[:len(p.Hashes)-1]
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 9, root, &short), ErrInvalidProofSize)
+	long := *p
+	long.Hashes = append(append([][sha256.Size]byte(nil), p.Hashes...), root)
+	assert.ErrorIs(t, VerifyMultiProof(leaves, indices, 9, root, &long), ErrInvalidProofSize)
+}
@@ -0,0 +1,85 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Truncate drops the leaves after the first n and returns the new merkle root.
+// Unlike appends it rewrites the history of the tree, so proofs and roots of
+// sizes above n obtained before no longer match the tree.
+func (m *MerkleHashTree) Truncate(n uint64) ([sha256.Size]byte, error) {
+	defer m.writeLock()()
+
+	if m.sealed {
+		return m.root(), ErrSealed
+	}
+	if n > uint64(len(m.tree[0])) {
+		return m.root(), fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, n, len(m.tree[0]))
+	}
+
+	if err := m.persist(n, nil, n); err != nil {
+		return m.root(), err
+	}
+
+	leaves := m.tree[0][:n:n]
+	if uint64(len(m.entries)) > n {
+		m.entries = m.entries[:n]
+	}
+	for i := range m.redacted {
+		if i >= n {
+			delete(m.redacted, i)
+		}
+	}
+	m.truncateKeys(n)
+	m.tree = make([][][sha256.Size]byte, levels(len(leaves)))
+	m.tree[0] = leaves
+	m.rewriteHistory(n + 1)
+	return m.rewrite(), nil
+}
+
+// SetLeaf replaces the leaf at index i with d and returns the new merkle root.
+// Like Truncate it rewrites the history of the tree. An entry stored for the leaf
+// with AppendWithExtra is dropped, and the leaf is no longer redacted. An entry
+// rejected by the admission hook fails with a *RejectedError.
+func (m *MerkleHashTree) SetLeaf(i uint64, d []byte) ([sha256.Size]byte, error) {
+	defer m.writeLock()()
+	if m.sealed {
+		return m.root(), ErrSealed
+	}
+	if i >= uint64(len(m.tree[0])) {
+		return m.root(), fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, len(m.tree[0]))
+	}
+
+	if err := m.admit(i, [][]byte{d}); err != nil {
+		return m.root(), err
+	}
+	leaf := m.hashLeaves(i, [][]byte{d})
+	if err := m.checkSorted(i, leaf); err != nil {
+		return m.root(), err
+	}
+	tail := append([][sha256.Size]byte{leaf[0]}, m.tree[0][i+1:]...)
+	if err := m.persist(i, tail, uint64(len(m.tree[0]))); err != nil {
+		return m.root(), err
+	}
+	m.tree[0][i] = leaf[0]
+	m.rewriteHistory(i + 1)
+	if i < uint64(len(m.entries)) {
+		m.entries[i] = storedEntry{}
+	}
+	delete(m.redacted, i)
+	return m.rewrite(), nil
+}
+
+// rewrite rebuilds the tree from its leaves and drops cached proofs, which may
+// no longer match the tree.
+func (m *MerkleHashTree) rewrite() [sha256.Size]byte {
+	m.generation++
+	m.lastDelta = nil
+	if m.proofCache != nil {
+		m.proofCache.purge()
+	}
+	m.buildTree(m.tree[0])
+	m.recordRoot()
+	return m.root()
+}
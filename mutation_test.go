@@ -0,0 +1,54 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	D := makeEntries(9)
+	tree := New(D, WithProofCache(16))
+	_, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+
+	for _, n := range []int{9, 5, 4, 1, 0} {
+		root, err := tree.Truncate(uint64(n))
+		assert.NoError(t, err)
+		assert.Equal(t, MTH(D[:n]), root)
+		assert.Equal(t, uint64(n), tree.Size())
+	}
+
+	_, err = tree.Truncate(1)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	tree.Append(D[:3]...)
+	tree.Append([]byte("other"), D[4])
+	other := append(append([][]byte{}, D[:3]...), []byte("other"), D[4])
+	assert.Equal(t, MTH(other), tree.MerkleRoot())
+	proof, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash([]byte("other")), MTH(other), proof))
+}
+
+func TestSetLeaf(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+
+	_, err := tree.SetLeaf(7, []byte("x"))
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	root, err := tree.SetLeaf(4, []byte("x"))
+	assert.NoError(t, err)
+	D[4] = []byte("x")
+	assert.Equal(t, MTH(D), root)
+	leaf, err := tree.LeafHash(4)
+	assert.NoError(t, err)
+	assert.Equal(t, leafHash(D[4]), leaf)
+
+	tree.Seal()
+	_, err = tree.SetLeaf(0, []byte("y"))
+	assert.ErrorIs(t, err, ErrSealed)
+	_, err = tree.Truncate(0)
+	assert.ErrorIs(t, err, ErrSealed)
+}
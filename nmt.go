@@ -0,0 +1,274 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by namespaced merkle trees
+var (
+	ErrNamespaceSize  = errors.New("merkletree: namespace has the wrong size")
+	ErrNamespaceOrder = errors.New("merkletree: leaves must be pushed in non-decreasing namespace order")
+	ErrIncomplete     = errors.New("merkletree: proof does not cover every leaf of the namespace")
+)
+
+// DefaultNamespaceSize is the namespace size of an NMT unless set WithNamespaceSize
+const DefaultNamespaceSize = 8
+
+// NMTOption configures an NMT
+type NMTOption func(*NMT)
+
+// WithNamespaceSize sets the size of namespaces in bytes
+func WithNamespaceSize(n int) NMTOption {
+	return func(t *NMT) {
+		t.nsSize = n
+	}
+}
+
+// NMT is a namespaced merkle tree in the style of Celestia. Every leaf belongs to
+// a namespace and every node commits to the minimum and maximum namespace of the
+// leaves below it, so that a single proof can show that a set of leaves is all
+// of a namespace, or that the namespace is absent.
+//
+// A node is serialized as minNs || maxNs || digest. The leaf of data d in namespace
+// ns is ns || ns || SHA-256(0x00 || ns || d), and the parent of l and r is
+// l.minNs || maxNs || SHA-256(0x01 || l || r), where maxNs is r.maxNs unless r only
+// holds leaves of the maximum namespace (all 0xff bytes), in which case it is
+// l.maxNs. The shape of the tree is the one of RFC 6962.
+type NMT struct {
+	nsSize     int
+	namespaces [][]byte
+	data       [][]byte
+	leaves     [][]byte
+}
+
+// NewNMT returns an empty namespaced merkle tree
+func NewNMT(opts ...NMTOption) *NMT {
+	t := &NMT{nsSize: DefaultNamespaceSize}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Size returns the number of leaves in the tree
+func (t *NMT) Size() uint64 {
+	return uint64(len(t.leaves))
+}
+
+// Push appends data in namespace ns. Namespaces must be pushed in non-decreasing order.
+func (t *NMT) Push(ns, data []byte) error {
+	if len(ns) != t.nsSize {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrNamespaceSize, len(ns), t.nsSize)
+	}
+	if n := len(t.namespaces); n > 0 && bytes.Compare(ns, t.namespaces[n-1]) < 0 {
+		return fmt.Errorf("%w: %x after %x", ErrNamespaceOrder, ns, t.namespaces[n-1])
+	}
+
+	t.namespaces = append(t.namespaces, append([]byte(nil), ns...))
+	t.data = append(t.data, append([]byte(nil), data...))
+	t.leaves = append(t.leaves, nmtLeafHash(ns, data))
+	return nil
+}
+
+// Root returns the root of the tree, minNs || maxNs || digest
+func (t *NMT) Root() []byte {
+	return nmtRange(t.leaves, t.nsSize, 0, uint64(len(t.leaves)))
+}
+
+func nmtLeafHash(ns, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{LeafPrefix})
+	h.Write(ns)
+	h.Write(data)
+	return h.Sum(append(append(make([]byte, 0, 2*len(ns)+sha256.Size), ns...), ns...))
+}
+
+func nmtNodeHash(left, right []byte, nsSize int) []byte {
+	maxNs := right[nsSize : 2*nsSize]
+	if bytes.Equal(right[:nsSize], bytes.Repeat([]byte{0xff}, nsSize)) {
+		maxNs = left[nsSize : 2*nsSize]
+	}
+
+	h := sha256.New()
+	h.Write([]byte{NodePrefix})
+	h.Write(left)
+	h.Write(right)
+	node := make([]byte, 0, 2*nsSize+sha256.Size)
+	node = append(node, left[:nsSize]...)
+	node = append(node, maxNs...)
+	return h.Sum(node)
+}
+
+// nmtRange returns the node over the leaves [start, end)
+func nmtRange(leaves [][]byte, nsSize int, start, end uint64) []byte {
+	switch end - start {
+	case 0:
+		empty := sha256.Sum256(nil)
+		return append(make([]byte, 2*nsSize), empty[:]...)
+	case 1:
+		return leaves[start]
+	}
+	k := start + SplitPoint(end-start)
+	return nmtNodeHash(nmtRange(leaves, nsSize, start, k), nmtRange(leaves, nsSize, k, end), nsSize)
+}
+
+// NamespaceProof proves that the leaves [Start, End) of a tree of TreeSize leaves
+// are all the leaves of a namespace. Nodes holds the roots of the subtrees outside
+// of the range, from left to right. An absence proof has a single leaf in its range,
+// the first one of a greater namespace, given by its LeafHash. A proof with an
+// empty range shows that the namespace is outside of the range of the root.
+type NamespaceProof struct {
+	Start    uint64
+	End      uint64
+	TreeSize uint64
+	Nodes    [][]byte
+	LeafHash []byte
+}
+
+// ProveNamespace returns the data of the leaves of namespace ns along with the
+// proof that there are no other leaves of ns in the tree.
+func (t *NMT) ProveNamespace(ns []byte) ([][]byte, NamespaceProof, error) {
+	if len(ns) != t.nsSize {
+		return nil, NamespaceProof{}, fmt.Errorf("%w: %d bytes, expected %d", ErrNamespaceSize, len(ns), t.nsSize)
+	}
+
+	n := uint64(len(t.leaves))
+	root := t.Root()
+	if n == 0 || bytes.Compare(ns, root[:t.nsSize]) < 0 || bytes.Compare(ns, root[t.nsSize:2*t.nsSize]) > 0 {
+		return nil, NamespaceProof{TreeSize: n}, nil
+	}
+
+	start := uint64(0)
+	for start < n && bytes.Compare(t.namespaces[start], ns) < 0 {
+		start++
+	}
+	end := start
+	for end < n && bytes.Equal(t.namespaces[end], ns) {
+		end++
+	}
+
+	proof := NamespaceProof{Start: start, End: end, TreeSize: n}
+	if start == end {
+		// Absent: prove the first leaf of a greater namespace instead.
+		proof.End = start + 1
+		proof.LeafHash = t.leaves[start]
+	}
+	proof.Nodes = t.rangeNodes(proof.Start, proof.End, 0, n)
+	return t.data[start:end:end], proof, nil
+}
+
+// rangeNodes returns the roots of the subtrees of [lo, hi) outside of [start, end), from left to right
+func (t *NMT) rangeNodes(start, end, lo, hi uint64) [][]byte {
+	if hi <= start || lo >= end {
+		return [][]byte{nmtRange(t.leaves, t.nsSize, lo, hi)}
+	}
+	if hi-lo == 1 {
+		return nil
+	}
+	k := lo + SplitPoint(hi-lo)
+	return append(t.rangeNodes(start, end, lo, k), t.rangeNodes(start, end, k, hi)...)
+}
+
+// VerifyNamespace checks that data holds all the leaves of namespace ns in the tree
+// with the given root, in order. ErrIncomplete is returned when the proof shows
+// other leaves of ns may exist outside of its range.
+func VerifyNamespace(root, ns []byte, data [][]byte, p NamespaceProof) error {
+	nsSize := len(ns)
+	if len(root) != 2*nsSize+sha256.Size {
+		return fmt.Errorf("%w: root of %d bytes", ErrNamespaceSize, len(root))
+	}
+
+	if p.Start == p.End {
+		// The namespace must be outside of the range of the root.
+		if len(data) != 0 || len(p.Nodes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if p.TreeSize == 0 || bytes.Compare(ns, root[:nsSize]) < 0 || bytes.Compare(ns, root[nsSize:2*nsSize]) > 0 {
+			return nil
+		}
+		return ErrIncomplete
+	}
+	if p.Start > p.End || p.End > p.TreeSize {
+		return fmt.Errorf("%w: range [%d, %d), size %d", ErrInvalidRange, p.Start, p.End, p.TreeSize)
+	}
+
+	var leaves [][]byte
+	if p.LeafHash != nil {
+		// Absence proof: the single leaf of the range belongs to a greater namespace.
+		if len(data) != 0 || p.End-p.Start != 1 || len(p.LeafHash) != len(root) {
+			return ErrInvalidProofSize
+		}
+		if bytes.Compare(p.LeafHash[:nsSize], ns) <= 0 {
+			return ErrIncomplete
+		}
+		leaves = [][]byte{p.LeafHash}
+	} else {
+		if uint64(len(data)) != p.End-p.Start {
+			return ErrInvalidProofSize
+		}
+		for _, d := range data {
+			leaves = append(leaves, nmtLeafHash(ns, d))
+		}
+	}
+
+	v := nmtVerifier{ns: ns, start: p.Start, end: p.End, leaves: leaves, nodes: p.Nodes}
+	calculated, err := v.node(0, p.TreeSize)
+	if err != nil {
+		return err
+	}
+	if len(v.nodes) != 0 {
+		return ErrInvalidProofSize
+	}
+	if !bytes.Equal(calculated, root) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// nmtVerifier recomputes the root of a tree from the leaves of a range and the
+// nodes outside of it, checking that no node outside of the range may hold leaves
+// of the namespace.
+type nmtVerifier struct {
+	ns         []byte
+	start, end uint64
+	leaves     [][]byte
+	nodes      [][]byte
+}
+
+func (v *nmtVerifier) node(lo, hi uint64) ([]byte, error) {
+	nsSize := len(v.ns)
+	if hi <= v.start || lo >= v.end {
+		if len(v.nodes) == 0 {
+			return nil, ErrInvalidProofSize
+		}
+		node := v.nodes[0]
+		v.nodes = v.nodes[1:]
+		if len(node) != 2*nsSize+sha256.Size {
+			return nil, fmt.Errorf("%w: node of %d bytes", ErrNamespaceSize, len(node))
+		}
+		if hi <= v.start && bytes.Compare(node[nsSize:2*nsSize], v.ns) >= 0 {
+			return nil, ErrIncomplete
+		}
+		if lo >= v.end && bytes.Compare(node[:nsSize], v.ns) <= 0 {
+			return nil, ErrIncomplete
+		}
+		return node, nil
+	}
+	if hi-lo == 1 {
+		return v.leaves[lo-v.start], nil
+	}
+
+	k := lo + SplitPoint(hi-lo)
+	left, err := v.node(lo, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := v.node(k, hi)
+	if err != nil {
+		return nil, err
+	}
+	return nmtNodeHash(left, right, nsSize), nil
+}
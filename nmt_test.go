@@ -0,0 +1,172 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func namespace(b byte) []byte {
+	return bytes.Repeat([]byte{b}, DefaultNamespaceSize)
+}
+
+func makeNMT(t *testing.T, namespaces ...byte) *NMT {
+	tree := NewNMT()
+	for i, ns := range namespaces {
+		assert.NoError(t, tree.Push(namespace(ns), []byte(fmt.Sprintf("d%d", i))))
+	}
+	return tree
+}
+
+func TestNMTRoot(t *testing.T) {
+	empty := sha256.Sum256(nil)
+	assert.Equal(t, append(make([]byte, 16), empty[:]...), NewNMT().Root())
+
+	tree := makeNMT(t, 1, 1, 2, 4, 4, 5, 7)
+	root := tree.Root()
+	assert.Len(t, root, 2*DefaultNamespaceSize+sha256.Size)
+	assert.Equal(t, namespace(1), root[:8])
+	assert.Equal(t, namespace(7), root[8:16])
+
+	// Leaves of the maximum namespace do not raise the maximum of the root.
+	assert.NoError(t, tree.Push(namespace(0xff), []byte("parity")))
+	assert.Equal(t, namespace(7), tree.Root()[8:16])
+
+	assert.ErrorIs(t, tree.Push(namespace(3), nil), ErrNamespaceOrder)
+	assert.ErrorIs(t, tree.Push([]byte{1}, nil), ErrNamespaceSize)
+	assert.Equal(t, uint64(8), tree.Size())
+}
+
+func TestNMTProveNamespace(t *testing.T) {
+	tree := makeNMT(t, 1, 1, 2, 2, 2, 4, 5, 5, 9)
+	root := tree.Root()
+
+	data, proof, err := tree.ProveNamespace(namespace(2))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("d2"), []byte("d3"), []byte("d4")}, data)
+	assert.NoError(t, VerifyNamespace(root, namespace(2), data, proof))
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), data[:2], proof), ErrInvalidProofSize)
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), [][]byte{data[0], data[1], []byte("x")}, proof), ErrRootMismatch)
+
+	for _, ns := range []byte{1, 5, 9} {
+		data, proof, err := tree.ProveNamespace(namespace(ns))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, data)
+		assert.NoError(t, VerifyNamespace(root, namespace(ns), data, proof), "ns %d", ns)
+	}
+
+	// Absent namespaces within the range of the root are proven by the next leaf.
+	data, proof, err = tree.ProveNamespace(namespace(3))
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, uint64(5), proof.Start)
+	assert.NotNil(t, proof.LeafHash)
+	assert.NoError(t, VerifyNamespace(root, namespace(3), nil, proof))
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(4), nil, proof), ErrIncomplete)
+
+	// Namespaces outside of the range of the root need no nodes at all.
+	for _, ns := range []byte{0, 10} {
+		data, proof, err := tree.ProveNamespace(namespace(ns))
+		assert.NoError(t, err)
+		assert.Empty(t, data)
+		assert.Empty(t, proof.Nodes)
+		assert.NoError(t, VerifyNamespace(root, namespace(ns), nil, proof))
+	}
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), nil, NamespaceProof{TreeSize: 9}), ErrIncomplete)
+}
+
+func TestNMTRejectsIncompleteProofs(t *testing.T) {
+	tree := makeNMT(t, 1, 2, 2, 2, 3, 3, 4)
+	root := tree.Root()
+
+	// A proof leaving out the last leaf of the namespace
+	partial := NamespaceProof{Start: 1, End: 3, TreeSize: 7, Nodes: tree.rangeNodes(1, 3, 0, 7)}
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), [][]byte{[]byte("d1"), []byte("d2")}, partial), ErrIncomplete)
+
+	// A proof leaving out the first leaf of the namespace
+	partial = NamespaceProof{Start: 2, End: 4, TreeSize: 7, Nodes: tree.rangeNodes(2, 4, 0, 7)}
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), [][]byte{[]byte("d2"), []byte("d3")}, partial), ErrIncomplete)
+
+	// An absence proof for a namespace that is present
+	absent := NamespaceProof{Start: 4, End: 5, TreeSize: 7, Nodes: tree.rangeNodes(4, 5, 0, 7), LeafHash: tree.leaves[4]}
+	assert.ErrorIs(t, VerifyNamespace(root, namespace(2), nil, absent), ErrIncomplete)
+}
+
+func TestNMTNamespaceSize(t *testing.T) {
+	tree := NewNMT(WithNamespaceSize(29))
+	ns := bytes.Repeat([]byte{3}, 29)
+	assert.NoError(t, tree.Push(ns, []byte("blob")))
+	assert.Len(t, tree.Root(), 2*29+sha256.Size)
+
+	data, proof, err := tree.ProveNamespace(ns)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyNamespace(tree.Root(), ns, data, proof))
+}
+
+// nmtVector is a tree of testdata/nmt/vectors.json, with its root and namespace
+// proofs computed by github.com/celestiaorg/nmt
+type nmtVector struct {
+	Namespaces []string `json:"namespaces"`
+	Data       []string `json:"data"`
+	Root       string   `json:"root"`
+	Proofs     []struct {
+		Namespace string   `json:"namespace"`
+		Start     uint64   `json:"start"`
+		End       uint64   `json:"end"`
+		Nodes     []string `json:"nodes"`
+		LeafHash  string   `json:"leaf_hash"`
+	} `json:"proofs"`
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestNMTVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/nmt/vectors.json")
+	assert.NoError(t, err)
+	var vectors []nmtVector
+	assert.NoError(t, json.Unmarshal(data, &vectors))
+	assert.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		tree := NewNMT()
+		for i, ns := range v.Namespaces {
+			assert.NoError(t, tree.Push(decodeHex(t, ns), decodeHex(t, v.Data[i])))
+		}
+		root := tree.Root()
+		assert.Equal(t, v.Root, hex.EncodeToString(root), "namespaces %v", v.Namespaces)
+
+		for _, want := range v.Proofs {
+			ns := decodeHex(t, want.Namespace)
+			leaves, proof, err := tree.ProveNamespace(ns)
+			assert.NoError(t, err)
+			nodes := make([]string, len(proof.Nodes))
+			for i, n := range proof.Nodes {
+				nodes[i] = hex.EncodeToString(n)
+			}
+			assert.Equal(t, want.Start, proof.Start, "namespaces %v, namespace %s", v.Namespaces, want.Namespace)
+			assert.Equal(t, want.End, proof.End, "namespaces %v, namespace %s", v.Namespaces, want.Namespace)
+			assert.Equal(t, want.Nodes, nodes, "namespaces %v, namespace %s", v.Namespaces, want.Namespace)
+			assert.Equal(t, want.LeafHash, hex.EncodeToString(proof.LeafHash), "namespaces %v, namespace %s", v.Namespaces, want.Namespace)
+
+			// The proof of the reference implementation verifies as is
+			recorded := NamespaceProof{Start: want.Start, End: want.End, TreeSize: uint64(len(v.Namespaces))}
+			for _, n := range want.Nodes {
+				recorded.Nodes = append(recorded.Nodes, decodeHex(t, n))
+			}
+			if want.LeafHash != "" {
+				recorded.LeafHash = decodeHex(t, want.LeafHash)
+			}
+			assert.NoError(t, VerifyNamespace(decodeHex(t, v.Root), ns, leaves, recorded), "namespaces %v, namespace %s", v.Namespaces, want.Namespace)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotCovered is returned when proving a leaf a NodeBundle was not extracted for
+var ErrNotCovered = errors.New("merkletree: index is not covered by the bundle")
+
+// NodeBundle is the minimal set of nodes needed to prove the inclusion of a fixed
+// set of leaves in the tree with the given root and size: the hashes of the
+// covered leaves and of every node on their audit paths that cannot be computed
+// from other nodes of the bundle, each included once.
+type NodeBundle struct {
+	TreeSize uint64
+	Root     [sha256.Size]byte
+	Indices  []uint64
+	Nodes    []ProofNode
+}
+
+// ExtractBundle returns the NodeBundle to prove the leaves at indices in the
+// current tree, for instance offline with a BundleProver. A BundleProver hashes
+// with SHA-256, so the bundle of a tree over another Hasher fails with
+// ErrUnsupportedHash.
+func (m *MerkleHashTree) ExtractBundle(indices []uint64) (NodeBundle, error) {
+	defer m.readLock()()
+
+	if name := m.algorithm(); name != "" {
+		return NodeBundle{}, fmt.Errorf("%w: bundle of a tree over %s", ErrUnsupportedHash, name)
+	}
+
+	size := uint64(len(m.tree[0]))
+	b := NodeBundle{TreeSize: size, Root: m.root(), Indices: sortedIndices(indices)}
+	// Nodes on the path of a covered leaf are computed from the leaf and its
+	// siblings, so they are left out when they are the sibling of another path
+	onPath := make(map[NodeID]bool)
+	for _, i := range b.Indices {
+		if i >= size {
+			return NodeBundle{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, size)
+		}
+		onPath[NodeID{Index: i}] = true
+		for _, step := range inclusionSteps(i, 0, size) {
+			onPath[step.parent] = true
+		}
+	}
+
+	nodes := make(map[NodeID][sha256.Size]byte)
+	for _, i := range b.Indices {
+		nodes[NodeID{Index: i}] = m.tree[0][i]
+		proof, err := m.inclusionProofAtSize(i, size)
+		if err != nil {
+			return NodeBundle{}, err
+		}
+		for j, step := range inclusionSteps(i, 0, size) {
+			if !onPath[step.sibling] {
+				nodes[step.sibling] = proof.Hashes[j]
+			}
+		}
+	}
+	for id, hash := range nodes {
+		b.Nodes = append(b.Nodes, ProofNode{NodeID: id, Hash: hash})
+	}
+	sort.Slice(b.Nodes, func(i, j int) bool {
+		if b.Nodes[i].Level != b.Nodes[j].Level {
+			return b.Nodes[i].Level < b.Nodes[j].Level
+		}
+		return b.Nodes[i].Index < b.Nodes[j].Index
+	})
+	return b, nil
+}
+
+// sortedIndices returns indices sorted, without duplicates
+func sortedIndices(indices []uint64) []uint64 {
+	sorted := append([]uint64(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for j, i := range sorted {
+		if j == 0 || i != sorted[j-1] {
+			unique = append(unique, i)
+		}
+	}
+	return unique
+}
+
+// BundleProver generates inclusion proofs from a NodeBundle, without the tree
+type BundleProver struct {
+	bundle  NodeBundle
+	covered map[uint64]bool
+	nodes   map[NodeID][sha256.Size]byte
+}
+
+// NewBundleProver returns a prover for the leaves covered by b. The root
+// computed from the nodes of b must be b.Root.
+func NewBundleProver(b NodeBundle) (*BundleProver, error) {
+	p := &BundleProver{bundle: b, covered: make(map[uint64]bool), nodes: make(map[NodeID][sha256.Size]byte)}
+	for _, n := range b.Nodes {
+		if !n.ExistsIn(b.TreeSize) {
+			return nil, fmt.Errorf("%w: no node at level %d, index %d in a tree of size %d", ErrInvalidProof, n.Level, n.Index, b.TreeSize)
+		}
+		p.nodes[n.NodeID] = n.Hash
+	}
+	for _, i := range b.Indices {
+		if _, ok := p.nodes[NodeID{Index: i}]; !ok || i >= b.TreeSize {
+			return nil, fmt.Errorf("%w: leaf %d", ErrInvalidProof, i)
+		}
+		p.covered[i] = true
+	}
+	if b.TreeSize == 0 {
+		return p, nil
+	}
+
+	root, err := p.rangeHash(0, b.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+	if root != b.Root {
+		return nil, fmt.Errorf("%w: bundle nodes hash to %x, bundle root %x", ErrRootMismatch, root, b.Root)
+	}
+	return p, nil
+}
+
+// Root returns the root of the tree the bundle was extracted from
+func (p *BundleProver) Root() [sha256.Size]byte {
+	return p.bundle.Root
+}
+
+// Size returns the size of the tree the bundle was extracted from
+func (p *BundleProver) Size() uint64 {
+	return p.bundle.TreeSize
+}
+
+// LeafHash returns the hash of the covered leaf at index i
+func (p *BundleProver) LeafHash(i uint64) ([sha256.Size]byte, error) {
+	if !p.covered[i] {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: %d", ErrNotCovered, i)
+	}
+	return p.nodes[NodeID{Index: i}], nil
+}
+
+// InclusionProof returns the proof of the covered leaf at index i against the
+// root of the bundle. Leaves the bundle was not extracted for fail with
+// ErrNotCovered, even when their proof happens to be computable.
+func (p *BundleProver) InclusionProof(i uint64) (InclusionProof, error) {
+	if !p.covered[i] {
+		return InclusionProof{}, fmt.Errorf("%w: %d", ErrNotCovered, i)
+	}
+	steps := inclusionSteps(i, 0, p.bundle.TreeSize)
+	proof := InclusionProof{LeafIndex: i, TreeSize: p.bundle.TreeSize, Hashes: make([][sha256.Size]byte, len(steps))}
+	for j, step := range steps {
+		start, end, _ := step.sibling.leafRange(p.bundle.TreeSize)
+		hash, err := p.rangeHash(start, end)
+		if err != nil {
+			return InclusionProof{}, err
+		}
+		proof.Hashes[j] = hash
+	}
+	return proof, nil
+}
+
+// rangeHash returns the hash of the leaves [start, end), from the bundle node
+// covering them or from its children
+func (p *BundleProver) rangeHash(start, end uint64) ([sha256.Size]byte, error) {
+	id := rangeNode(start, end)
+	if hash, ok := p.nodes[id]; ok {
+		return hash, nil
+	}
+	if end-start == 1 {
+		return [sha256.Size]byte{}, &MissingNodeError{Node: id}
+	}
+
+	k := SplitPoint(end - start)
+	left, err := p.rangeHash(start, start+k)
+	if err != nil {
+		return left, err
+	}
+	right, err := p.rangeHash(start+k, end)
+	if err != nil {
+		return right, err
+	}
+	hash := nodeHash(append(left[:], right[:]...))
+	p.nodes[id] = hash
+	return hash, nil
+}
+
+// MarshalBinary encodes b as the big endian uint64 tree size, the root, the
+// uint64 count of indices and the indices, and the uint64 count of nodes, each
+// its uint64 level and index and its hash
+func (b NodeBundle) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, b.TreeSize)
+	buf.Write(b.Root[:])
+	binary.Write(&buf, binary.BigEndian, uint64(len(b.Indices)))
+	binary.Write(&buf, binary.BigEndian, b.Indices)
+	binary.Write(&buf, binary.BigEndian, uint64(len(b.Nodes)))
+	for _, n := range b.Nodes {
+		binary.Write(&buf, binary.BigEndian, n.Level)
+		binary.Write(&buf, binary.BigEndian, n.Index)
+		buf.Write(n.Hash[:])
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a bundle encoded by MarshalBinary
+func (b *NodeBundle) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var decoded NodeBundle
+	if err := binary.Read(r, binary.BigEndian, &decoded.TreeSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if n, _ := r.Read(decoded.Root[:]); n != sha256.Size {
+		return fmt.Errorf("%w: bundle root", ErrInvalidProof)
+	}
+	var count uint64
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil || count > uint64(r.Len())/8 {
+		return fmt.Errorf("%w: bad index count", ErrInvalidProof)
+	}
+	decoded.Indices = make([]uint64, count)
+	binary.Read(r, binary.BigEndian, decoded.Indices)
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil || count > uint64(r.Len())/(16+sha256.Size) {
+		return fmt.Errorf("%w: bad node count", ErrInvalidProof)
+	}
+	decoded.Nodes = make([]ProofNode, count)
+	for i := range decoded.Nodes {
+		binary.Read(r, binary.BigEndian, &decoded.Nodes[i].Level)
+		binary.Read(r, binary.BigEndian, &decoded.Nodes[i].Index)
+		r.Read(decoded.Nodes[i].Hash[:])
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidProof, r.Len())
+	}
+
+	*b = decoded
+	return nil
+}
@@ -0,0 +1,90 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractBundle(t *testing.T) {
+	D := makeEntries(37)
+	tree := New(D)
+	indices := []uint64{3, 4, 20, 36, 4, 21}
+
+	b, err := tree.ExtractBundle(indices)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4, 20, 21, 36}, b.Indices)
+	assert.Equal(t, uint64(37), b.TreeSize)
+	assert.Equal(t, tree.MerkleRoot(), b.Root)
+
+	data, err := b.MarshalBinary()
+	assert.NoError(t, err)
+	var decoded NodeBundle
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, b, decoded)
+
+	prover, err := NewBundleProver(decoded)
+	assert.NoError(t, err)
+	for i := uint64(0); i < 37; i++ {
+		proof, err := prover.InclusionProof(i)
+		leaf, leafErr := prover.LeafHash(i)
+		if !containsIndex(b.Indices, i) {
+			assert.ErrorIs(t, err, ErrNotCovered, "index %d", i)
+			assert.ErrorIs(t, leafErr, ErrNotCovered)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, leafErr)
+		assert.Equal(t, leafHash(D[i]), leaf)
+		want, _ := tree.InclusionProofByIndex(i)
+		assert.Equal(t, want, proof)
+		assert.NoError(t, VerifyInclusion(leaf, tree.MerkleRoot(), proof))
+	}
+
+	// Minimal: no node can be computed from the other nodes of the bundle, and every
+	// node is used by a covered proof
+	for j := range b.Nodes {
+		if b.Nodes[j].Level == 0 && containsIndex(b.Indices, b.Nodes[j].Index) {
+			continue
+		}
+		smaller := b
+		smaller.Nodes = append(append([]ProofNode(nil), b.Nodes[:j]...), b.Nodes[j+1:]...)
+		_, err := NewBundleProver(smaller)
+		assert.ErrorIs(t, err, ErrMissingNode, "node %v", b.Nodes[j].NodeID)
+	}
+	// The 5 covered leaves, and the 9 siblings off their paths
+	assert.Len(t, b.Nodes, 5+9)
+}
+
+func TestExtractBundleErrors(t *testing.T) {
+	tree := New(makeEntries(10))
+	_, err := tree.ExtractBundle([]uint64{10})
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	b, err := tree.ExtractBundle([]uint64{2})
+	assert.NoError(t, err)
+	b.Nodes[0].Hash[0] ^= 1
+	_, err = NewBundleProver(b)
+	assert.ErrorIs(t, err, ErrRootMismatch)
+
+	empty, err := New(nil).ExtractBundle(nil)
+	assert.NoError(t, err)
+	prover, err := NewBundleProver(empty)
+	assert.NoError(t, err)
+	_, err = prover.InclusionProof(0)
+	assert.ErrorIs(t, err, ErrNotCovered)
+
+	var decoded NodeBundle
+	data, _ := b.MarshalBinary()
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data[:len(data)-1]), ErrInvalidProof)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(append(data, 0)), ErrInvalidProof)
+}
+
+func containsIndex(indices []uint64, i uint64) bool {
+	for _, j := range indices {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// ProofNode is a hash of a proof together with the node of the tree it is the hash of
+type ProofNode struct {
+	NodeID
+	Hash [sha256.Size]byte
+}
+
+// NodeMismatchError is returned by CheckProofNodes for a proof node whose hash differs
+// from the hash recomputed from the leaves it covers
+type NodeMismatchError struct {
+	Node     NodeID
+	Expected [sha256.Size]byte
+	Actual   [sha256.Size]byte
+}
+
+func (e *NodeMismatchError) Error() string {
+	return fmt.Sprintf("%v: node at level %d, index %d is %x, recomputed %x", ErrInvalidProof, e.Node.Level, e.Node.Index, e.Actual, e.Expected)
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidProof) report true
+func (e *NodeMismatchError) Unwrap() error {
+	return ErrInvalidProof
+}
+
+// InclusionProofNodes returns the audit path for the leaf at index i in the tree of the
+// first n leaves, with every hash annotated with its node in the layout of that tree.
+func (mth *MerkleHashTree) InclusionProofNodes(i, n uint64) ([]ProofNode, error) {
+	defer mth.readLock()()
+
+	proof, err := mth.inclusionProofAtSize(i, n)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := inclusionSteps(i, 0, n)
+	nodes := make([]ProofNode, len(steps))
+	for j, step := range steps {
+		nodes[j] = ProofNode{NodeID: step.sibling, Hash: proof.Hashes[j]}
+	}
+	return nodes, nil
+}
+
+// CheckProofNodes recomputes every node of a proof for the tree of the first size leaves
+// from the leaf hashes it covers, and returns a *NodeMismatchError for the first node
+// whose hash differs. It helps telling which stored node a failing proof was built from.
+func (mth *MerkleHashTree) CheckProofNodes(size uint64, nodes []ProofNode) error {
+	defer mth.readLock()()
+
+	if size > uint64(len(mth.tree[0])) {
+		return fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, size, len(mth.tree[0]))
+	}
+	for _, node := range nodes {
+		start, end, ok := node.leafRange(size)
+		if !ok {
+			return fmt.Errorf("%w: no node at level %d, index %d in a tree of size %d", ErrInvalidProof, node.Level, node.Index, size)
+		}
+
+		expected := treeHash(start, end, func(i uint64) [sha256.Size]byte {
+			return mth.tree[0][i]
+		})
+		if expected != node.Hash {
+			return &NodeMismatchError{Node: node.NodeID, Expected: expected, Actual: node.Hash}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInclusionProofNodes(t *testing.T) {
+	// The 7 leaf tree of TestInclusionProof: b is leaf 1, c leaf 2, f leaf 5 and j
+	// the carried leaf 6, g and h cover leaves [0, 2) and [2, 4), i covers [4, 6),
+	// k covers [0, 4) and l covers [4, 7).
+	D := makeEntries(7)
+	tree := New(D)
+
+	b, c, f, j := NodeID{0, 1}, NodeID{0, 2}, NodeID{0, 5}, NodeID{0, 6}
+	g, h, i := NodeID{1, 0}, NodeID{1, 1}, NodeID{1, 2}
+	k, l := NodeID{2, 0}, NodeID{2, 1}
+
+	expected := map[uint64][]NodeID{
+		0: {b, h, l},
+		3: {c, g, l},
+		4: {f, j, k},
+		6: {i, k},
+	}
+	for index, ids := range expected {
+		nodes, err := tree.InclusionProofNodes(index, 7)
+		assert.NoError(t, err)
+		assert.Len(t, nodes, len(ids))
+
+		path := Path(index, D)
+		for n, node := range nodes {
+			assert.Equal(t, ids[n], node.NodeID, "index %d, node %d", index, n)
+			assert.Equal(t, path[n], node.Hash)
+		}
+		assert.NoError(t, tree.CheckProofNodes(7, nodes))
+	}
+
+	// In the tree of the first 6 leaves, i is the right subtree of the root
+	nodes, err := tree.InclusionProofNodes(0, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, []NodeID{b, h, i}, []NodeID{nodes[0].NodeID, nodes[1].NodeID, nodes[2].NodeID})
+	assert.NoError(t, tree.CheckProofNodes(6, nodes))
+
+	_, err = tree.InclusionProofNodes(7, 7)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestCheckProofNodesReportsCorruptNode(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+
+	// Corrupt the stored node h, which the audit path of leaf 0 is built from
+	tree.tree[1][1][0] ^= 0xff
+	nodes, err := tree.InclusionProofNodes(0, 7)
+	assert.NoError(t, err)
+
+	err = tree.CheckProofNodes(7, nodes)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+	var mismatch *NodeMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, NodeID{1, 1}, mismatch.Node)
+	assert.Equal(t, tree.tree[1][1], mismatch.Actual)
+	assert.Equal(t, MTH(D[2:4]), mismatch.Expected)
+
+	err = tree.CheckProofNodes(7, []ProofNode{{NodeID: NodeID{Level: 1, Index: 3}}})
+	assert.ErrorIs(t, err, ErrInvalidProof)
+}
@@ -0,0 +1,113 @@
+package merkletree
+
+import "runtime"
+
+// Option configures optional behaviour of a MerkleHashTree
+type Option func(*MerkleHashTree)
+
+// Metrics receives notifications about internal events of a merkle hash tree.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	ProofCacheHit()
+	ProofCacheMiss()
+}
+
+// WithLocking makes the tree safe for concurrent use: appends are serialized
+// and exclusive, while roots and proofs, including MerkleRoot, InclusionProof,
+// AduitPath and ConsitencyProof, may be read concurrently with each other.
+// Leaves are hashed before the lock is taken, so readers only wait for an append
+// to link the hashed leaves into the tree.
+func WithLocking() Option {
+	return func(m *MerkleHashTree) {
+		m.locking = true
+	}
+}
+
+// WithProofCache enables a bounded LRU cache of inclusion proofs holding at most n entries
+func WithProofCache(n int) Option {
+	return func(m *MerkleHashTree) {
+		if n > 0 {
+			m.proofCache = newProofCache(n)
+		}
+	}
+}
+
+// WithMetrics reports internal events of the tree to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(m *MerkleHashTree) {
+		m.metrics = metrics
+	}
+}
+
+// WithHashBackend hashes leaves in batches with backend instead of DefaultBackend
+func WithHashBackend(backend HashBackend) Option {
+	return func(m *MerkleHashTree) {
+		m.backend = backend
+	}
+}
+
+// WithParallelism hashes the leaves and interior nodes of large trees on workers
+// goroutines, or on GOMAXPROCS goroutines when workers is not positive, with the
+// backend or Hasher of the tree. Batches of leaves and levels of nodes are split
+// into ranges hashed concurrently, giving the hashes of serial hashing. Batches and
+// levels smaller than 1024 hashes are hashed on the calling goroutine, so that small
+// trees and appends start no goroutines.
+//
+// Leaves are hashed with a ParallelBackend of workers over the backend of the tree.
+// A tree WithHashBackend(ParallelBackend(n)) hashes its leaves on its n goroutines
+// and only splits levels of nodes on workers, so that the two never nest.
+func WithParallelism(workers int) Option {
+	return func(m *MerkleHashTree) {
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		m.parallelism = workers
+	}
+}
+
+// WithMaxLeaves caps the tree at n leaves. Appends that would exceed the cap are
+// rejected with ErrLogFull without applying any of their leaves, as are more than n
+// entries passed to TryNew.
+func WithMaxLeaves(n uint64) Option {
+	return func(m *MerkleHashTree) {
+		m.maxLeaves = n
+	}
+}
+
+// WithSealWhenFull seals the tree as soon as it holds the number of leaves set with WithMaxLeaves
+func WithSealWhenFull() Option {
+	return func(m *MerkleHashTree) {
+		m.sealWhenFull = true
+	}
+}
+
+// WithIndexBoundLeaves makes every leaf hash commit to the index of the leaf, hashing
+// entry d at index i as IndexBoundLeafHash(i, d) instead of SHA-256(0x00 || d), so that
+// an entry cannot be moved to another position of the tree without breaking its proofs.
+func WithIndexBoundLeaves() Option {
+	return func(m *MerkleHashTree) {
+		m.indexBound = true
+	}
+}
+
+// WithDeferredHashing makes Append and TryAppend only buffer copies of the entries,
+// deferring leaf hashing and the update of the tree to the next call reading or
+// modifying the tree, which hashes all buffered entries as a single batch with
+// the tree's HashBackend. As the new root is not known yet, Append and TryAppend
+// return the zero hash instead; read it with MerkleRoot. Capacity and sealing are
+// still checked by Append and TryAppend, so the deferred work itself cannot fail.
+// Trees created from sorted leaf hashes ignore the option, as the order of the
+// leaves has to be checked when they are appended.
+func WithDeferredHashing() Option {
+	return func(m *MerkleHashTree) {
+		m.deferred = true
+	}
+}
+
+// WithNodeDeltas records the nodes created or overwritten by every append, which
+// LastAppendDelta returns until the next append.
+func WithNodeDeltas() Option {
+	return func(m *MerkleHashTree) {
+		m.recordDeltas = true
+	}
+}
@@ -0,0 +1,187 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// ErrConflictingNode is returned when a proof implies a different hash for a node
+// than a previously verified proof. Both proofs cannot be valid for the same tree,
+// so this is evidence of log misbehavior.
+var ErrConflictingNode = errors.New("merkletree: proof conflicts with a previously verified node")
+
+// NodeID identifies the node at the given level and index of a tree, covering the
+// leaves [Index*2^Level, min((Index+1)*2^Level, size)). Nodes on the right edge of a
+// tree are identified by the level matching the height of the subtree they commit to.
+type NodeID struct {
+	Level uint64
+	Index uint64
+}
+
+// leafRange returns the leaves [start, end) covered by the node in a tree of size leaves.
+// ok is false when the node is not part of the canonical layout of that tree.
+func (id NodeID) leafRange(size uint64) (start, end uint64, ok bool) {
+	if id.Level >= 64 || id.Index > (size-1)>>id.Level {
+		return 0, 0, false
+	}
+	start, end = id.RangeCovered()
+	if end > size {
+		end = size
+	}
+	return start, end, start < end && rangeNode(start, end) == id
+}
+
+// rangeNode returns the id of the node covering the leaves [start, end)
+func rangeNode(start, end uint64) NodeID {
+	level := uint64(levels(int(end-start)) - 1)
+	return NodeID{Level: level, Index: start >> level}
+}
+
+// pathStep is a single step of an audit path: the sibling hashed with the current
+// node to give the parent, and whether the sibling is on the left.
+type pathStep struct {
+	sibling NodeID
+	parent  NodeID
+	left    bool
+}
+
+// inclusionSteps returns the steps of the audit path of leaf m within the leaves
+// [start, end), ordered from the leaf towards the root.
+func inclusionSteps(m, start, end uint64) []pathStep {
+	n := end - start
+	if n <= 1 {
+		return nil
+	}
+
+	k := start + SplitPoint(n)
+	parent := rangeNode(start, end)
+	if m < k {
+		steps := inclusionSteps(m, start, k)
+		return append(steps, pathStep{sibling: rangeNode(k, end), parent: parent})
+	}
+	// The left part is always a perfect subtree, the left child of the parent
+	steps := inclusionSteps(m, k, end)
+	return append(steps, pathStep{sibling: parent.Left(), parent: parent, left: true})
+}
+
+// PartialTree accumulates the nodes learned from inclusion proofs verified against
+// a pinned tree head, so that leaves whose audit paths are already covered can be
+// verified without fetching another proof.
+type PartialTree struct {
+	head  TreeHead
+	nodes map[NodeID][sha256.Size]byte
+}
+
+// NewPartialTree returns a partial tree pinned to head
+func NewPartialTree(head TreeHead) *PartialTree {
+	p := &PartialTree{head: head, nodes: make(map[NodeID][sha256.Size]byte)}
+	if head.TreeSize > 0 {
+		p.nodes[rangeNode(0, head.TreeSize)] = head.RootHash
+	}
+	return p
+}
+
+// AddProof verifies the inclusion proof of leafHash at index against the pinned
+// tree head and records the nodes it implies. A proof that does not verify fails
+// with ErrRootMismatch. A proof that verifies but implies a different hash for a
+// node learned from an earlier proof is rejected with ErrConflictingNode.
+func (p *PartialTree) AddProof(leafHash [sha256.Size]byte, index uint64, proof [][sha256.Size]byte) error {
+	if index >= p.head.TreeSize {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, p.head.TreeSize)
+	}
+
+	// The verifier hashes one parent per hash of the audit path, from the leaf up
+	parents := make([][sha256.Size]byte, 0, len(proof))
+	node := func(left, right [sha256.Size]byte) [sha256.Size]byte {
+		parent := verify.NodeHash(left, right)
+		parents = append(parents, parent)
+		return parent
+	}
+	root, err := verify.RootFromInclusionProofWith(node, leafHash, InclusionProof{LeafIndex: index, TreeSize: p.head.TreeSize, Hashes: proof})
+	if err != nil {
+		return err
+	}
+	if root != p.head.RootHash {
+		return ErrRootMismatch
+	}
+
+	implied := map[NodeID][sha256.Size]byte{{Level: 0, Index: index}: leafHash}
+	for i, step := range inclusionSteps(index, 0, p.head.TreeSize) {
+		implied[step.sibling] = proof[i]
+		implied[step.parent] = parents[i]
+	}
+	for id, hash := range implied {
+		if known, ok := p.nodes[id]; ok && known != hash {
+			return fmt.Errorf("%w: level %d, index %d", ErrConflictingNode, id.Level, id.Index)
+		}
+	}
+
+	for id, hash := range implied {
+		p.nodes[id] = hash
+	}
+	return nil
+}
+
+// NodeKnown reports whether the hash of the node at level and index is known,
+// either from a verified proof or by hashing known children.
+func (p *PartialTree) NodeKnown(level, index uint64) bool {
+	_, ok := p.node(NodeID{Level: level, Index: index})
+	return ok
+}
+
+// node returns the hash of id when it is known or can be derived from its children
+func (p *PartialTree) node(id NodeID) ([sha256.Size]byte, bool) {
+	if hash, ok := p.nodes[id]; ok {
+		return hash, true
+	}
+	if id.Level == 0 || id.Level >= 64 {
+		return [sha256.Size]byte{}, false
+	}
+
+	// Only nodes of the canonical layout are derived, see NodeID.
+	start, end, ok := id.leafRange(p.head.TreeSize)
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+
+	k := start + SplitPoint(end-start)
+	left, ok := p.node(rangeNode(start, k))
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	right, ok := p.node(rangeNode(k, end))
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	return nodeHash(append(left[:], right[:]...)), true
+}
+
+// TryVerify checks that leafHash is the leaf at index of the pinned tree using only
+// known nodes. When the audit path is not fully known it returns false along with
+// the nodes that are missing.
+func (p *PartialTree) TryVerify(leafHash [sha256.Size]byte, index uint64) (bool, []NodeID) {
+	if index >= p.head.TreeSize {
+		return false, nil
+	}
+
+	steps := inclusionSteps(index, 0, p.head.TreeSize)
+	proof := make([][sha256.Size]byte, 0, len(steps))
+	var missing []NodeID
+	for _, step := range steps {
+		hash, ok := p.node(step.sibling)
+		if !ok {
+			missing = append(missing, step.sibling)
+			continue
+		}
+		proof = append(proof, hash)
+	}
+	if len(missing) > 0 {
+		return false, missing
+	}
+
+	root, err := verify.RootFromInclusionProof(leafHash, InclusionProof{LeafIndex: index, TreeSize: p.head.TreeSize, Hashes: proof})
+	return err == nil && root == p.head.RootHash, nil
+}
@@ -0,0 +1,86 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialTreeCoverageGrows(t *testing.T) {
+	D := makeEntries(8)
+	head := TreeHead{TreeSize: 8, RootHash: MTH(D)}
+	p := NewPartialTree(head)
+
+	ok, missing := p.TryVerify(leafHash(D[2]), 2)
+	assert.False(t, ok)
+	assert.Equal(t, []NodeID{{0, 3}, {1, 0}, {2, 1}}, missing)
+
+	assert.NoError(t, p.AddProof(leafHash(D[3]), 3, Path(3, D)))
+	assert.True(t, p.NodeKnown(0, 3))
+	assert.True(t, p.NodeKnown(2, 0))
+
+	ok, missing = p.TryVerify(leafHash(D[2]), 2)
+	assert.True(t, ok)
+	assert.Empty(t, missing)
+
+	ok, missing = p.TryVerify(leafHash(D[5]), 2)
+	assert.False(t, ok)
+	assert.Empty(t, missing)
+
+	ok, missing = p.TryVerify(leafHash(D[5]), 5)
+	assert.False(t, ok)
+	assert.Equal(t, []NodeID{{0, 4}, {1, 3}}, missing)
+
+	assert.NoError(t, p.AddProof(leafHash(D[6]), 6, Path(6, D)))
+	assert.False(t, p.NodeKnown(0, 4))
+	ok, missing = p.TryVerify(leafHash(D[5]), 5)
+	assert.False(t, ok)
+	assert.Equal(t, []NodeID{{0, 4}}, missing)
+
+	// Both leaves of (1, 2) become known, so the node is derived from its children.
+	assert.NoError(t, p.AddProof(leafHash(D[5]), 5, Path(5, D)))
+	assert.True(t, p.NodeKnown(1, 2))
+	for i, d := range D {
+		ok, _ = p.TryVerify(leafHash(d), uint64(i))
+		assert.Equal(t, i >= 2, ok, "leaf %d", i)
+	}
+}
+
+func TestPartialTreeRightEdgeNodes(t *testing.T) {
+	D := makeEntries(5)
+	p := NewPartialTree(TreeHead{TreeSize: 5, RootHash: MTH(D)})
+
+	ok, missing := p.TryVerify(leafHash(D[0]), 0)
+	assert.False(t, ok)
+	assert.Equal(t, []NodeID{{0, 1}, {1, 1}, {0, 4}}, missing)
+
+	assert.NoError(t, p.AddProof(leafHash(D[4]), 4, Path(4, D)))
+	assert.True(t, p.NodeKnown(2, 0))
+	ok, _ = p.TryVerify(leafHash(D[4]), 4)
+	assert.True(t, ok)
+}
+
+func TestPartialTreeRejectsConflicts(t *testing.T) {
+	D := makeEntries(8)
+	forked := append([][]byte{}, D...)
+	forked[5] = []byte("forged")
+
+	p := NewPartialTree(TreeHead{TreeSize: 8, RootHash: MTH(D)})
+	assert.ErrorIs(t, p.AddProof(leafHash(D[4]), 4, Path(4, forked)), ErrRootMismatch)
+	assert.ErrorIs(t, p.AddProof(leafHash(D[4]), 4, Path(4, D)[:2]), ErrInvalidProofSize)
+
+	assert.NoError(t, p.AddProof(leafHash(D[5]), 5, Path(5, D)))
+	assert.ErrorIs(t, p.AddProof(leafHash(D[4]), 4, Path(4, forked)), ErrRootMismatch)
+	assert.ErrorIs(t, p.AddProof(leafHash(D[4]), 5, Path(5, D)), ErrRootMismatch)
+
+	// Proofs verifying against the same root only conflict for colliding hashes,
+	// simulated here by a known node forged in place.
+	known := p.nodes[NodeID{Level: 0, Index: 4}]
+	p.nodes[NodeID{Level: 0, Index: 4}] = leafHash([]byte("forged"))
+	assert.ErrorIs(t, p.AddProof(leafHash(D[4]), 4, Path(4, D)), ErrConflictingNode)
+	p.nodes[NodeID{Level: 0, Index: 4}] = known
+
+	// A rejected proof leaves the known nodes untouched.
+	ok, _ := p.TryVerify(leafHash(D[5]), 5)
+	assert.True(t, ok)
+}
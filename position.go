@@ -0,0 +1,52 @@
+package merkletree
+
+// Position is the coordinate of a node, for tooling storing or inspecting nodes:
+// the node at Level and Index. Parent, Sibling, Left, Right and RangeCovered follow
+// the layout of a perfect tree, in which the node covers the leaves
+// [Index·2^Level, (Index+1)·2^Level). A tree of a given size holds the nodes for
+// which ExistsIn reports true: a node on its right edge covers fewer leaves, and
+// is at the level of the subtree it commits to, so a node there may be the child
+// of a node more than a level up.
+type Position = NodeID
+
+// Parent returns the node one level up whose subtree contains this node
+func (id NodeID) Parent() NodeID {
+	return NodeID{Level: id.Level + 1, Index: id.Index >> 1}
+}
+
+// Sibling returns the other child of the parent
+func (id NodeID) Sibling() NodeID {
+	return NodeID{Level: id.Level, Index: id.Index ^ 1}
+}
+
+// Left returns the left child. Leaves, at level 0, have no children and Left
+// panics for them.
+func (id NodeID) Left() NodeID {
+	if id.Level == 0 {
+		panic("merkletree: a leaf has no children")
+	}
+	return NodeID{Level: id.Level - 1, Index: id.Index << 1}
+}
+
+// Right returns the right child. Leaves, at level 0, have no children and Right
+// panics for them.
+func (id NodeID) Right() NodeID {
+	if id.Level == 0 {
+		panic("merkletree: a leaf has no children")
+	}
+	return NodeID{Level: id.Level - 1, Index: id.Index<<1 | 1}
+}
+
+// RangeCovered returns the leaves [start, end) under the node in a perfect tree.
+// In a tree of a given size, an existing node covers the part of them below size.
+func (id NodeID) RangeCovered() (start, end uint64) {
+	start = id.Index << id.Level
+	return start, start + 1<<id.Level
+}
+
+// ExistsIn reports whether the node is part of the canonical layout of a tree of
+// size leaves
+func (id NodeID) ExistsIn(size uint64) bool {
+	_, _, ok := id.leafRange(size)
+	return ok
+}
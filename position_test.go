@@ -0,0 +1,99 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// canonicalNodes returns the nodes of a tree of the leaves [start, end) as defined
+// by the recursion of RFC 6962
+func canonicalNodes(start, end uint64) []NodeID {
+	nodes := []NodeID{rangeNode(start, end)}
+	if end-start > 1 {
+		k := start + SplitPoint(end-start)
+		nodes = append(nodes, canonicalNodes(start, k)...)
+		nodes = append(nodes, canonicalNodes(k, end)...)
+	}
+	return nodes
+}
+
+func TestPosition(t *testing.T) {
+	p := Position{Level: 2, Index: 5}
+	assert.Equal(t, Position{Level: 3, Index: 2}, p.Parent())
+	assert.Equal(t, Position{Level: 2, Index: 4}, p.Sibling())
+	assert.Equal(t, Position{Level: 1, Index: 10}, p.Left())
+	assert.Equal(t, Position{Level: 1, Index: 11}, p.Right())
+	start, end := p.RangeCovered()
+	assert.Equal(t, uint64(20), start)
+	assert.Equal(t, uint64(24), end)
+	assert.Panics(t, func() { Position{Index: 3}.Left() })
+	assert.Panics(t, func() { Position{Index: 3}.Right() })
+
+	// A tree of 5 leaves: leaf 4 is on the right edge, right below the root
+	var existing []Position
+	for level := uint64(0); level < 5; level++ {
+		for index := uint64(0); index < 8; index++ {
+			if p := (Position{Level: level, Index: index}); p.ExistsIn(5) {
+				existing = append(existing, p)
+			}
+		}
+	}
+	assert.Equal(t, []Position{
+		{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4},
+		{1, 0}, {1, 1},
+		{2, 0},
+		{3, 0},
+	}, existing)
+}
+
+func TestPositionExhaustive(t *testing.T) {
+	for size := uint64(1); size <= 16; size++ {
+		want := make(map[NodeID]bool)
+		for _, id := range canonicalNodes(0, size) {
+			want[id] = true
+		}
+		assert.Len(t, want, int(2*size-1))
+
+		for level := uint64(0); level <= 5; level++ {
+			for index := uint64(0); index <= 16; index++ {
+				p := Position{Level: level, Index: index}
+				assert.Equal(t, want[p], p.ExistsIn(size), "node %v in a tree of size %d", p, size)
+				assert.Equal(t, p, p.Sibling().Sibling())
+				if level > 0 {
+					assert.Equal(t, p, p.Left().Parent())
+					assert.Equal(t, p, p.Right().Parent())
+					assert.Equal(t, p.Left().Sibling(), p.Right())
+				}
+				if !p.ExistsIn(size) {
+					continue
+				}
+
+				// An existing node covers leaves below size, and those of its
+				// perfect children below size
+				start, end := p.RangeCovered()
+				assert.Less(t, start, size)
+				if level > 0 {
+					leftStart, leftEnd := p.Left().RangeCovered()
+					rightStart, rightEnd := p.Right().RangeCovered()
+					assert.Equal(t, start, leftStart)
+					assert.Equal(t, leftEnd, rightStart)
+					assert.Equal(t, end, rightEnd)
+					// The left child of an existing interior node always exists
+					assert.True(t, p.Left().ExistsIn(size))
+					assert.Equal(t, end <= size, p.Right().ExistsIn(size) && rightEnd <= size)
+				}
+				// The parent of a node in the tree is its lowest existing ancestor,
+				// whose left child it is or whose right part it covers
+				if p != rangeNode(0, size) {
+					q := p.Parent()
+					for !q.ExistsIn(size) {
+						q = q.Parent()
+					}
+					_, leftEnd := q.Left().RangeCovered()
+					assert.True(t, p == q.Left() || start == leftEnd, "node %v, parent %v in a tree of size %d", p, q, size)
+				}
+			}
+		}
+	}
+}
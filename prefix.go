@@ -0,0 +1,61 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotAPrefix is returned when a tree is not an earlier state of another tree
+var ErrNotAPrefix = errors.New("merkletree: tree is not a prefix of the other tree")
+
+// NotAPrefixError is returned by ConsistencyProofAgainst when the leaves of the older
+// tree are not the first leaves of the current tree. Index is the first leaf at
+// which they differ.
+type NotAPrefixError struct {
+	Index uint64
+}
+
+func (e *NotAPrefixError) Error() string {
+	return fmt.Sprintf("%v: first divergent leaf at index %d", ErrNotAPrefix, e.Index)
+}
+
+// Unwrap makes errors.Is(err, ErrNotAPrefix) report true
+func (e *NotAPrefixError) Unwrap() error {
+	return ErrNotAPrefix
+}
+
+// ConsistencyProofAgainst returns the consistency proof between old, an earlier state
+// of the tree such as one restored from a checkpoint, and the tree, after verifying it
+// against the roots of both trees. When the leaves of old are not the first leaves of
+// the tree, it returns a *NotAPrefixError holding the first leaf where they diverge.
+func (mth *MerkleHashTree) ConsistencyProofAgainst(old *MerkleHashTree) (ConsistencyProof, error) {
+	if old == mth {
+		size := mth.Size()
+		return ConsistencyProof{OldSize: size, NewSize: size, Hashes: make([][sha256.Size]byte, 0)}, nil
+	}
+
+	defer old.readLock()()
+	defer mth.readLock()()
+
+	m, n := uint64(len(old.tree[0])), uint64(len(mth.tree[0]))
+	if m > n {
+		return ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	proof := ConsistencyProof{OldSize: m, NewSize: n, Hashes: make([][sha256.Size]byte, 0)}
+	if m > 0 && m < n {
+		proof.Hashes = mth.consistencyProof(m, n)
+	}
+	if VerifyConsistency(old.root(), mth.root(), proof) == nil {
+		return proof, nil
+	}
+
+	// Trees of the same first k leaves have the same root at size k, so the first
+	// divergent leaf is found by binary search over the roots of prefixes.
+	i := sort.Search(int(m), func(k int) bool {
+		return old.mthOfRange(0, k) != mth.mthOfRange(0, k)
+	})
+	return ConsistencyProof{}, &NotAPrefixError{Index: uint64(i)}
+}
@@ -0,0 +1,57 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistencyProofAgainst(t *testing.T) {
+	D := makeEntries(20)
+	cur := New(D)
+	for m := 0; m <= len(D); m++ {
+		old := New(D[:m])
+		proof, err := cur.ConsistencyProofAgainst(old)
+		assert.NoError(t, err, "m=%d", m)
+		assert.Equal(t, uint64(m), proof.OldSize)
+		assert.Equal(t, uint64(len(D)), proof.NewSize)
+		assert.NoError(t, VerifyConsistency(old.MerkleRoot(), cur.MerkleRoot(), proof))
+	}
+
+	proof, err := cur.ConsistencyProofAgainst(cur)
+	assert.NoError(t, err)
+	assert.Empty(t, proof.Hashes)
+
+	_, err = New(D[:5]).ConsistencyProofAgainst(cur)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestConsistencyProofAgainstDivergedHistory(t *testing.T) {
+	D := makeEntries(20)
+	cur := New(D, WithLocking())
+
+	for _, diverged := range []int{0, 5, 8, 12} {
+		forked := append([][]byte{}, D[:13]...)
+		forked[diverged] = []byte("rewritten")
+		old := New(forked, WithLocking())
+
+		_, err := cur.ConsistencyProofAgainst(old)
+		assert.ErrorIs(t, err, ErrNotAPrefix)
+		var notPrefix *NotAPrefixError
+		assert.ErrorAs(t, err, &notPrefix)
+		assert.Equal(t, uint64(diverged), notPrefix.Index)
+	}
+
+	// Equal sizes with different leaves
+	forked := append([][]byte{}, D...)
+	forked[19] = []byte("rewritten")
+	_, err := cur.ConsistencyProofAgainst(New(forked))
+	var notPrefix *NotAPrefixError
+	assert.ErrorAs(t, err, &notPrefix)
+	assert.Equal(t, uint64(19), notPrefix.Index)
+
+	proof, err := cur.ConsistencyProofAgainst(New(D))
+	assert.NoError(t, err)
+	assert.Equal(t, ConsistencyProof{OldSize: 20, NewSize: 20, Hashes: proof.Hashes}, proof)
+	assert.Empty(t, proof.Hashes)
+}
@@ -0,0 +1,120 @@
+package merkletree
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Defaults of Fprint
+const (
+	defaultPrintDepth = 6
+	defaultPrintWidth = 120
+	// printHashWidth is the width of a node printed as its first two bytes in hex
+	printHashWidth = 4
+	printGap       = 2
+)
+
+type printConfig struct {
+	depth int
+	width int
+}
+
+// PrintOption configures Fprint
+type PrintOption func(*printConfig)
+
+// WithPrintDepth draws the top n levels of the tree, and lists the levels below
+// them by their number of nodes and first and last node
+func WithPrintDepth(n int) PrintOption {
+	return func(c *printConfig) {
+		c.depth = n
+	}
+}
+
+// WithPrintWidth truncates lines to n columns
+func WithPrintWidth(n int) PrintOption {
+	return func(c *printConfig) {
+		c.width = n
+	}
+}
+
+// Print prints the merkle hash tree to standard output
+func (m *MerkleHashTree) Print() {
+	m.Fprint(os.Stdout)
+}
+
+// String returns the merkle hash tree rendered by Fprint with the default options
+func (m *MerkleHashTree) String() string {
+	var b strings.Builder
+	m.Fprint(&b)
+	return b.String()
+}
+
+// Fprint writes the merkle hash tree to w, the root first. Nodes are printed as
+// their first two bytes in hex, below the root as a drawing of the top levels,
+// 6 unless set WithPrintDepth, and then a line per deeper level such as
+// "level 27: 3 nodes, first=1a2b, last=3c4d". Lines are truncated to 120 columns
+// unless set WithPrintWidth, so the output stays small however deep the tree.
+func (m *MerkleHashTree) Fprint(w io.Writer, opts ...PrintOption) error {
+	c := printConfig{depth: defaultPrintDepth, width: defaultPrintWidth}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.depth < 1 {
+		c.depth = 1
+	}
+
+	defer m.readLock()()
+	top := len(m.tree) - 1
+	bottom := top - c.depth + 1
+	if bottom < 0 {
+		bottom = 0
+	}
+
+	cell := printHashWidth + printGap
+	for i := top; i >= bottom; i-- {
+		// A node at i covers 2^(i-bottom) cells of the bottom drawn level, and is
+		// printed centered over them. Padding is capped to the line width, which
+		// keeps drawing deep levels WithPrintDepth cheap.
+		span := 1 << minInt(i-bottom, 30)
+		var line strings.Builder
+		line.WriteString(strings.Repeat(" ", minInt((span-1)*cell/2, c.width)))
+		for _, v := range m.tree[i] {
+			if line.Len() >= c.width {
+				break
+			}
+			fmt.Fprintf(&line, "%.2x%s", v, strings.Repeat(" ", minInt(span*cell-printHashWidth, c.width)))
+		}
+		if _, err := fmt.Fprintln(w, truncateLine(strings.TrimRight(line.String(), " "), c.width)); err != nil {
+			return err
+		}
+	}
+
+	for i := bottom - 1; i >= 0; i-- {
+		level := m.tree[i]
+		line := fmt.Sprintf("level %d: %d nodes", i, len(level))
+		if len(level) > 0 {
+			line += fmt.Sprintf(", first=%.2x, last=%.2x", level[0], level[len(level)-1])
+		}
+		if _, err := fmt.Fprintln(w, truncateLine(line, c.width)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateLine cuts line to width columns, ending it with "..." when cut
+func truncateLine(line string, width int) string {
+	if width <= 3 || len(line) <= width {
+		return line
+	}
+	return line[:width-3] + "..."
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,63 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFprint(t *testing.T) {
+	tree := New(makeEntries(4))
+	var b strings.Builder
+	assert.NoError(t, tree.Fprint(&b))
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "         "+hexPrefix(tree.MerkleRoot()), lines[0])
+	assert.Equal(t, hexPrefix(tree.tree[0][0])+"  "+hexPrefix(tree.tree[0][1]), lines[2][:10])
+	assert.Equal(t, b.String(), tree.String())
+
+	b.Reset()
+	assert.NoError(t, tree.Fprint(&b, WithPrintDepth(1)))
+	assert.Equal(t, hexPrefix(tree.MerkleRoot())+"\n"+
+		"level 1: 2 nodes, first="+hexPrefix(tree.tree[1][0])+", last="+hexPrefix(tree.tree[1][1])+"\n"+
+		"level 0: 4 nodes, first="+hexPrefix(tree.tree[0][0])+", last="+hexPrefix(tree.tree[0][3])+"\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, New(makeEntries(64)).Fprint(&b, WithPrintWidth(40)))
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		assert.LessOrEqual(t, len(line), 40)
+	}
+	assert.Contains(t, b.String(), "...")
+}
+
+func TestFprintDeepTree(t *testing.T) {
+	// A synthetic tree of 40 levels, only keeping a few nodes per level
+	tree := &MerkleHashTree{tree: make([][][sha256.Size]byte, 40)}
+	for i := range tree.tree {
+		nodes := 1 << minInt(39-i, 3)
+		for j := 0; j < nodes; j++ {
+			tree.tree[i] = append(tree.tree[i], sha256.Sum256([]byte{byte(i), byte(j)}))
+		}
+	}
+
+	start := time.Now()
+	for _, depth := range []int{1, 6, 40} {
+		var b strings.Builder
+		assert.NoError(t, tree.Fprint(&b, WithPrintDepth(depth)))
+		assert.Equal(t, 40, strings.Count(b.String(), "\n"))
+		assert.Less(t, b.Len(), 40*(defaultPrintWidth+1))
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	var b strings.Builder
+	assert.NoError(t, tree.Fprint(&b))
+	assert.Contains(t, b.String(), "level 27: 8 nodes, first="+hexPrefix(tree.tree[27][0])+", last="+hexPrefix(tree.tree[27][7])+"\n")
+}
+
+func hexPrefix(h [sha256.Size]byte) string {
+	return fmt.Sprintf("%.2x", h)
+}
@@ -0,0 +1,250 @@
+package merkletree
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors returned by pending queues
+var (
+	ErrNotMerged      = errors.New("merkletree: entry is not merged yet")
+	ErrUnknownPromise = errors.New("merkletree: unknown promise")
+)
+
+// PromiseID identifies a submitted entry: its RFC 6962 leaf hash, so submitting
+// the same entry again yields the same promise
+type PromiseID [sha256.Size]byte
+
+// Promise acknowledges a submitted entry before it is merged into the tree, like
+// the signed certificate timestamp of a CT log. Signature is set when the queue
+// has a signer, over PromiseMessage.
+type Promise struct {
+	ID        PromiseID
+	Timestamp time.Time
+	Signature []byte
+}
+
+// PromiseMessage returns the message a promise is signed over: EncodeLeafFields of
+// the ID and of the big endian uint64 timestamp in milliseconds since the epoch
+func PromiseMessage(p Promise) []byte {
+	timestamp := binary.BigEndian.AppendUint64(nil, uint64(p.Timestamp.UnixMilli()))
+	return EncodeLeafFields(p.ID[:], timestamp)
+}
+
+// VerifyPromise checks the signature of p by pub, an *ecdsa.PublicKey or an
+// ed25519.PublicKey
+func VerifyPromise(p Promise, pub crypto.PublicKey) error {
+	if !verifyNoteSignature(pub, PromiseMessage(p), p.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Submission is a pending entry with the promise made for it
+type Submission struct {
+	Promise Promise
+	Data    []byte
+}
+
+// PendingStore persists the pending entries of a PendingQueue so they survive a
+// restart. Load returns the entries pending when the queue is created, Add
+// persists a submission before its promise is returned, and Remove drops the
+// submissions of merged entries.
+type PendingStore interface {
+	Load() ([]Submission, error)
+	Add(s Submission) error
+	Remove(ids []PromiseID) error
+}
+
+// NotMergedError is returned when proving an entry that is still pending
+type NotMergedError struct {
+	Promise Promise
+}
+
+func (e *NotMergedError) Error() string {
+	return fmt.Sprintf("%v: promise %x from %v", ErrNotMerged, e.Promise.ID, e.Promise.Timestamp)
+}
+
+// Unwrap makes errors.Is(err, ErrNotMerged) report true
+func (e *NotMergedError) Unwrap() error {
+	return ErrNotMerged
+}
+
+// PendingQueueOption configures a PendingQueue
+type PendingQueueOption func(*PendingQueue)
+
+// WithPromiseSigner signs promises with signer, an ECDSA or Ed25519 key
+func WithPromiseSigner(signer crypto.Signer) PendingQueueOption {
+	return func(q *PendingQueue) {
+		q.signer = signer
+	}
+}
+
+// WithPendingStore persists pending entries to store
+func WithPendingStore(store PendingStore) PendingQueueOption {
+	return func(q *PendingQueue) {
+		q.store = store
+	}
+}
+
+// PendingQueue acknowledges submitted entries with a promise right away and
+// appends them to a tree later, on Merge, in the order they were submitted.
+// Entries are deduplicated: submitting an entry that is pending, or was merged by
+// the queue, returns its promise again. It is safe for concurrent use.
+type PendingQueue struct {
+	tree   *MerkleHashTree
+	signer crypto.Signer
+	store  PendingStore
+	clock  func() time.Time
+
+	mu       sync.Mutex
+	pending  []Submission
+	promises map[PromiseID]Promise
+	merged   map[PromiseID]uint64
+}
+
+// NewPendingQueue returns a queue merging into tree. With a PendingStore, the
+// entries it loads are pending again, except those already in the tree.
+func NewPendingQueue(tree *MerkleHashTree, opts ...PendingQueueOption) (*PendingQueue, error) {
+	q := &PendingQueue{
+		tree:     tree,
+		clock:    time.Now,
+		promises: make(map[PromiseID]Promise),
+		merged:   make(map[PromiseID]uint64),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if q.store == nil {
+		return q, nil
+	}
+
+	loaded, err := q.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	var done []PromiseID
+	for _, s := range loaded {
+		// Entries merged before the store could drop them are not merged twice
+		if i, err := tree.LeafIndex(s.Promise.ID); err == nil {
+			q.merged[s.Promise.ID] = i
+			done = append(done, s.Promise.ID)
+		} else {
+			q.pending = append(q.pending, s)
+		}
+		q.promises[s.Promise.ID] = s.Promise
+	}
+	if len(done) > 0 {
+		if err := q.store.Remove(done); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Submit queues d to be merged and returns the promise for it
+func (q *PendingQueue) Submit(d []byte) (Promise, error) {
+	id := PromiseID(leafHash(d))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if p, ok := q.promises[id]; ok {
+		return p, nil
+	}
+
+	p := Promise{ID: id, Timestamp: q.clock()}
+	if q.signer != nil {
+		sig, err := signNote(q.signer, PromiseMessage(p))
+		if err != nil {
+			return Promise{}, err
+		}
+		p.Signature = sig
+	}
+	s := Submission{Promise: p, Data: append([]byte(nil), d...)}
+	if q.store != nil {
+		if err := q.store.Add(s); err != nil {
+			return Promise{}, err
+		}
+	}
+
+	q.pending = append(q.pending, s)
+	q.promises[id] = p
+	return p, nil
+}
+
+// Pending returns the number of entries waiting to be merged
+func (q *PendingQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Merge appends the pending entries to the tree in submission order, as a single
+// batch, and returns the new root. When the tree rejects the batch the entries
+// stay pending.
+func (q *PendingQueue) Merge() ([sha256.Size]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return q.tree.MerkleRoot(), nil
+	}
+	d := make([][]byte, len(q.pending))
+	for i, s := range q.pending {
+		d[i] = s.Data
+	}
+	hash := q.tree.leafHasher(d)
+
+	unlock := q.tree.writeLock()
+	first := uint64(len(q.tree.tree[0]))
+	leaves, err := hash(first)
+	var root [sha256.Size]byte
+	if err == nil {
+		root, err = q.tree.admitLeafHashes(leaves)
+	}
+	unlock()
+	if err != nil {
+		return root, err
+	}
+
+	ids := make([]PromiseID, len(q.pending))
+	for i, s := range q.pending {
+		ids[i] = s.Promise.ID
+		q.merged[s.Promise.ID] = first + uint64(i)
+	}
+	q.pending = nil
+	if q.store != nil {
+		if err := q.store.Remove(ids); err != nil {
+			return root, err
+		}
+	}
+	return root, nil
+}
+
+// LeafIndexOf returns the index of the merged entry of the promise id
+func (q *PendingQueue) LeafIndexOf(id PromiseID) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, ok := q.merged[id]; ok {
+		return i, nil
+	}
+	if p, ok := q.promises[id]; ok {
+		return 0, &NotMergedError{Promise: p}
+	}
+	return 0, fmt.Errorf("%w: %x", ErrUnknownPromise, id)
+}
+
+// ProveByPromise returns the inclusion proof of the entry of the promise id in
+// the current tree, or a *NotMergedError while it is pending
+func (q *PendingQueue) ProveByPromise(id PromiseID) (InclusionProof, error) {
+	i, err := q.LeafIndexOf(id)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return q.tree.InclusionProofByIndex(i)
+}
@@ -0,0 +1,157 @@
+package merkletree
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is a PendingStore keeping submissions in memory, as a file would
+type memoryStore struct {
+	submissions []Submission
+	failRemove  bool
+}
+
+func (s *memoryStore) Load() ([]Submission, error) {
+	return append([]Submission(nil), s.submissions...), nil
+}
+
+func (s *memoryStore) Add(sub Submission) error {
+	s.submissions = append(s.submissions, sub)
+	return nil
+}
+
+func (s *memoryStore) Remove(ids []PromiseID) error {
+	if s.failRemove {
+		return errors.New("disk full")
+	}
+	removed := make(map[PromiseID]bool)
+	for _, id := range ids {
+		removed[id] = true
+	}
+	kept := s.submissions[:0]
+	for _, sub := range s.submissions {
+		if !removed[sub.Promise.ID] {
+			kept = append(kept, sub)
+		}
+	}
+	s.submissions = kept
+	return nil
+}
+
+func TestPendingQueueProveByPromise(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	D := makeEntries(6)
+	tree := New(D[:2])
+	q, err := NewPendingQueue(tree, WithPromiseSigner(key))
+	assert.NoError(t, err)
+	now := time.UnixMilli(1700000000000)
+	q.clock = func() time.Time { return now }
+
+	promises := make([]Promise, 0, 4)
+	for _, d := range D[2:] {
+		p, err := q.Submit(d)
+		assert.NoError(t, err)
+		assert.Equal(t, PromiseID(leafHash(d)), p.ID)
+		assert.Equal(t, now, p.Timestamp)
+		assert.NoError(t, VerifyPromise(p, key.Public()))
+		promises = append(promises, p)
+	}
+
+	// Duplicate submissions get the same promise and are merged once
+	now = now.Add(time.Second)
+	again, err := q.Submit(D[3])
+	assert.NoError(t, err)
+	assert.Equal(t, promises[1], again)
+	assert.Equal(t, 4, q.Pending())
+
+	_, err = q.ProveByPromise(promises[0].ID)
+	assert.ErrorIs(t, err, ErrNotMerged)
+	var notMerged *NotMergedError
+	assert.True(t, errors.As(err, &notMerged))
+	assert.Equal(t, promises[0], notMerged.Promise)
+	_, err = q.ProveByPromise(PromiseID{})
+	assert.ErrorIs(t, err, ErrUnknownPromise)
+
+	root, err := q.Merge()
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), root)
+	assert.Equal(t, 0, q.Pending())
+	for i, p := range promises {
+		index, err := q.LeafIndexOf(p.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i+2), index)
+		proof, err := q.ProveByPromise(p.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i+2]), root, proof))
+	}
+
+	again, err = q.Submit(D[5])
+	assert.NoError(t, err)
+	assert.Equal(t, promises[3], again)
+	assert.Equal(t, 0, q.Pending())
+	root, err = q.Merge()
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), root)
+
+	tampered := promises[0]
+	tampered.Timestamp = tampered.Timestamp.Add(time.Millisecond)
+	assert.ErrorIs(t, VerifyPromise(tampered, key.Public()), ErrInvalidSignature)
+}
+
+func TestPendingQueueRestart(t *testing.T) {
+	D := makeEntries(5)
+	tree := New(nil)
+	store := &memoryStore{}
+	q, err := NewPendingQueue(tree, WithPendingStore(store))
+	assert.NoError(t, err)
+	for _, d := range D[:3] {
+		_, err := q.Submit(d)
+		assert.NoError(t, err)
+	}
+
+	// The pending entries survive a restart and merge in submission order
+	q, err = NewPendingQueue(tree, WithPendingStore(store))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, q.Pending())
+	_, err = q.ProveByPromise(PromiseID(leafHash(D[1])))
+	assert.ErrorIs(t, err, ErrNotMerged)
+	_, err = q.Submit(D[3])
+	assert.NoError(t, err)
+
+	// A crash after merging but before the store dropped the entries does not
+	// merge them twice
+	store.failRemove = true
+	_, err = q.Merge()
+	assert.Error(t, err)
+	assert.Len(t, store.submissions, 4)
+	store.failRemove = false
+
+	q, err = NewPendingQueue(tree, WithPendingStore(store))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, q.Pending())
+	assert.Empty(t, store.submissions)
+	index, err := q.LeafIndexOf(PromiseID(leafHash(D[2])))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), index)
+	assert.Equal(t, MTH(D[:4]), tree.MerkleRoot())
+}
+
+func TestPendingQueueRejectedMerge(t *testing.T) {
+	tree := New(nil, WithMaxLeaves(2))
+	q, err := NewPendingQueue(tree)
+	assert.NoError(t, err)
+	for _, d := range makeEntries(3) {
+		_, err := q.Submit(d)
+		assert.NoError(t, err)
+	}
+	_, err = q.Merge()
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.Equal(t, 3, q.Pending())
+	assert.Equal(t, uint64(0), tree.Size())
+}
@@ -0,0 +1,212 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// Errors returned by the proof APIs
+var (
+	ErrEmptyTree        = errors.New("merkletree: tree is empty")
+	ErrIndexOutOfRange  = verify.ErrIndexOutOfRange
+	ErrInvalidProof     = verify.ErrInvalidProof
+	ErrRootMismatch     = verify.ErrRootMismatch
+	ErrInvalidProofSize = verify.ErrInvalidProofSize
+	ErrInvalidRange     = verify.ErrInvalidRange
+	ErrLeafNotFound     = errors.New("merkletree: leaf not found")
+)
+
+// InclusionProof is a Merkle audit path for the leaf at LeafIndex in the tree of the
+// first TreeSize leaves. Hashes are ordered from the leaf towards the root.
+type InclusionProof = verify.InclusionProof
+
+// DigestInclusionProof is an InclusionProof over digests of any size, see Hasher
+type DigestInclusionProof = verify.DigestInclusionProof
+
+// DigestConsistencyProof is a ConsistencyProof over digests of any size, see Hasher
+type DigestConsistencyProof = verify.DigestConsistencyProof
+
+// ConsistencyProof is a Merkle consistency proof between the tree of the first
+// OldSize leaves and the tree of the first NewSize leaves.
+type ConsistencyProof = verify.ConsistencyProof
+
+// InclusionProofByIndex returns the audit path for the leaf at index i, or
+// ErrIndexOutOfRange. Unlike looking the leaf up by its entry, it proves every
+// leaf of an entry appended more than once.
+func (mth *MerkleHashTree) InclusionProofByIndex(i uint64) (InclusionProof, error) {
+	defer mth.readLock()()
+	return mth.inclusionProofAtSize(i, uint64(len(mth.tree[0])))
+}
+
+// InclusionProofAtSize returns the audit path for the leaf at index i in the tree of
+// the first n leaves, as the tree_size parameter of get-proof-by-hash in RFC 6962,
+// computed from the stored nodes. The proof verifies against the root of that
+// tree, whatever was appended since. It fails with ErrIndexOutOfRange when i >= n
+// or n is larger than the tree.
+func (mth *MerkleHashTree) InclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	defer mth.readLock()()
+	return mth.inclusionProofAtSize(i, n)
+}
+
+func (mth *MerkleHashTree) inclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	if i >= n || n > uint64(len(mth.tree[0])) {
+		return InclusionProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, n)
+	}
+
+	hashes := mth.cachedProof(proofCacheKey{index: i, size: n}, func() [][sha256.Size]byte {
+		return mth.auditPath(int(i), 0, int(n-1))
+	})
+	return InclusionProof{Algorithm: mth.algorithm(), LeafIndex: i, TreeSize: n, Hashes: hashes}, nil
+}
+
+// ConsistencyProof returns the consistency proof between the trees of the first m and n leaves
+func (mth *MerkleHashTree) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	defer mth.readLock()()
+
+	if m > n || n > uint64(len(mth.tree[0])) {
+		return ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	proof := ConsistencyProof{Algorithm: mth.algorithm(), OldSize: m, NewSize: n, Hashes: make([][sha256.Size]byte, 0)}
+	if m > 0 {
+		proof.Hashes = mth.consistencyProof(m, n)
+	}
+	return proof, nil
+}
+
+// LeafHash returns the hash of the leaf at index i
+func (mth *MerkleHashTree) LeafHash(i uint64) ([sha256.Size]byte, error) {
+	defer mth.readLock()()
+
+	if i >= uint64(len(mth.tree[0])) {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, len(mth.tree[0]))
+	}
+	return mth.tree[0][i], nil
+}
+
+// LeafIndex returns the index of the first leaf with the given leaf hash
+func (mth *MerkleHashTree) LeafIndex(leafHash [sha256.Size]byte) (uint64, error) {
+	defer mth.readLock()()
+
+	i := mth.leafIndex(leafHash)
+	if i < 0 {
+		return 0, ErrLeafNotFound
+	}
+	return uint64(i), nil
+}
+
+// ProofOfLatest returns the audit path for the most recently appended leaf.
+// The path of the last leaf only consists of the complete left subtrees along
+// the right edge of the tree, so it is read directly from the stored levels
+// without walking the tree.
+func (mth *MerkleHashTree) ProofOfLatest() (InclusionProof, error) {
+	defer mth.readLock()()
+	return mth.proofOfLatest()
+}
+
+func (mth *MerkleHashTree) proofOfLatest() (InclusionProof, error) {
+	n := uint64(len(mth.tree[0]))
+	if n == 0 {
+		return InclusionProof{}, ErrEmptyTree
+	}
+
+	hashes := mth.cachedProof(proofCacheKey{index: n - 1, size: n}, func() [][sha256.Size]byte {
+		hashes := make([][sha256.Size]byte, 0, len(mth.tree)-1)
+		index := n - 1
+		for level := 0; level < len(mth.tree)-1; level++ {
+			// A right child always has a complete left sibling. A left child on the
+			// right edge has no sibling and is carried up unchanged.
+			if index%2 == 1 {
+				hashes = append(hashes, mth.tree[level][index-1])
+			}
+			index = index / 2
+		}
+		return hashes
+	})
+
+	return InclusionProof{Algorithm: mth.algorithm(), LeafIndex: n - 1, TreeSize: n, Hashes: hashes}, nil
+}
+
+// cachedProof returns the audit path for key from the proof cache when enabled,
+// generating and caching it on a miss.
+func (mth *MerkleHashTree) cachedProof(key proofCacheKey, generate func() [][sha256.Size]byte) [][sha256.Size]byte {
+	if mth.proofCache == nil {
+		return generate()
+	}
+
+	if hashes, ok := mth.proofCache.get(key); ok {
+		if mth.metrics != nil {
+			mth.metrics.ProofCacheHit()
+		}
+		return hashes
+	}
+
+	if mth.metrics != nil {
+		mth.metrics.ProofCacheMiss()
+	}
+	hashes := generate()
+	mth.proofCache.add(key, hashes)
+	return hashes
+}
+
+// AppendWithProof appends d to the tree and returns the consistency proof from
+// the previous tree size to the new one, along with the inclusion proof of the
+// last leaf, which serves as a receipt for the appended entries.
+func (mth *MerkleHashTree) AppendWithProof(d ...[]byte) (ConsistencyProof, InclusionProof, error) {
+	hash := mth.leafHasher(d)
+
+	defer mth.writeLock()()
+	oldSize := uint64(len(mth.tree[0]))
+	leaves, err := hash(oldSize)
+	if err != nil {
+		return ConsistencyProof{}, InclusionProof{}, err
+	}
+	if _, err := mth.admitLeafHashes(leaves); err != nil {
+		return ConsistencyProof{}, InclusionProof{}, err
+	}
+	newSize := uint64(len(mth.tree[0]))
+
+	consistency := ConsistencyProof{Algorithm: mth.algorithm(), OldSize: oldSize, NewSize: newSize, Hashes: make([][sha256.Size]byte, 0)}
+	if oldSize > 0 {
+		consistency.Hashes = mth.consistencyProof(oldSize, newSize)
+	}
+
+	latest, err := mth.proofOfLatest()
+	if err != nil {
+		return ConsistencyProof{}, InclusionProof{}, err
+	}
+	return consistency, latest, nil
+}
+
+// VerifyInclusion checks that leafHash is included in the tree with the given root
+// at the position and tree size described by p.
+func VerifyInclusion(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+	return verify.VerifyInclusion(leafHash, root, p)
+}
+
+// VerifyInclusionProof reports whether path, as returned by Path, is the audit path
+// of the leaf with data leaf at leafIndex in the tree of treeSize leaves with the
+// given root. Paths of the wrong length for the index and size are rejected, see
+// VerifyInclusion for the reason.
+func VerifyInclusionProof(leafIndex, treeSize uint64, leaf []byte, path [][sha256.Size]byte, root [sha256.Size]byte) bool {
+	p := InclusionProof{LeafIndex: leafIndex, TreeSize: treeSize, Hashes: path}
+	return VerifyInclusion(leafHash(leaf), root, p) == nil
+}
+
+// VerifyConsistency checks that the tree with root newRoot is an append-only extension
+// of the tree with root oldRoot, using the consistency proof p between their sizes.
+func VerifyConsistency(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error {
+	return verify.VerifyConsistency(oldRoot, newRoot, p)
+}
+
+// VerifyConsistencyProof checks that proof, as returned by Proof or ConsitencyProof,
+// proves that the tree with root newRoot of the first n leaves extends the tree
+// with root oldRoot of the first m leaves. It fails with ErrInvalidProofSize when
+// the proof has the wrong number of hashes for m and n, and with ErrRootMismatch,
+// naming the root, when the old or the new root is not the one reconstructed.
+func VerifyConsistencyProof(m, n uint64, oldRoot, newRoot [sha256.Size]byte, proof [][sha256.Size]byte) error {
+	return VerifyConsistency(oldRoot, newRoot, ConsistencyProof{OldSize: m, NewSize: n, Hashes: proof})
+}
@@ -0,0 +1,313 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInclusionProofByIndex(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+
+	for i := range D {
+		proof, err := tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, Path(uint64(i), D), proof.Hashes)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i]), MTH(D), proof))
+	}
+
+	_, err := tree.InclusionProofByIndex(7)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestInclusionProofAtSizeAfterGrowth(t *testing.T) {
+	D := makeEntries(16)
+	tree := New(D[:7])
+	root := tree.MerkleRoot()
+	proofs := make([]InclusionProof, 7)
+	for i := range proofs {
+		var err error
+		proofs[i], err = tree.InclusionProofAtSize(uint64(i), 7)
+		assert.NoError(t, err)
+	}
+
+	for _, d := range D[7:] {
+		tree.Append(d)
+	}
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+	for i := range proofs {
+		p, err := tree.InclusionProofAtSize(uint64(i), 7)
+		assert.NoError(t, err)
+		assert.Equal(t, proofs[i], p)
+		assert.Equal(t, Path(uint64(i), D[:7]), p.Hashes)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i]), root, p), "leaf %d", i)
+		assert.Error(t, VerifyInclusion(leafHash(D[i]), tree.MerkleRoot(), p), "leaf %d", i)
+	}
+
+	_, err := tree.InclusionProofAtSize(7, 7)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = tree.InclusionProofAtSize(3, 17)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestInclusionProofByIndexDuplicateLeaves(t *testing.T) {
+	D := makeEntries(5)
+	D = append(D, D[1], D[3], D[1])
+	tree := New(D)
+	root := MTH(D)
+
+	// Looking up the entry only ever proves its first leaf
+	assert.Equal(t, Path(1, D), tree.InclusionProof(D[1]))
+	for _, i := range []uint64{1, 5, 7} {
+		proof, err := tree.InclusionProofByIndex(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, proof.LeafIndex)
+		assert.NoError(t, VerifyInclusion(leafHash(D[1]), root, proof))
+	}
+	first, _ := tree.InclusionProofByIndex(1)
+	last, _ := tree.InclusionProofByIndex(7)
+	assert.NotEqual(t, first.Hashes, last.Hashes)
+
+	// A proof of one duplicate does not verify at the index of another
+	last.LeafIndex = 5
+	assert.Error(t, VerifyInclusion(leafHash(D[1]), root, last))
+}
+
+func TestProofErrors(t *testing.T) {
+	empty := New(nil)
+	assert.Equal(t, sha256.Sum256(nil), empty.MerkleRoot())
+	assert.Equal(t, TreeHead{RootHash: sha256.Sum256(nil)}, empty.TreeHead())
+	_, err := empty.InclusionProofOfEntry([]byte("d0"))
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+	_, err = empty.InclusionProofByIndex(0)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	p, err := empty.ConsistencyProof(0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, p.Hashes)
+
+	// The proof of the only leaf is legitimately empty
+	D := makeEntries(3)
+	single := New(D[:1])
+	proof, err := single.InclusionProofOfEntry(D[0])
+	assert.NoError(t, err)
+	assert.Empty(t, proof.Hashes)
+	assert.NoError(t, VerifyInclusion(leafHash(D[0]), single.MerkleRoot(), proof))
+	_, err = single.InclusionProofOfEntry(D[1])
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+	assert.Empty(t, single.InclusionProof(D[1]))
+
+	tree := New(D)
+	_, err = tree.InclusionProofAtSize(1, 1)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = tree.InclusionProofAtSize(0, 4)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = tree.LeafHash(3)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = tree.LeafIndex(leafHash([]byte("missing")))
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+
+	for _, sizes := range [][2]uint64{{2, 1}, {1, 4}, {4, 4}} {
+		_, err = tree.ConsistencyProof(sizes[0], sizes[1])
+		assert.ErrorIs(t, err, ErrInvalidRange, "sizes %v", sizes)
+		assert.Nil(t, tree.ConsitencyProof(sizes[0], sizes[1]), "sizes %v", sizes)
+	}
+	for _, sizes := range [][2]uint64{{0, 3}, {3, 3}} {
+		p, err := tree.ConsistencyProof(sizes[0], sizes[1])
+		assert.NoError(t, err, "sizes %v", sizes)
+		assert.Empty(t, p.Hashes, "sizes %v", sizes)
+	}
+}
+
+func TestProofVerify(t *testing.T) {
+	D := makeEntries(13)
+	tree := New(D[:5])
+	oldRoot := tree.MerkleRoot()
+	tree.Append(D[5:]...)
+	root := tree.MerkleRoot()
+
+	p, err := tree.InclusionProofOfEntry(D[9])
+	assert.NoError(t, err)
+	assert.NoError(t, p.Verify(leafHash(D[9]), root))
+	assert.ErrorIs(t, p.Verify(leafHash(D[8]), root), ErrRootMismatch)
+	assert.Equal(t, p.Hashes, tree.InclusionProof(D[9]))
+
+	c, err := tree.ConsistencyProof(5, 13)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Verify(oldRoot, root))
+	assert.ErrorIs(t, c.Verify(root, oldRoot), ErrRootMismatch)
+	assert.Equal(t, c.Hashes, tree.ConsitencyProof(5, 13))
+
+	// The slice returned for an empty old tree is the empty proof as well
+	c, err = tree.ConsistencyProof(0, 13)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Verify(MTH(nil), root))
+	assert.Empty(t, tree.ConsitencyProof(0, 13))
+}
+
+func TestProofOfLatest(t *testing.T) {
+	tree := New(nil)
+	_, err := tree.ProofOfLatest()
+	assert.ErrorIs(t, err, ErrEmptyTree)
+
+	for i := 1; i <= 33; i++ {
+		D := makeEntries(i)
+		tree := New(D)
+
+		latest, err := tree.ProofOfLatest()
+		assert.NoError(t, err)
+		expected, err := tree.InclusionProofByIndex(uint64(i - 1))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, latest)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i-1]), MTH(D), latest))
+	}
+}
+
+func TestAppendWithProof(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D[:3])
+
+	consistency, latest, err := tree.AppendWithProof(D[3:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), consistency.OldSize)
+	assert.Equal(t, uint64(7), consistency.NewSize)
+	assert.Equal(t, Proof(3, D), consistency.Hashes)
+	assert.Equal(t, uint64(6), latest.LeafIndex)
+	assert.NoError(t, VerifyInclusion(leafHash(D[6]), tree.MerkleRoot(), latest))
+}
+
+func TestVerifyInclusionRejectsBadProofs(t *testing.T) {
+	D := makeEntries(7)
+	root := MTH(D)
+	proof := InclusionProof{LeafIndex: 3, TreeSize: 7, Hashes: Path(3, D)}
+
+	assert.ErrorIs(t, VerifyInclusion(leafHash(D[4]), root, proof), ErrRootMismatch)
+
+	short := proof
+	short.Hashes = proof.Hashes[:2]
+	assert.ErrorIs(t, VerifyInclusion(leafHash(D[3]), root, short), ErrInvalidProofSize)
+
+	long := proof
+	long.Hashes = append(append(long.Hashes[:0:0], proof.Hashes...), root)
+	assert.ErrorIs(t, VerifyInclusion(leafHash(D[3]), root, long), ErrInvalidProofSize)
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	D := makeEntries(7)
+	root := MTH(D)
+	for i := range D {
+		path := Path(uint64(i), D)
+		assert.True(t, VerifyInclusionProof(uint64(i), 7, D[i], path, root), "leaf %d", i)
+		assert.False(t, VerifyInclusionProof(uint64(i), 7, D[(i+1)%7], path, root), "leaf %d", i)
+		assert.False(t, VerifyInclusionProof(uint64(i), 7, D[i], path[:len(path)-1], root), "leaf %d", i)
+		assert.False(t, VerifyInclusionProof(uint64(i), 7, D[i], append(path, root), root), "leaf %d", i)
+	}
+	// d6 sits on the unbalanced right edge, its path skips the missing level
+	assert.Len(t, Path(6, D), 2)
+	assert.False(t, VerifyInclusionProof(6, 8, D[6], Path(6, D), root))
+	assert.False(t, VerifyInclusionProof(7, 7, D[6], Path(6, D), root))
+
+	// A single leaf is its own root, with an empty path
+	assert.True(t, VerifyInclusionProof(0, 1, D[0], nil, MTH(D[:1])))
+	assert.False(t, VerifyInclusionProof(0, 1, D[0], [][32]byte{root}, MTH(D[:1])))
+	assert.False(t, VerifyInclusionProof(0, 0, nil, nil, MTH(nil)))
+
+	for _, n := range []int{2, 16, 33, 100} {
+		D := makeEntries(n)
+		root := MTH(D)
+		for i := range D {
+			assert.True(t, VerifyInclusionProof(uint64(i), uint64(n), D[i], Path(uint64(i), D), root), "leaf %d of %d", i, n)
+		}
+		last := Path(uint64(n-1), D)
+		assert.False(t, VerifyInclusionProof(uint64(n-1), uint64(n), D[n-1], last, MTH(D[:n-1])))
+	}
+}
+
+func TestVerifyConsistencyProof(t *testing.T) {
+	D := makeEntries(20)
+	tree := New(D)
+	for n := 1; n <= len(D); n++ {
+		newRoot := MTH(D[:n])
+		assert.NoError(t, VerifyConsistencyProof(0, uint64(n), MTH(nil), newRoot, nil))
+		for m := 1; m <= n; m++ {
+			oldRoot := MTH(D[:m])
+			proof := Proof(uint64(m), D[:n])
+			assert.NoError(t, VerifyConsistencyProof(uint64(m), uint64(n), oldRoot, newRoot, proof), "sizes %d to %d", m, n)
+			assert.NoError(t, VerifyConsistencyProof(uint64(m), uint64(n), oldRoot, newRoot, tree.ConsitencyProof(uint64(m), uint64(n))), "sizes %d to %d", m, n)
+			if m == n {
+				continue
+			}
+
+			err := VerifyConsistencyProof(uint64(m), uint64(n), oldRoot, newRoot, append(proof, newRoot))
+			assert.ErrorIs(t, err, ErrInvalidProofSize, "sizes %d to %d", m, n)
+			err = VerifyConsistencyProof(uint64(m), uint64(n), oldRoot, newRoot, proof[:len(proof)-1])
+			assert.Error(t, err, "sizes %d to %d", m, n)
+
+			err = VerifyConsistencyProof(uint64(m), uint64(n), MTH(D[1:m+1]), newRoot, proof)
+			assert.ErrorIs(t, err, ErrRootMismatch, "sizes %d to %d", m, n)
+			if m&(m-1) != 0 {
+				// A power of two old size has its root omitted from the proof,
+				// so the mismatch shows in the new root
+				assert.ErrorContains(t, err, "old root")
+			}
+			err = VerifyConsistencyProof(uint64(m), uint64(n), oldRoot, MTH(D[:n-1]), proof)
+			assert.ErrorIs(t, err, ErrRootMismatch, "sizes %d to %d", m, n)
+			assert.ErrorContains(t, err, "new root")
+		}
+	}
+	assert.ErrorIs(t, VerifyConsistencyProof(5, 4, MTH(D[:5]), MTH(D[:4]), nil), ErrInvalidRange)
+}
+
+func benchmarkTree(n int) *MerkleHashTree {
+	return New(makeEntries(n))
+}
+
+func BenchmarkProofOfLatest(b *testing.B) {
+	tree := benchmarkTree(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.ProofOfLatest()
+	}
+}
+
+func BenchmarkInclusionProofByIndexLatest(b *testing.B) {
+	tree := benchmarkTree(1 << 20)
+	n := uint64(len(tree.tree[0]))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.InclusionProofByIndex(n - 1)
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	D := makeEntries(20)
+	tree := New(D)
+
+	for n := 1; n <= len(D); n++ {
+		for m := 1; m <= n; m++ {
+			proof := ConsistencyProof{OldSize: uint64(m), NewSize: uint64(n), Hashes: tree.ConsitencyProof(uint64(m), uint64(n))}
+			assert.NoError(t, VerifyConsistency(MTH(D[:m]), MTH(D[:n]), proof), "m=%d n=%d", m, n)
+		}
+	}
+
+	proof := ConsistencyProof{OldSize: 3, NewSize: 7, Hashes: Proof(3, D[:7])}
+	assert.ErrorIs(t, VerifyConsistency(MTH(D[:4]), MTH(D[:7]), proof), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyConsistency(MTH(D[:3]), MTH(D[:8]), proof), ErrRootMismatch)
+
+	proof.Hashes = proof.Hashes[:3]
+	assert.ErrorIs(t, VerifyConsistency(MTH(D[:3]), MTH(D[:7]), proof), ErrInvalidProofSize)
+}
+
+func TestProofShapeMatchesGeneratedProofs(t *testing.T) {
+	D := makeEntries(70)
+	tree := New(D)
+	for size := uint64(1); size <= uint64(len(D)); size++ {
+		for index := uint64(0); index < size; index++ {
+			p, err := tree.InclusionProofAtSize(index, size)
+			assert.NoError(t, err)
+			assert.Equal(t, len(p.Hashes), InnerProofSize(index, size)+BorderSize(index, size), "index=%d size=%d", index, size)
+		}
+		assert.Equal(t, PerfectSubtreeDecomposition(size)[0], SplitPoint(size+1), "size=%d", size)
+	}
+}
@@ -0,0 +1,34 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EncodeProofJSON returns p as a JSON object holding its leaf index, tree size and
+// audit path, with hashes as lowercase hex strings, and its algorithm unless empty:
+//
+//	{"leaf_index": 3, "tree_size": 7, "audit_path": ["9c1b...", ...]}
+//
+// It is the object served by NewHandler for inclusion proofs.
+func EncodeProofJSON(p InclusionProof) ([]byte, error) {
+	return json.Marshal(inclusionProofResponse{Algorithm: p.Algorithm, LeafIndex: p.LeafIndex, TreeSize: p.TreeSize, AuditPath: encodeHexHashes(p.Hashes)})
+}
+
+// DecodeProofJSON returns the inclusion proof encoded by EncodeProofJSON. Data that
+// is not such an object, hashes that are not 32 bytes of hex and leaf indexes
+// outside of the tree fail with ErrInvalidProof.
+func DecodeProofJSON(data []byte) (InclusionProof, error) {
+	var decoded inclusionProofResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return InclusionProof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if decoded.LeafIndex >= decoded.TreeSize {
+		return InclusionProof{}, fmt.Errorf("%w: leaf index %d, tree size %d", ErrInvalidProof, decoded.LeafIndex, decoded.TreeSize)
+	}
+	hashes, err := decodeHexHashes(decoded.AuditPath)
+	if err != nil {
+		return InclusionProof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return InclusionProof{Algorithm: decoded.Algorithm, LeafIndex: decoded.LeafIndex, TreeSize: decoded.TreeSize, Hashes: hashes}, nil
+}
@@ -0,0 +1,76 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProofJSON(t *testing.T) {
+	D := makeEntries(7)
+	tree := New(D)
+	root := tree.MerkleRoot()
+	for i := range D {
+		p, err := tree.InclusionProofOfEntry(D[i])
+		assert.NoError(t, err)
+		assert.Equal(t, tree.InclusionProof(D[i]), p.Hashes)
+
+		b, err := EncodeProofJSON(p)
+		assert.NoError(t, err)
+		decoded, err := DecodeProofJSON(b)
+		assert.NoError(t, err)
+		assert.Equal(t, p, decoded)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i]), root, decoded))
+	}
+
+	p, _ := tree.InclusionProofByIndex(3)
+	b, _ := EncodeProofJSON(p)
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &fields))
+	assert.Equal(t, float64(3), fields["leaf_index"])
+	assert.Equal(t, float64(7), fields["tree_size"])
+	hashes := fields["audit_path"].([]interface{})
+	assert.Len(t, hashes, len(p.Hashes))
+	for _, h := range hashes {
+		assert.Len(t, h, 64)
+		assert.Equal(t, strings.ToLower(h.(string)), h)
+	}
+	assert.NotContains(t, fields, "algorithm")
+
+	// The proof of a tree over another hasher keeps its algorithm
+	h := newSHA512_256Hasher(t)
+	p, _ = New(D, WithHasher(h)).InclusionProofByIndex(3)
+	b, _ = EncodeProofJSON(p)
+	decoded, err := DecodeProofJSON(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha2-512-256", decoded.Algorithm)
+	assert.ErrorIs(t, VerifyInclusion(h.LeafHash(D[3]), h.MTH(D), decoded), ErrHashMismatch)
+	assert.NoError(t, h.VerifyInclusion(h.LeafHash(D[3]), h.MTH(D), decoded))
+}
+
+func TestDecodeProofJSONRejectsMalformed(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	for _, data := range []string{
+		``,
+		`[]`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": "` + hash + `"}`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": ["` + hash[:62] + `"]}`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": ["` + hash + `ab"]}`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": ["` + hash[:63] + `"]}`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": ["` + hash[:62] + `zz"]}`,
+		`{"leaf_index": 0, "tree_size": 2, "audit_path": [42]}`,
+		`{"leaf_index": 2, "tree_size": 2, "audit_path": ["` + hash + `"]}`,
+		`{"leaf_index": -1, "tree_size": 2, "audit_path": ["` + hash + `"]}`,
+		`{"audit_path": []}`,
+	} {
+		_, err := DecodeProofJSON([]byte(data))
+		assert.ErrorIs(t, err, ErrInvalidProof, "%s", data)
+	}
+
+	p, err := DecodeProofJSON([]byte(`{"leaf_index": 1, "tree_size": 2, "audit_path": ["` + hash + `"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, InclusionProof{LeafIndex: 1, TreeSize: 2, Hashes: [][32]byte{p.Hashes[0]}}, p)
+	assert.Equal(t, byte(0xab), p.Hashes[0][31])
+}
@@ -0,0 +1,155 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ErrMissingNode is returned when a node needed for a proof is not in storage
+var ErrMissingNode = errors.New("merkletree: node is missing from storage")
+
+// NodeStorage reads the hashes of perfect subtrees, the node at level l and index i
+// covering the leaves [i·2^l, (i+1)·2^l). Perfect subtrees never change once their
+// leaves are appended, so storage only holds those.
+type NodeStorage interface {
+	// ReadNodes returns the hashes of ids, in the same order. A node that is not
+	// stored fails with a *MissingNodeError.
+	ReadNodes(ids []NodeID) ([][sha256.Size]byte, error)
+}
+
+// MissingNodeError names a node missing from storage
+type MissingNodeError struct {
+	Node NodeID
+}
+
+func (e *MissingNodeError) Error() string {
+	return fmt.Sprintf("%v: level %d, index %d", ErrMissingNode, e.Node.Level, e.Node.Index)
+}
+
+// Unwrap makes errors.Is(err, ErrMissingNode) report true
+func (e *MissingNodeError) Unwrap() error {
+	return ErrMissingNode
+}
+
+// PerfectNodes calls store with every perfect subtree of the tree, level by level,
+// to fill a NodeStorage
+func (m *MerkleHashTree) PerfectNodes(store func(id NodeID, hash [sha256.Size]byte) error) error {
+	defer m.readLock()()
+
+	size := uint64(len(m.tree[0]))
+	for level := range m.tree {
+		for index, hash := range m.tree[level] {
+			if (uint64(index)+1)<<level > size {
+				break
+			}
+			if err := store(NodeID{Level: uint64(level), Index: uint64(index)}, hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Prover builds proofs for a tree of size leaves from the perfect subtrees in a
+// NodeStorage, without the tree in memory. Every proof reads the nodes it needs in
+// a single ReadNodes call, which are the proof hashes themselves, except that a
+// hash over an incomplete subtree on the right edge is computed from its perfect
+// subtrees.
+type Prover struct {
+	storage NodeStorage
+	size    uint64
+}
+
+// NewProver returns a prover for the tree of size leaves held by storage
+func NewProver(storage NodeStorage, size uint64) *Prover {
+	return &Prover{storage: storage, size: size}
+}
+
+// Size returns the size of the tree the prover builds proofs for
+func (p *Prover) Size() uint64 {
+	return p.size
+}
+
+// InclusionProof returns the audit path for the leaf at index i in the tree of the
+// first n leaves
+func (p *Prover) InclusionProof(i, n uint64) (InclusionProof, error) {
+	if i >= n || n > p.size {
+		return InclusionProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, n)
+	}
+
+	steps := inclusionSteps(i, 0, n)
+	ranges := make([][2]uint64, len(steps))
+	for j, step := range steps {
+		start, end, _ := step.sibling.leafRange(n)
+		ranges[j] = [2]uint64{start, end}
+	}
+	hashes, err := p.rangeHashes(ranges)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return InclusionProof{LeafIndex: i, TreeSize: n, Hashes: hashes}, nil
+}
+
+// ConsistencyProof returns the consistency proof between the trees of the first m
+// and n leaves
+func (p *Prover) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	if m > n || n > p.size {
+		return ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	proof := ConsistencyProof{OldSize: m, NewSize: n, Hashes: make([][sha256.Size]byte, 0)}
+	if m == 0 || m == n {
+		return proof, nil
+	}
+	hashes, err := p.rangeHashes(consistencyRanges(m, 0, n, true))
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+	proof.Hashes = hashes
+	return proof, nil
+}
+
+// rangeHashes returns the hashes of the canonical leaf ranges, reading the perfect
+// subtrees they decompose into at once
+func (p *Prover) rangeHashes(ranges [][2]uint64) ([][sha256.Size]byte, error) {
+	ids := make([]NodeID, 0, len(ranges))
+	parts := make([][]int, len(ranges))
+	seen := make(map[NodeID]int)
+	for j, r := range ranges {
+		start := r[0]
+		for _, size := range PerfectSubtreeDecomposition(r[1] - r[0]) {
+			level := uint64(bits.TrailingZeros64(size))
+			id := NodeID{Level: level, Index: start >> level}
+			k, ok := seen[id]
+			if !ok {
+				k = len(ids)
+				seen[id] = k
+				ids = append(ids, id)
+			}
+			parts[j] = append(parts[j], k)
+			start += size
+		}
+	}
+
+	read, err := p.storage.ReadNodes(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(read) != len(ids) {
+		return nil, fmt.Errorf("%w: storage returned %d nodes for %d", ErrMissingNode, len(read), len(ids))
+	}
+
+	hashes := make([][sha256.Size]byte, len(ranges))
+	for j, part := range parts {
+		// The hash of a range is its perfect subtrees hashed together from the right
+		h := read[part[len(part)-1]]
+		for k := len(part) - 2; k >= 0; k-- {
+			left := read[part[k]]
+			h = nodeHash(append(left[:], h[:]...))
+		}
+		hashes[j] = h
+	}
+	return hashes, nil
+}
@@ -0,0 +1,109 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStorage is a NodeStorage counting its reads
+type countingStorage struct {
+	nodes map[NodeID][sha256.Size]byte
+	calls int
+	reads int
+}
+
+func (s *countingStorage) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	s.calls++
+	s.reads += len(ids)
+	hashes := make([][sha256.Size]byte, len(ids))
+	for i, id := range ids {
+		h, ok := s.nodes[id]
+		if !ok {
+			return nil, &MissingNodeError{Node: id}
+		}
+		hashes[i] = h
+	}
+	return hashes, nil
+}
+
+func storeTree(t *testing.T, tree *MerkleHashTree) *countingStorage {
+	s := &countingStorage{nodes: make(map[NodeID][sha256.Size]byte)}
+	assert.NoError(t, tree.PerfectNodes(func(id NodeID, hash [sha256.Size]byte) error {
+		s.nodes[id] = hash
+		return nil
+	}))
+	return s
+}
+
+func TestProver(t *testing.T) {
+	tree := New(makeEntries(21))
+	storage := storeTree(t, tree)
+	// 21 leaves, 10 + 5 + 2 + 1 perfect subtrees above them
+	assert.Len(t, storage.nodes, 21+10+5+2+1)
+	prover := NewProver(storage, 21)
+
+	for n := uint64(1); n <= 21; n++ {
+		for i := uint64(0); i < n; i++ {
+			want, err := tree.InclusionProofAtSize(i, n)
+			assert.NoError(t, err)
+			got, err := prover.InclusionProof(i, n)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, "index %d, size %d", i, n)
+		}
+		for m := uint64(0); m <= n; m++ {
+			want, err := tree.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			got, err := prover.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, "sizes %d and %d", m, n)
+		}
+	}
+
+	_, err := prover.InclusionProof(21, 21)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+	_, err = prover.ConsistencyProof(3, 22)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestProverReads(t *testing.T) {
+	tree := New(makeEntries(64))
+	storage := storeTree(t, tree)
+	prover := NewProver(storage, 64)
+
+	// In a perfect tree every proof hash is a single stored node, read in one batch
+	proof, err := prover.InclusionProof(37, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, storage.calls)
+	assert.Equal(t, len(proof.Hashes), storage.reads)
+
+	storage.calls, storage.reads = 0, 0
+	consistency, err := prover.ConsistencyProof(23, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, storage.calls)
+	assert.Equal(t, len(consistency.Hashes), storage.reads)
+
+	// An incomplete subtree on the right edge is read as its perfect subtrees: the
+	// sibling of leaf 0 in a tree of 7 leaves covers [4, 7), stored as [4, 6) and [6, 7)
+	storage.calls, storage.reads = 0, 0
+	proof, err = prover.InclusionProof(0, 7)
+	assert.NoError(t, err)
+	assert.Len(t, proof.Hashes, 3)
+	assert.Equal(t, 4, storage.reads)
+}
+
+func TestProverMissingNode(t *testing.T) {
+	tree := New(makeEntries(16))
+	storage := storeTree(t, tree)
+	delete(storage.nodes, NodeID{Level: 2, Index: 3})
+
+	_, err := NewProver(storage, 16).InclusionProof(0, 16)
+	assert.NoError(t, err)
+	_, err = NewProver(storage, 16).InclusionProof(9, 16)
+	var missing *MissingNodeError
+	assert.True(t, errors.As(err, &missing))
+	assert.Equal(t, NodeID{Level: 2, Index: 3}, missing.Node)
+	assert.ErrorIs(t, err, ErrMissingNode)
+}
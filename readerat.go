@@ -0,0 +1,157 @@
+package merkletree
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const defaultVerifiedChunks = 16
+
+// VerifyingReaderAt reads ranges of a file of length bytes whose chunk tree, as
+// built by NewFromChunks, has a trusted root. Every ReadAt reads the whole chunks
+// covering the range and verifies each with its inclusion proof before copying
+// bytes out of it, so no unverified byte is ever returned. The most recently used
+// verified chunks are cached. It is safe for concurrent use, as io.ReaderAt
+// requires.
+type VerifyingReaderAt struct {
+	r         io.ReaderAt
+	root      [sha256.Size]byte
+	length    uint64
+	chunkSize uint64
+	chunks    uint64
+	proof     func(i uint64) (InclusionProof, error)
+
+	mu       sync.Mutex
+	capacity int
+	cached   map[uint64]*list.Element
+	order    *list.List
+}
+
+type verifiedChunk struct {
+	index uint64
+	data  []byte
+}
+
+// ReaderAtOption configures a VerifyingReaderAt
+type ReaderAtOption func(*VerifyingReaderAt)
+
+// WithVerifiedChunkCache caches up to n verified chunks, 16 by default, or none
+// when n is not positive
+func WithVerifiedChunkCache(n int) ReaderAtOption {
+	return func(v *VerifyingReaderAt) {
+		v.capacity = n
+	}
+}
+
+// TreeChunkProofs returns the proofs of the chunks of a chunk tree served by tree,
+// for a VerifyingReader or VerifyingReaderAt reading a file whose tree is at hand
+func TreeChunkProofs(tree Tree) func(i uint64) (InclusionProof, error) {
+	size := tree.Size()
+	return func(i uint64) (InclusionProof, error) {
+		return tree.InclusionProofAtSize(i, size)
+	}
+}
+
+// NewVerifyingReaderAt returns a reader of ranges of r verified against root,
+// calling proof for the inclusion proof of every chunk it reads, either computed
+// from a local tree with TreeChunkProofs or fetched from a log
+func NewVerifyingReaderAt(r io.ReaderAt, root [sha256.Size]byte, length uint64, chunkSize int, proof func(i uint64) (InclusionProof, error), opts ...ReaderAtOption) (*VerifyingReaderAt, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("%w: chunk size %d", ErrInvalidRange, chunkSize)
+	}
+	if length == 0 && root != sha256.Sum256(nil) {
+		return nil, fmt.Errorf("%w: empty file for the root of a non-empty tree", ErrRootMismatch)
+	}
+	v := &VerifyingReaderAt{
+		r:         r,
+		root:      root,
+		length:    length,
+		chunkSize: uint64(chunkSize),
+		chunks:    (length + uint64(chunkSize) - 1) / uint64(chunkSize),
+		proof:     proof,
+		capacity:  defaultVerifiedChunks,
+		cached:    make(map[uint64]*list.Element),
+		order:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v, nil
+}
+
+// ReadAt reads len(p) verified bytes from offset off. Like any io.ReaderAt, it
+// returns io.EOF when the file ends before p is full. A chunk that fails
+// verification, or cannot be read whole, fails with a *ChunkError after the bytes
+// of the chunks before it were copied to p.
+func (v *VerifyingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset %d", ErrInvalidRange, off)
+	}
+	if uint64(off) >= v.length {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) {
+		pos := uint64(off) + uint64(n)
+		if pos >= v.length {
+			return n, io.EOF
+		}
+		i := pos / v.chunkSize
+		chunk, err := v.chunk(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], chunk[pos-i*v.chunkSize:])
+	}
+	return n, nil
+}
+
+// Size returns the length of the file
+func (v *VerifyingReaderAt) Size() int64 {
+	return int64(v.length)
+}
+
+// chunk returns the verified chunk i, from the cache or read and verified
+func (v *VerifyingReaderAt) chunk(i uint64) ([]byte, error) {
+	v.mu.Lock()
+	if e, ok := v.cached[i]; ok {
+		v.order.MoveToFront(e)
+		v.mu.Unlock()
+		return e.Value.(*verifiedChunk).data, nil
+	}
+	v.mu.Unlock()
+
+	size := v.chunkSize
+	if i == v.chunks-1 {
+		size = v.length - i*v.chunkSize
+	}
+	chunk := make([]byte, size)
+	if n, err := v.r.ReadAt(chunk, int64(i*v.chunkSize)); n < len(chunk) {
+		if err == nil || errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, &ChunkError{Index: i, Err: err}
+	}
+	if err := verifyChunk(chunk, i, v.chunks, v.root, v.proof); err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.capacity < 1 {
+		return chunk, nil
+	}
+	if _, ok := v.cached[i]; !ok {
+		v.cached[i] = v.order.PushFront(&verifiedChunk{index: i, data: chunk})
+		if v.order.Len() > v.capacity {
+			oldest := v.order.Back()
+			v.order.Remove(oldest)
+			delete(v.cached, oldest.Value.(*verifiedChunk).index)
+		}
+	}
+	return chunk, nil
+}
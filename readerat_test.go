@@ -0,0 +1,118 @@
+package merkletree
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingReaderAt counts the reads of the underlying reader
+type countingReaderAt struct {
+	io.ReaderAt
+	reads int
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestVerifyingReaderAt(t *testing.T) {
+	const chunkSize = 256
+	file := randomFile(40*chunkSize+100, 7)
+	tree, err := NewFromChunks(bytes.NewReader(file), chunkSize)
+	assert.NoError(t, err)
+
+	corrupted := append([]byte(nil), file...)
+	bad := map[uint64]bool{}
+	for _, pos := range []int{3*chunkSize + 17, 11 * chunkSize, 26*chunkSize - 1, len(file) - 1} {
+		corrupted[pos] ^= 0x40
+		bad[uint64(pos/chunkSize)] = true
+	}
+
+	backing := &countingReaderAt{ReaderAt: bytes.NewReader(corrupted)}
+	v, err := NewVerifyingReaderAt(backing, tree.MerkleRoot(), uint64(len(file)), chunkSize, TreeChunkProofs(tree))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(file)), v.Size())
+
+	rng := rand.New(rand.NewSource(8))
+	for k := 0; k < 2000; k++ {
+		off := rng.Intn(len(file) + 10)
+		p := make([]byte, rng.Intn(3*chunkSize))
+		n, err := v.ReadAt(p, int64(off))
+
+		// The first bad chunk in the range, if any
+		end := off + len(p)
+		if end > len(file) {
+			end = len(file)
+		}
+		if end < off {
+			end = off
+		}
+		firstBad := -1
+		for pos := off; pos < end; pos = (pos/chunkSize + 1) * chunkSize {
+			if bad[uint64(pos/chunkSize)] {
+				firstBad = pos
+				break
+			}
+		}
+
+		switch {
+		case firstBad >= 0:
+			var chunkErr *ChunkError
+			assert.ErrorAs(t, err, &chunkErr, "read %d bytes at %d", len(p), off)
+			assert.True(t, bad[chunkErr.Index])
+			assert.Equal(t, firstBad-off, n)
+		case off+len(p) > len(file):
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, end-off, n)
+		default:
+			assert.NoError(t, err, "read %d bytes at %d", len(p), off)
+			assert.Equal(t, len(p), n)
+		}
+		if n > 0 {
+			assert.Equal(t, file[off:off+n], p[:n])
+		}
+	}
+
+	// Verified chunks are served from the cache
+	p := make([]byte, 2*chunkSize)
+	_, err = v.ReadAt(p, chunkSize/2)
+	assert.NoError(t, err)
+	reads := backing.reads
+	_, err = v.ReadAt(p[:chunkSize], chunkSize)
+	assert.NoError(t, err)
+	assert.Equal(t, reads, backing.reads)
+
+	_, err = v.ReadAt(p, -1)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestVerifyingReaderAtShortFile(t *testing.T) {
+	file := randomFile(1000, 9)
+	tree, err := NewFromChunks(bytes.NewReader(file), 100)
+	assert.NoError(t, err)
+	proofs := make([]InclusionProof, tree.Size())
+	for i := range proofs {
+		proofs[i], err = tree.InclusionProofAtSize(uint64(i), tree.Size())
+		assert.NoError(t, err)
+	}
+
+	// A backing file cut short fails the chunks it no longer holds whole
+	v, err := NewVerifyingReaderAt(bytes.NewReader(file[:950]), tree.MerkleRoot(), 1000, 100, StaticChunkProofs(proofs), WithVerifiedChunkCache(0))
+	assert.NoError(t, err)
+	p := make([]byte, 100)
+	n, err := v.ReadAt(p, 850)
+	assert.Equal(t, 50, n)
+	var chunkErr *ChunkError
+	assert.ErrorAs(t, err, &chunkErr)
+	assert.Equal(t, uint64(9), chunkErr.Index)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	n, err = v.ReadAt(p, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, file[100:200], p)
+}
@@ -0,0 +1,156 @@
+package merkletree
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrRedacted is returned when reading the data of a redacted leaf
+var ErrRedacted = errors.New("merkletree: entry is redacted")
+
+// tombstoneLabel is the first field of a tombstone leaf, telling it apart from
+// other entries
+const tombstoneLabel = "merkletree tombstone v1"
+
+// MarkRedacted stops the tree from returning the data of the leaf at index i: the
+// entry and extra data stored for it are dropped, and GetEntry and the entries
+// served by NewHandler fail with ErrRedacted, also for trees with a LeafSource.
+// The leaf hash stays, so roots and proofs are unchanged.
+func (m *MerkleHashTree) MarkRedacted(i uint64) error {
+	defer m.writeLock()()
+	return m.markRedacted(i)
+}
+
+func (m *MerkleHashTree) markRedacted(i uint64) error {
+	if i >= uint64(len(m.tree[0])) {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, len(m.tree[0]))
+	}
+	if m.redacted == nil {
+		m.redacted = make(map[uint64]bool)
+	}
+	m.redacted[i] = true
+	if i < uint64(len(m.entries)) {
+		m.entries[i] = storedEntry{}
+	}
+	return nil
+}
+
+// IsRedacted reports whether the leaf at index i is redacted
+func (m *MerkleHashTree) IsRedacted(i uint64) bool {
+	defer m.readLock()()
+	return m.redacted[i]
+}
+
+// Redacted returns the indexes of the redacted leaves in increasing order
+func (m *MerkleHashTree) Redacted() []uint64 {
+	defer m.readLock()()
+	return m.redactedIndexes()
+}
+
+func (m *MerkleHashTree) redactedIndexes() []uint64 {
+	indexes := make([]uint64, 0, len(m.redacted))
+	for i := range m.redacted {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+	return indexes
+}
+
+// Tombstone records the redaction of the leaf at Index, with the leaf hash it had,
+// as an entry of the log itself. Signature is set when the tombstone is signed,
+// over TombstoneMessage.
+type Tombstone struct {
+	Index     uint64
+	LeafHash  [sha256.Size]byte
+	Timestamp time.Time
+	Signature []byte
+}
+
+// TombstoneMessage returns the message a tombstone is signed over: EncodeLeafFields
+// of "merkletree tombstone v1", the big endian uint64 index, the leaf hash and the
+// big endian uint64 timestamp in milliseconds since the epoch
+func TombstoneMessage(t Tombstone) []byte {
+	index := binary.BigEndian.AppendUint64(nil, t.Index)
+	timestamp := binary.BigEndian.AppendUint64(nil, uint64(t.Timestamp.UnixMilli()))
+	return EncodeLeafFields([]byte(tombstoneLabel), index, t.LeafHash[:], timestamp)
+}
+
+// TombstoneLeaf returns the entry logging t: EncodeLeafFields of the fields of
+// TombstoneMessage followed by the signature
+func TombstoneLeaf(t Tombstone) []byte {
+	index := binary.BigEndian.AppendUint64(nil, t.Index)
+	timestamp := binary.BigEndian.AppendUint64(nil, uint64(t.Timestamp.UnixMilli()))
+	return EncodeLeafFields([]byte(tombstoneLabel), index, t.LeafHash[:], timestamp, t.Signature)
+}
+
+// ParseTombstone decodes an entry returned by TombstoneLeaf
+func ParseTombstone(leaf []byte) (Tombstone, error) {
+	fields, err := DecodeLeafFields(leaf)
+	if err != nil {
+		return Tombstone{}, err
+	}
+	if len(fields) != 5 || string(fields[0]) != tombstoneLabel || len(fields[1]) != 8 || len(fields[2]) != sha256.Size || len(fields[3]) != 8 {
+		return Tombstone{}, fmt.Errorf("%w: not a tombstone", ErrMalformedLeafFields)
+	}
+
+	t := Tombstone{
+		Index:     binary.BigEndian.Uint64(fields[1]),
+		Timestamp: time.UnixMilli(int64(binary.BigEndian.Uint64(fields[3]))),
+	}
+	copy(t.LeafHash[:], fields[2])
+	if len(fields[4]) > 0 {
+		t.Signature = fields[4]
+	}
+	return t, nil
+}
+
+// VerifyTombstone checks the signature of t by pub, an *ecdsa.PublicKey or an
+// ed25519.PublicKey
+func VerifyTombstone(t Tombstone, pub crypto.PublicKey) error {
+	if !verifyNoteSignature(pub, TombstoneMessage(t), t.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Redact marks the leaf at index i redacted and appends a tombstone logging the
+// redaction, signed by signer unless it is nil. It returns the tombstone, which
+// is the last leaf of the tree and stored like an entry appended with
+// AppendWithExtra. When the tombstone cannot be appended, the leaf
+// is not redacted.
+func (m *MerkleHashTree) Redact(i uint64, signer crypto.Signer) (Tombstone, error) {
+	defer m.writeLock()()
+
+	if i >= uint64(len(m.tree[0])) {
+		return Tombstone{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, len(m.tree[0]))
+	}
+	t := Tombstone{Index: i, LeafHash: m.tree[0][i], Timestamp: m.now().Truncate(time.Millisecond)}
+	if signer != nil {
+		sig, err := signNote(signer, TombstoneMessage(t))
+		if err != nil {
+			return Tombstone{}, err
+		}
+		t.Signature = sig
+	}
+
+	leaf := TombstoneLeaf(t)
+	e, err := m.newStoredEntry(leaf, nil)
+	if err != nil {
+		return Tombstone{}, err
+	}
+	size := uint64(len(m.tree[0]))
+	leaves, err := m.leafHasher([][]byte{leaf})(size)
+	if err != nil {
+		return Tombstone{}, err
+	}
+	if _, err := m.admitLeafHashes(leaves); err != nil {
+		return Tombstone{}, err
+	}
+	m.storeEntry(size, e)
+	return t, m.markRedacted(i)
+}
@@ -0,0 +1,112 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkRedacted(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(nil)
+	for _, d := range D {
+		tree.AppendWithExtra(d, []byte("extra"))
+	}
+	root := tree.MerkleRoot()
+
+	assert.NoError(t, tree.MarkRedacted(3))
+	assert.NoError(t, tree.MarkRedacted(5))
+	assert.ErrorIs(t, tree.MarkRedacted(8), ErrIndexOutOfRange)
+	assert.True(t, tree.IsRedacted(3))
+	assert.False(t, tree.IsRedacted(4))
+	assert.Equal(t, []uint64{3, 5}, tree.Redacted())
+
+	// Hashes and proofs are unchanged, the data is gone
+	assert.Equal(t, root, tree.MerkleRoot())
+	proof, err := tree.InclusionProofByIndex(3)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[3]), root, proof))
+	_, _, err = tree.GetEntry(3)
+	assert.ErrorIs(t, err, ErrRedacted)
+	_, err = tree.GetExtra(5)
+	assert.ErrorIs(t, err, ErrRedacted)
+	leaf, _, err := tree.GetEntry(4)
+	assert.NoError(t, err)
+	assert.Equal(t, D[4], leaf)
+
+	server := httptest.NewServer(NewHandler(tree))
+	defer server.Close()
+	resp, err := http.Get(server.URL + EntriesPath + "?start=2&end=4")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+	// Redaction persists through serialization
+	key := bytes.Repeat([]byte{1}, 32)
+	var buf bytes.Buffer
+	assert.NoError(t, tree.SaveEncrypted(&buf, key))
+	loaded, err := LoadEncrypted(&buf, key, WithLeafSource(func(i uint64) ([]byte, error) { return D[i], nil }))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3, 5}, loaded.Redacted())
+	_, _, err = loaded.GetEntry(3)
+	assert.ErrorIs(t, err, ErrRedacted)
+	leaf, _, err = loaded.GetEntry(4)
+	assert.NoError(t, err)
+	assert.Equal(t, D[4], leaf)
+
+	// Rewritten and truncated leaves are no longer redacted
+	_, err = tree.SetLeaf(3, []byte("replaced"))
+	assert.NoError(t, err)
+	_, err = tree.Truncate(5)
+	assert.NoError(t, err)
+	assert.Empty(t, tree.Redacted())
+}
+
+func TestRedactWithTombstone(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	D := makeEntries(5)
+	tree := New(D)
+	now := time.UnixMilli(1700000000123)
+	tree.clock = func() time.Time { return now }
+
+	tomb, err := tree.Redact(2, key)
+	assert.NoError(t, err)
+	assert.Equal(t, Tombstone{Index: 2, LeafHash: leafHash(D[2]), Timestamp: now, Signature: tomb.Signature}, tomb)
+	assert.NoError(t, VerifyTombstone(tomb, pub))
+	assert.True(t, tree.IsRedacted(2))
+	assert.Equal(t, uint64(6), tree.Size())
+	assert.Equal(t, MTH(append(D, TombstoneLeaf(tomb))), tree.MerkleRoot())
+
+	// The tombstone entry round-trips
+	leaf, _, err := tree.GetEntry(5)
+	assert.NoError(t, err)
+	parsed, err := ParseTombstone(leaf)
+	assert.NoError(t, err)
+	assert.True(t, parsed.Timestamp.Equal(tomb.Timestamp))
+	parsed.Timestamp = tomb.Timestamp
+	assert.Equal(t, tomb, parsed)
+	assert.NoError(t, VerifyTombstone(parsed, pub))
+	parsed.Index = 3
+	assert.ErrorIs(t, VerifyTombstone(parsed, pub), ErrInvalidSignature)
+
+	unsigned, err := tree.Redact(0, nil)
+	assert.NoError(t, err)
+	parsed, err = ParseTombstone(TombstoneLeaf(unsigned))
+	assert.NoError(t, err)
+	assert.Nil(t, parsed.Signature)
+	_, err = ParseTombstone(D[0])
+	assert.ErrorIs(t, err, ErrMalformedLeafFields)
+
+	// A tombstone that cannot be appended leaves the leaf unredacted
+	full := New(D, WithMaxLeaves(5))
+	_, err = full.Redact(1, nil)
+	assert.ErrorIs(t, err, ErrLogFull)
+	assert.False(t, full.IsRedacted(1))
+}
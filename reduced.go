@@ -0,0 +1,94 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// ReduceProof returns p without the hashes a verifier knowing the nodes in known
+// can supply itself, to be checked with VerifyReducedInclusion. Only nodes that
+// are perfect subtrees in the tree of size p.TreeSize are left out: perfect
+// subtrees never change once their leaves are appended, while a node on the right
+// edge of the tree with the same NodeID has another hash at every size.
+func ReduceProof(p InclusionProof, known map[NodeID][sha256.Size]byte) InclusionProof {
+	steps := inclusionSteps(p.LeafIndex, 0, p.TreeSize)
+	if len(steps) != len(p.Hashes) {
+		return p
+	}
+	reduced := InclusionProof{LeafIndex: p.LeafIndex, TreeSize: p.TreeSize, Hashes: make([][sha256.Size]byte, 0, len(p.Hashes))}
+	for j, step := range steps {
+		if _, ok := knownNode(known, step.sibling, p.TreeSize); !ok {
+			reduced.Hashes = append(reduced.Hashes, p.Hashes[j])
+		}
+	}
+	return reduced
+}
+
+// VerifyReducedInclusion checks that leafHash is included in the tree with the
+// given root, using the reduced proof p returned by ReduceProof for the same known
+// nodes. Every node of the audit path must either be supplied by p or be a perfect
+// subtree in known, which the caller must only fill with nodes it verified, e.g.
+// with KnownNodes of verified proofs.
+func VerifyReducedInclusion(leafHash, root [sha256.Size]byte, p InclusionProof, known map[NodeID][sha256.Size]byte) error {
+	full, err := expandProof(p, known)
+	if err != nil {
+		return err
+	}
+	return VerifyInclusion(leafHash, root, full)
+}
+
+// KnownNodes returns the nodes of the proof p that are perfect subtrees, which a
+// verifier can cache once p is verified to reduce later proofs
+func KnownNodes(p InclusionProof) map[NodeID][sha256.Size]byte {
+	known := make(map[NodeID][sha256.Size]byte)
+	steps := inclusionSteps(p.LeafIndex, 0, p.TreeSize)
+	if len(steps) != len(p.Hashes) {
+		return known
+	}
+	for j, step := range steps {
+		if isPerfect(step.sibling, p.TreeSize) {
+			known[step.sibling] = p.Hashes[j]
+		}
+	}
+	return known
+}
+
+// expandProof returns the full proof of a reduced proof p, taking the hashes left
+// out from known
+func expandProof(p InclusionProof, known map[NodeID][sha256.Size]byte) (InclusionProof, error) {
+	if p.LeafIndex >= p.TreeSize {
+		return p, fmt.Errorf("%w: leaf index %d, tree size %d", ErrInvalidProof, p.LeafIndex, p.TreeSize)
+	}
+	steps := inclusionSteps(p.LeafIndex, 0, p.TreeSize)
+	full := InclusionProof{LeafIndex: p.LeafIndex, TreeSize: p.TreeSize, Hashes: make([][sha256.Size]byte, len(steps))}
+	supplied := p.Hashes
+	for j, step := range steps {
+		if hash, ok := knownNode(known, step.sibling, p.TreeSize); ok {
+			full.Hashes[j] = hash
+			continue
+		}
+		if len(supplied) == 0 {
+			return p, fmt.Errorf("%w: node at level %d, index %d is neither supplied nor known", ErrInvalidProof, step.sibling.Level, step.sibling.Index)
+		}
+		full.Hashes[j], supplied = supplied[0], supplied[1:]
+	}
+	if len(supplied) != 0 {
+		return p, fmt.Errorf("%w: %d hashes supplied for known nodes", ErrInvalidProof, len(supplied))
+	}
+	return full, nil
+}
+
+// knownNode returns the hash of id in known when it is a perfect subtree in the
+// tree of the given size
+func knownNode(known map[NodeID][sha256.Size]byte, id NodeID, size uint64) ([sha256.Size]byte, bool) {
+	if !isPerfect(id, size) {
+		return [sha256.Size]byte{}, false
+	}
+	hash, ok := known[id]
+	return hash, ok
+}
+
+// isPerfect reports whether id covers 2^Level leaves of the tree of the given size
+func isPerfect(id NodeID, size uint64) bool {
+	return id.Level < 64 && id.Index < size>>id.Level
+}
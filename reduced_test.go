@@ -0,0 +1,88 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReduceProof(t *testing.T) {
+	D := makeEntries(100)
+	tree := New(D)
+
+	// A client caching the nodes of a proof gets later proofs for neighbouring
+	// leaves with only the nodes it does not know
+	first, err := tree.InclusionProofAtSize(0, 64)
+	assert.NoError(t, err)
+	known := KnownNodes(first)
+	assert.Len(t, known, 6)
+
+	for n := uint64(1); n <= 100; n++ {
+		root := tree.rootAtSize(n)
+		for i := uint64(0); i < n; i++ {
+			p, err := tree.InclusionProofAtSize(i, n)
+			assert.NoError(t, err)
+			reduced := ReduceProof(p, known)
+			assert.LessOrEqual(t, len(reduced.Hashes), len(p.Hashes))
+			assert.NoError(t, VerifyReducedInclusion(leafHash(D[i]), root, reduced, known), "index %d, size %d", i, n)
+			if n > 1 {
+				assert.Error(t, VerifyReducedInclusion(leafHash(D[(i+1)%n]), root, reduced, known))
+			}
+			// A reduction for no known nodes is the proof itself
+			assert.Equal(t, p, ReduceProof(p, nil))
+		}
+	}
+
+	p, err := tree.InclusionProofAtSize(1, 64)
+	assert.NoError(t, err)
+	// Only leaf 0, the sibling of leaf 1, is not on the path of leaf 0
+	assert.Len(t, ReduceProof(p, known).Hashes, 1)
+	p, err = tree.InclusionProofAtSize(2, 100)
+	assert.NoError(t, err)
+	// The nodes at levels 2 to 5 are known, [64, 100) is the right edge
+	assert.Len(t, p.Hashes, 7)
+	assert.Len(t, ReduceProof(p, known).Hashes, 3)
+}
+
+func TestReduceProofAdversarial(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D)
+	proof8, err := tree.InclusionProofAtSize(0, 8)
+	assert.NoError(t, err)
+	known := KnownNodes(proof8)
+	// Node (2, 1) covers the leaves [4, 8), a perfect subtree at size 8
+	assert.Contains(t, known, NodeID{Level: 2, Index: 1})
+
+	// At size 7 the same NodeID is the right edge [4, 7), whose hash differs. A
+	// server leaving it out must not make the client use the cached hash.
+	proof7, err := tree.InclusionProofAtSize(0, 7)
+	assert.NoError(t, err)
+	reduced := ReduceProof(proof7, known)
+	assert.Len(t, reduced.Hashes, 1)
+	assert.NoError(t, VerifyReducedInclusion(leafHash(D[0]), tree.rootAtSize(7), reduced, known))
+	omitted := InclusionProof{LeafIndex: 0, TreeSize: 7, Hashes: nil}
+	assert.ErrorIs(t, VerifyReducedInclusion(leafHash(D[0]), tree.rootAtSize(7), omitted, known), ErrInvalidProof)
+
+	// An empty reduced proof does not make another leaf verify with the known path
+	assert.Empty(t, ReduceProof(proof8, known).Hashes)
+	assert.ErrorIs(t, VerifyReducedInclusion(leafHash([]byte("forged")), tree.MerkleRoot(), ReduceProof(proof8, known), known), ErrRootMismatch)
+
+	// A server leaving out a node the client does not know is caught
+	partial := map[NodeID][sha256.Size]byte{{Level: 0, Index: 1}: known[NodeID{Level: 0, Index: 1}]}
+	assert.ErrorIs(t, VerifyReducedInclusion(leafHash(D[0]), tree.MerkleRoot(), ReduceProof(proof8, known), partial), ErrInvalidProof)
+
+	// Supplied hashes for known nodes are extra hashes, not replacements of them
+	withExtra := proof8
+	withExtra.Hashes = append([][sha256.Size]byte{{}}, proof8.Hashes...)
+	assert.ErrorIs(t, VerifyReducedInclusion(leafHash(D[0]), tree.MerkleRoot(), withExtra, known), ErrInvalidProof)
+
+	// A wrong hash in the known set is never silently replaced by a supplied one
+	poisoned := KnownNodes(proof8)
+	poisoned[NodeID{Level: 1, Index: 1}] = sha256.Sum256([]byte("poison"))
+	assert.ErrorIs(t, VerifyReducedInclusion(leafHash(D[0]), tree.MerkleRoot(), proof8, poisoned), ErrInvalidProof)
+	assert.Error(t, VerifyReducedInclusion(leafHash(D[0]), tree.MerkleRoot(), ReduceProof(proof8, poisoned), poisoned))
+
+	_, err = expandProof(InclusionProof{LeafIndex: 8, TreeSize: 8}, known)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+}
@@ -0,0 +1,157 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned when verifying Rekor entries
+var (
+	ErrInvalidCheckpoint = errors.New("merkletree: invalid checkpoint")
+	ErrInvalidSignature  = errors.New("merkletree: no valid checkpoint signature")
+)
+
+// RekorCheckpoint is the body of a Rekor signed checkpoint: the origin of the log,
+// its tree head and the extension lines following the root hash, e.g. the
+// timestamp Rekor adds.
+type RekorCheckpoint struct {
+	Origin string
+	TreeHead
+	Other []string
+}
+
+// RekorInclusionProof is the inclusionProof of the verification object of a
+// Rekor log entry. LogIndex is the index of the entry in the shard of the log
+// the checkpoint belongs to.
+type RekorInclusionProof struct {
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint"`
+}
+
+// InclusionProof converts p to an inclusion proof of this package
+func (p RekorInclusionProof) InclusionProof() (InclusionProof, error) {
+	if p.LogIndex < 0 || p.TreeSize < 0 {
+		return InclusionProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, p.LogIndex, p.TreeSize)
+	}
+	hashes, err := decodeHexHashes(p.Hashes)
+	if err != nil {
+		return InclusionProof{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return InclusionProof{LeafIndex: uint64(p.LogIndex), TreeSize: uint64(p.TreeSize), Hashes: hashes}, nil
+}
+
+// RekorLeafHash returns the leaf hash of a Rekor entry from its canonicalized
+// body, i.e. the base64 decoded body field of the entry.
+func RekorLeafHash(body []byte) [sha256.Size]byte {
+	return leafHash(body)
+}
+
+// ParseRekorCheckpoint parses a signed checkpoint in the signed note format and
+// returns its body along with the text covered by the signatures and the
+// signature lines.
+func ParseRekorCheckpoint(note string) (RekorCheckpoint, string, []string, error) {
+	i := strings.LastIndex(note, "\n\n")
+	if i < 0 {
+		return RekorCheckpoint{}, "", nil, fmt.Errorf("%w: missing signatures", ErrInvalidCheckpoint)
+	}
+	text, sigs := note[:i+1], strings.Split(strings.TrimSuffix(note[i+2:], "\n"), "\n")
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) < 3 || lines[0] == "" {
+		return RekorCheckpoint{}, "", nil, fmt.Errorf("%w: too few lines", ErrInvalidCheckpoint)
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil {
+		return RekorCheckpoint{}, "", nil, fmt.Errorf("%w: tree size: %v", ErrInvalidCheckpoint, err)
+	}
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(root) != sha256.Size {
+		return RekorCheckpoint{}, "", nil, fmt.Errorf("%w: root hash", ErrInvalidCheckpoint)
+	}
+
+	c := RekorCheckpoint{Origin: lines[0], TreeHead: TreeHead{TreeSize: size}, Other: lines[3:]}
+	copy(c.RootHash[:], root)
+	for _, sig := range sigs {
+		if !strings.HasPrefix(sig, "— ") {
+			return RekorCheckpoint{}, "", nil, fmt.Errorf("%w: malformed signature line", ErrInvalidCheckpoint)
+		}
+	}
+	return c, text, sigs, nil
+}
+
+// VerifyRekorCheckpoint parses a signed checkpoint and checks that it carries a
+// valid signature by the log's public key pub, an *ecdsa.PublicKey or an
+// ed25519.PublicKey. Like Rekor, signatures are matched to pub by the first four
+// bytes of the SHA-256 of its PKIX encoding.
+func VerifyRekorCheckpoint(note string, pub crypto.PublicKey) (RekorCheckpoint, error) {
+	c, text, sigs, err := ParseRekorCheckpoint(note)
+	if err != nil {
+		return RekorCheckpoint{}, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return RekorCheckpoint{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	keyHash := sha256.Sum256(der)
+
+	for _, line := range sigs {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) < 5 || !bytes.Equal(sig[:4], keyHash[:4]) {
+			continue
+		}
+		if verifyNoteSignature(pub, []byte(text), sig[4:]) {
+			return c, nil
+		}
+	}
+	return RekorCheckpoint{}, ErrInvalidSignature
+}
+
+func verifyNoteSignature(pub crypto.PublicKey, text, sig []byte) bool {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(text)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, text, sig)
+	}
+	return false
+}
+
+// VerifyRekorEntry checks that the entry with the given leaf hash is included in the
+// log at the tree head of the checkpoint of p, and that the checkpoint is signed by
+// the log's public key pub. The leaf hash is usually RekorLeafHash of the entry body.
+func VerifyRekorEntry(leafHash [sha256.Size]byte, p RekorInclusionProof, pub crypto.PublicKey) error {
+	c, err := VerifyRekorCheckpoint(p.Checkpoint, pub)
+	if err != nil {
+		return err
+	}
+
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil || !bytes.Equal(root, c.RootHash[:]) || uint64(p.TreeSize) != c.TreeSize {
+		return fmt.Errorf("%w: inclusion proof does not match the checkpoint", ErrInvalidCheckpoint)
+	}
+
+	proof, err := p.InclusionProof()
+	if err != nil {
+		return err
+	}
+	return VerifyInclusion(leafHash, c.RootHash, proof)
+}
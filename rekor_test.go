@@ -0,0 +1,180 @@
+package merkletree
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signRekorCheckpoint signs a checkpoint the way Rekor does: the signature line
+// carries the first four bytes of the SHA-256 of the PKIX encoded public key,
+// followed by the signature of the note text.
+func signRekorCheckpoint(t *testing.T, origin string, head TreeHead, signer crypto.Signer) string {
+	text := fmt.Sprintf("%s\n%d\n%s\nTimestamp: 1689748607742585419\n", origin, head.TreeSize, base64.StdEncoding.EncodeToString(head.RootHash[:]))
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	assert.NoError(t, err)
+	keyHash := sha256.Sum256(der)
+
+	var sig []byte
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		sig, err = signer.Sign(rand.Reader, []byte(text), crypto.Hash(0))
+	default:
+		digest := sha256.Sum256([]byte(text))
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	assert.NoError(t, err)
+
+	name := "rekor.sigstore.dev"
+	return text + "\n— " + name + " " + base64.StdEncoding.EncodeToString(append(keyHash[:4], sig...)) + "\n"
+}
+
+func rekorFixture(t *testing.T, signer crypto.Signer) ([][]byte, RekorInclusionProof) {
+	bodies := make([][]byte, 11)
+	for i := range bodies {
+		bodies[i] = []byte(fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%064x"}}}}`, i))
+	}
+	tree := New(bodies)
+	proof, err := tree.InclusionProofByIndex(6)
+	assert.NoError(t, err)
+	head := tree.TreeHead()
+
+	return bodies, RekorInclusionProof{
+		LogIndex:   6,
+		RootHash:   hex.EncodeToString(head.RootHash[:]),
+		TreeSize:   int64(head.TreeSize),
+		Hashes:     encodeHexHashes(proof.Hashes),
+		Checkpoint: signRekorCheckpoint(t, "rekor.sigstore.dev - 2605736670972794746", head, signer),
+	}
+}
+
+func TestVerifyRekorEntry(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	for _, signer := range []crypto.Signer{ecKey, edKey} {
+		bodies, p := rekorFixture(t, signer)
+		assert.NoError(t, VerifyRekorEntry(RekorLeafHash(bodies[6]), p, signer.Public()))
+		assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(bodies[5]), p, signer.Public()), ErrRootMismatch)
+	}
+
+	bodies, p := rekorFixture(t, ecKey)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(bodies[6]), p, other.Public()), ErrInvalidSignature)
+
+	mismatched := p
+	mismatched.TreeSize--
+	assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(bodies[6]), mismatched, ecKey.Public()), ErrInvalidCheckpoint)
+}
+
+// rekorBundle holds the transparency log entries of a Sigstore bundle
+type rekorBundle struct {
+	VerificationMaterial struct {
+		TlogEntries []struct {
+			CanonicalizedBody []byte `json:"canonicalizedBody"`
+			InclusionProof    struct {
+				LogIndex   int64    `json:"logIndex,string"`
+				RootHash   []byte   `json:"rootHash"`
+				TreeSize   int64    `json:"treeSize,string"`
+				Hashes     [][]byte `json:"hashes"`
+				Checkpoint struct {
+					Envelope string `json:"envelope"`
+				} `json:"checkpoint"`
+			} `json:"inclusionProof"`
+		} `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+}
+
+// recordedRekorEntry returns the body and inclusion proof of the entry recorded from
+// rekor.sigstore.dev under testdata/rekor, and the public key of the log
+func recordedRekorEntry(t *testing.T) ([]byte, RekorInclusionProof, crypto.PublicKey) {
+	data, err := os.ReadFile("testdata/rekor/sigstore.js@2.0.0-provenance.sigstore.json")
+	assert.NoError(t, err)
+	var bundle rekorBundle
+	assert.NoError(t, json.Unmarshal(data, &bundle))
+	assert.Len(t, bundle.VerificationMaterial.TlogEntries, 1)
+	entry := bundle.VerificationMaterial.TlogEntries[0]
+
+	hashes := make([]string, len(entry.InclusionProof.Hashes))
+	for i, h := range entry.InclusionProof.Hashes {
+		hashes[i] = hex.EncodeToString(h)
+	}
+	p := RekorInclusionProof{
+		LogIndex:   entry.InclusionProof.LogIndex,
+		RootHash:   hex.EncodeToString(entry.InclusionProof.RootHash),
+		TreeSize:   entry.InclusionProof.TreeSize,
+		Hashes:     hashes,
+		Checkpoint: entry.InclusionProof.Checkpoint.Envelope,
+	}
+
+	data, err = os.ReadFile("testdata/rekor/rekor.pub")
+	assert.NoError(t, err)
+	block, _ := pem.Decode(data)
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	assert.NoError(t, err)
+	return entry.CanonicalizedBody, p, pub
+}
+
+func TestVerifyRecordedRekorEntry(t *testing.T) {
+	body, p, pub := recordedRekorEntry(t)
+	assert.Equal(t, int64(27657874), p.LogIndex)
+	assert.NoError(t, VerifyRekorEntry(RekorLeafHash(body), p, pub))
+
+	c, err := VerifyRekorCheckpoint(p.Checkpoint, pub)
+	assert.NoError(t, err)
+	assert.Equal(t, "rekor.sigstore.dev - 2605736670972794746", c.Origin)
+	assert.Equal(t, uint64(27657875), c.TreeSize)
+	assert.Equal(t, []string{"Timestamp: 1692374735595899989"}, c.Other)
+
+	// Another body, another index, a tampered path or another key fail, the last
+	// leaf of the tree having a shorter path than the one before
+	assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(append(body, ' ')), p, pub), ErrRootMismatch)
+	moved := p
+	moved.LogIndex--
+	assert.Error(t, VerifyRekorEntry(RekorLeafHash(body), moved, pub))
+	tampered := p
+	tampered.Hashes = append([]string{hex.EncodeToString(make([]byte, sha256.Size))}, p.Hashes[1:]...)
+	assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(body), tampered, pub), ErrRootMismatch)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, VerifyRekorEntry(RekorLeafHash(body), p, other.Public()), ErrInvalidSignature)
+}
+
+func TestParseRekorCheckpoint(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	head := New(makeEntries(5)).TreeHead()
+	note := signRekorCheckpoint(t, "rekor.sigstore.dev - 2605736670972794746", head, key)
+
+	c, err := VerifyRekorCheckpoint(note, key.Public())
+	assert.NoError(t, err)
+	assert.Equal(t, "rekor.sigstore.dev - 2605736670972794746", c.Origin)
+	assert.Equal(t, head, c.TreeHead)
+	assert.Equal(t, []string{"Timestamp: 1689748607742585419"}, c.Other)
+
+	tampered := "x" + note
+	_, err = VerifyRekorCheckpoint(tampered, key.Public())
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, _, _, err = ParseRekorCheckpoint("origin\n5\nnot base64!\n\n— origin AAAA\n")
+	assert.ErrorIs(t, err, ErrInvalidCheckpoint)
+	_, _, _, err = ParseRekorCheckpoint("origin\n5\n")
+	assert.ErrorIs(t, err, ErrInvalidCheckpoint)
+}
@@ -0,0 +1,230 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned when applying replication records
+var (
+	ErrReplicationGap = errors.New("merkletree: replication record starts beyond the end of the follower")
+	ErrDivergentTree  = errors.New("merkletree: replication record does not match the follower")
+)
+
+// ReplicationRecord describes the leaves appended to a primary tree by a single
+// append, together with the resulting tree head and the consistency proof from
+// the tree of the first FirstIndex leaves to it.
+type ReplicationRecord struct {
+	FirstIndex  uint64
+	LeafHashes  [][sha256.Size]byte
+	NewSize     uint64
+	NewRoot     [sha256.Size]byte
+	Consistency ConsistencyProof
+}
+
+// Primary owns a tree and streams a ReplicationRecord for every append to its subscribers
+type Primary struct {
+	mu   sync.RWMutex
+	tree *MerkleHashTree
+	// sizes holds the tree size after every append, so that records follow append boundaries
+	sizes   []uint64
+	changed chan struct{}
+}
+
+// NewPrimary returns a primary for tree. The tree must only be appended to through the primary.
+func NewPrimary(tree *MerkleHashTree) *Primary {
+	return &Primary{tree: tree, sizes: []uint64{tree.Size()}, changed: make(chan struct{})}
+}
+
+// Append adds entries to the tree and returns the new merkle root
+func (p *Primary) Append(d ...[]byte) [sha256.Size]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	root := p.tree.Append(d...)
+	if len(d) > 0 {
+		p.sizes = append(p.sizes, p.tree.Size())
+		close(p.changed)
+		p.changed = make(chan struct{})
+	}
+	return root
+}
+
+// Tree returns the tree of the primary. It must not be appended to directly.
+func (p *Primary) Tree() *MerkleHashTree {
+	return p.tree
+}
+
+// RecordFrom returns the record of the leaves appended after the first from
+// leaves, up to the end of the append that added leaf from. ok is false when the
+// tree has no leaves after from.
+func (p *Primary) RecordFrom(from uint64) (record ReplicationRecord, ok bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	record, ok, _ = p.recordFrom(from)
+	return record, ok
+}
+
+func (p *Primary) recordFrom(from uint64) (ReplicationRecord, bool, chan struct{}) {
+	size := p.tree.Size()
+	if from >= size {
+		return ReplicationRecord{}, false, p.changed
+	}
+
+	i := sort.Search(len(p.sizes), func(i int) bool { return p.sizes[i] > from })
+	end := size
+	if i < len(p.sizes) {
+		end = p.sizes[i]
+	}
+
+	record := ReplicationRecord{
+		FirstIndex:  from,
+		LeafHashes:  copyHashes(p.tree.tree[0][from:end]),
+		NewSize:     end,
+		NewRoot:     p.tree.mthOfRange(0, int(end-1)),
+		Consistency: ConsistencyProof{OldSize: from, NewSize: end, Hashes: make([][sha256.Size]byte, 0)},
+	}
+	if from > 0 {
+		record.Consistency.Hashes = p.tree.ConsitencyProof(from, end)
+	}
+	return record, true, p.changed
+}
+
+// Subscribe streams records covering every leaf after the first from leaves, one
+// per append, until ctx is done. A follower resumes by subscribing from its size.
+func (p *Primary) Subscribe(ctx context.Context, from uint64) <-chan ReplicationRecord {
+	records := make(chan ReplicationRecord)
+	go func() {
+		defer close(records)
+		next := from
+		for {
+			p.mu.RLock()
+			record, ok, changed := p.recordFrom(next)
+			p.mu.RUnlock()
+
+			if !ok {
+				select {
+				case <-changed:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case records <- record:
+				next = record.NewSize
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return records
+}
+
+// Follower maintains a replica of a primary tree from verified replication records
+type Follower struct {
+	mu   sync.RWMutex
+	tree *MerkleHashTree
+}
+
+// NewFollower returns a follower replicating into tree, which usually starts empty
+func NewFollower(tree *MerkleHashTree) *Follower {
+	return &Follower{tree: tree}
+}
+
+// Apply verifies record against the replica and appends its new leaves. Records
+// already applied, entirely or partially, are accepted as long as they agree with
+// the replica, so a resumed stream may safely replay them. A record that does not
+// verify is rejected and leaves the replica untouched. The new leaves are appended
+// as AppendLeafHashes does, under the lock of the tree, so a record the tree has no
+// room for or that its storage fails to persist is rejected as well.
+func (f *Follower) Apply(record ReplicationRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer f.tree.writeLock()()
+
+	size := uint64(len(f.tree.tree[0]))
+	if record.FirstIndex > size {
+		return fmt.Errorf("%w: record starts at %d, follower has %d leaves", ErrReplicationGap, record.FirstIndex, size)
+	}
+	if uint64(len(record.LeafHashes)) != record.NewSize-record.FirstIndex || record.NewSize < record.FirstIndex {
+		return fmt.Errorf("%w: %d leaf hashes for range [%d, %d)", ErrInvalidProof, len(record.LeafHashes), record.FirstIndex, record.NewSize)
+	}
+
+	// leaves the follower already has must match the record
+	known := record.FirstIndex
+	for ; known < size && known < record.NewSize; known++ {
+		if f.tree.tree[0][known] != record.LeafHashes[known-record.FirstIndex] {
+			return fmt.Errorf("%w: leaf %d", ErrDivergentTree, known)
+		}
+	}
+	if record.NewSize <= size {
+		if f.tree.mthOfRange(0, int(record.NewSize-1)) != record.NewRoot {
+			return fmt.Errorf("%w: root at size %d", ErrDivergentTree, record.NewSize)
+		}
+		return nil
+	}
+
+	// the new leaves must produce the advertised root, and the advertised root
+	// must be consistent with the tree the record starts from
+	newLeaves := record.LeafHashes[known-record.FirstIndex:]
+	frontier := f.tree.frontier(size)
+	for i, leaf := range newLeaves {
+		frontier = frontierAppend(frontier, size+uint64(i), leaf)
+	}
+	if frontierRoot(frontier) != record.NewRoot {
+		return fmt.Errorf("%w: leaf hashes do not produce the new root", ErrRootMismatch)
+	}
+
+	oldRoot := sha256.Sum256(nil)
+	if record.FirstIndex > 0 {
+		oldRoot = f.tree.mthOfRange(0, int(record.FirstIndex-1))
+	}
+	if record.Consistency.OldSize != record.FirstIndex || record.Consistency.NewSize != record.NewSize {
+		return fmt.Errorf("%w: consistency proof for sizes %d to %d", ErrInvalidRange, record.Consistency.OldSize, record.Consistency.NewSize)
+	}
+	if err := VerifyConsistency(oldRoot, record.NewRoot, record.Consistency); err != nil {
+		return err
+	}
+
+	_, err := f.tree.admitLeafHashes(newLeaves)
+	return err
+}
+
+// Replicate applies the records of primary to the follower, starting from the
+// follower's current size, until ctx is done or a record is rejected.
+func (f *Follower) Replicate(ctx context.Context, primary *Primary) error {
+	for record := range primary.Subscribe(ctx, f.Size()) {
+		if err := f.Apply(record); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Size returns the number of leaves of the replica
+func (f *Follower) Size() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.tree.Size()
+}
+
+// MerkleRoot returns the merkle root of the replica
+func (f *Follower) MerkleRoot() [sha256.Size]byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.tree.MerkleRoot()
+}
+
+// InclusionProof returns the audit path for the leaf at index in the replica's tree of the first size leaves
+func (f *Follower) InclusionProof(index, size uint64) (InclusionProof, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.tree.InclusionProofAtSize(index, size)
+}
@@ -0,0 +1,120 @@
+package merkletree
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForSize(t *testing.T, f *Follower, size uint64) {
+	assert.Eventually(t, func() bool { return f.Size() == size }, time.Second, time.Millisecond)
+}
+
+func TestReplication(t *testing.T) {
+	D := makeEntries(40)
+	primary := NewPrimary(New(D[:5]))
+	follower := NewFollower(New(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- follower.Replicate(ctx, primary) }()
+
+	waitForSize(t, follower, 5)
+	for i := 5; i < 20; i += 3 {
+		primary.Append(D[i : i+3]...)
+	}
+	waitForSize(t, follower, 20)
+	assert.Equal(t, MTH(D[:20]), follower.MerkleRoot())
+
+	// kill the follower mid-stream, keep appending, and resume
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	primary.Append(D[20:30]...)
+	primary.Append(D[30:]...)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() { done <- follower.Replicate(ctx, primary) }()
+	waitForSize(t, follower, 40)
+	assert.Equal(t, primary.Tree().MerkleRoot(), follower.MerkleRoot())
+
+	proof, err := follower.InclusionProof(17, 40)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash(D[17]), MTH(D), proof))
+}
+
+func TestFollowerApply(t *testing.T) {
+	D := makeEntries(16)
+	primary := NewPrimary(New(D[:4]))
+	primary.Append(D[4:9]...)
+	primary.Append(D[9:]...)
+
+	follower := NewFollower(New(nil))
+	first, ok := primary.RecordFrom(0)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), first.NewSize)
+	assert.NoError(t, follower.Apply(first))
+
+	second, ok := primary.RecordFrom(4)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(9), second.NewSize)
+
+	// a corrupted record is rejected without touching the replica
+	corrupted := second
+	corrupted.LeafHashes = copyHashes(second.LeafHashes)
+	corrupted.LeafHashes[2] = leafHash([]byte("forged"))
+	assert.ErrorIs(t, follower.Apply(corrupted), ErrRootMismatch)
+
+	forgedRoot := second
+	forgedRoot.NewRoot = MTH(append(append([][]byte{}, D[:8]...), []byte("forged")))
+	assert.Error(t, follower.Apply(forgedRoot))
+	assert.Equal(t, uint64(4), follower.Size())
+	assert.Equal(t, MTH(D[:4]), follower.MerkleRoot())
+
+	third, _ := primary.RecordFrom(9)
+	assert.ErrorIs(t, follower.Apply(third), ErrReplicationGap)
+
+	// records are idempotent, and overlapping records only add the missing leaves
+	assert.NoError(t, follower.Apply(second))
+	assert.NoError(t, follower.Apply(second))
+	overlapping, _ := primary.RecordFrom(6)
+	assert.NoError(t, follower.Apply(overlapping))
+	assert.NoError(t, follower.Apply(third))
+	assert.Equal(t, MTH(D), follower.MerkleRoot())
+	assert.Equal(t, primary.Tree().tree, follower.tree.tree)
+}
+
+func TestFollowerApplyThroughTree(t *testing.T) {
+	D := makeEntries(12)
+	primary := NewPrimary(New(D[:4]))
+	primary.Append(D[4:]...)
+	first, _ := primary.RecordFrom(0)
+	second, _ := primary.RecordFrom(4)
+
+	// Replicated leaves are persisted, and stay readable while they are applied
+	storage := NewMemoryNodeStorage()
+	tree, err := OpenNodeStorage(storage, WithLocking())
+	assert.NoError(t, err)
+	follower := NewFollower(tree)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for tree.Size() < 12 {
+			tree.MerkleRoot()
+		}
+	}()
+	assert.NoError(t, follower.Apply(first))
+	assert.NoError(t, follower.Apply(second))
+	<-done
+	reopened, err := OpenNodeStorage(storage)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D), reopened.MerkleRoot())
+
+	// A replica without room for the record is left untouched
+	full := NewFollower(New(nil, WithMaxLeaves(8)))
+	assert.NoError(t, full.Apply(first))
+	assert.ErrorIs(t, full.Apply(second), ErrLogFull)
+	assert.Equal(t, MTH(D[:4]), full.MerkleRoot())
+}
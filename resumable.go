@@ -0,0 +1,156 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrCorruptBuild is returned when resuming a build from a checkpoint that fails
+// validation
+var ErrCorruptBuild = errors.New("merkletree: corrupt build checkpoint")
+
+const defaultBuildCheckpointEvery = 1 << 20
+
+// BuildSource is the input of a resumable build: its entries in order, from any
+// index on
+type BuildSource interface {
+	// Seek makes the next call to Next return the entry at index i
+	Seek(i uint64) error
+	// Next returns the next entry, or io.EOF after the last one. The entry is not
+	// used after the next call.
+	Next() ([]byte, error)
+}
+
+// ChunkSource returns the source of the chunks of chunkSize bytes of r, the
+// entries of NewFromChunks, of which the last may be shorter
+func ChunkSource(r io.ReadSeeker, chunkSize int) BuildSource {
+	return &chunkSource{r: r, chunk: make([]byte, chunkSize)}
+}
+
+type chunkSource struct {
+	r     io.ReadSeeker
+	chunk []byte
+}
+
+func (s *chunkSource) Seek(i uint64) error {
+	_, err := s.r.Seek(int64(i)*int64(len(s.chunk)), io.SeekStart)
+	return err
+}
+
+func (s *chunkSource) Next() ([]byte, error) {
+	n, err := io.ReadFull(s.r, s.chunk)
+	if n > 0 {
+		return s.chunk[:n], nil
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return nil, err
+}
+
+// ResumableBuild computes the tree head of the entries of a source, writing its
+// progress to a checkpoint file every so many entries: the number of entries so
+// far and the frontier of their tree, in the format of the checkpoints of a
+// FileLog. A build interrupted by a crash is resumed from its last checkpoint by
+// ResumeBuild, and gives the same tree head as an uninterrupted one. Only the
+// frontier is kept in memory, so the size of the input is not limited.
+type ResumableBuild struct {
+	path   string
+	source BuildSource
+	every  uint64
+
+	size     uint64
+	frontier [][sha256.Size]byte
+}
+
+// BuildOption configures a ResumableBuild
+type BuildOption func(*ResumableBuild)
+
+// WithBuildCheckpointEvery writes a checkpoint after every n entries, 2^20 by
+// default
+func WithBuildCheckpointEvery(n uint64) BuildOption {
+	return func(b *ResumableBuild) {
+		b.every = n
+	}
+}
+
+// ResumeBuild returns the build of the entries of source checkpointed to path,
+// resumed from the checkpoint with source seeked to the first entry after it, or
+// starting over when there is no checkpoint at path. A checkpoint that fails
+// validation fails with ErrCorruptBuild.
+func ResumeBuild(path string, source BuildSource, opts ...BuildOption) (*ResumableBuild, error) {
+	b := &ResumableBuild{
+		path:     path,
+		source:   source,
+		every:    defaultBuildCheckpointEvery,
+		frontier: make([][sha256.Size]byte, 0),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.every < 1 {
+		b.every = 1
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		// The offset of the checkpoint is not used, the size is the position
+		size, _, frontier, err := parseCheckpoint(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptBuild, path, err)
+		}
+		b.size, b.frontier = size, frontier
+	}
+	if err := source.Seek(b.size); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Run reads the rest of the source and returns the tree head of all its entries,
+// writing a checkpoint every so many entries and one at the end. When reading the
+// source fails, the build can be resumed from its last checkpoint.
+func (b *ResumableBuild) Run() (TreeHead, error) {
+	for {
+		e, err := b.source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return TreeHead{}, err
+		}
+		b.frontier = frontierAppend(b.frontier, b.size, leafHash(e))
+		b.size++
+		if b.size%b.every == 0 {
+			if err := b.Checkpoint(); err != nil {
+				return TreeHead{}, err
+			}
+		}
+	}
+	if err := b.Checkpoint(); err != nil {
+		return TreeHead{}, err
+	}
+	return b.TreeHead(), nil
+}
+
+// Checkpoint atomically replaces the checkpoint with the progress of the build
+func (b *ResumableBuild) Checkpoint() error {
+	return writeFileAtomic(b.path, marshalCheckpoint(b.size, 0, b.frontier))
+}
+
+// TreeHead returns the size and root of the tree of the entries read so far
+func (b *ResumableBuild) TreeHead() TreeHead {
+	return TreeHead{TreeSize: b.size, RootHash: frontierRoot(b.frontier)}
+}
+
+// Frontier returns the frontier of the tree of the entries read so far, see
+// MerkleHashTree.Frontier
+func (b *ResumableBuild) Frontier() [][sha256.Size]byte {
+	return copyHashes(b.frontier)
+}
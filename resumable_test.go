@@ -0,0 +1,92 @@
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// killedSource fails after a number of entries, as a crash of the build would
+type killedSource struct {
+	BuildSource
+	left  int
+	seeks []uint64
+}
+
+var errKilled = errors.New("killed")
+
+func (s *killedSource) Seek(i uint64) error {
+	s.seeks = append(s.seeks, i)
+	return s.BuildSource.Seek(i)
+}
+
+func (s *killedSource) Next() ([]byte, error) {
+	if s.left == 0 {
+		return nil, errKilled
+	}
+	s.left--
+	return s.BuildSource.Next()
+}
+
+func TestResumableBuild(t *testing.T) {
+	file := randomFile(100*97+31, 5)
+	want, err := NewFromChunks(bytes.NewReader(file), 100)
+	assert.NoError(t, err)
+
+	// Straight through
+	path := filepath.Join(t.TempDir(), "build")
+	b, err := ResumeBuild(path, ChunkSource(bytes.NewReader(file), 100), WithBuildCheckpointEvery(10))
+	assert.NoError(t, err)
+	head, err := b.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, want.TreeHead(), head)
+	assert.Equal(t, want.frontier(want.Size()), b.Frontier())
+
+	for _, kills := range [][]int{{0}, {9}, {10}, {55}, {97}, {98}, {3, 4, 50}, {25, 25, 25, 25}} {
+		path := filepath.Join(t.TempDir(), "build")
+		for _, k := range kills {
+			source := &killedSource{BuildSource: ChunkSource(bytes.NewReader(file), 100), left: k}
+			b, err := ResumeBuild(path, source, WithBuildCheckpointEvery(10))
+			assert.NoError(t, err)
+			before := b.TreeHead().TreeSize
+			assert.Equal(t, []uint64{before}, source.seeks)
+			assert.Zero(t, before%10)
+
+			_, err = b.Run()
+			assert.ErrorIs(t, err, errKilled)
+			assert.Equal(t, before+uint64(k), b.TreeHead().TreeSize)
+		}
+
+		b, err := ResumeBuild(path, ChunkSource(bytes.NewReader(file), 100), WithBuildCheckpointEvery(10))
+		assert.NoError(t, err)
+		head, err := b.Run()
+		assert.NoError(t, err)
+		assert.Equal(t, want.TreeHead(), head, "kills %v", kills)
+	}
+
+	// A finished build resumes to the same tree head without reading more
+	b, err = ResumeBuild(path, ChunkSource(bytes.NewReader(file), 100))
+	assert.NoError(t, err)
+	assert.Equal(t, want.TreeHead(), b.TreeHead())
+}
+
+func TestResumableBuildCorruptCheckpoint(t *testing.T) {
+	file := randomFile(5000, 6)
+	path := filepath.Join(t.TempDir(), "build")
+	source := &killedSource{BuildSource: ChunkSource(bytes.NewReader(file), 100), left: 25}
+	b, err := ResumeBuild(path, source, WithBuildCheckpointEvery(10))
+	assert.NoError(t, err)
+	_, err = b.Run()
+	assert.ErrorIs(t, err, errKilled)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-40] ^= 1
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+	_, err = ResumeBuild(path, ChunkSource(bytes.NewReader(file), 100))
+	assert.ErrorIs(t, err, ErrCorruptBuild)
+}
@@ -0,0 +1,154 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// sampleDomain separates the hashes deriving sample indices from any other use of
+// the root
+const sampleDomain = "merkletree sample v1"
+
+// SampleIndices derives k distinct leaf indices of a tree of size leaves from its
+// root, so that anyone holding the root can re-derive them and the log cannot
+// choose which leaves get audited. Draw j, for j = 0, 1, 2, ..., is
+//
+//	v_j = first 8 bytes, big endian, of SHA-256("merkletree sample v1" || root || uint64(j))
+//
+// with j big endian. A draw is rejected when v_j >= 2^64 - (2^64 mod size), which
+// keeps v_j mod size uniform, and otherwise yields index v_j mod size unless that
+// index was drawn before. Draws continue until k indices are found, which are
+// returned in the order they were drawn. With k >= size every index is returned.
+func SampleIndices(root [sha256.Size]byte, size uint64, k int) []uint64 {
+	if size == 0 || k <= 0 {
+		return nil
+	}
+	if uint64(k) > size {
+		k = int(size)
+	}
+
+	// 2^64 mod size, computed without overflowing as (2^64 - size) mod size
+	limit := -((-size) % size)
+	msg := make([]byte, 0, len(sampleDomain)+sha256.Size+8)
+	msg = append(msg, sampleDomain...)
+	msg = append(msg, root[:]...)
+
+	drawn := make(map[uint64]bool, k)
+	indices := make([]uint64, 0, k)
+	for j := uint64(0); len(indices) < k; j++ {
+		h := sha256.Sum256(binary.BigEndian.AppendUint64(msg, j))
+		v := binary.BigEndian.Uint64(h[:8])
+		if limit != 0 && v >= limit {
+			continue
+		}
+		if i := v % size; !drawn[i] {
+			drawn[i] = true
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// AuditSample proves the inclusion of the leaves sampled by SampleIndices from a
+// tree head: LeafHashes[i] and Proofs[i] are for the i-th derived index
+type AuditSample struct {
+	Head       TreeHead
+	LeafHashes [][sha256.Size]byte
+	Proofs     []InclusionProof
+}
+
+// AuditSampleVerifiable samples k leaves of the current tree with SampleIndices
+// and returns their proofs against the current tree head
+func AuditSampleVerifiable(tree *MerkleHashTree, k int) (AuditSample, error) {
+	defer tree.readLock()()
+
+	size := uint64(len(tree.tree[0]))
+	s := AuditSample{Head: TreeHead{TreeSize: size, RootHash: tree.root()}}
+	for _, i := range SampleIndices(s.Head.RootHash, size, k) {
+		proof, err := tree.inclusionProofAtSize(i, size)
+		if err != nil {
+			return AuditSample{}, err
+		}
+		s.LeafHashes = append(s.LeafHashes, tree.tree[0][i])
+		s.Proofs = append(s.Proofs, proof)
+	}
+	return s, nil
+}
+
+// VerifyAuditSample checks that s samples k leaves of the tree at head: it holds a
+// valid proof for every index derived from head by SampleIndices, and nothing else
+func VerifyAuditSample(head TreeHead, k int, s AuditSample) error {
+	if s.Head != head {
+		return fmt.Errorf("%w: sample of the tree head of size %d, want size %d", ErrRootMismatch, s.Head.TreeSize, head.TreeSize)
+	}
+	indices := SampleIndices(head.RootHash, head.TreeSize, k)
+	if len(s.Proofs) != len(indices) || len(s.LeafHashes) != len(indices) {
+		return fmt.Errorf("%w: %d proofs and %d leaf hashes for %d sampled leaves", ErrInvalidProof, len(s.Proofs), len(s.LeafHashes), len(indices))
+	}
+	for j, i := range indices {
+		p := s.Proofs[j]
+		if p.LeafIndex != i || p.TreeSize != head.TreeSize {
+			return fmt.Errorf("%w: proof %d is for index %d of %d, want index %d", ErrInvalidProof, j, p.LeafIndex, p.TreeSize, i)
+		}
+		if err := VerifyInclusion(s.LeafHashes[j], head.RootHash, p); err != nil {
+			return fmt.Errorf("sampled leaf %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MarshalBinary encodes s as the big endian uint64 size and the root of its tree
+// head and a big endian uint32 count of sampled leaves, followed by the leaf hash
+// and the proof, encoded as for ComposedProof, of every sampled leaf
+func (s AuditSample) MarshalBinary() ([]byte, error) {
+	if len(s.Proofs) != len(s.LeafHashes) {
+		return nil, fmt.Errorf("%w: %d proofs for %d leaf hashes", ErrInvalidProof, len(s.Proofs), len(s.LeafHashes))
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, s.Head.TreeSize)
+	buf.Write(s.Head.RootHash[:])
+	binary.Write(&buf, binary.BigEndian, uint32(len(s.Proofs)))
+	for i, p := range s.Proofs {
+		buf.Write(s.LeafHashes[i][:])
+		if err := writeInclusionProof(&buf, p); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes an audit sample encoded by MarshalBinary
+func (s *AuditSample) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var decoded AuditSample
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &decoded.Head.TreeSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if n, _ := r.Read(decoded.Head.RootHash[:]); n != sha256.Size {
+		return fmt.Errorf("%w: root", ErrInvalidProof)
+	}
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil || uint64(count)*sha256.Size > uint64(r.Len()) {
+		return fmt.Errorf("%w: bad sample count", ErrInvalidProof)
+	}
+	for i := uint32(0); i < count; i++ {
+		var leaf [sha256.Size]byte
+		var proof InclusionProof
+		if n, _ := r.Read(leaf[:]); n != sha256.Size {
+			return fmt.Errorf("%w: leaf hash", ErrInvalidProof)
+		}
+		if err := readInclusionProof(r, &proof); err != nil {
+			return err
+		}
+		decoded.LeafHashes = append(decoded.LeafHashes, leaf)
+		decoded.Proofs = append(decoded.Proofs, proof)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidProof, r.Len())
+	}
+
+	*s = decoded
+	return nil
+}
@@ -0,0 +1,62 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleIndices(t *testing.T) {
+	// Pinned so the derivation cannot drift: auditors re-derive the same indices
+	root := sha256.Sum256([]byte("merkletree"))
+	assert.Equal(t, []uint64{78, 285, 868, 220, 195, 105, 935, 832}, SampleIndices(root, 1000, 8))
+	assert.Equal(t, []uint64{4, 1, 2}, SampleIndices(root, 6, 3))
+	// Duplicate draws are skipped until every index is drawn
+	assert.Equal(t, []uint64{3, 0, 2, 1, 4}, SampleIndices(root, 5, 5))
+	assert.Equal(t, []uint64{3, 0, 2, 1, 4}, SampleIndices(root, 5, 50))
+
+	assert.Nil(t, SampleIndices(root, 0, 3))
+	assert.Nil(t, SampleIndices(root, 10, 0))
+	assert.Equal(t, []uint64{0}, SampleIndices(root, 1, 1))
+	assert.NotEqual(t, SampleIndices(root, 1000, 8), SampleIndices(sha256.Sum256(nil), 1000, 8))
+}
+
+func TestAuditSampleVerifiable(t *testing.T) {
+	D := makeEntries(100)
+	tree := New(D)
+	head := tree.TreeHead()
+
+	s, err := AuditSampleVerifiable(tree, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, head, s.Head)
+	assert.Len(t, s.Proofs, 10)
+	for j, i := range SampleIndices(head.RootHash, 100, 10) {
+		assert.Equal(t, leafHash(D[i]), s.LeafHashes[j])
+	}
+	assert.NoError(t, VerifyAuditSample(head, 10, s))
+
+	b, err := s.MarshalBinary()
+	assert.NoError(t, err)
+	var decoded AuditSample
+	assert.NoError(t, decoded.UnmarshalBinary(b))
+	assert.Equal(t, s, decoded)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(b[:len(b)-1]), ErrInvalidProof)
+
+	// Samples of other leaves, fewer leaves or another tree head do not verify
+	assert.ErrorIs(t, VerifyAuditSample(head, 11, s), ErrInvalidProof)
+	other, err := tree.InclusionProofByIndex(0)
+	assert.NoError(t, err)
+	swapped := s
+	swapped.Proofs = append([]InclusionProof{other}, s.Proofs[1:]...)
+	swapped.LeafHashes = append([][sha256.Size]byte{leafHash(D[0])}, s.LeafHashes[1:]...)
+	assert.ErrorIs(t, VerifyAuditSample(head, 10, swapped), ErrInvalidProof)
+	forged := s
+	forged.LeafHashes = append([][sha256.Size]byte{leafHash([]byte("forged"))}, s.LeafHashes[1:]...)
+	assert.ErrorIs(t, VerifyAuditSample(head, 10, forged), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyAuditSample(TreeHead{TreeSize: 99, RootHash: MTH(D[:99])}, 10, s), ErrRootMismatch)
+
+	empty, err := AuditSampleVerifiable(New(nil), 3)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyAuditSample(New(nil).TreeHead(), 3, empty))
+}
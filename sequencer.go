@@ -0,0 +1,194 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSequencerClosed is returned when submitting to a closed Sequencer
+var ErrSequencerClosed = errors.New("merkletree: sequencer is closed")
+
+// Sequenced is the outcome of a submitted entry: the index the entry was
+// assigned and the head of the tree after the batch containing it was appended.
+// Err is set instead when the tree rejected the batch, e.g. with ErrLogFull.
+type Sequenced struct {
+	Index    uint64
+	TreeSize uint64
+	Root     [sha256.Size]byte
+	Err      error
+}
+
+type pendingEntry struct {
+	data   []byte
+	result chan Sequenced
+}
+
+// SequencerOption configures a Sequencer
+type SequencerOption func(*Sequencer)
+
+const (
+	defaultMaxBatch      = 256
+	defaultFlushInterval = 100 * time.Millisecond
+)
+
+// WithMaxBatch flushes a batch as soon as n entries are pending. A non-positive n
+// keeps the default of 256.
+func WithMaxBatch(n int) SequencerOption {
+	return func(s *Sequencer) {
+		if n <= 0 {
+			n = defaultMaxBatch
+		}
+		s.maxBatch = n
+	}
+}
+
+// WithFlushInterval flushes pending entries every interval d. A non-positive d
+// keeps the default of 100ms.
+func WithFlushInterval(d time.Duration) SequencerOption {
+	return func(s *Sequencer) {
+		if d <= 0 {
+			d = defaultFlushInterval
+		}
+		s.interval = d
+	}
+}
+
+// Sequencer buffers submitted entries and appends them to a tree in batches,
+// either when the flush interval elapses or when the maximum batch size is
+// reached. Entries are assigned indexes in the order they were submitted.
+type Sequencer struct {
+	tree     *MerkleHashTree
+	maxBatch int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []pendingEntry
+	closed  bool
+
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSequencer returns a sequencer appending to tree. By default batches of up
+// to 256 entries are flushed every 100ms. Other writers of the tree must use
+// WithLocking for the assigned indexes to stay correct.
+func NewSequencer(tree *MerkleHashTree, opts ...SequencerOption) *Sequencer {
+	s := &Sequencer{
+		tree:     tree,
+		maxBatch: defaultMaxBatch,
+		interval: defaultFlushInterval,
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.run()
+	return s
+}
+
+// Submit queues d to be appended to the tree. The returned channel receives
+// the outcome once the batch containing d has been appended. d must not be modified
+// until then.
+func (s *Sequencer) Submit(d []byte) (<-chan Sequenced, error) {
+	entry := pendingEntry{data: d, result: make(chan Sequenced, 1)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrSequencerClosed
+	}
+
+	s.pending = append(s.pending, entry)
+	if len(s.pending) >= s.maxBatch {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return entry.result, nil
+}
+
+// Close stops accepting entries, flushes the pending ones and waits for them
+// to be appended.
+func (s *Sequencer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSequencerClosed
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	<-s.stopped
+	return nil
+}
+
+func (s *Sequencer) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.full:
+			s.flush()
+		case <-s.done:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush appends the pending entries as a single batch and notifies their submitters
+func (s *Sequencer) flush() {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for len(batch) > 0 {
+		n := len(batch)
+		if n > s.maxBatch {
+			n = s.maxBatch
+		}
+		s.appendBatch(batch[:n])
+		batch = batch[n:]
+	}
+}
+
+func (s *Sequencer) appendBatch(batch []pendingEntry) {
+	d := make([][]byte, 0, len(batch))
+	for _, e := range batch {
+		d = append(d, e.data)
+	}
+	hash := s.tree.leafHasher(d)
+
+	unlock := s.tree.writeLock()
+	first := uint64(len(s.tree.tree[0]))
+	leaves, err := hash(first)
+	var root [sha256.Size]byte
+	if err == nil {
+		root, err = s.tree.admitLeafHashes(leaves)
+	}
+	unlock()
+
+	if err != nil {
+		for _, e := range batch {
+			e.result <- Sequenced{Err: err}
+		}
+		return
+	}
+
+	size := first + uint64(len(batch))
+	for i, e := range batch {
+		e.result <- Sequenced{Index: first + uint64(i), TreeSize: size, Root: root}
+	}
+}
@@ -0,0 +1,131 @@
+package merkletree
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, c <-chan Sequenced) Sequenced {
+	t.Helper()
+	select {
+	case r := <-c:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry was not sequenced")
+		return Sequenced{}
+	}
+}
+
+func TestSequencerFlushesOnInterval(t *testing.T) {
+	D := makeEntries(3)
+	tree := New(nil, WithLocking())
+	s := NewSequencer(tree, WithMaxBatch(1000), WithFlushInterval(10*time.Millisecond))
+	defer s.Close()
+
+	results := make([]<-chan Sequenced, 0, len(D))
+	for _, d := range D {
+		c, err := s.Submit(d)
+		assert.NoError(t, err)
+		results = append(results, c)
+	}
+
+	for i, c := range results {
+		r := receive(t, c)
+		assert.Equal(t, uint64(i), r.Index)
+		assert.Equal(t, uint64(3), r.TreeSize)
+		assert.Equal(t, MTH(D), r.Root)
+	}
+}
+
+func TestSequencerFlushesOnBatchSize(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D[:2], WithLocking())
+	s := NewSequencer(tree, WithMaxBatch(3), WithFlushInterval(time.Hour))
+	defer s.Close()
+
+	results := make([]<-chan Sequenced, 0)
+	for _, d := range D[2:5] {
+		c, err := s.Submit(d)
+		assert.NoError(t, err)
+		results = append(results, c)
+	}
+
+	for i, c := range results {
+		r := receive(t, c)
+		assert.Equal(t, uint64(2+i), r.Index)
+		assert.Equal(t, MTH(D[:5]), r.Root)
+	}
+}
+
+func TestSequencerPreservesSubmissionOrder(t *testing.T) {
+	D := makeEntries(100)
+	tree := New(nil, WithLocking())
+	s := NewSequencer(tree, WithMaxBatch(7), WithFlushInterval(time.Millisecond))
+
+	results := make([]<-chan Sequenced, 0, len(D))
+	for _, d := range D {
+		c, err := s.Submit(d)
+		assert.NoError(t, err)
+		results = append(results, c)
+	}
+	assert.NoError(t, s.Close())
+
+	for i, c := range results {
+		r := receive(t, c)
+		assert.Equal(t, uint64(i), r.Index)
+		assert.Equal(t, MTH(D[:r.TreeSize]), r.Root)
+	}
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+}
+
+func TestSequencerCloseFlushesPending(t *testing.T) {
+	D := makeEntries(5)
+	tree := New(nil, WithLocking())
+	s := NewSequencer(tree, WithMaxBatch(1000), WithFlushInterval(time.Hour))
+
+	results := make([]<-chan Sequenced, 0, len(D))
+	for _, d := range D {
+		c, err := s.Submit(d)
+		assert.NoError(t, err)
+		results = append(results, c)
+	}
+	assert.NoError(t, s.Close())
+
+	for i, c := range results {
+		r := receive(t, c)
+		assert.Equal(t, uint64(i), r.Index)
+		assert.Equal(t, MTH(D), r.Root)
+	}
+
+	_, err := s.Submit([]byte("late"))
+	assert.ErrorIs(t, err, ErrSequencerClosed)
+	assert.ErrorIs(t, s.Close(), ErrSequencerClosed)
+}
+
+func TestSequencerInvalidOptionsKeepDefaults(t *testing.T) {
+	for _, opts := range [][]SequencerOption{
+		{WithMaxBatch(0), WithFlushInterval(0)},
+		{WithMaxBatch(-1), WithFlushInterval(-time.Second)},
+	} {
+		D := makeEntries(3)
+		tree := New(nil, WithLocking())
+		s := NewSequencer(tree, opts...)
+		assert.Equal(t, defaultMaxBatch, s.maxBatch)
+		assert.Equal(t, defaultFlushInterval, s.interval)
+
+		results := make([]<-chan Sequenced, 0, len(D))
+		for _, d := range D {
+			c, err := s.Submit(d)
+			assert.NoError(t, err)
+			results = append(results, c)
+		}
+		for i, c := range results {
+			r := receive(t, c)
+			assert.Equal(t, uint64(i), r.Index)
+			assert.Equal(t, MTH(D), r.Root)
+		}
+		assert.NoError(t, s.Close())
+	}
+}
@@ -0,0 +1,27 @@
+package merkletree
+
+import "github.com/viveksyngh/merkletree/verify"
+
+// SplitPoint returns the largest power of two smaller than n, the number of leaves
+// in the left subtree of a tree of n leaves, or 0 when n < 2.
+func SplitPoint(n uint64) uint64 {
+	return verify.SplitPoint(n)
+}
+
+// PerfectSubtreeDecomposition returns the sizes of the perfect subtrees a tree of n
+// leaves decomposes into, from the leftmost and largest to the rightmost and smallest.
+func PerfectSubtreeDecomposition(n uint64) []uint64 {
+	return verify.PerfectSubtreeDecomposition(n)
+}
+
+// InnerProofSize returns the number of hashes of the audit path of the leaf at index in
+// a tree of size leaves below the point where it meets the path of the last leaf
+func InnerProofSize(index, size uint64) int {
+	return verify.InnerProofSize(index, size)
+}
+
+// BorderSize returns the number of hashes of the audit path of the leaf at index in a
+// tree of size leaves along the right edge of the tree, above the inner part of the path
+func BorderSize(index, size uint64) int {
+	return verify.BorderSize(index, size)
+}
@@ -0,0 +1,64 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/viveksyngh/merkletree/verify"
+)
+
+// SizeProof is evidence that a tree of TreeSize leaves has a given root: the hash of
+// its last leaf and the audit path of that leaf, which only consists of the roots of
+// the perfect subtrees to its left.
+type SizeProof struct {
+	TreeSize     uint64
+	LastLeafHash [sha256.Size]byte
+	Hashes       [][sha256.Size]byte
+}
+
+// ProveSize returns the size proof of the tree. The proof of the empty tree has no hashes.
+func (m *MerkleHashTree) ProveSize() (SizeProof, error) {
+	defer m.readLock()()
+
+	if len(m.tree[0]) == 0 {
+		return SizeProof{Hashes: make([][sha256.Size]byte, 0)}, nil
+	}
+	latest, err := m.proofOfLatest()
+	if err != nil {
+		return SizeProof{}, err
+	}
+	return SizeProof{TreeSize: latest.TreeSize, LastLeafHash: m.tree[0][latest.LeafIndex], Hashes: latest.Hashes}, nil
+}
+
+// VerifySize checks that p proves a tree of size leaves with the given size-bound
+// root, as returned by BoundRoot.
+//
+// The audit path of the last leaf has one hash per perfect subtree left of it, but
+// the hashes do not reveal the heights of these subtrees. Under RFC 6962 hashing a
+// proof therefore also fits the other sizes whose last leaf has as many such
+// subtrees, so the RFC 6962 root alone cannot be checked against a size, and the
+// size is bound by the size-bound root instead.
+func VerifySize(boundRoot [sha256.Size]byte, size uint64, p SizeProof) error {
+	calculated, err := rootFromSizeProof(size, p)
+	if err != nil {
+		return err
+	}
+	if BoundRoot(size, calculated) != boundRoot {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// rootFromSizeProof recomputes the root of the tree of size leaves from p
+func rootFromSizeProof(size uint64, p SizeProof) ([sha256.Size]byte, error) {
+	if p.TreeSize != size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: proof for size %d, claimed size %d", ErrInvalidRange, p.TreeSize, size)
+	}
+	if size == 0 {
+		if len(p.Hashes) != 0 {
+			return [sha256.Size]byte{}, ErrInvalidProofSize
+		}
+		return sha256.Sum256(nil), nil
+	}
+	return verify.RootFromInclusionProof(p.LastLeafHash, InclusionProof{LeafIndex: size - 1, TreeSize: size, Hashes: p.Hashes})
+}
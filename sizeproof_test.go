@@ -0,0 +1,65 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProveSize(t *testing.T) {
+	D := makeEntries(40)
+	for n := 0; n <= len(D); n++ {
+		tree := New(D[:n])
+		p, err := tree.ProveSize()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(n), p.TreeSize)
+		assert.NoError(t, VerifySize(BoundRoot(uint64(n), MTH(D[:n])), uint64(n), p), "n=%d", n)
+	}
+}
+
+func TestProveSizeEdgeCases(t *testing.T) {
+	p, err := New(nil).ProveSize()
+	assert.NoError(t, err)
+	assert.Empty(t, p.Hashes)
+	assert.NoError(t, VerifySize(BoundRoot(0, sha256.Sum256(nil)), 0, p))
+	assert.ErrorIs(t, VerifySize(BoundRoot(0, MTH(makeEntries(1))), 0, p), ErrRootMismatch)
+
+	D := makeEntries(1)
+	p, err = New(D).ProveSize()
+	assert.NoError(t, err)
+	assert.Empty(t, p.Hashes)
+	assert.Equal(t, leafHash(D[0]), p.LastLeafHash)
+	assert.NoError(t, VerifySize(BoundRoot(1, MTH(D)), 1, p))
+}
+
+func TestVerifySizeRejectsWrongSize(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D[:7])
+	root := tree.BoundRoot()
+	p, err := tree.ProveSize()
+	assert.NoError(t, err)
+	assert.NoError(t, VerifySize(root, 7, p))
+
+	// The claimed size must be the size of the proof
+	assert.ErrorIs(t, VerifySize(root, 8, p), ErrInvalidRange)
+
+	// A proof relabelled with another size no longer has the shape of that size
+	for _, size := range []uint64{1, 2, 5, 8, 9} {
+		lying := p
+		lying.TreeSize = size
+		assert.Error(t, VerifySize(root, size, lying), "size=%d", size)
+	}
+
+	// The last leaves of 4 and 6 leaves have as many subtrees to their left as the
+	// last leaf of 7, and the proof relabelled with these sizes gives the RFC 6962
+	// root of the tree, but not its size-bound root.
+	for _, size := range []uint64{4, 6} {
+		lying := p
+		lying.TreeSize = size
+		calculated, err := rootFromSizeProof(size, lying)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.MerkleRoot(), calculated)
+		assert.ErrorIs(t, VerifySize(root, size, lying), ErrRootMismatch, "size=%d", size)
+	}
+}
@@ -0,0 +1,112 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnsortedLeaves is returned when the leaf hashes of a sorted tree are not strictly increasing
+var ErrUnsortedLeaves = errors.New("merkletree: leaf hashes are not sorted and unique")
+
+// NewFromSortedLeafHashes creates a merkle hash tree from leaf hashes sorted in
+// strictly increasing bytewise order, as used for allowlists. The tree remembers
+// that its leaves are sorted and looks leaf hashes up by binary search.
+//
+// Appends to a sorted tree must keep the leaves sorted: a batch whose leaf hashes
+// are not all greater than the last leaf, in increasing order, is rejected with
+// ErrUnsortedLeaves. SetLeaf is rejected the same way when the new leaf would be
+// out of order.
+func NewFromSortedLeafHashes(hashes [][sha256.Size]byte, opts ...Option) (*MerkleHashTree, error) {
+	if i := unsortedAt(nil, hashes); i >= 0 {
+		return nil, fmt.Errorf("%w: at index %d", ErrUnsortedLeaves, i)
+	}
+
+	tree := MerkleHashTree{sorted: true}
+	for _, opt := range opts {
+		opt(&tree)
+	}
+	if err := tree.Hasher().fitsTree(); err != nil {
+		return nil, err
+	}
+	tree.tree = make([][][sha256.Size]byte, levels(len(hashes)))
+	tree.tree[0] = append(make([][sha256.Size]byte, 0, len(hashes)), hashes...)
+	tree.buildTree(tree.tree[0])
+	tree.recordRoot()
+	return &tree, nil
+}
+
+// unsortedAt returns the index in hashes of the first leaf hash not greater than
+// the one preceding it, starting after the leaf hash prev when it is not nil, or -1.
+func unsortedAt(prev *[sha256.Size]byte, hashes [][sha256.Size]byte) int {
+	for i := range hashes {
+		if prev != nil && bytes.Compare(prev[:], hashes[i][:]) >= 0 {
+			return i
+		}
+		prev = &hashes[i]
+	}
+	return -1
+}
+
+// Sorted reports whether the tree was created from sorted leaf hashes
+func (m *MerkleHashTree) Sorted() bool {
+	return m.sorted
+}
+
+// Contains reports whether the tree has a leaf with the given leaf hash
+func (m *MerkleHashTree) Contains(leafHash [sha256.Size]byte) bool {
+	defer m.readLock()()
+	return m.leafIndex(leafHash) >= 0
+}
+
+// InclusionProofByHash returns the audit path of the first leaf with the given leaf hash
+func (m *MerkleHashTree) InclusionProofByHash(leafHash [sha256.Size]byte) (InclusionProof, error) {
+	defer m.readLock()()
+
+	i := m.leafIndex(leafHash)
+	if i < 0 {
+		return InclusionProof{}, ErrLeafNotFound
+	}
+	return m.inclusionProofAtSize(uint64(i), uint64(len(m.tree[0])))
+}
+
+// leafIndex returns the index of the first leaf with the given leaf hash, or -1.
+// The leaves of a sorted tree are searched by binary search.
+func (m *MerkleHashTree) leafIndex(leafHash [sha256.Size]byte) int {
+	if !m.sorted {
+		return IndexOf(m.tree[0], leafHash)
+	}
+
+	leaves := m.tree[0]
+	i := sort.Search(len(leaves), func(i int) bool {
+		return bytes.Compare(leaves[i][:], leafHash[:]) >= 0
+	})
+	if i < len(leaves) && leaves[i] == leafHash {
+		return i
+	}
+	return -1
+}
+
+// checkSorted returns ErrUnsortedLeaves when a sorted tree would no longer be sorted
+// after replacing the leaves from index first on with leaves
+func (m *MerkleHashTree) checkSorted(first uint64, leaves [][sha256.Size]byte) error {
+	if !m.sorted {
+		return nil
+	}
+
+	var prev *[sha256.Size]byte
+	if first > 0 {
+		prev = &m.tree[0][first-1]
+	}
+	if i := unsortedAt(prev, leaves); i >= 0 {
+		return fmt.Errorf("%w: at index %d", ErrUnsortedLeaves, first+uint64(i))
+	}
+	if next := first + uint64(len(leaves)); next < uint64(len(m.tree[0])) && len(leaves) > 0 {
+		if bytes.Compare(leaves[len(leaves)-1][:], m.tree[0][next][:]) >= 0 {
+			return fmt.Errorf("%w: at index %d", ErrUnsortedLeaves, next)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sortedLeafHashes(n int) [][sha256.Size]byte {
+	hashes := make([][sha256.Size]byte, n)
+	for i, d := range makeEntries(n) {
+		hashes[i] = leafHash(d)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	return hashes
+}
+
+func TestNewFromSortedLeafHashes(t *testing.T) {
+	hashes := sortedLeafHashes(100)
+	tree, err := NewFromSortedLeafHashes(hashes)
+	assert.NoError(t, err)
+	assert.True(t, tree.Sorted())
+	assert.Equal(t, rootOfLeafHashes(t, hashes), tree.MerkleRoot())
+
+	for i, h := range hashes {
+		assert.True(t, tree.Contains(h))
+		index, err := tree.LeafIndex(h)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), index)
+
+		p, err := tree.InclusionProofByHash(h)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), p.LeafIndex)
+		assert.NoError(t, VerifyInclusion(h, tree.MerkleRoot(), p))
+	}
+
+	missing := sha256.Sum256([]byte("missing"))
+	assert.False(t, tree.Contains(missing))
+	_, err = tree.InclusionProofByHash(missing)
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+}
+
+func rootOfLeafHashes(t *testing.T, hashes [][sha256.Size]byte) [sha256.Size]byte {
+	root, err := MTHRangeFromLeafHashes(hashes, 0, uint64(len(hashes)))
+	assert.NoError(t, err)
+	return root
+}
+
+func TestNewFromSortedLeafHashesRejectsUnsorted(t *testing.T) {
+	hashes := sortedLeafHashes(10)
+
+	duplicate := append(append([][sha256.Size]byte{}, hashes[:5]...), hashes[4:]...)
+	_, err := NewFromSortedLeafHashes(duplicate)
+	assert.ErrorIs(t, err, ErrUnsortedLeaves)
+
+	swapped := append([][sha256.Size]byte{}, hashes...)
+	swapped[2], swapped[3] = swapped[3], swapped[2]
+	_, err = NewFromSortedLeafHashes(swapped)
+	assert.ErrorIs(t, err, ErrUnsortedLeaves)
+}
+
+func TestSortedTreeAppends(t *testing.T) {
+	hashes := sortedLeafHashes(10)
+	tree, err := NewFromSortedLeafHashes(hashes[:8])
+	assert.NoError(t, err)
+
+	_, err = tree.admitLeafHashes([][sha256.Size]byte{hashes[9], hashes[8]})
+	assert.ErrorIs(t, err, ErrUnsortedLeaves)
+	_, err = tree.admitLeafHashes([][sha256.Size]byte{hashes[3]})
+	assert.ErrorIs(t, err, ErrUnsortedLeaves)
+	assert.Equal(t, uint64(8), tree.Size())
+
+	root, err := tree.admitLeafHashes(hashes[8:])
+	assert.NoError(t, err)
+	assert.Equal(t, rootOfLeafHashes(t, hashes), root)
+	assert.True(t, tree.Contains(hashes[9]))
+
+	// Appending entries only succeeds when their leaf hashes happen to sort last
+	_, err = tree.TryAppend([]byte("entry"))
+	if h := leafHash([]byte("entry")); bytes.Compare(h[:], hashes[9][:]) <= 0 {
+		assert.ErrorIs(t, err, ErrUnsortedLeaves)
+	}
+}
+
+const benchmarkSortedLeaves = 1 << 20
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+func BenchmarkLookupSorted(b *testing.B) {
+	hashes := sortedLeafHashes(benchmarkSortedLeaves)
+	before := heapInUse()
+	tree, _ := NewFromSortedLeafHashes(hashes)
+	inUse := heapInUse() - before
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Contains(hashes[i%len(hashes)])
+	}
+	b.ReportMetric(float64(inUse)/(1<<20), "MB")
+}
+
+func BenchmarkLookupMap(b *testing.B) {
+	hashes := sortedLeafHashes(benchmarkSortedLeaves)
+	before := heapInUse()
+	tree := New(nil)
+	tree.appendLeafHashes(hashes)
+	index := make(map[[sha256.Size]byte]uint64, len(hashes))
+	for i, h := range hashes {
+		index[h] = uint64(i)
+	}
+	inUse := heapInUse() - before
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = index[hashes[i%len(hashes)]]
+	}
+	b.ReportMetric(float64(inUse)/(1<<20), "MB")
+}
@@ -0,0 +1,164 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// SparseDepth is the depth of a SparseMerkleTree: one level per bit of a path
+const SparseDepth = 256
+
+// sparseZero[h] is the root of an empty subtree of height h: the empty leaf is 32
+// zero bytes and every empty node hashes two empty children with the node prefix
+var sparseZero = func() [SparseDepth + 1][sha256.Size]byte {
+	var zero [SparseDepth + 1][sha256.Size]byte
+	for h := 0; h < SparseDepth; h++ {
+		zero[h+1] = nodeHash(append(zero[h][:], zero[h][:]...))
+	}
+	return zero
+}()
+
+// SparseZeroHash returns the root of an empty sparse subtree of the given height
+func SparseZeroHash(height int) [sha256.Size]byte {
+	return sparseZero[height]
+}
+
+// sparseNodeID identifies the node at height above the leaves whose path starts
+// with prefix, the path of any of its leaves with the low height bits cleared
+type sparseNodeID struct {
+	height int
+	prefix [sha256.Size]byte
+}
+
+func sparseNode(path [sha256.Size]byte, height int) sparseNodeID {
+	for b := 0; b < height; b++ {
+		i := SparseDepth - 1 - b
+		path[i/8] &^= 0x80 >> (i % 8)
+	}
+	return sparseNodeID{height: height, prefix: path}
+}
+
+// pathBit returns bit i of path, counted from the root, where 1 means right
+func pathBit(path [sha256.Size]byte, i int) bool {
+	return path[i/8]&(0x80>>(i%8)) != 0
+}
+
+// SparseMerkleTree is a merkle tree of depth 256 with a leaf for every 256 bit
+// path, most of them empty. Bit 0 of a path, its most significant bit, picks the
+// child of the root, 1 being the right one. Leaves are leaf hashes set by the
+// caller, the zero hash being the empty leaf, and nodes are hashed as in RFC 6962
+// with SHA-256, as sparse trees take no Hasher. Only non-empty nodes are stored.
+type SparseMerkleTree struct {
+	nodes map[sparseNodeID][sha256.Size]byte
+}
+
+// NewSparseMerkleTree returns a sparse merkle tree whose leaves are all empty
+func NewSparseMerkleTree() *SparseMerkleTree {
+	return &SparseMerkleTree{nodes: make(map[sparseNodeID][sha256.Size]byte)}
+}
+
+// Root returns the root of the tree
+func (t *SparseMerkleTree) Root() [sha256.Size]byte {
+	return t.node(sparseNodeID{height: SparseDepth})
+}
+
+func (t *SparseMerkleTree) node(id sparseNodeID) [sha256.Size]byte {
+	if hash, ok := t.nodes[id]; ok {
+		return hash
+	}
+	return sparseZero[id.height]
+}
+
+// Get returns the leaf at path, and whether it is not empty
+func (t *SparseMerkleTree) Get(path [sha256.Size]byte) ([sha256.Size]byte, bool) {
+	leaf, ok := t.nodes[sparseNodeID{prefix: path}]
+	return leaf, ok
+}
+
+// Set sets the leaf at path and updates the nodes above it. Setting the zero hash
+// empties the leaf.
+func (t *SparseMerkleTree) Set(path [sha256.Size]byte, leaf [sha256.Size]byte) {
+	hash := leaf
+	for h := 0; ; h++ {
+		id := sparseNode(path, h)
+		if hash == sparseZero[h] {
+			delete(t.nodes, id)
+		} else {
+			t.nodes[id] = hash
+		}
+		if h == SparseDepth {
+			return
+		}
+
+		sibling := t.node(sparseNode(siblingPath(path, h), h))
+		if pathBit(path, SparseDepth-1-h) {
+			hash = nodeHash(append(sibling[:], hash[:]...))
+		} else {
+			hash = nodeHash(append(hash[:], sibling[:]...))
+		}
+	}
+}
+
+// Delete empties the leaf at path
+func (t *SparseMerkleTree) Delete(path [sha256.Size]byte) {
+	t.Set(path, sparseZero[0])
+}
+
+// siblingPath returns path with the bit choosing the node at height flipped
+func siblingPath(path [sha256.Size]byte, height int) [sha256.Size]byte {
+	i := SparseDepth - 1 - height
+	path[i/8] ^= 0x80 >> (i % 8)
+	return path
+}
+
+// SparseProof is the audit path of a leaf of a SparseMerkleTree. Empty siblings are
+// left out: bit h of Bitmap, counted from the most significant bit of byte 0, is
+// set when the sibling at height h is not empty, and Siblings holds the non-empty
+// siblings from the leaf up.
+type SparseProof struct {
+	Bitmap   [SparseDepth / 8]byte
+	Siblings [][sha256.Size]byte
+}
+
+// Prove returns the audit path of the leaf at path. It proves the leaf, or that
+// the leaf is empty.
+func (t *SparseMerkleTree) Prove(path [sha256.Size]byte) SparseProof {
+	p := SparseProof{Siblings: make([][sha256.Size]byte, 0)}
+	for h := 0; h < SparseDepth; h++ {
+		if sibling, ok := t.nodes[sparseNode(siblingPath(path, h), h)]; ok {
+			p.Bitmap[h/8] |= 0x80 >> (h % 8)
+			p.Siblings = append(p.Siblings, sibling)
+		}
+	}
+	return p
+}
+
+// VerifySparseProof checks that the leaf at path of the sparse merkle tree with
+// the given root is leaf, the zero hash proving that it is empty
+func VerifySparseProof(root, path, leaf [sha256.Size]byte, p SparseProof) error {
+	hash := leaf
+	next := 0
+	for h := 0; h < SparseDepth; h++ {
+		sibling := sparseZero[h]
+		if p.Bitmap[h/8]&(0x80>>(h%8)) != 0 {
+			if next == len(p.Siblings) {
+				return fmt.Errorf("%w: %d siblings", ErrInvalidProofSize, len(p.Siblings))
+			}
+			sibling = p.Siblings[next]
+			next++
+		}
+		if pathBit(path, SparseDepth-1-h) {
+			hash = nodeHash(append(sibling[:], hash[:]...))
+		} else {
+			hash = nodeHash(append(hash[:], sibling[:]...))
+		}
+	}
+
+	if next != len(p.Siblings) {
+		return fmt.Errorf("%w: %d siblings", ErrInvalidProofSize, len(p.Siblings))
+	}
+	if hash != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// naiveSparseRoot returns the root of the subtree at height holding leaves, by path
+func naiveSparseRoot(leaves map[[sha256.Size]byte][sha256.Size]byte, height int) [sha256.Size]byte {
+	if len(leaves) == 0 {
+		return SparseZeroHash(height)
+	}
+	if height == 0 {
+		for _, leaf := range leaves {
+			return leaf
+		}
+	}
+
+	left := make(map[[sha256.Size]byte][sha256.Size]byte)
+	right := make(map[[sha256.Size]byte][sha256.Size]byte)
+	for path, leaf := range leaves {
+		if pathBit(path, SparseDepth-height) {
+			right[path] = leaf
+		} else {
+			left[path] = leaf
+		}
+	}
+	l, r := naiveSparseRoot(left, height-1), naiveSparseRoot(right, height-1)
+	return nodeHash(append(l[:], r[:]...))
+}
+
+func TestSparseMerkleTree(t *testing.T) {
+	tree := NewSparseMerkleTree()
+	assert.Equal(t, SparseZeroHash(SparseDepth), tree.Root())
+
+	leaves := make(map[[sha256.Size]byte][sha256.Size]byte)
+	for i, d := range makeEntries(20) {
+		path := sha256.Sum256(d)
+		if i%5 == 0 {
+			// Paths sharing a long prefix
+			path[31] ^= 1
+		}
+		leaves[path] = leafHash(d)
+		tree.Set(path, leafHash(d))
+	}
+	root := tree.Root()
+	assert.Equal(t, naiveSparseRoot(leaves, SparseDepth), root)
+
+	for path, leaf := range leaves {
+		got, ok := tree.Get(path)
+		assert.True(t, ok)
+		assert.Equal(t, leaf, got)
+
+		p := tree.Prove(path)
+		assert.NoError(t, VerifySparseProof(root, path, leaf, p))
+		assert.ErrorIs(t, VerifySparseProof(root, path, SparseZeroHash(0), p), ErrRootMismatch)
+
+		short := p
+		short.Siblings = p.Siblings[1:]
+		assert.ErrorIs(t, VerifySparseProof(root, path, leaf, short), ErrInvalidProofSize)
+		long := p
+		long.Siblings = append(p.Siblings[:len(p.Siblings):len(p.Siblings)], leaf)
+		assert.ErrorIs(t, VerifySparseProof(root, path, leaf, long), ErrInvalidProofSize)
+	}
+
+	absent := sha256.Sum256([]byte("absent"))
+	_, ok := tree.Get(absent)
+	assert.False(t, ok)
+	assert.NoError(t, VerifySparseProof(root, absent, SparseZeroHash(0), tree.Prove(absent)))
+
+	for path := range leaves {
+		tree.Delete(path)
+	}
+	assert.Equal(t, SparseZeroHash(SparseDepth), tree.Root())
+	assert.Empty(t, tree.nodes)
+}
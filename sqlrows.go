@@ -0,0 +1,66 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// RowError reports the row of a query result a leaf could not be read from,
+// counting from 1
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("merkletree: row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// NewFromRows creates a merkle hash tree with a leaf for every row of rows. See
+// AppendRows.
+func NewFromRows(rows *sql.Rows, scan func(*sql.Rows) ([]byte, error), opts ...Option) (*MerkleHashTree, error) {
+	tree := New(nil, opts...)
+	if _, err := tree.AppendRows(rows, scan); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// AppendRows appends a leaf for every row of rows, in the order the rows are
+// returned, and returns the new merkle root. The leaf of a row is the result of
+// scan, which is called once per row and must return a slice it does not reuse,
+// e.g. by scanning into a *[]byte rather than a *sql.RawBytes. The rows are
+// streamed and appended in chunks, never all held in memory, and closed when
+// done.
+//
+// SQL does not order rows unless asked to, so the query must have an ORDER BY
+// clause for the tree to be rebuilt with the same root, e.g.
+//
+//	SELECT payload FROM entries ORDER BY id
+//
+// Errors of scan and of iterating the rows are reported as a *RowError; on error,
+// the rows preceding the failing one have been appended.
+func (m *MerkleHashTree) AppendRows(rows *sql.Rows, scan func(*sql.Rows) ([]byte, error)) ([sha256.Size]byte, error) {
+	defer rows.Close()
+	row := 0
+	return m.appendRecords(func() ([]byte, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, &RowError{Row: row + 1, Err: err}
+			}
+			return nil, io.EOF
+		}
+		row++
+		leaf, err := scan(rows)
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+		return leaf, nil
+	})
+}
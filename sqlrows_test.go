@@ -0,0 +1,166 @@
+package merkletree
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memTable is a table of the in-memory SQL driver, rows of an id and a payload
+// in insertion order
+type memTable struct {
+	ids      []int64
+	payloads [][]byte
+	// failAt fails iterating the rows at the row of that index, when positive
+	failAt int
+}
+
+var memTables = map[string]*memTable{}
+
+// memDriver serves "SELECT payload FROM <table>", optionally "ORDER BY id", from
+// the memTables named by the data source name
+type memDriver struct{}
+
+func init() {
+	sql.Register("merkletree-memory", memDriver{})
+}
+
+func (memDriver) Open(name string) (driver.Conn, error) {
+	t, ok := memTables[name]
+	if !ok {
+		return nil, fmt.Errorf("no table %q", name)
+	}
+	return memConn{t}, nil
+}
+
+type memConn struct{ t *memTable }
+
+func (c memConn) Prepare(query string) (driver.Stmt, error) {
+	return memStmt{t: c.t, ordered: strings.HasSuffix(query, "ORDER BY id")}, nil
+}
+func (memConn) Close() error              { return nil }
+func (memConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type memStmt struct {
+	t       *memTable
+	ordered bool
+}
+
+func (memStmt) Close() error  { return nil }
+func (memStmt) NumInput() int { return 0 }
+func (memStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s memStmt) Query([]driver.Value) (driver.Rows, error) {
+	order := make([]int, len(s.t.ids))
+	for i := range order {
+		order[i] = i
+	}
+	if s.ordered {
+		sort.Slice(order, func(a, b int) bool { return s.t.ids[order[a]] < s.t.ids[order[b]] })
+	}
+	return &memRows{t: s.t, order: order}, nil
+}
+
+var errMemConnection = errors.New("connection reset")
+
+type memRows struct {
+	t     *memTable
+	order []int
+	next  int
+}
+
+func (*memRows) Columns() []string { return []string{"payload"} }
+func (*memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.t.failAt > 0 && r.next == r.t.failAt {
+		return errMemConnection
+	}
+	if r.next == len(r.order) {
+		return io.EOF
+	}
+	dest[0] = r.t.payloads[r.order[r.next]]
+	r.next++
+	return nil
+}
+
+func scanPayload(rows *sql.Rows) ([]byte, error) {
+	var payload []byte
+	err := rows.Scan(&payload)
+	return payload, err
+}
+
+func TestNewFromRows(t *testing.T) {
+	// Rows inserted out of id order, as a table's physical order often is
+	D := makeEntries(ingestChunkSize*2 + 17)
+	table := &memTable{}
+	for i := range D {
+		id := (i * 7919) % len(D)
+		table.ids = append(table.ids, int64(id))
+		table.payloads = append(table.payloads, D[id])
+	}
+	memTables["entries"] = table
+	db, err := sql.Open("merkletree-memory", "entries")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT payload FROM entries ORDER BY id")
+	assert.NoError(t, err)
+	tree, err := NewFromRows(rows, scanPayload)
+	assert.NoError(t, err)
+	assert.Equal(t, New(D).TreeHead(), tree.TreeHead())
+
+	// Without ORDER BY, the rows come in table order and the root differs
+	rows, err = db.Query("SELECT payload FROM entries")
+	assert.NoError(t, err)
+	unordered, err := NewFromRows(rows, scanPayload)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.Size(), unordered.Size())
+	assert.NotEqual(t, tree.MerkleRoot(), unordered.MerkleRoot())
+}
+
+func TestNewFromRowsErrors(t *testing.T) {
+	D := makeEntries(10)
+	table := &memTable{failAt: 6}
+	for i, d := range D {
+		table.ids = append(table.ids, int64(i))
+		table.payloads = append(table.payloads, d)
+	}
+	memTables["failing"] = table
+	db, err := sql.Open("merkletree-memory", "failing")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT payload FROM failing ORDER BY id")
+	assert.NoError(t, err)
+	tree := New(nil)
+	_, err = tree.AppendRows(rows, scanPayload)
+	var rowErr *RowError
+	assert.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, 7, rowErr.Row)
+	assert.ErrorIs(t, err, errMemConnection)
+	assert.Equal(t, New(D[:6]).TreeHead(), tree.TreeHead())
+
+	errScan := errors.New("bad payload")
+	rows, err = db.Query("SELECT payload FROM failing ORDER BY id")
+	assert.NoError(t, err)
+	seen := 0
+	_, err = NewFromRows(rows, func(rows *sql.Rows) ([]byte, error) {
+		if seen++; seen == 3 {
+			return nil, errScan
+		}
+		return scanPayload(rows)
+	})
+	assert.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, 3, rowErr.Row)
+	assert.ErrorIs(t, err, errScan)
+}
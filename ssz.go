@@ -0,0 +1,112 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxSSZProofHashes is the limit of the hashes list of the SSZ proof container,
+// enough for an audit path of any tree with up to 2^64 leaves
+const MaxSSZProofHashes = 64
+
+// sszProofFixedSize is the size of the fixed part of the SSZ proof container: two
+// uint64 fields and the offset of the hashes list
+const sszProofFixedSize = 8 + 8 + 4
+
+// SSZInclusionProof is an inclusion proof in the SSZ container
+//
+//	class InclusionProof(Container):
+//	    leaf_index: uint64
+//	    tree_size: uint64
+//	    hashes: List[Bytes32, 64]
+//
+// Its methods follow the fastssz conventions, so it can be embedded in containers
+// using generated code. Convert an InclusionProof with SSZInclusionProof(p).
+type SSZInclusionProof InclusionProof
+
+// SizeSSZ returns the size of the SSZ encoding of p
+func (p SSZInclusionProof) SizeSSZ() int {
+	return sszProofFixedSize + len(p.Hashes)*sha256.Size
+}
+
+// MarshalSSZ returns the SSZ encoding of p
+func (p SSZInclusionProof) MarshalSSZ() ([]byte, error) {
+	return p.MarshalSSZTo(make([]byte, 0, p.SizeSSZ()))
+}
+
+// MarshalSSZTo appends the SSZ encoding of p to buf
+func (p SSZInclusionProof) MarshalSSZTo(buf []byte) ([]byte, error) {
+	if len(p.Hashes) > MaxSSZProofHashes {
+		return nil, fmt.Errorf("%w: %d hashes", ErrInvalidProofSize, len(p.Hashes))
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, p.LeafIndex)
+	buf = binary.LittleEndian.AppendUint64(buf, p.TreeSize)
+	buf = binary.LittleEndian.AppendUint32(buf, sszProofFixedSize)
+	for _, h := range p.Hashes {
+		buf = append(buf, h[:]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of an inclusion proof into p
+func (p *SSZInclusionProof) UnmarshalSSZ(data []byte) error {
+	if len(data) < sszProofFixedSize {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidProof, len(data))
+	}
+	if offset := binary.LittleEndian.Uint32(data[16:]); offset != sszProofFixedSize {
+		return fmt.Errorf("%w: hashes offset %d", ErrInvalidProof, offset)
+	}
+	hashes := data[sszProofFixedSize:]
+	if len(hashes)%sha256.Size != 0 || len(hashes)/sha256.Size > MaxSSZProofHashes {
+		return fmt.Errorf("%w: %d bytes of hashes", ErrInvalidProof, len(hashes))
+	}
+
+	decoded := SSZInclusionProof{
+		LeafIndex: binary.LittleEndian.Uint64(data),
+		TreeSize:  binary.LittleEndian.Uint64(data[8:]),
+		Hashes:    make([][sha256.Size]byte, len(hashes)/sha256.Size),
+	}
+	for i := range decoded.Hashes {
+		copy(decoded.Hashes[i][:], hashes[i*sha256.Size:])
+	}
+	*p = decoded
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of p
+func (p SSZInclusionProof) HashTreeRoot() ([32]byte, error) {
+	if len(p.Hashes) > MaxSSZProofHashes {
+		return [32]byte{}, fmt.Errorf("%w: %d hashes", ErrInvalidProofSize, len(p.Hashes))
+	}
+
+	var leafIndex, treeSize, length [32]byte
+	binary.LittleEndian.PutUint64(leafIndex[:], p.LeafIndex)
+	binary.LittleEndian.PutUint64(treeSize[:], p.TreeSize)
+	binary.LittleEndian.PutUint64(length[:], uint64(len(p.Hashes)))
+	hashes := SHA256Pair(sszMerkleize(p.Hashes, MaxSSZProofHashes), length)
+
+	return sszMerkleize([][32]byte{leafIndex, treeSize, hashes}, 3), nil
+}
+
+// sszMerkleize returns the SSZ merkleization of chunks padded with zero chunks to
+// the next power of two of limit
+func sszMerkleize(chunks [][32]byte, limit int) [32]byte {
+	layer := append([][32]byte(nil), chunks...)
+	var zero [32]byte
+	for width := 1; width < limit; width *= 2 {
+		if len(layer)%2 == 1 {
+			layer = append(layer, zero)
+		}
+		next := layer[:0]
+		for i := 0; i < len(layer); i += 2 {
+			next = append(next, SHA256Pair(layer[i], layer[i+1]))
+		}
+		layer = next
+		zero = SHA256Pair(zero, zero)
+	}
+	if len(layer) == 0 {
+		return zero
+	}
+	return layer[0]
+}
@@ -0,0 +1,89 @@
+package merkletree
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSZGoldenVectors(t *testing.T) {
+	f, err := os.Open("testdata/ssz/inclusion_proof.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	vectors := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		var index, size, count uint64
+		var root, encoding string
+		_, err := fmt.Sscan(line, &index, &size, &count, &root, &encoding)
+		assert.NoError(t, err)
+
+		p := SSZInclusionProof{LeafIndex: index, TreeSize: size, Hashes: [][32]byte{}}
+		for i := uint64(0); i < count; i++ {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], i)
+			p.Hashes = append(p.Hashes, sha256.Sum256(b[:]))
+		}
+
+		data, err := p.MarshalSSZ()
+		assert.NoError(t, err)
+		assert.Equal(t, encoding, hex.EncodeToString(data))
+		assert.Equal(t, len(data), p.SizeSSZ())
+		htr, err := p.HashTreeRoot()
+		assert.NoError(t, err)
+		assert.Equal(t, root, hex.EncodeToString(htr[:]))
+
+		var decoded SSZInclusionProof
+		assert.NoError(t, decoded.UnmarshalSSZ(data))
+		assert.Equal(t, p, decoded)
+		vectors++
+	}
+	assert.Equal(t, 4, vectors)
+}
+
+func TestSSZRoundTrip(t *testing.T) {
+	D := makeEntries(13)
+	tree := New(D)
+	for i := uint64(0); i < 13; i++ {
+		p, err := tree.InclusionProofByIndex(i)
+		assert.NoError(t, err)
+		data, err := SSZInclusionProof(p).MarshalSSZ()
+		assert.NoError(t, err)
+
+		var decoded SSZInclusionProof
+		assert.NoError(t, decoded.UnmarshalSSZ(data))
+		assert.NoError(t, VerifyInclusion(leafHash(D[i]), tree.MerkleRoot(), InclusionProof(decoded)))
+	}
+}
+
+func TestSSZRejectsMalformed(t *testing.T) {
+	p := SSZInclusionProof{LeafIndex: 1, TreeSize: 3, Hashes: make([][32]byte, 2)}
+	data, err := p.MarshalSSZ()
+	assert.NoError(t, err)
+
+	var decoded SSZInclusionProof
+	assert.ErrorIs(t, decoded.UnmarshalSSZ(data[:19]), ErrInvalidProof)
+	assert.ErrorIs(t, decoded.UnmarshalSSZ(data[:len(data)-1]), ErrInvalidProof)
+	badOffset := append([]byte(nil), data...)
+	badOffset[16] = 24
+	assert.ErrorIs(t, decoded.UnmarshalSSZ(badOffset), ErrInvalidProof)
+
+	long := SSZInclusionProof{Hashes: make([][32]byte, MaxSSZProofHashes+1)}
+	_, err = long.MarshalSSZ()
+	assert.ErrorIs(t, err, ErrInvalidProofSize)
+	_, err = long.HashTreeRoot()
+	assert.ErrorIs(t, err, ErrInvalidProofSize)
+	assert.ErrorIs(t, decoded.UnmarshalSSZ(append(data[:20], make([]byte, 65*32)...)), ErrInvalidProof)
+}
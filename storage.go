@@ -0,0 +1,164 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// ErrCorruptStorage is returned when the nodes of a BatchNodeStorage do not make up
+// a tree of its stored size
+var ErrCorruptStorage = errors.New("merkletree: node storage is inconsistent")
+
+// WriteBatch is every node write of a single change of a tree, together with the
+// size of the tree after it
+type WriteBatch struct {
+	Size  uint64
+	Nodes []ProofNode
+}
+
+// BatchNodeStorage is a NodeStorage a tree opened with OpenNodeStorage persists its
+// perfect subtrees to
+type BatchNodeStorage interface {
+	NodeStorage
+	// WriteBatch applies b atomically, e.g. in a single database transaction:
+	// once it returns, after an error or a crash, either all of its nodes and its
+	// size are stored, or none.
+	WriteBatch(b WriteBatch) error
+	// Size returns the size of the last batch applied, or 0
+	Size() (uint64, error)
+}
+
+// OpenNodeStorage returns the tree stored in storage, created with opts, which
+// writes every later change to storage as a single WriteBatch before applying it.
+// A change whose batch fails is not applied. The stored leaves are checked against
+// the stored perfect subtrees the tree of the stored size decomposes into, and
+// storage that is inconsistent, e.g. after a crash of a backend that does not
+// apply batches atomically, fails with ErrCorruptStorage. Entries are only
+// buffered WithDeferredHashing when the tree has no storage, so it is turned off.
+func OpenNodeStorage(storage BatchNodeStorage, opts ...Option) (*MerkleHashTree, error) {
+	size, err := storage.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]NodeID, 0, size)
+	for i := uint64(0); i < size; i++ {
+		ids = append(ids, NodeID{Index: i})
+	}
+	var frontier []NodeID
+	start := uint64(0)
+	for _, n := range PerfectSubtreeDecomposition(size) {
+		level := uint64(bits.TrailingZeros64(n))
+		frontier = append(frontier, NodeID{Level: level, Index: start >> level})
+		start += n
+	}
+	hashes, err := storage.ReadNodes(append(ids, frontier...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: size %d: %v", ErrCorruptStorage, size, err)
+	}
+	if uint64(len(hashes)) != size+uint64(len(frontier)) {
+		return nil, fmt.Errorf("%w: %d nodes read for %d", ErrCorruptStorage, len(hashes), size+uint64(len(frontier)))
+	}
+
+	tree, err := TryNew(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	tree.deferred = false
+	tree.appendLeafHashes(hashes[:size])
+	for i, id := range frontier {
+		if stored := hashes[size+uint64(i)]; tree.tree[id.Level][id.Index] != stored {
+			return nil, fmt.Errorf("%w: size %d: node at level %d, index %d is %x, its leaves hash to %x", ErrCorruptStorage, size, id.Level, id.Index, stored, tree.tree[id.Level][id.Index])
+		}
+	}
+	tree.storage = storage
+	return tree, nil
+}
+
+// persist writes the change of the leaves from first on, to tail, giving a tree of
+// size leaves, to the storage of the tree
+func (m *MerkleHashTree) persist(first uint64, tail [][sha256.Size]byte, size uint64) error {
+	if m.storage == nil {
+		return nil
+	}
+	return m.storage.WriteBatch(m.storageBatch(first, tail, size))
+}
+
+// storageBatch returns the batch storing the perfect subtrees covering a leaf from
+// first on, when the leaves from first on become tail and the tree size leaves.
+// Perfect subtrees before first are read from the tree.
+func (m *MerkleHashTree) storageBatch(first uint64, tail [][sha256.Size]byte, size uint64) WriteBatch {
+	batch := WriteBatch{Size: size}
+	below, belowFirst := tail, first
+	for level := uint64(0); size>>level > 0; level++ {
+		levelFirst := first >> level
+		nodes := below
+		if level > 0 {
+			child := func(i uint64) [sha256.Size]byte {
+				if i >= belowFirst {
+					return below[i-belowFirst]
+				}
+				return m.tree[level-1][i]
+			}
+			nodes = make([][sha256.Size]byte, 0, size>>level-levelFirst)
+			for i := levelFirst; i < size>>level; i++ {
+				left, right := child(2*i), child(2*i+1)
+				nodes = append(nodes, m.nodeHash(left, right))
+			}
+		}
+		for i, h := range nodes {
+			batch.Nodes = append(batch.Nodes, ProofNode{NodeID: NodeID{Level: level, Index: levelFirst + uint64(i)}, Hash: h})
+		}
+		below, belowFirst = nodes, levelFirst
+	}
+	return batch
+}
+
+// MemoryNodeStorage is a BatchNodeStorage in memory. It is safe for concurrent use.
+type MemoryNodeStorage struct {
+	mu    sync.RWMutex
+	size  uint64
+	nodes map[NodeID][sha256.Size]byte
+}
+
+// NewMemoryNodeStorage returns an empty storage
+func NewMemoryNodeStorage() *MemoryNodeStorage {
+	return &MemoryNodeStorage{nodes: make(map[NodeID][sha256.Size]byte)}
+}
+
+// ReadNodes returns the hashes of ids
+func (s *MemoryNodeStorage) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := make([][sha256.Size]byte, len(ids))
+	for i, id := range ids {
+		h, ok := s.nodes[id]
+		if !ok {
+			return nil, &MissingNodeError{Node: id}
+		}
+		hashes[i] = h
+	}
+	return hashes, nil
+}
+
+// WriteBatch applies b, which cannot fail halfway in memory
+func (s *MemoryNodeStorage) WriteBatch(b WriteBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range b.Nodes {
+		s.nodes[n.NodeID] = n.Hash
+	}
+	s.size = b.Size
+	return nil
+}
+
+// Size returns the size of the last batch applied
+func (s *MemoryNodeStorage) Size() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size, nil
+}
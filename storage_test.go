@@ -0,0 +1,181 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultyStorage fails the batch numbered failAt. A torn storage, which does not
+// apply batches atomically, first stores the nodes of the failing batch before
+// keep and then, if sizeFirst, its size.
+type faultyStorage struct {
+	*MemoryNodeStorage
+	batches   int
+	failAt    int
+	torn      bool
+	keep      int
+	sizeFirst bool
+}
+
+func (s *faultyStorage) WriteBatch(b WriteBatch) error {
+	s.batches++
+	if s.batches != s.failAt {
+		return s.MemoryNodeStorage.WriteBatch(b)
+	}
+	if s.torn {
+		size, _ := s.MemoryNodeStorage.Size()
+		if s.sizeFirst {
+			size = b.Size
+		}
+		keep := s.keep
+		if keep > len(b.Nodes) {
+			keep = len(b.Nodes)
+		}
+		s.MemoryNodeStorage.WriteBatch(WriteBatch{Size: size, Nodes: b.Nodes[:keep]})
+	}
+	return errInjected
+}
+
+// storedNodes returns the nodes of storage that are perfect subtrees of its tree
+func storedNodes(t *testing.T, s *MemoryNodeStorage) map[NodeID][sha256.Size]byte {
+	size, _ := s.Size()
+	nodes := make(map[NodeID][sha256.Size]byte)
+	for id, h := range s.nodes {
+		if (id.Index+1)<<id.Level <= size {
+			nodes[id] = h
+		}
+	}
+	return nodes
+}
+
+func TestOpenNodeStorage(t *testing.T) {
+	storage := NewMemoryNodeStorage()
+	tree, err := OpenNodeStorage(storage)
+	assert.NoError(t, err)
+
+	entries := makeEntries(40)
+	_, err = tree.TryAppend(entries[:13]...)
+	assert.NoError(t, err)
+	for _, d := range entries[13:27] {
+		_, err = tree.TryAppend(d)
+		assert.NoError(t, err)
+	}
+	_, err = tree.SetLeaf(5, []byte("changed"))
+	assert.NoError(t, err)
+	_, err = tree.Truncate(22)
+	assert.NoError(t, err)
+	_, err = tree.TryAppend(entries[27:]...)
+	assert.NoError(t, err)
+
+	// The storage holds exactly the perfect subtrees of the tree
+	want := make(map[NodeID][sha256.Size]byte)
+	assert.NoError(t, tree.PerfectNodes(func(id NodeID, hash [sha256.Size]byte) error {
+		want[id] = hash
+		return nil
+	}))
+	assert.Equal(t, want, storedNodes(t, storage))
+	size, _ := storage.Size()
+	assert.Equal(t, tree.Size(), size)
+
+	reopened, err := OpenNodeStorage(storage)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.MerkleRoot(), reopened.MerkleRoot())
+	proof, err := NewProver(storage, size).InclusionProof(5, size)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyInclusion(leafHash([]byte("changed")), tree.MerkleRoot(), proof))
+}
+
+func TestOpenNodeStorageHasher(t *testing.T) {
+	h := newSHA512_256Hasher(t)
+	storage := NewMemoryNodeStorage()
+	tree, err := OpenNodeStorage(storage, WithHasher(h))
+	assert.NoError(t, err)
+
+	D := makeEntries(27)
+	_, err = tree.TryAppend(D[:11]...)
+	assert.NoError(t, err)
+	_, err = tree.TryAppend(D[11:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, h.MTH(D), tree.MerkleRoot())
+
+	// The stored nodes are hashed with the hasher, so the tree reopens over it
+	reopened, err := OpenNodeStorage(storage, WithHasher(h))
+	assert.NoError(t, err)
+	assert.Equal(t, tree.MerkleRoot(), reopened.MerkleRoot())
+	_, err = OpenNodeStorage(storage)
+	assert.ErrorIs(t, err, ErrCorruptStorage)
+}
+
+func TestOpenNodeStorageWriteBatchFailure(t *testing.T) {
+	entries := makeEntries(20)
+	changes := []func(tree *MerkleHashTree) error{
+		func(tree *MerkleHashTree) error {
+			_, err := tree.TryAppend(entries[10:]...)
+			return err
+		},
+		func(tree *MerkleHashTree) error {
+			_, err := tree.SetLeaf(3, []byte("changed"))
+			return err
+		},
+		func(tree *MerkleHashTree) error {
+			_, err := tree.Truncate(4)
+			return err
+		},
+	}
+	for _, change := range changes {
+		storage := &faultyStorage{MemoryNodeStorage: NewMemoryNodeStorage(), failAt: 2}
+		tree, err := OpenNodeStorage(storage)
+		assert.NoError(t, err)
+		_, err = tree.TryAppend(entries[:10]...)
+		assert.NoError(t, err)
+		root := tree.MerkleRoot()
+
+		assert.ErrorIs(t, change(tree), errInjected)
+		assert.Equal(t, uint64(10), tree.Size())
+		assert.Equal(t, root, tree.MerkleRoot())
+
+		reopened, err := OpenNodeStorage(storage)
+		assert.NoError(t, err)
+		assert.Equal(t, root, reopened.MerkleRoot())
+	}
+}
+
+func TestOpenNodeStorageTornBatch(t *testing.T) {
+	entries := makeEntries(20)
+	for keep := 0; keep <= 40; keep++ {
+		for _, sizeFirst := range []bool{false, true} {
+			for _, setLeaf := range []bool{false, true} {
+				storage := &faultyStorage{MemoryNodeStorage: NewMemoryNodeStorage(), failAt: 2, torn: true, keep: keep, sizeFirst: sizeFirst}
+				tree, err := OpenNodeStorage(storage)
+				assert.NoError(t, err)
+				_, err = tree.TryAppend(entries[:11]...)
+				assert.NoError(t, err)
+				root := tree.MerkleRoot()
+
+				changed := New(entries[:11])
+				if setLeaf {
+					_, err = tree.SetLeaf(2, []byte("changed"))
+					changed.SetLeaf(2, []byte("changed"))
+				} else {
+					_, err = tree.TryAppend(entries[11:]...)
+					changed.TryAppend(entries[11:]...)
+				}
+				assert.ErrorIs(t, err, errInjected)
+
+				// A torn batch is refused on reopen, or leaves the old tree, or the
+				// new one when all of it was stored
+				reopened, err := OpenNodeStorage(storage)
+				if err != nil {
+					assert.ErrorIs(t, err, ErrCorruptStorage, "keep %d", keep)
+					continue
+				}
+				assert.Contains(t, [][sha256.Size]byte{root, changed.MerkleRoot()}, reopened.MerkleRoot(), "keep %d, sizeFirst %v, setLeaf %v", keep, sizeFirst, setLeaf)
+			}
+		}
+	}
+}
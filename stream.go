@@ -0,0 +1,190 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ChunkError reports the first chunk of a stream that did not verify
+type ChunkError struct {
+	Index uint64
+	Err   error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("merkletree: chunk %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the verification error
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// NewFromChunks builds a merkle hash tree with a leaf per chunkSize bytes of r, the
+// chunk tree verified by a VerifyingReader. The last chunk may be shorter. Created
+// WithContentDefinedChunking, chunks are chunkSize bytes on average instead.
+func NewFromChunks(r io.Reader, chunkSize int, opts ...Option) (*MerkleHashTree, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("%w: chunk size %d", ErrInvalidRange, chunkSize)
+	}
+	tree := New(nil, opts...)
+	if tree.cdc != nil {
+		params := *tree.cdc
+		params.Avg = chunkSize
+		if err := tree.appendContentChunks(r, params); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	}
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if _, err := tree.TryAppend(chunk[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return tree, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// StaticChunkProofs returns the proof of every chunk from proofs, indexed by chunk,
+// for proofs supplied up front to NewVerifyingReader
+func StaticChunkProofs(proofs []InclusionProof) func(i uint64) (InclusionProof, error) {
+	return func(i uint64) (InclusionProof, error) {
+		if i >= uint64(len(proofs)) {
+			return InclusionProof{}, fmt.Errorf("%w: no proof for chunk %d", ErrIndexOutOfRange, i)
+		}
+		return proofs[i], nil
+	}
+}
+
+// VerifyingReader reads a stream of length bytes whose chunk tree, as built by
+// NewFromChunks, has a trusted root, and only returns the bytes of a chunk once the
+// whole chunk was read and verified with its inclusion proof. The first chunk
+// that does not verify, or is cut short, fails every later Read with a *ChunkError.
+type VerifyingReader struct {
+	r         io.Reader
+	root      [sha256.Size]byte
+	length    uint64
+	chunkSize uint64
+	sizes     []uint64
+	proof     func(i uint64) (InclusionProof, error)
+
+	chunks   uint64
+	next     uint64
+	chunk    []byte
+	verified []byte
+	err      error
+}
+
+// NewVerifyingReader returns a reader verifying r against root, calling proof for
+// the inclusion proof of every chunk before it is returned
+func NewVerifyingReader(r io.Reader, root [sha256.Size]byte, length uint64, chunkSize int, proof func(i uint64) (InclusionProof, error)) (*VerifyingReader, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("%w: chunk size %d", ErrInvalidRange, chunkSize)
+	}
+	v := &VerifyingReader{
+		r:         r,
+		root:      root,
+		length:    length,
+		chunkSize: uint64(chunkSize),
+		proof:     proof,
+		chunks:    (length + uint64(chunkSize) - 1) / uint64(chunkSize),
+		chunk:     make([]byte, chunkSize),
+	}
+	if length == 0 && root != sha256.Sum256(nil) {
+		return nil, fmt.Errorf("%w: empty stream for the root of a non-empty tree", ErrRootMismatch)
+	}
+	return v, nil
+}
+
+// NewLayoutVerifyingReader returns a reader verifying r against root like
+// NewVerifyingReader, for a stream cut into the chunks of layout, as built by
+// NewFromChunks WithContentDefinedChunking
+func NewLayoutVerifyingReader(r io.Reader, root [sha256.Size]byte, layout ChunkLayout, proof func(i uint64) (InclusionProof, error)) (*VerifyingReader, error) {
+	if err := layout.Params.validate(); err != nil {
+		return nil, err
+	}
+	v, err := NewVerifyingReader(r, root, layout.Length(), layout.Params.Max, proof)
+	if err != nil {
+		return nil, err
+	}
+	v.sizes = layout.Sizes
+	v.chunks = uint64(len(layout.Sizes))
+	return v, nil
+}
+
+// Read reads verified bytes into p
+func (v *VerifyingReader) Read(p []byte) (int, error) {
+	if len(v.verified) == 0 {
+		if v.err != nil {
+			return 0, v.err
+		}
+		if err := v.readChunk(); err != nil {
+			v.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, v.verified)
+	v.verified = v.verified[n:]
+	return n, nil
+}
+
+// readChunk reads and verifies the next chunk, or makes sure the stream ends after
+// the last one
+func (v *VerifyingReader) readChunk() error {
+	if v.next == v.chunks {
+		if n, _ := io.ReadFull(v.r, v.chunk[:1]); n > 0 {
+			return &ChunkError{Index: v.next, Err: fmt.Errorf("%w: data beyond %d bytes", ErrInvalidRange, v.length)}
+		}
+		return io.EOF
+	}
+
+	size := v.chunkSize
+	if v.sizes != nil {
+		size = v.sizes[v.next]
+		if size > v.chunkSize {
+			return &ChunkError{Index: v.next, Err: fmt.Errorf("%w: chunk of %d bytes", ErrInvalidRange, size)}
+		}
+	} else if v.next == v.chunks-1 {
+		size = v.length - v.next*v.chunkSize
+	}
+	chunk := v.chunk[:size]
+	if _, err := io.ReadFull(v.r, chunk); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return &ChunkError{Index: v.next, Err: err}
+	}
+
+	if err := verifyChunk(chunk, v.next, v.chunks, v.root, v.proof); err != nil {
+		return err
+	}
+	v.next++
+	v.verified = chunk
+	return nil
+}
+
+// verifyChunk checks chunk i of a chunk tree of the given number of chunks against
+// root with the inclusion proof returned by proof, failing with a *ChunkError
+func verifyChunk(chunk []byte, i, chunks uint64, root [sha256.Size]byte, proof func(i uint64) (InclusionProof, error)) error {
+	p, err := proof(i)
+	if err != nil {
+		return &ChunkError{Index: i, Err: err}
+	}
+	if p.LeafIndex != i || p.TreeSize != chunks {
+		return &ChunkError{Index: i, Err: fmt.Errorf("%w: proof for index %d of %d, want %d of %d", ErrInvalidProof, p.LeafIndex, p.TreeSize, i, chunks)}
+	}
+	if err := VerifyInclusion(leafHash(chunk), root, p); err != nil {
+		return &ChunkError{Index: i, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkedFile returns a file of 10 full chunks of 1000 bytes and a chunk of 337,
+// with its chunk tree
+func chunkedFile(t *testing.T) ([]byte, *MerkleHashTree) {
+	file := make([]byte, 10*1000+337)
+	for i := range file {
+		file[i] = byte(i * 7)
+	}
+	tree, err := NewFromChunks(bytes.NewReader(file), 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), tree.Size())
+	return file, tree
+}
+
+func chunkProofs(t *testing.T, tree *MerkleHashTree) []InclusionProof {
+	proofs := make([]InclusionProof, tree.Size())
+	for i := range proofs {
+		var err error
+		proofs[i], err = tree.InclusionProofByIndex(uint64(i))
+		assert.NoError(t, err)
+	}
+	return proofs
+}
+
+func TestVerifyingReader(t *testing.T) {
+	file, tree := chunkedFile(t)
+	proofs := chunkProofs(t, tree)
+
+	requested := make([]uint64, 0)
+	r, err := NewVerifyingReader(bytes.NewReader(file), tree.MerkleRoot(), uint64(len(file)), 1000, func(i uint64) (InclusionProof, error) {
+		requested = append(requested, i)
+		return proofs[i], nil
+	})
+	assert.NoError(t, err)
+	read, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, file, read)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, requested)
+
+	// A file that is a whole number of chunks, and the empty file
+	tree, err = NewFromChunks(bytes.NewReader(file[:3000]), 1000)
+	assert.NoError(t, err)
+	r, err = NewVerifyingReader(bytes.NewReader(file[:3000]), tree.MerkleRoot(), 3000, 1000, StaticChunkProofs(chunkProofs(t, tree)))
+	assert.NoError(t, err)
+	read, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, file[:3000], read)
+
+	r, err = NewVerifyingReader(bytes.NewReader(nil), sha256.Sum256(nil), 0, 1000, StaticChunkProofs(nil))
+	assert.NoError(t, err)
+	read, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, read)
+	_, err = NewVerifyingReader(bytes.NewReader(nil), tree.MerkleRoot(), 0, 1000, StaticChunkProofs(nil))
+	assert.ErrorIs(t, err, ErrRootMismatch)
+}
+
+func TestVerifyingReaderCorruptChunk(t *testing.T) {
+	file, tree := chunkedFile(t)
+	corrupt := append([]byte(nil), file...)
+	corrupt[4321] ^= 1
+
+	r, err := NewVerifyingReader(bytes.NewReader(corrupt), tree.MerkleRoot(), uint64(len(file)), 1000, StaticChunkProofs(chunkProofs(t, tree)))
+	assert.NoError(t, err)
+
+	// Reads of 333 bytes straddle chunk boundaries, yet stop exactly before the
+	// corrupt chunk
+	var read []byte
+	buf := make([]byte, 333)
+	for {
+		n, err := r.Read(buf)
+		read = append(read, buf[:n]...)
+		if err != nil {
+			var chunkErr *ChunkError
+			assert.True(t, errors.As(err, &chunkErr))
+			assert.Equal(t, uint64(4), chunkErr.Index)
+			assert.ErrorIs(t, err, ErrRootMismatch)
+			break
+		}
+	}
+	assert.Equal(t, file[:4000], read)
+	_, err = r.Read(buf)
+	assert.ErrorIs(t, err, ErrRootMismatch)
+}
+
+func TestVerifyingReaderLength(t *testing.T) {
+	file, tree := chunkedFile(t)
+	proofs := StaticChunkProofs(chunkProofs(t, tree))
+	var chunkErr *ChunkError
+
+	// A stream cut short in the final chunk
+	r, err := NewVerifyingReader(bytes.NewReader(file[:len(file)-1]), tree.MerkleRoot(), uint64(len(file)), 1000, proofs)
+	assert.NoError(t, err)
+	read, err := io.ReadAll(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.True(t, errors.As(err, &chunkErr))
+	assert.Equal(t, uint64(10), chunkErr.Index)
+	assert.Equal(t, file[:10000], read)
+
+	// A stream longer than announced
+	r, err = NewVerifyingReader(bytes.NewReader(append(file, 0)), tree.MerkleRoot(), uint64(len(file)), 1000, proofs)
+	assert.NoError(t, err)
+	read, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	assert.Equal(t, file, read)
+
+	// A proof for another chunk
+	r, err = NewVerifyingReader(bytes.NewReader(file), tree.MerkleRoot(), uint64(len(file)), 1000, func(i uint64) (InclusionProof, error) {
+		return proofs(0)
+	})
+	assert.NoError(t, err)
+	read, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+	assert.Equal(t, file[:1000], read)
+}
@@ -0,0 +1,191 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Swapper serves a tree that is replaced as a whole by a rebuilt one, e.g. when
+// the dataset it commits to is snapshotted again. The rebuilt tree is swapped in
+// atomically: a reader holding the tree returned by Tree keeps reading it, while
+// later calls see the new tree. It is safe for concurrent use.
+//
+// Swapper implements Tree by calling the current tree, so two calls may be
+// answered by different trees across a swap. Readers that need a consistent view,
+// such as a tree head and a proof for it, should call Tree once and use it.
+type Swapper struct {
+	current atomic.Pointer[MerkleHashTree]
+	// rebuild serializes rebuilds
+	rebuild sync.Mutex
+	record  string
+}
+
+var _ Tree = (*Swapper)(nil)
+
+// SwapperOption configures a Swapper
+type SwapperOption func(*Swapper)
+
+// WithSwapRecord commits every swap to the file at path before the new tree is
+// served, recording the name of the durable storage the tree was built into and
+// its tree head. The file is replaced atomically, so after a crash it names either
+// the old or the new tree, to be opened again with ReadSwapRecord.
+func WithSwapRecord(path string) SwapperOption {
+	return func(s *Swapper) {
+		s.record = path
+	}
+}
+
+// NewSwapper returns a swapper serving tree, which must be created WithLocking if
+// it is appended to while being served
+func NewSwapper(tree *MerkleHashTree, opts ...SwapperOption) *Swapper {
+	s := &Swapper{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.current.Store(tree)
+	return s
+}
+
+// SwapRecord is the tree committed by the latest swap of a Swapper WithSwapRecord
+type SwapRecord struct {
+	// Name is the name of the durable storage of the tree, as passed to Rebuild,
+	// e.g. the directory of a FileLog
+	Name string
+	TreeHead
+}
+
+type swapRecordJSON struct {
+	Name     string `json:"name"`
+	TreeSize uint64 `json:"tree_size"`
+	RootHash string `json:"root_hash"`
+}
+
+// ReadSwapRecord returns the record written to path by a Swapper WithSwapRecord
+func ReadSwapRecord(path string) (SwapRecord, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return SwapRecord{}, err
+	}
+	var r swapRecordJSON
+	if err := json.Unmarshal(b, &r); err != nil {
+		return SwapRecord{}, err
+	}
+	record := SwapRecord{Name: r.Name, TreeHead: TreeHead{TreeSize: r.TreeSize}}
+	if err := decodeHexHash(r.RootHash, &record.RootHash); err != nil {
+		return SwapRecord{}, err
+	}
+	return record, nil
+}
+
+// Tree returns the tree currently served
+func (s *Swapper) Tree() *MerkleHashTree {
+	return s.current.Load()
+}
+
+type rebuildConfig struct {
+	expected *[sha256.Size]byte
+	diff     func([]Range)
+}
+
+// RebuildOption configures a call to Swapper.Rebuild
+type RebuildOption func(*rebuildConfig)
+
+// ExpectRoot only swaps in a rebuilt tree with the given root
+func ExpectRoot(root [sha256.Size]byte) RebuildOption {
+	return func(c *rebuildConfig) {
+		c.expected = &root
+	}
+}
+
+// WithRebuildDiff calls report with the ranges of leaves of the rebuilt tree that
+// differ from the tree it replaces, as planned by PlanSync, before the swap
+func WithRebuildDiff(report func([]Range)) RebuildOption {
+	return func(c *rebuildConfig) {
+		c.diff = report
+	}
+}
+
+// Rebuild calls build for a new tree, while the current one is still served,
+// checks it and swaps it in, returning its tree head. name is the name of the
+// durable storage build writes the tree to, recorded WithSwapRecord. A rebuilt
+// tree without the root expected with ExpectRoot fails with ErrRootMismatch and
+// is not served, and so is one whose swap cannot be recorded. Rebuilds are run
+// one at a time, so Rebuild is typically called in its own goroutine.
+func (s *Swapper) Rebuild(name string, build func() (*MerkleHashTree, error), opts ...RebuildOption) (TreeHead, error) {
+	var c rebuildConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	s.rebuild.Lock()
+	defer s.rebuild.Unlock()
+
+	tree, err := build()
+	if err != nil {
+		return TreeHead{}, err
+	}
+	head := tree.TreeHead()
+	if c.expected != nil && head.RootHash != *c.expected {
+		return TreeHead{}, fmt.Errorf("%w: rebuilt root %x, expected %x", ErrRootMismatch, head.RootHash, *c.expected)
+	}
+	if c.diff != nil {
+		plan, err := PlanSync(s.current.Load(), tree, head.TreeSize)
+		if err != nil {
+			return TreeHead{}, err
+		}
+		c.diff(plan)
+	}
+
+	if s.record != "" {
+		b, _ := json.Marshal(swapRecordJSON{Name: name, TreeSize: head.TreeSize, RootHash: hex.EncodeToString(head.RootHash[:])})
+		if err := writeFileAtomic(s.record, b); err != nil {
+			return TreeHead{}, err
+		}
+	}
+	s.current.Store(tree)
+	return head, nil
+}
+
+// Size returns the size of the current tree
+func (s *Swapper) Size() uint64 {
+	return s.Tree().Size()
+}
+
+// MerkleRoot returns the root of the current tree
+func (s *Swapper) MerkleRoot() [sha256.Size]byte {
+	return s.Tree().MerkleRoot()
+}
+
+// TreeHead returns the tree head of the current tree
+func (s *Swapper) TreeHead() TreeHead {
+	return s.Tree().TreeHead()
+}
+
+// LeafIndex returns the index of the first leaf with the given leaf hash in the
+// current tree, or ErrLeafNotFound
+func (s *Swapper) LeafIndex(leafHash [sha256.Size]byte) (uint64, error) {
+	return s.Tree().LeafIndex(leafHash)
+}
+
+// InclusionProofAtSize returns the audit path of the leaf at index i in the current
+// tree of the first n leaves
+func (s *Swapper) InclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	return s.Tree().InclusionProofAtSize(i, n)
+}
+
+// ConsistencyProof returns the consistency proof between the current trees of the
+// first m and n leaves
+func (s *Swapper) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	return s.Tree().ConsistencyProof(m, n)
+}
+
+// TryAppend appends to the current tree. Leaves appended while a rebuild runs are
+// not in the rebuilt tree.
+func (s *Swapper) TryAppend(d ...[]byte) ([sha256.Size]byte, error) {
+	return s.Tree().TryAppend(d...)
+}
@@ -0,0 +1,119 @@
+package merkletree
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwapperServesAcrossSwaps(t *testing.T) {
+	D := makeEntries(64)
+	versions := make([][][]byte, 4)
+	heads := make(map[TreeHead]int)
+	for v := range versions {
+		versions[v] = append([][]byte(nil), D...)
+		versions[v][v*7] = []byte{byte(v), 0xff}
+		heads[New(versions[v]).TreeHead()] = v
+	}
+	s := NewSwapper(New(versions[0]))
+
+	var stop atomic.Bool
+	var served atomic.Int64
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := uint64(r); !stop.Load(); i++ {
+				tree := s.Tree()
+				head := tree.TreeHead()
+				v, ok := heads[head]
+				if !ok {
+					t.Errorf("served unknown tree head %x", head.RootHash)
+					return
+				}
+				idx := i % head.TreeSize
+				p, err := tree.InclusionProofAtSize(idx, head.TreeSize)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := VerifyInclusion(leafHash(versions[v][idx]), head.RootHash, p); err != nil {
+					t.Errorf("torn proof for leaf %d: %v", idx, err)
+					return
+				}
+				served.Add(1)
+			}
+		}(r)
+	}
+
+	for v := 1; v < len(versions); v++ {
+		want := New(versions[v]).TreeHead()
+		var diff []Range
+		head, err := s.Rebuild("", func() (*MerkleHashTree, error) {
+			return New(versions[v]), nil
+		}, ExpectRoot(want.RootHash), WithRebuildDiff(func(r []Range) { diff = r }))
+		assert.NoError(t, err)
+		assert.Equal(t, want, head)
+		assert.Equal(t, want, s.TreeHead())
+		assert.NotEmpty(t, diff)
+		for _, r := range diff {
+			assert.LessOrEqual(t, r.Start, uint64(v*7))
+		}
+	}
+	for served.Load() < 1000 && !t.Failed() {
+		runtime.Gosched()
+	}
+	stop.Store(true)
+	wg.Wait()
+}
+
+func TestSwapperRejectsRebuild(t *testing.T) {
+	D := makeEntries(10)
+	s := NewSwapper(New(D))
+	before := s.TreeHead()
+
+	_, err := s.Rebuild("", func() (*MerkleHashTree, error) {
+		return New(D[:9]), nil
+	}, ExpectRoot(before.RootHash))
+	assert.ErrorIs(t, err, ErrRootMismatch)
+	assert.Equal(t, before, s.TreeHead())
+
+	errBuild := errors.New("build failed")
+	_, err = s.Rebuild("", func() (*MerkleHashTree, error) {
+		return nil, errBuild
+	})
+	assert.ErrorIs(t, err, errBuild)
+	assert.Equal(t, before, s.TreeHead())
+}
+
+func TestSwapperRecord(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "current")
+	D := makeEntries(20)
+	s := NewSwapper(New(D[:10]), WithSwapRecord(path))
+
+	head, err := s.Rebuild("gen-2", func() (*MerkleHashTree, error) {
+		return New(D), nil
+	})
+	assert.NoError(t, err)
+	record, err := ReadSwapRecord(path)
+	assert.NoError(t, err)
+	assert.Equal(t, SwapRecord{Name: "gen-2", TreeHead: head}, record)
+
+	// A swap that cannot be recorded is not served
+	s = NewSwapper(New(D[:10]), WithSwapRecord(filepath.Join(dir, "missing", "current")))
+	before := s.TreeHead()
+	_, err = s.Rebuild("gen-3", func() (*MerkleHashTree, error) {
+		return New(D), nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, before, s.TreeHead())
+	_, err = ReadSwapRecord(filepath.Join(dir, "missing", "current"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,420 @@
+package merkletree
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// Defaults of a TieredLog
+const (
+	defaultHotLevel       = 16
+	defaultHotLeaves      = 1 << 16
+	defaultColdCacheNodes = 4096
+)
+
+// TieredLog is an append-only log whose perfect subtrees are all written to a
+// BatchNodeStorage, of which only the hot ones are kept in memory: the nodes at or
+// above a level, 16 unless set WithHotLevel, and the nodes covering one of the most
+// recent leaves, 65536 unless set WithHotLeaves. Cold nodes are evicted and read
+// back from storage when a proof needs them, through an LRU cache of 4096 nodes
+// unless set WithColdCacheNodes. The nodes appends hash together are always hot,
+// so appending never reads storage. It is safe for concurrent use.
+type TieredLog struct {
+	mu sync.RWMutex
+
+	storage   BatchNodeStorage
+	hotLevel  int
+	hotLeaves uint64
+	cache     *nodeCache
+
+	size uint64
+	// levels[l] holds the perfect nodes at level l from index offsets[l] on, the
+	// first node that is not evicted
+	levels  [][][sha256.Size]byte
+	offsets []uint64
+}
+
+var _ Tree = (*TieredLog)(nil)
+
+// TieredOption configures a TieredLog
+type TieredOption func(*TieredLog)
+
+// WithHotLevel keeps every node at or above level l in memory
+func WithHotLevel(l int) TieredOption {
+	return func(t *TieredLog) {
+		t.hotLevel = l
+	}
+}
+
+// WithHotLeaves keeps the nodes covering one of the last n leaves in memory
+func WithHotLeaves(n uint64) TieredOption {
+	return func(t *TieredLog) {
+		t.hotLeaves = n
+	}
+}
+
+// WithColdCacheNodes caches up to n nodes read from storage
+func WithColdCacheNodes(n int) TieredOption {
+	return func(t *TieredLog) {
+		t.cache = newNodeCache(n)
+	}
+}
+
+// OpenTieredLog returns the log stored in storage, reading its hot nodes
+func OpenTieredLog(storage BatchNodeStorage, opts ...TieredOption) (*TieredLog, error) {
+	t := &TieredLog{
+		storage:   storage,
+		hotLevel:  defaultHotLevel,
+		hotLeaves: defaultHotLeaves,
+		cache:     newNodeCache(defaultColdCacheNodes),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	size, err := storage.Size()
+	if err != nil {
+		return nil, err
+	}
+	var ids []NodeID
+	for l := 0; size>>l > 0; l++ {
+		t.offsets = append(t.offsets, t.evictBefore(l, size))
+		for i := t.offsets[l]; i < size>>l; i++ {
+			ids = append(ids, NodeID{Level: uint64(l), Index: i})
+		}
+	}
+	hashes, err := storage.ReadNodes(ids)
+	if err != nil {
+		return nil, fmt.Errorf("%w: size %d: %v", ErrCorruptStorage, size, err)
+	}
+	if len(hashes) != len(ids) {
+		return nil, fmt.Errorf("%w: %d nodes read for %d", ErrCorruptStorage, len(hashes), len(ids))
+	}
+	t.levels = make([][][sha256.Size]byte, len(t.offsets))
+	for j, id := range ids {
+		t.levels[id.Level] = append(t.levels[id.Level], hashes[j])
+	}
+	t.size = size
+	return t, nil
+}
+
+// evictBefore returns the index of the first node at level l that is hot in the
+// log of size leaves. A node is hot at or above the hot level, when it covers one
+// of the hot leaves, or when it is the left child of a parent still incomplete.
+func (t *TieredLog) evictBefore(l int, size uint64) uint64 {
+	if l >= t.hotLevel {
+		return 0
+	}
+	var cold uint64
+	if size > t.hotLeaves {
+		cold = (size - t.hotLeaves) >> l
+	}
+	if complete := size >> l &^ 1; cold > complete {
+		cold = complete
+	}
+	return cold
+}
+
+// TryAppend appends a leaf for every entry of d and returns the new root. The new
+// perfect subtrees are written to storage as a single batch first, and an error
+// writing them leaves the log unchanged.
+func (t *TieredLog) TryAppend(d ...[]byte) ([sha256.Size]byte, error) {
+	leaves := hashEntries(d)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	levels := make([][][sha256.Size]byte, len(t.levels))
+	copy(levels, t.levels)
+	batch := WriteBatch{Size: t.size + uint64(len(leaves))}
+	for j, leaf := range leaves {
+		hash := leaf
+		for l, i := 0, t.size+uint64(j); ; l, i = l+1, i/2 {
+			if l == len(levels) {
+				levels = append(levels, nil)
+			}
+			levels[l] = append(levels[l], hash)
+			batch.Nodes = append(batch.Nodes, ProofNode{NodeID: NodeID{Level: uint64(l), Index: i}, Hash: hash})
+			if i%2 == 0 {
+				break
+			}
+			left := levels[l][i-1-t.offset(l)]
+			hash = nodeHash(append(left[:], hash[:]...))
+		}
+	}
+	if err := t.storage.WriteBatch(batch); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	t.levels = levels
+	t.size = batch.Size
+	for len(t.offsets) < len(t.levels) {
+		t.offsets = append(t.offsets, 0)
+	}
+	t.evict()
+	return t.rootHash()
+}
+
+// offset returns offsets[l], 0 for a level with no nodes yet
+func (t *TieredLog) offset(l int) uint64 {
+	if l < len(t.offsets) {
+		return t.offsets[l]
+	}
+	return 0
+}
+
+// evict drops the cold nodes of a level once they make up half of it, so that
+// every node is moved at most once on average
+func (t *TieredLog) evict() {
+	for l := range t.levels {
+		k := t.evictBefore(l, t.size) - t.offsets[l]
+		if k == 0 || 2*k < uint64(len(t.levels[l])) {
+			continue
+		}
+		hot := make([][sha256.Size]byte, uint64(len(t.levels[l]))-k)
+		copy(hot, t.levels[l][k:])
+		t.levels[l] = hot
+		t.offsets[l] += k
+	}
+}
+
+// HotNodes returns the number of nodes held in memory, not counting the cache of
+// cold nodes
+func (t *TieredLog) HotNodes() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := 0
+	for _, level := range t.levels {
+		n += len(level)
+	}
+	return n
+}
+
+// ReadNodes returns the hashes of the perfect subtrees ids, from memory, the cache
+// or else storage, reading all the nodes missing from the first two at once
+func (t *TieredLog) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.readNodes(ids)
+}
+
+func (t *TieredLog) readNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	hashes := make([][sha256.Size]byte, len(ids))
+	var cold []NodeID
+	var coldAt []int
+	for j, id := range ids {
+		if !isPerfect(id, t.size) {
+			return nil, &MissingNodeError{Node: id}
+		}
+		if l := int(id.Level); id.Index >= t.offsets[l] {
+			hashes[j] = t.levels[l][id.Index-t.offsets[l]]
+			continue
+		}
+		if h, ok := t.cache.get(id); ok {
+			hashes[j] = h
+			continue
+		}
+		cold = append(cold, id)
+		coldAt = append(coldAt, j)
+	}
+	if len(cold) == 0 {
+		return hashes, nil
+	}
+
+	read, err := t.storage.ReadNodes(cold)
+	if err != nil {
+		return nil, err
+	}
+	if len(read) != len(cold) {
+		return nil, fmt.Errorf("%w: storage returned %d nodes for %d", ErrMissingNode, len(read), len(cold))
+	}
+	for k, h := range read {
+		hashes[coldAt[k]] = h
+		t.cache.add(cold[k], h)
+	}
+	return hashes, nil
+}
+
+// prover returns a prover reading the nodes of the log, which is locked
+func (t *TieredLog) prover() *Prover {
+	return NewProver(tieredNodes{t}, t.size)
+}
+
+// tieredNodes reads the nodes of a TieredLog whose lock is already held
+type tieredNodes struct {
+	t *TieredLog
+}
+
+func (s tieredNodes) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	return s.t.readNodes(ids)
+}
+
+// rootHash returns the root of the log, which only needs hot nodes
+func (t *TieredLog) rootHash() ([sha256.Size]byte, error) {
+	return t.rootAt(t.size)
+}
+
+func (t *TieredLog) rootAt(n uint64) ([sha256.Size]byte, error) {
+	if n == 0 {
+		return sha256.Sum256(nil), nil
+	}
+	hashes, err := t.prover().rangeHashes([][2]uint64{{0, n}})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return hashes[0], nil
+}
+
+// Size returns the number of leaves of the log
+func (t *TieredLog) Size() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// MerkleRoot returns the root of the log
+func (t *TieredLog) MerkleRoot() [sha256.Size]byte {
+	return t.TreeHead().RootHash
+}
+
+// TreeHead returns the size and root of the log
+func (t *TieredLog) TreeHead() TreeHead {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	root, _ := t.rootHash()
+	return TreeHead{TreeSize: t.size, RootHash: root}
+}
+
+// RootAt returns the root of the log at size n, which may read cold nodes
+func (t *TieredLog) RootAt(n uint64) ([sha256.Size]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if n > t.size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, n, t.size)
+	}
+	return t.rootAt(n)
+}
+
+// LeafHash returns the leaf hash at index i
+func (t *TieredLog) LeafHash(i uint64) ([sha256.Size]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if i >= t.size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, t.size)
+	}
+	hashes, err := t.readNodes([]NodeID{{Index: i}})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return hashes[0], nil
+}
+
+// LeafIndex returns the index of the first leaf with the given leaf hash, or
+// ErrLeafNotFound. Cold leaves are read from storage a batch at a time, bypassing
+// the cache, so this is a scan of the whole log.
+func (t *TieredLog) LeafIndex(leafHash [sha256.Size]byte) (uint64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.size == 0 {
+		return 0, ErrLeafNotFound
+	}
+
+	const batch = 1024
+	ids := make([]NodeID, 0, batch)
+	for start := uint64(0); start < t.offsets[0]; start += batch {
+		ids = ids[:0]
+		for i := start; i < start+batch && i < t.offsets[0]; i++ {
+			ids = append(ids, NodeID{Index: i})
+		}
+		hashes, err := t.storage.ReadNodes(ids)
+		if err != nil {
+			return 0, err
+		}
+		if i := IndexOf(hashes, leafHash); i >= 0 {
+			return start + uint64(i), nil
+		}
+	}
+	if i := IndexOf(t.levels[0], leafHash); i >= 0 {
+		return t.offsets[0] + uint64(i), nil
+	}
+	return 0, ErrLeafNotFound
+}
+
+// InclusionProofAtSize returns the audit path of the leaf at index i in the log of
+// the first n leaves
+func (t *TieredLog) InclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.prover().InclusionProof(i, n)
+}
+
+// ConsistencyProof returns the consistency proof between the logs of the first m
+// and n leaves
+func (t *TieredLog) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.prover().ConsistencyProof(m, n)
+}
+
+// nodeCache is a bounded LRU cache of node hashes safe for concurrent use
+type nodeCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[NodeID]*list.Element
+	order    *list.List
+}
+
+type nodeCacheEntry struct {
+	id   NodeID
+	hash [sha256.Size]byte
+}
+
+func newNodeCache(capacity int) *nodeCache {
+	return &nodeCache{
+		capacity: capacity,
+		entries:  make(map[NodeID]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the cached hash of id
+func (c *nodeCache) get(id NodeID) ([sha256.Size]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[id]
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*nodeCacheEntry).hash, true
+}
+
+// add stores the hash of id, evicting the least recently used node when full
+func (c *nodeCache) add(id NodeID, hash [sha256.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[id]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.capacity <= 0 {
+		return
+	}
+	c.entries[id] = c.order.PushFront(&nodeCacheEntry{id: id, hash: hash})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*nodeCacheEntry).id)
+	}
+}
+
+// len returns the number of cached nodes
+func (c *nodeCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
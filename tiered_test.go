@@ -0,0 +1,188 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingNodeStorage is a MemoryNodeStorage counting the nodes read from it
+type countingNodeStorage struct {
+	*MemoryNodeStorage
+	reads int
+	fail  error
+}
+
+func (s *countingNodeStorage) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	s.reads += len(ids)
+	return s.MemoryNodeStorage.ReadNodes(ids)
+}
+
+func (s *countingNodeStorage) WriteBatch(b WriteBatch) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	return s.MemoryNodeStorage.WriteBatch(b)
+}
+
+func TestTieredLogMatchesTree(t *testing.T) {
+	D := makeEntries(300)
+	tree := New(nil)
+	storage := &countingNodeStorage{MemoryNodeStorage: NewMemoryNodeStorage()}
+	log, err := OpenTieredLog(storage, WithHotLevel(4), WithHotLeaves(16), WithColdCacheNodes(8))
+	assert.NoError(t, err)
+
+	for j := 0; j < len(D); j += 7 {
+		end := j + 7
+		if end > len(D) {
+			end = len(D)
+		}
+		tree.Append(D[j:end]...)
+		root, err := log.TryAppend(D[j:end]...)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.MerkleRoot(), root)
+	}
+	assert.Equal(t, 0, storage.reads, "appends read storage")
+	assert.Equal(t, tree.TreeHead(), log.TreeHead())
+
+	n := uint64(len(D))
+	for i := uint64(0); i < n; i += 13 {
+		want, err := tree.InclusionProofAtSize(i, n)
+		assert.NoError(t, err)
+		got, err := log.InclusionProofAtSize(i, n)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "leaf %d", i)
+
+		m := i + 1 + (n-i-1)/2
+		want, err = tree.InclusionProofAtSize(i, m)
+		assert.NoError(t, err)
+		got, err = log.InclusionProofAtSize(i, m)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "leaf %d of %d", i, m)
+	}
+	for m := uint64(0); m <= n; m += 11 {
+		want, err := tree.ConsistencyProof(m, n)
+		assert.NoError(t, err)
+		got, err := log.ConsistencyProof(m, n)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "consistency %d to %d", m, n)
+
+		root, err := log.RootAt(m)
+		assert.NoError(t, err)
+		assert.Equal(t, tree.rootAtSize(m), root)
+	}
+
+	i, err := log.LeafIndex(leafHash(D[5]))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), i)
+	i, err = log.LeafIndex(leafHash(D[n-1]))
+	assert.NoError(t, err)
+	assert.Equal(t, n-1, i)
+	_, err = log.LeafIndex(leafHash([]byte("missing")))
+	assert.True(t, errors.Is(err, ErrLeafNotFound))
+	h, err := log.LeafHash(3)
+	assert.NoError(t, err)
+	assert.Equal(t, leafHash(D[3]), h)
+	assert.LessOrEqual(t, log.cache.len(), 8)
+}
+
+func TestTieredLogBoundedMemory(t *testing.T) {
+	storage := &countingNodeStorage{MemoryNodeStorage: NewMemoryNodeStorage()}
+	log, err := OpenTieredLog(storage, WithHotLevel(6), WithHotLeaves(64), WithColdCacheNodes(32))
+	assert.NoError(t, err)
+
+	D := makeEntries(1 << 12)
+	for j := 0; j < len(D); j += 16 {
+		_, err := log.TryAppend(D[j : j+16]...)
+		assert.NoError(t, err)
+	}
+	// The hot nodes are those at or above level 6, at most two windows of 64
+	// leaves below it and the left children of incomplete parents
+	hot := log.HotNodes()
+	bound := 2*(1<<12>>6) + 2*(2*64) + 12
+	assert.LessOrEqual(t, hot, bound)
+	assert.Less(t, hot, 2*len(D)/4)
+
+	root := log.MerkleRoot()
+	assert.Equal(t, New(D).MerkleRoot(), root)
+	for _, i := range []uint64{0, 1, 100, 1000, 2047} {
+		before := storage.reads
+		proof, err := log.InclusionProofAtSize(i, log.Size())
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyInclusion(leafHash(D[i]), root, proof), "leaf %d", i)
+		assert.Greater(t, storage.reads, before, "cold proof of leaf %d read no nodes", i)
+	}
+
+	// A second proof of the same leaf is served from the cache
+	before := storage.reads
+	_, err = log.InclusionProofAtSize(2047, log.Size())
+	assert.NoError(t, err)
+	assert.Equal(t, before, storage.reads)
+	assert.Equal(t, hot, log.HotNodes())
+}
+
+func TestTieredLogReopen(t *testing.T) {
+	D := makeEntries(200)
+	storage := &countingNodeStorage{MemoryNodeStorage: NewMemoryNodeStorage()}
+	log, err := OpenTieredLog(storage, WithHotLevel(3), WithHotLeaves(10))
+	assert.NoError(t, err)
+	_, err = log.TryAppend(D[:150]...)
+	assert.NoError(t, err)
+
+	reopened, err := OpenTieredLog(storage, WithHotLevel(3), WithHotLeaves(10))
+	assert.NoError(t, err)
+	assert.Equal(t, log.TreeHead(), reopened.TreeHead())
+	root, err := reopened.TryAppend(D[150:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, New(D).MerkleRoot(), root)
+}
+
+func TestTieredLogFailedWrite(t *testing.T) {
+	D := makeEntries(40)
+	storage := &countingNodeStorage{MemoryNodeStorage: NewMemoryNodeStorage()}
+	log, err := OpenTieredLog(storage, WithHotLevel(2), WithHotLeaves(4))
+	assert.NoError(t, err)
+	_, err = log.TryAppend(D[:30]...)
+	assert.NoError(t, err)
+	head := log.TreeHead()
+
+	storage.fail = errors.New("disk full")
+	_, err = log.TryAppend(D[30:]...)
+	assert.Equal(t, storage.fail, err)
+	assert.Equal(t, head, log.TreeHead())
+
+	storage.fail = nil
+	root, err := log.TryAppend(D[30:]...)
+	assert.NoError(t, err)
+	assert.Equal(t, New(D).MerkleRoot(), root)
+}
+
+func BenchmarkTieredLogProof(b *testing.B) {
+	const n = 1 << 16
+	storage := NewMemoryNodeStorage()
+	log, err := OpenTieredLog(storage, WithHotLevel(10), WithHotLeaves(1024), WithColdCacheNodes(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	D := makeEntries(n)
+	for j := 0; j < n; j += 1024 {
+		if _, err := log.TryAppend(D[j : j+1024]...); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, bc := range []struct {
+		name  string
+		index uint64
+	}{{"hot", n - 1}, {"cold", 12345}} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := log.InclusionProofAtSize(bc.index, n); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
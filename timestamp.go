@@ -0,0 +1,87 @@
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors returned when verifying the timestamps of checkpoints
+var (
+	ErrInvalidTimestamp = errors.New("merkletree: invalid checkpoint timestamp")
+	ErrNoTimestamp      = errors.New("merkletree: no verifiable checkpoint timestamp")
+)
+
+// timestampNamePrefix marks the lines of timestamp tokens among the signature lines
+// of a signed note. Verifiers not knowing it ignore them as signatures by unknown
+// keys, so timestamped checkpoints stay valid signed notes.
+const timestampNamePrefix = "timestamp:"
+
+// TimestampToken is an attestation by an external authority, e.g. an RFC 3161
+// time-stamp token of a TSA, that the note text of a checkpoint existed at some
+// time. The token is opaque to this package and checked by a TimestampVerifier.
+// In the signed note format it is the signature line
+// "— timestamp:<authority> <base64 token>".
+type TimestampToken struct {
+	Authority string
+	Token     []byte
+}
+
+// Timestamper obtains a token for message from a timestamp authority. For an RFC
+// 3161 TSA, the message imprint of the request is the SHA-256 of message.
+type Timestamper func(message []byte) ([]byte, error)
+
+// TimestampVerifier checks that token attests message, the note text of a
+// checkpoint, and returns the time it attests
+type TimestampVerifier func(message, token []byte) (time.Time, error)
+
+// VerifiedTimestamp is the time a timestamp authority attests for a checkpoint
+type VerifiedTimestamp struct {
+	Authority string
+	Time      time.Time
+}
+
+// AddTimestamp obtains a token covering the note text of the checkpoint, which
+// commits to its origin, size and root, from the authority with stamp, and
+// attaches it. Signatures are not affected.
+func (c *CosignedCheckpoint) AddTimestamp(authority string, stamp Timestamper) error {
+	if authority == "" || strings.ContainsAny(authority, " \n+") {
+		return fmt.Errorf("%w: invalid authority name %q", ErrInvalidCheckpoint, authority)
+	}
+	token, err := stamp([]byte(c.Text()))
+	if err != nil {
+		return err
+	}
+	if len(token) == 0 {
+		return fmt.Errorf("%w: empty token of %s", ErrInvalidTimestamp, authority)
+	}
+	c.Timestamps = append(c.Timestamps, TimestampToken{Authority: authority, Token: token})
+	return nil
+}
+
+// VerifyTimestamps checks the tokens of the checkpoint against the note text with
+// the verifiers of their authorities, by name, and returns the attested times.
+// Tokens of authorities without a verifier are ignored, but a token failing its
+// verifier fails with ErrInvalidTimestamp, and a checkpoint without any verified
+// token with ErrNoTimestamp. Signatures are checked separately, with
+// VerifyThreshold.
+func (c *CosignedCheckpoint) VerifyTimestamps(verifiers map[string]TimestampVerifier) ([]VerifiedTimestamp, error) {
+	message := []byte(c.Text())
+	var verified []VerifiedTimestamp
+	for _, ts := range c.Timestamps {
+		verify, ok := verifiers[ts.Authority]
+		if !ok {
+			continue
+		}
+		at, err := verify(message, ts.Token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidTimestamp, ts.Authority, err)
+		}
+		verified = append(verified, VerifiedTimestamp{Authority: ts.Authority, Time: at})
+	}
+	if len(verified) == 0 {
+		return nil, fmt.Errorf("%w: %d tokens", ErrNoTimestamp, len(c.Timestamps))
+	}
+	return verified, nil
+}
@@ -0,0 +1,90 @@
+package merkletree
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAttestor timestamps messages with an HMAC over the time and the SHA-256 of
+// the message, standing in for a TSA
+type fakeAttestor struct {
+	key []byte
+	now time.Time
+}
+
+func (a fakeAttestor) mac(at uint64, message []byte) []byte {
+	digest := sha256.Sum256(message)
+	h := hmac.New(sha256.New, a.key)
+	binary.Write(h, binary.BigEndian, at)
+	h.Write(digest[:])
+	return h.Sum(nil)
+}
+
+func (a fakeAttestor) stamp(message []byte) ([]byte, error) {
+	at := uint64(a.now.Unix())
+	return append(binary.BigEndian.AppendUint64(nil, at), a.mac(at, message)...), nil
+}
+
+func (a fakeAttestor) verify(message, token []byte) (time.Time, error) {
+	if len(token) != 8+sha256.Size {
+		return time.Time{}, errors.New("malformed token")
+	}
+	at := binary.BigEndian.Uint64(token)
+	if !hmac.Equal(token[8:], a.mac(at, message)) {
+		return time.Time{}, errors.New("bad token")
+	}
+	return time.Unix(int64(at), 0), nil
+}
+
+func TestCheckpointTimestamps(t *testing.T) {
+	signers, pubkeys := cosigners(t)
+	tsa := fakeAttestor{key: []byte("tsa"), now: time.Unix(1700000000, 0)}
+	other := fakeAttestor{key: []byte("other"), now: time.Unix(1700000100, 0)}
+
+	c := NewCosignedCheckpoint("example.com/log", New(makeEntries(9)).TreeHead(), "extra")
+	assert.NoError(t, c.AddSignature("log", signers[0]))
+	_, err := c.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify})
+	assert.ErrorIs(t, err, ErrNoTimestamp)
+
+	assert.NoError(t, c.AddTimestamp("tsa", tsa.stamp))
+	assert.NoError(t, c.AddTimestamp("other", other.stamp))
+
+	// Timestamps survive the signed note format, and don't affect signatures
+	var decoded CosignedCheckpoint
+	assert.NoError(t, decoded.UnmarshalText([]byte(c.String())))
+	assert.Equal(t, *c, decoded)
+	assert.NoError(t, decoded.VerifyThreshold(pubkeys, 1))
+	_, err = VerifyRekorCheckpoint(c.String(), pubkeys["log"])
+	assert.NoError(t, err)
+
+	verified, err := decoded.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify, "other": other.verify})
+	assert.NoError(t, err)
+	assert.Equal(t, []VerifiedTimestamp{{"tsa", tsa.now}, {"other", other.now}}, verified)
+	verified, err = decoded.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify})
+	assert.NoError(t, err)
+	assert.Len(t, verified, 1)
+
+	// A token moved to another checkpoint doesn't verify
+	moved := NewCosignedCheckpoint("example.com/log", New(makeEntries(10)).TreeHead())
+	moved.Timestamps = decoded.Timestamps
+	_, err = moved.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify})
+	assert.ErrorIs(t, err, ErrInvalidTimestamp)
+
+	// Nor does a tampered token, or a token under the wrong authority
+	tampered := decoded
+	tampered.Timestamps = []TimestampToken{{Authority: "tsa", Token: append([]byte(nil), c.Timestamps[0].Token...)}}
+	tampered.Timestamps[0].Token[3] ^= 1
+	_, err = tampered.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify})
+	assert.ErrorIs(t, err, ErrInvalidTimestamp)
+	_, err = decoded.VerifyTimestamps(map[string]TimestampVerifier{"tsa": tsa.verify, "other": tsa.verify})
+	assert.ErrorIs(t, err, ErrInvalidTimestamp)
+
+	assert.ErrorIs(t, c.AddTimestamp("two words", tsa.stamp), ErrInvalidCheckpoint)
+	assert.ErrorIs(t, c.AddTimestamp("empty", func([]byte) ([]byte, error) { return nil, nil }), ErrInvalidTimestamp)
+}
@@ -3,19 +3,71 @@ package merkletree
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"math"
-	"strings"
+	"sync"
+	"time"
+
+	"github.com/viveksyngh/merkletree/verify"
 )
 
 // MerkleHashTree a general purpose merkle hash tree with support for append
 // it also stores the merkle hashes in a tree like structure
 type MerkleHashTree struct {
 	tree [][][sha256.Size]byte
+
+	mu      sync.RWMutex
+	locking bool
+
+	proofCache  *proofCache
+	metrics     Metrics
+	backend     HashBackend
+	hasher      *Hasher
+	parallelism int
+
+	indexBound bool
+	sorted     bool
+
+	deferred bool
+	pending  [][]byte
+
+	recordDeltas bool
+	lastDelta    []NodeDelta
+
+	entries    []storedEntry
+	codec      Codec
+	leafSource LeafSource
+	redacted   map[uint64]bool
+
+	keys         map[string][]uint64
+	keyOverwrite bool
+
+	storage BatchNodeStorage
+
+	admissionHook AdmissionHook
+
+	generation uint64
+
+	history     bool
+	retention   RetentionPolicy
+	roots       []RootRecord
+	unpublished [][2]uint64
+	clock       func() time.Time
+
+	cdc         *CDCParams
+	chunkLayout *ChunkLayout
+
+	maxLeaves    uint64
+	sealWhenFull bool
+	sealed       bool
 }
 
 // levels returns levels in a tree given the length of leave nodes
 func levels(nodes int) int {
+	if nodes <= 1 {
+		return 1
+	}
 	l := int(math.Log2(float64(nodes)))
 	if int(math.Pow(2, float64(l))) != nodes {
 		l = l + 2
@@ -25,24 +77,57 @@ func levels(nodes int) int {
 	return l
 }
 
-// New creates and returns a new merkle hash tree
-func New(d [][]byte) *MerkleHashTree {
-	leaves := make([][sha256.Size]byte, 0)
-	for _, e := range d {
-		leaves = append(leaves, leafHash(e))
+// New creates and returns a new merkle hash tree. It panics with the error of
+// TryNew when the tree cannot hold the entries d, use TryNew to handle it.
+func New(d [][]byte, opts ...Option) *MerkleHashTree {
+	tree, err := TryNew(d, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return tree
+}
+
+// TryNew creates and returns a new merkle hash tree holding the entries d, like New.
+// A Hasher without 32-byte digests fails with ErrUnsupportedHash, an entry rejected
+// by the admission hook with a *RejectedError, more entries than allowed
+// WithMaxLeaves with ErrLogFull, and a tree filled exactly by d is sealed
+// WithSealWhenFull.
+func TryNew(d [][]byte, opts ...Option) (*MerkleHashTree, error) {
+	tree := MerkleHashTree{}
+	for _, opt := range opts {
+		opt(&tree)
+	}
+	if err := tree.Hasher().fitsTree(); err != nil {
+		return nil, err
+	}
+	if err := tree.admit(0, d); err != nil {
+		return nil, err
+	}
+	if tree.maxLeaves > 0 && uint64(len(d)) > tree.maxLeaves {
+		return nil, fmt.Errorf("%w: %d leaves, %d remaining", ErrLogFull, len(d), tree.maxLeaves)
 	}
-	l := levels(len(d))
-	t := make([][][sha256.Size]byte, l)
-	t[0] = leaves
-	tree := MerkleHashTree{tree: t}
-	tree.buildTree(tree.tree[0], l-1)
-	return &tree
+	tree.tree = make([][][sha256.Size]byte, levels(len(d)))
+	tree.tree[0] = tree.hashLeaves(0, d)
+	tree.buildTree(tree.tree[0])
+	if tree.sealWhenFull && tree.remaining() == 0 {
+		tree.sealed = true
+	}
+	tree.recordRoot()
+	return &tree, nil
 }
 
 // leafHash returns hash of a leaf node
 func leafHash(input []byte) [sha256.Size]byte {
-	e := []byte{LeafPrefix}
-	e = append(e, input...)
+	return verify.LeafHash(input)
+}
+
+// IndexBoundLeafHash returns the hash of entry d as the leaf at index i of a tree
+// created WithIndexBoundLeaves: SHA-256(0x00 || uint64(i) || d), with i big endian.
+func IndexBoundLeafHash(i uint64, d []byte) [sha256.Size]byte {
+	e := make([]byte, 0, 1+8+len(d))
+	e = append(e, LeafPrefix)
+	e = binary.BigEndian.AppendUint64(e, i)
+	e = append(e, d...)
 	return sha256.Sum256(e)
 }
 
@@ -53,76 +138,116 @@ func nodeHash(input []byte) [sha256.Size]byte {
 	return sha256.Sum256(e)
 }
 
-// buildTree build a new merkle hash tree
-func (m *MerkleHashTree) buildTree(entries [][sha256.Size]byte, level int) [sha256.Size]byte {
-	n := uint64(len(entries))
-	if n == 0 {
-		return sha256.Sum256(nil)
+// buildTree builds the interior levels of a merkle hash tree from its leaves, sweeping
+// the tree bottom up one level at a time. Adjacent pairs of a level are hashed into the
+// next level, while a node left without a sibling on the right edge is carried up
+// unchanged until it can be paired. Each interior node is therefore stored at the level
+// matching the height of the subtree it commits to, so that the node covering leaves
+// [i*2^l, min((i+1)*2^l, n)) is found at tree[l][i].
+func (m *MerkleHashTree) buildTree(entries [][sha256.Size]byte) [sha256.Size]byte {
+	if len(entries) == 0 {
+		return m.emptyRoot()
 	}
 
-	if n == 1 {
-		return entries[0]
+	nodes := entries
+	for level := 1; len(nodes) > 1; level++ {
+		paired := make([][sha256.Size]byte, len(nodes)/2, (len(nodes)+1)/2)
+		parallelFor(len(paired), m.parallelism, func(start, end int) {
+			for i := start; i < end; i++ {
+				paired[i] = m.nodeHash(nodes[2*i], nodes[2*i+1])
+			}
+		})
+		m.tree[level] = paired
+
+		if len(nodes)%2 == 1 {
+			// The full slice expression makes the carried node live in a copy, outside of m.tree[level].
+			paired = append(paired[:len(paired):len(paired)], nodes[len(nodes)-1])
+		}
+		nodes = paired
 	}
-
-	k := largestPowerOf2SmallerThan(n)
-
-	left := m.buildTree(entries[0:k], level-1)
-	right := m.buildTree(entries[k:n], level-1)
-	final := append(left[:], right[:]...)
-	hash := nodeHash(final)
-	m.tree[level] = append(m.tree[level], hash)
-	return hash
+	return nodes[0]
 }
 
-// TODO: avoid building the entire tree and build only the part of the tree which needs to changed.
-// rebuildTree rebuilds the root hash of an exitsing merkle hash tree
-func (m *MerkleHashTree) rebuildTree(entries [][sha256.Size]byte, level int, levelIndexMap map[int]int) [sha256.Size]byte {
-	n := uint64(len(entries))
-	if n == 0 {
-		return sha256.Sum256(nil)
+// readLock acquires the read lock when locking is enabled and returns the function releasing it.
+// Entries buffered WithDeferredHashing are hashed into the tree first.
+func (m *MerkleHashTree) readLock() func() {
+	if m.deferred {
+		m.writeLock()()
 	}
+	if !m.locking {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
 
-	if n == 1 {
-		return entries[0]
+// writeLock acquires the write lock when locking is enabled and returns the function releasing it.
+// Entries buffered WithDeferredHashing are hashed into the tree first.
+func (m *MerkleHashTree) writeLock() func() {
+	unlock := m.lock()
+	m.hashPending()
+	return unlock
+}
+
+// lock acquires the write lock when locking is enabled, leaving buffered entries pending
+func (m *MerkleHashTree) lock() func() {
+	if !m.locking {
+		return func() {}
 	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
 
-	k := largestPowerOf2SmallerThan(n)
+// Append adds new leaf nodes to existing merkle hash tree and returns the new merkle root.
+// A batch rejected because the tree is full or sealed leaves the tree unchanged; use
+// TryAppend to tell it apart from an appended batch.
+func (m *MerkleHashTree) Append(d ...[]byte) [sha256.Size]byte {
+	root, _ := m.TryAppend(d...)
+	return root
+}
 
-	left := m.rebuildTree(entries[0:k], level-1, levelIndexMap)
-	right := m.rebuildTree(entries[k:n], level-1, levelIndexMap)
-	final := append(left[:], right[:]...)
-	hash := nodeHash(final)
+// RootMismatchError is returned by AppendIf when the tree's root is not the expected one
+type RootMismatchError struct {
+	Expected [sha256.Size]byte
+	Actual   [sha256.Size]byte
+}
 
-	index, _ := levelIndexMap[level]
-	if index == len(m.tree[level]) {
-		m.tree[level] = append(m.tree[level], hash)
-	} else {
-		m.tree[level][index] = hash
-	}
+func (e *RootMismatchError) Error() string {
+	return fmt.Sprintf("%v: expected %x, actual %x", ErrRootMismatch, e.Expected, e.Actual)
+}
 
-	levelIndexMap[level] = index + 1
-	return hash
+// Unwrap makes errors.Is(err, ErrRootMismatch) report true
+func (e *RootMismatchError) Unwrap() error {
+	return ErrRootMismatch
 }
 
-// Print prints the merkle hash tree
-func (m *MerkleHashTree) Print() {
-	l := len(m.tree)
-	tab := ""
-	for i := l - 1; i >= 0; i-- {
-		fmt.Print(strings.Repeat("  ", (1<<i)-1))
-		tab = strings.Repeat("  ", (1<<(i+1))-1)
-		for _, v := range m.tree[i] {
-			fmt.Printf("%.2x%s", v, tab)
-		}
-		fmt.Println()
+// AppendIf adds new leaf nodes only if the merkle root of the tree is expectedRoot,
+// and returns the new merkle root. Otherwise the tree is left unchanged and a
+// *RootMismatchError carrying the actual root is returned. With WithLocking the
+// check and the append happen atomically, which makes AppendIf a compare-and-swap
+// for serializing writers.
+func (m *MerkleHashTree) AppendIf(expectedRoot [sha256.Size]byte, d ...[]byte) ([sha256.Size]byte, error) {
+	hash := m.leafHasher(d)
+
+	defer m.writeLock()()
+	if actual := m.root(); actual != expectedRoot {
+		return [sha256.Size]byte{}, &RootMismatchError{Expected: expectedRoot, Actual: actual}
 	}
+	leaves, err := hash(uint64(len(m.tree[0])))
+	if err != nil {
+		return m.root(), err
+	}
+	return m.admitLeafHashes(leaves)
 }
 
-// Append adds new leaf nodes to existing merkle hash tree and returns the new merkle root
-func (m *MerkleHashTree) Append(d ...[]byte) [sha256.Size]byte {
-	for _, e := range d {
-		m.tree[0] = append(m.tree[0], leafHash(e))
+// appendLeafHashes adds leaf hashes to existing merkle hash tree and returns the new merkle root
+func (m *MerkleHashTree) appendLeafHashes(leaves [][sha256.Size]byte) [sha256.Size]byte {
+	if m.recordDeltas {
+		defer m.recordDelta(uint64(len(m.tree[0])))
 	}
+	oldSize := uint64(len(m.tree[0]))
+	m.generation++
+	m.tree[0] = append(m.tree[0], leaves...)
 
 	l := levels(len(m.tree[0]))
 	start := len(m.tree)
@@ -130,37 +255,162 @@ func (m *MerkleHashTree) Append(d ...[]byte) [sha256.Size]byte {
 		m.tree = append(m.tree, make([][sha256.Size]byte, 0))
 	}
 
-	return m.rebuildTree(m.tree[0], l-1, make(map[int]int))
+	m.extendTree(oldSize)
+	m.recordRoot()
+	return m.root()
+}
+
+// extendTree rehashes the interior nodes covering leaves from index oldSize on, after
+// leaves were appended, level by level as buildTree does. The nodes before them cover
+// complete subtrees of older leaves, which appended leaves leave unchanged, so only
+// the right edge of the tree is hashed: O(log n) nodes for a single leaf, instead of
+// the whole tree.
+func (m *MerkleHashTree) extendTree(oldSize uint64) {
+	nodes := uint64(len(m.tree[0]))
+	for level := 1; nodes > 1; level++ {
+		start := oldSize >> uint(level)
+		if start < uint64(len(m.tree[level])) {
+			m.tree[level] = m.tree[level][:start]
+		}
+		if end := nodes / 2; start < end {
+			stored := append(m.tree[level], make([][sha256.Size]byte, end-start)...)
+			parallelFor(int(end-start), m.parallelism, func(first, last int) {
+				for i := start + uint64(first); i < start+uint64(last); i++ {
+					left := m.storedOrCarried(uint64(level-1), 2*i)
+					right := m.storedOrCarried(uint64(level-1), 2*i+1)
+					stored[i] = m.nodeHash(left, right)
+				}
+			})
+			m.tree[level] = stored
+		}
+		// Count the node carried up unchanged on the right edge
+		nodes = (nodes + 1) / 2
+	}
+}
+
+// Size returns the number of leaves in the merkle hash tree
+func (m *MerkleHashTree) Size() uint64 {
+	defer m.readLock()()
+	return uint64(len(m.tree[0]))
+}
+
+// Height returns the number of levels of nodes above the leaves, the length of the
+// longest audit path: ceil(log2(n)) for n leaves, and 0 for a tree of at most one
+// leaf
+func (m *MerkleHashTree) Height() int {
+	defer m.readLock()()
+	return len(m.tree) - 1
+}
+
+// TreeHead returns the size and merkle root of the tree
+func (m *MerkleHashTree) TreeHead() TreeHead {
+	defer m.readLock()()
+	return TreeHead{TreeSize: uint64(len(m.tree[0])), RootHash: m.root()}
 }
 
 // MerkleRoot return root hash or merkle root of a merkle hash tree
 func (m *MerkleHashTree) MerkleRoot() [sha256.Size]byte {
+	defer m.readLock()()
+	return m.root()
+}
+
+// root returns the merkle root of the tree
+func (m *MerkleHashTree) root() [sha256.Size]byte {
+	if len(m.tree[0]) == 0 {
+		return m.emptyRoot()
+	}
 	return m.tree[len(m.tree)-1][0]
 }
 
-// InclusionProof returns inclusion proof for a merkle tree hash node
+// RootAtSize returns the merkle root of the first n leaves of the tree, MTH(D[0:n]),
+// computed from the stored nodes: the hash of the empty string for 0, the root of
+// the tree for its size and ErrInvalidRange beyond it. Unlike RootAt it ignores the
+// root history, and gives the root over the current leaves even after SetLeaf.
+func (m *MerkleHashTree) RootAtSize(n uint64) ([sha256.Size]byte, error) {
+	defer m.readLock()()
+
+	if n > uint64(len(m.tree[0])) {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, n, len(m.tree[0]))
+	}
+	return m.rootAtSize(n), nil
+}
+
+// rootAtSize returns the merkle root of the first n leaves of the tree
+func (m *MerkleHashTree) rootAtSize(n uint64) [sha256.Size]byte {
+	if n == 0 {
+		return m.emptyRoot()
+	}
+	return m.mthOfRange(0, int(n)-1)
+}
+
+// InclusionProof returns inclusion proof for a merkle tree hash node. See
+// InclusionProofOfEntry; the audit path is empty when it returns an error, as it
+// is for the only leaf of a tree, which InclusionProofOfEntry tells apart. An
+// entry appended more than once is only proven at its first leaf, use
+// InclusionProofByIndex to prove the others.
 func (mth *MerkleHashTree) InclusionProof(e []byte) [][sha256.Size]byte {
-	hash := leafHash(e)
-	m := IndexOf(mth.tree[0], hash)
-	if m < 0 {
+	p, err := mth.InclusionProofOfEntry(e)
+	if err != nil {
 		return make([][sha256.Size]byte, 0)
 	}
+	return p.Hashes
+}
+
+// indexesOfEntry returns the indexes of the leaves of entry e in ascending order
+func (mth *MerkleHashTree) indexesOfEntry(e []byte) []uint64 {
+	var indexes []uint64
+	if mth.indexBound {
+		for i, leaf := range mth.tree[0] {
+			if leaf == mth.hashLeaves(uint64(i), [][]byte{e})[0] {
+				indexes = append(indexes, uint64(i))
+			}
+		}
+		return indexes
+	}
 
-	return mth.AduitPath(m, 0, len(mth.tree[0])-1)
+	hash := mth.hashLeaves(0, [][]byte{e})[0]
+	first := mth.leafIndex(hash)
+	if first < 0 {
+		return nil
+	}
+	for i := first; i < len(mth.tree[0]); i++ {
+		if mth.tree[0][i] == hash {
+			indexes = append(indexes, uint64(i))
+		} else if mth.sorted {
+			break
+		}
+	}
+	return indexes
 }
 
+// mthOfRange returns the merkle tree hash of the leaves start through end inclusive
 func (mth *MerkleHashTree) mthOfRange(start, end int) [sha256.Size]byte {
 	if start == end {
 		return mth.tree[0][start]
 	}
 
-	levels := levels(end - start + 1)
-	maxSize := int(math.Pow(2, float64(levels-1)))
-	return mth.tree[levels-1][start/maxSize]
+	n := end - start + 1
+	level := levels(n) - 1
+	maxSize := int(math.Pow(2, float64(level)))
+	// The stored node only commits to exactly this range when the range is a complete
+	// subtree or runs up to the last leaf; ranges of historical tree sizes are recomputed.
+	if start%maxSize == 0 && (n == maxSize || end == len(mth.tree[0])-1) {
+		return mth.tree[level][start/maxSize]
+	}
+
+	k := int(SplitPoint(uint64(n)))
+	left := mth.mthOfRange(start, start+k-1)
+	right := mth.mthOfRange(start+k, end)
+	return mth.nodeHash(left, right)
 }
 
 // AduitPath returns audit path of a merkle hash tree
 func (mth *MerkleHashTree) AduitPath(m int, start, end int) [][sha256.Size]byte {
+	defer mth.readLock()()
+	return mth.auditPath(m, start, end)
+}
+
+func (mth *MerkleHashTree) auditPath(m int, start, end int) [][sha256.Size]byte {
 	n := end - start + 1
 	path := make([][sha256.Size]byte, 0)
 
@@ -176,13 +426,13 @@ func (mth *MerkleHashTree) AduitPath(m int, start, end int) [][sha256.Size]byte
 		return path
 	}
 
-	k := int(largestPowerOf2SmallerThan(uint64(n)))
+	k := int(SplitPoint(uint64(n)))
 	k = start + k
 	if m < k {
-		path = append(path, mth.AduitPath(m, start, k-1)...)
+		path = append(path, mth.auditPath(m, start, k-1)...)
 		path = append(path, mth.mthOfRange(k, end))
 	} else {
-		path = append(path, mth.AduitPath(m, k, end)...)
+		path = append(path, mth.auditPath(m, k, end)...)
 		path = append(path, mth.mthOfRange(start, k-1))
 	}
 
@@ -209,7 +459,17 @@ func printPath(path [][sha256.Size]byte) {
 
 // ConsitencyProof returns the Merkle Consitency Proof for a Merkle Tree
 // Hash of first n leaves and previously advertised hash of the first m levaes, m <= n.
+// It returns nil for sizes out of range, use ConsistencyProof to tell them apart
+// from a proof that is empty, with ErrInvalidRange.
 func (mth *MerkleHashTree) ConsitencyProof(m, n uint64) [][sha256.Size]byte {
+	p, err := mth.ConsistencyProof(m, n)
+	if err != nil {
+		return nil
+	}
+	return p.Hashes
+}
+
+func (mth *MerkleHashTree) consistencyProof(m, n uint64) [][sha256.Size]byte {
 	l := uint64(len(mth.tree[0]))
 
 	if m < 0 || m > n || m > l || n > l {
@@ -232,7 +492,7 @@ func (mth *MerkleHashTree) subProof(m uint64, start, end int, isKnown bool) [][s
 	}
 
 	if m < n {
-		k := largestPowerOf2SmallerThan(n)
+		k := SplitPoint(n)
 		if m <= k {
 			path = append(path, mth.subProof(m, start, start+int(k-1), isKnown)...)
 			path = append(path, mth.mthOfRange(start+int(k), end))
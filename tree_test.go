@@ -1,6 +1,9 @@
 package merkletree
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -134,3 +137,308 @@ func TestConsistencyProof(t *testing.T) {
 	path = tree.ConsitencyProof(6, 7)
 	assert.Len(t, path, 3)
 }
+
+func TestAppendIf(t *testing.T) {
+	D := makeEntries(8)
+	tree := New(D[:4], WithLocking())
+	root := tree.MerkleRoot()
+
+	newRoot, err := tree.AppendIf(root, D[4])
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:5]), newRoot)
+
+	_, err = tree.AppendIf(root, D[5])
+	assert.ErrorIs(t, err, ErrRootMismatch)
+	var mismatch *RootMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, newRoot, mismatch.Actual)
+	assert.Equal(t, uint64(5), tree.Size())
+}
+
+func TestAppendIfRacingWriters(t *testing.T) {
+	D := makeEntries(4)
+	for i := 0; i < 50; i++ {
+		tree := New(D, WithLocking())
+		expected := tree.MerkleRoot()
+
+		entries := [][]byte{[]byte("writer-a"), []byte("writer-b")}
+		roots := make([][sha256.Size]byte, 2)
+		errs := make([]error, 2)
+		var wg sync.WaitGroup
+		for w := range entries {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				roots[w], errs[w] = tree.AppendIf(expected, entries[w])
+			}(w)
+		}
+		wg.Wait()
+
+		winner, loser := 0, 1
+		if errs[0] != nil {
+			winner, loser = 1, 0
+		}
+		assert.NoError(t, errs[winner])
+		assert.ErrorIs(t, errs[loser], ErrRootMismatch)
+		assert.Equal(t, MTH(append(append([][]byte{}, D...), entries[winner])), tree.MerkleRoot())
+		assert.Equal(t, roots[winner], tree.MerkleRoot())
+		assert.Equal(t, uint64(5), tree.Size())
+	}
+}
+
+func TestConcurrentProversAndAppender(t *testing.T) {
+	D := makeEntries(400)
+	tree := New(D[:1], WithLocking())
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			prev := tree.TreeHead()
+			for k := r; ; k++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				head := tree.TreeHead()
+				i := uint64(k) % head.TreeSize
+				p, err := tree.InclusionProofAtSize(i, head.TreeSize)
+				if err != nil || VerifyInclusion(leafHash(D[i]), head.RootHash, p) != nil {
+					t.Errorf("inclusion of %d at size %d: %v", i, head.TreeSize, err)
+					return
+				}
+				hashes := tree.ConsitencyProof(prev.TreeSize, head.TreeSize)
+				c := ConsistencyProof{OldSize: prev.TreeSize, NewSize: head.TreeSize, Hashes: hashes}
+				if err := VerifyConsistency(prev.RootHash, head.RootHash, c); err != nil {
+					t.Errorf("consistency of %d and %d: %v", prev.TreeSize, head.TreeSize, err)
+					return
+				}
+				if path := tree.AduitPath(int(i), 0, int(head.TreeSize)-1); !assert.Equal(t, p.Hashes, path) {
+					return
+				}
+				if head.TreeSize > 1 && len(tree.InclusionProof(D[i])) == 0 {
+					t.Errorf("no inclusion proof of %d", i)
+					return
+				}
+				prev = head
+			}
+		}(r)
+	}
+
+	for _, d := range D[1:] {
+		tree.Append(d)
+	}
+	close(done)
+	wg.Wait()
+	assert.Equal(t, MTH(D), tree.MerkleRoot())
+}
+
+func BenchmarkConcurrentProofsWhileAppending(b *testing.B) {
+	D := makeEntries(1 << 12)
+	tree := New(D, WithLocking())
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				tree.Append(D[i%len(D)])
+			}
+		}
+	}()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := uint64(0)
+		for pb.Next() {
+			tree.InclusionProofAtSize(i%uint64(len(D)), uint64(len(D)))
+			i++
+		}
+	})
+	b.StopTimer()
+	close(done)
+}
+
+// recursiveBuildTree is the recursive construction of the tree levels, kept as a
+// reference for the bottom-up sweep of buildTree.
+func recursiveBuildTree(m *MerkleHashTree, entries [][sha256.Size]byte) [sha256.Size]byte {
+	n := uint64(len(entries))
+	if n == 0 {
+		return sha256.Sum256(nil)
+	}
+
+	if n == 1 {
+		return entries[0]
+	}
+
+	k := SplitPoint(n)
+
+	left := recursiveBuildTree(m, entries[0:k])
+	right := recursiveBuildTree(m, entries[k:n])
+	hash := nodeHash(append(left[:], right[:]...))
+	level := levels(int(n)) - 1
+	m.tree[level] = append(m.tree[level], hash)
+	return hash
+}
+
+func recursiveTree(leaves [][sha256.Size]byte) *MerkleHashTree {
+	m := &MerkleHashTree{tree: make([][][sha256.Size]byte, levels(len(leaves)))}
+	m.tree[0] = leaves
+	recursiveBuildTree(m, leaves)
+	return m
+}
+
+func TestBuildTreeMatchesRecursiveLayout(t *testing.T) {
+	for n := 0; n <= 70; n++ {
+		D := makeEntries(n)
+		tree := New(D)
+		expected := recursiveTree(tree.tree[0])
+		for l := range expected.tree {
+			assert.Equal(t, len(expected.tree[l]), len(tree.tree[l]), "n=%d level=%d", n, l)
+			for i := range expected.tree[l] {
+				assert.Equal(t, expected.tree[l][i], tree.tree[l][i], "n=%d level=%d index=%d", n, l, i)
+			}
+		}
+		assert.Equal(t, MTH(D), tree.MerkleRoot())
+	}
+}
+
+func TestSizeLeafHashHeight(t *testing.T) {
+	tree := New(nil)
+	assert.Equal(t, uint64(0), tree.Size())
+	assert.Equal(t, 0, tree.Height())
+	_, err := tree.LeafHash(0)
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	D := makeEntries(33)
+	heights := map[int]int{1: 0, 2: 1, 3: 2, 4: 2, 5: 3, 8: 3, 9: 4, 16: 4, 17: 5, 32: 5, 33: 6}
+	for n := 1; n <= len(D); n++ {
+		tree.Append(D[n-1])
+		tree.Append()
+		assert.Equal(t, uint64(n), tree.Size())
+		if h, ok := heights[n]; ok {
+			assert.Equal(t, h, tree.Height(), "size %d", n)
+		}
+		p, err := tree.InclusionProofByIndex(0)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(p.Hashes), tree.Height())
+	}
+	for i, d := range D {
+		leaf, err := tree.LeafHash(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, leafHash(d), leaf)
+	}
+	_, err = tree.LeafHash(uint64(len(D)))
+	assert.ErrorIs(t, err, ErrIndexOutOfRange)
+}
+
+func TestRootAtSize(t *testing.T) {
+	D := makeEntries(100)
+	tree := New(nil)
+	roots := [][sha256.Size]byte{tree.MerkleRoot()}
+	for n := 0; n < len(D); {
+		// Batches of 1 to 4 leaves
+		end := n + 1 + n%4
+		if end > len(D) {
+			end = len(D)
+		}
+		for _, d := range D[n:end] {
+			roots = append(roots, tree.Append(d))
+		}
+		n = end
+	}
+
+	for n, root := range roots {
+		got, err := tree.RootAtSize(uint64(n))
+		assert.NoError(t, err, "size %d", n)
+		assert.Equal(t, root, got, "size %d", n)
+		assert.Equal(t, MTH(D[:n]), got, "size %d", n)
+	}
+	empty, _ := tree.RootAtSize(0)
+	assert.Equal(t, sha256.Sum256(nil), empty)
+	latest, _ := tree.RootAtSize(uint64(len(D)))
+	assert.Equal(t, tree.MerkleRoot(), latest)
+	_, err := tree.RootAtSize(uint64(len(D)) + 1)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	h := newSHA512_256Hasher(t)
+	hashed := New(D[:9], WithHasher(h))
+	for n := 0; n <= 9; n++ {
+		root, err := hashed.RootAtSize(uint64(n))
+		assert.NoError(t, err)
+		assert.Equal(t, referenceMTH(h, D[:n]), root, "size %d", n)
+	}
+}
+
+func TestAppendRehashesRightEdge(t *testing.T) {
+	D := makeEntries(300)
+	tree := New(nil)
+	for n := 1; n <= len(D); n++ {
+		assert.Equal(t, MTH(D[:n]), tree.Append(D[n-1]), "size %d", n)
+		if n <= 70 {
+			assert.Equal(t, New(D[:n]).tree, tree.tree, "size %d", n)
+		}
+	}
+	assert.Equal(t, New(D).tree, tree.tree)
+
+	for batch := 2; batch <= 9; batch++ {
+		tree := New(nil)
+		for n := 0; n < len(D); n += batch {
+			end := n + batch
+			if end > len(D) {
+				end = len(D)
+			}
+			assert.Equal(t, MTH(D[:end]), tree.Append(D[n:end]...), "batch %d, size %d", batch, end)
+		}
+		assert.Equal(t, New(D).tree, tree.tree, "batch %d", batch)
+	}
+}
+
+func syntheticLeaves(n int) [][sha256.Size]byte {
+	leaves := make([][sha256.Size]byte, n)
+	for i := range leaves {
+		binary.BigEndian.PutUint64(leaves[i][:], uint64(i))
+	}
+	return leaves
+}
+
+func BenchmarkBuildTree8M(b *testing.B) {
+	leaves := syntheticLeaves(8 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := &MerkleHashTree{tree: make([][][sha256.Size]byte, levels(len(leaves)))}
+		m.tree[0] = leaves
+		m.buildTree(leaves)
+	}
+}
+
+func BenchmarkRecursiveBuildTree8M(b *testing.B) {
+	leaves := syntheticLeaves(8 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recursiveTree(leaves)
+	}
+}
+
+func BenchmarkSequentialAppends100k(b *testing.B) {
+	D := makeEntries(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := New(nil)
+		for _, d := range D {
+			tree.Append(d)
+		}
+	}
+}
+
+func BenchmarkAppendTo1M(b *testing.B) {
+	tree := benchmarkTree(1 << 20)
+	d := []byte("appended")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Append(d)
+	}
+}
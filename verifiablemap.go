@@ -0,0 +1,154 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MapPath returns the path of key in a VerifiableMap, SHA-256(key)
+func MapPath(key []byte) [sha256.Size]byte {
+	return sha256.Sum256(key)
+}
+
+// MapLeaf returns the leaf of key set to value in a VerifiableMap: the leaf hash of
+// EncodeLeafFields(key, value). Committing to the key and not only its path keeps
+// leaves of different keys apart even if their paths collide.
+func MapLeaf(key, value []byte) [sha256.Size]byte {
+	return leafHash(EncodeLeafFields(key, value))
+}
+
+// VerifiableMap is a key value map committed to by a SparseMerkleTree: the leaf at
+// MapPath(key) is MapLeaf(key, value) for every key in the map, and all other
+// leaves are empty. It is safe for concurrent use.
+type VerifiableMap struct {
+	mu     sync.RWMutex
+	tree   *SparseMerkleTree
+	keys   map[[sha256.Size]byte][]byte
+	values map[[sha256.Size]byte][]byte
+}
+
+// MapProof proves the value of a key of a VerifiableMap, or that the key is absent
+type MapProof = SparseProof
+
+// NewVerifiableMap returns an empty map
+func NewVerifiableMap() *VerifiableMap {
+	return &VerifiableMap{
+		tree:   NewSparseMerkleTree(),
+		keys:   make(map[[sha256.Size]byte][]byte),
+		values: make(map[[sha256.Size]byte][]byte),
+	}
+}
+
+// Put sets key to value, overwriting any previous value
+func (m *VerifiableMap) Put(key, value []byte) {
+	key = append([]byte(nil), key...)
+	value = append([]byte{}, value...)
+	path := MapPath(key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[path] = key
+	m.values[path] = value
+	m.tree.Set(path, MapLeaf(key, value))
+}
+
+// Delete removes key from the map and reports whether it was present
+func (m *VerifiableMap) Delete(key []byte) bool {
+	path := MapPath(key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[path]; !ok {
+		return false
+	}
+	delete(m.keys, path)
+	delete(m.values, path)
+	m.tree.Delete(path)
+	return true
+}
+
+// Get returns the value of key, nil when the key is absent, and the proof of it
+func (m *VerifiableMap) Get(key []byte) ([]byte, MapProof) {
+	path := MapPath(key)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.values[path]
+	if ok {
+		value = append([]byte{}, value...)
+	}
+	return value, m.tree.Prove(path)
+}
+
+// Len returns the number of keys in the map
+func (m *VerifiableMap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.values)
+}
+
+// Root returns the root of the sparse merkle tree of the map
+func (m *VerifiableMap) Root() [sha256.Size]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tree.Root()
+}
+
+// VerifyMapProof checks that key is set to value in the map with the given root, or
+// that key is absent when value is nil. An empty but non-nil value is a present key.
+func VerifyMapProof(root [sha256.Size]byte, key, value []byte, p MapProof) error {
+	leaf := sparseZero[0]
+	if value != nil {
+		leaf = MapLeaf(key, value)
+	}
+	return VerifySparseProof(root, MapPath(key), leaf, p)
+}
+
+// MarshalBinary returns the state of the map: EncodeLeafFields of its keys and
+// values alternately, sorted by path
+func (m *VerifiableMap) MarshalBinary() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	paths := make([][sha256.Size]byte, 0, len(m.keys))
+	for path := range m.keys {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return bytes.Compare(paths[i][:], paths[j][:]) < 0
+	})
+
+	fields := make([][]byte, 0, 2*len(paths))
+	for _, path := range paths {
+		fields = append(fields, m.keys[path], m.values[path])
+	}
+	return EncodeLeafFields(fields...), nil
+}
+
+// UnmarshalBinary replaces the state of the map with one returned by MarshalBinary
+func (m *VerifiableMap) UnmarshalBinary(data []byte) error {
+	fields, err := DecodeLeafFields(data)
+	if err != nil {
+		return err
+	}
+	if len(fields)%2 != 0 {
+		return fmt.Errorf("%w: key without value", ErrMalformedLeafFields)
+	}
+
+	decoded := NewVerifiableMap()
+	for i := 0; i < len(fields); i += 2 {
+		path := MapPath(fields[i])
+		if _, ok := decoded.keys[path]; ok {
+			return fmt.Errorf("%w: duplicate key %x", ErrMalformedLeafFields, fields[i])
+		}
+		decoded.Put(fields[i], fields[i+1])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tree, m.keys, m.values = decoded.tree, decoded.keys, decoded.values
+	return nil
+}
@@ -0,0 +1,99 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifiableMapPutGet(t *testing.T) {
+	m := NewVerifiableMap()
+	empty := m.Root()
+	m.Put([]byte("alice"), []byte("1"))
+	m.Put([]byte("bob"), []byte{})
+	root := m.Root()
+
+	value, p := m.Get([]byte("alice"))
+	assert.Equal(t, []byte("1"), value)
+	assert.NoError(t, VerifyMapProof(root, []byte("alice"), value, p))
+	assert.ErrorIs(t, VerifyMapProof(root, []byte("alice"), []byte("2"), p), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyMapProof(root, []byte("alice"), nil, p), ErrRootMismatch)
+
+	// An empty value is present, unlike an absent key
+	value, p = m.Get([]byte("bob"))
+	assert.Equal(t, []byte{}, value)
+	assert.NoError(t, VerifyMapProof(root, []byte("bob"), value, p))
+	assert.ErrorIs(t, VerifyMapProof(root, []byte("bob"), nil, p), ErrRootMismatch)
+
+	value, p = m.Get([]byte("carol"))
+	assert.Nil(t, value)
+	assert.NoError(t, VerifyMapProof(root, []byte("carol"), nil, p))
+	assert.ErrorIs(t, VerifyMapProof(root, []byte("carol"), []byte{}, p), ErrRootMismatch)
+
+	// Overwrites replace the leaf
+	m.Put([]byte("alice"), []byte("2"))
+	assert.NotEqual(t, root, m.Root())
+	value, p = m.Get([]byte("alice"))
+	assert.Equal(t, []byte("2"), value)
+	assert.NoError(t, VerifyMapProof(m.Root(), []byte("alice"), value, p))
+	m.Put([]byte("alice"), []byte("1"))
+	assert.Equal(t, root, m.Root())
+
+	// Deletes restore the empty subtree hashes
+	assert.True(t, m.Delete([]byte("alice")))
+	assert.False(t, m.Delete([]byte("alice")))
+	assert.True(t, m.Delete([]byte("bob")))
+	assert.Equal(t, 0, m.Len())
+	assert.Equal(t, empty, m.Root())
+	assert.Equal(t, SparseZeroHash(SparseDepth), m.Root())
+}
+
+func TestVerifiableMapStaleProofs(t *testing.T) {
+	m := NewVerifiableMap()
+	for _, k := range makeEntries(10) {
+		m.Put(k, append([]byte("v-"), k...))
+	}
+	oldRoot := m.Root()
+	value, p := m.Get([]byte("d3"))
+	_, absent := m.Get([]byte("missing"))
+
+	// Changing an unrelated key invalidates proofs against the new root
+	m.Put([]byte("d7"), []byte("changed"))
+	assert.NoError(t, VerifyMapProof(oldRoot, []byte("d3"), value, p))
+	assert.ErrorIs(t, VerifyMapProof(m.Root(), []byte("d3"), value, p), ErrRootMismatch)
+	assert.ErrorIs(t, VerifyMapProof(m.Root(), []byte("missing"), nil, absent), ErrRootMismatch)
+
+	value, p = m.Get([]byte("d3"))
+	assert.NoError(t, VerifyMapProof(m.Root(), []byte("d3"), value, p))
+	assert.True(t, m.Delete([]byte("d1")))
+	assert.ErrorIs(t, VerifyMapProof(m.Root(), []byte("d3"), value, p), ErrRootMismatch)
+}
+
+func TestVerifiableMapMarshalBinary(t *testing.T) {
+	m := NewVerifiableMap()
+	for i, k := range makeEntries(12) {
+		m.Put(k, makeEntries(i + 1)[i])
+	}
+	m.Put([]byte("empty"), nil)
+	data, err := m.MarshalBinary()
+	assert.NoError(t, err)
+
+	decoded := NewVerifiableMap()
+	decoded.Put([]byte("stale"), []byte("x"))
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, m.Root(), decoded.Root())
+	assert.Equal(t, m.Len(), decoded.Len())
+	value, p := decoded.Get([]byte("empty"))
+	assert.Equal(t, []byte{}, value)
+	assert.NoError(t, VerifyMapProof(m.Root(), []byte("empty"), value, p))
+
+	again, err := decoded.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+
+	assert.ErrorIs(t, decoded.UnmarshalBinary(EncodeLeafFields([]byte("k"))), ErrMalformedLeafFields)
+	dup := EncodeLeafFields([]byte("k"), []byte("1"), []byte("k"), []byte("2"))
+	assert.ErrorIs(t, decoded.UnmarshalBinary(dup), ErrMalformedLeafFields)
+	assert.ErrorIs(t, decoded.UnmarshalBinary([]byte{5}), ErrMalformedLeafFields)
+	assert.Equal(t, m.Root(), decoded.Root())
+}
@@ -0,0 +1,36 @@
+package verify
+
+// DigestInclusionProof is an InclusionProof of a tree over a hash function with
+// digests of any size, named by Algorithm
+type DigestInclusionProof struct {
+	Algorithm string
+	LeafIndex uint64
+	TreeSize  uint64
+	Hashes    [][]byte
+}
+
+// DigestConsistencyProof is a ConsistencyProof of trees over a hash function with
+// digests of any size, named by Algorithm
+type DigestConsistencyProof struct {
+	Algorithm string
+	OldSize   uint64
+	NewSize   uint64
+	Hashes    [][]byte
+}
+
+// DigestNodeHasher returns the digest of the node with the given children
+type DigestNodeHasher func(left, right []byte) []byte
+
+// RootFromDigestInclusionProof recomputes the root like RootFromInclusionProof, for
+// a tree whose nodes are hashed with nodeHash. It neither checks the algorithm nor
+// the size of the digests of p.
+func RootFromDigestInclusionProof(nodeHash DigestNodeHasher, leafDigest []byte, p DigestInclusionProof) ([]byte, error) {
+	return rootFromInclusionProof[[]byte](nodeHash, leafDigest, p.LeafIndex, p.TreeSize, p.Hashes)
+}
+
+// RootsFromDigestConsistencyProof recomputes the roots like
+// RootsFromConsistencyProof, for trees whose nodes are hashed with nodeHash. It
+// neither checks the algorithm nor the size of the digests of p.
+func RootsFromDigestConsistencyProof(nodeHash DigestNodeHasher, oldRoot []byte, p DigestConsistencyProof) ([]byte, []byte, error) {
+	return rootsFromConsistencyProof[[]byte](nodeHash, oldRoot, p.OldSize, p.NewSize, p.Hashes)
+}
@@ -0,0 +1,115 @@
+package verify
+
+import "crypto/sha256"
+
+// The functions below verify proofs without heap allocations, for constrained
+// verifiers. They hash into fixed size arrays and return the sentinel errors
+// unwrapped, which makes them accept and reject exactly the proofs the functions
+// above do, with errors.Is reporting the same sentinel.
+
+// RootFromInclusionProofInto stores in dst the root recomputed from leafHash and
+// hashes, the audit path of the leaf at index in the tree of size leaves, like
+// RootFromInclusionProof
+func RootFromInclusionProofInto(dst, leafHash *[sha256.Size]byte, index, size uint64, hashes [][sha256.Size]byte) error {
+	if index >= size {
+		return ErrIndexOutOfRange
+	}
+	inner := InnerProofSize(index, size)
+	if len(hashes) != inner+BorderSize(index, size) {
+		return ErrInvalidProofSize
+	}
+
+	*dst = *leafHash
+	for i := range hashes {
+		if i >= inner || (index>>uint(i))&1 == 1 {
+			nodeHashInto(dst, &hashes[i], dst)
+		} else {
+			nodeHashInto(dst, dst, &hashes[i])
+		}
+	}
+	return nil
+}
+
+// VerifyInclusionInto checks that leafHash is included in the tree with the given
+// root, like VerifyInclusion, using scratch for the recomputed root
+func VerifyInclusionInto(scratch, leafHash, root *[sha256.Size]byte, index, size uint64, hashes [][sha256.Size]byte) error {
+	if err := RootFromInclusionProofInto(scratch, leafHash, index, size, hashes); err != nil {
+		return err
+	}
+	if *scratch != *root {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// VerifyConsistencyInto checks that the tree with root newRoot of newSize leaves
+// extends the tree with root oldRoot of oldSize leaves, like VerifyConsistency,
+// using oldScratch and newScratch for the recomputed roots
+func VerifyConsistencyInto(oldScratch, newScratch, oldRoot, newRoot *[sha256.Size]byte, oldSize, newSize uint64, hashes [][sha256.Size]byte) error {
+	m, n := oldSize, newSize
+	if m > n {
+		return ErrInvalidRange
+	}
+	if m == 0 || m == n {
+		if len(hashes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if m == n && *oldRoot != *newRoot {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	// When the old size is a power of two, the old root is the implicit first
+	// hash of the proof
+	first := oldRoot
+	rest := hashes
+	if m&(m-1) != 0 {
+		if len(hashes) == 0 {
+			return ErrInvalidProofSize
+		}
+		first, rest = &hashes[0], hashes[1:]
+	}
+
+	fn := m - 1
+	sn := n - 1
+	for fn%2 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	*oldScratch, *newScratch = *first, *first
+	for i := range rest {
+		if sn == 0 {
+			return ErrInvalidProofSize
+		}
+		if fn%2 == 1 || fn == sn {
+			nodeHashInto(oldScratch, &rest[i], oldScratch)
+			nodeHashInto(newScratch, &rest[i], newScratch)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			nodeHashInto(newScratch, newScratch, &rest[i])
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return ErrInvalidProofSize
+	}
+	if *oldScratch != *oldRoot || *newScratch != *newRoot {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// nodeHashInto stores NodeHash(left, right) in dst, which may be left or right
+func nodeHashInto(dst, left, right *[sha256.Size]byte) {
+	var e [1 + 2*sha256.Size]byte
+	e[0] = NodePrefix
+	copy(e[1:], left[:])
+	copy(e[1+sha256.Size:], right[:])
+	*dst = sha256.Sum256(e[:])
+}
@@ -0,0 +1,141 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// sentinel returns the sentinel error err wraps, or err
+func sentinel(err error) error {
+	for _, s := range []error{ErrIndexOutOfRange, ErrInvalidProof, ErrRootMismatch, ErrInvalidProofSize, ErrInvalidRange} {
+		if errors.Is(err, s) {
+			return s
+		}
+	}
+	return err
+}
+
+// compareInclusion checks that VerifyInclusionInto and VerifyInclusion agree
+func compareInclusion(t *testing.T, leaf, root [sha256.Size]byte, index, size uint64, hashes [][sha256.Size]byte) {
+	want := VerifyInclusion(leaf, root, InclusionProof{LeafIndex: index, TreeSize: size, Hashes: hashes})
+	var scratch [sha256.Size]byte
+	got := VerifyInclusionInto(&scratch, &leaf, &root, index, size, hashes)
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("index %d, size %d, %d hashes: got %v, want %v", index, size, len(hashes), got, want)
+	}
+}
+
+// compareConsistency checks that VerifyConsistencyInto and VerifyConsistency agree
+func compareConsistency(t *testing.T, oldRoot, newRoot [sha256.Size]byte, m, n uint64, hashes [][sha256.Size]byte) {
+	want := VerifyConsistency(oldRoot, newRoot, ConsistencyProof{OldSize: m, NewSize: n, Hashes: hashes})
+	var oldScratch, newScratch [sha256.Size]byte
+	got := VerifyConsistencyInto(&oldScratch, &newScratch, &oldRoot, &newRoot, m, n, hashes)
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("sizes %d to %d, %d hashes: got %v, want %v", m, n, len(hashes), got, want)
+	}
+}
+
+func TestVerifyIntoMatches(t *testing.T) {
+	data := vectorData(t)
+	data = append(data, data...)
+	for n := 1; n <= len(data); n++ {
+		root := mth(data[:n])
+		for i := 0; i < n; i++ {
+			hashes := path(i, data[:n])
+			leaf := LeafHash(data[i])
+			compareInclusion(t, leaf, root, uint64(i), uint64(n), hashes)
+			compareInclusion(t, LeafHash(data[(i+1)%n]), root, uint64(i), uint64(n), hashes)
+			compareInclusion(t, leaf, root, uint64(n), uint64(n), hashes)
+			compareInclusion(t, leaf, root, uint64(i), uint64(n+1), hashes)
+			compareInclusion(t, leaf, root, uint64(i), uint64(n), append(hashes, root))
+			if len(hashes) > 0 {
+				compareInclusion(t, leaf, root, uint64(i), uint64(n), hashes[1:])
+			}
+		}
+		for m := 0; m <= n+1; m++ {
+			oldRoot := mth(data[:n])
+			if m <= n {
+				oldRoot = mth(data[:m])
+			}
+			var hashes [][sha256.Size]byte
+			if m > 0 && m <= n {
+				hashes = proof(m, data[:n])
+			}
+			compareConsistency(t, oldRoot, root, uint64(m), uint64(n), hashes)
+			compareConsistency(t, root, oldRoot, uint64(m), uint64(n), hashes)
+			compareConsistency(t, oldRoot, root, uint64(m), uint64(n), append(hashes, root))
+			if len(hashes) > 0 {
+				compareConsistency(t, oldRoot, root, uint64(m), uint64(n), hashes[1:])
+			}
+		}
+	}
+}
+
+func TestVerifyIntoAllocations(t *testing.T) {
+	data := vectorData(t)
+	n := len(data)
+	root := mth(data)
+	leaf := LeafHash(data[5])
+	hashes := path(5, data)
+	oldRoot := mth(data[:3])
+	consistency := proof(3, data)
+	var scratch, oldScratch, newScratch [sha256.Size]byte
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := VerifyInclusionInto(&scratch, &leaf, &root, 5, uint64(n), hashes); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyInclusionInto(&scratch, &root, &root, 5, uint64(n), hashes); err != ErrRootMismatch {
+			t.Fatal(err)
+		}
+		if err := VerifyInclusionInto(&scratch, &leaf, &root, 5, uint64(n), hashes[1:]); err != ErrInvalidProofSize {
+			t.Fatal(err)
+		}
+		if err := VerifyConsistencyInto(&oldScratch, &newScratch, &oldRoot, &root, 3, uint64(n), consistency); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per run", allocs)
+	}
+}
+
+func BenchmarkVerifyInclusionInto(b *testing.B) {
+	data := make([][]byte, 1000)
+	for i := range data {
+		data[i] = []byte{byte(i), byte(i >> 8)}
+	}
+	root, leaf, hashes := mth(data), LeafHash(data[617]), path(617, data)
+	var scratch [sha256.Size]byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := VerifyInclusionInto(&scratch, &leaf, &root, 617, 1000, hashes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzVerifyIntoMatches(f *testing.F) {
+	f.Add(uint64(5), uint64(8), uint64(3), []byte{}, byte(0))
+	f.Add(uint64(0), uint64(1), uint64(1), make([]byte, 3*sha256.Size), byte(1))
+	f.Fuzz(func(t *testing.T, index, size, old uint64, raw []byte, seed byte) {
+		hashes := make([][sha256.Size]byte, len(raw)/sha256.Size)
+		for i := range hashes {
+			copy(hashes[i][:], raw[i*sha256.Size:])
+		}
+		leaf := sha256.Sum256([]byte{seed})
+		root := sha256.Sum256([]byte{seed, 1})
+		compareInclusion(t, leaf, root, index, size, hashes)
+		compareConsistency(t, leaf, root, old, size, hashes)
+
+		// Valid proofs of a small tree must agree too
+		data := vectorData(t)
+		n := int(size%uint64(len(data))) + 1
+		i := int(index % uint64(n))
+		compareInclusion(t, LeafHash(data[i]), mth(data[:n]), uint64(i), uint64(n), path(i, data[:n]))
+		m := int(old%uint64(n)) + 1
+		compareConsistency(t, mth(data[:m]), mth(data[:n]), uint64(m), uint64(n), proof(m, data[:n]))
+	})
+}
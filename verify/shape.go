@@ -0,0 +1,39 @@
+package verify
+
+import "math/bits"
+
+// SplitPoint returns the largest power of two smaller than n, the number of leaves
+// in the left subtree of a tree of n leaves, or 0 when n < 2.
+func SplitPoint(n uint64) uint64 {
+	if n < 2 {
+		return 0
+	}
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// PerfectSubtreeDecomposition returns the sizes of the perfect subtrees a tree of n
+// leaves decomposes into, from the leftmost and largest one to the rightmost and
+// smallest one. There is one subtree per set bit of n, and the sizes sum to n.
+func PerfectSubtreeDecomposition(n uint64) []uint64 {
+	sizes := make([]uint64, 0, bits.OnesCount64(n))
+	for n > 0 {
+		size := uint64(1) << (bits.Len64(n) - 1)
+		sizes = append(sizes, size)
+		n -= size
+	}
+	return sizes
+}
+
+// InnerProofSize returns the number of hashes of the audit path of the leaf at index
+// in a tree of size leaves that are siblings below the point where the path of
+// the leaf and the path of the last leaf meet. The rest of the path is the border.
+func InnerProofSize(index, size uint64) int {
+	return bits.Len64(index ^ (size - 1))
+}
+
+// BorderSize returns the number of hashes of the audit path of the leaf at index in
+// a tree of size leaves that are left siblings on the path of the last leaf, above
+// the inner part of the path. The audit path has InnerProofSize + BorderSize hashes.
+func BorderSize(index, size uint64) int {
+	return bits.OnesCount64(index >> uint(InnerProofSize(index, size)))
+}
@@ -0,0 +1,188 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The functions below verify proofs whose hashes arrive one at a time, such as long
+// proofs read from the network, folding every hash into the recomputed roots as it
+// arrives instead of buffering the proof. They accept and reject exactly the
+// proofs the functions taking slices do, and fail as soon as the hashes read
+// cannot be a valid proof.
+
+// HashIterator returns the next hash of a proof, or false after the last one
+type HashIterator func() ([sha256.Size]byte, bool)
+
+// SliceHashes returns an iterator over hashes
+func SliceHashes(hashes [][sha256.Size]byte) HashIterator {
+	return func() ([sha256.Size]byte, bool) {
+		if len(hashes) == 0 {
+			return [sha256.Size]byte{}, false
+		}
+		h := hashes[0]
+		hashes = hashes[1:]
+		return h, true
+	}
+}
+
+// ProofReader reads the hashes of a proof from a stream of concatenated 32-byte
+// hashes
+type ProofReader struct {
+	r   io.Reader
+	buf [sha256.Size]byte
+	err error
+}
+
+// NewProofReader returns a reader of the hashes of a proof from r
+func NewProofReader(r io.Reader) *ProofReader {
+	return &ProofReader{r: r}
+}
+
+// Next returns the next hash read, or false at the end of the stream or on a read
+// error, reported by Err
+func (p *ProofReader) Next() ([sha256.Size]byte, bool) {
+	if p.err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	n, err := io.ReadFull(p.r, p.buf[:])
+	switch {
+	case err == io.EOF:
+		p.err = io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		p.err = fmt.Errorf("%w: stream ends %d bytes into a hash", ErrInvalidProofSize, n)
+	case err != nil:
+		p.err = err
+	}
+	return p.buf, p.err == nil
+}
+
+// Err returns the error that ended the stream, nil at its end. A stream ending
+// inside a hash fails with ErrInvalidProofSize.
+func (p *ProofReader) Err() error {
+	if p.err == io.EOF {
+		return nil
+	}
+	return p.err
+}
+
+// VerifyInclusionStream checks that leafHash is included in the tree with the given
+// root at index in the tree of size leaves, like VerifyInclusion, with the audit
+// path returned by next
+func VerifyInclusionStream(leafHash, root [sha256.Size]byte, index, size uint64, next HashIterator) error {
+	if index >= size {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, size)
+	}
+	inner := InnerProofSize(index, size)
+	total := inner + BorderSize(index, size)
+
+	r := leafHash
+	for i := 0; i < total; i++ {
+		h, ok := next()
+		if !ok {
+			return fmt.Errorf("%w: %d hashes, want %d", ErrInvalidProofSize, i, total)
+		}
+		if i >= inner || (index>>uint(i))&1 == 1 {
+			nodeHashInto(&r, &h, &r)
+		} else {
+			nodeHashInto(&r, &r, &h)
+		}
+	}
+	if _, ok := next(); ok {
+		return fmt.Errorf("%w: more than %d hashes", ErrInvalidProofSize, total)
+	}
+	if r != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// VerifyConsistencyStream checks that the tree with root newRoot of newSize leaves
+// extends the tree with root oldRoot of oldSize leaves, like VerifyConsistency,
+// with the consistency proof returned by next
+func VerifyConsistencyStream(oldRoot, newRoot [sha256.Size]byte, oldSize, newSize uint64, next HashIterator) error {
+	m, n := oldSize, newSize
+	if m > n {
+		return fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+	if m == 0 || m == n {
+		if _, ok := next(); ok {
+			return ErrInvalidProofSize
+		}
+		if m == n && oldRoot != newRoot {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	// When the old size is a power of two, the old root is the implicit first
+	// hash of the proof
+	first := oldRoot
+	if m&(m-1) != 0 {
+		h, ok := next()
+		if !ok {
+			return ErrInvalidProofSize
+		}
+		first = h
+	}
+
+	fn := m - 1
+	sn := n - 1
+	for fn%2 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := first, first
+	for c, ok := next(); ok; c, ok = next() {
+		if sn == 0 {
+			return ErrInvalidProofSize
+		}
+		if fn%2 == 1 || fn == sn {
+			nodeHashInto(&fr, &c, &fr)
+			nodeHashInto(&sr, &c, &sr)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			nodeHashInto(&sr, &sr, &c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return ErrInvalidProofSize
+	}
+	if fr != oldRoot {
+		return fmt.Errorf("%w: reconstructed old root does not match", ErrRootMismatch)
+	}
+	if sr != newRoot {
+		return fmt.Errorf("%w: reconstructed new root does not match", ErrRootMismatch)
+	}
+	return nil
+}
+
+// VerifyInclusionReader checks an inclusion proof like VerifyInclusionStream, with
+// the audit path read from r as concatenated 32-byte hashes
+func VerifyInclusionReader(leafHash, root [sha256.Size]byte, index, size uint64, r io.Reader) error {
+	p := NewProofReader(r)
+	err := VerifyInclusionStream(leafHash, root, index, size, p.Next)
+	if readErr := p.Err(); readErr != nil {
+		return readErr
+	}
+	return err
+}
+
+// VerifyConsistencyReader checks a consistency proof like VerifyConsistencyStream,
+// with the proof read from r as concatenated 32-byte hashes
+func VerifyConsistencyReader(oldRoot, newRoot [sha256.Size]byte, oldSize, newSize uint64, r io.Reader) error {
+	p := NewProofReader(r)
+	err := VerifyConsistencyStream(oldRoot, newRoot, oldSize, newSize, p.Next)
+	if readErr := p.Err(); readErr != nil {
+		return readErr
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// proofBytes concatenates hashes as read by a ProofReader
+func proofBytes(hashes [][sha256.Size]byte) []byte {
+	b := make([]byte, 0, len(hashes)*sha256.Size)
+	for _, h := range hashes {
+		b = append(b, h[:]...)
+	}
+	return b
+}
+
+// compareStreamInclusion checks that the streaming inclusion verifiers agree with
+// VerifyInclusion
+func compareStreamInclusion(t *testing.T, leaf, root [sha256.Size]byte, index, size uint64, hashes [][sha256.Size]byte) {
+	want := VerifyInclusion(leaf, root, InclusionProof{LeafIndex: index, TreeSize: size, Hashes: hashes})
+	got := VerifyInclusionStream(leaf, root, index, size, SliceHashes(hashes))
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("stream: index %d, size %d, %d hashes: got %v, want %v", index, size, len(hashes), got, want)
+	}
+	r := iotest.OneByteReader(bytes.NewReader(proofBytes(hashes)))
+	got = VerifyInclusionReader(leaf, root, index, size, r)
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("reader: index %d, size %d, %d hashes: got %v, want %v", index, size, len(hashes), got, want)
+	}
+}
+
+// compareStreamConsistency checks that the streaming consistency verifiers agree
+// with VerifyConsistency
+func compareStreamConsistency(t *testing.T, oldRoot, newRoot [sha256.Size]byte, m, n uint64, hashes [][sha256.Size]byte) {
+	want := VerifyConsistency(oldRoot, newRoot, ConsistencyProof{OldSize: m, NewSize: n, Hashes: hashes})
+	got := VerifyConsistencyStream(oldRoot, newRoot, m, n, SliceHashes(hashes))
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("stream: sizes %d to %d, %d hashes: got %v, want %v", m, n, len(hashes), got, want)
+	}
+	got = VerifyConsistencyReader(oldRoot, newRoot, m, n, bytes.NewReader(proofBytes(hashes)))
+	if sentinel(got) != sentinel(want) {
+		t.Errorf("reader: sizes %d to %d, %d hashes: got %v, want %v", m, n, len(hashes), got, want)
+	}
+}
+
+func TestVerifyStreamMatches(t *testing.T) {
+	data := vectorData(t)
+	data = append(data, data...)
+	for n := 1; n <= len(data); n++ {
+		root := mth(data[:n])
+		for i := 0; i < n; i++ {
+			hashes := path(i, data[:n])
+			leaf := LeafHash(data[i])
+			if err := VerifyInclusionStream(leaf, root, uint64(i), uint64(n), SliceHashes(hashes)); err != nil {
+				t.Errorf("index %d, size %d: %v", i, n, err)
+			}
+			compareStreamInclusion(t, leaf, root, uint64(i), uint64(n), hashes)
+			compareStreamInclusion(t, LeafHash(data[(i+1)%n]), root, uint64(i), uint64(n), hashes)
+			compareStreamInclusion(t, leaf, root, uint64(n), uint64(n), hashes)
+			compareStreamInclusion(t, leaf, root, uint64(i), uint64(n+1), hashes)
+			compareStreamInclusion(t, leaf, root, uint64(i), uint64(n), append(hashes, root))
+			if len(hashes) > 0 {
+				compareStreamInclusion(t, leaf, root, uint64(i), uint64(n), hashes[1:])
+			}
+		}
+		for m := 0; m <= n+1; m++ {
+			oldRoot := mth(data[:n])
+			if m <= n {
+				oldRoot = mth(data[:m])
+			}
+			var hashes [][sha256.Size]byte
+			if m > 0 && m <= n {
+				hashes = proof(m, data[:n])
+			}
+			if m <= n {
+				if err := VerifyConsistencyStream(oldRoot, root, uint64(m), uint64(n), SliceHashes(hashes)); err != nil {
+					t.Errorf("sizes %d to %d: %v", m, n, err)
+				}
+			}
+			compareStreamConsistency(t, oldRoot, root, uint64(m), uint64(n), hashes)
+			compareStreamConsistency(t, root, oldRoot, uint64(m), uint64(n), hashes)
+			compareStreamConsistency(t, oldRoot, root, uint64(m), uint64(n), append(hashes, root))
+			if len(hashes) > 0 {
+				compareStreamConsistency(t, oldRoot, root, uint64(m), uint64(n), hashes[1:])
+			}
+		}
+	}
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	return n, err
+}
+
+func TestVerifyReaderFailsEarly(t *testing.T) {
+	data := vectorData(t)
+	n := len(data)
+	root, leaf, hashes := mth(data), LeafHash(data[2]), path(2, data)
+
+	// A stream cut inside a hash
+	b := proofBytes(hashes)
+	err := VerifyInclusionReader(leaf, root, 2, uint64(n), bytes.NewReader(b[:len(b)-5]))
+	if !errors.Is(err, ErrInvalidProofSize) {
+		t.Errorf("truncated proof: %v", err)
+	}
+
+	// A longer proof than the index and size call for is rejected after reading
+	// one hash too many, not the rest of the stream
+	c := &countingReader{r: io.MultiReader(bytes.NewReader(b), bytes.NewReader(make([]byte, 1<<20)))}
+	err = VerifyInclusionReader(leaf, root, 2, uint64(n), c)
+	if !errors.Is(err, ErrInvalidProofSize) {
+		t.Errorf("long proof: %v", err)
+	}
+	if c.read > len(b)+sha256.Size {
+		t.Errorf("read %d bytes of a proof of %d", c.read, len(b))
+	}
+
+	c = &countingReader{r: bytes.NewReader(make([]byte, 1<<20))}
+	err = VerifyConsistencyReader(mth(data[:3]), root, 3, uint64(n), c)
+	if !errors.Is(err, ErrInvalidProofSize) {
+		t.Errorf("long consistency proof: %v", err)
+	}
+	if c.read > 10*sha256.Size {
+		t.Errorf("read %d bytes of a long consistency proof", c.read)
+	}
+
+	errRead := errors.New("connection reset")
+	err = VerifyInclusionReader(leaf, root, 2, uint64(n), iotest.ErrReader(errRead))
+	if !errors.Is(err, errRead) {
+		t.Errorf("read error: %v", err)
+	}
+}
+
+func BenchmarkVerifyInclusionReader(b *testing.B) {
+	// The audit path of the last leaf has a hash per level, so the proof grows with
+	// the tree while the verifier only ever holds one hash
+	for _, levels := range []int{8, 32, 63} {
+		size := uint64(1) << levels
+		hashes := make([][sha256.Size]byte, levels)
+		for i := range hashes {
+			hashes[i] = sha256.Sum256([]byte{byte(i)})
+		}
+		leaf := sha256.Sum256(nil)
+		root, err := RootFromInclusionProof(leaf, InclusionProof{LeafIndex: size - 1, TreeSize: size, Hashes: hashes})
+		if err != nil {
+			b.Fatal(err)
+		}
+		raw := proofBytes(hashes)
+		b.Run(fmt.Sprintf("levels=%d", levels), func(b *testing.B) {
+			r := bytes.NewReader(raw)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.Reset(raw)
+				if err := VerifyInclusionReader(leaf, root, size-1, size, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func FuzzVerifyStreamMatches(f *testing.F) {
+	f.Add(uint64(5), uint64(8), uint64(3), []byte{}, byte(0))
+	f.Add(uint64(0), uint64(1), uint64(1), make([]byte, 3*sha256.Size), byte(1))
+	f.Fuzz(func(t *testing.T, index, size, old uint64, raw []byte, seed byte) {
+		hashes := make([][sha256.Size]byte, len(raw)/sha256.Size)
+		for i := range hashes {
+			copy(hashes[i][:], raw[i*sha256.Size:])
+		}
+		leaf := sha256.Sum256([]byte{seed})
+		root := sha256.Sum256([]byte{seed, 1})
+		compareStreamInclusion(t, leaf, root, index, size, hashes)
+		compareStreamConsistency(t, leaf, root, old, size, hashes)
+
+		// Valid proofs of a small tree must agree too
+		data := vectorData(t)
+		n := int(size%uint64(len(data))) + 1
+		i := int(index % uint64(n))
+		compareStreamInclusion(t, LeafHash(data[i]), mth(data[:n]), uint64(i), uint64(n), path(i, data[:n]))
+		m := int(old%uint64(n)) + 1
+		compareStreamConsistency(t, mth(data[:m]), mth(data[:n]), uint64(m), uint64(n), proof(m, data[:n]))
+	})
+}
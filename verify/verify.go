@@ -0,0 +1,247 @@
+// Package verify verifies RFC 6962 inclusion and consistency proofs. It only
+// depends on the standard library and does not build trees, so clients that only
+// check proofs, including TinyGo and WebAssembly builds, can import it alone.
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Prefixes for leaves and nodes
+const (
+	LeafPrefix = byte(0)
+	NodePrefix = byte(1)
+)
+
+// Errors returned when a proof does not verify
+var (
+	ErrIndexOutOfRange  = errors.New("merkletree: leaf index out of range")
+	ErrInvalidProof     = errors.New("merkletree: invalid proof")
+	ErrRootMismatch     = errors.New("merkletree: root mismatch")
+	ErrInvalidProofSize = errors.New("merkletree: wrong number of proof hashes")
+	ErrInvalidRange     = errors.New("merkletree: invalid tree size range")
+	ErrHashMismatch     = errors.New("merkletree: root or proof of another hash function")
+)
+
+// SHA256Algorithm is the Algorithm of proofs of trees over SHA-256, which may also
+// leave it empty
+const SHA256Algorithm = "sha2-256"
+
+// InclusionProof is a Merkle audit path for the leaf at LeafIndex in the tree of the
+// first TreeSize leaves. Hashes are ordered from the leaf towards the root.
+// Algorithm names the hash function of the tree, empty for SHA-256, so that a proof
+// of a tree over another hash function fails verification with ErrHashMismatch.
+type InclusionProof struct {
+	Algorithm string
+	LeafIndex uint64
+	TreeSize  uint64
+	Hashes    [][sha256.Size]byte
+}
+
+// Verify checks that leafHash is included in the tree with the given root, like
+// VerifyInclusion
+func (p InclusionProof) Verify(leafHash, root [sha256.Size]byte) error {
+	return VerifyInclusion(leafHash, root, p)
+}
+
+// ConsistencyProof is a Merkle consistency proof between the tree of the first
+// OldSize leaves and the tree of the first NewSize leaves. Algorithm names the hash
+// function of the trees, like that of InclusionProof.
+type ConsistencyProof struct {
+	Algorithm string
+	OldSize   uint64
+	NewSize   uint64
+	Hashes    [][sha256.Size]byte
+}
+
+// checkAlgorithm fails with ErrHashMismatch unless algorithm names SHA-256
+func checkAlgorithm(algorithm string) error {
+	if algorithm != "" && algorithm != SHA256Algorithm {
+		return fmt.Errorf("%w: proof of %s, not %s", ErrHashMismatch, algorithm, SHA256Algorithm)
+	}
+	return nil
+}
+
+// Verify checks that the tree with root newRoot extends the tree with root oldRoot,
+// like VerifyConsistency
+func (p ConsistencyProof) Verify(oldRoot, newRoot [sha256.Size]byte) error {
+	return VerifyConsistency(oldRoot, newRoot, p)
+}
+
+// LeafHash returns the hash of the leaf with data d: SHA-256(0x00 || d)
+func LeafHash(d []byte) [sha256.Size]byte {
+	e := make([]byte, 0, 1+len(d))
+	e = append(e, LeafPrefix)
+	e = append(e, d...)
+	return sha256.Sum256(e)
+}
+
+// NodeHash returns the hash of the node with the given children: SHA-256(0x01 || left || right)
+func NodeHash(left, right [sha256.Size]byte) [sha256.Size]byte {
+	e := make([]byte, 0, 1+2*sha256.Size)
+	e = append(e, NodePrefix)
+	e = append(e, left[:]...)
+	e = append(e, right[:]...)
+	return sha256.Sum256(e)
+}
+
+// VerifyInclusion checks that leafHash is included in the tree with the given root
+// at the position and tree size described by p. A proof of a tree over another hash
+// function fails with ErrHashMismatch.
+func VerifyInclusion(leafHash, root [sha256.Size]byte, p InclusionProof) error {
+	if err := checkAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	calculated, err := RootFromInclusionProof(leafHash, p)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(calculated[:], root[:]) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// RootFromInclusionProof recomputes the root of the tree of size p.TreeSize from a
+// leaf hash and its audit path. It is equivalent to RFC 9162 section 2.1.3.2.
+func RootFromInclusionProof(leafHash [sha256.Size]byte, p InclusionProof) ([sha256.Size]byte, error) {
+	return RootFromInclusionProofWith(NodeHash, leafHash, p)
+}
+
+// NodeHasher returns the hash of the node with the given children
+type NodeHasher func(left, right [sha256.Size]byte) [sha256.Size]byte
+
+// RootFromInclusionProofWith recomputes the root like RootFromInclusionProof, for a
+// tree whose nodes are hashed with nodeHash instead of NodeHash
+func RootFromInclusionProofWith(nodeHash NodeHasher, leafHash [sha256.Size]byte, p InclusionProof) ([sha256.Size]byte, error) {
+	return rootFromInclusionProof[[sha256.Size]byte](nodeHash, leafHash, p.LeafIndex, p.TreeSize, p.Hashes)
+}
+
+// rootFromInclusionProof recomputes the root of the tree of size leaves from the
+// hash of the leaf at index and its audit path, for hashes of any type
+func rootFromInclusionProof[H any](nodeHash func(left, right H) H, leafHash H, index, size uint64, hashes []H) (H, error) {
+	var r H
+	if index >= size {
+		return r, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, size)
+	}
+
+	// Below the inner size the sibling sides follow the bits of the index. Above it,
+	// the path runs along the right edge of the tree, where siblings are on the left.
+	inner := InnerProofSize(index, size)
+	if len(hashes) != inner+BorderSize(index, size) {
+		return r, ErrInvalidProofSize
+	}
+
+	r = leafHash
+	for i, h := range hashes {
+		if i >= inner || (index>>uint(i))&1 == 1 {
+			r = nodeHash(h, r)
+		} else {
+			r = nodeHash(r, h)
+		}
+	}
+	return r, nil
+}
+
+// VerifyConsistency checks that the tree with root newRoot is an append-only extension
+// of the tree with root oldRoot, using the consistency proof p between their sizes.
+// A proof of trees over another hash function fails with ErrHashMismatch.
+func VerifyConsistency(oldRoot, newRoot [sha256.Size]byte, p ConsistencyProof) error {
+	if err := checkAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	m, n := p.OldSize, p.NewSize
+	if m > n {
+		return fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	// Every tree is consistent with the empty tree, and a tree with itself.
+	if m == 0 || m == n {
+		if len(p.Hashes) != 0 {
+			return ErrInvalidProofSize
+		}
+		if m == n && !bytes.Equal(oldRoot[:], newRoot[:]) {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	fr, sr, err := RootsFromConsistencyProof(oldRoot, p)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(fr[:], oldRoot[:]) {
+		return fmt.Errorf("%w: reconstructed old root does not match", ErrRootMismatch)
+	}
+	if !bytes.Equal(sr[:], newRoot[:]) {
+		return fmt.Errorf("%w: reconstructed new root does not match", ErrRootMismatch)
+	}
+	return nil
+}
+
+// RootsFromConsistencyProof recomputes the old and new roots from a consistency proof
+// between non empty, distinct tree sizes, following RFC 9162 section 2.1.4.2. oldRoot
+// is only used when the old size is a power of two, as the old root is then omitted
+// from the proof.
+func RootsFromConsistencyProof(oldRoot [sha256.Size]byte, p ConsistencyProof) ([sha256.Size]byte, [sha256.Size]byte, error) {
+	return RootsFromConsistencyProofWith(NodeHash, oldRoot, p)
+}
+
+// RootsFromConsistencyProofWith recomputes the roots like RootsFromConsistencyProof,
+// for trees whose nodes are hashed with nodeHash instead of NodeHash
+func RootsFromConsistencyProofWith(nodeHash NodeHasher, oldRoot [sha256.Size]byte, p ConsistencyProof) ([sha256.Size]byte, [sha256.Size]byte, error) {
+	return rootsFromConsistencyProof[[sha256.Size]byte](nodeHash, oldRoot, p.OldSize, p.NewSize, p.Hashes)
+}
+
+// rootsFromConsistencyProof recomputes the roots of the trees of m and n leaves
+// from a consistency proof between them, for hashes of any type
+func rootsFromConsistencyProof[H any](nodeHash func(left, right H) H, oldRoot H, m, n uint64, proof []H) (H, H, error) {
+	var zero H
+	if m == 0 || m >= n {
+		return zero, zero, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	if m&(m-1) == 0 {
+		proof = append([]H{oldRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return zero, zero, ErrInvalidProofSize
+	}
+
+	fn := m - 1
+	sn := n - 1
+	for fn%2 == 1 {
+		fn = fn >> 1
+		sn = sn >> 1
+	}
+
+	fr := proof[0]
+	sr := proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return zero, zero, ErrInvalidProofSize
+		}
+
+		if fn%2 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn%2 == 0 && fn != 0 {
+				fn = fn >> 1
+				sn = sn >> 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn = fn >> 1
+		sn = sn >> 1
+	}
+
+	if sn != 0 {
+		return zero, zero, ErrInvalidProofSize
+	}
+	return fr, sr, nil
+}
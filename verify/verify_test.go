@@ -0,0 +1,320 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// The leaves and roots of the RFC 6962 reference test vectors, used by the
+// certificate transparency implementations.
+var (
+	vectorLeaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	vectorRoots  = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func vectorData(t *testing.T) [][]byte {
+	data := make([][]byte, len(vectorLeaves))
+	for i, l := range vectorLeaves {
+		d, err := hex.DecodeString(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[i] = d
+	}
+	return data
+}
+
+func decodeRoot(t *testing.T, s string) [sha256.Size]byte {
+	var root [sha256.Size]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha256.Size {
+		t.Fatalf("invalid root %q", s)
+	}
+	copy(root[:], b)
+	return root
+}
+
+// split returns the largest power of two smaller than n
+func split(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// mth is the Merkle Tree Hash of RFC 6962 section 2.1
+func mth(d [][]byte) [sha256.Size]byte {
+	switch len(d) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return LeafHash(d[0])
+	}
+	k := split(len(d))
+	return NodeHash(mth(d[:k]), mth(d[k:]))
+}
+
+// path is the Merkle audit path of RFC 6962 section 2.1.1
+func path(m int, d [][]byte) [][sha256.Size]byte {
+	if len(d) <= 1 {
+		return nil
+	}
+	k := split(len(d))
+	if m < k {
+		return append(path(m, d[:k]), mth(d[k:]))
+	}
+	return append(path(m-k, d[k:]), mth(d[:k]))
+}
+
+// proof is the Merkle consistency proof of RFC 6962 section 2.1.2
+func proof(m int, d [][]byte) [][sha256.Size]byte {
+	return subproof(m, d, true)
+}
+
+func subproof(m int, d [][]byte, complete bool) [][sha256.Size]byte {
+	n := len(d)
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][sha256.Size]byte{mth(d)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(subproof(m, d[:k], complete), mth(d[k:]))
+	}
+	return append(subproof(m-k, d[k:], false), mth(d[:k]))
+}
+
+func TestReferenceVectors(t *testing.T) {
+	data := vectorData(t)
+	for n := 1; n <= len(data); n++ {
+		if root := mth(data[:n]); root != decodeRoot(t, vectorRoots[n-1]) {
+			t.Errorf("root of %d leaves is %x", n, root)
+		}
+	}
+	if LeafHash(nil) != decodeRoot(t, vectorRoots[0]) {
+		t.Errorf("unexpected hash of the empty leaf")
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	data := vectorData(t)
+	for n := 1; n <= len(data); n++ {
+		root := decodeRoot(t, vectorRoots[n-1])
+		for i := 0; i < n; i++ {
+			p := InclusionProof{LeafIndex: uint64(i), TreeSize: uint64(n), Hashes: path(i, data[:n])}
+			if err := VerifyInclusion(LeafHash(data[i]), root, p); err != nil {
+				t.Errorf("index %d, size %d: %v", i, n, err)
+			}
+
+			calculated, err := RootFromInclusionProof(LeafHash(data[i]), p)
+			if err != nil || calculated != root {
+				t.Errorf("index %d, size %d: root %x, %v", i, n, calculated, err)
+			}
+
+			other := LeafHash(data[(i+1)%len(data)])
+			if err := VerifyInclusion(other, root, p); !errors.Is(err, ErrRootMismatch) {
+				t.Errorf("index %d, size %d: wrong leaf verified: %v", i, n, err)
+			}
+			if err := p.Verify(LeafHash(data[i]), root); err != nil {
+				t.Errorf("index %d, size %d: Verify: %v", i, n, err)
+			}
+			if err := p.Verify(other, root); !errors.Is(err, ErrRootMismatch) {
+				t.Errorf("index %d, size %d: Verify of wrong leaf: %v", i, n, err)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionRejectsMalformedProofs(t *testing.T) {
+	data := vectorData(t)
+	root := decodeRoot(t, vectorRoots[6])
+	leaf := LeafHash(data[3])
+	p := InclusionProof{LeafIndex: 3, TreeSize: 7, Hashes: path(3, data[:7])}
+
+	tests := []struct {
+		name string
+		p    InclusionProof
+		err  error
+	}{
+		{"index beyond size", InclusionProof{LeafIndex: 7, TreeSize: 7, Hashes: p.Hashes}, ErrIndexOutOfRange},
+		{"empty tree", InclusionProof{LeafIndex: 0, TreeSize: 0}, ErrIndexOutOfRange},
+		{"short path", InclusionProof{LeafIndex: 3, TreeSize: 7, Hashes: p.Hashes[:2]}, ErrInvalidProofSize},
+		{"long path", InclusionProof{LeafIndex: 3, TreeSize: 7, Hashes: append(append([][sha256.Size]byte{}, p.Hashes...), root)}, ErrInvalidProofSize},
+		{"wrong index", InclusionProof{LeafIndex: 2, TreeSize: 7, Hashes: p.Hashes}, ErrRootMismatch},
+		{"size of a smaller tree", InclusionProof{LeafIndex: 3, TreeSize: 4, Hashes: p.Hashes}, ErrInvalidProofSize},
+		{"another algorithm", InclusionProof{Algorithm: "sha2-512-256", LeafIndex: 3, TreeSize: 7, Hashes: p.Hashes}, ErrHashMismatch},
+	}
+	for _, tt := range tests {
+		if err := VerifyInclusion(leaf, root, tt.p); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if err := VerifyInclusion(leaf, root, InclusionProof{Algorithm: SHA256Algorithm, LeafIndex: 3, TreeSize: 7, Hashes: p.Hashes}); err != nil {
+		t.Errorf("tagged with SHA-256: %v", err)
+	}
+
+	tampered := append([][sha256.Size]byte{}, p.Hashes...)
+	tampered[1][0] ^= 1
+	if err := VerifyInclusion(leaf, root, InclusionProof{LeafIndex: 3, TreeSize: 7, Hashes: tampered}); !errors.Is(err, ErrRootMismatch) {
+		t.Errorf("tampered path: got %v", err)
+	}
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	data := vectorData(t)
+	for n := 1; n <= len(data); n++ {
+		newRoot := decodeRoot(t, vectorRoots[n-1])
+		for m := 0; m <= n; m++ {
+			oldRoot := sha256.Sum256(nil)
+			if m > 0 {
+				oldRoot = decodeRoot(t, vectorRoots[m-1])
+			}
+			var hashes [][sha256.Size]byte
+			if m > 0 {
+				hashes = proof(m, data[:n])
+			}
+			p := ConsistencyProof{OldSize: uint64(m), NewSize: uint64(n), Hashes: hashes}
+			if err := VerifyConsistency(oldRoot, newRoot, p); err != nil {
+				t.Errorf("m=%d n=%d: %v", m, n, err)
+			}
+			if err := p.Verify(oldRoot, newRoot); err != nil {
+				t.Errorf("m=%d n=%d: Verify: %v", m, n, err)
+			}
+			if m == 0 || m == n {
+				continue
+			}
+
+			fr, sr, err := RootsFromConsistencyProof(oldRoot, p)
+			if err != nil || fr != oldRoot || sr != newRoot {
+				t.Errorf("m=%d n=%d: roots %x %x, %v", m, n, fr, sr, err)
+			}
+			if err := VerifyConsistency(newRoot, newRoot, p); !errors.Is(err, ErrRootMismatch) && !errors.Is(err, ErrInvalidProofSize) {
+				t.Errorf("m=%d n=%d: wrong old root verified: %v", m, n, err)
+			}
+			if err := VerifyConsistency(oldRoot, oldRoot, p); !errors.Is(err, ErrRootMismatch) {
+				t.Errorf("m=%d n=%d: wrong new root verified: %v", m, n, err)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyRejectsMalformedProofs(t *testing.T) {
+	data := vectorData(t)
+	oldRoot := decodeRoot(t, vectorRoots[2])
+	newRoot := decodeRoot(t, vectorRoots[6])
+	hashes := proof(3, data[:7])
+
+	tests := []struct {
+		name string
+		p    ConsistencyProof
+		err  error
+	}{
+		{"old size beyond new size", ConsistencyProof{OldSize: 8, NewSize: 7, Hashes: hashes}, ErrInvalidRange},
+		{"hashes for empty old tree", ConsistencyProof{OldSize: 0, NewSize: 7, Hashes: hashes}, ErrInvalidProofSize},
+		{"hashes for equal sizes", ConsistencyProof{OldSize: 7, NewSize: 7, Hashes: hashes}, ErrInvalidProofSize},
+		{"short proof", ConsistencyProof{OldSize: 3, NewSize: 7, Hashes: hashes[:2]}, ErrInvalidProofSize},
+		{"long proof", ConsistencyProof{OldSize: 3, NewSize: 7, Hashes: append(append([][sha256.Size]byte{}, hashes...), newRoot)}, ErrInvalidProofSize},
+		{"empty proof", ConsistencyProof{OldSize: 3, NewSize: 7}, ErrInvalidProofSize},
+		{"another algorithm", ConsistencyProof{Algorithm: "sha2-512-256", OldSize: 3, NewSize: 7, Hashes: hashes}, ErrHashMismatch},
+	}
+	for _, tt := range tests {
+		if err := VerifyConsistency(oldRoot, newRoot, tt.p); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if err := VerifyConsistency(newRoot, newRoot, ConsistencyProof{OldSize: 7, NewSize: 7}); err != nil {
+		t.Errorf("equal trees: %v", err)
+	}
+	if err := VerifyConsistency(oldRoot, newRoot, ConsistencyProof{OldSize: 7, NewSize: 7}); !errors.Is(err, ErrRootMismatch) {
+		t.Errorf("equal sizes with different roots: got %v", err)
+	}
+	if _, _, err := RootsFromConsistencyProof(oldRoot, ConsistencyProof{OldSize: 0, NewSize: 7}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("roots from empty old tree: got %v", err)
+	}
+}
+
+func TestSplitPoint(t *testing.T) {
+	for n := uint64(0); n <= 1025; n++ {
+		expected := uint64(0)
+		for k := uint64(1); k < n; k *= 2 {
+			expected = k
+		}
+		if got := SplitPoint(n); got != expected {
+			t.Errorf("SplitPoint(%d) = %d, want %d", n, got, expected)
+		}
+	}
+	if got := SplitPoint(1<<63 + 1); got != 1<<63 {
+		t.Errorf("SplitPoint(2^63+1) = %d", got)
+	}
+}
+
+func TestPerfectSubtreeDecomposition(t *testing.T) {
+	expected := map[uint64][]uint64{0: {}, 1: {1}, 6: {4, 2}, 7: {4, 2, 1}, 8: {8}, 13: {8, 4, 1}}
+	for n, sizes := range expected {
+		got := PerfectSubtreeDecomposition(n)
+		if len(got) != len(sizes) {
+			t.Fatalf("PerfectSubtreeDecomposition(%d) = %v, want %v", n, got, sizes)
+		}
+		for i := range sizes {
+			if got[i] != sizes[i] {
+				t.Errorf("PerfectSubtreeDecomposition(%d) = %v, want %v", n, got, sizes)
+			}
+		}
+	}
+
+	for n := uint64(0); n <= 1025; n++ {
+		sum := uint64(0)
+		prev := uint64(0)
+		for _, s := range PerfectSubtreeDecomposition(n) {
+			if s&(s-1) != 0 || (prev != 0 && s >= prev) {
+				t.Errorf("PerfectSubtreeDecomposition(%d) has size %d after %d", n, s, prev)
+			}
+			sum += s
+			prev = s
+		}
+		if sum != n {
+			t.Errorf("PerfectSubtreeDecomposition(%d) sums to %d", n, sum)
+		}
+	}
+}
+
+func TestProofSizes(t *testing.T) {
+	data := make([][]byte, 130)
+	for i := range data {
+		data[i] = []byte{byte(i)}
+	}
+
+	for size := 1; size <= len(data); size++ {
+		for index := 0; index < size; index++ {
+			inner := InnerProofSize(uint64(index), uint64(size))
+			border := BorderSize(uint64(index), uint64(size))
+			if got := len(path(index, data[:size])); inner+border != got {
+				t.Errorf("index %d, size %d: inner %d + border %d, path has %d hashes", index, size, inner, border, got)
+			}
+		}
+	}
+
+	// The last leaf of a tree has no inner part, and the leaves of a perfect tree no border
+	if InnerProofSize(6, 7) != 0 || BorderSize(6, 7) != 2 {
+		t.Errorf("unexpected sizes for the last leaf of 7")
+	}
+	if InnerProofSize(3, 8) != 3 || BorderSize(3, 8) != 0 {
+		t.Errorf("unexpected sizes for leaf 3 of 8")
+	}
+}
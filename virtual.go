@@ -0,0 +1,133 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// virtualMemoLevel is the lowest level of the nodes a VirtualTree memoizes. Nodes
+// below it cover at most 2^virtualMemoLevel leaves and are cheap to hash again.
+const virtualMemoLevel = 4
+
+// VirtualTree is a tree of a given size whose leaf hashes are generated by a
+// deterministic function instead of being stored, for testing at sizes no tree
+// could hold in memory. Node hashes are computed on demand and the nodes on the
+// paths of proofs are memoized. Proofs are built by a Prover reading the tree as
+// NodeStorage, so they come from the same algorithms as those of a MerkleHashTree.
+//
+// A node is computed from all the leaves it covers, unless WithSubtreeHash gives
+// its hash directly. It is safe for concurrent use.
+type VirtualTree struct {
+	size    uint64
+	leaf    func(index uint64) [sha256.Size]byte
+	subtree func(id NodeID) ([sha256.Size]byte, bool)
+	prover  *Prover
+
+	mu   sync.Mutex
+	memo map[NodeID][sha256.Size]byte
+}
+
+var _ NodeStorage = (*VirtualTree)(nil)
+
+// VirtualOption configures a VirtualTree
+type VirtualOption func(*VirtualTree)
+
+// WithSubtreeHash gives the hashes of perfect subtrees in closed form, for leaf
+// functions whose subtrees repeat, such as a tree of identical leaves. A perfect
+// subtree for which hash reports false is computed from its children.
+func WithSubtreeHash(hash func(id NodeID) ([sha256.Size]byte, bool)) VirtualOption {
+	return func(v *VirtualTree) {
+		v.subtree = hash
+	}
+}
+
+// NewVirtualTree returns the tree of size leaves whose leaf hash at index i is
+// leaf(i)
+func NewVirtualTree(size uint64, leaf func(index uint64) [sha256.Size]byte, opts ...VirtualOption) *VirtualTree {
+	v := &VirtualTree{size: size, leaf: leaf, memo: make(map[NodeID][sha256.Size]byte)}
+	for _, opt := range opts {
+		opt(v)
+	}
+	v.prover = NewProver(v, size)
+	return v
+}
+
+// Size returns the number of leaves of the tree
+func (v *VirtualTree) Size() uint64 {
+	return v.size
+}
+
+// Root returns the root hash of the tree
+func (v *VirtualTree) Root() [sha256.Size]byte {
+	root, _ := v.RootAtSize(v.size)
+	return root
+}
+
+// RootAtSize returns the root hash of the tree of the first n leaves
+func (v *VirtualTree) RootAtSize(n uint64) ([sha256.Size]byte, error) {
+	if n > v.size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: size %d of %d", ErrIndexOutOfRange, n, v.size)
+	}
+	if n == 0 {
+		return sha256.Sum256(nil), nil
+	}
+	hashes, err := v.prover.rangeHashes([][2]uint64{{0, n}})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return hashes[0], nil
+}
+
+// InclusionProofAtSize returns the audit path for the leaf at index i in the tree
+// of the first n leaves
+func (v *VirtualTree) InclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	return v.prover.InclusionProof(i, n)
+}
+
+// ConsistencyProof returns the consistency proof between the trees of the first m
+// and n leaves
+func (v *VirtualTree) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	return v.prover.ConsistencyProof(m, n)
+}
+
+// ReadNodes computes the hashes of the perfect subtrees ids
+func (v *VirtualTree) ReadNodes(ids []NodeID) ([][sha256.Size]byte, error) {
+	hashes := make([][sha256.Size]byte, len(ids))
+	for i, id := range ids {
+		if !isPerfect(id, v.size) {
+			return nil, &MissingNodeError{Node: id}
+		}
+		hashes[i] = v.node(id)
+	}
+	return hashes, nil
+}
+
+// node returns the hash of the perfect subtree id
+func (v *VirtualTree) node(id NodeID) [sha256.Size]byte {
+	if id.Level == 0 {
+		return v.leaf(id.Index)
+	}
+	if v.subtree != nil {
+		if h, ok := v.subtree(id); ok {
+			return h
+		}
+	}
+	if id.Level < virtualMemoLevel {
+		left, right := v.node(id.Left()), v.node(id.Right())
+		return nodeHash(append(left[:], right[:]...))
+	}
+
+	v.mu.Lock()
+	h, ok := v.memo[id]
+	v.mu.Unlock()
+	if ok {
+		return h
+	}
+	left, right := v.node(id.Left()), v.node(id.Right())
+	h = nodeHash(append(left[:], right[:]...))
+	v.mu.Lock()
+	v.memo[id] = h
+	v.mu.Unlock()
+	return h
+}
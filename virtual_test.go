@@ -0,0 +1,119 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// virtualEntry is the entry at index i of the procedurally generated trees
+func virtualEntry(i uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte("virtual "), i)
+}
+
+func virtualLeaf(i uint64) [sha256.Size]byte {
+	return leafHash(virtualEntry(i))
+}
+
+func TestVirtualTreeMatchesMaterialized(t *testing.T) {
+	for n := uint64(0); n <= 33; n++ {
+		D := make([][]byte, n)
+		for i := range D {
+			D[i] = virtualEntry(uint64(i))
+		}
+		tree := New(D)
+		v := NewVirtualTree(n, virtualLeaf)
+
+		assert.Equal(t, tree.MerkleRoot(), v.Root(), "size %d", n)
+		for m := uint64(0); m <= n; m++ {
+			got, err := v.RootAtSize(m)
+			assert.NoError(t, err)
+			assert.Equal(t, tree.rootAtSize(m), got, "size %d of %d", m, n)
+
+			want, err := tree.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			proof, err := v.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			assert.Equal(t, want, proof, "consistency %d to %d", m, n)
+
+			for i := uint64(0); i < m; i++ {
+				want, err := tree.InclusionProofAtSize(i, m)
+				assert.NoError(t, err)
+				proof, err := v.InclusionProofAtSize(i, m)
+				assert.NoError(t, err)
+				assert.Equal(t, want, proof, "leaf %d of %d", i, m)
+			}
+		}
+	}
+}
+
+func TestVirtualTreeOutOfRange(t *testing.T) {
+	v := NewVirtualTree(5, virtualLeaf)
+
+	_, err := v.InclusionProofAtSize(5, 5)
+	assert.True(t, errors.Is(err, ErrIndexOutOfRange))
+	_, err = v.InclusionProofAtSize(0, 6)
+	assert.True(t, errors.Is(err, ErrIndexOutOfRange))
+	_, err = v.ConsistencyProof(3, 6)
+	assert.True(t, errors.Is(err, ErrInvalidRange))
+	_, err = v.RootAtSize(6)
+	assert.True(t, errors.Is(err, ErrIndexOutOfRange))
+	_, err = v.ReadNodes([]NodeID{{Level: 1, Index: 2}})
+	assert.True(t, errors.Is(err, ErrMissingNode))
+}
+
+func TestVirtualTreeExtremeSize(t *testing.T) {
+	// Every leaf is the same except one, so a perfect subtree without it has the
+	// hash of a perfect subtree of identical leaves of its level
+	const special = 1<<40 + 12345
+	uniform := make([][sha256.Size]byte, 64)
+	uniform[0] = leafHash([]byte("uniform"))
+	for l := 1; l < len(uniform); l++ {
+		uniform[l] = nodeHash(append(uniform[l-1][:], uniform[l-1][:]...))
+	}
+	leaf := func(i uint64) [sha256.Size]byte {
+		if i == special {
+			return leafHash([]byte("special"))
+		}
+		return uniform[0]
+	}
+	subtree := func(id NodeID) ([sha256.Size]byte, bool) {
+		start, end := id.RangeCovered()
+		if special >= start && special < end {
+			return [sha256.Size]byte{}, false
+		}
+		return uniform[id.Level], true
+	}
+
+	sizes := []uint64{1 << 40, 1<<41 - 3, 1<<62 + 7}
+	v := NewVirtualTree(sizes[len(sizes)-1], leaf, WithSubtreeHash(subtree))
+	roots := make([][sha256.Size]byte, len(sizes))
+	for j, n := range sizes {
+		var err error
+		roots[j], err = v.RootAtSize(n)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uniform[40], roots[0])
+
+	for j, n := range sizes {
+		for _, i := range []uint64{0, special, n / 3, n - 1} {
+			if i >= n {
+				continue
+			}
+			proof, err := v.InclusionProofAtSize(i, n)
+			assert.NoError(t, err)
+			assert.NoError(t, VerifyInclusion(leaf(i), roots[j], proof), "leaf %d of %d", i, n)
+			if i != special {
+				assert.Error(t, VerifyInclusion(leafHash([]byte("special")), roots[j], proof))
+			}
+		}
+		for k := 0; k < j; k++ {
+			proof, err := v.ConsistencyProof(sizes[k], n)
+			assert.NoError(t, err)
+			assert.NoError(t, VerifyConsistency(roots[k], roots[j], proof), "%d to %d", sizes[k], n)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// ErrExpired is returned for leaves and nodes of a WindowedLog that have expired
+var ErrExpired = errors.New("merkletree: leaf has expired")
+
+// WindowedLog is an append-only log that can forget the leaf hashes of an expired
+// prefix of its leaves. Of the expired leaves it only keeps the roots of the
+// perfect subtrees covering them, so the root of the log, inclusion proofs of the
+// remaining leaves and consistency proofs from sizes at or after the expired prefix
+// keep working. It is safe for concurrent use.
+type WindowedLog struct {
+	mu sync.RWMutex
+
+	size    uint64
+	expired uint64
+	prefix  map[NodeID][sha256.Size]byte
+
+	// levels[l] holds the complete nodes at level l from index offsets[l] on, the
+	// first node covering no expired leaf.
+	levels  [][][sha256.Size]byte
+	offsets []uint64
+}
+
+var _ Tree = (*WindowedLog)(nil)
+
+// NewWindowedLog returns a windowed log with a leaf for every entry of d
+func NewWindowedLog(d [][]byte) *WindowedLog {
+	w := &WindowedLog{prefix: make(map[NodeID][sha256.Size]byte)}
+	w.appendLeafHashes(hashEntries(d))
+	return w
+}
+
+func hashEntries(d [][]byte) [][sha256.Size]byte {
+	leaves := make([][sha256.Size]byte, len(d))
+	for i, e := range d {
+		leaves[i] = leafHash(e)
+	}
+	return leaves
+}
+
+// TryAppend appends a leaf for every entry of d and returns the new root
+func (w *WindowedLog) TryAppend(d ...[]byte) ([sha256.Size]byte, error) {
+	leaves := hashEntries(d)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.appendLeafHashes(leaves)
+	return w.rangeHash(0, w.size)
+}
+
+// appendLeafHashes appends leaves and the complete nodes they finish
+func (w *WindowedLog) appendLeafHashes(leaves [][sha256.Size]byte) {
+	for _, leaf := range leaves {
+		hash := leaf
+		for l, i := 0, w.size; ; l, i = l+1, i/2 {
+			if l == len(w.levels) {
+				w.levels = append(w.levels, nil)
+				w.offsets = append(w.offsets, ceilShift(w.expired, l))
+			}
+			w.levels[l] = append(w.levels[l], hash)
+
+			// A right child completes its parent, which is only stored when it
+			// covers no expired leaf
+			if i%2 == 0 || i/2 < ceilShift(w.expired, l+1) {
+				break
+			}
+			left := w.levels[l][i-1-w.offsets[l]]
+			hash = nodeHash(append(left[:], hash[:]...))
+		}
+		w.size++
+	}
+}
+
+// ceilShift returns the index of the first node at level l starting at or after n
+func ceilShift(n uint64, l int) uint64 {
+	return (n + 1<<l - 1) >> l
+}
+
+// node returns the hash of the complete node at level l and index i
+func (w *WindowedLog) node(l int, i uint64) ([sha256.Size]byte, error) {
+	start := i << l
+	end := start + 1<<l
+	if l < len(w.levels) && i >= w.offsets[l] {
+		return w.levels[l][i-w.offsets[l]], nil
+	}
+	if hash, ok := w.prefix[NodeID{Level: uint64(l), Index: i}]; ok {
+		return hash, nil
+	}
+	if end <= w.expired {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: node covering leaves [%d, %d)", ErrExpired, start, end)
+	}
+
+	left, err := w.node(l-1, 2*i)
+	if err != nil {
+		return left, err
+	}
+	right, err := w.node(l-1, 2*i+1)
+	if err != nil {
+		return right, err
+	}
+	return nodeHash(append(left[:], right[:]...)), nil
+}
+
+// rangeHash returns the merkle tree hash of the leaves [start, end)
+func (w *WindowedLog) rangeHash(start, end uint64) ([sha256.Size]byte, error) {
+	n := end - start
+	if n == 0 {
+		return sha256.Sum256(nil), nil
+	}
+	if n&(n-1) == 0 && start%n == 0 {
+		l := bits.TrailingZeros64(n)
+		return w.node(l, start>>l)
+	}
+
+	k := SplitPoint(n)
+	left, err := w.rangeHash(start, start+k)
+	if err != nil {
+		return left, err
+	}
+	right, err := w.rangeHash(start+k, end)
+	if err != nil {
+		return right, err
+	}
+	return nodeHash(append(left[:], right[:]...)), nil
+}
+
+// ExpireBefore forgets the leaf hashes of the leaves before index, keeping the roots
+// of the perfect subtrees covering them. Leaves that already expired stay expired.
+func (w *WindowedLog) ExpireBefore(index uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if index > w.size {
+		return fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, index, w.size)
+	}
+	if index <= w.expired {
+		return nil
+	}
+
+	prefix := make(map[NodeID][sha256.Size]byte)
+	start := uint64(0)
+	for _, n := range PerfectSubtreeDecomposition(index) {
+		l := bits.TrailingZeros64(n)
+		hash, err := w.node(l, start>>l)
+		if err != nil {
+			return err
+		}
+		prefix[NodeID{Level: uint64(l), Index: start >> l}] = hash
+		start += n
+	}
+
+	for l := range w.levels {
+		offset := ceilShift(index, l)
+		drop := offset - w.offsets[l]
+		if drop > uint64(len(w.levels[l])) {
+			drop = uint64(len(w.levels[l]))
+		}
+		w.levels[l] = append([][sha256.Size]byte(nil), w.levels[l][drop:]...)
+		w.offsets[l] = offset
+	}
+	w.prefix = prefix
+	w.expired = index
+	return nil
+}
+
+// Expired returns the number of leading leaves that have expired
+func (w *WindowedLog) Expired() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.expired
+}
+
+// Size returns the number of leaves of the log, including the expired ones
+func (w *WindowedLog) Size() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.size
+}
+
+// MerkleRoot returns the root of the log
+func (w *WindowedLog) MerkleRoot() [sha256.Size]byte {
+	return w.TreeHead().RootHash
+}
+
+// TreeHead returns the size and root of the log
+func (w *WindowedLog) TreeHead() TreeHead {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	// The root only depends on the kept nodes
+	root, _ := w.rangeHash(0, w.size)
+	return TreeHead{TreeSize: w.size, RootHash: root}
+}
+
+// RootAt returns the root of the log at size n, or ErrExpired when it cannot be
+// computed from the kept nodes
+func (w *WindowedLog) RootAt(n uint64) ([sha256.Size]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if n > w.size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: size %d, tree size %d", ErrInvalidRange, n, w.size)
+	}
+	return w.rangeHash(0, n)
+}
+
+// LeafHash returns the leaf hash at index i, or ErrExpired
+func (w *WindowedLog) LeafHash(i uint64) ([sha256.Size]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if i >= w.size {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, w.size)
+	}
+	if i < w.expired {
+		return [sha256.Size]byte{}, fmt.Errorf("%w: index %d", ErrExpired, i)
+	}
+	return w.levels[0][i-w.offsets[0]], nil
+}
+
+// LeafIndex returns the index of the first leaf with the given leaf hash that has
+// not expired, or ErrLeafNotFound
+func (w *WindowedLog) LeafIndex(leafHash [sha256.Size]byte) (uint64, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.size == 0 {
+		return 0, ErrLeafNotFound
+	}
+	if i := IndexOf(w.levels[0], leafHash); i >= 0 {
+		return w.offsets[0] + uint64(i), nil
+	}
+	return 0, ErrLeafNotFound
+}
+
+// InclusionProofAtSize returns the audit path of the leaf at index i in the log of
+// the first n leaves, or ErrExpired for expired leaves
+func (w *WindowedLog) InclusionProofAtSize(i, n uint64) (InclusionProof, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if i >= n || n > w.size {
+		return InclusionProof{}, fmt.Errorf("%w: index %d, size %d", ErrIndexOutOfRange, i, n)
+	}
+	if i < w.expired {
+		return InclusionProof{}, fmt.Errorf("%w: index %d", ErrExpired, i)
+	}
+
+	hashes, err := w.path(i, 0, n)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	return InclusionProof{LeafIndex: i, TreeSize: n, Hashes: hashes}, nil
+}
+
+// path returns the audit path of leaf i within the leaves [start, end)
+func (w *WindowedLog) path(i, start, end uint64) ([][sha256.Size]byte, error) {
+	if end-start == 1 {
+		return make([][sha256.Size]byte, 0), nil
+	}
+
+	k := start + SplitPoint(end-start)
+	path, sibling := [][sha256.Size]byte(nil), [sha256.Size]byte{}
+	var err error
+	if i < k {
+		if path, err = w.path(i, start, k); err == nil {
+			sibling, err = w.rangeHash(k, end)
+		}
+	} else {
+		if path, err = w.path(i, k, end); err == nil {
+			sibling, err = w.rangeHash(start, k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, sibling), nil
+}
+
+// ConsistencyProof returns the consistency proof between the logs of the first m
+// and n leaves, or ErrExpired when it needs nodes within the expired leaves, which
+// is only possible for m before the end of the expired prefix.
+func (w *WindowedLog) ConsistencyProof(m, n uint64) (ConsistencyProof, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if m > n || n > w.size {
+		return ConsistencyProof{}, fmt.Errorf("%w: old size %d, new size %d", ErrInvalidRange, m, n)
+	}
+
+	p := ConsistencyProof{OldSize: m, NewSize: n, Hashes: make([][sha256.Size]byte, 0)}
+	if m == 0 || m == n {
+		return p, nil
+	}
+	hashes, err := w.subProof(m, 0, n, true)
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+	p.Hashes = hashes
+	return p, nil
+}
+
+// subProof returns SUBPROOF of RFC 6962 for the first m leaves of [start, end)
+func (w *WindowedLog) subProof(m, start, end uint64, known bool) ([][sha256.Size]byte, error) {
+	n := end - start
+	if m == n {
+		if known {
+			return make([][sha256.Size]byte, 0), nil
+		}
+		hash, err := w.rangeHash(start, end)
+		return [][sha256.Size]byte{hash}, err
+	}
+
+	k := SplitPoint(n)
+	var proof [][sha256.Size]byte
+	var hash [sha256.Size]byte
+	var err error
+	if m <= k {
+		if proof, err = w.subProof(m, start, start+k, known); err == nil {
+			hash, err = w.rangeHash(start+k, end)
+		}
+	} else {
+		if proof, err = w.subProof(m-k, start+k, end, false); err == nil {
+			hash, err = w.rangeHash(start, start+k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, hash), nil
+}
@@ -0,0 +1,108 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowedLogMatchesFullTree(t *testing.T) {
+	D := makeEntries(24)
+	full := New(D)
+	for size := 0; size <= 16; size++ {
+		for expiry := 0; expiry <= size; expiry++ {
+			w := NewWindowedLog(D[:size])
+			assert.NoError(t, w.ExpireBefore(uint64(expiry)))
+			assert.Equal(t, uint64(expiry), w.Expired())
+
+			// Roots keep matching while appending after the expiry
+			for n := size; n <= len(D); n++ {
+				if n > size {
+					root, err := w.TryAppend(D[n-1])
+					assert.NoError(t, err)
+					assert.Equal(t, MTH(D[:n]), root)
+				}
+				assert.Equal(t, TreeHead{TreeSize: uint64(n), RootHash: MTH(D[:n])}, w.TreeHead())
+			}
+
+			for i := uint64(0); i < uint64(len(D)); i++ {
+				p, err := w.InclusionProofAtSize(i, uint64(len(D)))
+				if i < uint64(expiry) {
+					assert.ErrorIs(t, err, ErrExpired)
+					_, err = w.LeafHash(i)
+					assert.ErrorIs(t, err, ErrExpired)
+					continue
+				}
+				assert.NoError(t, err)
+				assert.NoError(t, VerifyInclusion(leafHash(D[i]), full.MerkleRoot(), p))
+				index, err := w.LeafIndex(leafHash(D[i]))
+				assert.NoError(t, err)
+				assert.Equal(t, i, index)
+			}
+		}
+	}
+}
+
+func TestWindowedLogConsistencyAcrossExpiry(t *testing.T) {
+	D := makeEntries(40)
+	full := New(D)
+	w := NewWindowedLog(D[:13])
+	assert.NoError(t, w.ExpireBefore(11))
+	_, err := w.TryAppend(D[13:30]...)
+	assert.NoError(t, err)
+	assert.NoError(t, w.ExpireBefore(17))
+	_, err = w.TryAppend(D[30:]...)
+	assert.NoError(t, err)
+
+	for m := uint64(0); m <= 40; m++ {
+		for n := m; n <= 40; n++ {
+			p, err := w.ConsistencyProof(m, n)
+			if err != nil {
+				// Only old sizes within the expired leaves can need expired nodes
+				assert.ErrorIs(t, err, ErrExpired)
+				assert.Less(t, m, uint64(17))
+				continue
+			}
+			expected, err := full.ConsistencyProof(m, n)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, p)
+			assert.NoError(t, VerifyConsistency(MTH(D[:m]), MTH(D[:n]), p))
+		}
+	}
+
+	// Sizes at the boundaries of the perfect subtrees of the expired prefix stay provable
+	for _, m := range []uint64{16, 17} {
+		_, err := w.ConsistencyProof(m, 40)
+		assert.NoError(t, err)
+	}
+	_, err = w.ConsistencyProof(5, 40)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestWindowedLogExpireBefore(t *testing.T) {
+	D := makeEntries(8)
+	w := NewWindowedLog(D)
+	assert.ErrorIs(t, w.ExpireBefore(9), ErrIndexOutOfRange)
+
+	assert.NoError(t, w.ExpireBefore(5))
+	// Expired leaves cannot be revived
+	assert.NoError(t, w.ExpireBefore(2))
+	assert.Equal(t, uint64(5), w.Expired())
+	assert.Len(t, w.levels[0], 3)
+	assert.Len(t, w.prefix, 2)
+	_, err := w.LeafIndex(leafHash(D[3]))
+	assert.ErrorIs(t, err, ErrLeafNotFound)
+
+	root, err := w.RootAt(4)
+	assert.NoError(t, err)
+	assert.Equal(t, MTH(D[:4]), root)
+	_, err = w.RootAt(3)
+	assert.ErrorIs(t, err, ErrExpired)
+	_, err = w.RootAt(9)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+
+	assert.NoError(t, w.ExpireBefore(8))
+	assert.Equal(t, MTH(D), w.MerkleRoot())
+	_, err = w.InclusionProofAtSize(7, 8)
+	assert.ErrorIs(t, err, ErrExpired)
+}
@@ -0,0 +1,209 @@
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Errors returned when a witness refuses a tree head or cannot load its state
+var (
+	ErrRollback          = errors.New("merkletree: tree head is smaller than the trusted one")
+	ErrFork              = errors.New("merkletree: tree head has the trusted size but another root")
+	ErrTimestampRollback = errors.New("merkletree: checkpoint timestamp is older than the trusted one")
+	ErrCorruptWitness    = errors.New("merkletree: corrupt witness state")
+)
+
+const (
+	witnessMagic   = "MTWS"
+	witnessVersion = 1
+)
+
+// TreeHead identifies the state of a log by its size and merkle root
+type TreeHead struct {
+	TreeSize uint64
+	RootHash [sha256.Size]byte
+}
+
+// Witness holds a trusted tree head and only advances it to newer tree heads
+// that are proven to be append-only extensions of the trusted one.
+type Witness struct {
+	mu        sync.RWMutex
+	head      TreeHead
+	timestamp uint64
+	path      string
+}
+
+// NewWitness returns a witness trusting head
+func NewWitness(head TreeHead) *Witness {
+	return &Witness{head: head}
+}
+
+// OpenWitness returns a witness whose state is persisted to the file at path. The
+// state is loaded from the file if it exists, and otherwise the witness trusts head
+// and creates the file. Every update is written to the file before it is trusted.
+func OpenWitness(path string, head TreeHead) (*Witness, error) {
+	w, err := LoadWitness(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return w, err
+	}
+
+	w = &Witness{head: head, path: path}
+	if err := w.Save(path); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// LoadWitness returns a witness whose state is loaded from and persisted to the
+// file at path, written by Save or by a witness returned by OpenWitness
+func LoadWitness(path string) (*Witness, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Witness{path: path}
+	if err := w.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// TreeHead returns the currently trusted tree head
+func (w *Witness) TreeHead() TreeHead {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.head
+}
+
+// Timestamp returns the timestamp of the latest checkpoint passed to
+// UpdateCheckpoint, or 0
+func (w *Witness) Timestamp() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.timestamp
+}
+
+// Update verifies that head is consistent with the trusted tree head using proof
+// and, if so, trusts head from then on. A smaller head fails with ErrRollback and
+// a head of the trusted size with another root fails with ErrFork.
+func (w *Witness) Update(head TreeHead, proof ConsistencyProof) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.update(head, 0, proof)
+}
+
+// UpdateCheckpoint is Update for the tree head of a checkpoint that also refuses
+// checkpoints with a "Timestamp: " extension line older than the timestamp of the
+// latest one, with ErrTimestampRollback
+func (w *Witness) UpdateCheckpoint(c RekorCheckpoint, proof ConsistencyProof) error {
+	timestamp, err := checkpointTimestamp(c)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if timestamp != 0 && timestamp < w.timestamp {
+		return fmt.Errorf("%w: %d before %d", ErrTimestampRollback, timestamp, w.timestamp)
+	}
+	return w.update(c.TreeHead, timestamp, proof)
+}
+
+func (w *Witness) update(head TreeHead, timestamp uint64, proof ConsistencyProof) error {
+	switch {
+	case head.TreeSize < w.head.TreeSize:
+		return fmt.Errorf("%w: size %d, trusted size %d", ErrRollback, head.TreeSize, w.head.TreeSize)
+	case head.TreeSize == w.head.TreeSize && head.RootHash != w.head.RootHash:
+		return fmt.Errorf("%w: size %d", ErrFork, head.TreeSize)
+	case head.TreeSize > w.head.TreeSize:
+		if proof.OldSize != w.head.TreeSize || proof.NewSize != head.TreeSize {
+			return ErrInvalidRange
+		}
+		if err := VerifyConsistency(w.head.RootHash, head.RootHash, proof); err != nil {
+			return err
+		}
+	}
+
+	next := &Witness{head: head, timestamp: w.timestamp}
+	if timestamp > w.timestamp {
+		next.timestamp = timestamp
+	}
+	if w.path != "" {
+		if err := next.Save(w.path); err != nil {
+			return err
+		}
+	}
+	w.head, w.timestamp = next.head, next.timestamp
+	return nil
+}
+
+// checkpointTimestamp returns the timestamp of the "Timestamp: " extension line of
+// c, or 0 without one
+func checkpointTimestamp(c RekorCheckpoint) (uint64, error) {
+	for _, line := range c.Other {
+		if strings.HasPrefix(line, "Timestamp: ") {
+			timestamp, err := strconv.ParseUint(strings.TrimPrefix(line, "Timestamp: "), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: timestamp: %v", ErrInvalidCheckpoint, err)
+			}
+			return timestamp, nil
+		}
+	}
+	return 0, nil
+}
+
+// Save atomically replaces the file at path with the state of the witness
+func (w *Witness) Save(path string) error {
+	b, err := w.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// MarshalBinary returns the state of the witness: the trusted tree head and
+// timestamp, followed by a CRC-32C checksum
+func (w *Witness) MarshalBinary() ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	b := []byte(witnessMagic)
+	b = append(b, witnessVersion)
+	b = binary.BigEndian.AppendUint64(b, w.head.TreeSize)
+	b = append(b, w.head.RootHash[:]...)
+	b = binary.BigEndian.AppendUint64(b, w.timestamp)
+	return binary.BigEndian.AppendUint32(b, crc32.Checksum(b, crcTable)), nil
+}
+
+// UnmarshalBinary restores the state of the witness from data returned by MarshalBinary
+func (w *Witness) UnmarshalBinary(data []byte) error {
+	const size = len(witnessMagic) + 1 + 8 + sha256.Size + 8 + 4
+	if len(data) != size || !bytes.Equal(data[:len(witnessMagic)], []byte(witnessMagic)) {
+		return fmt.Errorf("%w: invalid state", ErrCorruptWitness)
+	}
+	body, checksum := data[:size-4], binary.BigEndian.Uint32(data[size-4:])
+	if crc32.Checksum(body, crcTable) != checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorruptWitness)
+	}
+	if body[len(witnessMagic)] != witnessVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrCorruptWitness, body[len(witnessMagic)])
+	}
+
+	body = body[len(witnessMagic)+1:]
+	var head TreeHead
+	head.TreeSize = binary.BigEndian.Uint64(body)
+	copy(head.RootHash[:], body[8:])
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.head, w.timestamp = head, binary.BigEndian.Uint64(body[8+sha256.Size:])
+	return nil
+}
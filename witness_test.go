@@ -0,0 +1,102 @@
+package merkletree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWitnessMonotonicity(t *testing.T) {
+	D := makeEntries(12)
+	tree := New(D)
+	w := NewWitness(TreeHead{TreeSize: 5, RootHash: MTH(D[:5])})
+
+	proof, err := tree.ConsistencyProof(5, 8)
+	assert.NoError(t, err)
+	head8 := TreeHead{TreeSize: 8, RootHash: MTH(D[:8])}
+	assert.NoError(t, w.Update(head8, proof))
+
+	// The trusted head again is fine, a smaller or forked one is not
+	assert.NoError(t, w.Update(head8, ConsistencyProof{}))
+	back, err := tree.ConsistencyProof(5, 8)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, w.Update(TreeHead{TreeSize: 5, RootHash: MTH(D[:5])}, back), ErrRollback)
+	forked := TreeHead{TreeSize: 8, RootHash: MTH(append(makeEntries(7), []byte("forged")))}
+	assert.ErrorIs(t, w.Update(forked, ConsistencyProof{OldSize: 8, NewSize: 8}), ErrFork)
+	assert.Equal(t, head8, w.TreeHead())
+}
+
+func TestWitnessCheckpointTimestamps(t *testing.T) {
+	D := makeEntries(12)
+	tree := New(D)
+	w := NewWitness(TreeHead{TreeSize: 5, RootHash: MTH(D[:5])})
+
+	checkpoint := func(size int, timestamp string) RekorCheckpoint {
+		return RekorCheckpoint{Origin: "log", TreeHead: TreeHead{TreeSize: uint64(size), RootHash: MTH(D[:size])}, Other: []string{"Timestamp: " + timestamp}}
+	}
+	proof, err := tree.ConsistencyProof(5, 8)
+	assert.NoError(t, err)
+	assert.NoError(t, w.UpdateCheckpoint(checkpoint(8, "2000"), proof))
+	assert.Equal(t, uint64(2000), w.Timestamp())
+
+	proof, err = tree.ConsistencyProof(8, 10)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, w.UpdateCheckpoint(checkpoint(10, "1999"), proof), ErrTimestampRollback)
+	assert.ErrorIs(t, w.UpdateCheckpoint(checkpoint(10, "soon"), proof), ErrInvalidCheckpoint)
+	assert.Equal(t, uint64(8), w.TreeHead().TreeSize)
+
+	// Checkpoints without a timestamp only have to be consistent
+	c := checkpoint(10, "")
+	c.Other = nil
+	assert.NoError(t, w.UpdateCheckpoint(c, proof))
+	assert.Equal(t, uint64(2000), w.Timestamp())
+	assert.NoError(t, w.UpdateCheckpoint(checkpoint(10, "2000"), ConsistencyProof{}))
+}
+
+func TestWitnessPersistence(t *testing.T) {
+	D := makeEntries(12)
+	tree := New(D)
+	path := filepath.Join(t.TempDir(), "witness")
+
+	w, err := OpenWitness(path, TreeHead{TreeSize: 5, RootHash: MTH(D[:5])})
+	assert.NoError(t, err)
+	proof, err := tree.ConsistencyProof(5, 9)
+	assert.NoError(t, err)
+	c := RekorCheckpoint{Origin: "log", TreeHead: TreeHead{TreeSize: 9, RootHash: MTH(D[:9])}, Other: []string{"Timestamp: 7"}}
+	assert.NoError(t, w.UpdateCheckpoint(c, proof))
+
+	// After a restart the witness trusts what it trusted before, not the initial head
+	restarted, err := OpenWitness(path, TreeHead{TreeSize: 5, RootHash: MTH(D[:5])})
+	assert.NoError(t, err)
+	assert.Equal(t, c.TreeHead, restarted.TreeHead())
+	assert.Equal(t, uint64(7), restarted.Timestamp())
+
+	// A rollback to the old state is refused and leaves the file untouched
+	saved, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	old, err := tree.ConsistencyProof(5, 9)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, restarted.Update(TreeHead{TreeSize: 5, RootHash: MTH(D[:5])}, old), ErrRollback)
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, after)
+
+	proof, err = tree.ConsistencyProof(9, 12)
+	assert.NoError(t, err)
+	assert.NoError(t, restarted.Update(tree.TreeHead(), proof))
+	loaded, err := LoadWitness(path)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.TreeHead(), loaded.TreeHead())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	after[10] ^= 1
+	assert.NoError(t, os.WriteFile(path, after, 0o644))
+	_, err = LoadWitness(path)
+	assert.ErrorIs(t, err, ErrCorruptWitness)
+	_, err = LoadWitness(filepath.Join(filepath.Dir(path), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}